@@ -0,0 +1,358 @@
+// Package cache provides in-memory caches in front of slower repositories.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+const (
+	defaultSessionCacheSize = 1024
+	defaultSessionCacheTTL  = 10 * time.Minute
+)
+
+// SessionCacheConfig holds configuration for the SessionCache
+// Optional fields with defaults:
+// - Size: Maximum number of devices whose last session is cached (default: 1024)
+// - TTL: How long a cached session is served without reading the database (default: 10m)
+type SessionCacheConfig struct {
+	Size int           // Optional: Maximum number of devices whose last session is cached
+	TTL  time.Duration // Optional: How long a cached session is served without reading the database
+}
+
+// NewSessionCacheConfigFromEnv creates a new SessionCacheConfig from environment variables
+// This is a helper function to simplify the creation of a properly configured SessionCacheConfig
+func NewSessionCacheConfigFromEnv() SessionCacheConfig {
+	config := SessionCacheConfig{}
+
+	if sizeStr := os.Getenv("SESSION_CACHE_SIZE"); sizeStr != "" {
+		if size, err := strconv.Atoi(sizeStr); err == nil && size > 0 {
+			config.Size = size
+		}
+	}
+
+	if ttlStr := os.Getenv("SESSION_CACHE_TTL_SECONDS"); ttlStr != "" {
+		if ttl, err := strconv.Atoi(ttlStr); err == nil && ttl > 0 {
+			config.TTL = time.Duration(ttl) * time.Second
+		}
+	}
+
+	return config
+}
+
+// SessionCacheStats reports the effectiveness of the cache
+type SessionCacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Size      int   `json:"size"`
+}
+
+// SessionCache is a bounded LRU cache of the last session of each device in
+// front of a SessionRepository. Writes go through to the repository first
+// and the cache is only updated once they succeed. Any write-behind queue
+// must be part of the wrapped repository so that the cache only ever
+// reflects writes the queue accepted.
+type SessionCache struct {
+	repo repositories.SessionRepository
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Front is the most recently used
+	stats   SessionCacheStats
+
+	// Devices whose sessions are being deleted, the children whose sessions
+	// are being erased, and the deletions started or finished so far. A read
+	// that raced a deletion is not cached, so that it never serves a session
+	// that was erased.
+	deleting  map[string]int
+	erasing   int
+	deletions uint64
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// cacheEntry is the cached last session of a device
+type cacheEntry struct {
+	deviceID  string
+	session   *entities.Session
+	expiresAt time.Time
+}
+
+// Ensure SessionCache implements the SessionRepository, ChildSessionFinder and ChildSessionEraser interfaces
+var (
+	_ repositories.SessionRepository  = (*SessionCache)(nil)
+	_ repositories.ChildSessionFinder = (*SessionCache)(nil)
+	_ repositories.ChildSessionEraser = (*SessionCache)(nil)
+)
+
+// NewSessionCache creates a new session cache in front of repo
+func NewSessionCache(config SessionCacheConfig, repo repositories.SessionRepository, logger *zap.Logger) *SessionCache {
+	// Apply defaults where needed
+	if config.Size == 0 {
+		config.Size = defaultSessionCacheSize
+		logger.Info("Using default session cache size", zap.Int("size", config.Size))
+	}
+
+	if config.TTL == 0 {
+		config.TTL = defaultSessionCacheTTL
+		logger.Info("Using default session cache TTL", zap.Duration("ttl", config.TTL))
+	}
+
+	return &SessionCache{
+		repo:     repo,
+		size:     config.Size,
+		ttl:      config.TTL,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		deleting: make(map[string]int),
+		now:      time.Now,
+	}
+}
+
+// copySession creates a copy of a session so that callers never share the
+// cached instance
+func copySession(session *entities.Session) *entities.Session {
+	copied := *session
+	copied.Messages = append([]entities.Message(nil), session.Messages...)
+	if session.Metadata.UserPreferences != nil {
+		copied.Metadata.UserPreferences = make(map[string]interface{}, len(session.Metadata.UserPreferences))
+		for k, v := range session.Metadata.UserPreferences {
+			copied.Metadata.UserPreferences[k] = v
+		}
+	}
+	return &copied
+}
+
+// Create implements repositories.SessionRepository. A new session becomes
+// the device's last session, replacing the one rolled over from.
+func (c *SessionCache) Create(ctx context.Context, session *entities.Session) error {
+	if err := c.repo.Create(ctx, session); err != nil {
+		c.invalidate(session.DeviceID)
+		return err
+	}
+	c.store(session)
+	return nil
+}
+
+// GetLastByDeviceID implements repositories.SessionRepository
+func (c *SessionCache) GetLastByDeviceID(ctx context.Context, deviceID string) (*entities.Session, error) {
+	c.mu.Lock()
+	if elem, ok := c.entries[deviceID]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if c.now().Before(entry.expiresAt) {
+			c.order.MoveToFront(elem)
+			c.stats.Hits++
+			session := copySession(entry.session)
+			c.mu.Unlock()
+			return session, nil
+		}
+		c.removeElement(elem)
+	}
+	c.stats.Misses++
+	deletions := c.deletions
+	c.mu.Unlock()
+
+	session, err := c.repo.GetLastByDeviceID(ctx, deviceID)
+	if err != nil || session == nil {
+		return session, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.deletions == deletions && c.deleting[deviceID] == 0 && c.erasing == 0 {
+		c.storeLocked(session)
+	}
+	return session, nil
+}
+
+// GetLastByChildID implements repositories.ChildSessionFinder. The last
+// session of a device need not be the last one of its child, so lookups
+// always reach the wrapped repository, which must find sessions by child.
+func (c *SessionCache) GetLastByChildID(ctx context.Context, childID string) (*entities.Session, error) {
+	finder, ok := c.repo.(repositories.ChildSessionFinder)
+	if !ok {
+		return nil, errors.New("cached session repository cannot find sessions by child")
+	}
+	return finder.GetLastByChildID(ctx, childID)
+}
+
+// Update implements repositories.SessionRepository
+func (c *SessionCache) Update(ctx context.Context, session *entities.Session) error {
+	if err := c.repo.Update(ctx, session); err != nil {
+		// The stored state is unknown
+		c.invalidate(session.DeviceID)
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[session.DeviceID]; ok && elem.Value.(*cacheEntry).session.ID != session.ID {
+		// An older session was updated and may now sort as the last one
+		c.removeElement(elem)
+		return nil
+	}
+	c.storeLocked(session)
+	return nil
+}
+
+// DeleteByDeviceID implements repositories.SessionRepository. The sessions
+// read while they are deleted are not cached.
+func (c *SessionCache) DeleteByDeviceID(ctx context.Context, deviceID string) (int64, error) {
+	c.mu.Lock()
+	c.deleting[deviceID]++
+	c.deletions++
+	if elem, ok := c.entries[deviceID]; ok {
+		c.removeElement(elem)
+	}
+	c.mu.Unlock()
+
+	deleted, err := c.repo.DeleteByDeviceID(ctx, deviceID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleting[deviceID]--
+	if c.deleting[deviceID] == 0 {
+		delete(c.deleting, deviceID)
+	}
+	c.deletions++
+	// Written while the sessions were deleted
+	if elem, ok := c.entries[deviceID]; ok {
+		c.removeElement(elem)
+	}
+	return deleted, err
+}
+
+// DeleteByChildID implements repositories.ChildSessionEraser. The child's
+// sessions may be cached under any device, so every cached session of the
+// child is dropped, and no session read meanwhile is cached.
+func (c *SessionCache) DeleteByChildID(ctx context.Context, childID string) (int64, error) {
+	eraser, ok := c.repo.(repositories.ChildSessionEraser)
+	if !ok {
+		return 0, errors.New("cached session repository cannot erase sessions by child")
+	}
+
+	c.mu.Lock()
+	c.erasing++
+	c.deletions++
+	c.removeChildLocked(childID)
+	c.mu.Unlock()
+
+	deleted, err := eraser.DeleteByChildID(ctx, childID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.erasing--
+	c.deletions++
+	// Written while the sessions were erased
+	c.removeChildLocked(childID)
+	return deleted, err
+}
+
+// Search implements repositories.SessionRepository. Searches span every
+// session of a device, so they always reach the wrapped repository.
+func (c *SessionCache) Search(ctx context.Context, deviceID, query string, limit int) ([]entities.MessageMatch, error) {
+	return c.repo.Search(ctx, deviceID, query, limit)
+}
+
+// ListByDeviceID implements repositories.SessionRepository. Listings span
+// every session of a device, so they always reach the wrapped repository.
+func (c *SessionCache) ListByDeviceID(ctx context.Context, deviceID string, limit int) ([]*entities.Session, error) {
+	return c.repo.ListByDeviceID(ctx, deviceID, limit)
+}
+
+// ExpireSessions implements repositories.SessionRepository. Cached sessions
+// that expire are dropped so that they are read back with their end time.
+func (c *SessionCache) ExpireSessions(ctx context.Context, cutoff time.Time) (int64, error) {
+	expired, err := c.repo.ExpireSessions(ctx, cutoff)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*cacheEntry)
+		if entry.session.EndedAt.IsZero() && entry.session.LastMessageAt.Before(cutoff) {
+			c.removeElement(elem)
+		}
+		elem = next
+	}
+
+	return expired, err
+}
+
+// Stats returns the cache counters
+func (c *SessionCache) Stats() SessionCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Size = c.order.Len()
+	return stats
+}
+
+// store caches a copy of session as the device's last session
+func (c *SessionCache) store(session *entities.Session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.storeLocked(session)
+}
+
+// storeLocked is store for callers holding the mutex
+func (c *SessionCache) storeLocked(session *entities.Session) {
+	entry := &cacheEntry{
+		deviceID:  session.DeviceID,
+		session:   copySession(session),
+		expiresAt: c.now().Add(c.ttl),
+	}
+
+	if elem, ok := c.entries[session.DeviceID]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[session.DeviceID] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// invalidate drops the cached session of a device
+func (c *SessionCache) invalidate(deviceID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[deviceID]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// removeChildLocked drops the cached sessions of a child. The caller must
+// hold the mutex.
+func (c *SessionCache) removeChildLocked(childID string) {
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*cacheEntry).session.ChildID == childID {
+			c.removeElement(elem)
+		}
+		elem = next
+	}
+}
+
+// removeElement removes an entry. The caller must hold the mutex.
+func (c *SessionCache) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).deviceID)
+}
@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// countingRepository counts the reads reaching the wrapped repository
+type countingRepository struct {
+	*conversationtest.SessionRepository
+	reads int
+}
+
+func (r *countingRepository) GetLastByDeviceID(ctx context.Context, deviceID string) (*entities.Session, error) {
+	r.reads++
+	return r.SessionRepository.GetLastByDeviceID(ctx, deviceID)
+}
+
+func newTestCache(t *testing.T, config SessionCacheConfig) (*SessionCache, *countingRepository) {
+	t.Helper()
+	repo := &countingRepository{SessionRepository: &conversationtest.SessionRepository{}}
+	return NewSessionCache(config, repo, zaptest.NewLogger(t)), repo
+}
+
+func TestSessionCache_HitAvoidsRepository(t *testing.T) {
+	ctx := context.Background()
+	cache, repo := newTestCache(t, SessionCacheConfig{})
+	repo.SessionRepository.Create(ctx, &entities.Session{DeviceID: "device-1"})
+
+	first, err := cache.GetLastByDeviceID(ctx, "device-1")
+	if err != nil || first == nil {
+		t.Fatalf("Expected a session, got %v, %v", first, err)
+	}
+	second, _ := cache.GetLastByDeviceID(ctx, "device-1")
+
+	if repo.reads != 1 {
+		t.Errorf("Expected a single repository read, got %d", repo.reads)
+	}
+	if second.ID != first.ID {
+		t.Errorf("Expected the cached session %q, got %q", first.ID, second.ID)
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 || stats.Size != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// Callers must not share the cached instance
+	second.Messages = append(second.Messages, entities.Message{Content: "not saved"})
+	third, _ := cache.GetLastByDeviceID(ctx, "device-1")
+	if len(third.Messages) != 0 {
+		t.Error("Expected unsaved changes not to leak into the cache")
+	}
+}
+
+func TestSessionCache_WriteThroughOnUpdate(t *testing.T) {
+	ctx := context.Background()
+	cache, repo := newTestCache(t, SessionCacheConfig{})
+
+	session := &entities.Session{DeviceID: "device-1"}
+	if err := cache.Create(ctx, session); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	session.Messages = append(session.Messages, entities.Message{Role: entities.UserRole, Content: "halo"})
+	if err := cache.Update(ctx, session); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+
+	cached, _ := cache.GetLastByDeviceID(ctx, "device-1")
+	if len(cached.Messages) != 1 || repo.reads != 0 {
+		t.Errorf("Expected the updated session from the cache, got %d messages after %d reads", len(cached.Messages), repo.reads)
+	}
+}
+
+func TestSessionCache_Invalidation(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("update of an older session", func(t *testing.T) {
+		cache, repo := newTestCache(t, SessionCacheConfig{})
+		old := &entities.Session{DeviceID: "device-1"}
+		cache.Create(ctx, old)
+		cache.Create(ctx, &entities.Session{DeviceID: "device-1"})
+
+		cache.Update(ctx, old)
+		cache.GetLastByDeviceID(ctx, "device-1")
+
+		if repo.reads != 1 {
+			t.Errorf("Expected the cache to be invalidated, got %d reads", repo.reads)
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		cache, repo := newTestCache(t, SessionCacheConfig{TTL: time.Minute})
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+		cache.Create(ctx, &entities.Session{DeviceID: "device-1"})
+
+		now = now.Add(2 * time.Minute)
+		cache.GetLastByDeviceID(ctx, "device-1")
+
+		if repo.reads != 1 {
+			t.Errorf("Expected an expired entry to be read again, got %d reads", repo.reads)
+		}
+	})
+
+	t.Run("deletion", func(t *testing.T) {
+		cache, _ := newTestCache(t, SessionCacheConfig{})
+		cache.Create(ctx, &entities.Session{DeviceID: "device-1"})
+		cache.DeleteByDeviceID(ctx, "device-1")
+
+		if session, _ := cache.GetLastByDeviceID(ctx, "device-1"); session != nil {
+			t.Errorf("Expected deleted sessions not to be served, got %+v", session)
+		}
+	})
+
+	t.Run("erasure of a child", func(t *testing.T) {
+		cache, _ := newTestCache(t, SessionCacheConfig{})
+		cache.Create(ctx, &entities.Session{DeviceID: "device-old", ChildID: "child-1"})
+		cache.Create(ctx, &entities.Session{DeviceID: "device-2", ChildID: "child-2"})
+
+		if deleted, err := cache.DeleteByChildID(ctx, "child-1"); err != nil || deleted != 1 {
+			t.Fatalf("Expected the child's session to be erased, got %d, %v", deleted, err)
+		}
+
+		if session, _ := cache.GetLastByDeviceID(ctx, "device-old"); session != nil {
+			t.Errorf("Expected erased sessions not to be served, got %+v", session)
+		}
+		if session, _ := cache.GetLastByDeviceID(ctx, "device-2"); session == nil {
+			t.Error("Expected the session of another child to be kept")
+		}
+	})
+}
+
+func TestSessionCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	cache, repo := newTestCache(t, SessionCacheConfig{Size: 2})
+	cache.Create(ctx, &entities.Session{DeviceID: "device-1"})
+	cache.Create(ctx, &entities.Session{DeviceID: "device-2"})
+	cache.GetLastByDeviceID(ctx, "device-1")
+	cache.Create(ctx, &entities.Session{DeviceID: "device-3"})
+
+	cache.GetLastByDeviceID(ctx, "device-1")
+	if repo.reads != 0 {
+		t.Errorf("Expected the recently used device to stay cached")
+	}
+	cache.GetLastByDeviceID(ctx, "device-2")
+	if repo.reads != 1 {
+		t.Errorf("Expected the least recently used device to be evicted")
+	}
+	if stats := cache.Stats(); stats.Evictions < 1 || stats.Size > 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestSessionCache_ExpireSessionsDropsExpiredEntries(t *testing.T) {
+	ctx := context.Background()
+	cache, repo := newTestCache(t, SessionCacheConfig{})
+	repo.SessionRepository.Create(ctx, &entities.Session{DeviceID: "device-1", LastMessageAt: time.Now().Add(-time.Hour)})
+	repo.SessionRepository.Create(ctx, &entities.Session{DeviceID: "device-2", LastMessageAt: time.Now()})
+	cache.GetLastByDeviceID(ctx, "device-1")
+	cache.GetLastByDeviceID(ctx, "device-2")
+
+	expired, err := cache.ExpireSessions(ctx, time.Now().Add(-entities.SessionIdleTimeout))
+	if err != nil || expired != 1 {
+		t.Fatalf("Expected one expired session, got %d, %v", expired, err)
+	}
+
+	session, _ := cache.GetLastByDeviceID(ctx, "device-1")
+	if session.EndedAt.IsZero() {
+		t.Error("Expected the expired session to be read back with its end time")
+	}
+	cache.GetLastByDeviceID(ctx, "device-2")
+	if repo.reads != 3 {
+		t.Errorf("Expected only the expired session to be read again, got %d reads", repo.reads)
+	}
+}
+
+// blockingReadRepository holds reads until released, so that a deletion can
+// run while a read is in flight
+type blockingReadRepository struct {
+	*conversationtest.SessionRepository
+	reading chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingReadRepository) GetLastByDeviceID(ctx context.Context, deviceID string) (*entities.Session, error) {
+	session, err := r.SessionRepository.GetLastByDeviceID(ctx, deviceID)
+	r.reading <- struct{}{}
+	<-r.release
+	return session, err
+}
+
+// A read racing a deletion never puts the erased session back in the cache
+func TestSessionCache_ReadDuringDeleteIsNotCached(t *testing.T) {
+	ctx := context.Background()
+	repo := &blockingReadRepository{
+		SessionRepository: &conversationtest.SessionRepository{},
+		reading:           make(chan struct{}),
+		release:           make(chan struct{}),
+	}
+	cache := NewSessionCache(SessionCacheConfig{}, repo, zaptest.NewLogger(t))
+	repo.SessionRepository.Create(ctx, &entities.Session{DeviceID: "device-1"})
+
+	read := make(chan struct{})
+	go func() {
+		cache.GetLastByDeviceID(ctx, "device-1")
+		close(read)
+	}()
+	// The session was read before the deletion and is returned after it
+	<-repo.reading
+	if _, err := cache.DeleteByDeviceID(ctx, "device-1"); err != nil {
+		t.Fatalf("Failed to delete sessions: %v", err)
+	}
+	close(repo.release)
+	<-read
+
+	if stats := cache.Stats(); stats.Size != 0 {
+		t.Errorf("Expected the erased session not cached, got %+v", stats)
+	}
+	go func() { <-repo.reading }()
+	if session, err := cache.GetLastByDeviceID(ctx, "device-1"); err != nil || session != nil {
+		t.Errorf("Expected no session after the deletion, got %v, %v", session, err)
+	}
+}
@@ -0,0 +1,50 @@
+// Package deterministic provides speech services that never call an
+// external API and always produce the same output. They exist for
+// integration tests and are only served when explicitly enabled.
+package deterministic
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultTranscript    = "halo"
+	defaultAudioDuration = 500 * time.Millisecond
+	defaultSampleRate    = 24000
+	defaultChunkSize     = 1024
+)
+
+// Config holds configuration for the deterministic speech services
+// Optional fields with defaults:
+// - Enabled: Let connections opt in to the deterministic services (default: false)
+// - Transcript: The text every transcription returns (default: "halo")
+// - AudioDuration: The length of the silence every synthesis returns (default: 500ms)
+type Config struct {
+	Enabled       bool          // Optional: Let connections opt in to the deterministic services
+	Transcript    string        // Optional: The text every transcription returns
+	AudioDuration time.Duration // Optional: The length of the silence every synthesis returns
+}
+
+// NewConfigFromEnv creates a new Config from environment variables
+// This is a helper function to simplify the creation of a properly configured Config
+func NewConfigFromEnv() Config {
+	config := Config{
+		Transcript: os.Getenv("DETERMINISTIC_TRANSCRIPT"),
+	}
+
+	if enabledStr := os.Getenv("DETERMINISTIC_MODE_ENABLED"); enabledStr != "" {
+		if enabled, err := strconv.ParseBool(enabledStr); err == nil {
+			config.Enabled = enabled
+		}
+	}
+
+	if durationStr := os.Getenv("DETERMINISTIC_AUDIO_DURATION_MS"); durationStr != "" {
+		if duration, err := strconv.Atoi(durationStr); err == nil && duration > 0 {
+			config.AudioDuration = time.Duration(duration) * time.Millisecond
+		}
+	}
+
+	return config
+}
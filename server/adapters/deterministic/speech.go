@@ -0,0 +1,121 @@
+package deterministic
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// Ensure the services implement the repository interfaces
+var (
+	_ repositories.SpeechToText          = (*SpeechToText)(nil)
+	_ repositories.TextToSpeech          = (*TextToSpeech)(nil)
+	_ repositories.SpeechToTextStreaming = (*transcription)(nil)
+)
+
+// SpeechToText transcribes any audio as the configured transcript
+type SpeechToText struct {
+	transcript string
+}
+
+// NewSpeechToText creates a SpeechToText with defaults applied to config
+func NewSpeechToText(config Config, logger *zap.Logger) *SpeechToText {
+	if config.Transcript == "" {
+		config.Transcript = defaultTranscript
+		logger.Info("Using default deterministic transcript", zap.String("transcript", config.Transcript))
+	}
+	return &SpeechToText{transcript: config.Transcript}
+}
+
+// TranscribeAudio implements repositories.SpeechToText
+func (s *SpeechToText) TranscribeAudio(ctx context.Context, audioData []byte, config repositories.AudioConfig) (string, error) {
+	return s.transcript, nil
+}
+
+// InitTranscribeStreaming implements repositories.SpeechToText
+func (s *SpeechToText) InitTranscribeStreaming(ctx context.Context, config repositories.AudioConfig) (repositories.SpeechToTextStreaming, error) {
+	return &transcription{transcript: s.transcript}, nil
+}
+
+// transcription accepts audio until it is ended
+type transcription struct {
+	mu         sync.Mutex
+	transcript string
+	ended      bool
+}
+
+// Stream implements repositories.SpeechToTextStreaming
+func (t *transcription) Stream(data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ended {
+		return errors.New("transcription already ended")
+	}
+	return nil
+}
+
+// End implements repositories.SpeechToTextStreaming
+func (t *transcription) End() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ended {
+		return "", errors.New("transcription already ended")
+	}
+	t.ended = true
+	return t.transcript, nil
+}
+
+// Close implements repositories.SpeechToTextStreaming
+func (t *transcription) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ended = true
+	return nil
+}
+
+// TextToSpeech synthesizes any text as silence of the configured length,
+// as 16-bit mono PCM at the requested sample rate
+type TextToSpeech struct {
+	duration time.Duration
+}
+
+// NewTextToSpeech creates a TextToSpeech with defaults applied to config
+func NewTextToSpeech(config Config, logger *zap.Logger) *TextToSpeech {
+	if config.AudioDuration == 0 {
+		config.AudioDuration = defaultAudioDuration
+		logger.Info("Using default deterministic audio duration", zap.Duration("audioDuration", config.AudioDuration))
+	}
+	return &TextToSpeech{duration: config.AudioDuration}
+}
+
+// ConvertTextToSpeech implements repositories.TextToSpeech
+func (s *TextToSpeech) ConvertTextToSpeech(ctx context.Context, text string) (<-chan []byte, error) {
+	if text == "" {
+		return nil, errors.New("text cannot be empty")
+	}
+
+	sampleRate, ok := repositories.SampleRateFromContext(ctx)
+	if !ok {
+		sampleRate = defaultSampleRate
+	}
+	chunkSize, ok := repositories.ChunkSizeFromContext(ctx)
+	if !ok {
+		chunkSize = defaultChunkSize
+	}
+
+	// Two bytes per sample
+	remaining := int(int64(sampleRate)*int64(s.duration)/int64(time.Second)) * 2
+	audio := make(chan []byte, remaining/chunkSize+1)
+	for remaining > 0 {
+		size := min(chunkSize, remaining)
+		audio <- make([]byte, size)
+		remaining -= size
+	}
+	close(audio)
+	return audio, nil
+}
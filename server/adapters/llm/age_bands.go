@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// AgeBand tailors response length and vocabulary to children within an age
+// range (inclusive)
+type AgeBand struct {
+	MinAge          int    `json:"min_age"`
+	MaxAge          int    `json:"max_age"`
+	MaxSentences    int    `json:"max_sentences"`
+	ReadingLevel    string `json:"reading_level"`
+	MaxOutputTokens int    `json:"max_output_tokens"`
+}
+
+// defaultAgeBands covers the ages the doll is designed for
+var defaultAgeBands = []AgeBand{
+	{MinAge: 2, MaxAge: 5, MaxSentences: 1, ReadingLevel: "a preschooler: very short, concrete words", MaxOutputTokens: 60},
+	{MinAge: 6, MaxAge: 8, MaxSentences: 2, ReadingLevel: "an early reader: simple everyday words", MaxOutputTokens: 120},
+	{MinAge: 9, MaxAge: 12, MaxSentences: 4, ReadingLevel: "an upper primary student: clear words, new ideas explained briefly", MaxOutputTokens: 250},
+}
+
+// ParseAgeBands parses age bands encoded as a JSON array, e.g.
+// [{"min_age": 2, "max_age": 5, "max_sentences": 1, "reading_level": "preschooler", "max_output_tokens": 60}]
+func ParseAgeBands(data string) ([]AgeBand, error) {
+	var bands []AgeBand
+	if err := json.Unmarshal([]byte(data), &bands); err != nil {
+		return nil, fmt.Errorf("failed to parse age bands: %w", err)
+	}
+	if err := ValidateAgeBands(bands); err != nil {
+		return nil, err
+	}
+	return bands, nil
+}
+
+// ValidateAgeBands checks that every band is well formed and that no two
+// bands claim the same age
+func ValidateAgeBands(bands []AgeBand) error {
+	sorted := append([]AgeBand(nil), bands...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MinAge < sorted[j].MinAge })
+
+	for i, band := range sorted {
+		if band.MinAge < 0 || band.MaxAge < band.MinAge {
+			return fmt.Errorf("age band %d-%d has an invalid range", band.MinAge, band.MaxAge)
+		}
+		if band.MaxSentences <= 0 {
+			return fmt.Errorf("age band %d-%d must allow at least one sentence", band.MinAge, band.MaxAge)
+		}
+		if band.MaxOutputTokens <= 0 {
+			return fmt.Errorf("age band %d-%d must allow at least one output token", band.MinAge, band.MaxAge)
+		}
+		if i > 0 && band.MinAge <= sorted[i-1].MaxAge {
+			return fmt.Errorf("age band %d-%d overlaps %d-%d", band.MinAge, band.MaxAge, sorted[i-1].MinAge, sorted[i-1].MaxAge)
+		}
+	}
+	return nil
+}
+
+// ageBandFor returns the band covering age, or nil when no band does
+func ageBandFor(bands []AgeBand, age int) *AgeBand {
+	for i := range bands {
+		if age >= bands[i].MinAge && age <= bands[i].MaxAge {
+			return &bands[i]
+		}
+	}
+	return nil
+}
+
+// lengthGuidance is the system prompt line limiting response length
+func (b *AgeBand) lengthGuidance() string {
+	sentences := "1 sentence"
+	if b.MaxSentences > 1 {
+		sentences = fmt.Sprintf("%d sentences", b.MaxSentences)
+	}
+	return fmt.Sprintf("SHORT and simple - maximum %s, written for %s", sentences, b.ReadingLevel)
+}
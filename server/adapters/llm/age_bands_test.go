@@ -0,0 +1,71 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+func TestValidateAgeBands(t *testing.T) {
+	if err := ValidateAgeBands(defaultAgeBands); err != nil {
+		t.Errorf("Expected the default bands to be valid, got %v", err)
+	}
+
+	invalid := map[string][]AgeBand{
+		"inverted range": {{MinAge: 8, MaxAge: 6, MaxSentences: 1, MaxOutputTokens: 50}},
+		"no sentences":   {{MinAge: 2, MaxAge: 5, MaxSentences: 0, MaxOutputTokens: 50}},
+		"no tokens":      {{MinAge: 2, MaxAge: 5, MaxSentences: 1, MaxOutputTokens: 0}},
+		"overlapping": {
+			{MinAge: 6, MaxAge: 9, MaxSentences: 2, MaxOutputTokens: 100},
+			{MinAge: 2, MaxAge: 6, MaxSentences: 1, MaxOutputTokens: 50},
+		},
+	}
+	for name, bands := range invalid {
+		if err := ValidateAgeBands(bands); err == nil {
+			t.Errorf("Expected %s bands to be rejected", name)
+		}
+	}
+
+	if _, err := ParseAgeBands(`[{"min_age": 2, "max_age": 5, "max_sentences": 0, "max_output_tokens": 60}]`); err == nil {
+		t.Errorf("Expected ParseAgeBands to validate the parsed bands")
+	}
+}
+
+func TestGeminiLLM_AppliesAgeBand(t *testing.T) {
+	g := &GeminiLLM{
+		logger: zaptest.NewLogger(t),
+		config: GeminiConfig{APIKey: "key", MaxOutputTokens: 500},
+	}
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		maxTokens int
+		guidance  string
+	}{
+		{"preschooler", repositories.WithChildAge(context.Background(), 4), 60, "maximum 1 sentence, written for a preschooler"},
+		{"upper primary", repositories.WithChildAge(context.Background(), 11), 250, "maximum 4 sentences, written for an upper primary student"},
+		{"unknown age", context.Background(), 500, defaultLengthGuidance},
+		{"outside every band", repositories.WithChildAge(context.Background(), 15), 500, defaultLengthGuidance},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chat, err := g.GenerateChat(tt.ctx, nil)
+			if err != nil {
+				t.Fatalf("Failed to generate chat: %v", err)
+			}
+			session := chat.(*GeminiChatSession)
+			if session.maxOutputTokens != tt.maxTokens {
+				t.Errorf("Expected max output tokens %d, got %d", tt.maxTokens, session.maxOutputTokens)
+			}
+			if !strings.Contains(session.systemPrompt, tt.guidance) {
+				t.Errorf("Expected prompt guidance %q, got:\n%s", tt.guidance, session.systemPrompt)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// contextSummaryMaxOutputTokens bounds the summary a fresh chat context is
+// seeded with
+const contextSummaryMaxOutputTokens = 400
+
+// contextSummaryPrompt asks for a summary the doll can continue a long
+// conversation from
+const contextSummaryPrompt = `The following is a conversation between a child and a talking doll. The doll will continue talking with the child without seeing it.
+Write a brief summary of it for the doll, in the language of the conversation, in at most six sentences: what the child told about themselves, what they like, the games or stories in progress, and what you talked about last.
+Answer only with the summary.
+%s
+Conversation:
+%s`
+
+// contextSummaryRequest returns the summary prompt of transcript, continuing
+// from the summary of the conversation before it, if any
+func contextSummaryRequest(summary, transcript string) string {
+	earlier := ""
+	if summary = strings.TrimSpace(summary); summary != "" {
+		earlier = "\nSummary of the conversation before:\n" + summary + "\n"
+	}
+	return fmt.Sprintf(contextSummaryPrompt, earlier, transcript)
+}
+
+// contextSummaryGuidance returns the system prompt addition continuing the
+// conversation summarized in summary
+func contextSummaryGuidance(summary string) string {
+	return fmt.Sprintf(`
+
+CONVERSATION SO FAR: You have already been talking with the child for a while. This is a summary of what you talked about:
+%s
+Continue the conversation naturally from it. Do not greet the child again as if you just met, and do not mention the summary.`, strings.TrimSpace(summary))
+}
+
+// Ensure GeminiLLM implements the ContextSummarizer interface
+var _ repositories.ContextSummarizer = (*GeminiLLM)(nil)
+
+// SummarizeContext implements repositories.ContextSummarizer
+func (g *GeminiLLM) SummarizeContext(ctx context.Context, summary string, messages []entities.Message) (string, error) {
+	transcript := parentSummaryTranscript(messages, parentSummaryMaxTranscript)
+	if transcript == "" {
+		return "", fmt.Errorf("no messages to summarize")
+	}
+
+	timeoutSeconds := g.config.TimeoutSeconds
+	if timeoutSeconds == 0 {
+		timeoutSeconds = defaultTimeoutSeconds
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	contents := []*genai.Content{genai.NewContentFromText(contextSummaryRequest(summary, transcript), genai.RoleUser)}
+	config := &genai.GenerateContentConfig{
+		Temperature:     genai.Ptr(float32(0)),
+		MaxOutputTokens: contextSummaryMaxOutputTokens,
+	}
+
+	client := g.clients.get(ctx)
+	response, err := client.Models.GenerateContent(ctx, g.config.Model, contents, config)
+	if err != nil {
+		g.clients.report(client, err)
+		return "", fmt.Errorf("failed to summarize chat context: %w", err)
+	}
+
+	answer := strings.TrimSpace(response.Text())
+	if answer == "" {
+		return "", fmt.Errorf("failed to summarize chat context: no summary")
+	}
+	return answer, nil
+}
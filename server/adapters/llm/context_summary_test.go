@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+func TestContextSummaryRequest(t *testing.T) {
+	transcript := "Child: Namaku Kirana\nDoll: Halo Kirana!"
+
+	prompt := contextSummaryRequest("", transcript)
+	if strings.Contains(prompt, "Summary of the conversation before") {
+		t.Errorf("Expected no earlier summary, got:\n%s", prompt)
+	}
+	if !strings.HasSuffix(prompt, "Conversation:\n"+transcript) {
+		t.Errorf("Expected the transcript at the end, got:\n%s", prompt)
+	}
+
+	// A second reset summarizes what the first summary left out too
+	prompt = contextSummaryRequest(" Kirana suka kucing. ", transcript)
+	if !strings.Contains(prompt, "Summary of the conversation before:\nKirana suka kucing.\n") {
+		t.Errorf("Expected the earlier summary, got:\n%s", prompt)
+	}
+}
+
+func TestGeminiLLM_SeedsChatWithContextSummary(t *testing.T) {
+	g := &GeminiLLM{
+		logger: zaptest.NewLogger(t),
+		config: GeminiConfig{APIKey: "key", MaxOutputTokens: 500},
+	}
+
+	ctx := repositories.WithContextSummary(context.Background(), "Kirana suka kucing dan sedang menebak-nebak binatang.")
+	chat, err := g.GenerateChat(ctx, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate chat: %v", err)
+	}
+	prompt := chat.(*GeminiChatSession).systemPrompt
+	if !strings.HasPrefix(prompt, GeminiHardcodedConfig.SystemPrompt) {
+		t.Errorf("Expected the system prompt to be kept, got:\n%s", prompt)
+	}
+	if !strings.Contains(prompt, "CONVERSATION SO FAR") || !strings.Contains(prompt, "Kirana suka kucing dan sedang menebak-nebak binatang.") {
+		t.Errorf("Expected the summary in the prompt, got:\n%s", prompt)
+	}
+
+	chat, err = g.GenerateChat(repositories.WithContextSummary(context.Background(), ""), nil)
+	if err != nil {
+		t.Fatalf("Failed to generate chat: %v", err)
+	}
+	if prompt := chat.(*GeminiChatSession).systemPrompt; prompt != GeminiHardcodedConfig.SystemPrompt {
+		t.Errorf("Expected no guidance for an empty summary, got:\n%s", prompt)
+	}
+}
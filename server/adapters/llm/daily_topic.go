@@ -0,0 +1,18 @@
+package llm
+
+import "fmt"
+
+// dailyTopicGuidance returns the system prompt addition suggesting the theme
+// of the day. The first turn of a session is the doll's greeting, where the
+// theme may be offered; afterwards it only comes up when the child is
+// interested.
+func dailyTopicGuidance(topic string, greeting bool) string {
+	if greeting {
+		return fmt.Sprintf(`
+
+TOPIC OF THE DAY: Today's theme is %s. As you greet the child, you may playfully invite them to explore it together. It is only a suggestion: answer what the child said first, and if they want to talk or play about something else, follow their lead.`, topic)
+	}
+	return fmt.Sprintf(`
+
+TOPIC OF THE DAY: Today's theme is %s. Bring it up only when it fits what the child is talking about or when the conversation runs out of ideas. Never steer the child away from their own interests to get back to it.`, topic)
+}
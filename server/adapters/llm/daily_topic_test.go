@@ -0,0 +1,39 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+func TestGeminiChatSession_SuggestsDailyTopic(t *testing.T) {
+	session, gemini := newMockedChatSession(t,
+		`{"candidates":[{"finishReason":"STOP","content":{"role":"model","parts":[{"text":"Halo juga!"}]}}]}`)
+	message := entities.Message{Role: entities.UserRole, Content: "halo"}
+	ctx := repositories.WithDailyTopic(context.Background(), "planet-planet")
+
+	if _, err := session.SendMessage(ctx, message); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if request := gemini.lastRequest(); !strings.Contains(request, "Today's theme is planet-planet. As you greet the child") {
+		t.Errorf("Expected the greeting to suggest the topic, got:\n%s", request)
+	}
+
+	if _, err := session.SendMessage(ctx, message); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	request := gemini.lastRequest()
+	if !strings.Contains(request, "Today's theme is planet-planet. Bring it up only when") || strings.Contains(request, "As you greet the child") {
+		t.Errorf("Expected later turns to keep the topic in the background, got:\n%s", request)
+	}
+
+	if _, err := session.SendMessage(context.Background(), message); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if request := gemini.lastRequest(); strings.Contains(request, "TOPIC OF THE DAY") {
+		t.Errorf("Expected no topic without one configured, got:\n%s", request)
+	}
+}
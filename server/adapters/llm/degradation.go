@@ -0,0 +1,23 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// degradedGuidance is the system prompt addition keeping responses short
+// while conversations are degraded, so that they are generated and spoken
+// sooner
+const degradedGuidance = `
+
+KEEP IT SHORT: Answer in one or two short sentences.`
+
+// modelFor returns the model answering a request with ctx: the fallback
+// model, when there is one, while conversations are degraded
+func (s *GeminiChatSession) modelFor(ctx context.Context) string {
+	if s.fallbackModel != "" && repositories.DegradationFromContext(ctx) != repositories.DegradationNone {
+		return s.fallbackModel
+	}
+	return s.model
+}
@@ -0,0 +1,38 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+func TestGeminiChatSession_FallsBackWhileDegraded(t *testing.T) {
+	session, gemini := newMockedChatSession(t,
+		`{"candidates":[{"finishReason":"STOP","content":{"role":"model","parts":[{"text":"Halo juga!"}]}}]}`)
+	session.fallbackModel = "gemini-lite"
+	message := entities.Message{Role: entities.UserRole, Content: "halo"}
+
+	if _, err := session.SendMessage(context.Background(), message); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if path := gemini.lastPath(); !strings.Contains(path, "/models/"+defaultModel+":") {
+		t.Errorf("Expected the primary model while healthy, got %s", path)
+	}
+	if request := gemini.lastRequest(); strings.Contains(request, "KEEP IT SHORT") {
+		t.Errorf("Expected no brevity guidance while healthy, got:\n%s", request)
+	}
+
+	ctx := repositories.WithDegradation(context.Background(), repositories.DegradationPartial)
+	if _, err := session.SendMessage(ctx, message); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if path := gemini.lastPath(); !strings.Contains(path, "/models/gemini-lite:") {
+		t.Errorf("Expected the fallback model while degraded, got %s", path)
+	}
+	if request := gemini.lastRequest(); !strings.Contains(request, "KEEP IT SHORT") {
+		t.Errorf("Expected brevity guidance while degraded, got:\n%s", request)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"go.uber.org/zap"
 	"google.golang.org/genai"
@@ -20,23 +21,17 @@ const (
 	defaultTopK           = 40.0
 	defaultMaxTokens      = 500
 	defaultTimeoutSeconds = 30
-)
+	defaultHistoryTokens  = 8000
 
-// GeminiHardcodedConfig contains fixed configuration values that are not meant to be configurable
-var GeminiHardcodedConfig = struct {
-	// SystemPrompt is the fixed system prompt used for child-friendly interactions
-	SystemPrompt string
-
-	// SafetySettings are the fixed safety settings for content generation
-	SafetySettings []*genai.SafetySetting
+	// defaultLengthGuidance limits responses when the child's age is unknown
+	defaultLengthGuidance = "SHORT and simple - maximum 1 paragraph (2-3 sentences)"
+)
 
-	// Fallbacks are the fixed fallback messages used when generation fails
-	Fallbacks []string
-}{
-	SystemPrompt: `You are a friendly, caring AI companion for children. Your responses should be:
+// systemPromptTemplate is completed with the length guidance of the child's age
+const systemPromptTemplate = `You are a friendly, caring AI companion for children. Your responses should be:
 - Safe, appropriate, and educational for children ages 4-12
 - Warm, encouraging, and supportive
-- SHORT and simple - maximum 1 paragraph (2-3 sentences)
+- %s
 - Easy to understand with simple words
 - Never scary, violent, or inappropriate
 - Helpful in learning and development
@@ -46,7 +41,24 @@ KEEP RESPONSES BRIEF: Children have short attention spans. Make every response c
 
 Example: "That's such a wonderful story! I love how creative you are. Want to hear a secret about dragons?"
 
-Remember: Keep responses short, simple, and age-appropriate.`,
+Remember: Keep responses short, simple, and age-appropriate.`
+
+// GeminiHardcodedConfig contains fixed configuration values that are not meant to be configurable
+var GeminiHardcodedConfig = struct {
+	// SystemPrompt is the fixed system prompt used for child-friendly interactions
+	SystemPrompt string
+
+	// SafetySettings are the fixed safety settings for content generation
+	SafetySettings []*genai.SafetySetting
+
+	// Fallbacks are the fixed fallback messages used when generation fails
+	Fallbacks []string
+
+	// Redirections steer the child to another topic when Gemini blocks the
+	// conversation for safety
+	Redirections []string
+}{
+	SystemPrompt: fmt.Sprintf(systemPromptTemplate, defaultLengthGuidance),
 
 	SafetySettings: []*genai.SafetySetting{
 		{
@@ -74,6 +86,13 @@ Remember: Keep responses short, simple, and age-appropriate.`,
 		"Let me think about that... maybe you can help me by asking in a different way?",
 		"I'm learning new things every day! Can you tell me more about what you're thinking?",
 	},
+
+	Redirections: []string{
+		"Hmm, let's talk about something else! What's your favorite animal?",
+		"That's a good one to ask a grown-up you trust. Shall we play a guessing game instead?",
+		"How about something happy? Tell me what made you smile today!",
+		"I know something fun! Do you want to hear a story about a brave little cat?",
+	},
 }
 
 // GeminiConfig holds configuration for the GeminiChatSession adapter
@@ -82,26 +101,63 @@ Remember: Keep responses short, simple, and age-appropriate.`,
 // - APIKey: Your Google AI API key
 // Optional fields with defaults:
 // - Model: The model to use (default: "gemini-1.0-pro")
+// - FallbackModel: Cheaper model answering while conversations are degraded (default: none, Model answers)
 // - Temperature: Controls randomness between 0 and 1 (default: 0.7)
 // - TopP: Nucleus sampling parameter between 0 and 1 (default: 0.8)
 // - TopK: Top-k sampling parameter (default: 40)
 // - MaxOutputTokens: Maximum tokens in response (default: 500)
 // - TimeoutSeconds: Timeout for API calls in seconds (default: 30)
+// - HistoryTokenBudget: Approximate tokens of system prompt, history and message sent per request (default: 8000)
+// - AgeBands: Response length and token limit per age range (default: 2-5, 6-8 and 9-12 bands)
+// - TemperatureRamp: Temperature per turn of the conversation, overriding Temperature (default: none, Temperature for every turn)
+// - ReconnectMaxBackoffSeconds: Longest wait between attempts to recreate a client broken by a transport error (default: 30)
 type GeminiConfig struct {
 	APIKey          string  // Required: Your Google AI API key
 	Model           string  // Optional: The model to use
+	FallbackModel   string  // Optional: Cheaper model answering while conversations are degraded
 	Temperature     float32 // Optional: Controls randomness between 0 and 1
 	TopP            float32 // Optional: Nucleus sampling parameter between 0 and 1
 	TopK            float32 // Optional: Top-k sampling parameter
 	MaxOutputTokens int     // Optional: Maximum tokens in response
 	TimeoutSeconds  int     // Optional: Timeout for API calls in seconds
+
+	HistoryTokenBudget int // Optional: Approximate tokens of system prompt, history and message sent per request
+
+	AgeBands []AgeBand // Optional: Response length and token limit per age range
+
+	TemperatureRamp []TemperaturePoint // Optional: Temperature per turn of the conversation, overriding Temperature
+
+	ReconnectMaxBackoffSeconds int // Optional: Longest wait between attempts to recreate a broken client
 }
 
 // GeminiLLM implements the LargeLanguageModel interface using Google's Gemini API
 type GeminiLLM struct {
-	client *genai.Client
-	logger *zap.Logger
-	config GeminiConfig
+	// Recreated by its factory after transport errors
+	clients *sharedClient
+	logger  *zap.Logger
+	config  GeminiConfig
+	tools   repositories.ToolRegistry
+}
+
+// ModelName implements repositories.ModelNamer
+func (g *GeminiLLM) ModelName() string {
+	return g.config.Model
+}
+
+// CheckHealth implements repositories.HealthChecker by looking up the model
+func (g *GeminiLLM) CheckHealth(ctx context.Context) error {
+	client := g.clients.get(ctx)
+	if _, err := client.Models.Get(ctx, g.config.Model, nil); err != nil {
+		g.clients.report(client, err)
+		return fmt.Errorf("failed to get Gemini model: %w", err)
+	}
+	return nil
+}
+
+// SetToolRegistry routes the function calls of the model to tools. Without a
+// registry function calls are logged and ignored.
+func (g *GeminiLLM) SetToolRegistry(tools repositories.ToolRegistry) {
+	g.tools = tools
 }
 
 // NewGeminiLLM creates a new Gemini LLM instance
@@ -111,13 +167,11 @@ func NewGeminiLLM(logger *zap.Logger) (*GeminiLLM, error) {
 		return nil, fmt.Errorf("GEMINI_API_KEY environment variable is required")
 	}
 
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  apiKey,
-		Backend: genai.BackendGeminiAPI,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
+	factory := func(ctx context.Context) (*genai.Client, error) {
+		return genai.NewClient(ctx, &genai.ClientConfig{
+			APIKey:  apiKey,
+			Backend: genai.BackendGeminiAPI,
+		})
 	}
 
 	config := NewGeminiConfigFromEnv()
@@ -126,8 +180,31 @@ func NewGeminiLLM(logger *zap.Logger) (*GeminiLLM, error) {
 		config.Model = "gemini-2.0-flash" // Using Flash model as requested
 	}
 
+	return NewGeminiLLMWithClientFactory(config, factory, logger)
+}
+
+// NewGeminiLLMWithClientFactory creates a Gemini LLM instance whose clients
+// are created by factory
+func NewGeminiLLMWithClientFactory(config GeminiConfig, factory ClientFactory, logger *zap.Logger) (*GeminiLLM, error) {
+	client, err := factory(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
+	}
+
+	if config.ReconnectMaxBackoffSeconds == 0 {
+		config.ReconnectMaxBackoffSeconds = defaultReconnectMaxBackoffSeconds
+		logger.Info("Using default reconnect max backoff", zap.Int("reconnectMaxBackoffSeconds", config.ReconnectMaxBackoffSeconds))
+	}
+
 	return &GeminiLLM{
-		client: client,
+		clients: &sharedClient{
+			factory:    factory,
+			backoff:    reconnectBackoff,
+			maxBackoff: time.Duration(config.ReconnectMaxBackoffSeconds) * time.Second,
+			logger:     logger,
+			now:        time.Now,
+			client:     client,
+		},
 		logger: logger,
 		config: config,
 	}, nil
@@ -143,6 +220,8 @@ func NewGeminiConfigFromEnv() GeminiConfig {
 	config := GeminiConfig{
 		APIKey: apiKey,
 		Model:  os.Getenv("GOOGLE_AI_MODEL"),
+
+		FallbackModel: os.Getenv("GOOGLE_AI_FALLBACK_MODEL"),
 	}
 
 	// System prompt is now hardcoded and not configurable via environment variables
@@ -178,10 +257,65 @@ func NewGeminiConfigFromEnv() GeminiConfig {
 		}
 	}
 
+	if budgetStr := os.Getenv("GOOGLE_AI_HISTORY_TOKEN_BUDGET"); budgetStr != "" {
+		if budget, err := strconv.Atoi(budgetStr); err == nil && budget > 0 {
+			config.HistoryTokenBudget = budget
+		}
+	}
+
+	if bandsStr := os.Getenv("GOOGLE_AI_AGE_BANDS"); bandsStr != "" {
+		if bands, err := ParseAgeBands(bandsStr); err == nil {
+			config.AgeBands = bands
+		}
+	}
+
+	if rampStr := os.Getenv("GOOGLE_AI_TEMPERATURE_RAMP"); rampStr != "" {
+		if ramp, err := ParseTemperatureRamp(rampStr); err == nil {
+			config.TemperatureRamp = ramp
+		}
+	}
+
+	if backoffStr := os.Getenv("GOOGLE_AI_RECONNECT_MAX_BACKOFF_SECONDS"); backoffStr != "" {
+		if backoff, err := strconv.Atoi(backoffStr); err == nil && backoff > 0 {
+			config.ReconnectMaxBackoffSeconds = backoff
+		}
+	}
+
 	return config
 }
 
-// GenerateChat creates a chat session with history
+// GenerateChat creates a chat session with history. When the context carries
+// the child's age, the matching age band limits the response length; topics
+// discouraged by the parent, the words the child learned lately and the
+// summary of the conversation before a context reset are added to the system
+// prompt.
 func (g *GeminiLLM) GenerateChat(ctx context.Context, history []entities.Message) (repositories.ChatSession, error) {
-	return NewGeminiChatSession(g.client, g.config, g.logger, history)
+	session, err := NewGeminiChatSession(nil, g.config, g.logger, history)
+	if err != nil {
+		return nil, err
+	}
+	if g.clients != nil {
+		session.clients = g.clients
+	}
+	session.tools = g.tools
+
+	if age, ok := repositories.ChildAgeFromContext(ctx); ok {
+		bands := g.config.AgeBands
+		if len(bands) == 0 {
+			bands = defaultAgeBands
+		}
+		if band := ageBandFor(bands, age); band != nil {
+			session.systemPrompt = fmt.Sprintf(systemPromptTemplate, band.lengthGuidance())
+			session.maxOutputTokens = band.MaxOutputTokens
+		} else {
+			g.logger.Debug("No age band for child age, using default response length", zap.Int("age", age))
+		}
+	}
+
+	session.systemPrompt += topicGuidance(repositories.DiscouragedTopicsFromContext(ctx))
+	session.systemPrompt += learnedWordsGuidance(repositories.LearnedWordsFromContext(ctx))
+	if summary, ok := repositories.ContextSummaryFromContext(ctx); ok {
+		session.systemPrompt += contextSummaryGuidance(summary)
+	}
+	return session, nil
 }
@@ -3,27 +3,42 @@ package llm
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 	"google.golang.org/genai"
 
 	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
 )
 
 // GeminiChatSession implements the ChatSession interface
 type GeminiChatSession struct {
-	client          *genai.Client
+	clients         *sharedClient
 	logger          *zap.Logger
 	model           string
+	fallbackModel   string
 	temperature     float32
 	topP            float32
 	topK            float32
 	maxOutputTokens int
 	timeoutSeconds  int
+	historyTokens   int
 	safetySettings  []*genai.SafetySetting
 	systemPrompt    string
 	history         []*genai.Content
+	tools           repositories.ToolRegistry
+
+	// Temperature per turn, the fixed temperature applies when empty
+	temperatureRamp []TemperaturePoint
+	// Messages of the child sent in this session, including its history
+	turns int
+
+	// Wait before the first retry of a failed request, growing per attempt
+	retryDelay time.Duration
+	// Set while the last request failed at Gemini after every retry
+	failed bool
 }
 
 // ValidateGeminiConfig validates the GeminiConfig
@@ -52,6 +67,14 @@ func ValidateGeminiConfig(config GeminiConfig) error {
 		return fmt.Errorf("timeout must be positive, got %d", config.TimeoutSeconds)
 	}
 
+	if err := ValidateAgeBands(config.AgeBands); err != nil {
+		return err
+	}
+
+	if err := ValidateTemperatureRamp(config.TemperatureRamp); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -62,8 +85,15 @@ func NewGeminiChatSession(client *genai.Client, config GeminiConfig, logger *zap
 		return nil, err
 	}
 
-	// Convert repository format to Gemini format
-	geminiHistory := convertRepositoryToGeminiFormat(history)
+	// Keep only the turns the model can follow, in Gemini format
+	history, dropped, merged := sanitizeHistory(history)
+	if dropped > 0 || merged > 0 {
+		logger.Info("Sanitized chat history",
+			zap.Int("droppedMessages", dropped),
+			zap.Int("mergedMessages", merged),
+			zap.Int("historyLength", len(history)))
+	}
+	geminiHistory := messagesToContents(history)
 
 	// Apply defaults where needed
 	model := config.Model
@@ -102,46 +132,41 @@ func NewGeminiChatSession(client *genai.Client, config GeminiConfig, logger *zap
 		logger.Info("Using default timeoutSeconds", zap.Int("timeoutSeconds", timeoutSeconds))
 	}
 
+	historyTokens := config.HistoryTokenBudget
+	if historyTokens == 0 {
+		historyTokens = defaultHistoryTokens
+		logger.Info("Using default history token budget", zap.Int("historyTokenBudget", historyTokens))
+	}
+
 	// Use the hardcoded safety settings
 	logger.Info("Using hardcoded safety settings and system prompt")
 
 	return &GeminiChatSession{
-		client:          client,
+		clients:         fixedClient(client),
 		logger:          logger,
 		model:           model,
+		fallbackModel:   config.FallbackModel,
 		temperature:     temperature,
 		topP:            topP,
 		topK:            topK,
 		maxOutputTokens: maxOutputTokens,
 		timeoutSeconds:  timeoutSeconds,
+		historyTokens:   historyTokens,
 		safetySettings:  GeminiHardcodedConfig.SafetySettings,
 		systemPrompt:    GeminiHardcodedConfig.SystemPrompt,
 		history:         geminiHistory,
+		temperatureRamp: config.TemperatureRamp,
+		turns:           countTurns(history),
+		retryDelay:      time.Second,
 	}, nil
 }
 
 // SendMessage sends a message and gets a response, updating the history
 func (s *GeminiChatSession) SendMessage(ctx context.Context, message entities.Message) (entities.Message, error) {
-	// Prepare contents for API call (system prompt + history + current message)
-	var contents []*genai.Content
-
-	// Add system instruction as the first message
-	contents = append(contents, genai.NewContentFromText(s.systemPrompt, genai.RoleUser))
-
-	// Add existing history (already in Gemini format)
-	contents = append(contents, s.history...)
-
-	// Add the current user message to the contents for this API call
-	userContent := genai.NewContentFromText(message.Content, genai.RoleUser)
-	contents = append(contents, userContent)
-
-	// Configure settings using the session's configuration
-	config := &genai.GenerateContentConfig{
-		SafetySettings:  s.safetySettings,
-		Temperature:     genai.Ptr(s.temperature),
-		TopP:            genai.Ptr(s.topP),
-		TopK:            genai.Ptr(s.topK),
-		MaxOutputTokens: int32(s.maxOutputTokens),
+	contents, userContent, config := s.prepareRequest(ctx, message)
+	segmented := repositories.IntentSegmentationFromContext(ctx)
+	if segmented {
+		segmentByIntent(config)
 	}
 
 	// Add timeout to context if not already set
@@ -152,43 +177,106 @@ func (s *GeminiChatSession) SendMessage(ctx context.Context, message entities.Me
 	var response *genai.GenerateContentResponse
 	var err error
 	for attempt := 0; attempt < 3; attempt++ {
-		response, err = s.client.Models.GenerateContent(ctx, s.model, contents, config)
+		client := s.clients.get(ctx)
+		response, err = client.Models.GenerateContent(ctx, s.modelFor(ctx), contents, config)
 		if err == nil {
 			break
 		}
+		s.clients.report(client, err)
 
 		s.logger.Warn("Failed to generate content, retrying",
 			zap.Int("attempt", attempt+1),
 			zap.Error(err))
 
 		if attempt < 2 {
-			time.Sleep(time.Duration(attempt+1) * time.Second)
+			time.Sleep(time.Duration(attempt+1) * s.retryDelay)
 		}
 	}
 
+	s.failed = err != nil
 	if err != nil {
 		s.logger.Error("Failed to send message in chat session", zap.Error(err))
 		return s.createFallbackResponse(), nil // Return fallback instead of error
 	}
 
-	if len(response.Candidates) == 0 || len(response.Candidates[0].Content.Parts) == 0 {
+	// Refusals are not failures: the child hears a gentle change of topic
+	if category, blocked := blockedCategory(response); blocked {
+		contentBlocked.Add(category, 1)
+		s.logger.Warn("Gemini blocked content for safety",
+			zap.String("event", "content_blocked"),
+			zap.String("category", category))
+		return s.cannedResponse(GeminiHardcodedConfig.Redirections), nil
+	}
+
+	if len(response.Candidates) == 0 || response.Candidates[0].Content == nil || len(response.Candidates[0].Content.Parts) == 0 {
 		s.logger.Warn("No content generated in chat session")
 		return s.createFallbackResponse(), nil
 	}
 
-	// Extract text from the response
-	var responseText string
-	for _, part := range response.Candidates[0].Content.Parts {
-		if part.Text != "" {
-			responseText += part.Text
+	responseText := s.responseText(ctx, response.Candidates[0].Content.Parts)
+	if strings.TrimSpace(responseText) == "" {
+		s.logger.Warn("Empty response in chat session")
+		return s.createFallbackResponse(), nil
+	}
+	if segmented {
+		answers, err := parseIntentAnswers(responseText)
+		if err != nil {
+			s.logger.Warn("Failed to read the response segmented by intent", zap.Error(err))
+			return s.createFallbackResponse(), nil
 		}
+		responseText = s.connectIntentAnswers(answers)
 	}
 
-	if responseText == "" {
-		s.logger.Warn("Empty response in chat session")
-		return s.createFallbackResponse(), nil
+	return s.recordResponse(message, userContent, responseText), nil
+}
+
+// prepareRequest builds the contents and configuration of the request
+// answering message, starting the next turn of the session
+func (s *GeminiChatSession) prepareRequest(ctx context.Context, message entities.Message) ([]*genai.Content, *genai.Content, *genai.GenerateContentConfig) {
+	// The time of day changes between turns, so it is added per request
+	systemPrompt := s.systemPrompt
+	if localTime, ok := repositories.LocalTimeFromContext(ctx); ok {
+		systemPrompt += timeOfDayGuidance(localTime)
+	}
+	if language, ok := repositories.ReplyLanguageFromContext(ctx); ok {
+		systemPrompt += replyLanguageGuidance(language)
+	}
+	if topic, ok := repositories.DailyTopicFromContext(ctx); ok {
+		systemPrompt += dailyTopicGuidance(topic, len(s.history) == 0)
+	}
+	if previous, ok := repositories.RephraseFromContext(ctx); ok {
+		systemPrompt += rephraseGuidance(previous)
+	}
+	if topic, ok := repositories.ResumeTopicFromContext(ctx); ok {
+		systemPrompt += resumeTopicGuidance(topic)
+	}
+	if repositories.DegradationFromContext(ctx) != repositories.DegradationNone {
+		systemPrompt += degradedGuidance
+	}
+
+	// Drop the oldest turns that no longer fit the token budget
+	s.trimHistory(systemPrompt, message.Content)
+
+	// The contents are the history and the current message; the system
+	// prompt goes in the system instruction, not as a turn of the child
+	userContent := messageToContent(message)
+	contents := requestContents(s.history, userContent)
+
+	// Configure settings using the session's configuration
+	config := &genai.GenerateContentConfig{
+		SystemInstruction: genai.NewContentFromText(systemPrompt, genai.RoleUser),
+		SafetySettings:    s.safetySettings,
+		Temperature:       genai.Ptr(s.nextTemperature()),
+		TopP:              genai.Ptr(s.topP),
+		TopK:              genai.Ptr(s.topK),
+		MaxOutputTokens:   int32(s.maxOutputTokens),
 	}
+	return contents, userContent, config
+}
 
+// recordResponse adds the user message and the response text to the history
+// and returns the response message
+func (s *GeminiChatSession) recordResponse(message entities.Message, userContent *genai.Content, responseText string) entities.Message {
 	// Create response message and add both user message and response to history
 	responseContent := genai.NewContentFromText(responseText, genai.RoleModel)
 
@@ -205,30 +293,49 @@ func (s *GeminiChatSession) SendMessage(ctx context.Context, message entities.Me
 		zap.String("response_preview", responseText[:min(50, len(responseText))]),
 		zap.Int("history_length", len(s.history)))
 
-	return responseMessage, nil
+	return responseMessage
+}
+
+// Ensure GeminiChatSession implements the ChatSessionHealth interface
+var _ repositories.ChatSessionHealth = (*GeminiChatSession)(nil)
+
+// Healthy implements repositories.ChatSessionHealth. A session whose last
+// request failed at Gemini after every retry was answered with a fallback,
+// and is reported unhealthy until a request succeeds.
+func (s *GeminiChatSession) Healthy() bool {
+	return !s.failed
 }
 
 // History returns the current conversation history
 func (s *GeminiChatSession) History() ([]entities.Message, error) {
-	return convertGeminiToRepositoryFormat(s.history), nil
+	return contentsToMessages(s.history), nil
 }
 
 // createFallbackResponse creates a fallback response message
 func (s *GeminiChatSession) createFallbackResponse() entities.Message {
-	// Simple pseudo-random selection based on current time
-	fallbacks := GeminiHardcodedConfig.Fallbacks
-	index := int(time.Now().UnixNano()) % len(fallbacks)
+	return s.cannedResponse(GeminiHardcodedConfig.Fallbacks)
+}
+
+// cannedResponse picks one of texts as the response and adds it to the history
+func (s *GeminiChatSession) cannedResponse(texts []string) entities.Message {
+	text := pickText(texts)
 
-	fallbackMessage := entities.Message{
+	message := entities.Message{
 		Role:    entities.DollRole,
-		Content: fallbacks[index],
+		Content: text,
 	}
 
-	// Add fallback to history as Gemini content
-	fallbackContent := genai.NewContentFromText(fallbacks[index], genai.RoleModel)
-	s.history = append(s.history, fallbackContent)
+	// Add the response to history as Gemini content
+	s.history = append(s.history, genai.NewContentFromText(text, genai.RoleModel))
 
-	return fallbackMessage
+	return message
+}
+
+// pickText returns one of texts
+func pickText(texts []string) string {
+	// Simple pseudo-random selection based on current time
+	index := int(time.Now().UnixNano()) % len(texts)
+	return texts[index]
 }
 
 // Helper function for min
@@ -238,60 +345,3 @@ func min(a, b int) int {
 	}
 	return b
 }
-
-// convertRepositoryToGeminiFormat converts repository messages to Gemini format
-func convertRepositoryToGeminiFormat(messages []entities.Message) []*genai.Content {
-	var contents []*genai.Content
-
-	for _, msg := range messages {
-		var role genai.Role
-		switch msg.Role {
-		case entities.UserRole:
-			role = genai.RoleUser
-		case entities.DollRole:
-			role = genai.RoleModel
-		case entities.SystemRole:
-			role = genai.RoleUser // Treat system messages as user messages in Gemini
-		default:
-			role = genai.RoleUser // Default to user role
-		}
-
-		contents = append(contents, genai.NewContentFromText(msg.Content, role))
-	}
-
-	return contents
-}
-
-// convertGeminiToRepositoryFormat converts Gemini content to repository messages
-func convertGeminiToRepositoryFormat(contents []*genai.Content) []entities.Message {
-	var messages []entities.Message
-
-	for _, content := range contents {
-		var role entities.Role
-		switch content.Role {
-		case genai.RoleUser:
-			role = entities.UserRole
-		case genai.RoleModel:
-			role = entities.DollRole
-		default:
-			role = entities.UserRole // Default to user role
-		}
-
-		// Extract text from parts (limiting to text only as specified)
-		var text string
-		for _, part := range content.Parts {
-			if part.Text != "" {
-				text += part.Text
-			}
-		}
-
-		if text != "" {
-			messages = append(messages, entities.Message{
-				Role:    role,
-				Content: text,
-			})
-		}
-	}
-
-	return messages
-}
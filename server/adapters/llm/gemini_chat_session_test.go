@@ -0,0 +1,23 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+func TestGeminiChatSession_SendsSystemPromptAsSystemInstruction(t *testing.T) {
+	s := &GeminiChatSession{logger: zaptest.NewLogger(t), systemPrompt: "Kamu adalah boneka."}
+
+	contents, userContent, config := s.prepareRequest(context.Background(), entities.Message{Role: entities.UserRole, Content: "halo"})
+
+	if config.SystemInstruction == nil || len(config.SystemInstruction.Parts) != 1 || config.SystemInstruction.Parts[0].Text != "Kamu adalah boneka." {
+		t.Fatalf("Expected the system prompt as the system instruction, got %+v", config.SystemInstruction)
+	}
+	if len(contents) != 1 || contents[0] != userContent || contents[0].Parts[0].Text != "halo" {
+		t.Errorf("Expected the first turn to be the child's message alone, got %+v", contents)
+	}
+}
@@ -0,0 +1,71 @@
+package llm
+
+import (
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"google.golang.org/genai"
+)
+
+// estimateTokens approximates the tokens of text at four characters per
+// token. It is cheap and errs on the side of overestimating for the short
+// words children use.
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// contentTokens approximates the tokens of the text parts of content
+func contentTokens(content *genai.Content) int {
+	if content == nil {
+		return 0
+	}
+	tokens := 0
+	for _, part := range content.Parts {
+		if part != nil {
+			tokens += estimateTokens(part.Text)
+		}
+	}
+	return tokens
+}
+
+// trimHistory drops the oldest complete turns from the history until the
+// system prompt of the request, the history and message fit the token
+// budget. A turn is a child message with the responses that follow it, so
+// the history always starts with a child message. The system prompt is
+// always kept.
+func (s *GeminiChatSession) trimHistory(systemPrompt, message string) {
+	if s.historyTokens <= 0 {
+		return
+	}
+
+	tokens := estimateTokens(systemPrompt) + estimateTokens(message)
+	for _, content := range s.history {
+		tokens += contentTokens(content)
+	}
+	if tokens <= s.historyTokens {
+		return
+	}
+
+	before, dropped := tokens, 0
+	for len(s.history) > 0 && tokens > s.historyTokens {
+		// The turn ends where the next child message starts
+		end := 1
+		for end < len(s.history) && s.history[end].Role != genai.RoleUser {
+			end++
+		}
+		for _, content := range s.history[:end] {
+			tokens -= contentTokens(content)
+		}
+		s.history = s.history[end:]
+		dropped++
+	}
+	// Release the dropped turns
+	s.history = append([]*genai.Content(nil), s.history...)
+
+	s.logger.Info("Trimmed chat history to the token budget",
+		zap.Int("droppedTurns", dropped),
+		zap.Int("estimatedTokens", before),
+		zap.Int("remainingTokens", tokens),
+		zap.Int("historyTokenBudget", s.historyTokens),
+		zap.Int("historyLength", len(s.history)))
+}
@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/genai"
+)
+
+// historyTexts returns the text of every history entry
+func historyTexts(history []*genai.Content) []string {
+	var texts []string
+	for _, content := range history {
+		texts = append(texts, content.Parts[0].Text)
+	}
+	return texts
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hai", 1},
+		{"halo boneka", 3},
+		{"ääää", 1},
+	}
+	for _, tt := range tests {
+		if got := estimateTokens(tt.text); got != tt.want {
+			t.Errorf("Expected %d tokens for %q, got %d", tt.want, tt.text, got)
+		}
+	}
+}
+
+func TestGeminiChatSession_TrimsOldestTurnsToBudget(t *testing.T) {
+	// Every entry is 40 characters, 10 tokens
+	entry := func(role, text string) *genai.Content {
+		return genai.NewContentFromText(text+strings.Repeat(".", 40-len(text)), genai.Role(role))
+	}
+	newSession := func(budget int) *GeminiChatSession {
+		return &GeminiChatSession{
+			logger:        zaptest.NewLogger(t),
+			systemPrompt:  strings.Repeat("s", 40),
+			historyTokens: budget,
+			history: []*genai.Content{
+				entry(genai.RoleUser, "child 1"),
+				entry(genai.RoleModel, "doll 1"),
+				// A fallback answer without the child message
+				entry(genai.RoleModel, "fallback"),
+				entry(genai.RoleUser, "child 2"),
+				entry(genai.RoleModel, "doll 2"),
+				entry(genai.RoleUser, "child 3"),
+				entry(genai.RoleModel, "doll 3"),
+			},
+		}
+	}
+	message := strings.Repeat("m", 40)
+
+	tests := []struct {
+		name   string
+		budget int
+		want   []string
+	}{
+		{"fits", 90, []string{"child 1", "doll 1", "fallback", "child 2", "doll 2", "child 3", "doll 3"}},
+		{"drops the oldest turn with all its responses", 89, []string{"child 2", "doll 2", "child 3", "doll 3"}},
+		{"keeps the most recent turn", 40, []string{"child 3", "doll 3"}},
+		{"drops everything when only the prompt fits", 25, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSession(tt.budget)
+			s.trimHistory(s.systemPrompt, message)
+
+			got := historyTexts(s.history)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected history %v, got %v", tt.want, got)
+			}
+			for i := range tt.want {
+				if !strings.HasPrefix(got[i], tt.want[i]) {
+					t.Fatalf("Expected history %v, got %v", tt.want, got)
+				}
+			}
+			if len(s.history) > 0 && s.history[0].Role != genai.RoleUser {
+				t.Errorf("Expected the history to start with a child message, got %s", s.history[0].Role)
+			}
+
+			tokens := estimateTokens(s.systemPrompt) + estimateTokens(message)
+			for _, content := range s.history {
+				tokens += contentTokens(content)
+			}
+			if tokens > tt.budget && len(s.history) > 0 {
+				t.Errorf("Expected at most %d tokens, got %d", tt.budget, tokens)
+			}
+			if s.systemPrompt != strings.Repeat("s", 40) {
+				t.Error("Expected the system prompt to be kept")
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/genai"
+)
+
+// intentSegmentationGuidance is the system prompt addition asking to answer
+// every separate thing the child asked in turn, as a structured response
+const intentSegmentationGuidance = `
+
+SEVERAL QUESTIONS: The child may ask for several things in one breath, like a joke and a sum. List every separate thing the child asked or asked for, in the order they said it, and answer each one briefly. Start every answer after the first with a short, natural transition, such as "Terus," or "Oh iya,", so that the answers read one after the other sound like a single friendly reply. A message asking for one thing has a single answer.
+Answer only with JSON: {"intents": [{"intent": "what the child asked", "answer": "what the doll says"}]}`
+
+// intentsSchema is the schema of the structured response segmented by intent
+var intentsSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"intents": {
+			Type: genai.TypeArray,
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"intent": {Type: genai.TypeString},
+					"answer": {Type: genai.TypeString},
+				},
+				Required: []string{"intent", "answer"},
+			},
+		},
+	},
+	Required: []string{"intents"},
+}
+
+// intentAnswer is the answer to one of the things the child asked
+type intentAnswer struct {
+	Intent string `json:"intent"`
+	Answer string `json:"answer"`
+}
+
+// parseIntentAnswers reads the structured response segmented by intent,
+// skipping intents without an answer
+func parseIntentAnswers(answer string) ([]intentAnswer, error) {
+	var response struct {
+		Intents []intentAnswer `json:"intents"`
+	}
+	if err := json.Unmarshal([]byte(trimCodeFence(answer)), &response); err != nil {
+		return nil, fmt.Errorf("failed to parse intents: %w", err)
+	}
+	var answers []intentAnswer
+	for _, intent := range response.Intents {
+		intent.Answer = strings.TrimSpace(intent.Answer)
+		if intent.Answer != "" {
+			answers = append(answers, intent)
+		}
+	}
+	if len(answers) == 0 {
+		return nil, fmt.Errorf("failed to parse intents: no answer")
+	}
+	return answers, nil
+}
+
+// joinIntentAnswers connects the answers into the spoken response. The
+// model starts every answer after the first with a transition, so they are
+// simply read one after the other.
+func joinIntentAnswers(answers []intentAnswer) string {
+	texts := make([]string, 0, len(answers))
+	for _, answer := range answers {
+		texts = append(texts, answer.Answer)
+	}
+	return strings.Join(texts, " ")
+}
+
+// segmentByIntent asks the request of config for the structured response
+// segmented by intent. Only complete responses are segmented: a structured
+// response cannot be spoken as it streams.
+func segmentByIntent(config *genai.GenerateContentConfig) {
+	config.SystemInstruction.Parts = append(config.SystemInstruction.Parts, genai.NewPartFromText(intentSegmentationGuidance))
+	config.ResponseMIMEType = "application/json"
+	config.ResponseSchema = intentsSchema
+}
+
+// connectIntentAnswers returns the spoken response of the answers of the
+// structured response
+func (s *GeminiChatSession) connectIntentAnswers(answers []intentAnswer) string {
+	if len(answers) > 1 {
+		intents := make([]string, 0, len(answers))
+		for _, answer := range answers {
+			intents = append(intents, answer.Intent)
+		}
+		s.logger.Info("Answering several intents", zap.Strings("intents", intents))
+	}
+	return joinIntentAnswers(answers)
+}
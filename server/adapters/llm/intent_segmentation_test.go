@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+func TestGeminiChatSession_AnswersEveryIntent(t *testing.T) {
+	session, gemini := newMockedChatSession(t,
+		`{"candidates":[{"finishReason":"STOP","content":{"role":"model","parts":[{"text":"{\"intents\":[{\"intent\":\"a joke\",\"answer\":\"Kenapa ayam menyeberang jalan? Supaya sampai di seberang!\"},{\"intent\":\"2 plus 2\",\"answer\":\"Terus, 2 tambah 2 itu 4.\"}]}"}]}}]}`)
+	message := entities.Message{Role: entities.UserRole, Content: "ceritakan lelucon dan berapa 2 tambah 2"}
+
+	response, err := session.SendMessage(repositories.WithIntentSegmentation(context.Background()), message)
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	want := "Kenapa ayam menyeberang jalan? Supaya sampai di seberang! Terus, 2 tambah 2 itu 4."
+	if response.Content != want {
+		t.Errorf("Expected both intents answered in one response, got %q", response.Content)
+	}
+	request := gemini.lastRequest()
+	if !strings.Contains(request, "SEVERAL QUESTIONS") || !strings.Contains(request, `"responseMimeType":"application/json"`) {
+		t.Errorf("Expected a structured response to be asked for, got:\n%s", request)
+	}
+
+	// The history keeps what the doll said, not the structure
+	history, _ := session.History()
+	if contents := history[len(history)-1].Content; contents != want {
+		t.Errorf("Expected the spoken response in the history, got %q", contents)
+	}
+}
+
+func TestGeminiChatSession_SegmentsOnlyWhenAsked(t *testing.T) {
+	session, gemini := newMockedChatSession(t,
+		`{"candidates":[{"finishReason":"STOP","content":{"role":"model","parts":[{"text":"Halo juga!"}]}}]}`)
+
+	response, err := session.SendMessage(context.Background(), entities.Message{Role: entities.UserRole, Content: "halo"})
+	if err != nil || response.Content != "Halo juga!" {
+		t.Fatalf("Expected the model's response, got %q: %v", response.Content, err)
+	}
+	if request := gemini.lastRequest(); strings.Contains(request, "SEVERAL QUESTIONS") || strings.Contains(request, "responseMimeType") {
+		t.Errorf("Expected no structured response without segmentation, got:\n%s", request)
+	}
+}
+
+func TestGeminiChatSession_FallsBackOnUnstructuredResponse(t *testing.T) {
+	session, _ := newMockedChatSession(t,
+		`{"candidates":[{"finishReason":"STOP","content":{"role":"model","parts":[{"text":"{\"intents\":["}]}}]}`)
+
+	response, err := session.SendMessage(repositories.WithIntentSegmentation(context.Background()),
+		entities.Message{Role: entities.UserRole, Content: "halo"})
+	if err != nil {
+		t.Fatalf("Expected a fallback, got %v", err)
+	}
+	if !slices.Contains(GeminiHardcodedConfig.Fallbacks, response.Content) {
+		t.Errorf("Expected a fallback instead of the broken structure, got %q", response.Content)
+	}
+}
+
+func TestParseIntentAnswers(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  string
+		want    []string
+		wantErr bool
+	}{
+		{"single intent", `{"intents":[{"intent":"greeting","answer":"Halo!"}]}`, []string{"Halo!"}, false},
+		{"code fence", "```json\n{\"intents\":[{\"intent\":\"a\",\"answer\":\"Satu.\"},{\"intent\":\"b\",\"answer\":\"Terus, dua.\"}]}\n```", []string{"Satu.", "Terus, dua."}, false},
+		{"empty answers skipped", `{"intents":[{"intent":"a","answer":" "},{"intent":"b","answer":"Dua."}]}`, []string{"Dua."}, false},
+		{"no answer", `{"intents":[]}`, nil, true},
+		{"not JSON", "Halo!", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answers, err := parseIntentAnswers(tt.answer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			var got []string
+			for _, answer := range answers {
+				got = append(got, answer.Answer)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
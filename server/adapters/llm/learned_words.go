@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+const (
+	// learnedWordsMaxMessage bounds each message of the turn sent for
+	// extraction, so that long answers stay cheap
+	learnedWordsMaxMessage = 1000
+	// learnedWordsMaxKnown bounds the known words sent, the most recent ones
+	learnedWordsMaxKnown = 50
+	// learnedWordsPerTurn bounds the words kept of a turn
+	learnedWordsPerTurn = 3
+	// learnedWordsMaxOutputTokens bounds the words answered
+	learnedWordsMaxOutputTokens = 100
+)
+
+// learnedWordsPrompt asks for the notable new words of a turn as JSON
+const learnedWordsPrompt = `The following is one exchange between a child and a talking doll.
+Pick at most %d notable words or short concepts worth remembering for the child's vocabulary: uncommon words the child used, or new words the doll introduced and explained. Skip everyday words, names of people and the words the child already knows.
+Answer only with a JSON object of the form
+{"words": [{"word": "the word, in the language of the conversation", "source": "child" or "doll"}]}
+with an empty list when there is none.
+
+Words the child already knows: %s
+
+Child: %s
+Doll: %s`
+
+// Ensure GeminiLLM implements the WordExtractor interface
+var _ repositories.WordExtractor = (*GeminiLLM)(nil)
+
+// ExtractLearnedWords implements repositories.WordExtractor
+func (g *GeminiLLM) ExtractLearnedWords(ctx context.Context, child, doll entities.Message, known []string) ([]entities.LearnedWord, error) {
+	childText := truncateMessage(child.Content, learnedWordsMaxMessage)
+	dollText := truncateMessage(doll.Content, learnedWordsMaxMessage)
+	if childText == "" && dollText == "" {
+		return nil, nil
+	}
+	if len(known) > learnedWordsMaxKnown {
+		known = known[:learnedWordsMaxKnown]
+	}
+	knownText := strings.Join(known, ", ")
+	if knownText == "" {
+		knownText = "none"
+	}
+
+	timeoutSeconds := g.config.TimeoutSeconds
+	if timeoutSeconds == 0 {
+		timeoutSeconds = defaultTimeoutSeconds
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	prompt := fmt.Sprintf(learnedWordsPrompt, learnedWordsPerTurn, knownText, childText, dollText)
+	contents := []*genai.Content{genai.NewContentFromText(prompt, genai.RoleUser)}
+	config := &genai.GenerateContentConfig{
+		Temperature:      genai.Ptr(float32(0)),
+		MaxOutputTokens:  learnedWordsMaxOutputTokens,
+		ResponseMIMEType: "application/json",
+	}
+
+	client := g.clients.get(ctx)
+	response, err := client.Models.GenerateContent(ctx, g.config.Model, contents, config)
+	if err != nil {
+		g.clients.report(client, err)
+		return nil, fmt.Errorf("failed to extract learned words: %w", err)
+	}
+
+	return parseLearnedWords(response.Text())
+}
+
+// parseLearnedWords reads the JSON words answered by the model, keeping at
+// most learnedWordsPerTurn of them
+func parseLearnedWords(answer string) ([]entities.LearnedWord, error) {
+	var parsed struct {
+		Words []struct {
+			Word   string `json:"word"`
+			Source string `json:"source"`
+		} `json:"words"`
+	}
+	if err := json.Unmarshal([]byte(trimCodeFence(answer)), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse learned words: %w", err)
+	}
+
+	var words []entities.LearnedWord
+	for _, word := range parsed.Words {
+		if len(words) == learnedWordsPerTurn {
+			break
+		}
+		text := strings.TrimSpace(word.Word)
+		if text == "" {
+			continue
+		}
+		source := entities.LearnedFromChild
+		if strings.EqualFold(strings.TrimSpace(word.Source), entities.LearnedFromDoll) {
+			source = entities.LearnedFromDoll
+		}
+		words = append(words, entities.LearnedWord{Word: text, Source: source})
+	}
+	return words, nil
+}
+
+// truncateMessage returns text trimmed to at most maxLength bytes, cut at a
+// space when there is one
+func truncateMessage(text string, maxLength int) string {
+	text = strings.TrimSpace(text)
+	if len(text) <= maxLength {
+		return text
+	}
+	text = strings.ToValidUTF8(text[:maxLength], "")
+	if i := strings.LastIndex(text, " "); i > 0 {
+		text = text[:i]
+	}
+	return text
+}
+
+// learnedWordsGuidance returns the system prompt addition asking the model to
+// reinforce now and then the words the child learned lately
+func learnedWordsGuidance(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`
+
+WORDS TO PRACTICE: The child learned these words lately: %s.
+Now and then, when it fits the conversation naturally, use one of them again or gently ask the child what it means. Do not force them into every answer and never quiz the child.`, strings.Join(words, ", "))
+}
@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+func TestParseLearnedWords(t *testing.T) {
+	answer := "```json\n" + `{"words": [{"word": " fotosintesis ", "source": "doll"}, {"word": "", "source": "child"}, {"word": "klorofil", "source": "other"}, {"word": "akar", "source": "doll"}, {"word": "daun", "source": "doll"}]}` + "\n```"
+	words, err := parseLearnedWords(answer)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []entities.LearnedWord{
+		{Word: "fotosintesis", Source: entities.LearnedFromDoll},
+		{Word: "klorofil", Source: entities.LearnedFromChild},
+		{Word: "akar", Source: entities.LearnedFromDoll},
+	}
+	if len(words) != len(want) {
+		t.Fatalf("Expected %+v, got %+v", want, words)
+	}
+	for i := range want {
+		if words[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], words[i])
+		}
+	}
+
+	if words, err := parseLearnedWords(`{"words": []}`); err != nil || len(words) != 0 {
+		t.Errorf("Expected no words, got %+v: %v", words, err)
+	}
+	if _, err := parseLearnedWords("fotosintesis"); err == nil {
+		t.Error("Expected an error for an answer that is not JSON")
+	}
+}
+
+func TestTruncateMessage(t *testing.T) {
+	if got := truncateMessage(" Tanaman membuat makanan dari cahaya ", 20); got != "Tanaman membuat" {
+		t.Errorf("Expected the message cut at a space, got %q", got)
+	}
+	if got := truncateMessage("Halo", 20); got != "Halo" {
+		t.Errorf("Expected a short message kept, got %q", got)
+	}
+}
+
+func TestLearnedWordsGuidance(t *testing.T) {
+	if learnedWordsGuidance(nil) != "" {
+		t.Error("Expected no guidance without learned words")
+	}
+	guidance := learnedWordsGuidance([]string{"fotosintesis", "gerhana"})
+	if !strings.Contains(guidance, "fotosintesis, gerhana") || !strings.Contains(guidance, "Now and then") {
+		t.Errorf("Expected the words to practice now and then, got %q", guidance)
+	}
+}
@@ -0,0 +1,128 @@
+package llm
+
+import (
+	"strings"
+
+	"google.golang.org/genai"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// Messages of the conversation are entities.Message throughout the server;
+// only this adapter speaks Gemini content. A doll message is model content.
+// Gemini contents have no system role, so system messages are sent as user
+// content and read back as user messages.
+
+// geminiRole returns the Gemini role of messages with role
+func geminiRole(role entities.Role) genai.Role {
+	canonical, _ := entities.ParseRole(string(role))
+	if canonical == entities.DollRole {
+		return genai.RoleModel
+	}
+	return genai.RoleUser
+}
+
+// messageRole returns the role of messages read from Gemini content with role
+func messageRole(role genai.Role) entities.Role {
+	if role == genai.RoleModel {
+		return entities.DollRole
+	}
+	return entities.UserRole
+}
+
+// messageToContent converts a message to Gemini content
+func messageToContent(message entities.Message) *genai.Content {
+	return genai.NewContentFromText(message.Content, geminiRole(message.Role))
+}
+
+// contentToMessage converts the text of Gemini content to a message. It
+// reports false when the content has no text.
+func contentToMessage(content *genai.Content) (entities.Message, bool) {
+	var text string
+	for _, part := range content.Parts {
+		text += part.Text
+	}
+	if text == "" {
+		return entities.Message{}, false
+	}
+	return entities.Message{Role: messageRole(genai.Role(content.Role)), Content: text}, true
+}
+
+// messagesToContents converts messages to Gemini contents
+func messagesToContents(messages []entities.Message) []*genai.Content {
+	var contents []*genai.Content
+	for _, message := range messages {
+		contents = append(contents, messageToContent(message))
+	}
+	return contents
+}
+
+// contentsToMessages converts Gemini contents to messages, skipping those
+// without text
+func contentsToMessages(contents []*genai.Content) []entities.Message {
+	var messages []entities.Message
+	for _, content := range contents {
+		if message, ok := contentToMessage(content); ok {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// sanitizeHistory returns the conversational turns of history in the order
+// Gemini expects: the child and the doll taking turns, starting with the
+// child and ending with the doll, since the next message is the child's.
+// System messages, messages without text and messages of unknown roles are
+// dropped, consecutive messages of one role are merged, and a doll message
+// before the first child message or a child message left unanswered at the
+// end is dropped. It returns how many messages were dropped and merged.
+func sanitizeHistory(history []entities.Message) (sanitized []entities.Message, dropped, merged int) {
+	for _, message := range history {
+		role, ok := entities.ParseRole(string(message.Role))
+		content := strings.TrimSpace(message.Content)
+		if !ok || role == entities.SystemRole || content == "" {
+			dropped++
+			continue
+		}
+		if len(sanitized) == 0 && role == entities.DollRole {
+			dropped++
+			continue
+		}
+
+		message.Role, message.Content = role, content
+		if last := len(sanitized) - 1; last >= 0 && sanitized[last].Role == role {
+			sanitized[last].Content += " " + content
+			merged++
+			continue
+		}
+		sanitized = append(sanitized, message)
+	}
+
+	if last := len(sanitized) - 1; last >= 0 && sanitized[last].Role == entities.UserRole {
+		sanitized = sanitized[:last]
+		dropped++
+	}
+	return sanitized, dropped, merged
+}
+
+// requestContents returns the contents of a request sending next after
+// history, starting with the child and with the child and the doll taking
+// turns: doll contents before the first child content are dropped, and
+// consecutive contents of one role, such as a fallback answer following a
+// response, are merged. The history is left as it is.
+func requestContents(history []*genai.Content, next *genai.Content) []*genai.Content {
+	var contents []*genai.Content
+	for _, content := range append(history[:len(history):len(history)], next) {
+		if content == nil || (len(contents) == 0 && content.Role != genai.RoleUser) {
+			continue
+		}
+		if last := len(contents) - 1; last >= 0 && contents[last].Role == content.Role {
+			merged := *contents[last]
+			merged.Parts = append(merged.Parts[:len(merged.Parts):len(merged.Parts)], content.Parts...)
+			contents[last] = &merged
+			continue
+		}
+		contents = append(contents, content)
+	}
+	return contents
+}
@@ -0,0 +1,162 @@
+package llm
+
+import (
+	"testing"
+
+	"google.golang.org/genai"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+func TestMessageConversion_RoundTrip(t *testing.T) {
+	cases := []struct {
+		role       entities.Role
+		geminiRole genai.Role
+		readBack   entities.Role
+	}{
+		{entities.UserRole, genai.RoleUser, entities.UserRole},
+		{entities.DollRole, genai.RoleModel, entities.DollRole},
+		// Gemini contents have no system role
+		{entities.SystemRole, genai.RoleUser, entities.UserRole},
+		// Other names of the doll in stored history
+		{"assistant", genai.RoleModel, entities.DollRole},
+		{"model", genai.RoleModel, entities.DollRole},
+		{"", genai.RoleUser, entities.UserRole},
+	}
+	for _, c := range cases {
+		content := messageToContent(entities.Message{Role: c.role, Content: "halo"})
+		if genai.Role(content.Role) != c.geminiRole {
+			t.Errorf("Role %q: expected Gemini role %q, got %q", c.role, c.geminiRole, content.Role)
+		}
+		message, ok := contentToMessage(content)
+		if !ok || message.Role != c.readBack || message.Content != "halo" {
+			t.Errorf("Role %q: expected a %q message back, got %+v", c.role, c.readBack, message)
+		}
+	}
+}
+
+func TestContentsToMessages_SkipsContentWithoutText(t *testing.T) {
+	contents := []*genai.Content{
+		genai.NewContentFromText("halo", genai.RoleUser),
+		{Role: genai.RoleModel, Parts: []*genai.Part{{FunctionCall: &genai.FunctionCall{Name: "play_song"}}}},
+		{Role: genai.RoleModel, Parts: []*genai.Part{{Text: "Halo "}, {Text: "juga!"}}},
+	}
+	messages := contentsToMessages(contents)
+	if len(messages) != 2 || messages[1].Role != entities.DollRole || messages[1].Content != "Halo juga!" {
+		t.Errorf("Expected the text of the user and doll contents, got %+v", messages)
+	}
+}
+
+func TestSanitizeHistory_MergesConsecutiveTurnsOfOneRole(t *testing.T) {
+	history := []entities.Message{
+		{Role: entities.UserRole, Content: "Halo"},
+		{Role: entities.UserRole, Content: "kamu siapa?"},
+		{Role: entities.DollRole, Content: "Aku Arunika!"},
+		{Role: "assistant", Content: "Kamu mau main?"},
+		{Role: entities.UserRole, Content: "Mau!"},
+		{Role: entities.DollRole, Content: "Ayo!"},
+	}
+	sanitized, dropped, merged := sanitizeHistory(history)
+
+	expected := []entities.Message{
+		{Role: entities.UserRole, Content: "Halo kamu siapa?"},
+		{Role: entities.DollRole, Content: "Aku Arunika! Kamu mau main?"},
+		{Role: entities.UserRole, Content: "Mau!"},
+		{Role: entities.DollRole, Content: "Ayo!"},
+	}
+	if dropped != 0 || merged != 2 {
+		t.Errorf("Expected 2 merged and no dropped messages, got %d merged and %d dropped", merged, dropped)
+	}
+	assertMessages(t, sanitized, expected)
+}
+
+func TestSanitizeHistory_StartsWithTheChildAndEndsWithTheDoll(t *testing.T) {
+	history := []entities.Message{
+		{Role: entities.DollRole, Content: "Halo, aku Arunika!"},
+		{Role: entities.UserRole, Content: "Halo"},
+		{Role: entities.DollRole, Content: "Apa kabar?"},
+		{Role: entities.UserRole, Content: "Baik"},
+	}
+	sanitized, dropped, _ := sanitizeHistory(history)
+
+	expected := []entities.Message{
+		{Role: entities.UserRole, Content: "Halo"},
+		{Role: entities.DollRole, Content: "Apa kabar?"},
+	}
+	if dropped != 2 {
+		t.Errorf("Expected the leading doll turn and the unanswered child turn dropped, got %d dropped", dropped)
+	}
+	assertMessages(t, sanitized, expected)
+}
+
+func TestSanitizeHistory_DropsNonConversationalMessages(t *testing.T) {
+	history := []entities.Message{
+		{Role: entities.SystemRole, Content: "Session resumed"},
+		{Role: entities.UserRole, Content: "Halo"},
+		{Role: entities.DollRole, Content: "   "},
+		{Role: "tool", Content: "play_song"},
+		{Role: entities.DollRole, Content: " Halo juga! "},
+	}
+	sanitized, dropped, _ := sanitizeHistory(history)
+
+	expected := []entities.Message{
+		{Role: entities.UserRole, Content: "Halo"},
+		{Role: entities.DollRole, Content: "Halo juga!"},
+	}
+	if dropped != 3 {
+		t.Errorf("Expected 3 dropped messages, got %d", dropped)
+	}
+	assertMessages(t, sanitized, expected)
+}
+
+func TestSanitizeHistory_EmptyHistory(t *testing.T) {
+	for _, history := range [][]entities.Message{
+		nil,
+		{},
+		{{Role: entities.DollRole, Content: "Halo!"}},
+		{{Role: entities.UserRole, Content: "Halo"}},
+	} {
+		if sanitized, _, _ := sanitizeHistory(history); len(sanitized) != 0 {
+			t.Errorf("Expected no turns from %+v, got %+v", history, sanitized)
+		}
+	}
+}
+
+func assertMessages(t *testing.T, messages, expected []entities.Message) {
+	t.Helper()
+	if len(messages) != len(expected) {
+		t.Fatalf("Expected %d messages, got %+v", len(expected), messages)
+	}
+	for i := range expected {
+		if messages[i].Role != expected[i].Role || messages[i].Content != expected[i].Content {
+			t.Errorf("Message %d: expected %+v, got %+v", i, expected[i], messages[i])
+		}
+	}
+}
+
+func TestRequestContents_AlternatesStartingWithTheChild(t *testing.T) {
+	history := []*genai.Content{
+		genai.NewContentFromText("sapaan", genai.RoleModel),
+		genai.NewContentFromText("halo", genai.RoleUser),
+		genai.NewContentFromText("halo juga", genai.RoleModel),
+		// A fallback answer without the child message
+		genai.NewContentFromText("coba lagi", genai.RoleModel),
+	}
+	next := genai.NewContentFromText("ayo main", genai.RoleUser)
+
+	contents := requestContents(history, next)
+
+	var roles []string
+	for _, content := range contents {
+		roles = append(roles, content.Role)
+	}
+	if len(contents) != 3 || roles[0] != genai.RoleUser || roles[1] != genai.RoleModel || roles[2] != genai.RoleUser {
+		t.Fatalf("Expected the child and the doll taking turns, got %v", roles)
+	}
+	if len(contents[1].Parts) != 2 || contents[2] != next {
+		t.Errorf("Expected the doll contents merged before the next message, got %+v", contents)
+	}
+	if len(history) != 4 || len(history[2].Parts) != 1 {
+		t.Errorf("Expected the history left as it is, got %+v", history)
+	}
+}
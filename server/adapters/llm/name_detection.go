@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// nameDetectionPrompt asks for the people's names in a transcription as JSON
+const nameDetectionPrompt = `List the names of people mentioned in the following text spoken by a child.
+Do not list names of animals, toys, cartoon characters, places or brands.
+Answer only with a JSON array of strings exactly as they appear in the text, or [] when there are none.
+
+Text: %s`
+
+// Ensure GeminiLLM implements the NameDetector interface
+var _ repositories.NameDetector = (*GeminiLLM)(nil)
+
+// DetectNames implements repositories.NameDetector
+func (g *GeminiLLM) DetectNames(ctx context.Context, text string) ([]string, error) {
+	timeoutSeconds := g.config.TimeoutSeconds
+	if timeoutSeconds == 0 {
+		timeoutSeconds = defaultTimeoutSeconds
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	contents := []*genai.Content{genai.NewContentFromText(fmt.Sprintf(nameDetectionPrompt, text), genai.RoleUser)}
+	config := &genai.GenerateContentConfig{
+		Temperature:      genai.Ptr(float32(0)),
+		ResponseMIMEType: "application/json",
+	}
+
+	client := g.clients.get(ctx)
+	response, err := client.Models.GenerateContent(ctx, g.config.Model, contents, config)
+	if err != nil {
+		g.clients.report(client, err)
+		return nil, fmt.Errorf("failed to detect names: %w", err)
+	}
+
+	return parseDetectedNames(response.Text())
+}
+
+// parseDetectedNames reads the JSON array of names answered by the model
+func parseDetectedNames(answer string) ([]string, error) {
+	var names []string
+	if err := json.Unmarshal([]byte(trimCodeFence(answer)), &names); err != nil {
+		return nil, fmt.Errorf("failed to parse detected names: %w", err)
+	}
+	return nonEmpty(names), nil
+}
+
+// trimCodeFence removes the Markdown code fence models sometimes wrap JSON in
+func trimCodeFence(answer string) string {
+	answer = strings.TrimSpace(answer)
+	answer = strings.TrimPrefix(answer, "```json")
+	answer = strings.TrimPrefix(answer, "```")
+	return strings.TrimSuffix(strings.TrimSpace(answer), "```")
+}
@@ -0,0 +1,29 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDetectedNames(t *testing.T) {
+	tests := map[string][]string{
+		`["Budi", "Ibu Sari"]`:     {"Budi", "Ibu Sari"},
+		"```json\n[\"Budi\"]\n```": {"Budi"},
+		`[]`:                       nil,
+		`[" ", "Kirana "]`:         {"Kirana"},
+	}
+	for answer, want := range tests {
+		got, err := parseDetectedNames(answer)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", answer, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v for %q, got %v", want, answer, got)
+		}
+	}
+
+	if _, err := parseDetectedNames("Budi"); err == nil {
+		t.Error("Expected an error for an answer that is not JSON")
+	}
+}
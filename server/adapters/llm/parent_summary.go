@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+const (
+	// parentSummaryMaxTranscript bounds the transcript sent for a summary,
+	// keeping its most recent messages, so that long sessions stay cheap
+	parentSummaryMaxTranscript = 8000
+	// parentSummaryMaxOutputTokens bounds the summary answered
+	parentSummaryMaxOutputTokens = 300
+)
+
+// parentSummaryPrompt asks for a parent-facing summary of a transcript as JSON
+const parentSummaryPrompt = `The following is a conversation between a child and a talking doll.
+Write a brief summary of it for the child's parent, in the language of the conversation.
+Be neutral and factual: describe what the child talked about without judging the child, and do not quote personal information.
+List concerns only when the child said something a parent should follow up on, such as being scared, hurt, sad or unsafe.
+Answer only with a JSON object of the form
+{"summary": "two or three sentences", "topics": ["a few words each"], "mood": "one word, or empty when unclear", "concerns": ["one sentence each"]}
+
+Conversation:
+%s`
+
+// Ensure GeminiLLM implements the ParentSummarizer interface
+var _ repositories.ParentSummarizer = (*GeminiLLM)(nil)
+
+// SummarizeForParent implements repositories.ParentSummarizer
+func (g *GeminiLLM) SummarizeForParent(ctx context.Context, messages []entities.Message) (*entities.ParentSummary, error) {
+	transcript := parentSummaryTranscript(messages, parentSummaryMaxTranscript)
+	if transcript == "" {
+		return nil, fmt.Errorf("no messages to summarize")
+	}
+
+	timeoutSeconds := g.config.TimeoutSeconds
+	if timeoutSeconds == 0 {
+		timeoutSeconds = defaultTimeoutSeconds
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	contents := []*genai.Content{genai.NewContentFromText(fmt.Sprintf(parentSummaryPrompt, transcript), genai.RoleUser)}
+	config := &genai.GenerateContentConfig{
+		Temperature:      genai.Ptr(float32(0)),
+		MaxOutputTokens:  parentSummaryMaxOutputTokens,
+		ResponseMIMEType: "application/json",
+	}
+
+	client := g.clients.get(ctx)
+	response, err := client.Models.GenerateContent(ctx, g.config.Model, contents, config)
+	if err != nil {
+		g.clients.report(client, err)
+		return nil, fmt.Errorf("failed to summarize session: %w", err)
+	}
+
+	return parseParentSummary(response.Text())
+}
+
+// parentSummaryTranscript writes the child and doll messages one per line,
+// keeping the most recent ones that fit in maxLength bytes
+func parentSummaryTranscript(messages []entities.Message, maxLength int) string {
+	var lines []string
+	length := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		role, _ := entities.ParseRole(string(messages[i].Role))
+		content := strings.TrimSpace(messages[i].Content)
+		if content == "" || role == entities.SystemRole {
+			continue
+		}
+		speaker := "Child"
+		if role == entities.DollRole {
+			speaker = "Doll"
+		}
+		line := speaker + ": " + content
+		if length+len(line)+1 > maxLength {
+			break
+		}
+		length += len(line) + 1
+		lines = append(lines, line)
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseParentSummary reads the JSON summary answered by the model
+func parseParentSummary(answer string) (*entities.ParentSummary, error) {
+	var parsed struct {
+		Summary  string   `json:"summary"`
+		Topics   []string `json:"topics"`
+		Mood     string   `json:"mood"`
+		Concerns []string `json:"concerns"`
+	}
+	if err := json.Unmarshal([]byte(trimCodeFence(answer)), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse parent summary: %w", err)
+	}
+
+	summary := &entities.ParentSummary{
+		Summary:  strings.TrimSpace(parsed.Summary),
+		Topics:   nonEmpty(parsed.Topics),
+		Mood:     strings.TrimSpace(parsed.Mood),
+		Concerns: nonEmpty(parsed.Concerns),
+	}
+	if summary.Summary == "" {
+		return nil, fmt.Errorf("failed to parse parent summary: no summary")
+	}
+	return summary, nil
+}
+
+// nonEmpty returns the trimmed values that are not blank
+func nonEmpty(values []string) []string {
+	var kept []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
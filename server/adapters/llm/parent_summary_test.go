@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+func TestParseParentSummary(t *testing.T) {
+	answer := "```json\n" + `{"summary": " Kirana bercerita tentang kucingnya. ", "topics": ["kucing", " "], "mood": "ceria", "concerns": []}` + "\n```"
+	summary, err := parseParentSummary(answer)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if summary.Summary != "Kirana bercerita tentang kucingnya." || summary.Mood != "ceria" {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+	if !reflect.DeepEqual(summary.Topics, []string{"kucing"}) || summary.Concerns != nil {
+		t.Errorf("Expected the blank topic and no concerns, got %+v", summary)
+	}
+
+	for _, answer := range []string{"Kirana senang", `{"summary": "", "topics": ["kucing"]}`} {
+		if _, err := parseParentSummary(answer); err == nil {
+			t.Errorf("Expected an error for %q", answer)
+		}
+	}
+}
+
+func TestParentSummaryTranscript(t *testing.T) {
+	messages := []entities.Message{
+		{Role: entities.UserRole, Content: "Halo boneka"},
+		{Role: entities.SystemRole, Content: "Hari ini hari Senin"},
+		{Role: entities.DollRole, Content: "Halo juga!"},
+		{Role: entities.UserRole, Content: "Aku takut gelap"},
+	}
+
+	got := parentSummaryTranscript(messages, 1000)
+	want := "Child: Halo boneka\nDoll: Halo juga!\nChild: Aku takut gelap"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	// The most recent messages are kept
+	got = parentSummaryTranscript(messages, len("Doll: Halo juga!\nChild: Aku takut gelap\n"))
+	if strings.Contains(got, "Halo boneka") || !strings.HasSuffix(got, "Aku takut gelap") {
+		t.Errorf("Expected only the latest messages, got %q", got)
+	}
+}
@@ -0,0 +1,121 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/genai"
+)
+
+const (
+	// reconnectBackoff is the wait after the first failed recreation of a
+	// broken client, doubling with every further failure
+	reconnectBackoff = time.Second
+
+	defaultReconnectMaxBackoffSeconds = 30
+)
+
+// clientsRecreated counts the Gemini clients replaced after transport errors
+var clientsRecreated = expvar.NewInt("llm_clients_recreated")
+
+// ClientFactory creates the Gemini client used by GeminiLLM. It is called
+// again to replace the client after a transport error it cannot recover from.
+type ClientFactory func(ctx context.Context) (*genai.Client, error)
+
+// sharedClient is the Gemini client shared by the LLM and its chat sessions.
+// After a transport error the client is marked broken and recreated the next
+// time it is used. Failed recreations are retried with exponential backoff,
+// using the broken client meanwhile.
+type sharedClient struct {
+	factory    ClientFactory
+	backoff    time.Duration
+	maxBackoff time.Duration
+	logger     *zap.Logger
+	now        func() time.Time
+
+	mu       sync.Mutex
+	client   *genai.Client
+	broken   bool
+	failures int
+	retryAt  time.Time
+}
+
+// fixedClient shares client without ever recreating it
+func fixedClient(client *genai.Client) *sharedClient {
+	return &sharedClient{client: client, now: time.Now}
+}
+
+// get returns the client to send the next request with, recreating a broken
+// one when allowed
+func (c *sharedClient) get(ctx context.Context) *genai.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.broken || c.factory == nil || c.now().Before(c.retryAt) {
+		return c.client
+	}
+
+	client, err := c.factory(ctx)
+	if err != nil {
+		c.failures++
+		delay := c.backoff << (c.failures - 1)
+		if delay <= 0 || delay > c.maxBackoff {
+			delay = c.maxBackoff
+		}
+		c.retryAt = c.now().Add(delay)
+		c.logger.Warn("Failed to recreate Gemini client, keeping the broken one",
+			zap.Int("failures", c.failures),
+			zap.Duration("retryIn", delay),
+			zap.Error(err))
+		return c.client
+	}
+
+	c.client = client
+	c.broken = false
+	c.failures = 0
+	clientsRecreated.Add(1)
+	c.logger.Info("Recreated Gemini client after a transport error")
+	return c.client
+}
+
+// report marks client broken when err shows its transport cannot recover
+func (c *sharedClient) report(client *genai.Client, err error) {
+	if !isTransportError(err) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.factory == nil || c.client != client || c.broken {
+		return
+	}
+	c.broken = true
+	c.retryAt = c.now()
+	c.logger.Warn("Gemini client transport failed, recreating it before the next request", zap.Error(err))
+}
+
+// isTransportError reports whether err comes from the connection to Gemini
+// rather than from Gemini itself or the caller giving up
+func isTransportError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return false
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
@@ -0,0 +1,200 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/genai"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// clientFactory creates clients for the given Gemini servers in turn, the
+// last one from then on, and counts the clients created
+type clientFactory struct {
+	mu      sync.Mutex
+	urls    []string
+	created int
+	err     error
+}
+
+func (f *clientFactory) create(ctx context.Context) (*genai.Client, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	url := f.urls[min(f.created, len(f.urls)-1)]
+	f.created++
+	return genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:      "test",
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: genai.HTTPOptions{BaseURL: url},
+	})
+}
+
+func (f *clientFactory) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.created
+}
+
+// newGeminiServer answers every request with handler
+func newGeminiServer(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// dropConnection fails the request at the transport level
+func dropConnection(w http.ResponseWriter, r *http.Request) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+func answer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `{"candidates":[{"finishReason":"STOP","content":{"role":"model","parts":[{"text":"Halo juga!"}]}}]}`)
+}
+
+// newReconnectingSession returns a chat session of an LLM whose clients
+// factory creates
+func newReconnectingSession(t *testing.T, factory *clientFactory) *GeminiChatSession {
+	t.Helper()
+	g, err := NewGeminiLLMWithClientFactory(GeminiConfig{APIKey: "test"}, factory.create, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create LLM: %v", err)
+	}
+	chat, err := g.GenerateChat(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	session := chat.(*GeminiChatSession)
+	session.retryDelay = time.Millisecond
+	return session
+}
+
+func TestGeminiLLM_RecreatesClientAfterTransportError(t *testing.T) {
+	factory := &clientFactory{urls: []string{
+		newGeminiServer(t, dropConnection),
+		newGeminiServer(t, answer),
+	}}
+	session := newReconnectingSession(t, factory)
+	recreated := clientsRecreated.Value()
+
+	response, err := session.SendMessage(context.Background(), entities.Message{Role: entities.UserRole, Content: "halo"})
+	if err != nil || response.Content != "Halo juga!" {
+		t.Fatalf("Expected the recreated client to answer, got %q: %v", response.Content, err)
+	}
+	if got := factory.count(); got != 2 {
+		t.Errorf("Expected the client to be recreated once, got %d clients", got)
+	}
+	if got := clientsRecreated.Value() - recreated; got != 1 {
+		t.Errorf("Expected one recreation to be counted, got %d", got)
+	}
+}
+
+func TestGeminiLLM_KeepsClientOnAPIError(t *testing.T) {
+	factory := &clientFactory{urls: []string{newGeminiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"error":{"code":503,"message":"overloaded","status":"UNAVAILABLE"}}`)
+	})}}
+	session := newReconnectingSession(t, factory)
+
+	if _, err := session.SendMessage(context.Background(), entities.Message{Role: entities.UserRole, Content: "halo"}); err != nil {
+		t.Fatalf("Expected a fallback, got %v", err)
+	}
+	if got := factory.count(); got != 1 {
+		t.Errorf("Expected the client to be kept, got %d clients", got)
+	}
+}
+
+func TestSharedClient_BacksOffFailedRecreation(t *testing.T) {
+	factory := &clientFactory{urls: []string{newGeminiServer(t, answer)}}
+	now := time.Now()
+	clients := &sharedClient{
+		factory:    factory.create,
+		backoff:    time.Second,
+		maxBackoff: 3 * time.Second,
+		logger:     zaptest.NewLogger(t),
+		now:        func() time.Time { return now },
+	}
+	broken, _ := factory.create(context.Background())
+	clients.client = broken
+	clients.report(broken, &netError{})
+
+	factory.err = errors.New("dns failure")
+	for i, wait := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		if got := clients.get(context.Background()); got != broken {
+			t.Fatalf("Attempt %d: expected the broken client to be kept", i)
+		}
+		now = now.Add(wait - time.Millisecond)
+		factory.err = nil
+		if got := clients.get(context.Background()); got != broken {
+			t.Fatalf("Attempt %d: expected no recreation within %v", i, wait)
+		}
+		factory.err = errors.New("dns failure")
+		now = now.Add(time.Millisecond)
+	}
+
+	factory.err = nil
+	if got := clients.get(context.Background()); got == broken {
+		t.Error("Expected the client to be recreated once the factory recovers")
+	}
+}
+
+// netError is a transport error
+type netError struct{}
+
+func (*netError) Error() string   { return "connection reset" }
+func (*netError) Timeout() bool   { return false }
+func (*netError) Temporary() bool { return false }
+
+func TestGeminiChatSession_ReportsUnhealthyWhileRequestsFail(t *testing.T) {
+	var mu sync.Mutex
+	failing := true
+	factory := &clientFactory{urls: []string{newGeminiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !failing {
+			answer(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"error":{"code":503,"message":"overloaded","status":"UNAVAILABLE"}}`)
+	})}}
+	session := newReconnectingSession(t, factory)
+	if !session.Healthy() {
+		t.Fatal("Expected a new session to be healthy")
+	}
+
+	message := entities.Message{Role: entities.UserRole, Content: "halo"}
+	if _, err := session.SendMessage(context.Background(), message); err != nil {
+		t.Fatalf("Expected a fallback, got %v", err)
+	}
+	if session.Healthy() {
+		t.Error("Expected the session answered with a fallback to be unhealthy")
+	}
+
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	if _, err := session.SendMessage(context.Background(), message); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !session.Healthy() {
+		t.Error("Expected the session to be healthy again once a request succeeded")
+	}
+}
@@ -0,0 +1,12 @@
+package llm
+
+import "fmt"
+
+// rephraseGuidance returns the system prompt addition asking to explain the
+// previous response again, in simpler words, when the child said they did
+// not understand it
+func rephraseGuidance(previous string) string {
+	return fmt.Sprintf(`
+
+EXPLAIN SIMPLER: The child did not understand your previous answer: %q. Do not treat their message as a new question and do not change the subject. Explain the same thing again in simpler words, with shorter sentences and an example from a young child's everyday life, then gently check that it makes sense now.`, previous)
+}
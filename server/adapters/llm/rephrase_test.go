@@ -0,0 +1,32 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+func TestGeminiChatSession_ExplainsPreviousResponseSimpler(t *testing.T) {
+	session, gemini := newMockedChatSession(t,
+		`{"candidates":[{"finishReason":"STOP","content":{"role":"model","parts":[{"text":"Begini..."}]}}]}`)
+	message := entities.Message{Role: entities.UserRole, Content: "aku tidak mengerti"}
+	ctx := repositories.WithRephrase(context.Background(), "Fotosintesis adalah proses tumbuhan membuat makanan")
+
+	if _, err := session.SendMessage(ctx, message); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	request := gemini.lastRequest()
+	if !strings.Contains(request, "EXPLAIN SIMPLER") || !strings.Contains(request, "Fotosintesis adalah proses tumbuhan membuat makanan") {
+		t.Errorf("Expected the previous response to be explained again, got:\n%s", request)
+	}
+
+	if _, err := session.SendMessage(context.Background(), message); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if request := gemini.lastRequest(); strings.Contains(request, "EXPLAIN SIMPLER") {
+		t.Errorf("Expected no rephrase without a previous response, got:\n%s", request)
+	}
+}
@@ -0,0 +1,11 @@
+package llm
+
+import "fmt"
+
+// replyLanguageGuidance returns the system prompt addition locking the
+// responses to the language the child's parent chose
+func replyLanguageGuidance(language string) string {
+	return fmt.Sprintf(`
+
+LANGUAGE: Always answer in the language with the BCP-47 tag %s, even when the child speaks or asks for another language.`, language)
+}
@@ -0,0 +1,31 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+func TestGeminiChatSession_LocksReplyLanguage(t *testing.T) {
+	session, gemini := newMockedChatSession(t,
+		`{"candidates":[{"finishReason":"STOP","content":{"role":"model","parts":[{"text":"Halo juga!"}]}}]}`)
+	english := entities.Message{Role: entities.UserRole, Content: "can we talk in English?"}
+
+	ctx := repositories.WithReplyLanguage(context.Background(), "id-ID")
+	if _, err := session.SendMessage(ctx, english); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if request := gemini.lastRequest(); !strings.Contains(request, "BCP-47 tag id-ID, even when the child speaks or asks for another language") {
+		t.Errorf("Expected the reply language in the prompt, got:\n%s", request)
+	}
+
+	if _, err := session.SendMessage(context.Background(), english); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if request := gemini.lastRequest(); strings.Contains(request, "LANGUAGE:") {
+		t.Errorf("Expected no reply language without a lock, got:\n%s", request)
+	}
+}
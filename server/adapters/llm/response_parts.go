@@ -0,0 +1,71 @@
+package llm
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/genai"
+)
+
+// responseText turns the parts of a response into the text the doll speaks.
+// Text parts are joined, leaving out the model's thoughts. Function calls go
+// to the tool registry, whose replies are spoken only when the model said
+// nothing itself. Other parts, such as inline data, are logged and dropped.
+func (s *GeminiChatSession) responseText(ctx context.Context, parts []*genai.Part) string {
+	var text, toolText strings.Builder
+	for _, part := range parts {
+		switch {
+		case part == nil:
+		case part.FunctionCall != nil:
+			toolText.WriteString(s.callFunction(ctx, part.FunctionCall))
+		case part.Thought:
+			// Reasoning is never spoken
+		case part.Text != "":
+			text.WriteString(part.Text)
+		default:
+			s.logger.Info("Ignoring unsupported response part", zap.String("kind", partKind(part)))
+		}
+	}
+
+	if strings.TrimSpace(text.String()) == "" {
+		return toolText.String()
+	}
+	return text.String()
+}
+
+// callFunction runs a function call of the model and returns the text to
+// speak for it, if any
+func (s *GeminiChatSession) callFunction(ctx context.Context, call *genai.FunctionCall) string {
+	if s.tools == nil {
+		s.logger.Warn("Ignoring function call without a tool registry", zap.String("function", call.Name))
+		return ""
+	}
+
+	reply, err := s.tools.CallFunction(ctx, call.Name, call.Args)
+	if err != nil {
+		s.logger.Warn("Function call failed",
+			zap.String("function", call.Name),
+			zap.Error(err))
+		return ""
+	}
+	return reply
+}
+
+// partKind names the content of a non-text part for logging
+func partKind(part *genai.Part) string {
+	switch {
+	case part.InlineData != nil:
+		return "inline_data:" + part.InlineData.MIMEType
+	case part.FileData != nil:
+		return "file_data:" + part.FileData.MIMEType
+	case part.ExecutableCode != nil:
+		return "executable_code"
+	case part.CodeExecutionResult != nil:
+		return "code_execution_result"
+	case part.FunctionResponse != nil:
+		return "function_response"
+	default:
+		return "empty"
+	}
+}
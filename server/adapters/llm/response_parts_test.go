@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/genai"
+)
+
+// fakeTools records function calls and answers with reply
+type fakeTools struct {
+	reply string
+	err   error
+	calls []string
+}
+
+func (f *fakeTools) CallFunction(ctx context.Context, name string, args map[string]any) (string, error) {
+	f.calls = append(f.calls, name)
+	return f.reply, f.err
+}
+
+func TestGeminiChatSession_ResponseText(t *testing.T) {
+	call := &genai.Part{FunctionCall: &genai.FunctionCall{Name: "play_song", Args: map[string]any{"title": "Balonku"}}}
+	image := &genai.Part{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte{0x89}}}
+
+	tests := []struct {
+		name      string
+		parts     []*genai.Part
+		tools     *fakeTools
+		want      string
+		wantCalls int
+	}{
+		{"text only", []*genai.Part{{Text: "Halo "}, {Text: "teman!"}}, &fakeTools{}, "Halo teman!", 0},
+		{"thoughts are not spoken", []*genai.Part{{Text: "The child greets me", Thought: true}, {Text: "Halo!"}}, &fakeTools{}, "Halo!", 0},
+		{"function call only", []*genai.Part{call}, &fakeTools{reply: "Ayo bernyanyi!"}, "Ayo bernyanyi!", 1},
+		{"mixed", []*genai.Part{{Text: "Ini lagunya!"}, call, image}, &fakeTools{reply: "Ayo bernyanyi!"}, "Ini lagunya!", 1},
+		{"function call failing", []*genai.Part{call}, &fakeTools{reply: "unused", err: errors.New("no such song")}, "", 1},
+		{"function call without registry", []*genai.Part{call}, nil, "", 0},
+		{"unsupported only", []*genai.Part{image, nil}, &fakeTools{}, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &GeminiChatSession{logger: zaptest.NewLogger(t)}
+			if tt.tools != nil {
+				s.tools = tt.tools
+			}
+			if got := s.responseText(context.Background(), tt.parts); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+			if tt.tools != nil && len(tt.tools.calls) != tt.wantCalls {
+				t.Errorf("Expected %d function calls, got %v", tt.wantCalls, tt.tools.calls)
+			}
+		})
+	}
+}
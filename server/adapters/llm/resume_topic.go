@@ -0,0 +1,11 @@
+package llm
+
+import "fmt"
+
+// resumeTopicGuidance returns the system prompt addition asking to welcome
+// the child back to the conversation, last about topic, before answering
+func resumeTopicGuidance(topic string) string {
+	return fmt.Sprintf(`
+
+WELCOME BACK: The child is back after a short break. Before the break you were talking about this: %q. Start your answer with one short, warm sentence welcoming the child back that mentions what you were talking about, then answer their message. Do not ask whether they want to continue the old topic if they asked about something else.`, topic)
+}
@@ -0,0 +1,32 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+func TestGeminiChatSession_WelcomesChildBack(t *testing.T) {
+	session, gemini := newMockedChatSession(t,
+		`{"candidates":[{"finishReason":"STOP","content":{"role":"model","parts":[{"text":"Selamat datang kembali!"}]}}]}`)
+	message := entities.Message{Role: entities.UserRole, Content: "aku pulang"}
+	ctx := repositories.WithResumeTopic(context.Background(), "dinosaurus terbesar")
+
+	if _, err := session.SendMessage(ctx, message); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	request := gemini.lastRequest()
+	if !strings.Contains(request, "WELCOME BACK") || !strings.Contains(request, "dinosaurus terbesar") {
+		t.Errorf("Expected the child to be welcomed back to the topic, got:\n%s", request)
+	}
+
+	if _, err := session.SendMessage(context.Background(), message); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if request := gemini.lastRequest(); strings.Contains(request, "WELCOME BACK") {
+		t.Errorf("Expected no welcome back without a resume topic, got:\n%s", request)
+	}
+}
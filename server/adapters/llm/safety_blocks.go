@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"expvar"
+
+	"google.golang.org/genai"
+)
+
+// contentBlocked counts the prompts and responses Gemini blocked for safety,
+// by harm category
+var contentBlocked = expvar.NewMap("llm_content_blocked")
+
+// safetyFinishReasons are the finish reasons of a response withheld for safety
+var safetyFinishReasons = map[genai.FinishReason]bool{
+	genai.FinishReasonSafety:            true,
+	genai.FinishReasonBlocklist:         true,
+	genai.FinishReasonProhibitedContent: true,
+	genai.FinishReasonSPII:              true,
+	genai.FinishReasonImageSafety:       true,
+}
+
+// blockedCategory reports whether Gemini blocked the prompt or the response
+// for safety, and the harm category it was blocked for. Without a blocked
+// safety rating the block reason stands in for the category.
+func blockedCategory(response *genai.GenerateContentResponse) (string, bool) {
+	if feedback := response.PromptFeedback; feedback != nil &&
+		feedback.BlockReason != "" && feedback.BlockReason != genai.BlockedReasonUnspecified {
+		return harmCategory(feedback.SafetyRatings, string(feedback.BlockReason)), true
+	}
+	if len(response.Candidates) == 0 || response.Candidates[0] == nil {
+		return "", false
+	}
+	candidate := response.Candidates[0]
+	if !safetyFinishReasons[candidate.FinishReason] {
+		return "", false
+	}
+	return harmCategory(candidate.SafetyRatings, string(candidate.FinishReason)), true
+}
+
+// harmCategory returns the category of the first blocked rating, or reason
+// when none is marked blocked
+func harmCategory(ratings []*genai.SafetyRating, reason string) string {
+	for _, rating := range ratings {
+		if rating != nil && rating.Blocked {
+			return string(rating.Category)
+		}
+	}
+	return reason
+}
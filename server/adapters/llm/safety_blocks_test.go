@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/genai"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// mockedGemini answers every request with a fixed JSON response body and
+// records the request bodies and paths
+type mockedGemini struct {
+	mu       sync.Mutex
+	requests []string
+	paths    []string
+}
+
+// lastRequest returns the body of the last request sent to Gemini
+func (m *mockedGemini) lastRequest() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.requests) == 0 {
+		return ""
+	}
+	return m.requests[len(m.requests)-1]
+}
+
+// lastPath returns the URL path of the last request sent to Gemini, which
+// names the model
+func (m *mockedGemini) lastPath() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.paths) == 0 {
+		return ""
+	}
+	return m.paths[len(m.paths)-1]
+}
+
+// newMockedChatSession returns a session whose requests Gemini answers with
+// the JSON response body
+func newMockedChatSession(t *testing.T, body string) (*GeminiChatSession, *mockedGemini) {
+	t.Helper()
+	mock := &mockedGemini{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request, _ := io.ReadAll(r.Body)
+		mock.mu.Lock()
+		mock.requests = append(mock.requests, string(request))
+		mock.paths = append(mock.paths, r.URL.Path)
+		mock.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:      "test",
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: genai.HTTPOptions{BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	session, err := NewGeminiChatSession(client, GeminiConfig{APIKey: "test"}, zaptest.NewLogger(t), nil)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	return session, mock
+}
+
+func TestGeminiChatSession_RedirectsSafetyBlocks(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantCategory string
+	}{
+		{
+			name:         "blocked response",
+			body:         `{"candidates":[{"finishReason":"SAFETY","safetyRatings":[{"category":"HARM_CATEGORY_HARASSMENT","probability":"LOW"},{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","probability":"HIGH","blocked":true}]}]}`,
+			wantCategory: "HARM_CATEGORY_DANGEROUS_CONTENT",
+		},
+		{
+			name:         "blocked prompt",
+			body:         `{"promptFeedback":{"blockReason":"SAFETY","safetyRatings":[{"category":"HARM_CATEGORY_SEXUALLY_EXPLICIT","probability":"MEDIUM","blocked":true}]}}`,
+			wantCategory: "HARM_CATEGORY_SEXUALLY_EXPLICIT",
+		},
+		{
+			name:         "blocklist without ratings",
+			body:         `{"candidates":[{"finishReason":"BLOCKLIST"}]}`,
+			wantCategory: "BLOCKLIST",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, _ := newMockedChatSession(t, tt.body)
+			before := blockedCount(tt.wantCategory)
+
+			response, err := session.SendMessage(context.Background(), entities.Message{Role: entities.UserRole, Content: "halo"})
+			if err != nil {
+				t.Fatalf("Expected the block to be answered, got %v", err)
+			}
+			if !slices.Contains(GeminiHardcodedConfig.Redirections, response.Content) {
+				t.Errorf("Expected a redirection, got %q", response.Content)
+			}
+			if got := blockedCount(tt.wantCategory) - before; got != 1 {
+				t.Errorf("Expected one content_blocked count for %s, got %d", tt.wantCategory, got)
+			}
+		})
+	}
+}
+
+func TestGeminiChatSession_AnswersUnblockedResponse(t *testing.T) {
+	session, _ := newMockedChatSession(t,
+		`{"candidates":[{"finishReason":"STOP","content":{"role":"model","parts":[{"text":"Halo juga!"}]},"safetyRatings":[{"category":"HARM_CATEGORY_HARASSMENT","probability":"NEGLIGIBLE"}]}]}`)
+
+	response, err := session.SendMessage(context.Background(), entities.Message{Role: entities.UserRole, Content: "halo"})
+	if err != nil || response.Content != "Halo juga!" {
+		t.Errorf("Expected the model's response, got %q: %v", response.Content, err)
+	}
+}
+
+// blockedCount returns the content_blocked count of category
+func blockedCount(category string) int64 {
+	if count, ok := contentBlocked.Get(category).(interface{ Value() int64 }); ok {
+		return count.Value()
+	}
+	return 0
+}
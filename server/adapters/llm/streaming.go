@@ -0,0 +1,123 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/genai"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// Ensure GeminiChatSession can stream its responses
+var _ repositories.StreamingChatSession = (*GeminiChatSession)(nil)
+
+// SendMessageStream implements repositories.StreamingChatSession. Text passed
+// to onText cannot be taken back, so only a stream failing before its first
+// text is retried. A stream failing midway ends the response with what was
+// said so far; one blocked for safety midway moves on to a redirection.
+func (s *GeminiChatSession) SendMessageStream(ctx context.Context, message entities.Message, onText func(text string)) (entities.Message, error) {
+	contents, userContent, config := s.prepareRequest(ctx, message)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.timeoutSeconds)*time.Second)
+	defer cancel()
+
+	var text, toolText strings.Builder
+	var category string
+	var blocked bool
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		toolText.Reset()
+		category, blocked, err = s.streamContent(ctx, contents, config, &text, &toolText, onText)
+		if err == nil || text.Len() > 0 {
+			break
+		}
+
+		s.logger.Warn("Failed to stream content, retrying",
+			zap.Int("attempt", attempt+1),
+			zap.Error(err))
+
+		if attempt < 2 {
+			time.Sleep(time.Duration(attempt+1) * s.retryDelay)
+		}
+	}
+
+	s.failed = err != nil && text.Len() == 0
+	switch {
+	case blocked:
+		contentBlocked.Add(category, 1)
+		s.logger.Warn("Gemini blocked content for safety",
+			zap.String("event", "content_blocked"),
+			zap.String("category", category),
+			zap.Bool("midway", text.Len() > 0))
+		if text.Len() == 0 {
+			return s.streamCannedResponse(GeminiHardcodedConfig.Redirections, onText), nil
+		}
+		redirection := " " + pickText(GeminiHardcodedConfig.Redirections)
+		onText(redirection)
+		text.WriteString(redirection)
+	case err != nil && text.Len() == 0:
+		s.logger.Error("Failed to stream message in chat session", zap.Error(err))
+		return s.streamCannedResponse(GeminiHardcodedConfig.Fallbacks, onText), nil
+	case err != nil:
+		s.logger.Warn("Gemini stream failed midway, ending the response early", zap.Error(err))
+	}
+
+	responseText := text.String()
+	if strings.TrimSpace(responseText) == "" {
+		if strings.TrimSpace(toolText.String()) == "" {
+			s.logger.Warn("Empty response in chat session")
+			return s.streamCannedResponse(GeminiHardcodedConfig.Fallbacks, onText), nil
+		}
+		responseText = toolText.String()
+		onText(responseText)
+	}
+
+	return s.recordResponse(message, userContent, responseText), nil
+}
+
+// streamContent streams one response, writing its text to text as it passes
+// it to onText and the replies of its function calls to toolText. It reports
+// the harm category when Gemini blocked the response for safety.
+func (s *GeminiChatSession) streamContent(ctx context.Context, contents []*genai.Content, config *genai.GenerateContentConfig, text, toolText *strings.Builder, onText func(text string)) (string, bool, error) {
+	client := s.clients.get(ctx)
+	for response, err := range client.Models.GenerateContentStream(ctx, s.modelFor(ctx), contents, config) {
+		if err != nil {
+			s.clients.report(client, err)
+			return "", false, err
+		}
+		if category, blocked := blockedCategory(response); blocked {
+			return category, true, nil
+		}
+		if len(response.Candidates) == 0 || response.Candidates[0].Content == nil {
+			continue
+		}
+
+		for _, part := range response.Candidates[0].Content.Parts {
+			switch {
+			case part == nil:
+			case part.FunctionCall != nil:
+				toolText.WriteString(s.callFunction(ctx, part.FunctionCall))
+			case part.Thought:
+				// Reasoning is never spoken
+			case part.Text != "":
+				text.WriteString(part.Text)
+				onText(part.Text)
+			default:
+				s.logger.Info("Ignoring unsupported response part", zap.String("kind", partKind(part)))
+			}
+		}
+	}
+	return "", false, nil
+}
+
+// streamCannedResponse picks one of texts as the response like
+// cannedResponse, passing it to onText
+func (s *GeminiChatSession) streamCannedResponse(texts []string, onText func(text string)) entities.Message {
+	message := s.cannedResponse(texts)
+	onText(message.Content)
+	return message
+}
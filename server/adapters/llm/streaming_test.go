@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// sse encodes responses as the server-sent events of a streamed response
+func sse(responses ...string) string {
+	var body strings.Builder
+	for _, response := range responses {
+		body.WriteString("data: " + response + "\n\n")
+	}
+	return body.String()
+}
+
+func TestGeminiChatSession_StreamsResponseText(t *testing.T) {
+	session, _ := newMockedChatSession(t, sse(
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hmm, ","thought":true},{"text":"Halo! "}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Apa kabar?"}]},"finishReason":"STOP"}]}`,
+	))
+
+	var pieces []string
+	response, err := session.SendMessageStream(context.Background(), entities.Message{Role: entities.UserRole, Content: "halo"},
+		func(text string) { pieces = append(pieces, text) })
+	if err != nil {
+		t.Fatalf("Expected the response, got %v", err)
+	}
+	if !slices.Equal(pieces, []string{"Halo! ", "Apa kabar?"}) {
+		t.Errorf("Expected the text as it streamed without thoughts, got %q", pieces)
+	}
+	if response.Content != "Halo! Apa kabar?" {
+		t.Errorf("Expected the whole response, got %q", response.Content)
+	}
+
+	history, _ := session.History()
+	if len(history) != 2 || history[1].Content != "Halo! Apa kabar?" {
+		t.Errorf("Expected the turn in the history, got %v", history)
+	}
+}
+
+func TestGeminiChatSession_StreamRedirectsWhenBlockedMidway(t *testing.T) {
+	session, _ := newMockedChatSession(t, sse(
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Jadi begini."}]}}]}`,
+		`{"candidates":[{"finishReason":"SAFETY","safetyRatings":[{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","probability":"HIGH","blocked":true}]}]}`,
+	))
+	before := blockedCount("HARM_CATEGORY_DANGEROUS_CONTENT")
+
+	var pieces []string
+	response, err := session.SendMessageStream(context.Background(), entities.Message{Role: entities.UserRole, Content: "halo"},
+		func(text string) { pieces = append(pieces, text) })
+	if err != nil {
+		t.Fatalf("Expected the block to be answered, got %v", err)
+	}
+	if len(pieces) != 2 || !slices.Contains(GeminiHardcodedConfig.Redirections, strings.TrimPrefix(pieces[1], " ")) {
+		t.Fatalf("Expected a redirection after the text already spoken, got %q", pieces)
+	}
+	if response.Content != strings.Join(pieces, "") {
+		t.Errorf("Expected the response to be what was spoken, got %q", response.Content)
+	}
+	if got := blockedCount("HARM_CATEGORY_DANGEROUS_CONTENT") - before; got != 1 {
+		t.Errorf("Expected one content_blocked count, got %d", got)
+	}
+}
+
+func TestGeminiChatSession_StreamFallsBackOnEmptyResponse(t *testing.T) {
+	session, _ := newMockedChatSession(t, sse(
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":" "}]},"finishReason":"STOP"}]}`,
+	))
+
+	var pieces []string
+	response, err := session.SendMessageStream(context.Background(), entities.Message{Role: entities.UserRole, Content: "halo"},
+		func(text string) { pieces = append(pieces, text) })
+	if err != nil || !slices.Contains(GeminiHardcodedConfig.Fallbacks, response.Content) {
+		t.Fatalf("Expected a fallback, got %q: %v", response.Content, err)
+	}
+	if pieces[len(pieces)-1] != response.Content {
+		t.Errorf("Expected the fallback to be passed on, got %q", pieces)
+	}
+}
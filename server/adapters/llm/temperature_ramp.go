@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// TemperaturePoint sets the temperature of a turn of the conversation,
+// counting the child's messages from 1
+type TemperaturePoint struct {
+	Turn        int     `json:"turn"`
+	Temperature float32 `json:"temperature"`
+}
+
+// ParseTemperatureRamp parses a temperature ramp encoded as a JSON array, e.g.
+// [{"turn": 1, "temperature": 0.4}, {"turn": 6, "temperature": 0.8}]
+func ParseTemperatureRamp(data string) ([]TemperaturePoint, error) {
+	var ramp []TemperaturePoint
+	if err := json.Unmarshal([]byte(data), &ramp); err != nil {
+		return nil, fmt.Errorf("failed to parse temperature ramp: %w", err)
+	}
+	if err := ValidateTemperatureRamp(ramp); err != nil {
+		return nil, err
+	}
+	return ramp, nil
+}
+
+// ValidateTemperatureRamp checks that the points of the ramp are in
+// increasing turn order. Temperatures are clamped to [0, 1] when applied.
+func ValidateTemperatureRamp(ramp []TemperaturePoint) error {
+	for i, point := range ramp {
+		if point.Turn < 1 {
+			return fmt.Errorf("temperature ramp turn %d must be at least 1", point.Turn)
+		}
+		if i > 0 && point.Turn <= ramp[i-1].Turn {
+			return fmt.Errorf("temperature ramp turn %d must come after turn %d", point.Turn, ramp[i-1].Turn)
+		}
+	}
+	return nil
+}
+
+// rampTemperature returns the temperature of turn following ramp. Between two
+// points the temperature changes linearly; before the first and after the
+// last point it stays at theirs.
+func rampTemperature(ramp []TemperaturePoint, turn int) float32 {
+	temperature := ramp[len(ramp)-1].Temperature
+	if turn <= ramp[0].Turn {
+		temperature = ramp[0].Temperature
+	}
+	for i := 1; i < len(ramp); i++ {
+		from, to := ramp[i-1], ramp[i]
+		if turn >= from.Turn && turn <= to.Turn {
+			progress := float32(turn-from.Turn) / float32(to.Turn-from.Turn)
+			temperature = from.Temperature + progress*(to.Temperature-from.Temperature)
+			break
+		}
+	}
+	switch {
+	case temperature < 0:
+		return 0
+	case temperature > 1:
+		return 1
+	default:
+		return temperature
+	}
+}
+
+// countTurns returns the number of messages of the child in history
+func countTurns(history []entities.Message) int {
+	turns := 0
+	for _, msg := range history {
+		if msg.Role == entities.UserRole {
+			turns++
+		}
+	}
+	return turns
+}
+
+// nextTemperature starts the next turn of the session and returns its
+// temperature, the configured one unless a temperature ramp applies
+func (s *GeminiChatSession) nextTemperature() float32 {
+	s.turns++
+	if len(s.temperatureRamp) == 0 {
+		return s.temperature
+	}
+	return rampTemperature(s.temperatureRamp, s.turns)
+}
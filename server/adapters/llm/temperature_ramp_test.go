@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"math"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+func TestValidateTemperatureRamp(t *testing.T) {
+	invalid := map[string][]TemperaturePoint{
+		"turn zero":   {{Turn: 0, Temperature: 0.5}},
+		"unordered":   {{Turn: 4, Temperature: 0.5}, {Turn: 2, Temperature: 0.7}},
+		"repeated":    {{Turn: 2, Temperature: 0.5}, {Turn: 2, Temperature: 0.7}},
+		"second zero": {{Turn: 1, Temperature: 0.5}, {Turn: 0, Temperature: 0.7}},
+	}
+	for name, ramp := range invalid {
+		if err := ValidateTemperatureRamp(ramp); err == nil {
+			t.Errorf("Expected a %s ramp to be rejected", name)
+		}
+	}
+
+	if _, err := ParseTemperatureRamp(`[{"turn": 3, "temperature": 0.2}, {"turn": 1, "temperature": 0.8}]`); err == nil {
+		t.Errorf("Expected ParseTemperatureRamp to validate the parsed ramp")
+	}
+}
+
+func TestGeminiChatSession_FollowsTemperatureRamp(t *testing.T) {
+	// Warming up over the first turns, then calming down towards the end
+	ramp := []TemperaturePoint{{Turn: 2, Temperature: 0.2}, {Turn: 6, Temperature: 1.4}, {Turn: 8, Temperature: 0.6}}
+	session, err := NewGeminiChatSession(nil, GeminiConfig{APIKey: "test", TemperatureRamp: ramp}, zaptest.NewLogger(t), nil)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	// Above 1 the ramp is clamped
+	want := []float32{0.2, 0.2, 0.5, 0.8, 1, 1, 1, 0.6, 0.6}
+	for turn, expected := range want {
+		if got := session.nextTemperature(); math.Abs(float64(got-expected)) > 1e-6 {
+			t.Errorf("Turn %d: expected temperature %.2f, got %.2f", turn+1, expected, got)
+		}
+	}
+}
+
+func TestGeminiChatSession_TemperatureRampContinuesHistory(t *testing.T) {
+	history := []entities.Message{
+		{Role: entities.UserRole, Content: "halo"},
+		{Role: entities.DollRole, Content: "halo juga"},
+		{Role: entities.UserRole, Content: "apa kabar?"},
+		{Role: entities.DollRole, Content: "baik!"},
+	}
+	ramp := []TemperaturePoint{{Turn: 1, Temperature: 0.3}, {Turn: 5, Temperature: 0.7}}
+	session, err := NewGeminiChatSession(nil, GeminiConfig{APIKey: "test", TemperatureRamp: ramp}, zaptest.NewLogger(t), history)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	if got := session.nextTemperature(); math.Abs(float64(got-0.5)) > 1e-6 {
+		t.Errorf("Expected the third turn of the session at 0.5, got %.2f", got)
+	}
+}
+
+func TestGeminiChatSession_FixedTemperatureWithoutRamp(t *testing.T) {
+	session, err := NewGeminiChatSession(nil, GeminiConfig{APIKey: "test", Temperature: 0.4}, zaptest.NewLogger(t), nil)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	for turn := 1; turn <= 3; turn++ {
+		if got := session.nextTemperature(); got != 0.4 {
+			t.Errorf("Turn %d: expected the configured temperature, got %.2f", turn, got)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package llm
+
+import (
+	"fmt"
+	"time"
+)
+
+// Indonesian names of the days of the week, indexed by time.Weekday
+var dayNames = [...]string{"Minggu", "Senin", "Selasa", "Rabu", "Kamis", "Jumat", "Sabtu"}
+
+// partOfDay names the part of the day of t the way Indonesian greetings do:
+// pagi until 11:00, siang until 15:00, sore until 18:00 and malam otherwise
+func partOfDay(t time.Time) string {
+	switch hour := t.Hour(); {
+	case hour >= 4 && hour < 11:
+		return "pagi (morning)"
+	case hour >= 11 && hour < 15:
+		return "siang (midday)"
+	case hour >= 15 && hour < 18:
+		return "sore (afternoon)"
+	default:
+		return "malam (night)"
+	}
+}
+
+// timeOfDayGuidance returns the system prompt addition telling the model the
+// child's local time, so that greetings and suggestions fit the time of day
+func timeOfDayGuidance(t time.Time) string {
+	return fmt.Sprintf(`
+
+CURRENT TIME: For the child it is %s, %s, %s.
+Greet and suggest activities that fit this time of day, e.g. "Selamat pagi!" in the morning or winding down at night.`,
+		dayNames[t.Weekday()], t.Format("15:04"), partOfDay(t))
+}
@@ -0,0 +1,39 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeOfDayGuidance(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatalf("Failed to load timezone: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		time time.Time
+		want string
+	}{
+		{"morning", time.Date(2024, time.March, 4, 7, 15, 0, 0, jakarta), "Senin, 07:15, pagi"},
+		{"midday", time.Date(2024, time.March, 4, 12, 0, 0, 0, jakarta), "Senin, 12:00, siang"},
+		{"afternoon", time.Date(2024, time.March, 4, 16, 30, 0, 0, jakarta), "Senin, 16:30, sore"},
+		{"evening", time.Date(2024, time.March, 4, 19, 45, 0, 0, jakarta), "Senin, 19:45, malam"},
+		{"after midnight", time.Date(2024, time.March, 10, 2, 0, 0, 0, jakarta), "Minggu, 02:00, malam"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timeOfDayGuidance(tt.time); !strings.Contains(got, tt.want) {
+				t.Errorf("Expected %q in the guidance, got:\n%s", tt.want, got)
+			}
+		})
+	}
+
+	// The guidance follows the child's clock, not the server's
+	instant := time.Date(2024, time.March, 4, 0, 30, 0, 0, time.UTC)
+	if morning, night := timeOfDayGuidance(instant.In(jakarta)), timeOfDayGuidance(instant); morning == night {
+		t.Errorf("Expected the guidance to change with the timezone, got:\n%s", morning)
+	}
+}
@@ -0,0 +1,18 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// topicGuidance returns the system prompt addition asking the model to steer
+// away from the topics the child's parent discouraged
+func topicGuidance(topics []string) string {
+	if len(topics) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`
+
+AVOID THESE TOPICS: The child's parents asked you not to talk about: %s.
+If the child brings one of them up, do not explain or discuss it. Acknowledge the child kindly and gently steer the conversation to a different, cheerful subject.`, strings.Join(topics, ", "))
+}
@@ -0,0 +1,39 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+func TestGeminiLLM_InjectsDiscouragedTopics(t *testing.T) {
+	g := &GeminiLLM{
+		logger: zaptest.NewLogger(t),
+		config: GeminiConfig{APIKey: "key", MaxOutputTokens: 500},
+	}
+
+	ctx := repositories.WithDiscouragedTopics(repositories.WithChildAge(context.Background(), 4), []string{"monsters", "ghost stories"})
+	chat, err := g.GenerateChat(ctx, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate chat: %v", err)
+	}
+	prompt := chat.(*GeminiChatSession).systemPrompt
+	if !strings.Contains(prompt, "not to talk about: monsters, ghost stories.") {
+		t.Errorf("Expected the discouraged topics in the prompt, got:\n%s", prompt)
+	}
+	if !strings.Contains(prompt, "written for a preschooler") {
+		t.Errorf("Expected the age band guidance to be kept, got:\n%s", prompt)
+	}
+
+	chat, err = g.GenerateChat(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Failed to generate chat: %v", err)
+	}
+	if prompt := chat.(*GeminiChatSession).systemPrompt; prompt != GeminiHardcodedConfig.SystemPrompt {
+		t.Errorf("Expected the default prompt without topics, got:\n%s", prompt)
+	}
+}
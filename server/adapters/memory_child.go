@@ -0,0 +1,170 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/errs"
+)
+
+// MemoryChildRepository is an in-memory implementation of ChildRepository
+type MemoryChildRepository struct {
+	mu       sync.RWMutex
+	children map[string]*entities.Child // id -> child mapping
+}
+
+// NewMemoryChildRepository creates a new in-memory child repository
+func NewMemoryChildRepository() *MemoryChildRepository {
+	return &MemoryChildRepository{
+		children: make(map[string]*entities.Child),
+	}
+}
+
+// copyChild returns a deep copy to prevent external modifications
+func copyChild(child *entities.Child) *entities.Child {
+	childCopy := *child
+	childCopy.DeviceIDs = append([]string(nil), child.DeviceIDs...)
+	childCopy.LearnedWords = append([]entities.LearnedWord(nil), child.LearnedWords...)
+	return &childCopy
+}
+
+// Create implements ChildRepository interface
+func (m *MemoryChildRepository) Create(ctx context.Context, child *entities.Child) error {
+	if child == nil {
+		return errors.New("child cannot be nil")
+	}
+
+	if err := child.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Generate ID if not provided
+	if child.ID == "" {
+		child.ID = uuid.New().String()
+	}
+
+	if _, exists := m.children[child.ID]; exists {
+		return fmt.Errorf("child %q: %w", child.ID, errs.ErrChildExists)
+	}
+
+	// Set timestamps
+	now := time.Now()
+	child.CreatedAt = now
+	child.UpdatedAt = now
+
+	m.children[child.ID] = copyChild(child)
+
+	return nil
+}
+
+// GetByID implements ChildRepository interface
+func (m *MemoryChildRepository) GetByID(ctx context.Context, id string) (*entities.Child, error) {
+	if id == "" {
+		return nil, errors.New("child ID cannot be empty")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	child, exists := m.children[id]
+	if !exists {
+		return nil, fmt.Errorf("child %q: %w", id, errs.ErrChildNotFound)
+	}
+
+	return copyChild(child), nil
+}
+
+// GetByOwnerID implements ChildRepository interface
+func (m *MemoryChildRepository) GetByOwnerID(ctx context.Context, ownerID string) ([]*entities.Child, error) {
+	if ownerID == "" {
+		return nil, errors.New("owner ID cannot be empty")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := []*entities.Child{}
+	for _, child := range m.children {
+		if child.OwnerID == ownerID {
+			result = append(result, copyChild(child))
+		}
+	}
+
+	return result, nil
+}
+
+// GetByDeviceID implements ChildRepository interface
+func (m *MemoryChildRepository) GetByDeviceID(ctx context.Context, deviceID string) (*entities.Child, error) {
+	if deviceID == "" {
+		return nil, errors.New("device ID cannot be empty")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, child := range m.children {
+		for _, id := range child.DeviceIDs {
+			if id == deviceID {
+				return copyChild(child), nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("device %q: %w", deviceID, errs.ErrChildNotFound)
+}
+
+// Update implements ChildRepository interface
+func (m *MemoryChildRepository) Update(ctx context.Context, child *entities.Child) error {
+	if child == nil {
+		return errors.New("child cannot be nil")
+	}
+
+	if child.ID == "" {
+		return errors.New("child ID cannot be empty")
+	}
+
+	if err := child.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existingChild, exists := m.children[child.ID]
+	if !exists {
+		return fmt.Errorf("child %q: %w", child.ID, errs.ErrChildNotFound)
+	}
+
+	// Update timestamps
+	child.UpdatedAt = time.Now()
+	child.CreatedAt = existingChild.CreatedAt // Preserve original creation time
+
+	m.children[child.ID] = copyChild(child)
+
+	return nil
+}
+
+// Delete implements ChildRepository interface
+func (m *MemoryChildRepository) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("child ID cannot be empty")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.children[id]; !exists {
+		return fmt.Errorf("child %q: %w", id, errs.ErrChildNotFound)
+	}
+
+	delete(m.children, id)
+	return nil
+}
@@ -3,21 +3,31 @@ package adapters
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/errs"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// Ensure MemoryDeviceRepository implements the DeviceRepository and DeviceProvisioner interfaces
+var (
+	_ repositories.DeviceRepository  = (*MemoryDeviceRepository)(nil)
+	_ repositories.DeviceProvisioner = (*MemoryDeviceRepository)(nil)
 )
 
 // MemoryDeviceRepository is a production-ready in-memory implementation of DeviceRepository
 // This is suitable for production use as a simple storage backend
 type MemoryDeviceRepository struct {
 	mu      sync.RWMutex
-	devices map[string]*entities.Device           // id -> device mapping
-	secrets map[string]string                     // serial_number -> secret_key mapping
-	serials map[string]*entities.Device           // serial_number -> device mapping
-	owners  map[string][]*entities.Device         // owner_id -> devices mapping
+	devices map[string]*entities.Device   // id -> device mapping
+	secrets map[string]string             // serial_number -> secret_key mapping
+	serials map[string]*entities.Device   // serial_number -> device mapping
+	owners  map[string][]*entities.Device // owner_id -> devices mapping
 }
 
 // NewMemoryDeviceRepository creates a new in-memory device repository
@@ -40,20 +50,21 @@ func (m *MemoryDeviceRepository) ValidateDevice(serialNumber, secret string) (*e
 	// Check if secret matches
 	storedSecret, exists := m.secrets[serialNumber]
 	if !exists {
-		return nil, errors.New("device not found")
+		return nil, fmt.Errorf("serial number %q: %w", serialNumber, errs.ErrDeviceNotFound)
 	}
 
 	if storedSecret != secret {
-		return nil, errors.New("invalid credentials")
+		return nil, fmt.Errorf("serial number %q: %w", serialNumber, errs.ErrInvalidCredentials)
 	}
 
 	// Find and return the device
 	device, exists := m.serials[serialNumber]
 	if !exists {
-		return nil, errors.New("device not found")
+		return nil, fmt.Errorf("serial number %q: %w", serialNumber, errs.ErrDeviceNotFound)
 	}
 
-	return device, nil
+	// Return a copy, the stored device may be updated while the caller reads it
+	return copyDevice(device), nil
 }
 
 // Create implements DeviceRepository interface
@@ -71,7 +82,7 @@ func (m *MemoryDeviceRepository) Create(ctx context.Context, device *entities.De
 
 	// Check if device with same serial number already exists
 	if _, exists := m.serials[device.SerialNumber]; exists {
-		return errors.New("device with this serial number already exists")
+		return fmt.Errorf("serial number %q: %w", device.SerialNumber, errs.ErrDeviceExists)
 	}
 
 	// Generate ID if not provided
@@ -85,15 +96,7 @@ func (m *MemoryDeviceRepository) Create(ctx context.Context, device *entities.De
 	device.UpdatedAt = now
 
 	// Store device
-	deviceCopy := *device
-	m.devices[device.ID] = &deviceCopy
-	m.serials[device.SerialNumber] = &deviceCopy
-
-	// Update owner mapping if owner is set
-	if device.OwnerID != nil {
-		ownerID := *device.OwnerID
-		m.owners[ownerID] = append(m.owners[ownerID], &deviceCopy)
-	}
+	m.storeLocked(copyDevice(device))
 
 	return nil
 }
@@ -109,12 +112,11 @@ func (m *MemoryDeviceRepository) GetByID(ctx context.Context, id string) (*entit
 
 	device, exists := m.devices[id]
 	if !exists {
-		return nil, errors.New("device not found")
+		return nil, fmt.Errorf("device %q: %w", id, errs.ErrDeviceNotFound)
 	}
 
 	// Return a copy to prevent external modifications
-	deviceCopy := *device
-	return &deviceCopy, nil
+	return copyDevice(device), nil
 }
 
 // GetBySerialNumber implements DeviceRepository interface
@@ -128,12 +130,11 @@ func (m *MemoryDeviceRepository) GetBySerialNumber(ctx context.Context, serialNu
 
 	device, exists := m.serials[serialNumber]
 	if !exists {
-		return nil, errors.New("device not found")
+		return nil, fmt.Errorf("serial number %q: %w", serialNumber, errs.ErrDeviceNotFound)
 	}
 
 	// Return a copy to prevent external modifications
-	deviceCopy := *device
-	return &deviceCopy, nil
+	return copyDevice(device), nil
 }
 
 // GetByOwnerID implements DeviceRepository interface
@@ -153,8 +154,7 @@ func (m *MemoryDeviceRepository) GetByOwnerID(ctx context.Context, ownerID strin
 	// Return copies to prevent external modifications
 	result := make([]*entities.Device, len(devices))
 	for i, device := range devices {
-		deviceCopy := *device
-		result[i] = &deviceCopy
+		result[i] = copyDevice(device)
 	}
 
 	return result, nil
@@ -180,46 +180,34 @@ func (m *MemoryDeviceRepository) Update(ctx context.Context, device *entities.De
 	// Check if device exists
 	existingDevice, exists := m.devices[device.ID]
 	if !exists {
-		return errors.New("device not found")
+		return fmt.Errorf("device %q: %w", device.ID, errs.ErrDeviceNotFound)
 	}
 
 	// Check if serial number is being changed and conflicts with another device
 	if existingDevice.SerialNumber != device.SerialNumber {
 		if _, exists := m.serials[device.SerialNumber]; exists {
-			return errors.New("device with this serial number already exists")
+			return fmt.Errorf("serial number %q: %w", device.SerialNumber, errs.ErrDeviceExists)
 		}
 	}
 
-	// Update timestamps
-	device.UpdatedAt = time.Now()
-	device.CreatedAt = existingDevice.CreatedAt // Preserve original creation time
-
-	// Remove old mappings
-	delete(m.serials, existingDevice.SerialNumber)
-	if existingDevice.OwnerID != nil {
-		oldOwnerID := *existingDevice.OwnerID
-		ownerDevices := m.owners[oldOwnerID]
-		for i, d := range ownerDevices {
-			if d.ID == device.ID {
-				m.owners[oldOwnerID] = append(ownerDevices[:i], ownerDevices[i+1:]...)
-				if len(m.owners[oldOwnerID]) == 0 {
-					delete(m.owners, oldOwnerID)
-				}
-				break
-			}
-		}
+	// A device read before another update must not undo it
+	if !device.UpdatedAt.IsZero() && !device.UpdatedAt.Equal(existingDevice.UpdatedAt) {
+		return fmt.Errorf("device %q: %w", device.ID, errs.ErrDeviceModified)
 	}
 
-	// Store updated device
-	deviceCopy := *device
-	m.devices[device.ID] = &deviceCopy
-	m.serials[device.SerialNumber] = &deviceCopy
-
-	// Update owner mapping if owner is set
-	if device.OwnerID != nil {
-		ownerID := *device.OwnerID
-		m.owners[ownerID] = append(m.owners[ownerID], &deviceCopy)
+	// Update timestamps, always moving UpdatedAt forward so that every update
+	// tells apart the devices read before it
+	now := time.Now()
+	if !now.After(existingDevice.UpdatedAt) {
+		now = existingDevice.UpdatedAt.Add(time.Nanosecond)
 	}
+	device.UpdatedAt = now
+	device.CreatedAt = existingDevice.CreatedAt // Preserve original creation time
+
+	// Replace the old mappings
+	delete(m.serials, existingDevice.SerialNumber)
+	m.removeFromOwnerLocked(existingDevice)
+	m.storeLocked(copyDevice(device))
 
 	return nil
 }
@@ -235,7 +223,7 @@ func (m *MemoryDeviceRepository) Delete(ctx context.Context, id string) error {
 
 	device, exists := m.devices[id]
 	if !exists {
-		return errors.New("device not found")
+		return fmt.Errorf("device %q: %w", id, errs.ErrDeviceNotFound)
 	}
 
 	// Remove from all mappings
@@ -244,21 +232,60 @@ func (m *MemoryDeviceRepository) Delete(ctx context.Context, id string) error {
 	delete(m.secrets, device.SerialNumber)
 
 	// Remove from owner mapping
-	if device.OwnerID != nil {
-		ownerID := *device.OwnerID
-		ownerDevices := m.owners[ownerID]
-		for i, d := range ownerDevices {
-			if d.ID == id {
-				m.owners[ownerID] = append(ownerDevices[:i], ownerDevices[i+1:]...)
-				if len(m.owners[ownerID]) == 0 {
-					delete(m.owners, ownerID)
-				}
-				break
-			}
+	m.removeFromOwnerLocked(device)
+
+	return nil
+}
+
+// storeLocked stores device, which the repository owns from now on, in every
+// mapping. A device is listed once by its owner even when it was listed
+// already. The caller must hold the write lock.
+func (m *MemoryDeviceRepository) storeLocked(device *entities.Device) {
+	m.devices[device.ID] = device
+	m.serials[device.SerialNumber] = device
+	if device.OwnerID == nil {
+		return
+	}
+	ownerID := *device.OwnerID
+	owned := withoutDevice(m.owners[ownerID], device.ID)
+	m.owners[ownerID] = append(owned, device)
+}
+
+// removeFromOwnerLocked removes device from the devices of its owner.
+// The caller must hold the write lock.
+func (m *MemoryDeviceRepository) removeFromOwnerLocked(device *entities.Device) {
+	if device.OwnerID == nil {
+		return
+	}
+	ownerID := *device.OwnerID
+	owned := withoutDevice(m.owners[ownerID], device.ID)
+	if len(owned) == 0 {
+		delete(m.owners, ownerID)
+		return
+	}
+	m.owners[ownerID] = owned
+}
+
+// withoutDevice returns a new slice of devices without the device of id, so
+// that slices handed out before are never modified
+func withoutDevice(devices []*entities.Device, id string) []*entities.Device {
+	result := make([]*entities.Device, 0, len(devices))
+	for _, device := range devices {
+		if device.ID != id {
+			result = append(result, device)
 		}
 	}
+	return result
+}
 
-	return nil
+// copyDevice returns a copy of device that shares no memory with it
+func copyDevice(device *entities.Device) *entities.Device {
+	deviceCopy := *device
+	if device.OwnerID != nil {
+		ownerID := *device.OwnerID
+		deviceCopy.OwnerID = &ownerID
+	}
+	return &deviceCopy
 }
 
 // RegisterDeviceSecret registers a secret for a device's serial number
@@ -289,4 +316,65 @@ func (m *MemoryDeviceRepository) RemoveDeviceSecret(serialNumber string) error {
 
 	delete(m.secrets, serialNumber)
 	return nil
-}
\ No newline at end of file
+}
+
+// ImportDevices implements DeviceProvisioner interface
+// The batch is validated before anything is stored, so a failure registers no device
+func (m *MemoryDeviceRepository) ImportDevices(ctx context.Context, devices []repositories.DeviceCredentials) ([]string, error) {
+	seen := make(map[string]bool, len(devices))
+	for _, credentials := range devices {
+		if credentials.Device == nil {
+			return nil, errors.New("device cannot be nil")
+		}
+		if err := credentials.Device.Validate(); err != nil {
+			return nil, fmt.Errorf("device %q: %w", credentials.Device.SerialNumber, err)
+		}
+		if credentials.Secret == "" {
+			return nil, fmt.Errorf("device %q: secret cannot be empty", credentials.Device.SerialNumber)
+		}
+		if seen[credentials.Device.SerialNumber] {
+			return nil, fmt.Errorf("device %q: duplicate serial number", credentials.Device.SerialNumber)
+		}
+		seen[credentials.Device.SerialNumber] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing := []string{}
+	now := time.Now()
+	for _, credentials := range devices {
+		device := credentials.Device
+		if _, exists := m.serials[device.SerialNumber]; exists {
+			existing = append(existing, device.SerialNumber)
+			continue
+		}
+
+		if device.ID == "" {
+			device.ID = uuid.New().String()
+		}
+		device.CreatedAt = now
+		device.UpdatedAt = now
+
+		m.storeLocked(copyDevice(device))
+		m.secrets[device.SerialNumber] = credentials.Secret
+	}
+
+	return existing, nil
+}
+
+// ListDevices implements DeviceProvisioner interface
+func (m *MemoryDeviceRepository) ListDevices(ctx context.Context) ([]*entities.Device, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]*entities.Device, 0, len(m.devices))
+	for _, device := range m.devices {
+		result = append(result, copyDevice(device))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].SerialNumber < result[j].SerialNumber
+	})
+
+	return result, nil
+}
@@ -0,0 +1,181 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/errs"
+)
+
+func ownerID(id string) *string {
+	return &id
+}
+
+// updateDevice reads the device with get, changes it and stores it, reading
+// it again while another update got in between
+func updateDevice(ctx context.Context, repo *MemoryDeviceRepository, get func() (*entities.Device, error), change func(device *entities.Device)) error {
+	for {
+		device, err := get()
+		if err != nil {
+			return err
+		}
+		change(device)
+		if err := repo.Update(ctx, device); !errors.Is(err, errs.ErrDeviceModified) {
+			return err
+		}
+	}
+}
+
+func TestMemoryDeviceRepository_UpdateListsDeviceOnceByOwner(t *testing.T) {
+	repo := NewMemoryDeviceRepository()
+	ctx := context.Background()
+	device := &entities.Device{SerialNumber: "ARU-0001", Model: "doll-v1", OwnerID: ownerID("parent-1")}
+	if err := repo.Create(ctx, device); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+
+	// Updating without changing the owner keeps the device listed once
+	for i := 0; i < 2; i++ {
+		stored, _ := repo.GetByID(ctx, device.ID)
+		stored.FirmwareVersion = "2.0.3"
+		if err := repo.Update(ctx, stored); err != nil {
+			t.Fatalf("Failed to update device: %v", err)
+		}
+	}
+	if devices, _ := repo.GetByOwnerID(ctx, "parent-1"); len(devices) != 1 {
+		t.Fatalf("Expected the device listed once by its owner, got %d", len(devices))
+	}
+
+	// Changing the owner of a device read from the repository changes nothing
+	// stored until it is updated
+	stored, _ := repo.GetByID(ctx, device.ID)
+	*stored.OwnerID = "parent-2"
+	if devices, _ := repo.GetByOwnerID(ctx, "parent-1"); len(devices) != 1 || *devices[0].OwnerID != "parent-1" {
+		t.Fatalf("Expected the stored owner untouched, got %+v", devices)
+	}
+	if err := repo.Update(ctx, stored); err != nil {
+		t.Fatalf("Failed to update device: %v", err)
+	}
+	if devices, _ := repo.GetByOwnerID(ctx, "parent-1"); len(devices) != 0 {
+		t.Errorf("Expected the device no longer listed by its previous owner, got %d", len(devices))
+	}
+	if devices, _ := repo.GetByOwnerID(ctx, "parent-2"); len(devices) != 1 {
+		t.Errorf("Expected the device listed once by its new owner, got %d", len(devices))
+	}
+}
+
+// Run with -race: a device authenticating while its record is updated, as on
+// boot when it authenticates and connects at once
+func TestMemoryDeviceRepository_ConcurrentAuthAndUpdates(t *testing.T) {
+	repo := NewMemoryDeviceRepository()
+	ctx := context.Background()
+	device := &entities.Device{SerialNumber: "ARU-0001", Model: "doll-v1", OwnerID: ownerID("parent-1")}
+	if err := repo.Create(ctx, device); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	if err := repo.RegisterDeviceSecret(device.SerialNumber, "secret"); err != nil {
+		t.Fatalf("Failed to register secret: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				authenticated, err := repo.ValidateDevice(device.SerialNumber, "secret")
+				if err != nil {
+					t.Errorf("Failed to authenticate device: %v", err)
+					return
+				}
+				if authenticated.ID != device.ID || *authenticated.OwnerID == "" {
+					t.Errorf("Unexpected authenticated device: %+v", authenticated)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				err := updateDevice(ctx, repo, func() (*entities.Device, error) {
+					return repo.GetByID(ctx, device.ID)
+				}, func(stored *entities.Device) {
+					stored.FirmwareVersion = "2.0.3"
+				})
+				if err != nil {
+					t.Errorf("Failed to update device: %v", err)
+					return
+				}
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			owner := "parent-1"
+			if i%2 == 1 {
+				owner = "parent-2"
+			}
+			for j := 0; j < 50; j++ {
+				err := updateDevice(ctx, repo, func() (*entities.Device, error) {
+					return repo.GetBySerialNumber(ctx, device.SerialNumber)
+				}, func(stored *entities.Device) {
+					*stored.OwnerID = owner
+				})
+				if err != nil {
+					t.Errorf("Failed to update device: %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stored, err := repo.GetByID(ctx, device.ID)
+	if err != nil {
+		t.Fatalf("Failed to get device: %v", err)
+	}
+	for _, owner := range []string{"parent-1", "parent-2"} {
+		devices, _ := repo.GetByOwnerID(ctx, owner)
+		want := 0
+		if owner == *stored.OwnerID {
+			want = 1
+		}
+		if len(devices) != want {
+			t.Errorf("Expected %s to list %d devices, got %d", owner, want, len(devices))
+		}
+	}
+}
+
+func TestMemoryDeviceRepository_RejectsStaleUpdate(t *testing.T) {
+	repo := NewMemoryDeviceRepository()
+	ctx := context.Background()
+	device := &entities.Device{SerialNumber: "ARU-0001", Model: "doll-v1"}
+	if err := repo.Create(ctx, device); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+
+	first, _ := repo.GetByID(ctx, device.ID)
+	second, _ := repo.GetByID(ctx, device.ID)
+	first.FirmwareVersion = "2.0.3"
+	if err := repo.Update(ctx, first); err != nil {
+		t.Fatalf("Failed to update device: %v", err)
+	}
+
+	// Read before the firmware version was stored, so it would undo it
+	second.Online = true
+	if err := repo.Update(ctx, second); !errors.Is(err, errs.ErrDeviceModified) {
+		t.Fatalf("Expected ErrDeviceModified, got %v", err)
+	}
+	// The device updated first can be updated again
+	first.Online = true
+	if err := repo.Update(ctx, first); err != nil {
+		t.Fatalf("Failed to update device again: %v", err)
+	}
+
+	stored, _ := repo.GetByID(ctx, device.ID)
+	if stored.FirmwareVersion != "2.0.3" || !stored.Online {
+		t.Errorf("Expected both changes stored, got %+v", stored)
+	}
+}
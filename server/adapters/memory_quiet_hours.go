@@ -0,0 +1,61 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// MemoryQuietHoursRepository is an in-memory implementation of QuietHoursRepository
+type MemoryQuietHoursRepository struct {
+	mu        sync.RWMutex
+	schedules map[string]entities.QuietHours // child id -> schedule mapping
+}
+
+// NewMemoryQuietHoursRepository creates a new in-memory quiet hours repository
+func NewMemoryQuietHoursRepository() *MemoryQuietHoursRepository {
+	return &MemoryQuietHoursRepository{
+		schedules: make(map[string]entities.QuietHours),
+	}
+}
+
+// GetByChildID implements QuietHoursRepository interface
+func (m *MemoryQuietHoursRepository) GetByChildID(ctx context.Context, childID string) (*entities.QuietHours, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	schedule, exists := m.schedules[childID]
+	if !exists {
+		return nil, nil
+	}
+	return &schedule, nil
+}
+
+// Upsert implements QuietHoursRepository interface
+func (m *MemoryQuietHoursRepository) Upsert(ctx context.Context, quietHours *entities.QuietHours) error {
+	if quietHours == nil {
+		return errors.New("quiet hours cannot be nil")
+	}
+	if quietHours.ChildID == "" {
+		return errors.New("child ID cannot be empty")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	quietHours.UpdatedAt = time.Now()
+	m.schedules[quietHours.ChildID] = *quietHours
+	return nil
+}
+
+// DeleteByChildID implements QuietHoursRepository interface
+func (m *MemoryQuietHoursRepository) DeleteByChildID(ctx context.Context, childID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.schedules, childID)
+	return nil
+}
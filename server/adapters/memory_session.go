@@ -0,0 +1,353 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/errs"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// Ensure MemorySessionRepository implements the SessionRepository interface
+var _ repositories.SessionRepository = (*MemorySessionRepository)(nil)
+
+// Ensure MemorySessionRepository implements the ChildSessionFinder interface
+var _ repositories.ChildSessionFinder = (*MemorySessionRepository)(nil)
+
+// Ensure MemorySessionRepository implements the ChildSessionEraser interface
+var _ repositories.ChildSessionEraser = (*MemorySessionRepository)(nil)
+
+const defaultMaxMessagesPerDevice = 500
+
+// MemorySessionConfig holds configuration for the in-memory session repository
+// Optional fields with defaults:
+// - MaxMessagesPerDevice: Messages kept for each device before the oldest are evicted (default: 500)
+type MemorySessionConfig struct {
+	MaxMessagesPerDevice int // Optional: Messages kept for each device before the oldest are evicted
+}
+
+// NewMemorySessionConfigFromEnv creates a new MemorySessionConfig from environment variables
+// This is a helper function to simplify the creation of a properly configured MemorySessionConfig
+func NewMemorySessionConfigFromEnv() MemorySessionConfig {
+	config := MemorySessionConfig{}
+
+	if maxStr := os.Getenv("MEMORY_SESSION_MAX_MESSAGES_PER_DEVICE"); maxStr != "" {
+		if maxMessages, err := strconv.Atoi(maxStr); err == nil && maxMessages > 0 {
+			config.MaxMessagesPerDevice = maxMessages
+		}
+	}
+
+	return config
+}
+
+// MemorySessionRepository is an in-memory implementation of SessionRepository
+// for deployments without MongoDB. Each device keeps at most
+// MaxMessagesPerDevice messages: its oldest sessions are evicted first, then
+// the oldest messages of the session it is in.
+type MemorySessionRepository struct {
+	mu          sync.RWMutex
+	sessions    map[string]*entities.Session   // id -> session mapping
+	devices     map[string][]*entities.Session // device_id -> sessions mapping, oldest first
+	maxMessages int
+	logger      *zap.Logger
+}
+
+// NewMemorySessionRepository creates a new in-memory session repository
+func NewMemorySessionRepository(config MemorySessionConfig, logger *zap.Logger) *MemorySessionRepository {
+	// Apply defaults where needed
+	if config.MaxMessagesPerDevice == 0 {
+		config.MaxMessagesPerDevice = defaultMaxMessagesPerDevice
+		logger.Info("Using default max messages per device", zap.Int("maxMessagesPerDevice", config.MaxMessagesPerDevice))
+	}
+
+	return &MemorySessionRepository{
+		sessions:    make(map[string]*entities.Session),
+		devices:     make(map[string][]*entities.Session),
+		maxMessages: config.MaxMessagesPerDevice,
+		logger:      logger,
+	}
+}
+
+// Create implements SessionRepository interface
+func (m *MemorySessionRepository) Create(ctx context.Context, session *entities.Session) error {
+	if session == nil {
+		return errors.New("session cannot be nil")
+	}
+	if session.DeviceID == "" {
+		return errors.New("device ID cannot be empty")
+	}
+
+	// Set creation timestamps if not already set
+	now := time.Now()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = now
+	}
+	if session.LastMessageAt.IsZero() {
+		session.LastMessageAt = now
+	}
+	session.ID = uuid.New().String()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := copySession(session)
+	m.sessions[stored.ID] = stored
+	m.devices[stored.DeviceID] = append(m.devices[stored.DeviceID], stored)
+	m.evict(stored.DeviceID)
+	session.MarkStored()
+	return nil
+}
+
+// GetLastByDeviceID implements SessionRepository interface
+func (m *MemorySessionRepository) GetLastByDeviceID(ctx context.Context, deviceID string) (*entities.Session, error) {
+	if deviceID == "" {
+		return nil, errors.New("device ID cannot be empty")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var last *entities.Session
+	for _, session := range m.devices[deviceID] {
+		if last == nil || !session.LastMessageAt.Before(last.LastMessageAt) {
+			last = session
+		}
+	}
+	if last == nil {
+		return nil, nil // No session found, return nil without error
+	}
+	return copySession(last), nil
+}
+
+// GetLastByChildID implements ChildSessionFinder interface
+func (m *MemorySessionRepository) GetLastByChildID(ctx context.Context, childID string) (*entities.Session, error) {
+	if childID == "" {
+		return nil, errors.New("child ID cannot be empty")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var last *entities.Session
+	for _, session := range m.sessions {
+		if session.ChildID != childID {
+			continue
+		}
+		if last == nil || session.LastMessageAt.After(last.LastMessageAt) {
+			last = session
+		}
+	}
+	if last == nil {
+		return nil, nil // No session found, return nil without error
+	}
+	return copySession(last), nil
+}
+
+// Update implements SessionRepository interface. Like the MongoDB
+// repository, it appends the messages added since the session was read or
+// stored whose turn is not stored yet, so that a stale update never
+// overwrites the turns stored since.
+func (m *MemorySessionRepository) Update(ctx context.Context, session *entities.Session) error {
+	if session == nil {
+		return errors.New("session cannot be nil")
+	}
+	if session.ID == "" {
+		return errors.New("session ID cannot be empty")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, exists := m.sessions[session.ID]
+	if !exists {
+		return fmt.Errorf("session %q: %w", session.ID, errs.ErrSessionNotFound)
+	}
+	if stored.DeviceID != session.DeviceID {
+		return fmt.Errorf("session with ID %s belongs to another device", session.ID)
+	}
+
+	storedTurns := make(map[string]bool, len(stored.Messages))
+	for _, message := range stored.Messages {
+		if message.TurnID != "" {
+			storedTurns[message.TurnID] = true
+		}
+	}
+	messages := append([]entities.Message(nil), stored.Messages...)
+	for _, message := range entities.DedupeMessages(session.UnstoredMessages()) {
+		if message.TurnID != "" && storedTurns[message.TurnID] {
+			continue
+		}
+		messages = append(messages, message)
+	}
+
+	updated := *session
+	updated.Messages = messages
+	*stored = *copySession(&updated)
+	m.evict(stored.DeviceID)
+	session.MarkStored()
+	return nil
+}
+
+// DeleteByDeviceID implements SessionRepository interface
+func (m *MemorySessionRepository) DeleteByDeviceID(ctx context.Context, deviceID string) (int64, error) {
+	if deviceID == "" {
+		return 0, errors.New("device ID cannot be empty")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sessions := m.devices[deviceID]
+	for _, session := range sessions {
+		delete(m.sessions, session.ID)
+	}
+	delete(m.devices, deviceID)
+	return int64(len(sessions)), nil
+}
+
+// DeleteByChildID implements ChildSessionEraser interface
+func (m *MemorySessionRepository) DeleteByChildID(ctx context.Context, childID string) (int64, error) {
+	if childID == "" {
+		return 0, errors.New("child ID cannot be empty")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for deviceID, sessions := range m.devices {
+		var kept []*entities.Session
+		for _, session := range sessions {
+			if session.ChildID == childID {
+				delete(m.sessions, session.ID)
+				deleted++
+				continue
+			}
+			kept = append(kept, session)
+		}
+		if len(kept) == 0 {
+			delete(m.devices, deviceID)
+			continue
+		}
+		m.devices[deviceID] = kept
+	}
+	return deleted, nil
+}
+
+// Search implements SessionRepository interface
+func (m *MemorySessionRepository) Search(ctx context.Context, deviceID, query string, limit int) ([]entities.MessageMatch, error) {
+	if deviceID == "" {
+		return nil, errors.New("device ID cannot be empty")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	terms, err := entities.SearchTerms(query)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sessions := m.devices[deviceID]
+	var matches []entities.MessageMatch
+	for i := len(sessions) - 1; i >= 0 && len(matches) < limit; i-- {
+		matches = append(matches, sessions[i].SearchMessages(terms)...)
+	}
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// ListByDeviceID implements SessionRepository interface
+func (m *MemorySessionRepository) ListByDeviceID(ctx context.Context, deviceID string, limit int) ([]*entities.Session, error) {
+	if deviceID == "" {
+		return nil, errors.New("device ID cannot be empty")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sessions := make([]*entities.Session, 0, len(m.devices[deviceID]))
+	for _, session := range m.devices[deviceID] {
+		sessions = append(sessions, copySession(session))
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].LastMessageAt.After(sessions[j].LastMessageAt)
+	})
+	if len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+	return sessions, nil
+}
+
+// ExpireSessions implements SessionRepository interface
+func (m *MemorySessionRepository) ExpireSessions(ctx context.Context, cutoff time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired int64
+	now := time.Now()
+	for _, session := range m.sessions {
+		if session.Expire(cutoff, now) {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// evict drops the oldest messages of a device beyond the retention cap.
+// The caller must hold the write lock.
+func (m *MemorySessionRepository) evict(deviceID string) {
+	sessions := m.devices[deviceID]
+	total := 0
+	for _, session := range sessions {
+		total += len(session.Messages)
+	}
+
+	// Whole sessions go first, never the newest one
+	evicted := 0
+	for total > m.maxMessages && len(sessions) > evicted+1 {
+		oldest := sessions[evicted]
+		total -= len(oldest.Messages)
+		delete(m.sessions, oldest.ID)
+		evicted++
+		m.logger.Debug("Evicted session beyond retention cap",
+			zap.String("deviceID", deviceID),
+			zap.String("sessionID", oldest.ID))
+	}
+	if evicted > 0 {
+		sessions = append([]*entities.Session(nil), sessions[evicted:]...)
+		m.devices[deviceID] = sessions
+	}
+
+	if excess := total - m.maxMessages; excess > 0 {
+		current := sessions[0]
+		current.Messages = append([]entities.Message(nil), current.Messages[excess:]...)
+	}
+}
+
+// copySession copies session, keeping each turn once. The copy holds the
+// messages as stored.
+func copySession(session *entities.Session) *entities.Session {
+	sessionCopy := *session
+	sessionCopy.Messages = entities.DedupeMessages(session.Messages)
+	sessionCopy.MarkStored()
+	return &sessionCopy
+}
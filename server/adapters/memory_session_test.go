@@ -0,0 +1,255 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/errs"
+)
+
+func testMessages(contents ...string) []entities.Message {
+	var msgs []entities.Message
+	for _, content := range contents {
+		msgs = append(msgs, entities.Message{Timestamp: time.Now(), Role: entities.UserRole, Content: content})
+	}
+	return msgs
+}
+
+func messageContents(session *entities.Session) []string {
+	var result []string
+	for _, message := range session.Messages {
+		result = append(result, message.Content)
+	}
+	return result
+}
+
+func TestMemorySessionRepository_CreateGetUpdate(t *testing.T) {
+	repo := NewMemorySessionRepository(MemorySessionConfig{}, zaptest.NewLogger(t))
+	ctx := context.Background()
+
+	if last, err := repo.GetLastByDeviceID(ctx, "device-1"); err != nil || last != nil {
+		t.Fatalf("Expected no session before any is created, got %+v, %v", last, err)
+	}
+
+	older := &entities.Session{DeviceID: "device-1", LastMessageAt: time.Now().Add(-time.Hour)}
+	session := &entities.Session{DeviceID: "device-1"}
+	for _, s := range []*entities.Session{older, session, {DeviceID: "device-2"}} {
+		if err := repo.Create(ctx, s); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+	}
+	if session.ID == "" || session.ID == older.ID {
+		t.Fatalf("Expected a distinct ID to be assigned, got %q", session.ID)
+	}
+
+	// Changes are only stored on Update
+	session.Messages = testMessages("halo")
+	last, err := repo.GetLastByDeviceID(ctx, "device-1")
+	if err != nil || last.ID != session.ID || len(last.Messages) != 0 {
+		t.Fatalf("Expected the newest session without messages, got %+v, %v", last, err)
+	}
+
+	if err := repo.Update(ctx, session); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+	last, _ = repo.GetLastByDeviceID(ctx, "device-1")
+	if got := messageContents(last); len(got) != 1 || got[0] != "halo" {
+		t.Errorf("Expected the stored message, got %v", got)
+	}
+
+	if err := repo.Update(ctx, &entities.Session{ID: "missing", DeviceID: "device-1"}); !errors.Is(err, errs.ErrSessionNotFound) {
+		t.Errorf("Expected an unknown session to be rejected as not found, got %v", err)
+	}
+	if err := repo.Update(ctx, &entities.Session{ID: session.ID, DeviceID: "device-2"}); err == nil {
+		t.Error("Expected a session moved to another device to be rejected")
+	}
+
+	if deleted, _ := repo.DeleteByDeviceID(ctx, "device-1"); deleted != 2 {
+		t.Errorf("Expected 2 sessions deleted, got %d", deleted)
+	}
+	if last, _ := repo.GetLastByDeviceID(ctx, "device-2"); last == nil {
+		t.Error("Expected the sessions of another device to be kept")
+	}
+}
+
+func TestMemorySessionRepository_GetLastByChildID(t *testing.T) {
+	repo := NewMemorySessionRepository(MemorySessionConfig{}, zaptest.NewLogger(t))
+	ctx := context.Background()
+
+	older := &entities.Session{DeviceID: "device-1", ChildID: "child-1", LastMessageAt: time.Now().Add(-time.Hour)}
+	newer := &entities.Session{DeviceID: "device-2", ChildID: "child-1"}
+	for _, s := range []*entities.Session{older, newer, {DeviceID: "device-3", ChildID: "child-2"}, {DeviceID: "device-1"}} {
+		if err := repo.Create(ctx, s); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+	}
+
+	last, err := repo.GetLastByChildID(ctx, "child-1")
+	if err != nil || last == nil || last.ID != newer.ID {
+		t.Fatalf("Expected the child's newest session on any device, got %+v, %v", last, err)
+	}
+	if last, err := repo.GetLastByChildID(ctx, "child-3"); err != nil || last != nil {
+		t.Errorf("Expected no session for a child without one, got %+v, %v", last, err)
+	}
+}
+
+func TestMemorySessionRepository_DeleteByChildID(t *testing.T) {
+	repo := NewMemorySessionRepository(MemorySessionConfig{}, zaptest.NewLogger(t))
+	ctx := context.Background()
+
+	kept := &entities.Session{DeviceID: "device-1"}
+	for _, s := range []*entities.Session{{DeviceID: "device-1", ChildID: "child-1"}, {DeviceID: "device-2", ChildID: "child-1"}, kept} {
+		if err := repo.Create(ctx, s); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+	}
+
+	deleted, err := repo.DeleteByChildID(ctx, "child-1")
+	if err != nil || deleted != 2 {
+		t.Fatalf("Expected the child's 2 sessions to be deleted, got %d, %v", deleted, err)
+	}
+	if last, err := repo.GetLastByChildID(ctx, "child-1"); err != nil || last != nil {
+		t.Errorf("Expected no session left for the child, got %+v, %v", last, err)
+	}
+	if last, err := repo.GetLastByDeviceID(ctx, "device-1"); err != nil || last == nil || last.ID != kept.ID {
+		t.Errorf("Expected the session without the child to be kept, got %+v, %v", last, err)
+	}
+	if last, err := repo.GetLastByDeviceID(ctx, "device-2"); err != nil || last != nil {
+		t.Errorf("Expected no session left on device-2, got %+v, %v", last, err)
+	}
+}
+
+func TestMemorySessionRepository_StoresATurnOnce(t *testing.T) {
+	repo := NewMemorySessionRepository(MemorySessionConfig{}, zaptest.NewLogger(t))
+	ctx := context.Background()
+
+	session := &entities.Session{DeviceID: "device-1"}
+	if err := repo.Create(ctx, session); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	// A retried update appending the turn its first attempt already stored
+	turn := testMessages("halo")[0]
+	turn.TurnID = "conversation-1-1"
+	session.Messages = []entities.Message{turn, turn}
+	if err := repo.Update(ctx, session); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+
+	last, _ := repo.GetLastByDeviceID(ctx, "device-1")
+	if got := messageContents(last); len(got) != 1 || got[0] != "halo" {
+		t.Errorf("Expected the turn stored once, got %v", got)
+	}
+}
+
+// A stale copy of the session appends its own turn without dropping the
+// turns another update stored since it was read
+func TestMemorySessionRepository_StaleUpdateKeepsNewerTurns(t *testing.T) {
+	repo := NewMemorySessionRepository(MemorySessionConfig{}, zaptest.NewLogger(t))
+	ctx := context.Background()
+
+	session := &entities.Session{DeviceID: "device-1"}
+	if err := repo.Create(ctx, session); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	stale, _ := repo.GetLastByDeviceID(ctx, "device-1")
+
+	newer := testMessages("halo")[0]
+	newer.TurnID = "conversation-1-1"
+	session.Messages = append(session.Messages, newer)
+	if err := repo.Update(ctx, session); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+
+	retried := testMessages("apa kabar?")[0]
+	retried.TurnID = "conversation-2-1"
+	stale.Messages = append(stale.Messages, retried)
+	if err := repo.Update(ctx, stale); err != nil {
+		t.Fatalf("Failed to update stale session: %v", err)
+	}
+
+	last, _ := repo.GetLastByDeviceID(ctx, "device-1")
+	if got := messageContents(last); len(got) != 2 || got[0] != "halo" || got[1] != "apa kabar?" {
+		t.Errorf("Expected both turns stored, got %v", got)
+	}
+}
+
+func TestMemorySessionRepository_EvictsBeyondRetentionCap(t *testing.T) {
+	repo := NewMemorySessionRepository(MemorySessionConfig{MaxMessagesPerDevice: 4}, zaptest.NewLogger(t))
+	ctx := context.Background()
+
+	first := &entities.Session{DeviceID: "device-1", Messages: testMessages("a", "b")}
+	second := &entities.Session{DeviceID: "device-1", Messages: testMessages("c", "d")}
+	other := &entities.Session{DeviceID: "device-2", Messages: testMessages("x", "y", "z")}
+	for _, s := range []*entities.Session{first, second, other} {
+		if err := repo.Create(ctx, s); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+	}
+
+	// Exceeding the cap evicts the oldest session as a whole
+	second.Messages = append(second.Messages, testMessages("e")...)
+	if err := repo.Update(ctx, second); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+	if matches, _ := repo.Search(ctx, "device-1", "a", 10); len(matches) != 0 {
+		t.Errorf("Expected the oldest session to be evicted, got %v", matches)
+	}
+	if err := repo.Update(ctx, first); err == nil {
+		t.Error("Expected the evicted session to be gone")
+	}
+
+	// With a single session left its oldest messages go
+	second.Messages = append(second.Messages, testMessages("f", "g")...)
+	if err := repo.Update(ctx, second); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+	last, _ := repo.GetLastByDeviceID(ctx, "device-1")
+	want := []string{"d", "e", "f", "g"}
+	if got := messageContents(last); len(got) != len(want) || got[0] != want[0] || got[3] != want[3] {
+		t.Errorf("Expected messages %v, got %v", want, got)
+	}
+
+	if last, _ := repo.GetLastByDeviceID(ctx, "device-2"); len(last.Messages) != 3 {
+		t.Errorf("Expected other devices to keep their messages, got %v", messageContents(last))
+	}
+}
+
+func TestMemorySessionRepository_ConcurrentUse(t *testing.T) {
+	repo := NewMemorySessionRepository(MemorySessionConfig{MaxMessagesPerDevice: 10}, zaptest.NewLogger(t))
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session := &entities.Session{DeviceID: "device-1"}
+			if err := repo.Create(ctx, session); err != nil {
+				t.Errorf("Failed to create session: %v", err)
+				return
+			}
+			for j := 0; j < 5; j++ {
+				session.Messages = append(session.Messages, testMessages("halo")...)
+				repo.Update(ctx, session)
+				repo.GetLastByDeviceID(ctx, "device-1")
+				repo.Search(ctx, "device-1", "halo", 5)
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for _, session := range repo.devices["device-1"] {
+		total += len(session.Messages)
+	}
+	if total > 10 {
+		t.Errorf("Expected at most 10 messages kept, got %d", total)
+	}
+}
@@ -0,0 +1,190 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/errs"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+type ChildRepository struct {
+	collection *mongo.Collection
+}
+
+// NewChildRepository creates a new MongoDB child repository
+func NewChildRepository(db *mongo.Database) repositories.ChildRepository {
+	return &ChildRepository{
+		collection: db.Collection("children"),
+	}
+}
+
+// EnsureChildIndexes creates the indexes the child repository relies on to
+// list the children of a parent and find the child a device is bound to
+func EnsureChildIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("children").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "owner_id", Value: 1}},
+			Options: options.Index().SetName("owner_id"),
+		},
+		{
+			Keys:    bson.D{{Key: "device_ids", Value: 1}},
+			Options: options.Index().SetName("device_ids"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create child indexes: %w", err)
+	}
+	return nil
+}
+
+// Create implements repositories.ChildRepository
+func (r *ChildRepository) Create(ctx context.Context, child *entities.Child) error {
+	if child == nil {
+		return errors.New("child cannot be nil")
+	}
+	if err := child.Validate(); err != nil {
+		return err
+	}
+
+	// Generate ID if not provided
+	if child.ID == "" {
+		child.ID = uuid.New().String()
+	}
+
+	now := time.Now()
+	child.CreatedAt = now
+	child.UpdatedAt = now
+
+	if _, err := r.collection.InsertOne(ctx, child); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("child %q: %w", child.ID, errs.ErrChildExists)
+		}
+		return fmt.Errorf("failed to create child: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID implements repositories.ChildRepository
+func (r *ChildRepository) GetByID(ctx context.Context, id string) (*entities.Child, error) {
+	if id == "" {
+		return nil, errors.New("child ID cannot be empty")
+	}
+
+	var child entities.Child
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&child)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("child %q: %w", id, errs.ErrChildNotFound)
+		}
+		return nil, fmt.Errorf("failed to get child %s: %w", id, err)
+	}
+
+	return &child, nil
+}
+
+// GetByOwnerID implements repositories.ChildRepository
+func (r *ChildRepository) GetByOwnerID(ctx context.Context, ownerID string) ([]*entities.Child, error) {
+	if ownerID == "" {
+		return nil, errors.New("owner ID cannot be empty")
+	}
+
+	opts := options.Find().SetSort(bson.M{"created_at": 1})
+	cursor, err := r.collection.Find(ctx, bson.M{"owner_id": ownerID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get children of owner %s: %w", ownerID, err)
+	}
+	defer cursor.Close(ctx)
+
+	children := []*entities.Child{}
+	for cursor.Next(ctx) {
+		var child entities.Child
+		if err := cursor.Decode(&child); err != nil {
+			return nil, fmt.Errorf("failed to decode child: %w", err)
+		}
+		children = append(children, &child)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get children of owner %s: %w", ownerID, err)
+	}
+
+	return children, nil
+}
+
+// GetByDeviceID implements repositories.ChildRepository
+func (r *ChildRepository) GetByDeviceID(ctx context.Context, deviceID string) (*entities.Child, error) {
+	if deviceID == "" {
+		return nil, errors.New("device ID cannot be empty")
+	}
+
+	var child entities.Child
+	err := r.collection.FindOne(ctx, bson.M{"device_ids": deviceID}).Decode(&child)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("device %q: %w", deviceID, errs.ErrChildNotFound)
+		}
+		return nil, fmt.Errorf("failed to get child of device %s: %w", deviceID, err)
+	}
+
+	return &child, nil
+}
+
+// Update implements repositories.ChildRepository. The creation time stored
+// with the profile is kept.
+func (r *ChildRepository) Update(ctx context.Context, child *entities.Child) error {
+	if child == nil {
+		return errors.New("child cannot be nil")
+	}
+	if child.ID == "" {
+		return errors.New("child ID cannot be empty")
+	}
+	if err := child.Validate(); err != nil {
+		return err
+	}
+
+	if child.CreatedAt.IsZero() {
+		stored, err := r.GetByID(ctx, child.ID)
+		if err != nil {
+			return err
+		}
+		child.CreatedAt = stored.CreatedAt
+	}
+	child.UpdatedAt = time.Now()
+
+	// Replaced whole so that cleared optional fields are removed
+	result, err := r.collection.ReplaceOne(ctx, bson.M{"_id": child.ID}, child)
+	if err != nil {
+		return fmt.Errorf("failed to update child: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("child %q: %w", child.ID, errs.ErrChildNotFound)
+	}
+
+	return nil
+}
+
+// Delete implements repositories.ChildRepository
+func (r *ChildRepository) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("child ID cannot be empty")
+	}
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("failed to delete child %s: %w", id, err)
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("child %q: %w", id, errs.ErrChildNotFound)
+	}
+
+	return nil
+}
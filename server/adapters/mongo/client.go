@@ -67,6 +67,14 @@ func NewClient(logger *zap.Logger) (*Client, error) {
 	}, nil
 }
 
+// CheckHealth implements repositories.HealthChecker by pinging the database
+func (c *Client) CheckHealth(ctx context.Context) error {
+	if err := c.Client.Ping(ctx, nil); err != nil {
+		return fmt.Errorf("failed to ping MongoDB: %w", err)
+	}
+	return nil
+}
+
 // Close closes the MongoDB connection
 func (c *Client) Close(ctx context.Context) error {
 	if err := c.Client.Disconnect(ctx); err != nil {
@@ -0,0 +1,28 @@
+package mongo
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// sessionObjectID converts a session ID into the ObjectID stored as _id.
+// Malformed IDs fail with an error wrapping repositories.ErrInvalidSessionID.
+func sessionObjectID(id string) (primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("%w: %q is not a 24 character hex ObjectID", repositories.ErrInvalidSessionID, id)
+	}
+	return objectID, nil
+}
+
+// sessionIDFromInserted converts the _id generated on insert into a session ID
+func sessionIDFromInserted(insertedID interface{}) (string, error) {
+	objectID, ok := insertedID.(primitive.ObjectID)
+	if !ok {
+		return "", fmt.Errorf("%w: inserted ID has type %T, expected an ObjectID", repositories.ErrInvalidSessionID, insertedID)
+	}
+	return objectID.Hex(), nil
+}
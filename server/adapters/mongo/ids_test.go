@@ -0,0 +1,112 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+func TestSessionObjectID(t *testing.T) {
+	objectID := primitive.NewObjectID()
+
+	got, err := sessionObjectID(objectID.Hex())
+	if err != nil || got != objectID {
+		t.Errorf("Expected %s, got %s (%v)", objectID.Hex(), got.Hex(), err)
+	}
+
+	for _, id := range []string{"", "session-1", "zzzzzzzzzzzzzzzzzzzzzzzz", objectID.Hex() + "00"} {
+		if _, err := sessionObjectID(id); !errors.Is(err, repositories.ErrInvalidSessionID) {
+			t.Errorf("Expected ErrInvalidSessionID for %q, got %v", id, err)
+		}
+	}
+}
+
+func TestSessionIDFromInserted(t *testing.T) {
+	objectID := primitive.NewObjectID()
+	if id, err := sessionIDFromInserted(objectID); err != nil || id != objectID.Hex() {
+		t.Errorf("Expected %s, got %q (%v)", objectID.Hex(), id, err)
+	}
+
+	if _, err := sessionIDFromInserted("not-an-object-id"); !errors.Is(err, repositories.ErrInvalidSessionID) {
+		t.Errorf("Expected ErrInvalidSessionID, got %v", err)
+	}
+}
+
+func TestSessionRepository_UpdateRejectsMalformedID(t *testing.T) {
+	// The ID is validated before the collection is touched
+	repo := &SessionRepository{}
+
+	err := repo.Update(context.Background(), &entities.Session{ID: "session-1"})
+	if !errors.Is(err, repositories.ErrInvalidSessionID) {
+		t.Errorf("Expected ErrInvalidSessionID, got %v", err)
+	}
+}
+
+func TestSessionUpdate_PushesOnlyUnstoredTurnsAtOnce(t *testing.T) {
+	objectID := primitive.NewObjectID()
+	session := &entities.Session{
+		ID:      objectID.Hex(),
+		ChildID: "child-1",
+		Messages: []entities.Message{
+			{TurnID: "conv-1", Content: "halo"},
+			{TurnID: "conv-2", Content: "Halo juga!"},
+		},
+	}
+	session.MarkStored()
+	session.Messages = append(session.Messages,
+		entities.Message{TurnID: "conv-3", Content: "apa kabar?"},
+		entities.Message{TurnID: "conv-3", Content: "apa kabar?"},
+		entities.Message{TurnID: "conv-4", Content: "Baik!"})
+
+	filter, update := sessionUpdate(objectID, session)
+
+	if filter["_id"] != objectID || !reflect.DeepEqual(filter["messages.turn_id"], bson.M{"$nin": []string{"conv-3", "conv-4"}}) {
+		t.Errorf("Expected the push guarded by the new turns, got %v", filter)
+	}
+	fields := update["$set"].(bson.M)
+	if _, ok := fields["messages"]; ok {
+		t.Error("Expected the stored messages never to be replaced")
+	}
+	if fields["child_id"] != "child-1" {
+		t.Errorf("Expected child_id to be set, got %v", fields["child_id"])
+	}
+	pushed := update["$push"].(bson.M)["messages"].(bson.M)["$each"].([]entities.Message)
+	if len(pushed) != 2 || pushed[0].TurnID != "conv-3" || pushed[1].TurnID != "conv-4" {
+		t.Errorf("Expected only the new turns pushed, got %+v", pushed)
+	}
+
+	// Once stored, an update sends no message
+	session.MarkStored()
+	filter, update = sessionUpdate(objectID, session)
+	if _, ok := update["$push"]; ok || len(filter) != 1 {
+		t.Errorf("Expected no push for a stored session, got %v, %v", filter, update)
+	}
+}
+
+func TestSessionTurnUpdates_GuardsEachUnstoredTurn(t *testing.T) {
+	objectID := primitive.NewObjectID()
+	session := &entities.Session{
+		ID:             objectID.Hex(),
+		Messages:       []entities.Message{{TurnID: "conv-1"}, {TurnID: "conv-2"}, {TurnID: "conv-3"}},
+		StoredMessages: 1,
+	}
+
+	updates := sessionTurnUpdates(objectID, session)
+	if len(updates) != 3 {
+		t.Fatalf("Expected the fields and one push per unstored turn, got %d writes", len(updates))
+	}
+	for i, turnID := range []string{"conv-2", "conv-3"} {
+		push := updates[i+1].(*mongo.UpdateOneModel)
+		if filter := push.Filter.(bson.M); filter["messages.turn_id"].(bson.M)["$ne"] != turnID {
+			t.Errorf("Expected the push of %s guarded by its turn, got %v", turnID, filter)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+type QuietHoursRepository struct {
+	collection *mongo.Collection
+}
+
+// NewQuietHoursRepository creates a new MongoDB quiet hours repository
+func NewQuietHoursRepository(db *mongo.Database) repositories.QuietHoursRepository {
+	return &QuietHoursRepository{
+		collection: db.Collection("quiet_hours"),
+	}
+}
+
+// GetByChildID implements repositories.QuietHoursRepository
+func (r *QuietHoursRepository) GetByChildID(ctx context.Context, childID string) (*entities.QuietHours, error) {
+	if childID == "" {
+		return nil, errors.New("child ID cannot be empty")
+	}
+
+	var quietHours entities.QuietHours
+	err := r.collection.FindOne(ctx, bson.M{"_id": childID}).Decode(&quietHours)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil // No schedule set, return nil without error
+		}
+		return nil, fmt.Errorf("failed to get quiet hours for child %s: %w", childID, err)
+	}
+
+	return &quietHours, nil
+}
+
+// Upsert implements repositories.QuietHoursRepository
+func (r *QuietHoursRepository) Upsert(ctx context.Context, quietHours *entities.QuietHours) error {
+	if quietHours == nil {
+		return errors.New("quiet hours cannot be nil")
+	}
+	if quietHours.ChildID == "" {
+		return errors.New("child ID cannot be empty")
+	}
+
+	quietHours.UpdatedAt = time.Now()
+
+	_, err := r.collection.ReplaceOne(
+		ctx,
+		bson.M{"_id": quietHours.ChildID},
+		quietHours,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save quiet hours: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteByChildID implements repositories.QuietHoursRepository
+func (r *QuietHoursRepository) DeleteByChildID(ctx context.Context, childID string) error {
+	if childID == "" {
+		return errors.New("child ID cannot be empty")
+	}
+
+	if _, err := r.collection.DeleteOne(ctx, bson.M{"_id": childID}); err != nil {
+		return fmt.Errorf("failed to delete quiet hours for child %s: %w", childID, err)
+	}
+
+	return nil
+}
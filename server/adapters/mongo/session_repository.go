@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,6 +13,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/errs"
 	"github.com/satriahrh/arunika/server/domain/repositories"
 )
 
@@ -26,6 +28,30 @@ func NewSessionRepository(db *mongo.Database) repositories.SessionRepository {
 	}
 }
 
+// EnsureSessionIndexes creates the indexes the session repository relies on.
+// The text index powers transcript search; it does not stem words because
+// most conversations are in Bahasa Indonesia, which MongoDB cannot stem.
+func EnsureSessionIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("sessions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "messages.content", Value: "text"}},
+		Options: options.Index().
+			SetName("messages_content_text").
+			SetDefaultLanguage("none"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create session text index: %w", err)
+	}
+
+	_, err = db.Collection("sessions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "child_id", Value: 1}, {Key: "last_message_at", Value: -1}},
+		Options: options.Index().SetName("child_id_last_message_at").SetSparse(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create session child index: %w", err)
+	}
+	return nil
+}
+
 // Create implements repositories.SessionRepository
 func (r *SessionRepository) Create(ctx context.Context, session *entities.Session) error {
 	if session == nil {
@@ -46,9 +72,12 @@ func (r *SessionRepository) Create(ctx context.Context, session *entities.Sessio
 		"device_id":       session.DeviceID,
 		"created_at":      session.CreatedAt,
 		"last_message_at": session.LastMessageAt,
-		"messages":        session.Messages,
+		"messages":        entities.DedupeMessages(session.Messages),
 		"metadata":        session.Metadata,
 	}
+	if session.ChildID != "" {
+		doc["child_id"] = session.ChildID
+	}
 
 	// Insert the document
 	result, err := r.collection.InsertOne(ctx, doc)
@@ -57,10 +86,12 @@ func (r *SessionRepository) Create(ctx context.Context, session *entities.Sessio
 	}
 
 	// Set the generated ID back to the session
-	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
-		session.ID = oid.Hex()
+	session.ID, err = sessionIDFromInserted(result.InsertedID)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
 	}
 
+	session.MarkStored()
 	return nil
 }
 
@@ -83,10 +114,37 @@ func (r *SessionRepository) GetLastByDeviceID(ctx context.Context, deviceID stri
 		return nil, fmt.Errorf("failed to get last session for device %s: %w", deviceID, err)
 	}
 
+	session.MarkStored()
 	return &session, nil
 }
 
-// Update implements repositories.SessionRepository
+// GetLastByChildID implements repositories.ChildSessionFinder
+func (r *SessionRepository) GetLastByChildID(ctx context.Context, childID string) (*entities.Session, error) {
+	if childID == "" {
+		return nil, errors.New("child ID cannot be empty")
+	}
+
+	// Find the most recent session of the child on any of their devices
+	filter := bson.M{"child_id": childID}
+	opts := options.FindOne().SetSort(bson.M{"last_message_at": -1})
+
+	var session entities.Session
+	err := r.collection.FindOne(ctx, filter, opts).Decode(&session)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil // No session found, return nil without error
+		}
+		return nil, fmt.Errorf("failed to get last session for child %s: %w", childID, err)
+	}
+
+	session.MarkStored()
+	return &session, nil
+}
+
+// Update implements repositories.SessionRepository. Only the messages added
+// since the session was read or stored are sent, appended in a single push
+// unless the storage already holds one of their turns. A retried or stale
+// update thus never stores a turn twice nor overwrites the turns stored since.
 func (r *SessionRepository) Update(ctx context.Context, session *entities.Session) error {
 	if session == nil {
 		return errors.New("session cannot be nil")
@@ -95,36 +153,221 @@ func (r *SessionRepository) Update(ctx context.Context, session *entities.Sessio
 		return errors.New("session ID cannot be empty")
 	}
 
-	// Convert string ID to ObjectID
-	objectID, err := primitive.ObjectIDFromHex(session.ID)
+	objectID, err := sessionObjectID(session.ID)
 	if err != nil {
-		return fmt.Errorf("invalid session ID format: %w", err)
-	}
-
-	// Prepare update document
-	update := bson.M{
-		"$set": bson.M{
-			"device_id":       session.DeviceID,
-			"last_message_at": session.LastMessageAt,
-			"messages":        session.Messages,
-			"metadata":        session.Metadata,
-		},
+		return err
 	}
 
-	// Update the document
-	result, err := r.collection.UpdateOne(
-		ctx,
-		bson.M{"_id": objectID},
-		update,
-	)
+	filter, update := sessionUpdate(objectID, session)
+	result, err := r.collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return fmt.Errorf("failed to update session: %w", err)
 	}
 
+	// No match means the session is gone or some of the turns are stored
+	// already. Pushing the turns one by one tells them apart.
+	if result.MatchedCount == 0 && len(filter) > 1 {
+		bulk, err := r.collection.BulkWrite(ctx, sessionTurnUpdates(objectID, session), options.BulkWrite().SetOrdered(true))
+		if err != nil {
+			return fmt.Errorf("failed to update session: %w", err)
+		}
+		result.MatchedCount = bulk.MatchedCount
+	}
+
 	// Check if the document was found and updated
 	if result.MatchedCount == 0 {
-		return fmt.Errorf("session with ID %s not found", session.ID)
+		return fmt.Errorf("session %q: %w", session.ID, errs.ErrSessionNotFound)
 	}
 
+	session.MarkStored()
 	return nil
 }
+
+// sessionFields returns the fields of session set by every update
+func sessionFields(session *entities.Session) bson.M {
+	set := bson.M{
+		"device_id":       session.DeviceID,
+		"last_message_at": session.LastMessageAt,
+		"metadata":        session.Metadata,
+	}
+	// Active sessions must keep ended_at unset for ExpireSessions
+	if !session.EndedAt.IsZero() {
+		set["ended_at"] = session.EndedAt
+	}
+	if session.ParentSummary != nil {
+		set["parent_summary"] = session.ParentSummary
+	}
+	if session.ContextReset != nil {
+		set["context_reset"] = session.ContextReset
+	}
+	// A session created before its device was bound is found by child once
+	// it is next updated
+	if session.ChildID != "" {
+		set["child_id"] = session.ChildID
+	}
+	return set
+}
+
+// sessionUpdate returns the update storing the fields of session and
+// pushing its unstored messages at once, matching only while none of their
+// turns is stored
+func sessionUpdate(objectID primitive.ObjectID, session *entities.Session) (bson.M, bson.M) {
+	filter := bson.M{"_id": objectID}
+	update := bson.M{"$set": sessionFields(session)}
+
+	unstored := entities.DedupeMessages(session.UnstoredMessages())
+	if len(unstored) == 0 {
+		return filter, update
+	}
+	var turnIDs []string
+	for _, message := range unstored {
+		if message.TurnID != "" {
+			turnIDs = append(turnIDs, message.TurnID)
+		}
+	}
+	if len(turnIDs) > 0 {
+		filter["messages.turn_id"] = bson.M{"$nin": turnIDs}
+	}
+	update["$push"] = bson.M{"messages": bson.M{"$each": unstored}}
+	return filter, update
+}
+
+// sessionTurnUpdates returns the writes storing the fields of session, then
+// pushing each of its unstored messages whose turn is not stored yet
+func sessionTurnUpdates(objectID primitive.ObjectID, session *entities.Session) []mongo.WriteModel {
+	updates := []mongo.WriteModel{
+		mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": objectID}).
+			SetUpdate(bson.M{"$set": sessionFields(session)}),
+	}
+	for _, message := range entities.DedupeMessages(session.UnstoredMessages()) {
+		filter := bson.M{"_id": objectID}
+		if message.TurnID != "" {
+			filter["messages.turn_id"] = bson.M{"$ne": message.TurnID}
+		}
+		updates = append(updates, mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(bson.M{"$push": bson.M{"messages": message}}))
+	}
+	return updates
+}
+
+// DeleteByDeviceID implements repositories.SessionRepository
+func (r *SessionRepository) DeleteByDeviceID(ctx context.Context, deviceID string) (int64, error) {
+	if deviceID == "" {
+		return 0, errors.New("device ID cannot be empty")
+	}
+
+	result, err := r.collection.DeleteMany(ctx, bson.M{"device_id": deviceID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sessions for device %s: %w", deviceID, err)
+	}
+
+	return result.DeletedCount, nil
+}
+
+// DeleteByChildID implements repositories.ChildSessionEraser
+func (r *SessionRepository) DeleteByChildID(ctx context.Context, childID string) (int64, error) {
+	if childID == "" {
+		return 0, errors.New("child ID cannot be empty")
+	}
+
+	result, err := r.collection.DeleteMany(ctx, bson.M{"child_id": childID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sessions for child %s: %w", childID, err)
+	}
+
+	return result.DeletedCount, nil
+}
+
+// Search implements repositories.SessionRepository
+func (r *SessionRepository) Search(ctx context.Context, deviceID, query string, limit int) ([]entities.MessageMatch, error) {
+	if deviceID == "" {
+		return nil, errors.New("device ID cannot be empty")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	// Only sanitized words reach $search, never operators from the query
+	terms, err := entities.SearchTerms(query)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{
+		"device_id": deviceID,
+		"$text":     bson.M{"$search": strings.Join(terms, " ")},
+	}
+	opts := options.Find().
+		SetSort(bson.M{"last_message_at": -1}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search sessions for device %s: %w", deviceID, err)
+	}
+	defer cursor.Close(ctx)
+
+	var matches []entities.MessageMatch
+	for cursor.Next(ctx) && len(matches) < limit {
+		var session entities.Session
+		if err := cursor.Decode(&session); err != nil {
+			return nil, fmt.Errorf("failed to decode session: %w", err)
+		}
+		matches = append(matches, session.SearchMessages(terms)...)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search sessions for device %s: %w", deviceID, err)
+	}
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// ListByDeviceID implements repositories.SessionRepository
+func (r *SessionRepository) ListByDeviceID(ctx context.Context, deviceID string, limit int) ([]*entities.Session, error) {
+	if deviceID == "" {
+		return nil, errors.New("device ID cannot be empty")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	opts := options.Find().
+		SetSort(bson.M{"last_message_at": -1}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, bson.M{"device_id": deviceID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions for device %s: %w", deviceID, err)
+	}
+	defer cursor.Close(ctx)
+
+	var sessions []*entities.Session
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to list sessions for device %s: %w", deviceID, err)
+	}
+	for _, session := range sessions {
+		session.MarkStored()
+	}
+	return sessions, nil
+}
+
+// ExpireSessions implements repositories.SessionRepository
+func (r *SessionRepository) ExpireSessions(ctx context.Context, cutoff time.Time) (int64, error) {
+	filter := bson.M{
+		"ended_at":        bson.M{"$exists": false},
+		"last_message_at": bson.M{"$lt": cutoff},
+	}
+	update := bson.M{"$set": bson.M{"ended_at": time.Now()}}
+
+	result, err := r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire sessions: %w", err)
+	}
+
+	return result.ModifiedCount, nil
+}
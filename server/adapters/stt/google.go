@@ -4,34 +4,117 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	speech "cloud.google.com/go/speech/apiv1"
 	"cloud.google.com/go/speech/apiv1/speechpb"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/errs"
 	"github.com/satriahrh/arunika/server/domain/repositories"
 )
 
+const defaultFinalizationTimeout = 10 * time.Second
+
+// GoogleSpeechToTextConfig holds configuration for Google Cloud Speech-to-Text
+// Optional fields with defaults:
+// - FinalizationTimeout: How long End waits for the final transcription once the audio ended (default: 10s)
+type GoogleSpeechToTextConfig struct {
+	FinalizationTimeout time.Duration // Optional: How long End waits for the final transcription once the audio ended
+}
+
+// NewGoogleSpeechToTextConfigFromEnv creates a new GoogleSpeechToTextConfig from environment variables
+// This is a helper function to simplify the creation of a properly configured GoogleSpeechToTextConfig
+func NewGoogleSpeechToTextConfigFromEnv() GoogleSpeechToTextConfig {
+	config := GoogleSpeechToTextConfig{}
+
+	if timeoutStr := os.Getenv("GOOGLE_STT_FINALIZATION_TIMEOUT_MS"); timeoutStr != "" {
+		if timeout, err := strconv.Atoi(timeoutStr); err == nil && timeout > 0 {
+			config.FinalizationTimeout = time.Duration(timeout) * time.Millisecond
+		}
+	}
+
+	return config
+}
+
 // GoogleSpeechToText implements SpeechToText for Google Cloud
-type GoogleSpeechToText struct{}
+type GoogleSpeechToText struct {
+	finalizationTimeout time.Duration
 
-func (g *GoogleSpeechToText) InitTranscribeStreaming(ctx context.Context, config repositories.AudioConfig) (repositories.SpeechToTextStreaming, error) {
+	// open starts a recognition stream living as long as ctx
+	open func(ctx context.Context) (speechpb.Speech_StreamingRecognizeClient, io.Closer, error)
+}
+
+// NewGoogleSpeechToText creates a GoogleSpeechToText with defaults applied to config
+func NewGoogleSpeechToText(config GoogleSpeechToTextConfig, logger *zap.Logger) *GoogleSpeechToText {
+	// Apply defaults where needed
+	if config.FinalizationTimeout == 0 {
+		config.FinalizationTimeout = defaultFinalizationTimeout
+		logger.Info("Using default finalization timeout", zap.Duration("finalizationTimeout", config.FinalizationTimeout))
+	}
+
+	return &GoogleSpeechToText{
+		finalizationTimeout: config.FinalizationTimeout,
+		open:                openGoogleStream,
+	}
+}
+
+// openGoogleStream starts a recognition stream with a new client
+func openGoogleStream(ctx context.Context) (speechpb.Speech_StreamingRecognizeClient, io.Closer, error) {
 	// Create Google Cloud Speech client
 	client, err := speech.NewClient(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create speech client: %w", err)
+		return nil, nil, fmt.Errorf("failed to create speech client: %w", err)
 	}
 
 	// Create streaming recognize request
 	stream, err := client.StreamingRecognize(ctx)
 	if err != nil {
 		client.Close()
+		return nil, nil, fmt.Errorf("failed to create streaming recognize: %w", err)
+	}
+
+	return stream, client, nil
+}
+
+// InitTranscribeStreaming implements repositories.SpeechToText. ctx only
+// bounds setting the stream up: the stream itself lives until End returns,
+// however short ctx is, so that it lasts for the whole listening session.
+func (g *GoogleSpeechToText) InitTranscribeStreaming(ctx context.Context, config repositories.AudioConfig) (repositories.SpeechToTextStreaming, error) {
+	if err := ctx.Err(); err != nil {
 		return nil, fmt.Errorf("failed to create streaming recognize: %w", err)
 	}
 
+	open := g.open
+	if open == nil {
+		open = openGoogleStream
+	}
+	finalizationTimeout := g.finalizationTimeout
+	if finalizationTimeout == 0 {
+		finalizationTimeout = defaultFinalizationTimeout
+	}
+
+	streamCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	// Abandon the setup when ctx ends first
+	stopSetup := context.AfterFunc(ctx, cancel)
+	defer stopSetup()
+
+	stream, client, err := open(streamCtx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
 	// Convert encoding string to Google Speech API enum
 	encoding, err := getAudioEncoding(config.Encoding)
 	if err != nil {
 		stream.CloseSend()
 		client.Close()
+		cancel()
 		return nil, fmt.Errorf("unsupported audio encoding: %s", config.Encoding)
 	}
 
@@ -54,37 +137,55 @@ func (g *GoogleSpeechToText) InitTranscribeStreaming(ctx context.Context, config
 	}); err != nil {
 		stream.CloseSend()
 		client.Close()
+		cancel()
 		return nil, fmt.Errorf("failed to send streaming config: %w", err)
 	}
 
+	if !stopSetup() {
+		// ctx ended during the setup
+		stream.CloseSend()
+		client.Close()
+		return nil, fmt.Errorf("failed to create streaming recognize: %w", ctx.Err())
+	}
+
 	// Create the streaming instance
 	streamInstance := &GoogleSpeechToTextStream{
-		client:         client,
-		stream:         stream,
-		ctx:            ctx,
-		audioReceived:  false,
-		resultChan:     make(chan string, 1),
-		errorChan:      make(chan error, 1),
-		receiverActive: false,
+		client:              client,
+		stream:              stream,
+		ctx:                 streamCtx,
+		cancel:              cancel,
+		finalizationTimeout: finalizationTimeout,
+		audioReceived:       false,
+		resultChan:          make(chan string, 1),
+		errorChan:           make(chan error, 1),
+		receiverDone:        make(chan struct{}),
 	}
 
 	return streamInstance, nil
 }
 
 type GoogleSpeechToTextStream struct {
-	client         *speech.Client
-	stream         speechpb.Speech_StreamingRecognizeClient
-	ctx            context.Context
-	audioReceived  bool
-	resultChan     chan string
-	errorChan      chan error
-	receiverActive bool
+	client              io.Closer
+	stream              speechpb.Speech_StreamingRecognizeClient
+	ctx                 context.Context
+	cancel              context.CancelFunc
+	finalizationTimeout time.Duration
+	audioReceived       bool
+	resultChan          chan string
+	errorChan           chan error
+	// Confidence of the final transcription, set by the receiver before it
+	// sends the transcription
+	confidence float32
+	// Set once the receiver started, or once the stream closed before it
+	// could; receiverDone is closed when the receiver returns
+	receiverActive atomic.Bool
+	receiverDone   chan struct{}
+	cleanupOnce    sync.Once
 }
 
 func (g *GoogleSpeechToTextStream) Stream(data []byte) error {
 	// Start the result receiver goroutine only once
-	if !g.receiverActive {
-		g.receiverActive = true
+	if g.receiverActive.CompareAndSwap(false, true) {
 		go g.receiveResults()
 	}
 
@@ -117,7 +218,11 @@ func (g *GoogleSpeechToTextStream) End() (string, error) {
 	}
 
 	// Wait for final result or error
+	timeout := time.NewTimer(g.finalizationTimeout)
+	defer timeout.Stop()
 	select {
+	case <-timeout.C:
+		return "", fmt.Errorf("timed out after %s waiting for the final transcription", g.finalizationTimeout)
 	case <-g.ctx.Done():
 		return "", fmt.Errorf("context cancelled while waiting for result: %w", g.ctx.Err())
 	case err := <-g.errorChan:
@@ -126,7 +231,7 @@ func (g *GoogleSpeechToTextStream) End() (string, error) {
 		}
 	case result := <-g.resultChan:
 		if result == "" {
-			return "", fmt.Errorf("no speech detected in audio")
+			return "", fmt.Errorf("final transcription is empty: %w", errs.ErrNoSpeech)
 		}
 		return result, nil
 	}
@@ -134,16 +239,25 @@ func (g *GoogleSpeechToTextStream) End() (string, error) {
 	return "", fmt.Errorf("unexpected end of transcription")
 }
 
+// Confidence implements repositories.TranscriptionConfidence. Google reports
+// no confidence as zero.
+func (g *GoogleSpeechToTextStream) Confidence() (float64, bool) {
+	return float64(g.confidence), g.confidence > 0
+}
+
 func (g *GoogleSpeechToTextStream) receiveResults() {
+	defer close(g.receiverDone)
 	defer close(g.resultChan)
 	defer close(g.errorChan)
 
 	var finalTranscription string
+	var confidence float32
 
 	for {
 		resp, err := g.stream.Recv()
 		if err == io.EOF {
 			// Stream ended normally
+			g.confidence = confidence
 			g.resultChan <- finalTranscription
 			return
 		}
@@ -158,6 +272,7 @@ func (g *GoogleSpeechToTextStream) receiveResults() {
 				if result.IsFinal && len(result.Alternatives) > 0 {
 					// Take the best alternative
 					finalTranscription = result.Alternatives[0].Transcript
+					confidence = result.Alternatives[0].Confidence
 				}
 			}
 		}
@@ -165,9 +280,25 @@ func (g *GoogleSpeechToTextStream) receiveResults() {
 }
 
 func (g *GoogleSpeechToTextStream) cleanup() {
-	if g.client != nil {
-		g.client.Close()
+	g.cleanupOnce.Do(func() {
+		// Ends the stream and with it the receiver
+		g.cancel()
+		if g.client != nil {
+			g.client.Close()
+		}
+	})
+}
+
+// Close implements repositories.SpeechToTextStreaming. A listening session
+// abandoned before End, such as by a device that disconnected, releases the
+// recognition stream, its client and the receiver this way.
+func (g *GoogleSpeechToTextStream) Close() error {
+	g.cleanup()
+	// The receiver never starts once the stream closed
+	if !g.receiverActive.CompareAndSwap(false, true) {
+		<-g.receiverDone
 	}
+	return nil
 }
 
 // TranscribeAudio converts audio data to text using Google Cloud Speech-to-Text (non-streaming)
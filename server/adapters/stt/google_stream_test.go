@@ -0,0 +1,200 @@
+package stt
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/speech/apiv1/speechpb"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// fakeRecognizeStream answers with transcript once the audio ends, and
+// fails like a gRPC stream when its context is cancelled
+type fakeRecognizeStream struct {
+	speechpb.Speech_StreamingRecognizeClient
+
+	ctx        context.Context
+	transcript string
+	respond    bool
+	closed     chan struct{}
+	answered   bool
+}
+
+func (f *fakeRecognizeStream) Send(*speechpb.StreamingRecognizeRequest) error {
+	return f.ctx.Err()
+}
+
+func (f *fakeRecognizeStream) CloseSend() error {
+	close(f.closed)
+	return nil
+}
+
+func (f *fakeRecognizeStream) Recv() (*speechpb.StreamingRecognizeResponse, error) {
+	select {
+	case <-f.ctx.Done():
+		return nil, f.ctx.Err()
+	case <-f.closed:
+	}
+	if !f.respond {
+		<-f.ctx.Done()
+		return nil, f.ctx.Err()
+	}
+	if f.answered {
+		return nil, io.EOF
+	}
+	f.answered = true
+	return &speechpb.StreamingRecognizeResponse{
+		Results: []*speechpb.StreamingRecognitionResult{{
+			IsFinal:      true,
+			Alternatives: []*speechpb.SpeechRecognitionAlternative{{Transcript: f.transcript, Confidence: 0.75}},
+		}},
+	}, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+func newFakeSpeechToText(respond bool, finalizationTimeout time.Duration) (*GoogleSpeechToText, chan *fakeRecognizeStream) {
+	streams := make(chan *fakeRecognizeStream, 1)
+	return &GoogleSpeechToText{
+		finalizationTimeout: finalizationTimeout,
+		open: func(ctx context.Context) (speechpb.Speech_StreamingRecognizeClient, io.Closer, error) {
+			stream := &fakeRecognizeStream{ctx: ctx, transcript: "halo", respond: respond, closed: make(chan struct{})}
+			streams <- stream
+			return stream, nopCloser{}, nil
+		},
+	}, streams
+}
+
+var testAudioConfig = repositories.AudioConfig{SampleRate: 16000, Encoding: "LINEAR16", Language: "id-ID"}
+
+func TestGoogleSpeechToText_OutlivesInitTimeout(t *testing.T) {
+	g, streams := newFakeSpeechToText(true, time.Second)
+
+	initCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	streaming, err := g.InitTranscribeStreaming(initCtx, testAudioConfig)
+	if err != nil {
+		t.Fatalf("Failed to init streaming: %v", err)
+	}
+	stream := <-streams
+
+	// The child keeps talking past the init timeout
+	<-initCtx.Done()
+	if err := streaming.Stream([]byte{0x01, 0x02}); err != nil {
+		t.Fatalf("Expected streaming to continue after the init timeout, got %v", err)
+	}
+
+	transcript, err := streaming.End()
+	if err != nil || transcript != "halo" {
+		t.Fatalf("Expected the transcription, got %q, %v", transcript, err)
+	}
+	if stream.ctx.Err() == nil {
+		t.Error("Expected the stream context to be released after End")
+	}
+}
+
+func TestGoogleSpeechToText_ReportsConfidence(t *testing.T) {
+	g, _ := newFakeSpeechToText(true, time.Second)
+
+	streaming, err := g.InitTranscribeStreaming(context.Background(), testAudioConfig)
+	if err != nil {
+		t.Fatalf("Failed to init streaming: %v", err)
+	}
+	if err := streaming.Stream([]byte{0x01}); err != nil {
+		t.Fatalf("Failed to stream: %v", err)
+	}
+	if _, err := streaming.End(); err != nil {
+		t.Fatalf("Failed to end streaming: %v", err)
+	}
+
+	confidence, ok := streaming.(repositories.TranscriptionConfidence).Confidence()
+	if !ok || confidence != 0.75 {
+		t.Errorf("Expected the confidence of the final transcription, got %v, %v", confidence, ok)
+	}
+}
+
+func TestGoogleSpeechToText_FinalizationTimeout(t *testing.T) {
+	g, streams := newFakeSpeechToText(false, 50*time.Millisecond)
+
+	streaming, err := g.InitTranscribeStreaming(context.Background(), testAudioConfig)
+	if err != nil {
+		t.Fatalf("Failed to init streaming: %v", err)
+	}
+	stream := <-streams
+	if err := streaming.Stream([]byte{0x01}); err != nil {
+		t.Fatalf("Failed to stream: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := streaming.End(); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("Expected a finalization timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected End to give up after the finalization timeout, took %s", elapsed)
+	}
+	if stream.ctx.Err() == nil {
+		t.Error("Expected the abandoned stream to be cancelled")
+	}
+}
+
+func TestGoogleSpeechToText_InitRespectsCancelledContext(t *testing.T) {
+	g, _ := newFakeSpeechToText(true, time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := g.InitTranscribeStreaming(ctx, testAudioConfig); err == nil {
+		t.Error("Expected init to fail with a cancelled context")
+	}
+}
+
+func TestGoogleSpeechToText_CloseReleasesTheReceiver(t *testing.T) {
+	g, streams := newFakeSpeechToText(false, time.Second)
+
+	streaming, err := g.InitTranscribeStreaming(context.Background(), testAudioConfig)
+	if err != nil {
+		t.Fatalf("Failed to init streaming: %v", err)
+	}
+	stream := <-streams
+	if err := streaming.Stream([]byte{0x01}); err != nil {
+		t.Fatalf("Failed to stream: %v", err)
+	}
+
+	// The device disconnects in the middle of the utterance
+	if err := streaming.Close(); err != nil {
+		t.Fatalf("Failed to close streaming: %v", err)
+	}
+	if stream.ctx.Err() == nil {
+		t.Error("Expected the stream context to be released after Close")
+	}
+	select {
+	case <-streaming.(*GoogleSpeechToTextStream).receiverDone:
+	default:
+		t.Error("Expected the receiver to have stopped when Close returned")
+	}
+	if err := streaming.Close(); err != nil {
+		t.Errorf("Expected closing again to do nothing, got %v", err)
+	}
+}
+
+func TestGoogleSpeechToText_CloseWithoutAudio(t *testing.T) {
+	g, streams := newFakeSpeechToText(false, time.Second)
+
+	streaming, err := g.InitTranscribeStreaming(context.Background(), testAudioConfig)
+	if err != nil {
+		t.Fatalf("Failed to init streaming: %v", err)
+	}
+	stream := <-streams
+
+	if err := streaming.Close(); err != nil {
+		t.Fatalf("Failed to close streaming: %v", err)
+	}
+	if stream.ctx.Err() == nil {
+		t.Error("Expected the stream context to be released after Close")
+	}
+}
@@ -6,10 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -25,6 +27,13 @@ const (
 	defaultModelID      = "eleven_flash_v2_5"    // Default model ID
 	defaultStability    = 0.5                    // Default voice stability
 	defaultClarity      = 0.75                   // Default voice clarity/similarity_boost
+
+	defaultMaxCustomVoices = 3                // Default custom voices per parent account
+	defaultVoiceCacheTTL   = 10 * time.Minute // Default time the list of voices is trusted
+
+	defaultMaxConcurrentRequests = 4 // Default concurrent synthesis requests to the API
+
+	defaultQuotaRetryAfter = 5 * time.Minute // Default time syntheses are refused once the quota is exceeded
 )
 
 // ElevenLabsConfig holds configuration for the ElevenLabsTTS adapter
@@ -35,10 +44,21 @@ const (
 // - APIBaseURL: The base URL for the Eleven Labs API (default: "https://api.elevenlabs.io/v1")
 // - VoiceID: The voice ID to use (default: "21m00Tcm4TlvDq8ikWAM" - Rachel voice)
 // - ModelID: The model ID to use (default: "eleven_multilingual_v2")
-// - OutputFormat: The output format (default: "pcm_24000")
+// - OutputFormat: The output format, one of those ElevenLabs streams such as "mp3_44100_128" or "ulaw_8000" (default: "pcm_24000")
 // - ChunkSize: The size of audio chunks to stream (default: 1024)
 // - Stability: Voice stability value between 0 and 1 (default: 0.5)
 // - Clarity: Voice clarity/similarity boost value between 0 and 1 (default: 0.75)
+// - Voices: Voice and model per conversation language (default: none, VoiceID and ModelID for every language)
+// - MaxCustomVoices: Custom voices a parent account may create (default: 3)
+// - VoiceCacheTTL: Time the list of voices used to validate voice IDs is trusted (default: 10m)
+// - MaxConcurrentRequests: Synthesis requests sent to the API at once, further ones queue (default: 4)
+// - QuotaRetryAfter: Time syntheses fail without calling the API once it answered that the quota is exceeded (default: 5m)
+// - SilenceTrim: Drop the silence at the start and end of PCM output, other formats are streamed as they are (default: false)
+// - SilenceThreshold: RMS amplitude of 16-bit samples below which audio is silent (default: 300)
+// - SilenceMaxLeading: Audio at the start inspected for silence (default: 500ms)
+// - SilenceMaxTrailing: Silence at the end trimmed, longer silence is held back at most this long (default: 500ms)
+// - StreamFailover: Synthesize a response again without streaming when its stream fails (default: false)
+// - StreamFailoverMaxAudio: Audio a failed stream may have delivered for its response to be synthesized again (default: 2s)
 type ElevenLabsConfig struct {
 	APIKey       string  // Required: Your Eleven Labs API key
 	APIBaseURL   string  // Optional: The base URL for the Eleven Labs API
@@ -48,6 +68,27 @@ type ElevenLabsConfig struct {
 	ChunkSize    int     // Optional: The size of audio chunks to stream
 	Stability    float64 // Optional: Voice stability value between 0 and 1
 	Clarity      float64 // Optional: Voice clarity/similarity boost value between 0 and 1
+
+	Voices map[string]ElevenLabsVoice // Optional: Voice and model per conversation language
+
+	MaxCustomVoices int           // Optional: Custom voices a parent account may create
+	VoiceCacheTTL   time.Duration // Optional: Time the list of voices used to validate voice IDs is trusted
+
+	MaxConcurrentRequests int // Optional: Synthesis requests sent to the API at once, further ones queue
+
+	QuotaRetryAfter time.Duration // Optional: Time syntheses fail without calling the API once the quota is exceeded
+
+	SilenceTrim        bool          // Optional: Drop the silence at the start and end of PCM output
+	SilenceThreshold   int           // Optional: RMS amplitude of 16-bit samples below which audio is silent
+	SilenceMaxLeading  time.Duration // Optional: Audio at the start inspected for silence
+	SilenceMaxTrailing time.Duration // Optional: Silence at the end trimmed
+
+	StreamFailover         bool          // Optional: Synthesize a response again without streaming when its stream fails
+	StreamFailoverMaxAudio time.Duration // Optional: Audio a failed stream may have delivered for its response to be synthesized again
+
+	// Why ELEVEN_LABS_LANGUAGE_VOICES could not be parsed, reported by
+	// the constructor so that a mistyped mapping fails startup
+	voicesErr error
 }
 
 // ElevenLabsTTS implements TextToSpeech interface using Eleven Labs API
@@ -60,12 +101,45 @@ type ElevenLabsTTS struct {
 	chunkSize    int
 	stability    float64
 	clarity      float64
+	voices       map[string]ElevenLabsVoice
 	logger       *zap.Logger
+
+	// Guards custom voice creation against exceeding the per-account limit
+	voiceMu         sync.Mutex
+	maxCustomVoices int
+
+	// Voices of the account, refreshed after voiceCacheTTL or on demand
+	voiceCache     voiceCache
+	voiceRefreshMu sync.Mutex
+	voiceCacheTTL  time.Duration
+	now            func() time.Time
+
+	// Bounds the concurrent synthesis requests of the whole server
+	limiter *synthesisLimiter
+
+	// Syntheses fail without calling the API until quotaExhaustedUntil
+	quotaMu             sync.Mutex
+	quotaExhaustedUntil time.Time
+	quotaRetryAfter     time.Duration
+
+	// Trims the silence of PCM output when silenceTrim is set
+	silenceTrim        bool
+	silenceThreshold   int
+	silenceMaxLeading  time.Duration
+	silenceMaxTrailing time.Duration
+
+	// Synthesizes a response again without streaming when its stream fails
+	// before streamFailoverMaxAudio was delivered
+	streamFailover         bool
+	streamFailoverMaxAudio time.Duration
 }
 
 // Ensure ElevenLabsTTS implements the TextToSpeech interface
 var _ repositories.TextToSpeech = (*ElevenLabsTTS)(nil)
 
+// Ensure ElevenLabsTTS implements the PCMReporter interface
+var _ repositories.PCMReporter = (*ElevenLabsTTS)(nil)
+
 // ElevenLabsVoiceSettings represents voice settings for Eleven Labs API
 type ElevenLabsVoiceSettings struct {
 	Stability       float64 `json:"stability"`
@@ -104,6 +178,33 @@ func ValidateElevenLabsConfig(config ElevenLabsConfig) error {
 		return fmt.Errorf("chunk size must be positive, got %d", config.ChunkSize)
 	}
 
+	if config.MaxConcurrentRequests < 0 {
+		return fmt.Errorf("max concurrent requests must be positive, got %d", config.MaxConcurrentRequests)
+	}
+
+	if config.SilenceThreshold < 0 || config.SilenceThreshold > math.MaxInt16 {
+		return fmt.Errorf("silence threshold must be between 0 and %d, got %d", math.MaxInt16, config.SilenceThreshold)
+	}
+
+	if config.SilenceMaxLeading < 0 || config.SilenceMaxTrailing < 0 {
+		return fmt.Errorf("silence trimming durations must be positive")
+	}
+
+	if config.MaxCustomVoices < 0 {
+		return fmt.Errorf("max custom voices must be positive, got %d", config.MaxCustomVoices)
+	}
+
+	if config.voicesErr != nil {
+		return fmt.Errorf("invalid ELEVEN_LABS_LANGUAGE_VOICES: %w", config.voicesErr)
+	}
+
+	// Validate the output format is one ElevenLabs streams
+	if config.OutputFormat != "" {
+		if _, err := lookupOutputFormat(config.OutputFormat); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -158,6 +259,59 @@ func NewElevenLabsTTS(config ElevenLabsConfig, logger *zap.Logger) (*ElevenLabsT
 		logger.Info("Using default clarity", zap.Float64("clarity", clarity))
 	}
 
+	maxCustomVoices := config.MaxCustomVoices
+	if maxCustomVoices == 0 {
+		maxCustomVoices = defaultMaxCustomVoices
+		logger.Info("Using default max custom voices", zap.Int("maxCustomVoices", maxCustomVoices))
+	}
+
+	voiceCacheTTL := config.VoiceCacheTTL
+	if voiceCacheTTL == 0 {
+		voiceCacheTTL = defaultVoiceCacheTTL
+		logger.Info("Using default voice cache TTL", zap.Duration("voiceCacheTTL", voiceCacheTTL))
+	}
+
+	maxConcurrentRequests := config.MaxConcurrentRequests
+	if maxConcurrentRequests == 0 {
+		maxConcurrentRequests = defaultMaxConcurrentRequests
+		logger.Info("Using default max concurrent requests", zap.Int("maxConcurrentRequests", maxConcurrentRequests))
+	}
+
+	quotaRetryAfter := config.QuotaRetryAfter
+	if quotaRetryAfter == 0 {
+		quotaRetryAfter = defaultQuotaRetryAfter
+		logger.Info("Using default quota retry after", zap.Duration("quotaRetryAfter", quotaRetryAfter))
+	}
+
+	silenceThreshold := config.SilenceThreshold
+	silenceMaxLeading := config.SilenceMaxLeading
+	silenceMaxTrailing := config.SilenceMaxTrailing
+	if config.SilenceTrim {
+		if silenceThreshold == 0 {
+			silenceThreshold = defaultSilenceThreshold
+			logger.Info("Using default silence threshold", zap.Int("silenceThreshold", silenceThreshold))
+		}
+		if silenceMaxLeading == 0 {
+			silenceMaxLeading = defaultSilenceMaxLeading
+			logger.Info("Using default max leading silence", zap.Duration("silenceMaxLeading", silenceMaxLeading))
+		}
+		if silenceMaxTrailing == 0 {
+			silenceMaxTrailing = defaultSilenceMaxTrailing
+			logger.Info("Using default max trailing silence", zap.Duration("silenceMaxTrailing", silenceMaxTrailing))
+		}
+	}
+
+	streamFailoverMaxAudio := config.StreamFailoverMaxAudio
+	if config.StreamFailover && streamFailoverMaxAudio == 0 {
+		streamFailoverMaxAudio = defaultStreamFailoverMaxAudio
+		logger.Info("Using default stream failover max audio", zap.Duration("streamFailoverMaxAudio", streamFailoverMaxAudio))
+	}
+
+	voices := make(map[string]ElevenLabsVoice, len(config.Voices))
+	for language, voice := range config.Voices {
+		voices[strings.ToLower(language)] = voice
+	}
+
 	return &ElevenLabsTTS{
 		apiKey:       config.APIKey,
 		apiBaseURL:   apiBaseURL,
@@ -167,25 +321,59 @@ func NewElevenLabsTTS(config ElevenLabsConfig, logger *zap.Logger) (*ElevenLabsT
 		chunkSize:    chunkSize,
 		stability:    stability,
 		clarity:      clarity,
+		voices:       voices,
 		logger:       logger,
+
+		maxCustomVoices: maxCustomVoices,
+
+		voiceCacheTTL: voiceCacheTTL,
+		now:           time.Now,
+
+		limiter: newSynthesisLimiter(maxConcurrentRequests),
+
+		quotaRetryAfter: quotaRetryAfter,
+
+		silenceTrim:        config.SilenceTrim,
+		silenceThreshold:   silenceThreshold,
+		silenceMaxLeading:  silenceMaxLeading,
+		silenceMaxTrailing: silenceMaxTrailing,
+
+		streamFailover:         config.StreamFailover,
+		streamFailoverMaxAudio: streamFailoverMaxAudio,
 	}, nil
 }
 
-// ConvertTextToSpeech converts text to speech using Eleven Labs API
+// ConvertTextToSpeech converts text to speech using Eleven Labs API. It
+// returns once the API answered; when the account is out of quota the error
+// is repositories.ErrTTSQuotaExceeded, and so it is without calling the API
+// for QuotaRetryAfter.
 func (e *ElevenLabsTTS) ConvertTextToSpeech(ctx context.Context, text string) (<-chan []byte, error) {
 	if strings.TrimSpace(text) == "" {
 		return nil, fmt.Errorf("text cannot be empty")
 	}
+	if err := e.quotaError(); err != nil {
+		return nil, err
+	}
+
+	// Pick the voice matching the conversation language
+	language, _ := repositories.LanguageFromContext(ctx)
+	voice := e.resolveVoice(language)
+	// A child's custom voice replaces the language voice but keeps its model
+	if voiceID, ok := repositories.VoiceIDFromContext(ctx); ok {
+		voice.VoiceID = voiceID
+	}
 
 	e.logger.Info("Converting text to speech",
 		zap.String("text", text),
-		zap.String("voiceID", e.voiceID),
-		zap.String("modelID", e.modelID))
+		zap.String("language", language),
+		zap.String("voiceID", voice.VoiceID),
+		zap.String("modelID", voice.ModelID))
 
 	// Create request payload
 	request := ElevenLabsRequest{
 		Text:                   text,
-		ModelID:                e.modelID,
+		ModelID:                voice.ModelID,
+		LanguageCode:           voice.LanguageCode,
 		ApplyTextNormalization: "auto",
 		VoiceSettings: ElevenLabsVoiceSettings{
 			Stability:       e.stability,
@@ -202,19 +390,16 @@ func (e *ElevenLabsTTS) ConvertTextToSpeech(ctx context.Context, text string) (<
 	}
 
 	// Create HTTP request with streaming optimizations
+	outputFormat, format := e.resolveOutputFormat(ctx)
 	url := fmt.Sprintf("%s/text-to-speech/%s/stream?output_format=%s&enable_logging=false",
-		e.apiBaseURL, e.voiceID, e.outputFormat)
+		e.apiBaseURL, voice.VoiceID, outputFormat)
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 
-	// Set headers - Note: PCM format requires audio/pcm accept header
-	acceptHeader := "audio/mpeg"
-	if strings.HasPrefix(e.outputFormat, "pcm") {
-		acceptHeader = "audio/pcm"
-	}
-	httpReq.Header.Set("Accept", acceptHeader)
+	// Set headers - Note: the accept header must match the output format
+	httpReq.Header.Set("Accept", format.Accept)
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("xi-api-key", e.apiKey)
 
@@ -225,10 +410,27 @@ func (e *ElevenLabsTTS) ConvertTextToSpeech(ctx context.Context, text string) (<
 
 	// Create channel for streaming audio data
 	audioChan := make(chan []byte, 10)
+	// Receives the error refusing the synthesis, or nil once it is answered
+	answered := make(chan error, 1)
+	reported := false
+	report := func(err error) {
+		if !reported {
+			reported = true
+			answered <- err
+		}
+	}
 
 	// Execute request in goroutine to stream response
 	go func() {
 		defer close(audioChan)
+		defer report(nil)
+
+		// Wait for a free slot so that bursts of syntheses do not hit the API rate limits
+		if err := e.limiter.acquire(ctx, repositories.PriorityFromContext(ctx)); err != nil {
+			e.logger.Warn("Synthesis cancelled while waiting for the API", zap.Error(err))
+			return
+		}
+		defer e.limiter.release()
 
 		e.logger.Debug("Sending request to Eleven Labs API", zap.String("url", url))
 
@@ -242,65 +444,121 @@ func (e *ElevenLabsTTS) ConvertTextToSpeech(ctx context.Context, text string) (<
 		if resp.StatusCode != http.StatusOK {
 			// Read error response
 			errorBody, _ := io.ReadAll(resp.Body)
+			if message, exceeded := quotaExceeded(resp.StatusCode, errorBody); exceeded {
+				report(e.exhaustQuota(message))
+				return
+			}
 			e.logger.Error("Eleven Labs API returned error",
 				zap.Int("statusCode", resp.StatusCode),
-				zap.String("response", string(errorBody)))
+				zap.String("body", string(errorBody)))
 			return
 		}
+		report(nil)
 
 		e.logger.Info("Successfully received response from Eleven Labs API",
 			zap.String("contentType", resp.Header.Get("Content-Type")),
 			zap.String("contentLength", resp.Header.Get("Content-Length")))
 
-		// Stream the audio data in chunks
-		buffer := make([]byte, e.chunkSize)
-		totalBytes := 0
-		chunkCount := 0
+		// Stream the audio data in chunks, using the size negotiated for the
+		// connection when there is one
+		chunkSize := e.chunkSize
+		if size, ok := repositories.ChunkSizeFromContext(ctx); ok {
+			chunkSize = size
+		}
+		// No need for a buffer larger than the whole response
+		if resp.ContentLength > 0 && resp.ContentLength < int64(chunkSize) {
+			chunkSize = int(resp.ContentLength)
+		}
 
-		for {
-			select {
-			case <-ctx.Done():
-				e.logger.Warn("Context cancelled while streaming audio data")
-				return
-			default:
-				n, err := resp.Body.Read(buffer)
-				if n > 0 {
-					totalBytes += n
-					chunkCount++
-
-					// Create a copy of the data to send
-					chunk := make([]byte, n)
-					copy(chunk, buffer[:n])
-
-					e.logger.Debug("Sending audio chunk",
-						zap.Int("chunkNumber", chunkCount),
-						zap.Int("chunkSize", n),
-						zap.Int("totalBytes", totalBytes))
-
-					select {
-					case audioChan <- chunk:
-					case <-ctx.Done():
-						e.logger.Warn("Context cancelled while sending audio chunk")
-						return
-					}
-				}
+		sent, err := e.streamAudio(ctx, resp.Body, format, chunkSize, 0, audioChan)
+		if err != nil && e.canFailover(ctx, format, sent) {
+			e.failover(ctx, voice.VoiceID, outputFormat, format, requestBody, chunkSize, sent, audioChan)
+		}
+	}()
+
+	if err := <-answered; err != nil {
+		return nil, err
+	}
+	return audioChan, nil
+}
+
+// streamAudio sends the audio of body to audioChan in chunks of chunkSize,
+// skipping its first skip bytes, and returns how many bytes it sent. The
+// error is that of a stream failing midway, nil once it ended or ctx is done.
+func (e *ElevenLabsTTS) streamAudio(ctx context.Context, audio io.Reader, format outputFormat, chunkSize, skip int, audioChan chan<- []byte) (int, error) {
+	// Tells a stream that failed from one that ended, which ReadFull reports alike
+	stream := &streamReader{reader: audio}
+	var body io.Reader = stream
+	var trimmer *silenceTrimmer
+	if e.silenceTrim && format.pcm() {
+		trimmer = newSilenceTrimmer(stream, format.SampleRate, e.silenceThreshold, e.silenceMaxLeading, e.silenceMaxTrailing)
+		body = trimmer
+	}
+	if skip > 0 {
+		if _, err := io.CopyN(io.Discard, body, int64(skip)); err != nil {
+			return 0, stream.failure()
+		}
+	}
 
-				if err == io.EOF {
-					e.logger.Info("Finished streaming audio data",
-						zap.Int("totalChunks", chunkCount),
-						zap.Int("totalBytes", totalBytes))
-					return
+	buffer := make([]byte, chunkSize)
+	totalBytes := 0
+	chunkCount := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.logger.Warn("Context cancelled while streaming audio data")
+			return totalBytes, nil
+		default:
+			// Fill the whole buffer so that every frame but the last has the chunk size
+			n, err := io.ReadFull(body, buffer)
+			if n > 0 {
+				totalBytes += n
+				chunkCount++
+
+				// Create a copy of the data to send
+				chunk := make([]byte, n)
+				copy(chunk, buffer[:n])
+
+				e.logger.Debug("Sending audio chunk",
+					zap.Int("chunkNumber", chunkCount),
+					zap.Int("chunkSize", n),
+					zap.Int("totalBytes", totalBytes))
+
+				select {
+				case audioChan <- chunk:
+				case <-ctx.Done():
+					e.logger.Warn("Context cancelled while sending audio chunk")
+					return totalBytes, nil
 				}
+			}
 
-				if err != nil {
-					e.logger.Error("Error reading response body", zap.Error(err))
-					return
+			if failure := stream.failure(); failure != nil {
+				e.logger.Error("Error reading response body",
+					zap.Int("totalBytes", totalBytes),
+					zap.Error(failure))
+				return totalBytes, failure
+			}
+
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				e.logger.Info("Finished streaming audio data",
+					zap.Int("totalChunks", chunkCount),
+					zap.Int("totalBytes", totalBytes),
+					zap.Int("chunkSize", chunkSize))
+				if trimmer != nil {
+					e.logger.Debug("Trimmed silence of audio",
+						zap.Int("leadingBytes", trimmer.trimmedLeading),
+						zap.Int("trailingBytes", trimmer.trimmedTrailing))
 				}
+				return totalBytes, nil
 			}
-		}
-	}()
 
-	return audioChan, nil
+			if err != nil {
+				e.logger.Error("Error reading response body", zap.Error(err))
+				return totalBytes, err
+			}
+		}
+	}
 }
 
 // SetVoiceSettings allows customization of voice parameters
@@ -324,10 +582,40 @@ func (e *ElevenLabsTTS) SetModelID(modelID string) {
 	e.logger.Info("Updated model ID", zap.String("modelID", modelID))
 }
 
-// SetOutputFormat allows changing the output format for streaming
-func (e *ElevenLabsTTS) SetOutputFormat(format string) {
+// resolveOutputFormat returns the configured output format, switched to the
+// sample rate negotiated with the device when both are PCM
+func (e *ElevenLabsTTS) resolveOutputFormat(ctx context.Context) (string, outputFormat) {
+	configured := outputFormats[e.outputFormat]
+	rate, ok := repositories.SampleRateFromContext(ctx)
+	if !ok || !configured.pcm() {
+		return e.outputFormat, configured
+	}
+	name := fmt.Sprintf("pcm_%d", rate)
+	format, ok := outputFormats[name]
+	if !ok {
+		e.logger.Warn("Unsupported PCM sample rate requested, using the configured format",
+			zap.Int("sampleRate", rate),
+			zap.String("outputFormat", e.outputFormat))
+		return e.outputFormat, configured
+	}
+	return name, format
+}
+
+// OutputsPCM implements repositories.PCMReporter. Negotiated sample rates
+// only ever switch between PCM formats, so the configured one tells.
+func (e *ElevenLabsTTS) OutputsPCM() bool {
+	return outputFormats[e.outputFormat].pcm()
+}
+
+// SetOutputFormat allows changing the output format for streaming. It
+// errors, leaving the format unchanged, when ElevenLabs cannot stream format.
+func (e *ElevenLabsTTS) SetOutputFormat(format string) error {
+	if _, err := lookupOutputFormat(format); err != nil {
+		return err
+	}
 	e.outputFormat = format
 	e.logger.Info("Updated output format", zap.String("outputFormat", format))
+	return nil
 }
 
 // NewElevenLabsConfigFromEnv creates a new ElevenLabsConfig from environment variables
@@ -364,6 +652,75 @@ func NewElevenLabsConfigFromEnv() ElevenLabsConfig {
 		}
 	}
 
+	if maxStr := os.Getenv("ELEVEN_LABS_MAX_CUSTOM_VOICES"); maxStr != "" {
+		if maxVoices, err := strconv.Atoi(maxStr); err == nil && maxVoices > 0 {
+			config.MaxCustomVoices = maxVoices
+		}
+	}
+
+	if concurrentStr := os.Getenv("ELEVEN_LABS_MAX_CONCURRENT_REQUESTS"); concurrentStr != "" {
+		if concurrent, err := strconv.Atoi(concurrentStr); err == nil && concurrent > 0 {
+			config.MaxConcurrentRequests = concurrent
+		}
+	}
+
+	if retryStr := os.Getenv("ELEVEN_LABS_QUOTA_RETRY_AFTER_SECONDS"); retryStr != "" {
+		if retry, err := strconv.Atoi(retryStr); err == nil && retry > 0 {
+			config.QuotaRetryAfter = time.Duration(retry) * time.Second
+		}
+	}
+
+	if ttlStr := os.Getenv("ELEVEN_LABS_VOICE_CACHE_TTL_SECONDS"); ttlStr != "" {
+		if ttl, err := strconv.Atoi(ttlStr); err == nil && ttl > 0 {
+			config.VoiceCacheTTL = time.Duration(ttl) * time.Second
+		}
+	}
+
+	if trimStr := os.Getenv("ELEVEN_LABS_SILENCE_TRIM_ENABLED"); trimStr != "" {
+		if enabled, err := strconv.ParseBool(trimStr); err == nil {
+			config.SilenceTrim = enabled
+		}
+	}
+
+	if thresholdStr := os.Getenv("ELEVEN_LABS_SILENCE_THRESHOLD"); thresholdStr != "" {
+		if threshold, err := strconv.Atoi(thresholdStr); err == nil && threshold > 0 {
+			config.SilenceThreshold = threshold
+		}
+	}
+
+	if leadingStr := os.Getenv("ELEVEN_LABS_SILENCE_MAX_LEADING_MS"); leadingStr != "" {
+		if leading, err := strconv.Atoi(leadingStr); err == nil && leading > 0 {
+			config.SilenceMaxLeading = time.Duration(leading) * time.Millisecond
+		}
+	}
+
+	if trailingStr := os.Getenv("ELEVEN_LABS_SILENCE_MAX_TRAILING_MS"); trailingStr != "" {
+		if trailing, err := strconv.Atoi(trailingStr); err == nil && trailing > 0 {
+			config.SilenceMaxTrailing = time.Duration(trailing) * time.Millisecond
+		}
+	}
+
+	if failoverStr := os.Getenv("ELEVEN_LABS_STREAM_FAILOVER_ENABLED"); failoverStr != "" {
+		if enabled, err := strconv.ParseBool(failoverStr); err == nil {
+			config.StreamFailover = enabled
+		}
+	}
+
+	if maxAudioStr := os.Getenv("ELEVEN_LABS_STREAM_FAILOVER_MAX_AUDIO_MS"); maxAudioStr != "" {
+		if maxAudio, err := strconv.Atoi(maxAudioStr); err == nil && maxAudio > 0 {
+			config.StreamFailoverMaxAudio = time.Duration(maxAudio) * time.Millisecond
+		}
+	}
+
+	if voicesStr := os.Getenv("ELEVEN_LABS_LANGUAGE_VOICES"); voicesStr != "" {
+		voices, err := ParseElevenLabsVoices(voicesStr)
+		if err != nil {
+			config.voicesErr = err
+		} else {
+			config.Voices = voices
+		}
+	}
+
 	return config
 }
 
@@ -402,5 +759,34 @@ func (e *ElevenLabsTTS) GetAvailableVoices(ctx context.Context) ([]map[string]in
 	}
 
 	e.logger.Info("Retrieved available voices", zap.Int("count", len(voicesResponse.Voices)))
+	e.voiceCache.store(voicesResponse.Voices, e.now())
 	return voicesResponse.Voices, nil
 }
+
+// CheckHealth implements repositories.HealthChecker by listing the models
+func (e *ElevenLabsTTS) CheckHealth(ctx context.Context) error {
+	url := fmt.Sprintf("%s/models", e.apiBaseURL)
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	httpReq.Header.Set("xi-api-key", e.apiKey)
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to execute HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errorBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API returned error %d: %s", resp.StatusCode, string(errorBody))
+	}
+	return nil
+}
@@ -2,12 +2,17 @@ package tts
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
 )
 
 func TestNewElevenLabsTTS(t *testing.T) {
@@ -104,6 +109,198 @@ func TestElevenLabsTTS_ConvertTextToSpeech_EmptyText(t *testing.T) {
 	}
 }
 
+// newStreamingServer serves audioSize bytes of audio, flushing in small
+// writes like the real streaming endpoint
+func newStreamingServer(audioSize int) *httptest.Server {
+	audio := make([]byte, audioSize)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/pcm")
+		flusher := w.(http.Flusher)
+		for start := 0; start < len(audio); start += 300 {
+			end := min(start+300, len(audio))
+			w.Write(audio[start:end])
+			flusher.Flush()
+		}
+	}))
+}
+
+func TestElevenLabsTTS_ConvertTextToSpeech_ChunkSize(t *testing.T) {
+	server := newStreamingServer(10000)
+	defer server.Close()
+
+	tts, err := NewElevenLabsTTS(ElevenLabsConfig{APIKey: "test-api-key", APIBaseURL: server.URL}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create ElevenLabsTTS: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		wantFrames int
+	}{
+		{"configured default", context.Background(), 10},
+		{"negotiated size", repositories.WithChunkSize(context.Background(), 4096), 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audioChan, err := tts.ConvertTextToSpeech(tt.ctx, "Halo")
+			if err != nil {
+				t.Fatalf("Failed to convert text to speech: %v", err)
+			}
+
+			frames, totalBytes := 0, 0
+			for chunk := range audioChan {
+				frames++
+				totalBytes += len(chunk)
+			}
+
+			if frames != tt.wantFrames || totalBytes != 10000 {
+				t.Errorf("Expected %d frames of 10000 bytes, got %d frames of %d bytes", tt.wantFrames, frames, totalBytes)
+			}
+		})
+	}
+}
+
+func TestElevenLabsTTS_ConvertTextToSpeech_SampleRate(t *testing.T) {
+	formats := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		formats <- r.URL.Query().Get("output_format")
+		w.Header().Set("Content-Type", "audio/pcm")
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		outputFormat string
+		ctx          context.Context
+		wantFormat   string
+	}{
+		{"configured default", "", context.Background(), "pcm_24000"},
+		{"negotiated rate", "", repositories.WithSampleRate(context.Background(), 16000), "pcm_16000"},
+		{"unsupported rate", "", repositories.WithSampleRate(context.Background(), 11025), "pcm_24000"},
+		{"mp3 format kept", "mp3_44100_128", repositories.WithSampleRate(context.Background(), 16000), "mp3_44100_128"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tts, err := NewElevenLabsTTS(ElevenLabsConfig{
+				APIKey:       "test-api-key",
+				APIBaseURL:   server.URL,
+				OutputFormat: tt.outputFormat,
+			}, zaptest.NewLogger(t))
+			if err != nil {
+				t.Fatalf("Failed to create ElevenLabsTTS: %v", err)
+			}
+
+			audioChan, err := tts.ConvertTextToSpeech(tt.ctx, "Halo")
+			if err != nil {
+				t.Fatalf("Failed to convert text to speech: %v", err)
+			}
+			for range audioChan {
+			}
+
+			if got := <-formats; got != tt.wantFormat {
+				t.Errorf("Expected output format %q, got %q", tt.wantFormat, got)
+			}
+		})
+	}
+}
+
+func TestElevenLabsTTS_ConvertTextToSpeech_AcceptHeader(t *testing.T) {
+	accepts := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepts <- r.Header.Get("Accept")
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		outputFormat string
+		wantAccept   string
+	}{
+		{"pcm_16000", "audio/pcm"},
+		{"mp3_44100_128", "audio/mpeg"},
+		{"ulaw_8000", "audio/basic"},
+		{"opus_48000_64", "audio/ogg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.outputFormat, func(t *testing.T) {
+			tts, err := NewElevenLabsTTS(ElevenLabsConfig{
+				APIKey:       "test-api-key",
+				APIBaseURL:   server.URL,
+				OutputFormat: tt.outputFormat,
+			}, zaptest.NewLogger(t))
+			if err != nil {
+				t.Fatalf("Failed to create ElevenLabsTTS: %v", err)
+			}
+
+			audioChan, err := tts.ConvertTextToSpeech(context.Background(), "Halo")
+			if err != nil {
+				t.Fatalf("Failed to convert text to speech: %v", err)
+			}
+			for range audioChan {
+			}
+
+			if got := <-accepts; got != tt.wantAccept {
+				t.Errorf("Expected Accept %q, got %q", tt.wantAccept, got)
+			}
+		})
+	}
+}
+
+func TestNewElevenLabsTTS_RejectsUnsupportedOutputFormat(t *testing.T) {
+	for _, format := range []string{"opus", "wav_44100", "pcm_11025"} {
+		if _, err := NewElevenLabsTTS(ElevenLabsConfig{APIKey: "test-api-key", OutputFormat: format}, zaptest.NewLogger(t)); err == nil {
+			t.Errorf("Expected output format %q to be rejected", format)
+		}
+	}
+
+	tts, err := NewElevenLabsTTS(ElevenLabsConfig{APIKey: "test-api-key"}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create ElevenLabsTTS: %v", err)
+	}
+	if err := tts.SetOutputFormat("ulaw_16000"); err == nil {
+		t.Error("Expected SetOutputFormat to reject an unsupported format")
+	}
+	if tts.outputFormat != defaultOutputFormat {
+		t.Errorf("Expected the output format unchanged, got %q", tts.outputFormat)
+	}
+}
+
+// BenchmarkElevenLabsTTS_ChunkSize reports the frame count and throughput of
+// streaming one second of 24kHz PCM audio at different chunk sizes
+func BenchmarkElevenLabsTTS_ChunkSize(b *testing.B) {
+	const audioSize = 48000
+	server := newStreamingServer(audioSize)
+	defer server.Close()
+
+	tts, err := NewElevenLabsTTS(ElevenLabsConfig{APIKey: "test-api-key", APIBaseURL: server.URL}, zap.NewNop())
+	if err != nil {
+		b.Fatalf("Failed to create ElevenLabsTTS: %v", err)
+	}
+
+	for _, chunkSize := range []int{256, 512, 1024, 4096, 8192, 16384} {
+		b.Run(fmt.Sprintf("chunk=%d", chunkSize), func(b *testing.B) {
+			ctx := repositories.WithChunkSize(context.Background(), chunkSize)
+			b.SetBytes(audioSize)
+
+			frames := 0
+			for i := 0; i < b.N; i++ {
+				audioChan, err := tts.ConvertTextToSpeech(ctx, "Halo")
+				if err != nil {
+					b.Fatalf("Failed to convert text to speech: %v", err)
+				}
+				for range audioChan {
+					frames++
+				}
+			}
+
+			b.ReportMetric(float64(frames)/float64(b.N), "frames/op")
+		})
+	}
+}
+
 // Integration test - only runs if ELEVEN_LABS_API_KEY is set with real API key
 func TestElevenLabsTTS_ConvertTextToSpeech_Integration(t *testing.T) {
 	apiKey := os.Getenv("ELEVEN_LABS_API_KEY")
@@ -120,7 +317,9 @@ func TestElevenLabsTTS_ConvertTextToSpeech_Integration(t *testing.T) {
 	}
 
 	// Configure for Indonesian streaming with low latency
-	tts.SetOutputFormat("pcm_44100")
+	if err := tts.SetOutputFormat("pcm_44100"); err != nil {
+		t.Fatalf("Failed to set output format: %v", err)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
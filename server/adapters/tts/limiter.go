@@ -0,0 +1,101 @@
+package tts
+
+import (
+	"context"
+	"expvar"
+	"sync"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+var (
+	// synthesisQueueDepth counts syntheses waiting for a free API slot
+	synthesisQueueDepth = expvar.NewInt("tts_synthesis_queue_depth")
+	// synthesisInFlight counts syntheses currently calling the API
+	synthesisInFlight = expvar.NewInt("tts_synthesis_in_flight")
+)
+
+// synthesisLimiter bounds the concurrent synthesis requests to the API.
+// Waiting real-time requests always get the next free slot before background
+// ones; requests of the same priority are served in arrival order.
+type synthesisLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+	queues   [repositories.PriorityBackground + 1][]chan struct{}
+}
+
+func newSynthesisLimiter(limit int) *synthesisLimiter {
+	return &synthesisLimiter{limit: limit}
+}
+
+// acquire waits for a free slot. It fails when ctx ends first.
+func (l *synthesisLimiter) acquire(ctx context.Context, priority repositories.SynthesisPriority) error {
+	if priority < repositories.PriorityRealtime || priority > repositories.PriorityBackground {
+		priority = repositories.PriorityRealtime
+	}
+
+	l.mu.Lock()
+	if l.inFlight < l.limit && l.waiting() == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		synthesisInFlight.Add(1)
+		return nil
+	}
+	ready := make(chan struct{})
+	l.queues[priority] = append(l.queues[priority], ready)
+	l.mu.Unlock()
+	synthesisQueueDepth.Add(1)
+
+	select {
+	case <-ready:
+		synthesisQueueDepth.Add(-1)
+		synthesisInFlight.Add(1)
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	synthesisQueueDepth.Add(-1)
+	for i, waiter := range l.queues[priority] {
+		if waiter == ready {
+			l.queues[priority] = append(l.queues[priority][:i], l.queues[priority][i+1:]...)
+			return ctx.Err()
+		}
+	}
+	// The slot was handed over while ctx ended; pass it on
+	synthesisInFlight.Add(1)
+	l.releaseLocked()
+	return ctx.Err()
+}
+
+// release frees the slot taken by a successful acquire
+func (l *synthesisLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.releaseLocked()
+}
+
+func (l *synthesisLimiter) releaseLocked() {
+	synthesisInFlight.Add(-1)
+	for priority := range l.queues {
+		if len(l.queues[priority]) == 0 {
+			continue
+		}
+		next := l.queues[priority][0]
+		l.queues[priority] = l.queues[priority][1:]
+		// The slot passes to the waiter without being freed
+		close(next)
+		return
+	}
+	l.inFlight--
+}
+
+func (l *synthesisLimiter) waiting() int {
+	n := 0
+	for _, queue := range l.queues {
+		n += len(queue)
+	}
+	return n
+}
@@ -0,0 +1,145 @@
+package tts
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+func TestElevenLabsTTS_LimitsConcurrentRequests(t *testing.T) {
+	const limit = 3
+	var inFlight, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := peak.Load()
+			if current <= seen || peak.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		w.Header().Set("Content-Type", "audio/pcm")
+		w.Write(make([]byte, 100))
+	}))
+	defer server.Close()
+
+	tts, err := NewElevenLabsTTS(ElevenLabsConfig{APIKey: "key", APIBaseURL: server.URL, MaxConcurrentRequests: limit}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create TTS: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var received atomic.Int32
+	for i := 0; i < 20; i++ {
+		ctx := context.Background()
+		if i%2 == 0 {
+			ctx = repositories.WithPriority(ctx, repositories.PriorityBackground)
+		}
+		audioChan, err := tts.ConvertTextToSpeech(ctx, "Halo")
+		if err != nil {
+			t.Fatalf("Failed to convert text to speech: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range audioChan {
+				received.Add(int32(len(chunk)))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > limit || got == 0 {
+		t.Errorf("Expected at most %d concurrent requests, got %d", limit, got)
+	}
+	if got := received.Load(); got != 20*100 {
+		t.Errorf("Expected every synthesis to complete, got %d bytes", got)
+	}
+	if depth, active := synthesisQueueDepth.Value(), synthesisInFlight.Value(); depth != 0 || active != 0 {
+		t.Errorf("Expected empty metrics after the load, got queue depth %d and in flight %d", depth, active)
+	}
+}
+
+func TestSynthesisLimiter_PrioritizesRealtime(t *testing.T) {
+	limiter := newSynthesisLimiter(1)
+	ctx := context.Background()
+	if err := limiter.acquire(ctx, repositories.PriorityRealtime); err != nil {
+		t.Fatalf("Failed to acquire: %v", err)
+	}
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	enqueue := func(name string, priority repositories.SynthesisPriority) {
+		limiter.mu.Lock()
+		queued := limiter.waiting()
+		limiter.mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := limiter.acquire(ctx, priority); err != nil {
+				t.Errorf("Failed to acquire: %v", err)
+				return
+			}
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			limiter.release()
+		}()
+		// Wait until the request is queued so that arrival order is known
+		for {
+			limiter.mu.Lock()
+			n := limiter.waiting()
+			limiter.mu.Unlock()
+			if n > queued {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	enqueue("background-1", repositories.PriorityBackground)
+	enqueue("realtime-1", repositories.PriorityRealtime)
+	enqueue("background-2", repositories.PriorityBackground)
+	enqueue("realtime-2", repositories.PriorityRealtime)
+	limiter.release()
+	wg.Wait()
+
+	want := []string{"realtime-1", "realtime-2", "background-1", "background-2"}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("Expected order %v, got %v", want, order)
+		}
+	}
+}
+
+func TestSynthesisLimiter_CancelledWhileQueued(t *testing.T) {
+	limiter := newSynthesisLimiter(1)
+	if err := limiter.acquire(context.Background(), repositories.PriorityRealtime); err != nil {
+		t.Fatalf("Failed to acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.acquire(ctx, repositories.PriorityBackground); err == nil {
+		t.Fatal("Expected the queued request to give up with its context")
+	}
+
+	limiter.release()
+	if err := limiter.acquire(context.Background(), repositories.PriorityRealtime); err != nil {
+		t.Fatalf("Expected the slot to be free again: %v", err)
+	}
+	limiter.release()
+	if limiter.inFlight != 0 || limiter.waiting() != 0 {
+		t.Errorf("Expected an idle limiter, got %d in flight and %d waiting", limiter.inFlight, limiter.waiting())
+	}
+}
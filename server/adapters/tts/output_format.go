@@ -0,0 +1,68 @@
+package tts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// outputFormat describes an output format ElevenLabs streams
+type outputFormat struct {
+	// Accept is the Accept header ElevenLabs expects for the format
+	Accept string
+	// Container wraps the encoded audio, "raw" for bare samples
+	Container string
+	// Encoding of the samples
+	Encoding   string
+	SampleRate int
+	// Bitrate in kbps of compressed formats, 0 for uncompressed ones
+	Bitrate int
+}
+
+// outputFormats are the output formats ElevenLabs streams, by the name of
+// the output_format query parameter
+var outputFormats = map[string]outputFormat{
+	"mp3_22050_32":  {Accept: "audio/mpeg", Container: "mp3", Encoding: "mp3", SampleRate: 22050, Bitrate: 32},
+	"mp3_44100_32":  {Accept: "audio/mpeg", Container: "mp3", Encoding: "mp3", SampleRate: 44100, Bitrate: 32},
+	"mp3_44100_64":  {Accept: "audio/mpeg", Container: "mp3", Encoding: "mp3", SampleRate: 44100, Bitrate: 64},
+	"mp3_44100_96":  {Accept: "audio/mpeg", Container: "mp3", Encoding: "mp3", SampleRate: 44100, Bitrate: 96},
+	"mp3_44100_128": {Accept: "audio/mpeg", Container: "mp3", Encoding: "mp3", SampleRate: 44100, Bitrate: 128},
+	"mp3_44100_192": {Accept: "audio/mpeg", Container: "mp3", Encoding: "mp3", SampleRate: 44100, Bitrate: 192},
+
+	"pcm_8000":  {Accept: "audio/pcm", Container: "raw", Encoding: "pcm_s16le", SampleRate: 8000},
+	"pcm_16000": {Accept: "audio/pcm", Container: "raw", Encoding: "pcm_s16le", SampleRate: 16000},
+	"pcm_22050": {Accept: "audio/pcm", Container: "raw", Encoding: "pcm_s16le", SampleRate: 22050},
+	"pcm_24000": {Accept: "audio/pcm", Container: "raw", Encoding: "pcm_s16le", SampleRate: 24000},
+	"pcm_44100": {Accept: "audio/pcm", Container: "raw", Encoding: "pcm_s16le", SampleRate: 44100},
+	"pcm_48000": {Accept: "audio/pcm", Container: "raw", Encoding: "pcm_s16le", SampleRate: 48000},
+
+	// Telephony formats, 8 bits per sample
+	"ulaw_8000": {Accept: "audio/basic", Container: "raw", Encoding: "mulaw", SampleRate: 8000},
+	"alaw_8000": {Accept: "audio/x-alaw-basic", Container: "raw", Encoding: "alaw", SampleRate: 8000},
+
+	"opus_48000_32":  {Accept: "audio/ogg", Container: "ogg", Encoding: "opus", SampleRate: 48000, Bitrate: 32},
+	"opus_48000_64":  {Accept: "audio/ogg", Container: "ogg", Encoding: "opus", SampleRate: 48000, Bitrate: 64},
+	"opus_48000_96":  {Accept: "audio/ogg", Container: "ogg", Encoding: "opus", SampleRate: 48000, Bitrate: 96},
+	"opus_48000_128": {Accept: "audio/ogg", Container: "ogg", Encoding: "opus", SampleRate: 48000, Bitrate: 128},
+	"opus_48000_192": {Accept: "audio/ogg", Container: "ogg", Encoding: "opus", SampleRate: 48000, Bitrate: 192},
+}
+
+// lookupOutputFormat returns the output format named name, erroring with the
+// supported ones when ElevenLabs cannot stream it
+func lookupOutputFormat(name string) (outputFormat, error) {
+	format, ok := outputFormats[name]
+	if !ok {
+		names := make([]string, 0, len(outputFormats))
+		for name := range outputFormats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return outputFormat{}, fmt.Errorf("unsupported output format %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return format, nil
+}
+
+// pcm reports whether the format carries bare 16-bit PCM samples
+func (f outputFormat) pcm() bool {
+	return f.Encoding == "pcm_s16le"
+}
@@ -0,0 +1,71 @@
+package tts
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// ttsQuotaExceeded counts the syntheses ElevenLabs refused for lack of quota
+var ttsQuotaExceeded = expvar.NewInt("tts_quota_exceeded")
+
+// quotaStatuses are the statuses of ElevenLabs error bodies telling that the
+// account is out of credits or its subscription does not allow the request
+var quotaStatuses = map[string]bool{
+	"quota_exceeded":   true,
+	"payment_required": true,
+}
+
+// elevenLabsError is the body of an ElevenLabs error response, such as
+// {"detail": {"status": "quota_exceeded", "message": "..."}}. Validation
+// errors have other details, which are not read.
+type elevenLabsError struct {
+	Detail json.RawMessage `json:"detail"`
+}
+
+// quotaExceeded reports whether an error response of the API tells that the
+// account is out of quota, returning the message of the body when it does
+func quotaExceeded(statusCode int, body []byte) (string, bool) {
+	var response elevenLabsError
+	var detail struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &response) == nil && json.Unmarshal(response.Detail, &detail) == nil && quotaStatuses[detail.Status] {
+		return detail.Message, true
+	}
+	return string(body), statusCode == http.StatusPaymentRequired
+}
+
+// quotaError returns the error of syntheses while the quota is exhausted,
+// nil when requests may be sent
+func (e *ElevenLabsTTS) quotaError() error {
+	e.quotaMu.Lock()
+	defer e.quotaMu.Unlock()
+	if until := e.quotaExhaustedUntil; !until.IsZero() && e.now().Before(until) {
+		return fmt.Errorf("%w until %s", repositories.ErrTTSQuotaExceeded, until.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// exhaustQuota records that the API refused a synthesis for lack of quota:
+// no synthesis is sent for the next QuotaRetryAfter, since it would be
+// refused too
+func (e *ElevenLabsTTS) exhaustQuota(message string) error {
+	ttsQuotaExceeded.Add(1)
+	e.quotaMu.Lock()
+	e.quotaExhaustedUntil = e.now().Add(e.quotaRetryAfter)
+	e.quotaMu.Unlock()
+
+	e.logger.Error("Eleven Labs quota exceeded, refusing syntheses until it is retried",
+		zap.String("event", "tts_quota_exceeded"),
+		zap.String("message", message),
+		zap.Duration("quotaRetryAfter", e.quotaRetryAfter))
+	return fmt.Errorf("%w: %s", repositories.ErrTTSQuotaExceeded, message)
+}
@@ -0,0 +1,96 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+const quotaExceededBody = `{"detail":{"status":"quota_exceeded","message":"This request exceeds your quota of 10000. You have 12 credits remaining."}}`
+
+func TestQuotaExceeded(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		want       bool
+	}{
+		{"quota exceeded", http.StatusUnauthorized, quotaExceededBody, true},
+		{"payment required", http.StatusPaymentRequired, `{"detail":{"status":"payment_required","message":"Upgrade your subscription"}}`, true},
+		{"payment required without body", http.StatusPaymentRequired, "", true},
+		{"invalid API key", http.StatusUnauthorized, `{"detail":{"status":"invalid_api_key","message":"Invalid API key"}}`, false},
+		{"validation error", http.StatusUnprocessableEntity, `{"detail":[{"loc":["body","text"],"msg":"field required"}]}`, false},
+		{"rate limited", http.StatusTooManyRequests, `{"detail":{"status":"too_many_concurrent_requests"}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := quotaExceeded(tt.statusCode, []byte(tt.body)); got != tt.want {
+				t.Errorf("Expected quota exceeded %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestElevenLabsTTS_RefusesSynthesisWhileQuotaExceeded(t *testing.T) {
+	var requests atomic.Int32
+	var exceeded atomic.Bool
+	exceeded.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if exceeded.Load() {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(quotaExceededBody))
+			return
+		}
+		w.Header().Set("Content-Type", "audio/pcm")
+		w.Write(make([]byte, 100))
+	}))
+	defer server.Close()
+
+	tts, err := NewElevenLabsTTS(ElevenLabsConfig{APIKey: "key", APIBaseURL: server.URL, QuotaRetryAfter: time.Minute}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create TTS: %v", err)
+	}
+	now := time.Now()
+	tts.now = func() time.Time { return now }
+	before := ttsQuotaExceeded.Value()
+
+	if _, err := tts.ConvertTextToSpeech(context.Background(), "Halo"); !errors.Is(err, repositories.ErrTTSQuotaExceeded) {
+		t.Fatalf("Expected the quota to be exceeded, got %v", err)
+	}
+	if got := ttsQuotaExceeded.Value() - before; got != 1 {
+		t.Errorf("Expected one tts_quota_exceeded count, got %d", got)
+	}
+
+	// Retrying would be refused too, so the API is not called
+	if _, err := tts.ConvertTextToSpeech(context.Background(), "Halo"); !errors.Is(err, repositories.ErrTTSQuotaExceeded) {
+		t.Fatalf("Expected the synthesis to be refused, got %v", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Expected a single request to the API, got %d", got)
+	}
+
+	// The quota is retried once QuotaRetryAfter passed
+	exceeded.Store(false)
+	now = now.Add(time.Minute)
+	audioChan, err := tts.ConvertTextToSpeech(context.Background(), "Halo")
+	if err != nil {
+		t.Fatalf("Expected the synthesis to be retried, got %v", err)
+	}
+	received := 0
+	for chunk := range audioChan {
+		received += len(chunk)
+	}
+	if received != 100 || requests.Load() != 2 {
+		t.Errorf("Expected the audio of a second request, got %d bytes in %d requests", received, requests.Load())
+	}
+}
@@ -0,0 +1,132 @@
+package tts
+
+import (
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	defaultSilenceThreshold   = 300                    // RMS amplitude of 16-bit samples below which audio is silent, about -40 dBFS
+	defaultSilenceMaxLeading  = 500 * time.Millisecond // Default leading audio inspected for silence
+	defaultSilenceMaxTrailing = 500 * time.Millisecond // Default trailing silence trimmed
+
+	// silenceFrame is the audio measured at once
+	silenceFrame = 10 * time.Millisecond
+)
+
+// silenceTrimmer reads 16-bit PCM, dropping the silence at its start and
+// end. Only the first maxLeading bytes are inspected for leading silence,
+// and silence is held back up to maxTrailing bytes to be dropped when the
+// stream ends there, so that audio is delayed by at most a frame while the
+// doll speaks. The silent frame just before the speech is kept so that its
+// onset is not clipped.
+type silenceTrimmer struct {
+	src         io.Reader
+	frameSize   int
+	threshold   float64
+	leadingLeft int
+	maxTrailing int
+
+	leading bool
+	// pad is the last silent leading frame, spoken with the first loud one
+	pad   []byte
+	held  []byte
+	ready []byte
+	done  bool
+
+	// Bytes dropped, for logs
+	trimmedLeading  int
+	trimmedTrailing int
+}
+
+// newSilenceTrimmer trims the PCM read from src at sampleRate
+func newSilenceTrimmer(src io.Reader, sampleRate, threshold int, maxLeading, maxTrailing time.Duration) *silenceTrimmer {
+	return &silenceTrimmer{
+		src:         src,
+		frameSize:   pcmBytes(sampleRate, silenceFrame),
+		threshold:   float64(threshold),
+		leadingLeft: pcmBytes(sampleRate, maxLeading),
+		maxTrailing: pcmBytes(sampleRate, maxTrailing),
+		leading:     true,
+	}
+}
+
+// pcmBytes returns the bytes of 16-bit mono PCM lasting duration at
+// sampleRate, a whole number of samples
+func pcmBytes(sampleRate int, duration time.Duration) int {
+	return int(int64(sampleRate)*int64(duration)/int64(time.Second)) * 2
+}
+
+// Read implements io.Reader
+func (t *silenceTrimmer) Read(p []byte) (int, error) {
+	for len(t.ready) == 0 {
+		if t.done {
+			return 0, io.EOF
+		}
+		if err := t.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, t.ready)
+	t.ready = t.ready[n:]
+	return n, nil
+}
+
+// readFrame reads the next frame of src and decides what of it, and of the
+// silence held before it, is ready to be read
+func (t *silenceTrimmer) readFrame() error {
+	frame := make([]byte, t.frameSize)
+	n, err := io.ReadFull(t.src, frame)
+	frame = frame[:n]
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		t.done = true
+	} else if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		silent := rms(frame) < t.threshold
+		switch {
+		case t.leading && silent && t.leadingLeft >= n:
+			t.leadingLeft -= n
+			t.trimmedLeading += len(t.pad)
+			t.pad = frame
+		case t.leading:
+			t.leading = false
+			t.ready = append(t.pad, frame...)
+			t.pad = nil
+		case silent:
+			t.held = append(t.held, frame...)
+			// Longer silence is a pause within the speech
+			if excess := len(t.held) - t.maxTrailing; excess > 0 {
+				t.ready = append(t.ready, t.held[:excess]...)
+				t.held = append([]byte(nil), t.held[excess:]...)
+			}
+		default:
+			t.ready = append(append(t.ready, t.held...), frame...)
+			t.held = nil
+		}
+	}
+
+	if t.done {
+		t.trimmedLeading += len(t.pad)
+		t.trimmedTrailing += len(t.held)
+		t.pad, t.held = nil, nil
+	}
+	return nil
+}
+
+// rms returns the root mean square amplitude of 16-bit little-endian samples
+func rms(pcm []byte) float64 {
+	samples := len(pcm) / 2
+	if samples == 0 {
+		return 0
+	}
+	var sum float64
+	for i := 0; i+1 < len(pcm); i += 2 {
+		sample := float64(int16(uint16(pcm[i]) | uint16(pcm[i+1])<<8))
+		sum += sample * sample
+	}
+	return math.Sqrt(sum / float64(samples))
+}
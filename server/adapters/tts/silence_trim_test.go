@@ -0,0 +1,138 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+const trimTestRate = 16000
+
+// pcmSegment is synthetic 16-bit PCM: a tone of amplitude, or faint noise
+// when amplitude is small
+type pcmSegment struct {
+	duration  time.Duration
+	amplitude float64
+}
+
+func synthesizePCM(segments ...pcmSegment) []byte {
+	var pcm []byte
+	for _, segment := range segments {
+		samples := pcmBytes(trimTestRate, segment.duration) / 2
+		for i := 0; i < samples; i++ {
+			sample := int16(segment.amplitude * math.Sin(2*math.Pi*440*float64(i)/trimTestRate))
+			pcm = append(pcm, byte(sample), byte(uint16(sample)>>8))
+		}
+	}
+	return pcm
+}
+
+// pcmDuration returns how long bytes of PCM at trimTestRate last
+func pcmDuration(bytes int) time.Duration {
+	return time.Duration(bytes/2) * time.Second / trimTestRate
+}
+
+func trimAll(t *testing.T, pcm []byte) []byte {
+	t.Helper()
+	trimmed, err := io.ReadAll(newSilenceTrimmer(bytes.NewReader(pcm), trimTestRate, defaultSilenceThreshold, defaultSilenceMaxLeading, defaultSilenceMaxTrailing))
+	if err != nil {
+		t.Fatalf("Failed to trim: %v", err)
+	}
+	return trimmed
+}
+
+func assertDuration(t *testing.T, got, want time.Duration) {
+	t.Helper()
+	// One frame of tolerance on each end
+	if tolerance := 2 * silenceFrame; got < want || got > want+tolerance {
+		t.Errorf("Expected %v of audio within %v, got %v", want, tolerance, got)
+	}
+}
+
+func TestSilenceTrimmer_TrimsLeadingAndTrailingSilence(t *testing.T) {
+	pcm := synthesizePCM(
+		pcmSegment{200 * time.Millisecond, 40},
+		pcmSegment{300 * time.Millisecond, 8000},
+		pcmSegment{250 * time.Millisecond, 40},
+	)
+
+	trimmed := trimAll(t, pcm)
+
+	assertDuration(t, pcmDuration(len(trimmed)), 300*time.Millisecond)
+	if rms(trimmed[len(trimmed)-pcmBytes(trimTestRate, silenceFrame):]) < defaultSilenceThreshold {
+		t.Errorf("Expected the trimmed audio to end with the tone")
+	}
+}
+
+func TestSilenceTrimmer_KeepsPausesAndBoundsLeadingTrim(t *testing.T) {
+	pcm := synthesizePCM(
+		pcmSegment{800 * time.Millisecond, 0},
+		pcmSegment{100 * time.Millisecond, 8000},
+		pcmSegment{700 * time.Millisecond, 0},
+		pcmSegment{100 * time.Millisecond, 8000},
+	)
+
+	trimmed := trimAll(t, pcm)
+
+	// 500ms of the leading silence, none of the pause
+	assertDuration(t, pcmDuration(len(trimmed)), 1200*time.Millisecond)
+}
+
+func TestSilenceTrimmer_KeepsSilentStreamEmpty(t *testing.T) {
+	if trimmed := trimAll(t, synthesizePCM(pcmSegment{200 * time.Millisecond, 0})); len(trimmed) != 0 {
+		t.Errorf("Expected a silent stream trimmed away, got %d bytes", len(trimmed))
+	}
+}
+
+func TestElevenLabsTTS_ConvertTextToSpeech_SilenceTrim(t *testing.T) {
+	pcm := synthesizePCM(
+		pcmSegment{200 * time.Millisecond, 0},
+		pcmSegment{300 * time.Millisecond, 8000},
+		pcmSegment{200 * time.Millisecond, 0},
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pcm)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		outputFormat string
+		want         time.Duration
+	}{
+		{"pcm_16000", 300 * time.Millisecond},
+		// Not PCM, streamed as it is
+		{"mp3_44100_128", pcmDuration(len(pcm))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.outputFormat, func(t *testing.T) {
+			tts, err := NewElevenLabsTTS(ElevenLabsConfig{
+				APIKey:       "test-api-key",
+				APIBaseURL:   server.URL,
+				OutputFormat: tt.outputFormat,
+				SilenceTrim:  true,
+			}, zaptest.NewLogger(t))
+			if err != nil {
+				t.Fatalf("Failed to create ElevenLabsTTS: %v", err)
+			}
+
+			audioChan, err := tts.ConvertTextToSpeech(context.Background(), "Halo")
+			if err != nil {
+				t.Fatalf("Failed to convert text to speech: %v", err)
+			}
+			total := 0
+			for chunk := range audioChan {
+				total += len(chunk)
+			}
+
+			assertDuration(t, pcmDuration(total), tt.want)
+		})
+	}
+}
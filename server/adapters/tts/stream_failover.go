@@ -0,0 +1,111 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultStreamFailoverMaxAudio is the default audio a failed stream may have
+// delivered for its response to be synthesized again
+const defaultStreamFailoverMaxAudio = 2 * time.Second
+
+// ttsStreamFailovers counts the responses synthesized again without streaming
+// after their stream failed
+var ttsStreamFailovers = expvar.NewInt("tts_stream_failovers")
+
+// streamReader reads an audio stream, keeping the error of a stream that
+// failed midway apart from its end
+type streamReader struct {
+	reader io.Reader
+	err    error
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err != nil && err != io.EOF && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// failure returns the error the stream failed with, nil when it did not
+func (r *streamReader) failure() error {
+	return r.err
+}
+
+// bytesPerSecond returns the bytes of a second of audio in format, 0 for
+// compressed formats whose bytes do not map to time
+func (f outputFormat) bytesPerSecond() int {
+	switch {
+	case f.pcm():
+		return f.SampleRate * 2
+	case f.Container == "raw":
+		return f.SampleRate
+	}
+	return 0
+}
+
+// canFailover reports whether a response whose stream failed after sent bytes
+// is synthesized again. Past StreamFailoverMaxAudio the child heard most of
+// it, and a compressed stream can only be recovered before its first byte,
+// since the bytes of another synthesis do not continue it.
+func (e *ElevenLabsTTS) canFailover(ctx context.Context, format outputFormat, sent int) bool {
+	if !e.streamFailover || ctx.Err() != nil {
+		return false
+	}
+	if sent == 0 {
+		return true
+	}
+	perSecond := format.bytesPerSecond()
+	return perSecond > 0 && sent <= int(e.streamFailoverMaxAudio.Seconds()*float64(perSecond))
+}
+
+// failover synthesizes the response of a failed stream again with the
+// non-streaming endpoint, sending audioChan what follows the sent bytes the
+// stream delivered so that none of the audio is played twice
+func (e *ElevenLabsTTS) failover(ctx context.Context, voiceID, outputFormat string, format outputFormat, requestBody []byte, chunkSize, sent int, audioChan chan<- []byte) {
+	ttsStreamFailovers.Add(1)
+	e.logger.Warn("Eleven Labs stream failed, synthesizing the response again without streaming",
+		zap.String("event", "tts_stream_failover"),
+		zap.Int("sentBytes", sent))
+
+	url := fmt.Sprintf("%s/text-to-speech/%s?output_format=%s&enable_logging=false",
+		e.apiBaseURL, voiceID, outputFormat)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(requestBody))
+	if err != nil {
+		e.logger.Error("Failed to create failover HTTP request", zap.Error(err))
+		return
+	}
+	httpReq.Header.Set("Accept", format.Accept)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("xi-api-key", e.apiKey)
+
+	client := &http.Client{
+		Timeout: 60 * time.Second,
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		e.logger.Error("Failed to execute failover HTTP request", zap.Error(err))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errorBody, _ := io.ReadAll(resp.Body)
+		e.logger.Error("Eleven Labs API returned error to the failover request",
+			zap.Int("statusCode", resp.StatusCode),
+			zap.String("body", string(errorBody)))
+		return
+	}
+
+	if _, err := e.streamAudio(ctx, resp.Body, format, chunkSize, sent, audioChan); err != nil {
+		e.logger.Error("Failover synthesis failed too", zap.Error(err))
+	}
+}
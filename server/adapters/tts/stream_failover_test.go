@@ -0,0 +1,104 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// newFailingStreamServer serves audio whose stream breaks after failAfter
+// bytes, and whole from the non-streaming endpoint. It counts the requests
+// to the non-streaming endpoint in failovers.
+func newFailingStreamServer(audio []byte, failAfter int, failovers *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/pcm")
+		w.Header().Set("Content-Length", strconv.Itoa(len(audio)))
+		if !strings.HasSuffix(r.URL.Path, "/stream") {
+			atomic.AddInt32(failovers, 1)
+			w.Write(audio)
+			return
+		}
+		w.Write(audio[:failAfter])
+		w.(http.Flusher).Flush()
+		// Drop the connection before the announced length was written
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+}
+
+func TestElevenLabsTTS_FailsOverToNonStreamingSynthesis(t *testing.T) {
+	// 200ms of 16-bit audio at 24 kHz, whose bytes tell where they are
+	audio := make([]byte, 9600)
+	for i := range audio {
+		audio[i] = byte(i / 100)
+	}
+
+	tests := []struct {
+		name          string
+		config        ElevenLabsConfig
+		want          []byte
+		wantFailovers int32
+	}{
+		{"disabled", ElevenLabsConfig{}, audio[:2000], 0},
+		{"enabled", ElevenLabsConfig{StreamFailover: true}, audio, 1},
+		// 2000 bytes are 41ms of audio, the child heard too much of it
+		{"past the max audio", ElevenLabsConfig{StreamFailover: true, StreamFailoverMaxAudio: 40 * time.Millisecond}, audio[:2000], 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var failovers int32
+			server := newFailingStreamServer(audio, 2000, &failovers)
+			defer server.Close()
+
+			config := tt.config
+			config.APIKey = "test-api-key"
+			config.APIBaseURL = server.URL
+			tts, err := NewElevenLabsTTS(config, zaptest.NewLogger(t))
+			if err != nil {
+				t.Fatalf("Failed to create ElevenLabsTTS: %v", err)
+			}
+
+			audioChan, err := tts.ConvertTextToSpeech(context.Background(), "Halo")
+			if err != nil {
+				t.Fatalf("Failed to convert text to speech: %v", err)
+			}
+			var got []byte
+			for chunk := range audioChan {
+				got = append(got, chunk...)
+			}
+
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Expected %d bytes of the audio in order, got %d bytes", len(tt.want), len(got))
+			}
+			if failovers != tt.wantFailovers {
+				t.Errorf("Expected %d failover requests, got %d", tt.wantFailovers, failovers)
+			}
+		})
+	}
+}
+
+func TestElevenLabsTTS_FailsOverCompressedStreamOnlyBeforeItsAudio(t *testing.T) {
+	format := outputFormats["mp3_44100_128"]
+	tts := &ElevenLabsTTS{streamFailover: true, streamFailoverMaxAudio: time.Second}
+
+	if !tts.canFailover(context.Background(), format, 0) {
+		t.Error("Expected a failover of a stream that delivered no audio")
+	}
+	if tts.canFailover(context.Background(), format, 100) {
+		t.Error("Expected no failover once compressed audio was delivered")
+	}
+	if !tts.canFailover(context.Background(), outputFormats["ulaw_8000"], 8000) {
+		t.Error("Expected a failover of a second of 8-bit audio")
+	}
+}
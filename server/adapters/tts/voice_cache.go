@@ -0,0 +1,157 @@
+package tts
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// Ensure ElevenLabsTTS implements the VoiceValidator and
+// VoiceLanguageValidator interfaces
+var (
+	_ repositories.VoiceValidator         = (*ElevenLabsTTS)(nil)
+	_ repositories.VoiceLanguageValidator = (*ElevenLabsTTS)(nil)
+)
+
+// voiceCache remembers the voices of the account between refreshes, so that
+// validating voice IDs and listing voices read from memory
+type voiceCache struct {
+	mu        sync.Mutex
+	voices    []map[string]interface{}
+	ids       map[string]bool
+	fetchedAt time.Time
+}
+
+// store replaces the cached voices with those listed by the API
+func (c *voiceCache) store(voices []map[string]interface{}, now time.Time) {
+	ids := make(map[string]bool, len(voices))
+	for _, raw := range voices {
+		if id, _ := raw["voice_id"].(string); id != "" {
+			ids[id] = true
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.voices = voices
+	c.ids = ids
+	c.fetchedAt = now
+}
+
+// add records a voice created since the last refresh
+func (c *voiceCache) add(voice map[string]interface{}) {
+	id, _ := voice["voice_id"].(string)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ids == nil || id == "" {
+		return
+	}
+	// Copy so that lists already handed out stay as they were
+	c.voices = append(c.voices[:len(c.voices):len(c.voices)], voice)
+	c.ids[id] = true
+}
+
+// snapshot returns the cached voices, whether there are any, and whether
+// they are fresh
+func (c *voiceCache) snapshot(now time.Time, ttl time.Duration) (voices []map[string]interface{}, cached, fresh bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.voices, c.ids != nil, c.ids != nil && now.Sub(c.fetchedAt) < ttl
+}
+
+// lookup reports whether voiceID is cached
+func (c *voiceCache) lookup(voiceID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ids[voiceID]
+}
+
+// cachedVoices returns the voices of the account, listing them again when
+// they are older than VoiceCacheTTL. When a refresh fails the previous list
+// is used.
+func (e *ElevenLabsTTS) cachedVoices(ctx context.Context) ([]map[string]interface{}, error) {
+	if voices, _, fresh := e.voiceCache.snapshot(e.now(), e.voiceCacheTTL); fresh {
+		return voices, nil
+	}
+
+	// One refresh at a time; the others wait for its result
+	e.voiceRefreshMu.Lock()
+	defer e.voiceRefreshMu.Unlock()
+	voices, cached, fresh := e.voiceCache.snapshot(e.now(), e.voiceCacheTTL)
+	if fresh {
+		return voices, nil
+	}
+
+	refreshed, err := e.GetAvailableVoices(ctx)
+	if err != nil {
+		if !cached {
+			return nil, err
+		}
+		e.logger.Warn("Failed to refresh voices, using the cached list", zap.Error(err))
+		return voices, nil
+	}
+	return refreshed, nil
+}
+
+// VoiceExists implements repositories.VoiceValidator. The voices of the
+// account are listed at most once per VoiceCacheTTL; when a refresh fails
+// the previous list is used.
+func (e *ElevenLabsTTS) VoiceExists(ctx context.Context, voiceID string) (bool, error) {
+	if _, err := e.cachedVoices(ctx); err != nil {
+		return false, err
+	}
+	return e.voiceCache.lookup(voiceID), nil
+}
+
+// VoiceSupportsLanguage implements repositories.VoiceLanguageValidator from
+// the languages in the metadata of the cached voices. A voice that is not
+// cached, or whose metadata names no language, supports any.
+func (e *ElevenLabsTTS) VoiceSupportsLanguage(ctx context.Context, voiceID, language string) (bool, error) {
+	voices, err := e.cachedVoices(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, raw := range voices {
+		if id, _ := raw["voice_id"].(string); id == voiceID {
+			return parseVoice(raw).SupportsLanguage(language), nil
+		}
+	}
+	return true, nil
+}
+
+// RefreshVoices implements repositories.VoiceManager by listing the voices
+// of the account again, whatever the age of the cached list. When the API
+// fails the cached list is kept.
+func (e *ElevenLabsTTS) RefreshVoices(ctx context.Context) (int, error) {
+	e.voiceRefreshMu.Lock()
+	defer e.voiceRefreshMu.Unlock()
+	voices, err := e.GetAvailableVoices(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return len(voices), nil
+}
+
+// RefreshVoicesPeriodically refreshes the cached voices in the background
+// every half VoiceCacheTTL until ctx is done, so that conversations never
+// wait for the list to be fetched while the API is reachable. Failed
+// refreshes are logged and leave the cached list in place.
+func (e *ElevenLabsTTS) RefreshVoicesPeriodically(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(e.voiceCacheTTL / 2)
+		defer ticker.Stop()
+		for {
+			if _, err := e.RefreshVoices(ctx); err != nil && ctx.Err() == nil {
+				e.logger.Warn("Failed to refresh voices, keeping the cached list", zap.Error(err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
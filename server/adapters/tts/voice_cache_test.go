@@ -0,0 +1,186 @@
+package tts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+func TestElevenLabsTTS_VoiceExists(t *testing.T) {
+	server := newVoiceServer()
+	defer server.Close()
+
+	tts, err := NewElevenLabsTTS(ElevenLabsConfig{APIKey: "key", APIBaseURL: server.URL, VoiceCacheTTL: time.Minute}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create TTS: %v", err)
+	}
+	now := time.Now()
+	tts.now = func() time.Time { return now }
+
+	tests := []struct {
+		voiceID string
+		want    bool
+	}{
+		{"premade-1", true},
+		{"other-clone", true},
+		{"deleted-clone", false},
+	}
+	for _, tt := range tests {
+		exists, err := tts.VoiceExists(context.Background(), tt.voiceID)
+		if err != nil {
+			t.Fatalf("Failed to validate %s: %v", tt.voiceID, err)
+		}
+		if exists != tt.want {
+			t.Errorf("Expected VoiceExists(%s) = %v, got %v", tt.voiceID, tt.want, exists)
+		}
+	}
+	if server.listCount() != 1 {
+		t.Errorf("Expected the voices to be listed once, got %d", server.listCount())
+	}
+
+	// A voice created through the adapter is valid before the next refresh
+	if _, err := tts.CreateVoice(context.Background(), "owner-1", "Mama", []repositories.VoiceSample{{Filename: "mama.wav", Data: []byte{0x01, 0x02}}}); err != nil {
+		t.Fatalf("Failed to create voice: %v", err)
+	}
+	if exists, _ := tts.VoiceExists(context.Background(), "clone-Mama"); !exists {
+		t.Error("Expected the new voice to be valid")
+	}
+
+	// After the TTL the list is fetched again
+	server.mu.Lock()
+	server.voices = server.voices[:1]
+	server.mu.Unlock()
+	lists := server.listCount()
+	now = now.Add(time.Minute)
+	if exists, _ := tts.VoiceExists(context.Background(), "other-clone"); exists {
+		t.Error("Expected a voice deleted upstream to be invalid after the TTL")
+	}
+	if server.listCount() != lists+1 {
+		t.Errorf("Expected one refresh after the TTL, got %d", server.listCount()-lists)
+	}
+}
+
+func TestElevenLabsTTS_VoiceExistsKeepsListWhenRefreshFails(t *testing.T) {
+	server := newVoiceServer()
+
+	tts, err := NewElevenLabsTTS(ElevenLabsConfig{APIKey: "key", APIBaseURL: server.URL, VoiceCacheTTL: time.Minute}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create TTS: %v", err)
+	}
+	now := time.Now()
+	tts.now = func() time.Time { return now }
+
+	if exists, err := tts.VoiceExists(context.Background(), "premade-1"); err != nil || !exists {
+		t.Fatalf("Expected the premade voice to exist, got %v, %v", exists, err)
+	}
+
+	server.Close()
+	now = now.Add(time.Minute)
+	if exists, err := tts.VoiceExists(context.Background(), "premade-1"); err != nil || !exists {
+		t.Errorf("Expected the stale list to be used, got %v, %v", exists, err)
+	}
+}
+
+func TestElevenLabsTTS_CachedVoicesAvoidAPICalls(t *testing.T) {
+	server := newVoiceServer()
+	defer server.Close()
+
+	tts, err := NewElevenLabsTTS(ElevenLabsConfig{APIKey: "key", APIBaseURL: server.URL, VoiceCacheTTL: time.Minute}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create TTS: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := tts.ListVoices(context.Background(), "owner-2"); err != nil {
+			t.Fatalf("Failed to list voices: %v", err)
+		}
+		if exists, err := tts.VoiceExists(context.Background(), "premade-1"); err != nil || !exists {
+			t.Fatalf("Expected the premade voice to exist, got %v, %v", exists, err)
+		}
+	}
+	if server.listCount() != 1 {
+		t.Errorf("Expected listing and validation to share one API call, got %d", server.listCount())
+	}
+
+	// A created voice is listed from the cache too
+	if _, err := tts.CreateVoice(context.Background(), "owner-1", "Mama", []repositories.VoiceSample{{Filename: "mama.wav", Data: []byte{0x01}}}); err != nil {
+		t.Fatalf("Failed to create voice: %v", err)
+	}
+	lists := server.listCount()
+	voices, err := tts.ListVoices(context.Background(), "owner-1")
+	if err != nil {
+		t.Fatalf("Failed to list voices: %v", err)
+	}
+	if len(voices) != 2 || voices[1].ID != "clone-Mama" || voices[1].OwnerID != "owner-1" {
+		t.Errorf("Expected the premade and the new voice, got %+v", voices)
+	}
+	if server.listCount() != lists {
+		t.Errorf("Expected the voices to be listed from the cache, got %d API calls", server.listCount()-lists)
+	}
+}
+
+func TestElevenLabsTTS_RefreshVoices(t *testing.T) {
+	server := newVoiceServer()
+	defer server.Close()
+
+	tts, err := NewElevenLabsTTS(ElevenLabsConfig{APIKey: "key", APIBaseURL: server.URL, VoiceCacheTTL: time.Hour}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create TTS: %v", err)
+	}
+	if exists, _ := tts.VoiceExists(context.Background(), "other-clone"); !exists {
+		t.Fatal("Expected the clone to exist")
+	}
+
+	// Deleted upstream: the cache still has it until refreshed
+	server.mu.Lock()
+	server.voices = server.voices[:1]
+	server.mu.Unlock()
+	if exists, _ := tts.VoiceExists(context.Background(), "other-clone"); !exists {
+		t.Fatal("Expected the cached list to be used before the refresh")
+	}
+
+	count, err := tts.RefreshVoices(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to refresh voices: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 voice after the refresh, got %d", count)
+	}
+	if exists, _ := tts.VoiceExists(context.Background(), "other-clone"); exists {
+		t.Error("Expected the refresh to drop the deleted voice")
+	}
+
+	// A failed refresh keeps the cached list
+	server.Close()
+	if _, err := tts.RefreshVoices(context.Background()); err == nil {
+		t.Error("Expected the refresh to fail with the API down")
+	}
+	if exists, err := tts.VoiceExists(context.Background(), "premade-1"); err != nil || !exists {
+		t.Errorf("Expected the cached list to survive a failed refresh, got %v, %v", exists, err)
+	}
+}
+
+func TestElevenLabsTTS_RefreshVoicesPeriodically(t *testing.T) {
+	server := newVoiceServer()
+	defer server.Close()
+
+	tts, err := NewElevenLabsTTS(ElevenLabsConfig{APIKey: "key", APIBaseURL: server.URL, VoiceCacheTTL: 20 * time.Millisecond}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create TTS: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	tts.RefreshVoicesPeriodically(ctx)
+	defer cancel()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.listCount() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the voices to be refreshed in the background, got %d refreshes", server.listCount())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,206 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// ownerLabel is the voice label recording which account created a custom voice
+const ownerLabel = "arunika_owner"
+
+// Ensure ElevenLabsTTS implements the VoiceManager interface
+var _ repositories.VoiceManager = (*ElevenLabsTTS)(nil)
+
+// ListVoices implements repositories.VoiceManager. Premade voices are shared
+// by every account; custom voices are only listed for the account that
+// created them. The voices are read from the cache kept by VoiceExists.
+func (e *ElevenLabsTTS) ListVoices(ctx context.Context, ownerID string) ([]entities.Voice, error) {
+	available, err := e.cachedVoices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	voices := []entities.Voice{}
+	for _, raw := range available {
+		voice := parseVoice(raw)
+		if voice.ID == "" {
+			continue
+		}
+		if voice.Category == "premade" || (voice.Custom() && voice.OwnerID == ownerID) {
+			voices = append(voices, voice)
+		}
+	}
+	return voices, nil
+}
+
+// CreateVoice implements repositories.VoiceManager by cloning a voice through
+// the Eleven Labs voice management API
+func (e *ElevenLabsTTS) CreateVoice(ctx context.Context, ownerID, name string, samples []repositories.VoiceSample) (*entities.Voice, error) {
+	if ownerID == "" {
+		return nil, fmt.Errorf("owner ID cannot be empty")
+	}
+	if name == "" {
+		return nil, fmt.Errorf("voice name cannot be empty")
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("at least one voice sample is required")
+	}
+
+	// Serialize creation so that concurrent requests cannot exceed the limit
+	e.voiceMu.Lock()
+	defer e.voiceMu.Unlock()
+
+	voices, err := e.ListVoices(ctx, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count custom voices: %w", err)
+	}
+	owned := 0
+	for _, voice := range voices {
+		if voice.Custom() {
+			owned++
+		}
+	}
+	if owned >= e.maxCustomVoices {
+		return nil, repositories.ErrVoiceLimitReached
+	}
+
+	body, contentType, err := voiceForm(ownerID, name, samples)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/voices/add", e.apiBaseURL)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	httpReq.Header.Set("xi-api-key", e.apiKey)
+
+	client := &http.Client{
+		Timeout: 60 * time.Second, // Uploading samples can take a while
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errorBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API returned error %d: %s", resp.StatusCode, string(errorBody))
+	}
+
+	var created struct {
+		VoiceID string `json:"voice_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	e.voiceCache.add(map[string]interface{}{
+		"voice_id": created.VoiceID,
+		"name":     name,
+		"category": "cloned",
+		"labels":   map[string]interface{}{ownerLabel: ownerID},
+	})
+
+	e.logger.Info("Created custom voice",
+		zap.String("voiceID", created.VoiceID),
+		zap.String("ownerID", ownerID))
+
+	return &entities.Voice{
+		ID:       created.VoiceID,
+		Name:     name,
+		Category: "cloned",
+		OwnerID:  ownerID,
+	}, nil
+}
+
+// voiceForm encodes the multipart body of a voice creation request
+func voiceForm(ownerID, name string, samples []repositories.VoiceSample) (io.Reader, string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	if err := writer.WriteField("name", name); err != nil {
+		return nil, "", fmt.Errorf("failed to write voice name: %w", err)
+	}
+	labels, err := json.Marshal(map[string]string{ownerLabel: ownerID})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal voice labels: %w", err)
+	}
+	if err := writer.WriteField("labels", string(labels)); err != nil {
+		return nil, "", fmt.Errorf("failed to write voice labels: %w", err)
+	}
+
+	for _, sample := range samples {
+		part, err := writer.CreateFormFile("files", sample.Filename)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to add voice sample: %w", err)
+		}
+		if _, err := part.Write(sample.Data); err != nil {
+			return nil, "", fmt.Errorf("failed to write voice sample: %w", err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to encode voice form: %w", err)
+	}
+	return &body, writer.FormDataContentType(), nil
+}
+
+// parseVoice extracts the fields used by voice management from a voice
+// returned by GetAvailableVoices
+func parseVoice(raw map[string]interface{}) entities.Voice {
+	voice := entities.Voice{}
+	voice.ID, _ = raw["voice_id"].(string)
+	voice.Name, _ = raw["name"].(string)
+	voice.Category, _ = raw["category"].(string)
+	if labels, ok := raw["labels"].(map[string]interface{}); ok {
+		voice.OwnerID, _ = labels[ownerLabel].(string)
+	}
+	voice.Languages = voiceLanguages(raw)
+	return voice
+}
+
+// voiceLanguages returns the base languages a voice returned by
+// GetAvailableVoices speaks: those it was verified in, and the language of
+// its labels
+func voiceLanguages(raw map[string]interface{}) []string {
+	var languages []string
+	seen := make(map[string]bool)
+	add := func(language string) {
+		language = baseLanguage(strings.TrimSpace(language))
+		if language != "" && !seen[language] {
+			seen[language] = true
+			languages = append(languages, language)
+		}
+	}
+
+	if verified, ok := raw["verified_languages"].([]interface{}); ok {
+		for _, entry := range verified {
+			if entry, ok := entry.(map[string]interface{}); ok {
+				language, _ := entry["language"].(string)
+				add(language)
+			}
+		}
+	}
+	if labels, ok := raw["labels"].(map[string]interface{}); ok {
+		language, _ := labels["language"].(string)
+		add(language)
+	}
+	return languages
+}
@@ -0,0 +1,188 @@
+package tts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// voiceServer mocks the Eleven Labs voice management endpoints
+type voiceServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	voices []map[string]interface{}
+	lists  int
+}
+
+func newVoiceServer() *voiceServer {
+	s := &voiceServer{
+		voices: []map[string]interface{}{
+			{"voice_id": "premade-1", "name": "Rachel", "category": "premade"},
+			{"voice_id": "other-clone", "name": "Papa", "category": "cloned", "labels": map[string]interface{}{ownerLabel: "owner-2"}},
+		},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /voices", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.lists++
+		json.NewEncoder(w).Encode(map[string]interface{}{"voices": s.voices})
+	})
+	mux.HandleFunc("POST /voices/add", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil || len(r.MultipartForm.File["files"]) == 0 {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		var labels map[string]interface{}
+		json.Unmarshal([]byte(r.FormValue("labels")), &labels)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		id := "clone-" + r.FormValue("name")
+		s.voices = append(s.voices, map[string]interface{}{
+			"voice_id": id, "name": r.FormValue("name"), "category": "cloned", "labels": labels,
+		})
+		json.NewEncoder(w).Encode(map[string]string{"voice_id": id})
+	})
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// listCount returns how many times the voices were listed
+func (s *voiceServer) listCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lists
+}
+
+func TestElevenLabsTTS_ListVoices(t *testing.T) {
+	server := newVoiceServer()
+	defer server.Close()
+
+	tts, err := NewElevenLabsTTS(ElevenLabsConfig{APIKey: "key", APIBaseURL: server.URL}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create TTS: %v", err)
+	}
+
+	voices, err := tts.ListVoices(context.Background(), "owner-1")
+	if err != nil {
+		t.Fatalf("Failed to list voices: %v", err)
+	}
+	if len(voices) != 1 || voices[0].ID != "premade-1" {
+		t.Errorf("Expected only the premade voice for an account without clones, got %+v", voices)
+	}
+}
+
+func TestElevenLabsTTS_CreateVoice(t *testing.T) {
+	server := newVoiceServer()
+	defer server.Close()
+
+	tts, err := NewElevenLabsTTS(ElevenLabsConfig{APIKey: "key", APIBaseURL: server.URL, MaxCustomVoices: 1}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create TTS: %v", err)
+	}
+	samples := []repositories.VoiceSample{{Filename: "mama.wav", Data: []byte{0x01, 0x02}}}
+
+	voice, err := tts.CreateVoice(context.Background(), "owner-1", "mama", samples)
+	if err != nil {
+		t.Fatalf("Failed to create voice: %v", err)
+	}
+	if voice.ID != "clone-mama" || voice.OwnerID != "owner-1" {
+		t.Errorf("Unexpected voice: %+v", voice)
+	}
+
+	voices, _ := tts.ListVoices(context.Background(), "owner-1")
+	if len(voices) != 2 {
+		t.Errorf("Expected the new clone to be listed for its owner, got %+v", voices)
+	}
+
+	if _, err := tts.CreateVoice(context.Background(), "owner-1", "nenek", samples); !errors.Is(err, repositories.ErrVoiceLimitReached) {
+		t.Errorf("Expected the voice limit to be enforced, got %v", err)
+	}
+	// The limit is per account
+	if _, err := tts.CreateVoice(context.Background(), "owner-3", "nenek", samples); err != nil {
+		t.Errorf("Expected another account to create a voice, got %v", err)
+	}
+}
+
+func TestElevenLabsTTS_SynthesizesWithContextVoice(t *testing.T) {
+	paths := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		w.Write([]byte{0x01})
+	}))
+	defer server.Close()
+
+	tts, err := NewElevenLabsTTS(ElevenLabsConfig{APIKey: "key", APIBaseURL: server.URL}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create TTS: %v", err)
+	}
+
+	ctx := repositories.WithVoiceID(context.Background(), "clone-mama")
+	audio, err := tts.ConvertTextToSpeech(ctx, "Halo")
+	if err != nil {
+		t.Fatalf("Failed to convert text: %v", err)
+	}
+	for range audio {
+	}
+
+	if gotPath := <-paths; gotPath != "/text-to-speech/clone-mama/stream" {
+		t.Errorf("Expected synthesis with the custom voice, got %s", gotPath)
+	}
+}
+
+func TestElevenLabsTTS_VoiceSupportsLanguage(t *testing.T) {
+	server := newVoiceServer()
+	defer server.Close()
+	server.voices = append(server.voices,
+		map[string]interface{}{"voice_id": "english-only", "name": "Adam", "category": "premade",
+			"labels": map[string]interface{}{"language": "en", "accent": "american"}},
+		map[string]interface{}{"voice_id": "bilingual", "name": "Sari", "category": "premade",
+			"verified_languages": []interface{}{
+				map[string]interface{}{"language": "id", "locale": "id-ID"},
+				map[string]interface{}{"language": "en", "locale": "en-US"},
+			}},
+	)
+
+	tts, err := NewElevenLabsTTS(ElevenLabsConfig{APIKey: "key", APIBaseURL: server.URL}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create TTS: %v", err)
+	}
+
+	tests := []struct {
+		voiceID  string
+		language string
+		want     bool
+	}{
+		{"english-only", "en-GB", true},
+		{"english-only", "id-ID", false},
+		{"bilingual", "id-ID", true},
+		{"premade-1", "id-ID", true}, // No language in its metadata
+		{"unknown", "id-ID", true},
+	}
+	for _, tt := range tests {
+		supported, err := tts.VoiceSupportsLanguage(context.Background(), tt.voiceID, tt.language)
+		if err != nil {
+			t.Fatalf("Failed to check voice language: %v", err)
+		}
+		if supported != tt.want {
+			t.Errorf("Expected %s speaking %s to be %v, got %v", tt.voiceID, tt.language, tt.want, supported)
+		}
+	}
+
+	voices, _ := tts.ListVoices(context.Background(), "owner-1")
+	for _, voice := range voices {
+		if voice.ID == "bilingual" && (len(voice.Languages) != 2 || voice.Languages[0] != "id" || voice.Languages[1] != "en") {
+			t.Errorf("Expected the verified languages listed, got %v", voice.Languages)
+		}
+	}
+}
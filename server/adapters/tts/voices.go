@@ -0,0 +1,79 @@
+package tts
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ElevenLabsVoice selects the voice and model synthesizing one language
+type ElevenLabsVoice struct {
+	VoiceID      string `json:"voice_id"`
+	ModelID      string `json:"model_id,omitempty"`
+	LanguageCode string `json:"language_code,omitempty"`
+}
+
+// languageCodeModels lists the models that accept an explicit language_code
+var languageCodeModels = map[string]bool{
+	"eleven_flash_v2_5": true,
+	"eleven_turbo_v2_5": true,
+	"eleven_v3":         true,
+}
+
+// ParseElevenLabsVoices parses a language to voice mapping encoded as JSON,
+// e.g. {"id": {"voice_id": "abc", "model_id": "eleven_flash_v2_5"}}.
+// Keys are BCP-47 languages ("id-ID") or their base language ("id").
+func ParseElevenLabsVoices(data string) (map[string]ElevenLabsVoice, error) {
+	var voices map[string]ElevenLabsVoice
+	if err := json.Unmarshal([]byte(data), &voices); err != nil {
+		return nil, fmt.Errorf("failed to parse language voices: %w", err)
+	}
+
+	normalized := make(map[string]ElevenLabsVoice, len(voices))
+	for language, voice := range voices {
+		normalized[strings.ToLower(language)] = voice
+	}
+	return normalized, nil
+}
+
+// baseLanguage returns the primary language subtag of a BCP-47 tag
+func baseLanguage(language string) string {
+	base, _, _ := strings.Cut(strings.ToLower(language), "-")
+	return base
+}
+
+// resolveVoice picks the voice, model and language code used to synthesize
+// language. An exact mapping wins over the base language mapping, and
+// unmapped languages fall back to the configured voice and model.
+func (e *ElevenLabsTTS) resolveVoice(language string) ElevenLabsVoice {
+	voice := ElevenLabsVoice{
+		VoiceID: e.voiceID,
+		ModelID: e.modelID,
+	}
+	if language == "" {
+		return voice
+	}
+
+	mapped, ok := e.voices[strings.ToLower(language)]
+	if !ok {
+		mapped, ok = e.voices[baseLanguage(language)]
+	}
+	if ok {
+		if mapped.VoiceID != "" {
+			voice.VoiceID = mapped.VoiceID
+		}
+		if mapped.ModelID != "" {
+			voice.ModelID = mapped.ModelID
+		}
+		voice.LanguageCode = mapped.LanguageCode
+	}
+
+	if !languageCodeModels[voice.ModelID] {
+		// The model would reject the field
+		voice.LanguageCode = ""
+	} else if voice.LanguageCode == "" {
+		voice.LanguageCode = baseLanguage(language)
+	}
+
+	return voice
+}
@@ -0,0 +1,104 @@
+package tts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+func TestParseElevenLabsVoices(t *testing.T) {
+	voices, err := ParseElevenLabsVoices(`{"ID-id": {"voice_id": "voice-id", "model_id": "eleven_flash_v2_5"}}`)
+	if err != nil {
+		t.Fatalf("Failed to parse voices: %v", err)
+	}
+	if voices["id-id"].VoiceID != "voice-id" {
+		t.Errorf("Expected keys to be normalized, got %v", voices)
+	}
+
+	if _, err := ParseElevenLabsVoices("not json"); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+}
+
+// A mistyped mapping fails startup instead of silently using one voice for
+// every language
+func TestNewElevenLabsTTS_RejectsInvalidLanguageVoices(t *testing.T) {
+	t.Setenv("ELEVEN_LABS_API_KEY", "test-api-key")
+	t.Setenv("ELEVEN_LABS_LANGUAGE_VOICES", `{"id": {"voice_id": "voice-id"}`)
+
+	if _, err := NewElevenLabsTTS(NewElevenLabsConfigFromEnv(), zaptest.NewLogger(t)); err == nil {
+		t.Error("Expected error for invalid ELEVEN_LABS_LANGUAGE_VOICES")
+	}
+}
+
+func TestElevenLabsTTS_SelectsVoicePerLanguage(t *testing.T) {
+	var gotPath string
+	var gotRequest ElevenLabsRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotRequest = ElevenLabsRequest{}
+		json.NewDecoder(r.Body).Decode(&gotRequest)
+		w.Write([]byte{0x01})
+	}))
+	defer server.Close()
+
+	tts, err := NewElevenLabsTTS(ElevenLabsConfig{
+		APIKey:     "test-api-key",
+		APIBaseURL: server.URL,
+		VoiceID:    "default-voice",
+		ModelID:    "eleven_flash_v2_5",
+		Voices: map[string]ElevenLabsVoice{
+			"id":    {VoiceID: "indonesian-voice"},
+			"en":    {VoiceID: "english-voice", ModelID: "eleven_multilingual_v2"},
+			"en-gb": {VoiceID: "british-voice", ModelID: "eleven_turbo_v2_5", LanguageCode: "en"},
+		},
+	}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create ElevenLabsTTS: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		language     string
+		wantVoice    string
+		wantModel    string
+		wantLanguage string
+	}{
+		{"base language mapping", "id-ID", "indonesian-voice", "eleven_flash_v2_5", "id"},
+		{"model without language code support", "en-US", "english-voice", "eleven_multilingual_v2", ""},
+		{"exact mapping wins", "en-GB", "british-voice", "eleven_turbo_v2_5", "en"},
+		{"unmapped language uses default voice", "fr-FR", "default-voice", "eleven_flash_v2_5", "fr"},
+		{"no language", "", "default-voice", "eleven_flash_v2_5", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.language != "" {
+				ctx = repositories.WithLanguage(ctx, tt.language)
+			}
+
+			audioChan, err := tts.ConvertTextToSpeech(ctx, "Halo")
+			if err != nil {
+				t.Fatalf("Failed to convert text to speech: %v", err)
+			}
+			for range audioChan {
+			}
+
+			if !strings.Contains(gotPath, "/text-to-speech/"+tt.wantVoice+"/") {
+				t.Errorf("Expected voice %q, got path %q", tt.wantVoice, gotPath)
+			}
+			if gotRequest.ModelID != tt.wantModel || gotRequest.LanguageCode != tt.wantLanguage {
+				t.Errorf("Expected model %q and language %q, got %q and %q",
+					tt.wantModel, tt.wantLanguage, gotRequest.ModelID, gotRequest.LanguageCode)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"expvar"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,14 +14,27 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 
 	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/adapters/cache"
+	"github.com/satriahrh/arunika/server/adapters/deterministic"
 	"github.com/satriahrh/arunika/server/adapters/llm"
 	"github.com/satriahrh/arunika/server/adapters/mongo"
 	"github.com/satriahrh/arunika/server/adapters/stt"
 	"github.com/satriahrh/arunika/server/adapters/tts"
 	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
 	"github.com/satriahrh/arunika/server/internal/api"
+	"github.com/satriahrh/arunika/server/internal/audit"
+	"github.com/satriahrh/arunika/server/internal/auth"
+	"github.com/satriahrh/arunika/server/internal/cleanup"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/degradation"
+	"github.com/satriahrh/arunika/server/internal/events"
+	"github.com/satriahrh/arunika/server/internal/grpcserver"
+	"github.com/satriahrh/arunika/server/internal/logging"
+	"github.com/satriahrh/arunika/server/internal/webhook"
 	"github.com/satriahrh/arunika/server/internal/websocket"
 )
 
@@ -29,6 +44,8 @@ func main() {
 	// Initialize logger
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
+	// Children's speech and the doll's answers stay out of the logs unless debugging
+	logger = logging.Redact(logger, logging.NewRedactionConfigFromEnv())
 
 	// Create Echo instance
 	e := echo.New()
@@ -38,42 +55,155 @@ func main() {
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
 
-	// Initialize adapters
-	// Initialize MongoDB client
-	mongoClient, err := mongo.NewClient(logger)
-	if err != nil {
-		logger.Fatal("Failed to create MongoDB client", zap.Error(err))
-	}
-	defer func() {
-		ctx := context.Background()
-		mongoClient.Close(ctx)
-	}()
-
 	// Initialize repositories
-	sessionRepo := mongo.NewSessionRepository(mongoClient.Database)
+	var sessionRepo repositories.SessionRepository
+	var quietHoursRepo repositories.QuietHoursRepository
+	var childRepo repositories.ChildRepository
+	var sessionCache *cache.SessionCache
+	// Services conversations cannot go without, checked before devices may connect
+	var dependencies []websocket.Dependency
+	if os.Getenv("STORAGE_BACKEND") == "memory" {
+		// Run without MongoDB for development and edge deployments
+		logger.Info("Using in-memory storage, conversations are lost on restart")
+		sessionRepo = adapters.NewMemorySessionRepository(adapters.NewMemorySessionConfigFromEnv(), logger)
+		quietHoursRepo = adapters.NewMemoryQuietHoursRepository()
+		childRepo = adapters.NewMemoryChildRepository()
+	} else {
+		// Initialize MongoDB client
+		mongoClient, err := mongo.NewClient(logger)
+		if err != nil {
+			logger.Fatal("Failed to create MongoDB client", zap.Error(err))
+		}
+		defer func() {
+			ctx := context.Background()
+			mongoClient.Close(ctx)
+		}()
+		dependencies = append(dependencies, websocket.Dependency{Name: "mongodb", Checker: mongoClient})
+
+		// Transcript search and child lookups need indexes; conversations work without them
+		indexCtx, cancelIndex := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := mongo.EnsureSessionIndexes(indexCtx, mongoClient.Database); err != nil {
+			logger.Warn("Failed to ensure session indexes", zap.Error(err))
+		}
+		if err := mongo.EnsureChildIndexes(indexCtx, mongoClient.Database); err != nil {
+			logger.Warn("Failed to ensure child indexes", zap.Error(err))
+		}
+		cancelIndex()
+
+		// Serve the last session of recently active devices from memory
+		sessionCache = cache.NewSessionCache(cache.NewSessionCacheConfigFromEnv(), mongo.NewSessionRepository(mongoClient.Database), logger)
+		sessionRepo = sessionCache
+		quietHoursRepo = mongo.NewQuietHoursRepository(mongoClient.Database)
+		childRepo = mongo.NewChildRepository(mongoClient.Database)
+	}
 	deviceRepo := adapters.NewMemoryDeviceRepository()
-	sttRepo := &stt.GoogleSpeechToText{}
+	auditTrail := audit.NewZapTrail(logger)
+	eventBus := events.NewBus(logger)
+	sttRepo := stt.NewGoogleSpeechToText(stt.NewGoogleSpeechToTextConfigFromEnv(), logger)
 	ttsRepoConfig := tts.NewElevenLabsConfigFromEnv()
 	ttsRepo, err := tts.NewElevenLabsTTS(ttsRepoConfig, logger)
 	if err != nil {
 		logger.Fatal("Failed to create TTS repository", zap.Error(err))
 	}
+	// Keep the voices of the account cached so that conversations validate
+	// voice IDs without calling Eleven Labs
+	voiceRefreshCtx, stopVoiceRefresh := context.WithCancel(context.Background())
+	defer stopVoiceRefresh()
+	ttsRepo.RefreshVoicesPeriodically(voiceRefreshCtx)
 	geminiLLMRepo, err := llm.NewGeminiLLM(logger)
 	if err != nil {
 		logger.Fatal("Failed to create Gemini LLM", zap.Error(err))
 	}
 
+	// End sessions that have been idle for too long
+	sessionCleanup := cleanup.NewSessionCleanupService(cleanup.NewSessionCleanupConfigFromEnv(), sessionRepo, logger)
+	sessionCleanup.Start()
+
 	// Bootstrap with demo devices for development (in production, devices would be provisioned through separate APIs)
 	if err := bootstrapDemoDevices(deviceRepo, logger); err != nil {
 		logger.Warn("Failed to bootstrap demo devices", zap.Error(err))
 	}
 
-	// Initialize WebSocket hub with conversation service
-	hub := websocket.NewHub(geminiLLMRepo, ttsRepo, sttRepo, sessionRepo, logger)
+	// Consume session and device lifecycle events for analytics
+	go logLifecycleEvents(eventBus.Subscribe(0), logger.Named("analytics"))
+
+	// Deliver lifecycle events to integrator webhooks when configured
+	webhookCtx, stopWebhooks := context.WithCancel(context.Background())
+	defer stopWebhooks()
+	webhookConfig := webhook.NewWebhookConfigFromEnv()
+	var dispatcher *webhook.WebhookDispatcher
+	webhooksStopped := make(chan struct{})
+	if len(webhookConfig.URLs) > 0 {
+		dispatcher, err = webhook.NewWebhookDispatcher(webhookConfig, logger)
+		if err != nil {
+			logger.Fatal("Failed to create webhook dispatcher", zap.Error(err))
+		}
+		go func() {
+			dispatcher.Run(webhookCtx, eventBus.Subscribe(0))
+			close(webhooksStopped)
+		}()
+	}
+
+	// Initialize the transport-agnostic conversation engine
+	engineConfig := conversation.NewConfigFromEnv()
+	engine, err := conversation.NewEngine(engineConfig, geminiLLMRepo, ttsRepo, sttRepo, sessionRepo, childRepo, quietHoursRepo, eventBus, logger)
+	if err != nil {
+		logger.Fatal("Failed to create conversation engine", zap.Error(err))
+	}
+	// Have the canned phrases ready before an error needs the fallback;
+	// phrases failing now are synthesized on demand
+	go engine.PrewarmPhrases(context.Background())
+	// Cut responses back while the LLM or text-to-speech struggles
+	if degradationConfig := degradation.NewDegradationConfigFromEnv(); degradationConfig.Enabled {
+		controller, err := degradation.NewController(degradationConfig, logger)
+		if err != nil {
+			logger.Fatal("Failed to create degradation controller", zap.Error(err))
+		}
+		engine.SetDegradation(controller)
+	}
+
+	// Initialize WebSocket hub with conversation engine
+	hub, err := websocket.NewHub(websocket.NewHubConfigFromEnv(), engine, deviceRepo, eventBus, logger)
+	if err != nil {
+		logger.Fatal("Failed to create WebSocket hub", zap.Error(err))
+	}
+
+	// Let integration tests opt in to speech services without external APIs.
+	// Demo mode keeps the replies fixed as well, and fillers never interleave.
+	if deterministicConfig := deterministic.NewConfigFromEnv(); deterministicConfig.Enabled {
+		logger.Warn("Deterministic mode enabled, connections may ask for fake speech services",
+			zap.String("header", websocket.HeaderDeterministic))
+		deterministicEngineConfig := engineConfig
+		deterministicEngineConfig.DemoMode = true
+		deterministicEngineConfig.FillerEnabled = false
+		deterministicEngine, err := conversation.NewEngine(deterministicEngineConfig, geminiLLMRepo,
+			deterministic.NewTextToSpeech(deterministicConfig, logger), deterministic.NewSpeechToText(deterministicConfig, logger),
+			sessionRepo, childRepo, quietHoursRepo, eventBus, logger)
+		if err != nil {
+			logger.Fatal("Failed to create deterministic conversation engine", zap.Error(err))
+		}
+		hub.EnableDeterministicMode(deterministicEngine)
+	}
 	go hub.Run()
 
+	// Refuse connections while Gemini, Eleven Labs or MongoDB are unreachable
+	readinessCtx, stopReadiness := context.WithCancel(context.Background())
+	defer stopReadiness()
+	dependencies = append(dependencies,
+		websocket.Dependency{Name: "gemini", Checker: geminiLLMRepo},
+		websocket.Dependency{Name: "elevenlabs", Checker: ttsRepo})
+	hub.MonitorReadiness(readinessCtx, dependencies)
+
 	// Initialize API routes
-	api.InitRoutes(e, hub, deviceRepo, logger)
+	api.InitRoutes(e, hub, deviceRepo, deviceRepo, childRepo, sessionRepo, quietHoursRepo, ttsRepo, auditTrail, api.NewDeviceAuthConfigFromEnv(), api.NewChildVoiceConfigFromEnv(), logger)
+
+	// Expose session cache hits and misses with the runtime metrics served
+	// to admins on /debug/vars
+	if sessionCache != nil {
+		expvar.Publish("session_cache", expvar.Func(func() interface{} {
+			return sessionCache.Stats()
+		}))
+	}
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -81,15 +211,50 @@ func main() {
 		port = "8080"
 	}
 
+	// Optionally terminate TLS here and require client certificates from dolls
+	mtlsConfig := auth.NewMTLSConfigFromEnv()
+	if mtlsConfig.Required {
+		tlsConfig, err := mtlsConfig.TLSConfig()
+		if err != nil {
+			logger.Fatal("Failed to configure mTLS", zap.Error(err))
+		}
+		e.TLSServer.TLSConfig = tlsConfig
+		e.TLSServer.Addr = ":" + port
+		logger.Info("mTLS required, devices must present a client certificate")
+	}
+
 	// Graceful shutdown
 	go func() {
-		if err := e.Start(":" + port); err != nil && err != http.ErrServerClosed {
+		var err error
+		if mtlsConfig.Required {
+			err = e.StartServer(e.TLSServer)
+		} else {
+			err = e.Start(":" + port)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Fatal("shutting down the server", zap.Error(err))
 		}
 	}()
 
 	logger.Info("Server started with clean architecture pattern", zap.String("port", port))
 
+	// Optionally serve the conversation pipeline over gRPC as well
+	var grpcServer *grpc.Server
+	if grpcPort := os.Getenv("GRPC_PORT"); grpcPort != "" {
+		listener, err := net.Listen("tcp", ":"+grpcPort)
+		if err != nil {
+			logger.Fatal("Failed to listen for gRPC", zap.Error(err))
+		}
+		grpcServer = grpc.NewServer()
+		grpcserver.NewServer(engine, logger).Register(grpcServer)
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				logger.Error("gRPC server stopped", zap.Error(err))
+			}
+		}()
+		logger.Info("gRPC server started", zap.String("port", grpcPort))
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
@@ -100,6 +265,36 @@ func main() {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
 
+	if grpcServer != nil {
+		// Conversation streams stay open until devices hang up, so do not wait forever
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			grpcServer.Stop()
+		}
+	}
+
+	sessionCleanup.Stop()
+
+	// Ask devices to reconnect after the restart before their connections are cut
+	if err := hub.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("WebSocket connections not drained before shutdown", zap.Error(err))
+	}
+
+	// The events of the drained connections are delivered before exiting
+	if dispatcher != nil {
+		stopWebhooks()
+		<-webhooksStopped
+		if err := dispatcher.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("Webhook deliveries not finished before shutdown", zap.Error(err))
+		}
+	}
+
 	if err := e.Shutdown(shutdownCtx); err != nil {
 		logger.Fatal("Server forced to shutdown", zap.Error(err))
 	}
@@ -107,6 +302,21 @@ func main() {
 	logger.Info("Server exited")
 }
 
+// logLifecycleEvents writes lifecycle events to the analytics log
+func logLifecycleEvents(sub *events.Subscription, logger *zap.Logger) {
+	for event := range sub.Events() {
+		logger.Info("Lifecycle event",
+			zap.String("type", string(event.Type)),
+			zap.String("device_id", event.DeviceID),
+			zap.String("child_id", event.ChildID),
+			zap.String("session_id", event.SessionID),
+			zap.String("reason", event.Reason),
+			zap.Int("close_code", event.CloseCode),
+			zap.String("topic", event.Topic),
+			zap.Time("timestamp", event.Timestamp))
+	}
+}
+
 // bootstrapDemoDevices sets up demo devices for development and testing
 // In production, devices would be provisioned through device management APIs
 func bootstrapDemoDevices(deviceRepo *adapters.MemoryDeviceRepository, logger *zap.Logger) error {
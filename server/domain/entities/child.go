@@ -0,0 +1,144 @@
+package entities
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Limits on the topics a parent can discourage, keeping the system prompt short
+const (
+	MaxDiscouragedTopics     = 20
+	MaxDiscouragedTopicBytes = 50
+)
+
+// Child represents a child profile owned by a parent/user account
+type Child struct {
+	ID        string   `json:"id" bson:"_id" db:"id"`
+	OwnerID   string   `json:"owner_id" bson:"owner_id" db:"owner_id"`
+	Name      string   `json:"name" bson:"name" db:"name"`
+	DeviceIDs []string `json:"device_ids" bson:"device_ids" db:"device_ids"`
+	// VoiceID is the custom voice the doll speaks with, empty for the default voice
+	VoiceID string `json:"voice_id,omitempty" bson:"voice_id,omitempty" db:"voice_id"`
+	// VoiceInvalid flags a VoiceID the TTS provider no longer has, so that the
+	// parent can pick another one. The doll speaks with the default voice meanwhile.
+	VoiceInvalid bool `json:"voice_invalid,omitempty" bson:"voice_invalid,omitempty" db:"voice_invalid"`
+	// DiscouragedTopics are topics the parent wants the doll to steer away from
+	DiscouragedTopics []string `json:"discouraged_topics,omitempty" bson:"discouraged_topics,omitempty" db:"discouraged_topics"`
+	// BirthDate tailors responses to the child's age, zero when unknown
+	BirthDate time.Time `json:"birth_date,omitempty" bson:"birth_date,omitempty" db:"birth_date"`
+	// Timezone is the IANA timezone the child lives in, empty for the server's default
+	Timezone string `json:"timezone,omitempty" bson:"timezone,omitempty" db:"timezone"`
+	// ForcedLanguage is the BCP-47 language the doll always listens and speaks
+	// in, whatever language the child speaks. Empty keeps the device's language.
+	ForcedLanguage string `json:"forced_language,omitempty" bson:"forced_language,omitempty" db:"forced_language"`
+	// Language is the BCP-47 language the child usually speaks, used when
+	// neither the device nor the session name one. Empty leaves it to the device.
+	Language string `json:"language,omitempty" bson:"language,omitempty" db:"language"`
+	// RecordingConsent is set once the parent agreed to the child's audio being
+	// recorded. Without it nothing the child says is recorded.
+	RecordingConsent bool `json:"recording_consent" bson:"recording_consent,omitempty" db:"recording_consent"`
+	// VocabularyConsent is set once the parent agreed to the new words of the
+	// child's conversations being tracked. Without it no word is tracked.
+	VocabularyConsent bool `json:"vocabulary_consent" bson:"vocabulary_consent,omitempty" db:"vocabulary_consent"`
+	// LearnedWords are the notable words of the child's conversations, the
+	// oldest first
+	LearnedWords []LearnedWord `json:"learned_words,omitempty" bson:"learned_words,omitempty" db:"learned_words"`
+	CreatedAt    time.Time     `json:"created_at" bson:"created_at" db:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at" bson:"updated_at" db:"updated_at"`
+}
+
+func (c *Child) Validate() error {
+	if c.OwnerID == "" {
+		return errors.New("owner ID is required")
+	}
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+	if _, err := c.Location(); err != nil {
+		return err
+	}
+	for _, language := range []string{c.ForcedLanguage, c.Language} {
+		if language == "" {
+			continue
+		}
+		if _, err := NormalizeLanguageTag(language); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Location returns the child's timezone, nil when none is set
+func (c *Child) Location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return nil, nil
+	}
+	location, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", c.Timezone)
+	}
+	return location, nil
+}
+
+// languageTagPattern matches BCP-47 tags made of a language and subtags such
+// as a script or region, e.g. "id", "id-ID" or "zh-Hant-TW"
+var languageTagPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// NormalizeLanguageTag returns tag in its canonical case, e.g. "id-ID" for
+// "ID-id". It fails when tag is not a BCP-47 language tag.
+func NormalizeLanguageTag(tag string) (string, error) {
+	if !languageTagPattern.MatchString(tag) {
+		return "", fmt.Errorf("invalid language tag %q", tag)
+	}
+	subtags := strings.Split(tag, "-")
+	subtags[0] = strings.ToLower(subtags[0])
+	for i := 1; i < len(subtags); i++ {
+		switch subtag := subtags[i]; {
+		case len(subtag) == 2:
+			subtags[i] = strings.ToUpper(subtag)
+		case len(subtag) == 4:
+			subtags[i] = strings.ToUpper(subtag[:1]) + strings.ToLower(subtag[1:])
+		default:
+			subtags[i] = strings.ToLower(subtag)
+		}
+	}
+	return strings.Join(subtags, "-"), nil
+}
+
+// Age returns the child's age in whole years at t, false when the birth date is unknown
+func (c *Child) Age(t time.Time) (int, bool) {
+	if c.BirthDate.IsZero() {
+		return 0, false
+	}
+	years := t.Year() - c.BirthDate.Year()
+	// Not had this year's birthday yet
+	if t.Month() < c.BirthDate.Month() || (t.Month() == c.BirthDate.Month() && t.Day() < c.BirthDate.Day()) {
+		years--
+	}
+	return max(years, 0), true
+}
+
+// NormalizeDiscouragedTopics trims the topics and drops blank and duplicate
+// ones, ignoring case. It fails when a topic or the list is too long.
+func NormalizeDiscouragedTopics(topics []string) ([]string, error) {
+	normalized := make([]string, 0, len(topics))
+	seen := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		topic = strings.Join(strings.Fields(topic), " ")
+		if topic == "" || seen[strings.ToLower(topic)] {
+			continue
+		}
+		if len(topic) > MaxDiscouragedTopicBytes {
+			return nil, fmt.Errorf("topic %q is longer than %d bytes", topic, MaxDiscouragedTopicBytes)
+		}
+		seen[strings.ToLower(topic)] = true
+		normalized = append(normalized, topic)
+	}
+	if len(normalized) > MaxDiscouragedTopics {
+		return nil, fmt.Errorf("at most %d topics can be discouraged", MaxDiscouragedTopics)
+	}
+	return normalized, nil
+}
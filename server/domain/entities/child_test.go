@@ -0,0 +1,72 @@
+package entities
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestChild_Age(t *testing.T) {
+	child := &Child{BirthDate: time.Date(2020, 3, 15, 0, 0, 0, 0, time.UTC)}
+
+	if age, ok := child.Age(time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC)); !ok || age != 4 {
+		t.Errorf("Expected age 4 the day before the birthday, got %d", age)
+	}
+	if age, ok := child.Age(time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)); !ok || age != 5 {
+		t.Errorf("Expected age 5 on the birthday, got %d", age)
+	}
+
+	if _, ok := (&Child{}).Age(time.Now()); ok {
+		t.Errorf("Expected an unknown age without a birth date")
+	}
+}
+
+func TestNormalizeLanguageTag(t *testing.T) {
+	valid := map[string]string{
+		"id":         "id",
+		"ID-id":      "id-ID",
+		"en-us":      "en-US",
+		"zh-hant-tw": "zh-Hant-TW",
+		"es-419":     "es-419",
+	}
+	for tag, want := range valid {
+		if got, err := NormalizeLanguageTag(tag); err != nil || got != want {
+			t.Errorf("Expected %q to normalize to %q, got %q: %v", tag, want, got, err)
+		}
+	}
+
+	for _, tag := range []string{"", "indonesian", "id_ID", "id-", "bahasa indonesia"} {
+		if _, err := NormalizeLanguageTag(tag); err == nil {
+			t.Errorf("Expected %q to be rejected", tag)
+		}
+	}
+}
+
+func TestChild_AddLearnedWords(t *testing.T) {
+	day := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	child := &Child{LearnedWords: []LearnedWord{{Word: "Dinosaurus", Source: LearnedFromChild, LearnedAt: day.Add(-24 * time.Hour)}}}
+
+	added := child.AddLearnedWords([]LearnedWord{
+		{Word: "  fotosintesis ", Source: LearnedFromDoll, LearnedAt: day},
+		{Word: "dinosaurus", LearnedAt: day},
+		{Word: "gerhana", LearnedAt: day},
+		{Word: "", LearnedAt: day},
+		{Word: "kata yang jauh terlalu panjang untuk disimpan", LearnedAt: day},
+	})
+	if len(added) != 2 || added[0].Word != "fotosintesis" || added[0].Source != LearnedFromDoll || added[1].Source != LearnedFromChild {
+		t.Fatalf("Expected the two new words added, got %+v", added)
+	}
+	if child.LearnedWordsSince(day) != 2 {
+		t.Errorf("Expected two words learned on the day, got %d", child.LearnedWordsSince(day))
+	}
+	if recent := child.RecentLearnedWords(2); len(recent) != 2 || recent[0] != "gerhana" || recent[1] != "fotosintesis" {
+		t.Errorf("Expected the most recent words first, got %q", recent)
+	}
+
+	for i := 0; i < MaxLearnedWords; i++ {
+		child.AddLearnedWords([]LearnedWord{{Word: fmt.Sprintf("kata-%d", i), LearnedAt: day}})
+	}
+	if len(child.LearnedWords) != MaxLearnedWords || child.LearnedWords[0].Word == "Dinosaurus" {
+		t.Errorf("Expected the oldest words dropped beyond the limit, got %d words", len(child.LearnedWords))
+	}
+}
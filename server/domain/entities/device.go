@@ -4,11 +4,49 @@ import "time"
 
 // Device represents a doll device
 type Device struct {
-	ID           string    `json:"id" bson:"_id" db:"id"`
-	SerialNumber string    `json:"serial_number" bson:"serial_number" db:"serial_number"`
-	SecretKey    string    `json:"secret_key" bson:"secret_key" db:"secret_key"`
-	Model        string    `json:"model" bson:"model" db:"model"`
-	OwnerID      *string   `json:"owner_id" bson:"owner_id" db:"owner_id"`
-	CreatedAt    time.Time `json:"created_at" bson:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" bson:"updated_at" db:"updated_at"`
+	ID           string  `json:"id" bson:"_id" db:"id"`
+	SerialNumber string  `json:"serial_number" bson:"serial_number" db:"serial_number"`
+	SecretKey    string  `json:"secret_key" bson:"secret_key" db:"secret_key"`
+	Model        string  `json:"model" bson:"model" db:"model"`
+	OwnerID      *string `json:"owner_id" bson:"owner_id" db:"owner_id"`
+	// FirmwareVersion is the version the device announced when it last
+	// connected, empty until it announced one
+	FirmwareVersion string    `json:"firmware_version,omitempty" bson:"firmware_version,omitempty" db:"firmware_version"`
+	CreatedAt       time.Time `json:"created_at" bson:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" bson:"updated_at" db:"updated_at"`
+
+	// Online reports whether the device is connected to the server
+	Online             bool      `json:"online" bson:"online" db:"online"`
+	LastConnectedAt    time.Time `json:"last_connected_at,omitempty" bson:"last_connected_at,omitempty" db:"last_connected_at"`
+	LastDisconnectedAt time.Time `json:"last_disconnected_at,omitempty" bson:"last_disconnected_at,omitempty" db:"last_disconnected_at"`
+	// UptimeSeconds and DowntimeSeconds total the time spent connected and
+	// disconnected since the first connection
+	UptimeSeconds   int64 `json:"uptime_seconds" bson:"uptime_seconds" db:"uptime_seconds"`
+	DowntimeSeconds int64 `json:"downtime_seconds" bson:"downtime_seconds" db:"downtime_seconds"`
+}
+
+// Connect marks the device online at now, counting the time since its last
+// disconnection as downtime
+func (d *Device) Connect(now time.Time) {
+	if d.Online {
+		return
+	}
+	if !d.LastDisconnectedAt.IsZero() && now.After(d.LastDisconnectedAt) {
+		d.DowntimeSeconds += int64(now.Sub(d.LastDisconnectedAt) / time.Second)
+	}
+	d.Online = true
+	d.LastConnectedAt = now
+}
+
+// Disconnect marks the device offline at now, counting the time since it
+// connected as uptime
+func (d *Device) Disconnect(now time.Time) {
+	if !d.Online {
+		return
+	}
+	if now.After(d.LastConnectedAt) {
+		d.UptimeSeconds += int64(now.Sub(d.LastConnectedAt) / time.Second)
+	}
+	d.Online = false
+	d.LastDisconnectedAt = now
 }
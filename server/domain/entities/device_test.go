@@ -0,0 +1,24 @@
+package entities
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDevice_TracksUptimeAndDowntime(t *testing.T) {
+	start := time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC)
+	device := &Device{}
+
+	device.Connect(start)
+	device.Connect(start.Add(time.Minute)) // a replaced connection changes nothing
+	device.Disconnect(start.Add(time.Hour))
+	device.Disconnect(start.Add(2 * time.Hour))
+	device.Connect(start.Add(3 * time.Hour))
+
+	if !device.Online || !device.LastConnectedAt.Equal(start.Add(3*time.Hour)) {
+		t.Errorf("Expected the device online since the last connection, got %+v", device)
+	}
+	if device.UptimeSeconds != 3600 || device.DowntimeSeconds != 7200 {
+		t.Errorf("Expected 1h uptime and 2h downtime, got %ds and %ds", device.UptimeSeconds, device.DowntimeSeconds)
+	}
+}
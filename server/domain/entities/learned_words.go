@@ -0,0 +1,80 @@
+package entities
+
+import (
+	"strings"
+	"time"
+)
+
+// Limits on the words kept per child, keeping the child record small
+const (
+	MaxLearnedWords     = 200
+	MaxLearnedWordBytes = 40
+)
+
+// Sources of a learned word
+const (
+	// LearnedFromChild is a word the child used
+	LearnedFromChild = "child"
+	// LearnedFromDoll is a word the doll introduced to the child
+	LearnedFromDoll = "doll"
+)
+
+// LearnedWord is a notable word or concept that came up in a conversation of
+// the child, tracked for the parent and reinforced in later conversations
+type LearnedWord struct {
+	Word string `json:"word" bson:"word"`
+	// Source is LearnedFromChild or LearnedFromDoll
+	Source    string    `json:"source" bson:"source"`
+	SessionID string    `json:"session_id,omitempty" bson:"session_id,omitempty"`
+	LearnedAt time.Time `json:"learned_at" bson:"learned_at"`
+}
+
+// AddLearnedWords adds the words the child does not have yet, ignoring case,
+// and returns those added. Blank and overlong words are skipped, and the
+// oldest words are dropped beyond MaxLearnedWords.
+func (c *Child) AddLearnedWords(words []LearnedWord) []LearnedWord {
+	known := make(map[string]bool, len(c.LearnedWords)+len(words))
+	for _, word := range c.LearnedWords {
+		known[strings.ToLower(word.Word)] = true
+	}
+
+	var added []LearnedWord
+	for _, word := range words {
+		word.Word = strings.Join(strings.Fields(word.Word), " ")
+		if word.Word == "" || len(word.Word) > MaxLearnedWordBytes || known[strings.ToLower(word.Word)] {
+			continue
+		}
+		if word.Source != LearnedFromDoll {
+			word.Source = LearnedFromChild
+		}
+		known[strings.ToLower(word.Word)] = true
+		added = append(added, word)
+	}
+
+	c.LearnedWords = append(c.LearnedWords, added...)
+	if excess := len(c.LearnedWords) - MaxLearnedWords; excess > 0 {
+		c.LearnedWords = append([]LearnedWord(nil), c.LearnedWords[excess:]...)
+	}
+	return added
+}
+
+// LearnedWordsSince returns how many words the child learned at or after t
+func (c *Child) LearnedWordsSince(t time.Time) int {
+	count := 0
+	for _, word := range c.LearnedWords {
+		if !word.LearnedAt.Before(t) {
+			count++
+		}
+	}
+	return count
+}
+
+// RecentLearnedWords returns the words of the at most n words learned last,
+// the most recent first
+func (c *Child) RecentLearnedWords(n int) []string {
+	var words []string
+	for i := len(c.LearnedWords) - 1; i >= 0 && len(words) < n; i-- {
+		words = append(words, c.LearnedWords[i].Word)
+	}
+	return words
+}
@@ -0,0 +1,78 @@
+package entities
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// QuietHours is a daily window during which a child's doll does not engage
+// in conversation. Start and End are wall-clock times ("21:00") in Timezone;
+// a window whose end is before its start spans midnight.
+type QuietHours struct {
+	ChildID   string    `json:"child_id" bson:"_id"`
+	Start     string    `json:"start" bson:"start"`
+	End       string    `json:"end" bson:"end"`
+	Timezone  string    `json:"timezone" bson:"timezone"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// parseClock parses an "HH:MM" wall-clock time into minutes since midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (q *QuietHours) Validate() error {
+	if q.ChildID == "" {
+		return errors.New("child ID is required")
+	}
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseClock(q.End)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return errors.New("start and end must differ")
+	}
+	if q.Timezone == "" {
+		return errors.New("timezone is required")
+	}
+	if _, err := time.LoadLocation(q.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", q.Timezone)
+	}
+	return nil
+}
+
+// Active reports whether t falls inside the quiet window. The comparison
+// uses the wall clock in the schedule's timezone, so the window follows
+// daylight saving time changes. Start is inclusive and end exclusive.
+func (q *QuietHours) Active(t time.Time) (bool, error) {
+	location, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return false, fmt.Errorf("unknown timezone %q", q.Timezone)
+	}
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return false, err
+	}
+	end, err := parseClock(q.End)
+	if err != nil {
+		return false, err
+	}
+
+	local := t.In(location)
+	now := local.Hour()*60 + local.Minute()
+
+	if start < end {
+		return now >= start && now < end, nil
+	}
+	// The window spans midnight
+	return now >= start || now < end, nil
+}
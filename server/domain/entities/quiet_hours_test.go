@@ -0,0 +1,90 @@
+package entities
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHours_Active(t *testing.T) {
+	tests := []struct {
+		name       string
+		quietHours QuietHours
+		at         time.Time
+		want       bool
+	}{
+		{
+			name:       "inside window spanning midnight",
+			quietHours: QuietHours{Start: "20:00", End: "06:30", Timezone: "Asia/Jakarta"},
+			at:         time.Date(2025, 3, 1, 16, 0, 0, 0, time.UTC), // 23:00 WIB
+			want:       true,
+		},
+		{
+			name:       "after midnight before the end",
+			quietHours: QuietHours{Start: "20:00", End: "06:30", Timezone: "Asia/Jakarta"},
+			at:         time.Date(2025, 3, 1, 23, 29, 0, 0, time.UTC), // 06:29 WIB
+			want:       true,
+		},
+		{
+			name:       "end is exclusive",
+			quietHours: QuietHours{Start: "20:00", End: "06:30", Timezone: "Asia/Jakarta"},
+			at:         time.Date(2025, 3, 1, 23, 30, 0, 0, time.UTC), // 06:30 WIB
+			want:       false,
+		},
+		{
+			name:       "daytime window",
+			quietHours: QuietHours{Start: "13:00", End: "15:00", Timezone: "Asia/Jakarta"},
+			at:         time.Date(2025, 3, 1, 7, 0, 0, 0, time.UTC), // 14:00 WIB
+			want:       true,
+		},
+		{
+			name:       "outside daytime window",
+			quietHours: QuietHours{Start: "13:00", End: "15:00", Timezone: "Asia/Jakarta"},
+			at:         time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC), // 16:00 WIB
+			want:       false,
+		},
+		{
+			name:       "standard time in New York",
+			quietHours: QuietHours{Start: "20:00", End: "07:00", Timezone: "America/New_York"},
+			at:         time.Date(2025, 1, 15, 11, 30, 0, 0, time.UTC), // 06:30 EST
+			want:       true,
+		},
+		{
+			name:       "same instant under daylight saving time",
+			quietHours: QuietHours{Start: "20:00", End: "07:00", Timezone: "America/New_York"},
+			at:         time.Date(2025, 7, 15, 11, 30, 0, 0, time.UTC), // 07:30 EDT
+			want:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.quietHours.Active(tt.at)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected active %v at %s, got %v", tt.want, tt.at, got)
+			}
+		})
+	}
+}
+
+func TestQuietHours_Validate(t *testing.T) {
+	valid := QuietHours{ChildID: "child-1", Start: "20:00", End: "06:30", Timezone: "Asia/Jakarta"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected valid quiet hours, got %v", err)
+	}
+
+	invalid := []QuietHours{
+		{Start: "20:00", End: "06:30", Timezone: "Asia/Jakarta"},
+		{ChildID: "child-1", Start: "8pm", End: "06:30", Timezone: "Asia/Jakarta"},
+		{ChildID: "child-1", Start: "20:00", End: "20:00", Timezone: "Asia/Jakarta"},
+		{ChildID: "child-1", Start: "20:00", End: "06:30", Timezone: "Mars/Olympus"},
+		{ChildID: "child-1", Start: "20:00", End: "06:30"},
+	}
+	for _, quietHours := range invalid {
+		if err := quietHours.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", quietHours)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package entities
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	maxSearchQueryLength = 100
+	maxSearchTerms       = 8
+	snippetRadius        = 60 // Characters kept on each side of the first match
+)
+
+// ErrEmptySearchQuery is returned when a query contains no searchable words
+var ErrEmptySearchQuery = errors.New("search query has no searchable words")
+
+// MessageMatch is a stored message that matched a transcript search
+type MessageMatch struct {
+	SessionID string    `json:"session_id"`
+	DeviceID  string    `json:"device_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Role      Role      `json:"role"`
+	Snippet   string    `json:"snippet"`
+}
+
+// searchWords splits text into lowercase words of letters and digits
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// SearchTerms sanitizes a free-form query into the distinct words searched
+// for. Punctuation, including MongoDB text search operators such as quotes
+// and negation, is discarded so that a query can only ever match words.
+func SearchTerms(query string) ([]string, error) {
+	if len(query) > maxSearchQueryLength {
+		query = query[:maxSearchQueryLength]
+	}
+
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range searchWords(query) {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	if len(terms) == 0 {
+		return nil, ErrEmptySearchQuery
+	}
+	return terms, nil
+}
+
+// SearchMessages returns the messages of the session containing any of the
+// terms as a whole word, newest first
+func (s *Session) SearchMessages(terms []string) []MessageMatch {
+	var matches []MessageMatch
+	for i := len(s.Messages) - 1; i >= 0; i-- {
+		message := s.Messages[i]
+		if !containsAnyWord(message.Content, terms) {
+			continue
+		}
+		matches = append(matches, MessageMatch{
+			SessionID: s.ID,
+			DeviceID:  s.DeviceID,
+			Timestamp: message.Timestamp,
+			Role:      message.Role,
+			Snippet:   snippet(message.Content, terms),
+		})
+	}
+	return matches
+}
+
+func containsAnyWord(content string, terms []string) bool {
+	for _, word := range searchWords(content) {
+		for _, term := range terms {
+			if word == term {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// snippet trims content to the text around the first matching term
+func snippet(content string, terms []string) string {
+	runes := []rune(content)
+	if len(runes) <= 2*snippetRadius {
+		return content
+	}
+
+	lower := []rune(strings.ToLower(content))
+	first := -1
+	for _, term := range terms {
+		if len(lower) != len(runes) {
+			break // Lowercasing changed the length, offsets would not line up
+		}
+		if index := runeIndex(lower, []rune(term)); index >= 0 && (first < 0 || index < first) {
+			first = index
+		}
+	}
+	if first < 0 {
+		first = 0
+	}
+
+	start := max(first-snippetRadius, 0)
+	end := min(first+snippetRadius, len(runes))
+	result := strings.TrimSpace(string(runes[start:end]))
+	if start > 0 {
+		result = "…" + result
+	}
+	if end < len(runes) {
+		result += "…"
+	}
+	return result
+}
+
+func runeIndex(haystack, needle []rune) int {
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		if string(haystack[i:i+len(needle)]) == string(needle) {
+			return i
+		}
+	}
+	return -1
+}
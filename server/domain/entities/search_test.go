@@ -0,0 +1,64 @@
+package entities
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchTerms(t *testing.T) {
+	terms, err := SearchTerms(`  "Takut" -gelap  takut! $where `)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(terms, " ") != "takut gelap where" {
+		t.Errorf("Expected operators stripped and words deduplicated, got %v", terms)
+	}
+
+	if _, err := SearchTerms(` "-" $ `); !errors.Is(err, ErrEmptySearchQuery) {
+		t.Errorf("Expected ErrEmptySearchQuery, got %v", err)
+	}
+}
+
+func TestSession_SearchMessages(t *testing.T) {
+	base := time.Date(2025, 3, 1, 19, 0, 0, 0, time.UTC)
+	session := &Session{
+		ID:       "session-1",
+		DeviceID: "device-1",
+		Messages: []Message{
+			{Timestamp: base, Role: UserRole, Content: "Aku takut gelap"},
+			{Timestamp: base.Add(time.Second), Role: DollRole, Content: "Tidak apa-apa, aku di sini."},
+			{Timestamp: base.Add(2 * time.Second), Role: UserRole, Content: "Ketakutan itu wajar"},
+			{Timestamp: base.Add(3 * time.Second), Role: UserRole, Content: "Aku TAKUT petir."},
+		},
+	}
+
+	matches := session.SearchMessages([]string{"takut"})
+	if len(matches) != 2 {
+		t.Fatalf("Expected whole-word matches only, got %+v", matches)
+	}
+	if matches[0].Snippet != "Aku TAKUT petir." || matches[1].Snippet != "Aku takut gelap" {
+		t.Errorf("Expected newest match first, got %+v", matches)
+	}
+	if matches[0].SessionID != "session-1" || matches[0].DeviceID != "device-1" || matches[0].Role != UserRole {
+		t.Errorf("Expected session context on the match, got %+v", matches[0])
+	}
+}
+
+func TestSession_SearchMessagesSnippet(t *testing.T) {
+	content := strings.Repeat("bla ", 40) + "aku takut sekali " + strings.Repeat("bla ", 40)
+	session := &Session{Messages: []Message{{Content: content}}}
+
+	matches := session.SearchMessages([]string{"takut"})
+	if len(matches) != 1 {
+		t.Fatalf("Expected one match, got %d", len(matches))
+	}
+	snippet := matches[0].Snippet
+	if !strings.Contains(snippet, "takut") || !strings.HasPrefix(snippet, "…") || !strings.HasSuffix(snippet, "…") {
+		t.Errorf("Expected an elided snippet around the match, got %q", snippet)
+	}
+	if len([]rune(snippet)) > 2*snippetRadius+2 {
+		t.Errorf("Expected the snippet to be trimmed, got %d characters", len([]rune(snippet)))
+	}
+}
@@ -1,6 +1,7 @@
 package entities
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -15,6 +16,13 @@ type Message struct {
 	Content    string          `bson:"content" json:"content"`
 	DurationMs int64           `bson:"duration_ms" json:"duration_ms"`
 	Metadata   MessageMetadata `bson:"metadata" json:"metadata"`
+	// OperatorID is set on doll messages an operator typed while taking over
+	// the conversation, instead of the LLM
+	OperatorID string `bson:"operator_id,omitempty" json:"operator_id,omitempty"`
+	// TurnID identifies the message across retries of its storage: the
+	// conversation that added it and its sequence there. Messages stored
+	// before turn IDs existed have none.
+	TurnID string `bson:"turn_id,omitempty" json:"turn_id,omitempty"`
 }
 
 // Role defines the type of message sender
@@ -26,11 +34,36 @@ const (
 	SystemRole Role = "system"
 )
 
+// ParseRole returns the role named role. The doll is also known as
+// "assistant" or "model" to chat APIs and in data they wrote; both are read as
+// DollRole. It reports false for unknown roles.
+func ParseRole(role string) (Role, bool) {
+	switch Role(role) {
+	case UserRole, DollRole, SystemRole:
+		return Role(role), true
+	case "assistant", "model":
+		return DollRole, true
+	}
+	return Role(role), false
+}
+
+// UnmarshalJSON reads the role, normalizing the other names of the doll
+func (r *Role) UnmarshalJSON(data []byte) error {
+	var role string
+	if err := json.Unmarshal(data, &role); err != nil {
+		return err
+	}
+	*r, _ = ParseRole(role)
+	return nil
+}
+
 type SessionMetadata struct {
 	Language        string                 `bson:"language" json:"language"`
 	UserPreferences map[string]interface{} `bson:"user_preferences" json:"user_preferences"`
 }
 
+// Session is a conversation of a device. ID is the storage-assigned identifier,
+// the hex form of the ObjectID for sessions stored in MongoDB.
 type Session struct {
 	ID            string          `bson:"_id,omitempty" json:"id"`
 	DeviceID      string          `bson:"device_id" json:"device_id"`
@@ -38,14 +71,130 @@ type Session struct {
 	LastMessageAt time.Time       `bson:"last_message_at" json:"last_message_at"`
 	Messages      []Message       `bson:"messages" json:"messages"`
 	Metadata      SessionMetadata `bson:"metadata" json:"metadata"`
+	// ChildID is the child the device was bound to when the session started,
+	// empty for a device bound to none
+	ChildID string `bson:"child_id,omitempty" json:"child_id,omitempty"`
+	// EndedAt is set once the session expired, zero while it is active
+	EndedAt time.Time `bson:"ended_at,omitempty" json:"ended_at,omitempty"`
+	// ParentSummary is set once the session ended when parent summaries are
+	// enabled and the LLM could write one
+	ParentSummary *ParentSummary `bson:"parent_summary,omitempty" json:"parent_summary,omitempty"`
+	// ContextReset is set once the chat context of a long session was
+	// started afresh, and replaced at every later reset
+	ContextReset *ContextReset `bson:"context_reset,omitempty" json:"context_reset,omitempty"`
+	// StoredMessages is how many of Messages the storage is known to hold, so
+	// that an update only sends the messages added since. It is set by the
+	// session repositories and never stored.
+	StoredMessages int `bson:"-" json:"-"`
+}
+
+// ContextReset records the last fresh start of the chat context of a session.
+// The chat continues from the messages after MessageCount, seeded with the
+// summary of the messages before.
+type ContextReset struct {
+	// Summary of the conversation before the reset, empty when the LLM
+	// could not write one
+	Summary      string    `bson:"summary" json:"summary"`
+	MessageCount int       `bson:"message_count" json:"message_count"`
+	ResetAt      time.Time `bson:"reset_at" json:"reset_at"`
 }
 
+// ParentSummary is a brief, neutral account of a session for the parent
+type ParentSummary struct {
+	Summary string `bson:"summary" json:"summary"`
+	// Topics the child talked about, in a few words each
+	Topics []string `bson:"topics" json:"topics"`
+	// Mood of the child, e.g. "cheerful", empty when it was unclear
+	Mood string `bson:"mood,omitempty" json:"mood,omitempty"`
+	// Concerns a parent may want to follow up on, such as the child being
+	// scared or hurt; empty for most sessions
+	Concerns    []string  `bson:"concerns,omitempty" json:"concerns,omitempty"`
+	GeneratedAt time.Time `bson:"generated_at" json:"generated_at"`
+}
+
+// SessionIdleTimeout is how long a session can go without messages before it
+// can no longer be continued
+const SessionIdleTimeout = 15 * time.Minute
+
+// AddMessage appends messages to the session and saves it. Messages of a
+// turn the session already has are skipped, so that retrying never stores a
+// turn twice; when every message is skipped nothing is saved.
 func (s *Session) AddMessage(saveCommand func(s *Session) error, messages ...Message) error {
-	s.Messages = append(s.Messages, messages...)
-	s.LastMessageAt = messages[len(messages)-1].Timestamp
+	var added []Message
+	for _, message := range messages {
+		if message.TurnID != "" && s.HasTurn(message.TurnID) {
+			continue
+		}
+		added = append(added, message)
+		s.Messages = append(s.Messages, message)
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	s.LastMessageAt = added[len(added)-1].Timestamp
 	return saveCommand(s)
 }
 
+// UnstoredMessages returns the messages added since the session was last
+// read from or written to its storage
+func (s *Session) UnstoredMessages() []Message {
+	if s.StoredMessages < 0 || s.StoredMessages > len(s.Messages) {
+		return s.Messages
+	}
+	return s.Messages[s.StoredMessages:]
+}
+
+// MarkStored records that the storage holds every message of the session
+func (s *Session) MarkStored() {
+	s.StoredMessages = len(s.Messages)
+}
+
+// ContextMessages returns the messages the chat context of the session
+// continues from: every message, or those after the last context reset
+func (s *Session) ContextMessages() []Message {
+	if s.ContextReset == nil || s.ContextReset.MessageCount > len(s.Messages) {
+		return s.Messages
+	}
+	return s.Messages[s.ContextReset.MessageCount:]
+}
+
+// HasTurn reports whether the session has the message of turnID
+func (s *Session) HasTurn(turnID string) bool {
+	for _, message := range s.Messages {
+		if message.TurnID == turnID {
+			return true
+		}
+	}
+	return false
+}
+
+// DedupeMessages returns messages without the repeated messages of a turn,
+// keeping the first of each. Messages without a turn ID are all kept.
+func DedupeMessages(messages []Message) []Message {
+	seen := make(map[string]bool)
+	deduped := make([]Message, 0, len(messages))
+	for _, message := range messages {
+		if message.TurnID != "" {
+			if seen[message.TurnID] {
+				continue
+			}
+			seen[message.TurnID] = true
+		}
+		deduped = append(deduped, message)
+	}
+	return deduped
+}
+
 func (s *Session) CanContinueThisSession() bool {
-	return time.Since(s.LastMessageAt) < 15*time.Minute
+	return s.EndedAt.IsZero() && time.Since(s.LastMessageAt) < SessionIdleTimeout
+}
+
+// Expire marks an active session whose last message is before cutoff as
+// ended at now, and reports whether it did
+func (s *Session) Expire(cutoff, now time.Time) bool {
+	if !s.EndedAt.IsZero() || !s.LastMessageAt.Before(cutoff) {
+		return false
+	}
+	s.EndedAt = now
+	return true
 }
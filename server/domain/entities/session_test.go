@@ -0,0 +1,103 @@
+package entities
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSession_Expire(t *testing.T) {
+	now := time.Date(2025, 3, 1, 19, 0, 0, 0, time.UTC)
+	cutoff := now.Add(-SessionIdleTimeout)
+
+	idle := &Session{LastMessageAt: cutoff.Add(-time.Second)}
+	if !idle.Expire(cutoff, now) {
+		t.Fatal("Expected a past-due active session to expire")
+	}
+	if !idle.EndedAt.Equal(now) {
+		t.Errorf("Expected EndedAt %v, got %v", now, idle.EndedAt)
+	}
+	if idle.Expire(cutoff, now.Add(time.Minute)) {
+		t.Error("Expected an ended session not to expire again")
+	}
+	if !idle.EndedAt.Equal(now) {
+		t.Errorf("Expected EndedAt to stay %v, got %v", now, idle.EndedAt)
+	}
+
+	fresh := &Session{LastMessageAt: cutoff.Add(time.Second)}
+	if fresh.Expire(cutoff, now) {
+		t.Error("Expected a recently active session not to expire")
+	}
+	if !fresh.EndedAt.IsZero() {
+		t.Errorf("Expected EndedAt to stay zero, got %v", fresh.EndedAt)
+	}
+}
+
+func TestSession_CanContinueThisSession(t *testing.T) {
+	session := &Session{LastMessageAt: time.Now()}
+	if !session.CanContinueThisSession() {
+		t.Fatal("Expected a recent session to be continuable")
+	}
+
+	session.EndedAt = time.Now()
+	if session.CanContinueThisSession() {
+		t.Error("Expected an ended session not to be continuable")
+	}
+}
+
+func TestSession_AddMessageStoresATurnOnce(t *testing.T) {
+	session := &Session{}
+	saves := 0
+	save := func(*Session) error {
+		saves++
+		return nil
+	}
+	turn := Message{Timestamp: time.Now(), Role: UserRole, Content: "halo", TurnID: "conversation-1"}
+
+	if err := session.AddMessage(save, turn); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	if err := session.AddMessage(save, turn); err != nil {
+		t.Fatalf("Failed to add message again: %v", err)
+	}
+	if len(session.Messages) != 1 || saves != 1 {
+		t.Fatalf("Expected the turn stored once, got %d messages in %d saves", len(session.Messages), saves)
+	}
+
+	// Messages without a turn ID are never taken for one another
+	unnumbered := Message{Timestamp: time.Now(), Role: UserRole, Content: "halo"}
+	session.AddMessage(save, unnumbered, unnumbered)
+	if len(session.Messages) != 3 {
+		t.Errorf("Expected messages without a turn ID to be added, got %d messages", len(session.Messages))
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	for name, want := range map[string]Role{
+		"user":      UserRole,
+		"doll":      DollRole,
+		"system":    SystemRole,
+		"assistant": DollRole,
+		"model":     DollRole,
+	} {
+		if got, ok := ParseRole(name); !ok || got != want {
+			t.Errorf("Expected %q to be %q, got %q (%v)", name, want, got, ok)
+		}
+	}
+	if _, ok := ParseRole("narrator"); ok {
+		t.Error("Expected an unknown role to be reported")
+	}
+
+	var message Message
+	if err := json.Unmarshal([]byte(`{"role":"assistant","content":"Halo!"}`), &message); err != nil {
+		t.Fatalf("Failed to decode message: %v", err)
+	}
+	if message.Role != DollRole {
+		t.Errorf("Expected an assistant message to be read as the doll's, got %q", message.Role)
+	}
+	encoded, _ := json.Marshal(message)
+	var decoded Message
+	if err := json.Unmarshal(encoded, &decoded); err != nil || decoded.Role != DollRole {
+		t.Errorf("Expected the role to survive a round trip, got %q: %v", decoded.Role, err)
+	}
+}
@@ -0,0 +1,50 @@
+package entities
+
+import "time"
+
+// TurnRecorded is the lineage of one conversation turn, from the child's
+// audio to the doll's speech, kept as training data for the children whose
+// parent consented to recording. Personal information in the texts is
+// redacted.
+type TurnRecorded struct {
+	SessionID string `bson:"session_id" json:"session_id"`
+	DeviceID  string `bson:"device_id" json:"device_id"`
+	ChildID   string `bson:"child_id" json:"child_id"`
+
+	// InputAudio locates the child's audio among the recorded audio of the
+	// session, nil when it was not recorded
+	InputAudio *TurnAudio `bson:"input_audio,omitempty" json:"input_audio,omitempty"`
+
+	Transcription           string  `bson:"transcription" json:"transcription"`
+	TranscriptionConfidence float64 `bson:"transcription_confidence" json:"transcription_confidence"`
+	Emotion                 string  `bson:"emotion" json:"emotion"`
+	Language                string  `bson:"language" json:"language"`
+
+	// What the LLM was asked and answered; Cached is set when a repeated
+	// utterance was answered from the response cache instead
+	Model       string `bson:"model" json:"model"`
+	Prompt      string `bson:"prompt" json:"prompt"`
+	Response    string `bson:"response" json:"response"`
+	Cached      bool   `bson:"cached" json:"cached"`
+	Degradation string `bson:"degradation" json:"degradation"`
+
+	Speech TurnSpeech `bson:"speech" json:"speech"`
+
+	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+}
+
+// TurnAudio locates the child's utterance in the recorded audio of a session
+type TurnAudio struct {
+	SessionID  string    `bson:"session_id" json:"session_id"`
+	StartedAt  time.Time `bson:"started_at" json:"started_at"`
+	DurationMs int64     `bson:"duration_ms" json:"duration_ms"`
+}
+
+// TurnSpeech holds the text-to-speech parameters the response was spoken with.
+// Empty fields were left to the provider's defaults.
+type TurnSpeech struct {
+	VoiceID    string `bson:"voice_id,omitempty" json:"voice_id,omitempty"`
+	Language   string `bson:"language,omitempty" json:"language,omitempty"`
+	SampleRate int    `bson:"sample_rate,omitempty" json:"sample_rate,omitempty"`
+	ChunkSize  int    `bson:"chunk_size,omitempty" json:"chunk_size,omitempty"`
+}
@@ -0,0 +1,36 @@
+package entities
+
+import "strings"
+
+// Voice is a text-to-speech voice a parent can assign to their child's doll
+type Voice struct {
+	ID       string `json:"voice_id"`
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	// OwnerID is the account that created the voice, empty for stock voices
+	OwnerID string `json:"owner_id,omitempty"`
+	// Languages are the base languages the voice speaks, e.g. "en", empty when
+	// the provider does not tell
+	Languages []string `json:"languages,omitempty"`
+}
+
+// Custom reports whether the voice was created by an account
+func (v Voice) Custom() bool {
+	return v.OwnerID != ""
+}
+
+// SupportsLanguage reports whether the voice speaks the BCP-47 language,
+// comparing base languages. A voice whose languages are unknown is assumed
+// to speak any.
+func (v Voice) SupportsLanguage(language string) bool {
+	if len(v.Languages) == 0 || language == "" {
+		return true
+	}
+	base, _, _ := strings.Cut(strings.ToLower(language), "-")
+	for _, spoken := range v.Languages {
+		if strings.EqualFold(spoken, base) {
+			return true
+		}
+	}
+	return false
+}
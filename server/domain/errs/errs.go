@@ -0,0 +1,33 @@
+// Package errs defines the sentinel errors shared by the adapters and their
+// callers. Adapters wrap them with %w to add context, so that handlers can
+// match them with errors.Is instead of comparing messages, e.g. to choose an
+// HTTP status or a WebSocket error code.
+//
+// Errors that belong to a single repository contract, such as
+// repositories.ErrActiveSessionExists, are declared next to it instead.
+package errs
+
+import "errors"
+
+var (
+	// ErrDeviceNotFound is returned when no device matches the ID or serial number
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrDeviceExists is returned when a device with the same serial number is already registered
+	ErrDeviceExists = errors.New("device already exists")
+	// ErrDeviceModified is returned when a device was updated by another
+	// caller since it was read
+	ErrDeviceModified = errors.New("device was modified concurrently")
+	// ErrInvalidCredentials is returned when a device secret does not match
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	// ErrChildNotFound is returned when no child profile matches the ID or device
+	ErrChildNotFound = errors.New("child not found")
+	// ErrChildExists is returned when a child profile with the same ID already exists
+	ErrChildExists = errors.New("child already exists")
+
+	// ErrSessionNotFound is returned when no session matches the ID
+	ErrSessionNotFound = errors.New("session not found")
+
+	// ErrNoSpeech is returned when a transcription finished without recognizing any speech
+	ErrNoSpeech = errors.New("no speech detected")
+)
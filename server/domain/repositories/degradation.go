@@ -0,0 +1,45 @@
+package repositories
+
+import "context"
+
+// DegradationLevel is how far conversations cut back while their
+// dependencies are slow or failing
+type DegradationLevel int
+
+const (
+	// DegradationNone serves conversations in full, the default
+	DegradationNone DegradationLevel = iota
+	// DegradationPartial answers with a cheaper model and skips optional steps
+	DegradationPartial
+	// DegradationSevere also gives up on responses sooner
+	DegradationSevere
+)
+
+// String returns the name of the level, for logs
+func (l DegradationLevel) String() string {
+	switch l {
+	case DegradationNone:
+		return "none"
+	case DegradationPartial:
+		return "partial"
+	case DegradationSevere:
+		return "severe"
+	}
+	return "unknown"
+}
+
+type degradationKey struct{}
+
+// WithDegradation returns a context carrying the degradation level the
+// response is generated at, so that LargeLanguageModel implementations can
+// fall back to a cheaper model
+func WithDegradation(ctx context.Context, level DegradationLevel) context.Context {
+	return context.WithValue(ctx, degradationKey{}, level)
+}
+
+// DegradationFromContext returns the level set with WithDegradation,
+// DegradationNone otherwise
+func DegradationFromContext(ctx context.Context) DegradationLevel {
+	level, _ := ctx.Value(degradationKey{}).(DegradationLevel)
+	return level
+}
@@ -0,0 +1,10 @@
+package repositories
+
+import "context"
+
+// HealthChecker is implemented by dependencies that can tell whether they are
+// reachable, such as the LLM, text-to-speech and database clients
+type HealthChecker interface {
+	// CheckHealth returns an error when the dependency cannot serve requests
+	CheckHealth(ctx context.Context) error
+}
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/satriahrh/arunika/server/domain/entities"
 )
@@ -17,3 +18,204 @@ type ChatSession interface {
 	SendMessage(ctx context.Context, message entities.Message) (entities.Message, error)
 	History() ([]entities.Message, error)
 }
+
+// StreamingChatSession is implemented by a ChatSession that can pass the
+// text of a response on while it is generated
+type StreamingChatSession interface {
+	ChatSession
+	// SendMessageStream sends a message like SendMessage, calling onText with
+	// each piece of the response text in order before returning the response
+	SendMessageStream(ctx context.Context, message entities.Message, onText func(text string)) (entities.Message, error)
+}
+
+// ChatSessionHealth is implemented by a ChatSession that can tell whether it
+// is still fit for the next message, e.g. one whose last request failed at
+// the provider and was answered with a fallback instead
+type ChatSessionHealth interface {
+	Healthy() bool
+}
+
+// NameDetector finds the names of people mentioned in a text, so that they
+// can be redacted before the text is stored
+type NameDetector interface {
+	DetectNames(ctx context.Context, text string) ([]string, error)
+}
+
+// ParentSummarizer is implemented by a LargeLanguageModel that can summarize
+// the messages of a session for the child's parent
+type ParentSummarizer interface {
+	SummarizeForParent(ctx context.Context, messages []entities.Message) (*entities.ParentSummary, error)
+}
+
+// ContextSummarizer is implemented by a LargeLanguageModel that can summarize
+// the chat context of a long session, so that a fresh chat can continue from
+// the summary. summary is that of the conversation before messages, empty for
+// none.
+type ContextSummarizer interface {
+	SummarizeContext(ctx context.Context, summary string, messages []entities.Message) (string, error)
+}
+
+// WordExtractor is implemented by a LargeLanguageModel that can pick the
+// notable new words of a turn, such as "fotosintesis" in an answer about
+// plants, for the child's vocabulary. known are words the child already
+// has, which are not picked again.
+type WordExtractor interface {
+	ExtractLearnedWords(ctx context.Context, child, doll entities.Message, known []string) ([]entities.LearnedWord, error)
+}
+
+// ModelNamer is implemented by a LargeLanguageModel that can name the model
+// answering, for logs
+type ModelNamer interface {
+	ModelName() string
+}
+
+// ToolRegistry runs the functions a LargeLanguageModel calls while answering
+type ToolRegistry interface {
+	// CallFunction runs the named function with the arguments the model gave.
+	// The returned text, if any, is spoken when the model said nothing itself.
+	CallFunction(ctx context.Context, name string, args map[string]any) (string, error)
+}
+
+type childAgeKey struct{}
+
+// WithChildAge returns a context carrying the age of the child talking to the
+// doll so that LargeLanguageModel implementations can adapt their responses
+func WithChildAge(ctx context.Context, age int) context.Context {
+	return context.WithValue(ctx, childAgeKey{}, age)
+}
+
+// ChildAgeFromContext returns the age set with WithChildAge
+func ChildAgeFromContext(ctx context.Context) (int, bool) {
+	age, ok := ctx.Value(childAgeKey{}).(int)
+	return age, ok
+}
+
+type discouragedTopicsKey struct{}
+
+// WithDiscouragedTopics returns a context carrying the topics the child's
+// parent wants the doll to steer away from
+func WithDiscouragedTopics(ctx context.Context, topics []string) context.Context {
+	return context.WithValue(ctx, discouragedTopicsKey{}, topics)
+}
+
+// DiscouragedTopicsFromContext returns the topics set with WithDiscouragedTopics
+func DiscouragedTopicsFromContext(ctx context.Context) []string {
+	topics, _ := ctx.Value(discouragedTopicsKey{}).([]string)
+	return topics
+}
+
+type localTimeKey struct{}
+
+// WithLocalTime returns a context carrying the current time in the child's
+// timezone, so that LargeLanguageModel implementations can greet and suggest
+// activities fitting the time of day
+func WithLocalTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, localTimeKey{}, t)
+}
+
+// LocalTimeFromContext returns the time set with WithLocalTime
+func LocalTimeFromContext(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(localTimeKey{}).(time.Time)
+	return t, ok
+}
+
+type replyLanguageKey struct{}
+
+// WithReplyLanguage returns a context carrying the BCP-47 language the doll
+// must answer in, even when the child speaks another language
+func WithReplyLanguage(ctx context.Context, language string) context.Context {
+	return context.WithValue(ctx, replyLanguageKey{}, language)
+}
+
+// ReplyLanguageFromContext returns the language set with WithReplyLanguage
+func ReplyLanguageFromContext(ctx context.Context) (string, bool) {
+	language, ok := ctx.Value(replyLanguageKey{}).(string)
+	return language, ok && language != ""
+}
+
+type dailyTopicKey struct{}
+
+// WithDailyTopic returns a context carrying the theme of the day the doll may
+// suggest to the child, in the language of the conversation
+func WithDailyTopic(ctx context.Context, topic string) context.Context {
+	return context.WithValue(ctx, dailyTopicKey{}, topic)
+}
+
+// DailyTopicFromContext returns the topic set with WithDailyTopic
+func DailyTopicFromContext(ctx context.Context) (string, bool) {
+	topic, ok := ctx.Value(dailyTopicKey{}).(string)
+	return topic, ok && topic != ""
+}
+
+type rephraseKey struct{}
+
+// WithRephrase returns a context carrying the doll's previous response, which
+// the child did not understand: the reply explains it again more simply
+// instead of answering the message as a new question
+func WithRephrase(ctx context.Context, previous string) context.Context {
+	return context.WithValue(ctx, rephraseKey{}, previous)
+}
+
+// RephraseFromContext returns the previous response set with WithRephrase
+func RephraseFromContext(ctx context.Context) (string, bool) {
+	previous, ok := ctx.Value(rephraseKey{}).(string)
+	return previous, ok && previous != ""
+}
+
+type resumeTopicKey struct{}
+
+// WithResumeTopic returns a context carrying what the session was last about
+// when the child returns to it after a break: the reply starts by welcoming
+// the child back to it before answering the message
+func WithResumeTopic(ctx context.Context, topic string) context.Context {
+	return context.WithValue(ctx, resumeTopicKey{}, topic)
+}
+
+// ResumeTopicFromContext returns the topic set with WithResumeTopic
+func ResumeTopicFromContext(ctx context.Context) (string, bool) {
+	topic, ok := ctx.Value(resumeTopicKey{}).(string)
+	return topic, ok && topic != ""
+}
+
+type intentSegmentationKey struct{}
+
+// WithIntentSegmentation returns a context asking for a reply that lists the
+// separate things the child asked in the message and answers each in turn
+func WithIntentSegmentation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, intentSegmentationKey{}, true)
+}
+
+// IntentSegmentationFromContext reports whether the context was set with
+// WithIntentSegmentation
+func IntentSegmentationFromContext(ctx context.Context) bool {
+	segmented, _ := ctx.Value(intentSegmentationKey{}).(bool)
+	return segmented
+}
+
+type contextSummaryKey struct{}
+
+// WithContextSummary returns a context carrying the summary of the
+// conversation a fresh chat session continues
+func WithContextSummary(ctx context.Context, summary string) context.Context {
+	return context.WithValue(ctx, contextSummaryKey{}, summary)
+}
+
+// ContextSummaryFromContext returns the summary set with WithContextSummary
+func ContextSummaryFromContext(ctx context.Context) (string, bool) {
+	summary, ok := ctx.Value(contextSummaryKey{}).(string)
+	return summary, ok && summary != ""
+}
+
+type learnedWordsKey struct{}
+
+// WithLearnedWords returns a context carrying words the child learned lately,
+// which a chat session reinforces now and then
+func WithLearnedWords(ctx context.Context, words []string) context.Context {
+	return context.WithValue(ctx, learnedWordsKey{}, words)
+}
+
+// LearnedWordsFromContext returns the words set with WithLearnedWords
+func LearnedWordsFromContext(ctx context.Context) []string {
+	words, _ := ctx.Value(learnedWordsKey{}).([]string)
+	return words
+}
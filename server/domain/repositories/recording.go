@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// AudioRecorder keeps the audio devices stream, for export and debugging.
+// Only the audio of children whose parent consented is handed to it.
+type AudioRecorder interface {
+	// RecordAudio appends a chunk of the audio of a session. It is called on
+	// the audio path, so it must not block.
+	RecordAudio(ctx context.Context, sessionID string, audio []byte) error
+}
+
+// TurnSink stores the lineage of conversation turns, for training. Only the
+// turns of children whose parent consented are handed to it, redacted. It is
+// called in the background, off the path of the child's response.
+type TurnSink interface {
+	RecordTurn(ctx context.Context, turn entities.TurnRecorded) error
+}
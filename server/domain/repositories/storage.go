@@ -2,6 +2,8 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/satriahrh/arunika/server/domain/entities"
 )
@@ -21,15 +23,91 @@ type DeviceRepository interface {
 	GetByID(ctx context.Context, id string) (*entities.Device, error)
 	GetBySerialNumber(ctx context.Context, serialNumber string) (*entities.Device, error)
 	GetByOwnerID(ctx context.Context, ownerID string) ([]*entities.Device, error)
+	// Update stores device, failing with errs.ErrDeviceModified when the
+	// stored device was updated since device was read, as told by UpdatedAt.
+	// A device with a zero UpdatedAt is stored whatever changed meanwhile.
 	Update(ctx context.Context, device *entities.Device) error
 	Delete(ctx context.Context, id string) error
 	// ValidateDevice validates device credentials for authentication
 	ValidateDevice(serialNumber, secret string) (*entities.Device, error)
 }
 
+// DeviceCredentials is a device to register together with the secret it
+// authenticates with
+type DeviceCredentials struct {
+	Device *entities.Device
+	Secret string
+}
+
+// DeviceProvisioner registers devices in bulk for manufacturers
+type DeviceProvisioner interface {
+	// ImportDevices registers every device whose serial number is not known
+	// yet, all or none, and returns the serial numbers that were already
+	// registered. Those devices and their secrets are left untouched.
+	ImportDevices(ctx context.Context, devices []DeviceCredentials) (existing []string, err error)
+	// ListDevices returns every registered device ordered by serial number
+	ListDevices(ctx context.Context) ([]*entities.Device, error)
+}
+
+// ChildRepository defines data access methods for child profiles
+type ChildRepository interface {
+	Create(ctx context.Context, child *entities.Child) error
+	GetByID(ctx context.Context, id string) (*entities.Child, error)
+	GetByOwnerID(ctx context.Context, ownerID string) ([]*entities.Child, error)
+	// GetByDeviceID returns the child a device is assigned to
+	GetByDeviceID(ctx context.Context, deviceID string) (*entities.Child, error)
+	Update(ctx context.Context, child *entities.Child) error
+	Delete(ctx context.Context, id string) error
+}
+
+// ErrInvalidSessionID is wrapped by SessionRepository errors for session IDs
+// the storage cannot address, such as a malformed MongoDB ObjectID
+var ErrInvalidSessionID = errors.New("invalid session ID")
+
+// ErrActiveSessionExists is wrapped by SessionRepository.Create errors of
+// storages that allow a single active session per device, when the device
+// still has one
+var ErrActiveSessionExists = errors.New("device already has an active session")
+
 // SessionRepository defines data access methods for device sessions
 type SessionRepository interface {
 	Create(ctx context.Context, session *entities.Session) error
 	GetLastByDeviceID(ctx context.Context, deviceID string) (*entities.Session, error)
 	Update(ctx context.Context, session *entities.Session) error
+	// DeleteByDeviceID removes every session of a device and returns how many were removed
+	DeleteByDeviceID(ctx context.Context, deviceID string) (int64, error)
+	// Search returns up to limit messages of a device containing any of the
+	// sanitized query's words, newest first
+	Search(ctx context.Context, deviceID, query string, limit int) ([]entities.MessageMatch, error)
+	// ListByDeviceID returns up to limit sessions of a device, the most
+	// recently active first
+	ListByDeviceID(ctx context.Context, deviceID string, limit int) ([]*entities.Session, error)
+	// ExpireSessions marks active sessions without messages since cutoff as
+	// ended and returns how many were marked
+	ExpireSessions(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// ChildSessionFinder is implemented by a SessionRepository that can find the
+// sessions of a child across the devices they talked through
+type ChildSessionFinder interface {
+	// GetLastByChildID returns the child's most recently active session, or
+	// nil when they have none
+	GetLastByChildID(ctx context.Context, childID string) (*entities.Session, error)
+}
+
+// ChildSessionEraser is implemented by a SessionRepository that can erase the
+// sessions of a child, including those of devices no longer bound to them
+type ChildSessionEraser interface {
+	// DeleteByChildID removes every session of a child and returns how many
+	// were removed
+	DeleteByChildID(ctx context.Context, childID string) (int64, error)
+}
+
+// QuietHoursRepository defines data access methods for quiet hours schedules
+type QuietHoursRepository interface {
+	// GetByChildID returns the child's schedule, or nil when none is set
+	GetByChildID(ctx context.Context, childID string) (*entities.QuietHours, error)
+	// Upsert creates or replaces the child's schedule
+	Upsert(ctx context.Context, quietHours *entities.QuietHours) error
+	DeleteByChildID(ctx context.Context, childID string) error
 }
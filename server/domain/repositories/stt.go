@@ -17,7 +17,22 @@ type AudioConfig struct {
 	Language   string `json:"language"`
 }
 
+// SpeechToTextStreaming is the transcription of one utterance. Every stream
+// must be either ended or closed so that it releases its resources.
 type SpeechToTextStreaming interface {
 	Stream(data []byte) error
 	End() (string, error)
+	// Close releases the stream without waiting for its transcription, and
+	// returns once the goroutines of the stream stopped. It may be called
+	// after End and more than once.
+	Close() error
+}
+
+// TranscriptionConfidence is implemented by a SpeechToTextStreaming that can
+// tell how confident it is of the transcription End returned
+type TranscriptionConfidence interface {
+	// Confidence returns the confidence of the final transcription, between
+	// 0 and 1, and false when the service reported none. It is only
+	// meaningful once End returned.
+	Confidence() (float64, bool)
 }
@@ -1,7 +1,139 @@
 package repositories
 
-import "context"
+import (
+	"context"
+	"errors"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
 
 type TextToSpeech interface {
 	ConvertTextToSpeech(ctx context.Context, text string) (<-chan []byte, error)
 }
+
+type chunkSizeKey struct{}
+
+// WithChunkSize returns a context that asks TextToSpeech implementations to
+// stream audio in chunks of size bytes instead of their configured default
+func WithChunkSize(ctx context.Context, size int) context.Context {
+	return context.WithValue(ctx, chunkSizeKey{}, size)
+}
+
+// ChunkSizeFromContext returns the chunk size requested with WithChunkSize
+func ChunkSizeFromContext(ctx context.Context) (int, bool) {
+	size, ok := ctx.Value(chunkSizeKey{}).(int)
+	return size, ok && size > 0
+}
+
+type languageKey struct{}
+
+// WithLanguage returns a context carrying the BCP-47 language of the
+// conversation (e.g. "id-ID") so that TextToSpeech implementations can
+// pick a matching voice
+func WithLanguage(ctx context.Context, language string) context.Context {
+	return context.WithValue(ctx, languageKey{}, language)
+}
+
+// LanguageFromContext returns the language set with WithLanguage
+func LanguageFromContext(ctx context.Context) (string, bool) {
+	language, ok := ctx.Value(languageKey{}).(string)
+	return language, ok && language != ""
+}
+
+type voiceIDKey struct{}
+
+// WithVoiceID returns a context asking TextToSpeech implementations to speak
+// with the given voice instead of the one configured for the language
+func WithVoiceID(ctx context.Context, voiceID string) context.Context {
+	return context.WithValue(ctx, voiceIDKey{}, voiceID)
+}
+
+// VoiceIDFromContext returns the voice set with WithVoiceID
+func VoiceIDFromContext(ctx context.Context) (string, bool) {
+	voiceID, ok := ctx.Value(voiceIDKey{}).(string)
+	return voiceID, ok && voiceID != ""
+}
+
+type sampleRateKey struct{}
+
+// WithSampleRate returns a context asking TextToSpeech implementations to
+// produce PCM audio at rate Hz, the rate negotiated with the device
+func WithSampleRate(ctx context.Context, rate int) context.Context {
+	return context.WithValue(ctx, sampleRateKey{}, rate)
+}
+
+// SampleRateFromContext returns the sample rate set with WithSampleRate
+func SampleRateFromContext(ctx context.Context) (int, bool) {
+	rate, ok := ctx.Value(sampleRateKey{}).(int)
+	return rate, ok && rate > 0
+}
+
+// SynthesisPriority orders syntheses waiting for the text-to-speech provider
+type SynthesisPriority int
+
+const (
+	// PriorityRealtime is speech a child is waiting for, the default
+	PriorityRealtime SynthesisPriority = iota
+	// PriorityBackground is speech synthesized ahead of time, such as pre-warmed phrases
+	PriorityBackground
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a context whose syntheses queue with priority when the
+// provider is busy
+func WithPriority(ctx context.Context, priority SynthesisPriority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// PriorityFromContext returns the priority set with WithPriority, PriorityRealtime otherwise
+func PriorityFromContext(ctx context.Context) SynthesisPriority {
+	priority, _ := ctx.Value(priorityKey{}).(SynthesisPriority)
+	return priority
+}
+
+// ErrTTSQuotaExceeded is returned when the text-to-speech provider refuses to
+// synthesize because the account is out of quota; retrying does not help
+var ErrTTSQuotaExceeded = errors.New("text-to-speech quota exceeded")
+
+// ErrVoiceLimitReached is returned when an account already owns the maximum
+// number of custom voices
+var ErrVoiceLimitReached = errors.New("custom voice limit reached")
+
+// VoiceSample is a recording used to clone a voice
+type VoiceSample struct {
+	Filename string
+	Data     []byte
+}
+
+// VoiceValidator checks voice IDs before they are used to synthesize speech
+type VoiceValidator interface {
+	// VoiceExists reports whether the provider can still synthesize with voiceID
+	VoiceExists(ctx context.Context, voiceID string) (bool, error)
+}
+
+// VoiceLanguageValidator checks that a voice speaks the language it is asked
+// to synthesize
+type VoiceLanguageValidator interface {
+	// VoiceSupportsLanguage reports whether voiceID speaks the BCP-47
+	// language. Voices whose languages the provider does not tell speak any.
+	VoiceSupportsLanguage(ctx context.Context, voiceID, language string) (bool, error)
+}
+
+// PCMReporter is implemented by a TextToSpeech that can tell whether the
+// audio it streams is 16-bit PCM samples
+type PCMReporter interface {
+	// OutputsPCM reports whether synthesized audio is 16-bit little-endian PCM
+	OutputsPCM() bool
+}
+
+// VoiceManager manages the voices available to parent accounts
+type VoiceManager interface {
+	// ListVoices returns the stock voices and the custom voices of ownerID
+	ListVoices(ctx context.Context, ownerID string) ([]entities.Voice, error)
+	// CreateVoice clones a voice from samples on behalf of ownerID
+	CreateVoice(ctx context.Context, ownerID, name string, samples []VoiceSample) (*entities.Voice, error)
+	// RefreshVoices lists the voices of the provider again instead of using
+	// those cached, returning how many there are
+	RefreshVoices(ctx context.Context) (int, error)
+}
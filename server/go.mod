@@ -13,6 +13,7 @@ require (
 	github.com/labstack/echo/v4 v4.11.1
 	go.uber.org/zap v1.25.0
 	google.golang.org/genai v1.21.0
+	google.golang.org/grpc v1.73.0
 )
 
 require (
@@ -60,6 +61,5 @@ require (
 	google.golang.org/api v0.237.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250603155806-513f23925822 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250603155806-513f23925822 // indirect
-	google.golang.org/grpc v1.73.0 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
 )
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+	"github.com/satriahrh/arunika/server/internal/audit"
+)
+
+const (
+	maxDeviceImportBatch = 1000
+	maxDeviceImportBytes = 1 << 20
+	deviceSecretBytes    = 16
+)
+
+// errImportTooLarge is returned by parseDeviceImport for batches over maxDeviceImportBatch
+var errImportTooLarge = errors.New("too many devices")
+
+// importDevices provisions a batch of devices from a JSON or CSV manifest and
+// returns the secrets of the devices it created. Importing the same manifest
+// again creates nothing and returns no secrets.
+func importDevices(c echo.Context, provisioner repositories.DeviceProvisioner, trail audit.Trail, logger *zap.Logger) error {
+	claims := claimsFromContext(c)
+
+	entries, err := parseDeviceImport(c)
+	if errors.Is(err, errImportTooLarge) {
+		return c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{
+			Error:   "batch_too_large",
+			Message: "Import at most " + strconv.Itoa(maxDeviceImportBatch) + " devices at a time",
+		})
+	}
+	if err != nil || len(entries) == 0 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Provide devices as JSON or as CSV rows of serial_number,model",
+		})
+	}
+
+	seen := make(map[string]bool, len(entries))
+	batch := make([]repositories.DeviceCredentials, 0, len(entries))
+	for _, entry := range entries {
+		device := &entities.Device{SerialNumber: entry.SerialNumber, Model: entry.Model}
+		if err := device.Validate(); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_device",
+				Message: "Device " + strconv.Quote(entry.SerialNumber) + ": " + err.Error(),
+			})
+		}
+		if seen[entry.SerialNumber] {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "duplicate_serial_number",
+				Message: "Serial number " + strconv.Quote(entry.SerialNumber) + " appears more than once",
+			})
+		}
+		seen[entry.SerialNumber] = true
+
+		secret, err := generateDeviceSecret()
+		if err != nil {
+			logger.Error("Failed to generate device secret", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "import_failed",
+				Message: "Failed to import devices",
+			})
+		}
+		batch = append(batch, repositories.DeviceCredentials{Device: device, Secret: secret})
+	}
+
+	existing, err := provisioner.ImportDevices(c.Request().Context(), batch)
+	if err != nil {
+		logger.Error("Failed to import devices",
+			zap.Int("devices", len(batch)),
+			zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "import_failed",
+			Message: "Failed to import devices",
+		})
+	}
+
+	alreadyRegistered := make(map[string]bool, len(existing))
+	for _, serialNumber := range existing {
+		alreadyRegistered[serialNumber] = true
+	}
+
+	resp := DeviceImportResponse{Devices: make([]ImportedDevice, 0, len(batch))}
+	for _, credentials := range batch {
+		imported := ImportedDevice{
+			SerialNumber: credentials.Device.SerialNumber,
+			Model:        credentials.Device.Model,
+		}
+		if alreadyRegistered[imported.SerialNumber] {
+			imported.Status = "exists"
+			resp.Existing++
+		} else {
+			imported.Status = "created"
+			imported.DeviceID = credentials.Device.ID
+			imported.SecretKey = credentials.Secret
+			resp.Created++
+		}
+		resp.Devices = append(resp.Devices, imported)
+	}
+
+	trail.Record(c.Request().Context(), audit.Entry{
+		Action:  "devices_imported",
+		ActorID: claims.UserID,
+		Details: map[string]interface{}{
+			"created":  resp.Created,
+			"existing": resp.Existing,
+		},
+	})
+
+	logger.Info("Devices imported",
+		zap.String("user_id", claims.UserID),
+		zap.Int("created", resp.Created),
+		zap.Int("existing", resp.Existing))
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// exportDevices returns the manifest of every provisioned device without
+// secrets, as JSON or as CSV with format=csv
+func exportDevices(c echo.Context, provisioner repositories.DeviceProvisioner, logger *zap.Logger) error {
+	devices, err := provisioner.ListDevices(c.Request().Context())
+	if err != nil {
+		logger.Error("Failed to list devices", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "export_failed",
+			Message: "Failed to export devices",
+		})
+	}
+
+	manifest := make([]DeviceManifestEntry, 0, len(devices))
+	for _, device := range devices {
+		entry := DeviceManifestEntry{
+			DeviceID:        device.ID,
+			SerialNumber:    device.SerialNumber,
+			Model:           device.Model,
+			Owned:           device.OwnerID != nil,
+			CreatedAt:       device.CreatedAt,
+			Online:          device.Online,
+			UptimeSeconds:   device.UptimeSeconds,
+			DowntimeSeconds: device.DowntimeSeconds,
+		}
+		if !device.LastConnectedAt.IsZero() {
+			entry.LastConnectedAt = &device.LastConnectedAt
+		}
+		manifest = append(manifest, entry)
+	}
+
+	if c.QueryParam("format") != "csv" {
+		return c.JSON(http.StatusOK, DeviceExportResponse{Count: len(manifest), Devices: manifest})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set("Content-Disposition", `attachment; filename="devices.csv"`)
+	c.Response().WriteHeader(http.StatusOK)
+	w := csv.NewWriter(c.Response())
+	w.Write([]string{"serial_number", "model", "device_id", "owned", "created_at"})
+	for _, entry := range manifest {
+		w.Write([]string{
+			entry.SerialNumber,
+			entry.Model,
+			entry.DeviceID,
+			strconv.FormatBool(entry.Owned),
+			entry.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// parseDeviceImport reads the devices of an import request. CSV bodies hold
+// serial_number,model rows with an optional header row.
+func parseDeviceImport(c echo.Context) ([]DeviceImportEntry, error) {
+	body := http.MaxBytesReader(c.Response(), c.Request().Body, maxDeviceImportBytes)
+
+	var entries []DeviceImportEntry
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), "text/csv") {
+		reader := csv.NewReader(body)
+		reader.FieldsPerRecord = 2
+		reader.TrimLeadingSpace = true
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if len(entries) == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "serial_number") {
+				continue
+			}
+			entries = append(entries, DeviceImportEntry{
+				SerialNumber: strings.TrimSpace(record[0]),
+				Model:        strings.TrimSpace(record[1]),
+			})
+			if len(entries) > maxDeviceImportBatch {
+				return nil, errImportTooLarge
+			}
+		}
+		return entries, nil
+	}
+
+	var req DeviceImportRequest
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		return nil, err
+	}
+	if len(req.Devices) > maxDeviceImportBatch {
+		return nil, errImportTooLarge
+	}
+	for i := range req.Devices {
+		req.Devices[i].SerialNumber = strings.TrimSpace(req.Devices[i].SerialNumber)
+		req.Devices[i].Model = strings.TrimSpace(req.Devices[i].Model)
+	}
+	return req.Devices, nil
+}
+
+// generateDeviceSecret returns a random secret for a new device
+func generateDeviceSecret() (string, error) {
+	secret := make([]byte, deviceSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
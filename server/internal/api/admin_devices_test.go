@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/internal/auth"
+)
+
+type adminDevicesFixture struct {
+	echo    *echo.Echo
+	devices *adapters.MemoryDeviceRepository
+	trail   *recordingTrail
+}
+
+func newAdminDevicesFixture(t *testing.T) *adminDevicesFixture {
+	t.Helper()
+	logger := zaptest.NewLogger(t)
+	f := &adminDevicesFixture{
+		echo:    echo.New(),
+		devices: adapters.NewMemoryDeviceRepository(),
+		trail:   &recordingTrail{},
+	}
+
+	f.echo.POST("/api/v1/device/auth", func(c echo.Context) error {
+		return deviceAuth(c, f.devices, nil, logger)
+	})
+	f.echo.POST("/api/v1/admin/devices/import", func(c echo.Context) error {
+		return importDevices(c, f.devices, f.trail, logger)
+	}, requireRole("admin", logger))
+	f.echo.GET("/api/v1/admin/devices/export", func(c echo.Context) error {
+		return exportDevices(c, f.devices, logger)
+	}, requireRole("admin", logger))
+
+	return f
+}
+
+func (f *adminDevicesFixture) do(t *testing.T, method, path, token, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set(echo.HeaderContentType, contentType)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	f.echo.ServeHTTP(rec, req)
+	return rec
+}
+
+func (f *adminDevicesFixture) importDevices(t *testing.T, contentType, body string) DeviceImportResponse {
+	t.Helper()
+	rec := f.do(t, http.MethodPost, "/api/v1/admin/devices/import", adminToken(t), contentType, body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp DeviceImportResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return resp
+}
+
+func adminToken(t *testing.T) string {
+	t.Helper()
+	token, err := auth.GenerateAdminToken("admin-1")
+	if err != nil {
+		t.Fatalf("Failed to generate admin token: %v", err)
+	}
+	return token
+}
+
+func TestImportDevices_DevicesAuthenticateAndReimportIsIdempotent(t *testing.T) {
+	f := newAdminDevicesFixture(t)
+	manifest := "serial_number,model\nARU-0001,doll-v1\nARU-0002,doll-v1\nARU-0003,doll-v2\n"
+
+	first := f.importDevices(t, "text/csv", manifest)
+	if first.Created != 3 || first.Existing != 0 || len(first.Devices) != 3 {
+		t.Fatalf("Expected 3 devices created, got %+v", first)
+	}
+
+	for _, device := range first.Devices {
+		if device.Status != "created" || device.SecretKey == "" || device.DeviceID == "" {
+			t.Fatalf("Expected a created device with a secret, got %+v", device)
+		}
+		body := `{"serial_number":"` + device.SerialNumber + `","secret_key":"` + device.SecretKey + `"}`
+		rec := f.do(t, http.MethodPost, "/api/v1/device/auth", "", echo.MIMEApplicationJSON, body)
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected %s to authenticate with its secret, got %d: %s", device.SerialNumber, rec.Code, rec.Body.String())
+		}
+	}
+
+	// The same batch as JSON, plus one new device
+	second := f.importDevices(t, echo.MIMEApplicationJSON, `{"devices":[
+		{"serial_number":"ARU-0001","model":"doll-v1"},
+		{"serial_number":"ARU-0002","model":"doll-v1"},
+		{"serial_number":"ARU-0003","model":"doll-v2"},
+		{"serial_number":"ARU-0004","model":"doll-v2"}]}`)
+	if second.Created != 1 || second.Existing != 3 {
+		t.Fatalf("Expected only the new device created, got %+v", second)
+	}
+	for _, device := range second.Devices[:3] {
+		if device.Status != "exists" || device.SecretKey != "" {
+			t.Errorf("Expected an existing device without a secret, got %+v", device)
+		}
+	}
+
+	// Secrets from the first import still work
+	body := `{"serial_number":"ARU-0001","secret_key":"` + first.Devices[0].SecretKey + `"}`
+	if rec := f.do(t, http.MethodPost, "/api/v1/device/auth", "", echo.MIMEApplicationJSON, body); rec.Code != http.StatusOK {
+		t.Errorf("Expected re-import to keep the original secret, got %d", rec.Code)
+	}
+
+	devices, _ := f.devices.ListDevices(context.Background())
+	if len(devices) != 4 {
+		t.Errorf("Expected 4 devices without duplicates, got %d", len(devices))
+	}
+	if len(f.trail.entries) != 2 || f.trail.entries[0].Action != "devices_imported" {
+		t.Errorf("Expected an audit entry per import, got %+v", f.trail.entries)
+	}
+}
+
+func TestImportDevices_RejectsInvalidBatches(t *testing.T) {
+	f := newAdminDevicesFixture(t)
+
+	var oversized strings.Builder
+	for i := 0; i <= maxDeviceImportBatch; i++ {
+		oversized.WriteString("ARU-" + strconv.Itoa(i) + ",doll-v1\n")
+	}
+
+	tests := []struct {
+		name        string
+		token       string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{"user token", userToken(t, "owner-1"), "text/csv", "ARU-1,doll-v1\n", http.StatusForbidden},
+		{"missing token", "", "text/csv", "ARU-1,doll-v1\n", http.StatusUnauthorized},
+		{"duplicate serial", adminToken(t), "text/csv", "ARU-1,doll-v1\nARU-1,doll-v2\n", http.StatusBadRequest},
+		{"missing model", adminToken(t), echo.MIMEApplicationJSON, `{"devices":[{"serial_number":"ARU-1"}]}`, http.StatusBadRequest},
+		{"empty batch", adminToken(t), echo.MIMEApplicationJSON, `{"devices":[]}`, http.StatusBadRequest},
+		{"too many devices", adminToken(t), "text/csv", oversized.String(), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := f.do(t, http.MethodPost, "/api/v1/admin/devices/import", tt.token, tt.contentType, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	if devices, _ := f.devices.ListDevices(context.Background()); len(devices) != 0 {
+		t.Errorf("Expected rejected batches to create no device, got %d", len(devices))
+	}
+}
+
+func TestExportDevices_OmitsSecrets(t *testing.T) {
+	f := newAdminDevicesFixture(t)
+	imported := f.importDevices(t, "text/csv", "ARU-0002,doll-v1\nARU-0001,doll-v2\n")
+
+	rec := f.do(t, http.MethodGet, "/api/v1/admin/devices/export", adminToken(t), "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, device := range imported.Devices {
+		if strings.Contains(rec.Body.String(), device.SecretKey) {
+			t.Fatal("Expected the manifest not to contain secrets")
+		}
+	}
+	var resp DeviceExportResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Count != 2 || resp.Devices[0].SerialNumber != "ARU-0001" || resp.Devices[1].SerialNumber != "ARU-0002" {
+		t.Errorf("Expected both devices ordered by serial number, got %+v", resp)
+	}
+
+	rec = f.do(t, http.MethodGet, "/api/v1/admin/devices/export?format=csv", adminToken(t), "", "")
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 3 || lines[0] != "serial_number,model,device_id,owned,created_at" || !strings.HasPrefix(lines[1], "ARU-0001,doll-v2,") {
+		t.Errorf("Unexpected CSV manifest: %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/internal/audit"
+	"github.com/satriahrh/arunika/server/internal/websocket"
+)
+
+// systemBroadcaster sends system messages to the connected devices a
+// selector picks
+type systemBroadcaster interface {
+	Broadcast(selector websocket.DeviceSelector, message websocket.SystemMessage) int
+}
+
+// broadcastSystemMessage sends a system message to the connected devices
+// the selector of the request picks, by model, owner or firmware version,
+// and reports how many it was sent to. Devices that are offline miss it.
+func broadcastSystemMessage(c echo.Context, broadcaster systemBroadcaster, trail audit.Trail, logger *zap.Logger) error {
+	claims := claimsFromContext(c)
+
+	var req BroadcastRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request format",
+		})
+	}
+	if strings.TrimSpace(req.Message.Text) == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_fields",
+			Message: "Message text is required",
+		})
+	}
+
+	selector := websocket.DeviceSelector{
+		Model:           strings.TrimSpace(req.Selector.Model),
+		OwnerID:         strings.TrimSpace(req.Selector.OwnerID),
+		FirmwareVersion: strings.TrimSpace(req.Selector.FirmwareVersion),
+	}
+	delivered := broadcaster.Broadcast(selector, websocket.SystemMessage{
+		Kind: req.Message.Kind,
+		Text: req.Message.Text,
+	})
+
+	trail.Record(c.Request().Context(), audit.Entry{
+		Action:  "system_message_broadcast",
+		ActorID: claims.UserID,
+		Details: map[string]interface{}{
+			"model":            selector.Model,
+			"owner_id":         selector.OwnerID,
+			"firmware_version": selector.FirmwareVersion,
+			"kind":             req.Message.Kind,
+			"delivered":        delivered,
+		},
+	})
+
+	logger.Info("System message broadcast",
+		zap.String("user_id", claims.UserID),
+		zap.Int("delivered", delivered))
+
+	return c.JSON(http.StatusOK, BroadcastResponse{Delivered: delivered})
+}
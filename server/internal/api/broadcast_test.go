@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/internal/websocket"
+)
+
+// fakeBroadcaster records the broadcasts and delivers each to two devices
+type fakeBroadcaster struct {
+	selectors []websocket.DeviceSelector
+	messages  []websocket.SystemMessage
+}
+
+func (f *fakeBroadcaster) Broadcast(selector websocket.DeviceSelector, message websocket.SystemMessage) int {
+	f.selectors = append(f.selectors, selector)
+	f.messages = append(f.messages, message)
+	return 2
+}
+
+func TestBroadcastSystemMessage(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	broadcaster := &fakeBroadcaster{}
+	trail := &recordingTrail{}
+	e := echo.New()
+	e.POST("/api/v1/admin/broadcast", func(c echo.Context) error {
+		return broadcastSystemMessage(c, broadcaster, trail, logger)
+	}, requireRole("admin", logger))
+	broadcast := func(body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/broadcast", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := broadcast(`{"selector": {"model": "doll-v2"}, "message": {"kind": "maintenance", "text": "Pembaruan malam ini"}}`, adminToken(t))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp BroadcastResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Delivered != 2 {
+		t.Fatalf("Expected 2 deliveries, got %s, %v", rec.Body.String(), err)
+	}
+	if len(broadcaster.selectors) != 1 || broadcaster.selectors[0] != (websocket.DeviceSelector{Model: "doll-v2"}) {
+		t.Errorf("Expected a model selector, got %+v", broadcaster.selectors)
+	}
+	if message := broadcaster.messages[0]; message.Kind != "maintenance" || message.Text != "Pembaruan malam ini" {
+		t.Errorf("Unexpected system message %+v", message)
+	}
+	if len(trail.entries) != 1 || trail.entries[0].Action != "system_message_broadcast" {
+		t.Errorf("Expected the broadcast to be audited, got %+v", trail.entries)
+	}
+
+	if rec := broadcast(`{"selector": {"model": "doll-v2"}, "message": {"text": " "}}`, adminToken(t)); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without text, got %d", rec.Code)
+	}
+	if rec := broadcast(`{"message": {"text": "Halo"}}`, userToken(t, "owner-1")); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a user token, got %d", rec.Code)
+	}
+	if len(broadcaster.messages) != 1 {
+		t.Errorf("Expected a single broadcast, got %d", len(broadcaster.messages))
+	}
+}
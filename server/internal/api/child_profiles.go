@@ -0,0 +1,187 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/errs"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// birthDateLayout is the format of ChildProfileRequest.BirthDate
+const birthDateLayout = "2006-01-02"
+
+// getChildren lists the child profiles of the caller, the oldest first
+func getChildren(c echo.Context, childRepo repositories.ChildRepository, logger *zap.Logger) error {
+	claims := claimsFromContext(c)
+
+	children, err := childRepo.GetByOwnerID(c.Request().Context(), claims.UserID)
+	if err != nil {
+		logger.Error("Failed to list children", zap.String("user_id", claims.UserID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "children_unavailable",
+			Message: "Failed to load child profiles",
+		})
+	}
+
+	return c.JSON(http.StatusOK, ChildrenResponse{Children: children})
+}
+
+// createChild creates a child profile owned by the caller, bound to the
+// devices of the request
+func createChild(c echo.Context, childRepo repositories.ChildRepository, deviceRepo repositories.DeviceRepository, logger *zap.Logger) error {
+	claims := claimsFromContext(c)
+
+	child := &entities.Child{OwnerID: claims.UserID}
+	if ok, err := bindChildProfile(c, child); !ok {
+		return err
+	}
+	if ok, err := checkChildDevices(c, childRepo, deviceRepo, child, logger); !ok {
+		return err
+	}
+
+	if err := childRepo.Create(c.Request().Context(), child); err != nil {
+		logger.Error("Failed to create child", zap.String("user_id", claims.UserID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "create_failed",
+			Message: "Failed to create child profile",
+		})
+	}
+
+	logger.Info("Child profile created",
+		zap.String("child_id", child.ID),
+		zap.String("user_id", claims.UserID),
+		zap.Strings("device_ids", child.DeviceIDs))
+
+	return c.JSON(http.StatusCreated, child)
+}
+
+// updateChild replaces the name, devices and birth date of a child profile.
+// The settings managed by their own endpoints, such as the voice, are kept.
+func updateChild(c echo.Context, childRepo repositories.ChildRepository, deviceRepo repositories.DeviceRepository, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	if ok, err := bindChildProfile(c, child); !ok {
+		return err
+	}
+	if ok, err := checkChildDevices(c, childRepo, deviceRepo, child, logger); !ok {
+		return err
+	}
+
+	if err := childRepo.Update(c.Request().Context(), child); err != nil {
+		logger.Error("Failed to update child", zap.String("child_id", child.ID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "update_failed",
+			Message: "Failed to update child profile",
+		})
+	}
+
+	logger.Info("Child profile updated",
+		zap.String("child_id", child.ID),
+		zap.Strings("device_ids", child.DeviceIDs))
+
+	return c.JSON(http.StatusOK, child)
+}
+
+// bindChildProfile applies the profile of the request to child. On failure
+// the error response has already been written and ok is false.
+func bindChildProfile(c echo.Context, child *entities.Child) (ok bool, err error) {
+	var req ChildProfileRequest
+	if err := c.Bind(&req); err != nil {
+		return false, c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request format",
+		})
+	}
+
+	if req.Name == "" {
+		return false, c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "missing_fields",
+			Message: "Name is required",
+		})
+	}
+
+	var birthDate time.Time
+	if req.BirthDate != "" {
+		birthDate, err = time.Parse(birthDateLayout, req.BirthDate)
+		if err != nil {
+			return false, c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_birth_date",
+				Message: "Birth date must be formatted as YYYY-MM-DD",
+			})
+		}
+	}
+
+	// A device listed twice is bound once
+	deviceIDs := []string{}
+	seen := make(map[string]bool, len(req.DeviceIDs))
+	for _, deviceID := range req.DeviceIDs {
+		if deviceID != "" && !seen[deviceID] {
+			seen[deviceID] = true
+			deviceIDs = append(deviceIDs, deviceID)
+		}
+	}
+
+	child.Name = req.Name
+	child.DeviceIDs = deviceIDs
+	child.BirthDate = birthDate
+	return true, nil
+}
+
+// checkChildDevices verifies that every device of child exists, is not owned
+// by another user and is not bound to another child. On failure the error
+// response has already been written and ok is false.
+func checkChildDevices(c echo.Context, childRepo repositories.ChildRepository, deviceRepo repositories.DeviceRepository, child *entities.Child, logger *zap.Logger) (ok bool, err error) {
+	ctx := c.Request().Context()
+
+	for _, deviceID := range child.DeviceIDs {
+		device, err := deviceRepo.GetByID(ctx, deviceID)
+		if errors.Is(err, errs.ErrDeviceNotFound) {
+			return false, c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "device_not_found",
+				Message: "Device " + deviceID + " does not exist",
+			})
+		}
+		if err != nil {
+			logger.Error("Failed to get device", zap.String("device_id", deviceID), zap.Error(err))
+			return false, c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "device_unavailable",
+				Message: "Failed to load device",
+			})
+		}
+		if device.OwnerID != nil && *device.OwnerID != child.OwnerID {
+			logger.Warn("Child device rejected: device owned by another user",
+				zap.String("device_id", deviceID),
+				zap.String("user_id", child.OwnerID))
+			return false, c.JSON(http.StatusForbidden, ErrorResponse{
+				Error:   "forbidden",
+				Message: "Device " + deviceID + " belongs to another user",
+			})
+		}
+
+		bound, err := childRepo.GetByDeviceID(ctx, deviceID)
+		if err != nil && !errors.Is(err, errs.ErrChildNotFound) {
+			logger.Error("Failed to get child of device", zap.String("device_id", deviceID), zap.Error(err))
+			return false, c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "child_unavailable",
+				Message: "Failed to load child profile",
+			})
+		}
+		if err == nil && bound.ID != child.ID {
+			return false, c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "device_bound",
+				Message: "Device " + deviceID + " is bound to another child",
+			})
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+type childProfilesFixture struct {
+	echo     *echo.Echo
+	children *adapters.MemoryChildRepository
+	devices  *adapters.MemoryDeviceRepository
+}
+
+func newChildProfilesFixture(t *testing.T) *childProfilesFixture {
+	t.Helper()
+	logger := zaptest.NewLogger(t)
+	f := &childProfilesFixture{
+		echo:     echo.New(),
+		children: adapters.NewMemoryChildRepository(),
+		devices:  adapters.NewMemoryDeviceRepository(),
+	}
+
+	otherOwner := "owner-2"
+	for _, device := range []*entities.Device{
+		{ID: "device-1", SerialNumber: "SN-1", SecretKey: "secret", Model: "doll-v1"},
+		{ID: "device-2", SerialNumber: "SN-2", SecretKey: "secret", Model: "doll-v1"},
+		{ID: "device-3", SerialNumber: "SN-3", SecretKey: "secret", Model: "doll-v1", OwnerID: &otherOwner},
+	} {
+		if err := f.devices.Create(context.Background(), device); err != nil {
+			t.Fatalf("Failed to create device: %v", err)
+		}
+	}
+
+	f.echo.GET("/api/v1/children", func(c echo.Context) error {
+		return getChildren(c, f.children, logger)
+	}, requireRole("user", logger))
+	f.echo.POST("/api/v1/children", func(c echo.Context) error {
+		return createChild(c, f.children, f.devices, logger)
+	}, requireRole("user", logger))
+	f.echo.PUT("/api/v1/children/:id", func(c echo.Context) error {
+		return updateChild(c, f.children, f.devices, logger)
+	}, requireRole("user", logger))
+
+	return f
+}
+
+func (f *childProfilesFixture) do(t *testing.T, method, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	f.echo.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestChildProfiles_CreateListAndUpdate(t *testing.T) {
+	f := newChildProfilesFixture(t)
+	token := userToken(t, "owner-1")
+
+	rec := f.do(t, http.MethodPost, "/api/v1/children", token, `{"name":"Kirana","device_ids":["device-1","device-1"],"birth_date":"2019-04-02"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created entities.Child
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if created.ID == "" || created.OwnerID != "owner-1" || !reflect.DeepEqual(created.DeviceIDs, []string{"device-1"}) {
+		t.Errorf("Unexpected child created: %+v", created)
+	}
+	if bound, err := f.children.GetByDeviceID(context.Background(), "device-1"); err != nil || bound.ID != created.ID {
+		t.Errorf("Expected device-1 bound to the new child, got %v, %v", bound, err)
+	}
+
+	rec = f.do(t, http.MethodPut, "/api/v1/children/"+created.ID, token, `{"name":"Kirana Putri","device_ids":["device-2"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	stored, _ := f.children.GetByID(context.Background(), created.ID)
+	if stored.Name != "Kirana Putri" || !reflect.DeepEqual(stored.DeviceIDs, []string{"device-2"}) || !stored.BirthDate.IsZero() {
+		t.Errorf("Unexpected child stored: %+v", stored)
+	}
+
+	rec = f.do(t, http.MethodGet, "/api/v1/children", token, "")
+	var list ChildrenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(list.Children) != 1 || list.Children[0].ID != created.ID {
+		t.Errorf("Expected the child listed, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = f.do(t, http.MethodGet, "/api/v1/children", userToken(t, "owner-2"), "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(list.Children) != 0 {
+		t.Errorf("Expected no child listed for another user, got %v", list.Children)
+	}
+}
+
+func TestChildProfiles_RejectsDevices(t *testing.T) {
+	f := newChildProfilesFixture(t)
+	token := userToken(t, "owner-1")
+	if rec := f.do(t, http.MethodPost, "/api/v1/children", token, `{"name":"Kirana","device_ids":["device-1"]}`); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"missing name", `{"device_ids":["device-2"]}`, http.StatusBadRequest},
+		{"invalid birth date", `{"name":"Bima","birth_date":"02/04/2019"}`, http.StatusBadRequest},
+		{"unknown device", `{"name":"Bima","device_ids":["device-9"]}`, http.StatusBadRequest},
+		{"device of another user", `{"name":"Bima","device_ids":["device-3"]}`, http.StatusForbidden},
+		{"device of another child", `{"name":"Bima","device_ids":["device-1"]}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := f.do(t, http.MethodPost, "/api/v1/children", token, tt.body)
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestUpdateChild_RejectsOtherOwner(t *testing.T) {
+	f := newChildProfilesFixture(t)
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana"}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+
+	rec := f.do(t, http.MethodPut, "/api/v1/children/"+child.ID, userToken(t, "owner-2"), `{"name":"Bima"}`)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/errs"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+	"github.com/satriahrh/arunika/server/internal/audit"
+)
+
+// conversationForgetter clears conversation state held in memory for a
+// device or a child
+type conversationForgetter interface {
+	ForgetDevice(deviceID string)
+	ForgetChild(childID string)
+}
+
+// childLookupFailed answers a failed child lookup: 404 when the child does
+// not exist, 500 when the storage failed
+func childLookupFailed(c echo.Context, err error, logger *zap.Logger) error {
+	if errors.Is(err, errs.ErrChildNotFound) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "child_not_found",
+			Message: "Child profile not found",
+		})
+	}
+	logger.Error("Failed to get child", zap.String("child_id", c.Param("id")), zap.Error(err))
+	return c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Error:   "child_unavailable",
+		Message: "Failed to load child profile",
+	})
+}
+
+// ownedChild loads the child in the path and verifies the caller owns it.
+// On failure the error response has already been written and ok is false.
+func ownedChild(c echo.Context, childRepo repositories.ChildRepository, logger *zap.Logger) (child *entities.Child, ok bool, err error) {
+	claims := claimsFromContext(c)
+
+	child, err = childRepo.GetByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return nil, false, childLookupFailed(c, err, logger)
+	}
+
+	if child.OwnerID != claims.UserID {
+		logger.Warn("Child access rejected: not the owner",
+			zap.String("child_id", child.ID),
+			zap.String("user_id", claims.UserID))
+		return nil, false, c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "forbidden",
+			Message: "Only the owner can manage this child",
+		})
+	}
+
+	return child, true, nil
+}
+
+// deleteChildConversations erases every stored conversation of a child,
+// whichever devices they talked through, and the words they learned in them
+func deleteChildConversations(
+	c echo.Context,
+	childRepo repositories.ChildRepository,
+	sessionRepo repositories.SessionRepository,
+	forgetter conversationForgetter,
+	trail audit.Trail,
+	logger *zap.Logger,
+) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	if c.QueryParam("confirm") != "true" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "confirmation_required",
+			Message: "Set confirm=true to permanently erase all conversations",
+		})
+	}
+
+	ctx := c.Request().Context()
+	var sessionsDeleted int64
+	for _, deviceID := range child.DeviceIDs {
+		deleted, err := sessionRepo.DeleteByDeviceID(ctx, deviceID)
+		if err != nil {
+			logger.Error("Failed to delete device sessions",
+				zap.String("child_id", child.ID),
+				zap.String("device_id", deviceID),
+				zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "erasure_failed",
+				Message: "Failed to erase conversations",
+			})
+		}
+		sessionsDeleted += deleted
+		forgetter.ForgetDevice(deviceID)
+	}
+
+	// Sessions of devices the child is no longer bound to
+	if eraser, ok := sessionRepo.(repositories.ChildSessionEraser); ok {
+		deleted, err := eraser.DeleteByChildID(ctx, child.ID)
+		if err != nil {
+			logger.Error("Failed to delete child sessions",
+				zap.String("child_id", child.ID),
+				zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "erasure_failed",
+				Message: "Failed to erase conversations",
+			})
+		}
+		sessionsDeleted += deleted
+	} else {
+		logger.Warn("Session repository cannot erase sessions by child, only those of bound devices were erased",
+			zap.String("child_id", child.ID))
+	}
+	forgetter.ForgetChild(child.ID)
+
+	if len(child.LearnedWords) > 0 {
+		child.LearnedWords = nil
+		if err := childRepo.Update(ctx, child); err != nil {
+			logger.Error("Failed to erase learned words",
+				zap.String("child_id", child.ID),
+				zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "erasure_failed",
+				Message: "Failed to erase conversations",
+			})
+		}
+	}
+
+	trail.Record(ctx, audit.Entry{
+		Action:   "conversations_erased",
+		ActorID:  claimsFromContext(c).UserID,
+		TargetID: child.ID,
+		Details: map[string]interface{}{
+			"device_ids":       child.DeviceIDs,
+			"sessions_deleted": sessionsDeleted,
+		},
+	})
+
+	return c.JSON(http.StatusOK, DeleteConversationsResponse{
+		ChildID:         child.ID,
+		SessionsDeleted: sessionsDeleted,
+	})
+}
@@ -0,0 +1,345 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+	"github.com/satriahrh/arunika/server/internal/audit"
+	"github.com/satriahrh/arunika/server/internal/auth"
+)
+
+// fakeSessionRepository keeps sessions in memory for handler tests
+type fakeSessionRepository struct {
+	mu       sync.Mutex
+	sessions []*entities.Session
+}
+
+func (f *fakeSessionRepository) Create(ctx context.Context, session *entities.Session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions = append(f.sessions, session)
+	return nil
+}
+
+func (f *fakeSessionRepository) GetLastByDeviceID(ctx context.Context, deviceID string) (*entities.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.sessions) - 1; i >= 0; i-- {
+		if f.sessions[i].DeviceID == deviceID {
+			return f.sessions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeSessionRepository) Update(ctx context.Context, session *entities.Session) error {
+	return nil
+}
+
+func (f *fakeSessionRepository) DeleteByDeviceID(ctx context.Context, deviceID string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var kept []*entities.Session
+	var deleted int64
+	for _, session := range f.sessions {
+		if session.DeviceID == deviceID {
+			deleted++
+			continue
+		}
+		kept = append(kept, session)
+	}
+	f.sessions = kept
+	return deleted, nil
+}
+
+func (f *fakeSessionRepository) DeleteByChildID(ctx context.Context, childID string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var kept []*entities.Session
+	var deleted int64
+	for _, session := range f.sessions {
+		if session.ChildID == childID {
+			deleted++
+			continue
+		}
+		kept = append(kept, session)
+	}
+	f.sessions = kept
+	return deleted, nil
+}
+
+func (f *fakeSessionRepository) Search(ctx context.Context, deviceID, query string, limit int) ([]entities.MessageMatch, error) {
+	terms, err := entities.SearchTerms(query)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var matches []entities.MessageMatch
+	for i := len(f.sessions) - 1; i >= 0 && len(matches) < limit; i-- {
+		if f.sessions[i].DeviceID != deviceID {
+			continue
+		}
+		matches = append(matches, f.sessions[i].SearchMessages(terms)...)
+	}
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+func (f *fakeSessionRepository) ListByDeviceID(ctx context.Context, deviceID string, limit int) ([]*entities.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var sessions []*entities.Session
+	for i := len(f.sessions) - 1; i >= 0 && len(sessions) < limit; i-- {
+		if f.sessions[i].DeviceID == deviceID {
+			sessions = append(sessions, f.sessions[i])
+		}
+	}
+	return sessions, nil
+}
+
+func (f *fakeSessionRepository) ExpireSessions(ctx context.Context, cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+// fakeForgetter records the devices and children whose in-memory state was cleared
+type fakeForgetter struct {
+	forgotten         []string
+	forgottenChildren []string
+}
+
+func (f *fakeForgetter) ForgetDevice(deviceID string) {
+	f.forgotten = append(f.forgotten, deviceID)
+}
+
+func (f *fakeForgetter) ForgetChild(childID string) {
+	f.forgottenChildren = append(f.forgottenChildren, childID)
+}
+
+// recordingTrail keeps audit entries for assertions
+type recordingTrail struct {
+	entries []audit.Entry
+}
+
+func (r *recordingTrail) Record(ctx context.Context, entry audit.Entry) {
+	r.entries = append(r.entries, entry)
+}
+
+// unavailableChildRepository fails every lookup as a storage outage would
+type unavailableChildRepository struct {
+	repositories.ChildRepository
+}
+
+func (unavailableChildRepository) GetByID(ctx context.Context, id string) (*entities.Child, error) {
+	return nil, errors.New("connection refused")
+}
+
+type erasureFixture struct {
+	echo      *echo.Echo
+	childRepo repositories.ChildRepository
+	child     *entities.Child
+	sessions  *fakeSessionRepository
+	forgetter *fakeForgetter
+	trail     *recordingTrail
+}
+
+func newErasureFixture(t *testing.T) *erasureFixture {
+	t.Helper()
+	logger := zaptest.NewLogger(t)
+	f := &erasureFixture{
+		echo:      echo.New(),
+		sessions:  &fakeSessionRepository{},
+		forgetter: &fakeForgetter{},
+		trail:     &recordingTrail{},
+	}
+
+	f.childRepo = adapters.NewMemoryChildRepository()
+	f.child = &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1", "device-2"}}
+	if err := f.childRepo.Create(context.Background(), f.child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+
+	for _, deviceID := range []string{"device-1", "device-1", "device-2", "device-other"} {
+		f.sessions.Create(context.Background(), &entities.Session{
+			DeviceID: deviceID,
+			Messages: []entities.Message{{Role: entities.UserRole, Content: "rahasia"}},
+		})
+	}
+
+	f.echo.DELETE("/api/v1/children/:id/conversations", func(c echo.Context) error {
+		return deleteChildConversations(c, f.childRepo, f.sessions, f.forgetter, f.trail, logger)
+	}, requireRole("user", logger))
+
+	return f
+}
+
+func (f *erasureFixture) do(t *testing.T, token, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/children/"+f.child.ID+"/conversations"+query, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	f.echo.ServeHTTP(rec, req)
+	return rec
+}
+
+func userToken(t *testing.T, userID string) string {
+	t.Helper()
+	token, err := auth.GenerateUserToken(userID)
+	if err != nil {
+		t.Fatalf("Failed to generate user token: %v", err)
+	}
+	return token
+}
+
+func TestDeleteChildConversations_RemovesSessionsAndMemory(t *testing.T) {
+	f := newErasureFixture(t)
+
+	rec := f.do(t, userToken(t, "owner-1"), "?confirm=true")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp DeleteConversationsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.SessionsDeleted != 3 {
+		t.Errorf("Expected 3 sessions deleted, got %d", resp.SessionsDeleted)
+	}
+
+	if len(f.sessions.sessions) != 1 || f.sessions.sessions[0].DeviceID != "device-other" {
+		t.Errorf("Expected only the unrelated device session to remain, got %+v", f.sessions.sessions)
+	}
+
+	if len(f.forgetter.forgotten) != 2 {
+		t.Errorf("Expected in-memory state cleared for both devices, got %v", f.forgetter.forgotten)
+	}
+
+	if len(f.trail.entries) != 1 {
+		t.Fatalf("Expected one audit entry, got %d", len(f.trail.entries))
+	}
+	entry := f.trail.entries[0]
+	if entry.Action != "conversations_erased" || entry.ActorID != "owner-1" || entry.TargetID != f.child.ID {
+		t.Errorf("Unexpected audit entry: %+v", entry)
+	}
+	details, _ := json.Marshal(entry.Details)
+	if string(details) != `{"device_ids":["device-1","device-2"],"sessions_deleted":3}` {
+		t.Errorf("Audit entry must not include erased content, got %s", details)
+	}
+}
+
+func TestDeleteChildConversations_ErasesSessionsOfUnboundDevicesAndLearnedWords(t *testing.T) {
+	f := newErasureFixture(t)
+	// The child talked through a device that was unbound since
+	f.sessions.Create(context.Background(), &entities.Session{
+		DeviceID: "device-old",
+		ChildID:  f.child.ID,
+		Messages: []entities.Message{{Role: entities.UserRole, Content: "rahasia"}},
+	})
+	f.child.LearnedWords = []entities.LearnedWord{{Word: "kupu-kupu", LearnedAt: time.Now()}}
+	if err := f.childRepo.Update(context.Background(), f.child); err != nil {
+		t.Fatalf("Failed to update child: %v", err)
+	}
+
+	rec := f.do(t, userToken(t, "owner-1"), "?confirm=true")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp DeleteConversationsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.SessionsDeleted != 4 {
+		t.Errorf("Expected 4 sessions deleted, got %d", resp.SessionsDeleted)
+	}
+	for _, session := range f.sessions.sessions {
+		if session.DeviceID != "device-other" {
+			t.Errorf("Expected the session of unbound device %q to be erased", session.DeviceID)
+		}
+	}
+	if len(f.forgetter.forgottenChildren) != 1 || f.forgetter.forgottenChildren[0] != f.child.ID {
+		t.Errorf("Expected in-memory state of the child cleared, got %v", f.forgetter.forgottenChildren)
+	}
+
+	stored, err := f.childRepo.GetByID(context.Background(), f.child.ID)
+	if err != nil {
+		t.Fatalf("Failed to get child: %v", err)
+	}
+	if len(stored.LearnedWords) != 0 {
+		t.Errorf("Expected learned words to be erased, got %+v", stored.LearnedWords)
+	}
+}
+
+func TestDeleteChildConversations_RequiresConfirmation(t *testing.T) {
+	f := newErasureFixture(t)
+
+	rec := f.do(t, userToken(t, "owner-1"), "")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", rec.Code)
+	}
+	if len(f.sessions.sessions) != 4 {
+		t.Errorf("Expected no sessions deleted without confirmation, got %d remaining", len(f.sessions.sessions))
+	}
+}
+
+func TestDeleteChildConversations_OnlyOwner(t *testing.T) {
+	f := newErasureFixture(t)
+
+	rec := f.do(t, userToken(t, "someone-else"), "?confirm=true")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-owner, got %d", rec.Code)
+	}
+
+	deviceToken, _ := auth.GenerateDeviceToken("device-1")
+	rec = f.do(t, deviceToken, "?confirm=true")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for device token, got %d", rec.Code)
+	}
+
+	rec = f.do(t, "", "?confirm=true")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without token, got %d", rec.Code)
+	}
+
+	if len(f.sessions.sessions) != 4 || len(f.trail.entries) != 0 {
+		t.Errorf("Expected nothing erased for unauthorized requests")
+	}
+}
+
+func TestDeleteChildConversations_SeparatesMissingChildFromStorageFailure(t *testing.T) {
+	f := newErasureFixture(t)
+	token := userToken(t, "owner-1")
+
+	f.child.ID = "missing"
+	rec := f.do(t, token, "?confirm=true")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing child, got %d", rec.Code)
+	}
+
+	f.childRepo = unavailableChildRepository{f.childRepo}
+	rec = f.do(t, token, "?confirm=true")
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 when the storage fails, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if len(f.sessions.sessions) != 4 || len(f.trail.entries) != 0 {
+		t.Errorf("Expected nothing erased when the child cannot be loaded")
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+const (
+	defaultConversationLimit = 20
+	maxConversationLimit     = 100
+)
+
+// listChildConversations lists the sessions of a child's devices with their
+// parent summaries, the most recently active first
+func listChildConversations(c echo.Context, childRepo repositories.ChildRepository, sessionRepo repositories.SessionRepository, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	limit, err := queryInt(c, "limit", defaultConversationLimit)
+	if err != nil || limit < 1 || limit > maxConversationLimit {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_limit",
+			Message: "Limit must be between 1 and 100",
+		})
+	}
+
+	conversations := []ConversationSummary{}
+	for _, deviceID := range child.DeviceIDs {
+		sessions, err := sessionRepo.ListByDeviceID(c.Request().Context(), deviceID, limit)
+		if err != nil {
+			logger.Error("Failed to list conversations",
+				zap.String("child_id", child.ID),
+				zap.String("device_id", deviceID),
+				zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "list_failed",
+				Message: "Failed to list conversations",
+			})
+		}
+		for _, session := range sessions {
+			conversation := ConversationSummary{
+				SessionID:     session.ID,
+				DeviceID:      session.DeviceID,
+				StartedAt:     session.CreatedAt,
+				LastMessageAt: session.LastMessageAt,
+				MessageCount:  len(session.Messages),
+				ParentSummary: session.ParentSummary,
+			}
+			if !session.EndedAt.IsZero() {
+				endedAt := session.EndedAt
+				conversation.EndedAt = &endedAt
+			}
+			conversations = append(conversations, conversation)
+		}
+	}
+
+	sort.SliceStable(conversations, func(i, j int) bool {
+		return conversations[i].LastMessageAt.After(conversations[j].LastMessageAt)
+	})
+	if len(conversations) > limit {
+		conversations = conversations[:limit]
+	}
+
+	return c.JSON(http.StatusOK, ConversationListResponse{
+		ChildID:       child.ID,
+		Conversations: conversations,
+	})
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+func TestListChildConversations_ReturnsParentSummaries(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	childRepo := adapters.NewMemoryChildRepository()
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1", "device-2"}}
+	if err := childRepo.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+
+	base := time.Date(2025, 3, 1, 19, 0, 0, 0, time.UTC)
+	sessions := &fakeSessionRepository{}
+	summary := &entities.ParentSummary{Summary: "Kirana bercerita tentang kucingnya.", Topics: []string{"kucing"}, GeneratedAt: base}
+	for _, session := range []*entities.Session{
+		{ID: "ended", DeviceID: "device-1", LastMessageAt: base, EndedAt: base.Add(time.Hour), ParentSummary: summary},
+		{ID: "active", DeviceID: "device-2", LastMessageAt: base.Add(2 * time.Hour)},
+		{ID: "other", DeviceID: "device-other", LastMessageAt: base},
+	} {
+		sessions.Create(context.Background(), session)
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/children/:id/conversations", func(c echo.Context) error {
+		return listChildConversations(c, childRepo, sessions, logger)
+	}, requireRole("user", logger))
+	list := func(token, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/children/"+child.ID+"/conversations"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := list(userToken(t, "owner-1"), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp ConversationListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Conversations) != 2 || resp.Conversations[0].SessionID != "active" {
+		t.Fatalf("Expected the child's sessions, the most recent first, got %+v", resp.Conversations)
+	}
+	if resp.Conversations[0].EndedAt != nil || resp.Conversations[0].ParentSummary != nil {
+		t.Errorf("Expected the active session without an end or summary, got %+v", resp.Conversations[0])
+	}
+	ended := resp.Conversations[1]
+	if ended.EndedAt == nil || ended.ParentSummary == nil || ended.ParentSummary.Summary != summary.Summary {
+		t.Errorf("Expected the ended session with its summary, got %+v", ended)
+	}
+
+	if rec := list(userToken(t, "owner-1"), "?limit=0"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid limit, got %d", rec.Code)
+	}
+	if rec := list(userToken(t, "someone-else"), ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-owner, got %d", rec.Code)
+	}
+}
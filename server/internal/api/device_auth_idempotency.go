@@ -0,0 +1,117 @@
+package api
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Firmware retrying /device/auth after a lost response would otherwise be
+// issued a new token per attempt. A request carrying an Idempotency-Key
+// header gets the token issued to the first request of its device with the
+// same key, as long as that was within the idempotency window. Keys are kept
+// in memory only, so a restarted server issues new tokens.
+
+const (
+	// idempotencyKeyHeader carries the key the retries of a request share
+	idempotencyKeyHeader = "Idempotency-Key"
+	// maxIdempotencyKeyLength bounds the keys kept in memory
+	maxIdempotencyKeyLength = 128
+
+	defaultIdempotencyWindow = time.Minute
+)
+
+// DeviceAuthConfig holds configuration for device authentication
+// Optional fields with defaults:
+// - IdempotencyWindow: How long requests repeating an Idempotency-Key get the same token (default: 60s)
+type DeviceAuthConfig struct {
+	IdempotencyWindow time.Duration // Optional: How long requests repeating an Idempotency-Key get the same token
+}
+
+// NewDeviceAuthConfigFromEnv creates a new DeviceAuthConfig from environment variables
+// This is a helper function to simplify the creation of a properly configured DeviceAuthConfig
+func NewDeviceAuthConfigFromEnv() DeviceAuthConfig {
+	config := DeviceAuthConfig{}
+
+	if windowStr := os.Getenv("DEVICE_AUTH_IDEMPOTENCY_WINDOW_SECONDS"); windowStr != "" {
+		if window, err := strconv.Atoi(windowStr); err == nil && window > 0 {
+			config.IdempotencyWindow = time.Duration(window) * time.Second
+		}
+	}
+
+	return config
+}
+
+// idempotencyKey identifies the retries of a device authentication
+type idempotencyKey struct {
+	serialNumber string
+	key          string
+}
+
+// issuedToken is the response to the first request with an idempotency key
+type issuedToken struct {
+	response DeviceAuthResponse
+	expires  time.Time
+}
+
+// authIdempotency keeps the tokens issued to requests with an idempotency
+// key for the idempotency window
+type authIdempotency struct {
+	window time.Duration
+
+	mu     sync.Mutex
+	issued map[idempotencyKey]issuedToken
+
+	// Clock deciding when keys expire, replaced in tests
+	now func() time.Time
+}
+
+// newAuthIdempotency creates the idempotency keys of device authentication
+func newAuthIdempotency(config DeviceAuthConfig, logger *zap.Logger) *authIdempotency {
+	window := config.IdempotencyWindow
+	if window == 0 {
+		window = defaultIdempotencyWindow
+		logger.Info("Using default device auth idempotency window", zap.Duration("idempotencyWindow", window))
+	}
+
+	return &authIdempotency{
+		window: window,
+		issued: make(map[idempotencyKey]issuedToken),
+		now:    time.Now,
+	}
+}
+
+// respond returns the response issued to the device with serialNumber for
+// key within the window, or calls issue for a new one. Without a key every
+// request calls issue. It reports whether the response was issued before.
+func (a *authIdempotency) respond(serialNumber, key string, issue func() (DeviceAuthResponse, error)) (DeviceAuthResponse, bool, error) {
+	if a == nil || key == "" {
+		response, err := issue()
+		return response, false, err
+	}
+
+	// Retries racing each other must not both issue a token
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	for issuedKey, token := range a.issued {
+		if !now.Before(token.expires) {
+			delete(a.issued, issuedKey)
+		}
+	}
+
+	id := idempotencyKey{serialNumber: serialNumber, key: key}
+	if token, ok := a.issued[id]; ok {
+		return token.response, true, nil
+	}
+	response, err := issue()
+	if err != nil {
+		return response, false, err
+	}
+	a.issued[id] = issuedToken{response: response, expires: now.Add(a.window)}
+	return response, false, nil
+}
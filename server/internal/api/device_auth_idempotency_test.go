@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+type deviceAuthFixture struct {
+	echo        *echo.Echo
+	idempotency *authIdempotency
+	now         time.Time
+}
+
+func newDeviceAuthFixture(t *testing.T) *deviceAuthFixture {
+	t.Helper()
+	logger := zaptest.NewLogger(t)
+	devices := adapters.NewMemoryDeviceRepository()
+	for _, serial := range []string{"ARU-0001", "ARU-0002"} {
+		credentials := []repositories.DeviceCredentials{{
+			Device: &entities.Device{SerialNumber: serial, Model: "doll-v1"},
+			Secret: "secret-" + serial,
+		}}
+		if _, err := devices.ImportDevices(context.Background(), credentials); err != nil {
+			t.Fatalf("Failed to import device: %v", err)
+		}
+	}
+
+	f := &deviceAuthFixture{
+		echo:        echo.New(),
+		idempotency: newAuthIdempotency(DeviceAuthConfig{}, logger),
+		now:         time.Now(),
+	}
+	f.idempotency.now = func() time.Time { return f.now }
+	f.echo.POST("/api/v1/device/auth", func(c echo.Context) error {
+		return deviceAuth(c, devices, f.idempotency, logger)
+	})
+	return f
+}
+
+// authenticate authenticates the device with serial, sending key as its
+// idempotency key unless empty
+func (f *deviceAuthFixture) authenticate(t *testing.T, serial, key string) DeviceAuthResponse {
+	t.Helper()
+	body := `{"serial_number":"` + serial + `","secret_key":"secret-` + serial + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/device/auth", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	f.echo.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp DeviceAuthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return resp
+}
+
+func TestDeviceAuth_IdempotencyKeyReturnsSameToken(t *testing.T) {
+	f := newDeviceAuthFixture(t)
+
+	first := f.authenticate(t, "ARU-0001", "boot-42")
+	retry := f.authenticate(t, "ARU-0001", "boot-42")
+	if retry.Token != first.Token || !retry.ExpiresAt.Equal(first.ExpiresAt) {
+		t.Errorf("Expected a retry to get the same token, got %+v and %+v", first, retry)
+	}
+
+	if other := f.authenticate(t, "ARU-0001", "boot-43"); other.Token == first.Token {
+		t.Error("Expected another idempotency key to mint a new token")
+	}
+	if other := f.authenticate(t, "ARU-0002", "boot-42"); other.Token == first.Token || other.DeviceID == first.DeviceID {
+		t.Errorf("Expected the key of another device to mint its own token, got %+v", other)
+	}
+	if unkeyed := f.authenticate(t, "ARU-0001", ""); unkeyed.Token == first.Token {
+		t.Error("Expected a request without an idempotency key to mint a new token")
+	}
+
+	f.now = f.now.Add(defaultIdempotencyWindow)
+	if expired := f.authenticate(t, "ARU-0001", "boot-42"); expired.Token == first.Token {
+		t.Error("Expected the key to mint a new token once the window passed")
+	}
+}
+
+func TestDeviceAuth_IdempotencyKeyRequiresCredentials(t *testing.T) {
+	f := newDeviceAuthFixture(t)
+	f.authenticate(t, "ARU-0001", "boot-42")
+
+	body := `{"serial_number":"ARU-0001","secret_key":"wrong"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/device/auth", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(idempotencyKeyHeader, "boot-42")
+	rec := httptest.NewRecorder()
+	f.echo.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a known key with a wrong secret to be rejected, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/device/auth", strings.NewReader(`{"serial_number":"ARU-0001","secret_key":"secret-ARU-0001"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(idempotencyKeyHeader, strings.Repeat("k", maxIdempotencyKeyLength+1))
+	rec = httptest.NewRecorder()
+	f.echo.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected an oversized key to be rejected, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// getChildLanguage returns the language the doll is locked to for the child
+// and the language the child usually speaks
+func getChildLanguage(c echo.Context, childRepo repositories.ChildRepository, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, LanguageResponse{Language: child.ForcedLanguage, PreferredLanguage: child.Language})
+}
+
+// putChildLanguage locks the doll to a BCP-47 language for the child, whatever
+// language the child speaks. An empty language lifts the lock. The language
+// the child usually speaks is set alongside when the request names it. The
+// change applies from the child's next turn. A language the child's voice
+// does not speak is flagged or refused depending on policy.
+func putChildLanguage(c echo.Context, childRepo repositories.ChildRepository, voiceManager repositories.VoiceManager, policy string, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	var req LanguageRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request format",
+		})
+	}
+
+	language, err := normalizeLanguage(req.Language)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_language",
+			Message: err.Error(),
+		})
+	}
+	child.ForcedLanguage = language
+	// The preferred language is kept unless the request names one
+	if req.PreferredLanguage != nil {
+		preferred, err := normalizeLanguage(*req.PreferredLanguage)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_language",
+				Message: err.Error(),
+			})
+		}
+		child.Language = preferred
+	}
+
+	warning := ""
+	if policy != VoiceLanguageOff {
+		voice, err := assignedVoice(c.Request().Context(), voiceManager, child)
+		if err != nil {
+			// The language of the child matters more than how the voice sounds
+			logger.Warn("Failed to check the language of the child voice",
+				zap.String("child_id", child.ID),
+				zap.Error(err))
+		}
+		if voice != nil && !voice.SupportsLanguage(childLanguage(child)) {
+			logger.Info("Child voice does not speak the child's language",
+				zap.String("child_id", child.ID),
+				zap.String("voice_id", voice.ID),
+				zap.String("language", childLanguage(child)),
+				zap.String("policy", policy))
+			if policy == VoiceLanguageBlock {
+				return c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+					Error:   voiceLanguageMismatch,
+					Message: "The child's voice does not speak this language",
+				})
+			}
+			warning = voiceLanguageMismatch
+		}
+	}
+
+	if err := childRepo.Update(c.Request().Context(), child); err != nil {
+		logger.Error("Failed to update child language",
+			zap.String("child_id", child.ID),
+			zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "update_failed",
+			Message: "Failed to update language",
+		})
+	}
+
+	logger.Info("Child language updated",
+		zap.String("child_id", child.ID),
+		zap.String("forced_language", child.ForcedLanguage),
+		zap.String("preferred_language", child.Language))
+
+	return c.JSON(http.StatusOK, LanguageResponse{Language: child.ForcedLanguage, PreferredLanguage: child.Language, VoiceWarning: warning})
+}
+
+// normalizeLanguage returns language in its canonical case, empty when empty
+func normalizeLanguage(language string) (string, error) {
+	if language == "" {
+		return "", nil
+	}
+	return entities.NormalizeLanguageTag(language)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+func TestChildLanguage_OwnerLocksLanguage(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	children := adapters.NewMemoryChildRepository()
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}}
+	if err := children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/children/:id/language", func(c echo.Context) error {
+		return getChildLanguage(c, children, logger)
+	}, requireRole("user", logger))
+	e.PUT("/api/v1/children/:id/language", func(c echo.Context) error {
+		return putChildLanguage(c, children, nil, VoiceLanguageOff, logger)
+	}, requireRole("user", logger))
+
+	do := func(method, token, body string) (*httptest.ResponseRecorder, LanguageResponse) {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/v1/children/"+child.ID+"/language", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		var resp LanguageResponse
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return rec, resp
+	}
+	token := userToken(t, "owner-1")
+
+	if rec, resp := do(http.MethodGet, token, ""); rec.Code != http.StatusOK || resp.Language != "" {
+		t.Fatalf("Expected no language lock before any is set, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec, resp := do(http.MethodPut, token, `{"language":"ID-id"}`); rec.Code != http.StatusOK || resp.Language != "id-ID" {
+		t.Fatalf("Expected the normalized language to be set, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stored, _ := children.GetByID(context.Background(), child.ID); stored.ForcedLanguage != "id-ID" {
+		t.Errorf("Expected the language stored, got %q", stored.ForcedLanguage)
+	}
+
+	if rec, _ := do(http.MethodPut, token, `{"language":"bahasa indonesia"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid language tag, got %d", rec.Code)
+	}
+	if rec, _ := do(http.MethodPut, userToken(t, "someone-else"), `{"language":"en-US"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-owner, got %d", rec.Code)
+	}
+	if stored, _ := children.GetByID(context.Background(), child.ID); stored.ForcedLanguage != "id-ID" {
+		t.Errorf("Expected rejected changes not to be stored, got %q", stored.ForcedLanguage)
+	}
+
+	if rec, resp := do(http.MethodPut, token, `{"language":""}`); rec.Code != http.StatusOK || resp.Language != "" {
+		t.Errorf("Expected the language lock to be lifted, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestChildLanguage_OwnerSetsPreferredLanguage(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	children := adapters.NewMemoryChildRepository()
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}}
+	if err := children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+
+	e := echo.New()
+	e.PUT("/api/v1/children/:id/language", func(c echo.Context) error {
+		return putChildLanguage(c, children, nil, VoiceLanguageOff, logger)
+	}, requireRole("user", logger))
+
+	put := func(body string) (*httptest.ResponseRecorder, LanguageResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/children/"+child.ID+"/language", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("Authorization", "Bearer "+userToken(t, "owner-1"))
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		var resp LanguageResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+
+	if rec, resp := put(`{"language":"","preferred_language":"en-gb"}`); rec.Code != http.StatusOK || resp.PreferredLanguage != "en-GB" {
+		t.Fatalf("Expected the normalized preferred language to be set, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// Locking the language keeps the preferred one
+	if rec, resp := put(`{"language":"id-ID"}`); rec.Code != http.StatusOK || resp.PreferredLanguage != "en-GB" || resp.Language != "id-ID" {
+		t.Errorf("Expected the preferred language kept, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec, _ := put(`{"language":"","preferred_language":"english please"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid preferred language, got %d", rec.Code)
+	}
+	if stored, _ := children.GetByID(context.Background(), child.ID); stored.Language != "en-GB" || stored.ForcedLanguage != "id-ID" {
+		t.Errorf("Expected the rejected change not to be stored, got %q and %q", stored.Language, stored.ForcedLanguage)
+	}
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+	"github.com/satriahrh/arunika/server/internal/audit"
+)
+
+// getLearnedWords returns the words the child learned, the most recent first.
+// With date=YYYY-MM-DD only those of that day in the child's timezone are
+// returned.
+func getLearnedWords(c echo.Context, childRepo repositories.ChildRepository, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	resp := LearnedWordsResponse{ChildID: child.ID, Consent: child.VocabularyConsent, Words: []entities.LearnedWord{}}
+	var from, until time.Time
+	if date := c.QueryParam("date"); date != "" {
+		location, _ := child.Location()
+		if location == nil {
+			location = time.Local
+		}
+		day, err := time.ParseInLocation(time.DateOnly, date, location)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_date",
+				Message: "Date must be formatted as YYYY-MM-DD",
+			})
+		}
+		resp.Date = date
+		from, until = day, day.AddDate(0, 0, 1)
+	}
+
+	for i := len(child.LearnedWords) - 1; i >= 0; i-- {
+		word := child.LearnedWords[i]
+		if !from.IsZero() && (word.LearnedAt.Before(from) || !word.LearnedAt.Before(until)) {
+			continue
+		}
+		resp.Words = append(resp.Words, word)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// putVocabularyConsent gives or withdraws consent to the new words of the
+// child's conversations being tracked. Withdrawing it erases the words
+// tracked so far.
+func putVocabularyConsent(c echo.Context, childRepo repositories.ChildRepository, trail audit.Trail, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	var req RecordingConsentRequest
+	if err := c.Bind(&req); err != nil || req.Consent == nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Consent must be true or false",
+		})
+	}
+
+	previous := child.VocabularyConsent
+	child.VocabularyConsent = *req.Consent
+	if !child.VocabularyConsent {
+		child.LearnedWords = nil
+	}
+	ctx := c.Request().Context()
+	if err := childRepo.Update(ctx, child); err != nil {
+		logger.Error("Failed to update vocabulary consent",
+			zap.String("child_id", child.ID),
+			zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "update_failed",
+			Message: "Failed to update vocabulary consent",
+		})
+	}
+
+	trail.Record(ctx, audit.Entry{
+		Action:   "vocabulary_consent_updated",
+		ActorID:  claimsFromContext(c).UserID,
+		TargetID: child.ID,
+		Details: map[string]interface{}{
+			"consent":  child.VocabularyConsent,
+			"previous": previous,
+		},
+	})
+
+	return c.JSON(http.StatusOK, RecordingConsentResponse{Consent: child.VocabularyConsent})
+}
+
+// deleteLearnedWords erases the words the child learned, keeping the consent
+func deleteLearnedWords(c echo.Context, childRepo repositories.ChildRepository, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	child.LearnedWords = nil
+	if err := childRepo.Update(c.Request().Context(), child); err != nil {
+		logger.Error("Failed to erase learned words",
+			zap.String("child_id", child.ID),
+			zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "update_failed",
+			Message: "Failed to erase learned words",
+		})
+	}
+
+	return c.JSON(http.StatusOK, LearnedWordsResponse{ChildID: child.ID, Consent: child.VocabularyConsent, Words: []entities.LearnedWord{}})
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// turnSink signals the end of every response of a conversation
+type turnSink chan struct{}
+
+func (s turnSink) Emit(event conversation.Event) {
+	if event.Type == conversation.EventSpeakingEnd {
+		s <- struct{}{}
+	}
+}
+
+func TestLearnedWords_ParentSeesWordsOfConversations(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	children := adapters.NewMemoryChildRepository()
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}, Timezone: "Asia/Jakarta", VocabularyConsent: true}
+	if err := children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+
+	// A turn of the child's device picks its new words
+	llm := &conversationtest.LLM{Reply: "Daun hijau karena klorofil!", LearnedWords: []entities.LearnedWord{{Word: "klorofil", Source: entities.LearnedFromDoll}}}
+	engine, err := conversation.NewEngine(conversation.Config{LearnedWords: true}, llm, &conversationtest.TTS{Chunks: [][]byte{{0xAA}}},
+		&conversationtest.STT{Transcript: "kenapa daun hijau"}, &conversationtest.SessionRepository{}, children,
+		adapters.NewMemoryQuietHoursRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := make(turnSink, 1)
+	conv := engine.NewConversation("device-1", sink)
+	conv.StartListening(conversation.StartOptions{})
+	conv.StreamAudio([]byte{0x01, 0x02})
+	conv.EndListening()
+	select {
+	case <-sink:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the response")
+	}
+	conv.Close()
+
+	trail := &recordingTrail{}
+	e := echo.New()
+	e.GET("/api/v1/children/:id/learned-words", func(c echo.Context) error {
+		return getLearnedWords(c, children, logger)
+	}, requireRole("user", logger))
+	e.DELETE("/api/v1/children/:id/learned-words", func(c echo.Context) error {
+		return deleteLearnedWords(c, children, logger)
+	}, requireRole("user", logger))
+	e.PUT("/api/v1/children/:id/vocabulary-consent", func(c echo.Context) error {
+		return putVocabularyConsent(c, children, trail, logger)
+	}, requireRole("user", logger))
+
+	do := func(method, path, token, body string) (*httptest.ResponseRecorder, LearnedWordsResponse) {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/v1/children/"+child.ID+path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		var resp LearnedWordsResponse
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return rec, resp
+	}
+	token := userToken(t, "owner-1")
+
+	rec, resp := do(http.MethodGet, "/learned-words", token, "")
+	if rec.Code != http.StatusOK || !resp.Consent || len(resp.Words) != 1 {
+		t.Fatalf("Expected the learned word listed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if word := resp.Words[0]; word.Word != "klorofil" || word.Source != entities.LearnedFromDoll || word.SessionID == "" {
+		t.Errorf("Expected the word with its source and session, got %+v", word)
+	}
+
+	jakarta, _ := time.LoadLocation("Asia/Jakarta")
+	today := time.Now().In(jakarta).Format(time.DateOnly)
+	if _, resp := do(http.MethodGet, "/learned-words?date="+today, token, ""); len(resp.Words) != 1 || resp.Date != today {
+		t.Errorf("Expected the word of today, got %+v", resp)
+	}
+	if _, resp := do(http.MethodGet, "/learned-words?date=2020-01-01", token, ""); len(resp.Words) != 0 {
+		t.Errorf("Expected no word on another day, got %+v", resp.Words)
+	}
+	if rec, _ := do(http.MethodGet, "/learned-words?date=yesterday", token, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid date, got %d", rec.Code)
+	}
+	if rec, _ := do(http.MethodGet, "/learned-words", userToken(t, "someone-else"), ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-owner, got %d", rec.Code)
+	}
+
+	// Withdrawing consent erases the words
+	if rec, _ := do(http.MethodPut, "/vocabulary-consent", token, `{"consent":false}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected consent to be withdrawn, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, resp := do(http.MethodGet, "/learned-words", token, ""); resp.Consent || len(resp.Words) != 0 {
+		t.Errorf("Expected no consent and no words, got %+v", resp)
+	}
+	if len(trail.entries) != 1 || trail.entries[0].Action != "vocabulary_consent_updated" || trail.entries[0].Details["previous"] != true {
+		t.Errorf("Expected the withdrawal audited, got %+v", trail.entries)
+	}
+}
+
+func TestLearnedWords_OwnerErasesWords(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	children := adapters.NewMemoryChildRepository()
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", VocabularyConsent: true,
+		LearnedWords: []entities.LearnedWord{{Word: "gerhana", Source: entities.LearnedFromChild, LearnedAt: time.Now()}}}
+	if err := children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+
+	e := echo.New()
+	e.DELETE("/api/v1/children/:id/learned-words", func(c echo.Context) error {
+		return deleteLearnedWords(c, children, logger)
+	}, requireRole("user", logger))
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/children/"+child.ID+"/learned-words", nil)
+	req.Header.Set("Authorization", "Bearer "+userToken(t, "owner-1"))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	stored, _ := children.GetByID(context.Background(), child.ID)
+	if len(stored.LearnedWords) != 0 || !stored.VocabularyConsent {
+		t.Errorf("Expected the words erased and the consent kept, got %+v", stored)
+	}
+}
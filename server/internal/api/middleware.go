@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/internal/auth"
+)
+
+// claimsContextKey is the echo context key holding validated JWT claims
+const claimsContextKey = "jwt_claims"
+
+// bearerToken extracts the JWT token from the Authorization header
+func bearerToken(c echo.Context) string {
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader != "" && len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		return authHeader[7:]
+	}
+	return ""
+}
+
+// deviceTokenError returns the response to a device token that failed
+// validation with err, telling an expired token, which the device may
+// refresh, from a malformed or forged one
+func deviceTokenError(err error) ErrorResponse {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return ErrorResponse{Error: "token_expired", Message: "JWT token has expired, refresh it"}
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return ErrorResponse{Error: "token_malformed", Message: "JWT token is malformed"}
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return ErrorResponse{Error: "token_invalid_signature", Message: "JWT token signature is invalid"}
+	default:
+		return ErrorResponse{Error: "invalid_token", Message: "Invalid or expired JWT token"}
+	}
+}
+
+// requireRole rejects requests that lack a valid JWT token for the given role
+// and stores the validated claims in the echo context
+func requireRole(role string, logger *zap.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := bearerToken(c)
+			if token == "" {
+				return c.JSON(http.StatusUnauthorized, ErrorResponse{
+					Error:   "missing_token",
+					Message: "JWT token is required in Authorization header",
+				})
+			}
+
+			claims, err := auth.ValidateToken(token)
+			if err != nil {
+				logger.Warn("Request rejected: invalid token", zap.Error(err))
+				return c.JSON(http.StatusUnauthorized, ErrorResponse{
+					Error:   "invalid_token",
+					Message: "Invalid or expired JWT token",
+				})
+			}
+
+			if claims.Role != role {
+				logger.Warn("Request rejected: invalid role",
+					zap.String("role", claims.Role),
+					zap.String("required_role", role))
+				return c.JSON(http.StatusForbidden, ErrorResponse{
+					Error:   "invalid_role",
+					Message: "Token role is not allowed for this endpoint",
+				})
+			}
+
+			c.Set(claimsContextKey, claims)
+			return next(c)
+		}
+	}
+}
+
+// claimsFromContext returns the claims stored by requireRole
+func claimsFromContext(c echo.Context) *auth.JWTClaims {
+	claims, _ := c.Get(claimsContextKey).(*auth.JWTClaims)
+	return claims
+}
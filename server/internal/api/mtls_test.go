@@ -0,0 +1,151 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gorillaws "github.com/gorilla/websocket"
+
+	"github.com/satriahrh/arunika/server/internal/auth"
+)
+
+// testCA signs the certificates of a test
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Arunika Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse CA certificate: %v", err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key for commonName
+func (ca *testCA) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeFile writes data to a file of the test's temporary directory
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestWebSocketWithAuth_RequiresMatchingClientCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, "arunika-server", x509.ExtKeyUsageServerAuth)
+	config := auth.MTLSConfig{
+		Required:     true,
+		ClientCAFile: writeFile(t, "ca.pem", ca.pem),
+		CertFile:     writeFile(t, "server.pem", serverCert),
+		KeyFile:      writeFile(t, "server-key.pem", serverKey),
+	}
+	tlsConfig, err := config.TLSConfig()
+	if err != nil {
+		t.Fatalf("Failed to build the TLS configuration: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(newWebSocketEcho(t))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+	url := "wss" + strings.TrimPrefix(server.URL, "https") + "/ws"
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.pem)
+	clientCertPEM, clientKeyPEM := ca.issue(t, "device-1", x509.ExtKeyUsageClientAuth)
+	clientCert, err := tls.X509KeyPair(clientCertPEM, clientKeyPEM)
+	if err != nil {
+		t.Fatalf("Failed to load the client certificate: %v", err)
+	}
+	dial := func(certificates []tls.Certificate, deviceID string) (*http.Response, error) {
+		token, err := auth.GenerateDeviceToken(deviceID)
+		if err != nil {
+			t.Fatalf("Failed to generate token: %v", err)
+		}
+		dialer := gorillaws.Dialer{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certificates}}
+		conn, resp, err := dialer.Dial(url, http.Header{"Authorization": {"Bearer " + token}})
+		if conn != nil {
+			conn.Close()
+		}
+		return resp, err
+	}
+
+	if _, err := dial([]tls.Certificate{clientCert}, "device-1"); err != nil {
+		t.Errorf("Expected a certificate matching the token to connect, got %v", err)
+	}
+
+	resp, err := dial([]tls.Certificate{clientCert}, "device-2")
+	if err == nil {
+		t.Error("Expected a certificate of another device to be refused")
+	} else if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a mismatching certificate, got %v (%v)", resp, err)
+	}
+
+	if resp, err := dial(nil, "device-1"); err == nil || resp != nil {
+		t.Errorf("Expected the handshake without a client certificate to fail, got %v (%v)", resp, err)
+	}
+}
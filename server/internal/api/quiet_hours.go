@@ -0,0 +1,105 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// getQuietHours returns the quiet hours schedule of a child
+func getQuietHours(c echo.Context, childRepo repositories.ChildRepository, quietHoursRepo repositories.QuietHoursRepository, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	quietHours, err := quietHoursRepo.GetByChildID(c.Request().Context(), child.ID)
+	if err != nil {
+		logger.Error("Failed to get quiet hours",
+			zap.String("child_id", child.ID),
+			zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "quiet_hours_unavailable",
+			Message: "Failed to get quiet hours",
+		})
+	}
+	if quietHours == nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "quiet_hours_not_set",
+			Message: "No quiet hours are set for this child",
+		})
+	}
+
+	return c.JSON(http.StatusOK, quietHours)
+}
+
+// putQuietHours creates or replaces the quiet hours schedule of a child
+func putQuietHours(c echo.Context, childRepo repositories.ChildRepository, quietHoursRepo repositories.QuietHoursRepository, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	var req QuietHoursRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request format",
+		})
+	}
+
+	quietHours := &entities.QuietHours{
+		ChildID:  child.ID,
+		Start:    req.Start,
+		End:      req.End,
+		Timezone: req.Timezone,
+	}
+	if err := quietHours.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_quiet_hours",
+			Message: err.Error(),
+		})
+	}
+
+	if err := quietHoursRepo.Upsert(c.Request().Context(), quietHours); err != nil {
+		logger.Error("Failed to save quiet hours",
+			zap.String("child_id", child.ID),
+			zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "quiet_hours_unavailable",
+			Message: "Failed to save quiet hours",
+		})
+	}
+
+	logger.Info("Quiet hours updated",
+		zap.String("child_id", child.ID),
+		zap.String("start", quietHours.Start),
+		zap.String("end", quietHours.End),
+		zap.String("timezone", quietHours.Timezone))
+
+	return c.JSON(http.StatusOK, quietHours)
+}
+
+// deleteQuietHours removes the quiet hours schedule of a child
+func deleteQuietHours(c echo.Context, childRepo repositories.ChildRepository, quietHoursRepo repositories.QuietHoursRepository, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	if err := quietHoursRepo.DeleteByChildID(c.Request().Context(), child.ID); err != nil {
+		logger.Error("Failed to delete quiet hours",
+			zap.String("child_id", child.ID),
+			zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "quiet_hours_unavailable",
+			Message: "Failed to delete quiet hours",
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+type quietHoursFixture struct {
+	echo       *echo.Echo
+	child      *entities.Child
+	quietHours *adapters.MemoryQuietHoursRepository
+}
+
+func newQuietHoursFixture(t *testing.T) *quietHoursFixture {
+	t.Helper()
+	logger := zaptest.NewLogger(t)
+	f := &quietHoursFixture{
+		echo:       echo.New(),
+		quietHours: adapters.NewMemoryQuietHoursRepository(),
+	}
+
+	childRepo := adapters.NewMemoryChildRepository()
+	f.child = &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}}
+	if err := childRepo.Create(context.Background(), f.child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+
+	f.echo.GET("/api/v1/children/:id/quiet-hours", func(c echo.Context) error {
+		return getQuietHours(c, childRepo, f.quietHours, logger)
+	}, requireRole("user", logger))
+	f.echo.PUT("/api/v1/children/:id/quiet-hours", func(c echo.Context) error {
+		return putQuietHours(c, childRepo, f.quietHours, logger)
+	}, requireRole("user", logger))
+	f.echo.DELETE("/api/v1/children/:id/quiet-hours", func(c echo.Context) error {
+		return deleteQuietHours(c, childRepo, f.quietHours, logger)
+	}, requireRole("user", logger))
+
+	return f
+}
+
+func (f *quietHoursFixture) do(t *testing.T, method, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, "/api/v1/children/"+f.child.ID+"/quiet-hours", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	f.echo.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestQuietHours_OwnerManagesSchedule(t *testing.T) {
+	f := newQuietHoursFixture(t)
+	token := userToken(t, "owner-1")
+
+	if rec := f.do(t, http.MethodGet, token, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404 before a schedule is set, got %d", rec.Code)
+	}
+
+	rec := f.do(t, http.MethodPut, token, `{"start":"20:00","end":"06:30","timezone":"Asia/Jakarta"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = f.do(t, http.MethodGet, token, "")
+	var got entities.QuietHours
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if got.ChildID != f.child.ID || got.Start != "20:00" || got.End != "06:30" || got.Timezone != "Asia/Jakarta" {
+		t.Errorf("Unexpected schedule: %+v", got)
+	}
+
+	if rec := f.do(t, http.MethodDelete, token, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", rec.Code)
+	}
+	if stored, _ := f.quietHours.GetByChildID(context.Background(), f.child.ID); stored != nil {
+		t.Errorf("Expected the schedule to be removed, got %+v", stored)
+	}
+}
+
+func TestQuietHours_RejectsInvalidSchedule(t *testing.T) {
+	f := newQuietHoursFixture(t)
+
+	rec := f.do(t, http.MethodPut, userToken(t, "owner-1"), `{"start":"20:00","end":"06:30","timezone":"Mars/Olympus"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown timezone, got %d", rec.Code)
+	}
+}
+
+func TestQuietHours_OnlyOwner(t *testing.T) {
+	f := newQuietHoursFixture(t)
+
+	rec := f.do(t, http.MethodPut, userToken(t, "someone-else"), `{"start":"20:00","end":"06:30","timezone":"Asia/Jakarta"}`)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-owner, got %d", rec.Code)
+	}
+	if stored, _ := f.quietHours.GetByChildID(context.Background(), f.child.ID); stored != nil {
+		t.Errorf("Expected no schedule stored for a non-owner")
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+	"github.com/satriahrh/arunika/server/internal/audit"
+)
+
+// getRecordingConsent returns whether the child's audio may be recorded
+func getRecordingConsent(c echo.Context, childRepo repositories.ChildRepository, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, RecordingConsentResponse{Consent: child.RecordingConsent})
+}
+
+// putRecordingConsent gives or withdraws consent to the child's audio being
+// recorded. The change applies from the child's next listening session.
+func putRecordingConsent(c echo.Context, childRepo repositories.ChildRepository, trail audit.Trail, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	var req RecordingConsentRequest
+	if err := c.Bind(&req); err != nil || req.Consent == nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Consent must be true or false",
+		})
+	}
+
+	previous := child.RecordingConsent
+	child.RecordingConsent = *req.Consent
+	ctx := c.Request().Context()
+	if err := childRepo.Update(ctx, child); err != nil {
+		logger.Error("Failed to update recording consent",
+			zap.String("child_id", child.ID),
+			zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "update_failed",
+			Message: "Failed to update recording consent",
+		})
+	}
+
+	trail.Record(ctx, audit.Entry{
+		Action:   "recording_consent_updated",
+		ActorID:  claimsFromContext(c).UserID,
+		TargetID: child.ID,
+		Details: map[string]interface{}{
+			"consent":  child.RecordingConsent,
+			"previous": previous,
+		},
+	})
+
+	return c.JSON(http.StatusOK, RecordingConsentResponse{Consent: child.RecordingConsent})
+}
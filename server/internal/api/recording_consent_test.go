@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+func TestRecordingConsent_OwnerGivesAndWithdrawsConsent(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	children := adapters.NewMemoryChildRepository()
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}}
+	if err := children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	trail := &recordingTrail{}
+
+	e := echo.New()
+	e.GET("/api/v1/children/:id/recording-consent", func(c echo.Context) error {
+		return getRecordingConsent(c, children, logger)
+	}, requireRole("user", logger))
+	e.PUT("/api/v1/children/:id/recording-consent", func(c echo.Context) error {
+		return putRecordingConsent(c, children, trail, logger)
+	}, requireRole("user", logger))
+
+	do := func(method, token, body string) (*httptest.ResponseRecorder, RecordingConsentResponse) {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/v1/children/"+child.ID+"/recording-consent", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		var resp RecordingConsentResponse
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return rec, resp
+	}
+	token := userToken(t, "owner-1")
+
+	if rec, resp := do(http.MethodGet, token, ""); rec.Code != http.StatusOK || resp.Consent {
+		t.Fatalf("Expected no consent before any is given, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec, resp := do(http.MethodPut, token, `{"consent":true}`); rec.Code != http.StatusOK || !resp.Consent {
+		t.Fatalf("Expected consent to be given, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stored, _ := children.GetByID(context.Background(), child.ID); !stored.RecordingConsent {
+		t.Error("Expected the consent stored")
+	}
+
+	if rec, _ := do(http.MethodPut, token, `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without a consent, got %d", rec.Code)
+	}
+	if rec, _ := do(http.MethodPut, userToken(t, "someone-else"), `{"consent":false}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-owner, got %d", rec.Code)
+	}
+
+	if rec, resp := do(http.MethodPut, token, `{"consent":false}`); rec.Code != http.StatusOK || resp.Consent {
+		t.Errorf("Expected consent to be withdrawn, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if len(trail.entries) != 2 {
+		t.Fatalf("Expected both changes audited, got %+v", trail.entries)
+	}
+	for i, consent := range []bool{true, false} {
+		entry := trail.entries[i]
+		if entry.Action != "recording_consent_updated" || entry.ActorID != "owner-1" || entry.TargetID != child.ID ||
+			entry.Details["consent"] != consent {
+			t.Errorf("Expected change %d to consent %v audited, got %+v", i, consent, entry)
+		}
+	}
+}
@@ -1,19 +1,38 @@
 package api
 
 import (
+	"errors"
+	"expvar"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 
+	"github.com/satriahrh/arunika/server/domain/errs"
 	"github.com/satriahrh/arunika/server/domain/repositories"
+	"github.com/satriahrh/arunika/server/internal/audit"
 	"github.com/satriahrh/arunika/server/internal/auth"
 	"github.com/satriahrh/arunika/server/internal/websocket"
 )
 
 // InitRoutes initializes all API routes
-func InitRoutes(e *echo.Echo, hub *websocket.Hub, deviceRepo repositories.DeviceRepository, logger *zap.Logger) {
+func InitRoutes(
+	e *echo.Echo,
+	hub *websocket.Hub,
+	deviceRepo repositories.DeviceRepository,
+	provisioner repositories.DeviceProvisioner,
+	childRepo repositories.ChildRepository,
+	sessionRepo repositories.SessionRepository,
+	quietHoursRepo repositories.QuietHoursRepository,
+	voiceManager repositories.VoiceManager,
+	trail audit.Trail,
+	authConfig DeviceAuthConfig,
+	voiceConfig ChildVoiceConfig,
+	logger *zap.Logger,
+) {
 	// Health check
 	e.GET("/health", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, map[string]string{
@@ -24,10 +43,12 @@ func InitRoutes(e *echo.Echo, hub *websocket.Hub, deviceRepo repositories.Device
 
 	// API v1 routes
 	v1 := e.Group("/api/v1")
+	voicePolicy := newVoiceLanguagePolicy(voiceConfig, logger)
 
 	// Device APIs
+	idempotency := newAuthIdempotency(authConfig, logger)
 	v1.POST("/device/auth", func(c echo.Context) error {
-		return deviceAuth(c, deviceRepo, logger)
+		return deviceAuth(c, deviceRepo, idempotency, logger)
 	})
 
 	// User Management APIs
@@ -35,21 +56,128 @@ func InitRoutes(e *echo.Echo, hub *websocket.Hub, deviceRepo repositories.Device
 	v1.POST("/users/login", userLogin)
 
 	// Child Profiles APIs
-	v1.GET("/children", getChildren)
-	v1.POST("/children", createChild)
-	v1.PUT("/children/:id", updateChild)
+	v1.GET("/children", func(c echo.Context) error {
+		return getChildren(c, childRepo, logger)
+	}, requireRole("user", logger))
+	v1.POST("/children", func(c echo.Context) error {
+		return createChild(c, childRepo, deviceRepo, logger)
+	}, requireRole("user", logger))
+	v1.PUT("/children/:id", func(c echo.Context) error {
+		return updateChild(c, childRepo, deviceRepo, logger)
+	}, requireRole("user", logger))
+	v1.GET("/children/:id/conversations", func(c echo.Context) error {
+		return listChildConversations(c, childRepo, sessionRepo, logger)
+	}, requireRole("user", logger))
+	v1.DELETE("/children/:id/conversations", func(c echo.Context) error {
+		return deleteChildConversations(c, childRepo, sessionRepo, hub, trail, logger)
+	}, requireRole("user", logger))
+	v1.GET("/children/:id/quiet-hours", func(c echo.Context) error {
+		return getQuietHours(c, childRepo, quietHoursRepo, logger)
+	}, requireRole("user", logger))
+	v1.PUT("/children/:id/quiet-hours", func(c echo.Context) error {
+		return putQuietHours(c, childRepo, quietHoursRepo, logger)
+	}, requireRole("user", logger))
+	v1.DELETE("/children/:id/quiet-hours", func(c echo.Context) error {
+		return deleteQuietHours(c, childRepo, quietHoursRepo, logger)
+	}, requireRole("user", logger))
+
+	v1.GET("/children/:id/topics", func(c echo.Context) error {
+		return getDiscouragedTopics(c, childRepo, logger)
+	}, requireRole("user", logger))
+	v1.PUT("/children/:id/topics", func(c echo.Context) error {
+		return putDiscouragedTopics(c, childRepo, logger)
+	}, requireRole("user", logger))
+	v1.DELETE("/children/:id/topics", func(c echo.Context) error {
+		return deleteDiscouragedTopics(c, childRepo, logger)
+	}, requireRole("user", logger))
+
+	v1.GET("/children/:id/timezone", func(c echo.Context) error {
+		return getChildTimezone(c, childRepo, logger)
+	}, requireRole("user", logger))
+	v1.PUT("/children/:id/timezone", func(c echo.Context) error {
+		return putChildTimezone(c, childRepo, logger)
+	}, requireRole("user", logger))
+
+	v1.GET("/children/:id/language", func(c echo.Context) error {
+		return getChildLanguage(c, childRepo, logger)
+	}, requireRole("user", logger))
+	v1.PUT("/children/:id/language", func(c echo.Context) error {
+		return putChildLanguage(c, childRepo, voiceManager, voicePolicy, logger)
+	}, requireRole("user", logger))
+
+	v1.GET("/children/:id/recording-consent", func(c echo.Context) error {
+		return getRecordingConsent(c, childRepo, logger)
+	}, requireRole("user", logger))
+	v1.PUT("/children/:id/recording-consent", func(c echo.Context) error {
+		return putRecordingConsent(c, childRepo, trail, logger)
+	}, requireRole("user", logger))
+
+	v1.GET("/children/:id/learned-words", func(c echo.Context) error {
+		return getLearnedWords(c, childRepo, logger)
+	}, requireRole("user", logger))
+	v1.DELETE("/children/:id/learned-words", func(c echo.Context) error {
+		return deleteLearnedWords(c, childRepo, logger)
+	}, requireRole("user", logger))
+	v1.PUT("/children/:id/vocabulary-consent", func(c echo.Context) error {
+		return putVocabularyConsent(c, childRepo, trail, logger)
+	}, requireRole("user", logger))
+
+	v1.GET("/children/:id/transcripts/search", func(c echo.Context) error {
+		return searchTranscripts(c, childRepo, sessionRepo, logger)
+	}, requireRole("user", logger))
+	v1.PUT("/children/:id/voice", func(c echo.Context) error {
+		return assignChildVoice(c, childRepo, voiceManager, voicePolicy, logger)
+	}, requireRole("user", logger))
+	v1.DELETE("/children/:id/voice", func(c echo.Context) error {
+		return resetChildVoice(c, childRepo, logger)
+	}, requireRole("user", logger))
+
+	// Voice Management APIs
+	v1.GET("/voices", func(c echo.Context) error {
+		return listVoices(c, voiceManager, logger)
+	}, requireRole("user", logger))
+	v1.POST("/voices", func(c echo.Context) error {
+		return createVoice(c, voiceManager, logger)
+	}, requireRole("user", logger))
 
 	// Conversation History APIs
 	v1.GET("/conversations", getConversations)
 
+	// Admin APIs
+	v1.POST("/admin/devices/import", func(c echo.Context) error {
+		return importDevices(c, provisioner, trail, logger)
+	}, requireRole("admin", logger))
+	v1.GET("/admin/devices/export", func(c echo.Context) error {
+		return exportDevices(c, provisioner, logger)
+	}, requireRole("admin", logger))
+	v1.POST("/admin/voices/refresh", func(c echo.Context) error {
+		return refreshVoices(c, voiceManager, logger)
+	}, requireRole("admin", logger))
+	v1.POST("/admin/broadcast", func(c echo.Context) error {
+		return broadcastSystemMessage(c, hub, trail, logger)
+	}, requireRole("admin", logger))
+	v1.POST("/admin/devices/:id/selftest", func(c echo.Context) error {
+		return selfTestDevice(c, deviceRepo, hub, logger)
+	}, requireRole("admin", logger))
+	// Operator console taking over the live conversation of a device
+	v1.GET("/admin/devices/:id/takeover", func(c echo.Context) error {
+		return websocket.HandleOperatorWebSocket(hub, c, c.Param("id"), claimsFromContext(c).UserID, logger)
+	}, requireRole("admin", logger))
+	// Runtime metrics expose the command line and per-device counters
+	e.GET("/debug/vars", echo.WrapHandler(expvar.Handler()), requireRole("admin", logger))
+
 	// WebSocket endpoint with JWT validation
 	e.GET("/ws", func(c echo.Context) error {
 		return websocketWithAuth(hub, c, logger)
 	})
+	// Second connection carrying response audio, negotiated on /ws
+	e.GET("/ws/audio", func(c echo.Context) error {
+		return authenticatedWebSocket(hub, c, logger, websocket.HandleAudioWebSocketWithAuth)
+	})
 }
 
 // Placeholder handlers - to be implemented
-func deviceAuth(c echo.Context, deviceRepo repositories.DeviceRepository, logger *zap.Logger) error {
+func deviceAuth(c echo.Context, deviceRepo repositories.DeviceRepository, idempotency *authIdempotency, logger *zap.Logger) error {
 	var req DeviceAuthRequest
 
 	// Bind and validate request
@@ -69,8 +197,25 @@ func deviceAuth(c echo.Context, deviceRepo repositories.DeviceRepository, logger
 		})
 	}
 
+	idempotencyKey := c.Request().Header.Get(idempotencyKeyHeader)
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_idempotency_key",
+			Message: "Idempotency key must be at most 128 characters",
+		})
+	}
+
 	// Validate device credentials
 	device, err := deviceRepo.ValidateDevice(req.SerialNumber, req.SecretKey)
+	if err != nil && !errors.Is(err, errs.ErrDeviceNotFound) && !errors.Is(err, errs.ErrInvalidCredentials) {
+		logger.Error("Failed to validate device credentials",
+			zap.String("serial_number", req.SerialNumber),
+			zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "authentication_unavailable",
+			Message: "Failed to validate device credentials",
+		})
+	}
 	if err != nil {
 		logger.Warn("Device authentication failed",
 			zap.String("serial_number", req.SerialNumber),
@@ -81,8 +226,31 @@ func deviceAuth(c echo.Context, deviceRepo repositories.DeviceRepository, logger
 		})
 	}
 
-	// Generate JWT token for the device
-	token, err := auth.GenerateDeviceToken(device.ID)
+	// Over TLS, the client certificate must be issued to this device
+	if err := auth.VerifyDeviceCertificate(c.Request().TLS, device.ID); err != nil {
+		logger.Warn("Device authentication rejected: client certificate mismatch",
+			zap.String("serial_number", req.SerialNumber),
+			zap.Error(err))
+		return c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "certificate_mismatch",
+			Message: "Client certificate does not match the device",
+		})
+	}
+
+	// A retried request gets the token its first attempt was issued
+	response, reused, err := idempotency.respond(req.SerialNumber, idempotencyKey, func() (DeviceAuthResponse, error) {
+		// Generate JWT token for the device
+		token, err := auth.GenerateDeviceToken(device.ID)
+		if err != nil {
+			return DeviceAuthResponse{}, err
+		}
+		// Calculate expiration time (24 hours from now, matching JWT claims)
+		return DeviceAuthResponse{
+			Token:     token,
+			ExpiresAt: time.Now().Add(24 * time.Hour),
+			DeviceID:  device.ID,
+		}, nil
+	})
 	if err != nil {
 		logger.Error("Failed to generate device token",
 			zap.String("device_id", device.ID),
@@ -93,18 +261,12 @@ func deviceAuth(c echo.Context, deviceRepo repositories.DeviceRepository, logger
 		})
 	}
 
-	// Calculate expiration time (24 hours from now, matching JWT claims)
-	expiresAt := time.Now().Add(24 * time.Hour)
-
 	logger.Info("Device authenticated successfully",
 		zap.String("device_id", device.ID),
-		zap.String("serial_number", device.SerialNumber))
+		zap.String("serial_number", device.SerialNumber),
+		zap.Bool("idempotent_replay", reused))
 
-	return c.JSON(http.StatusOK, DeviceAuthResponse{
-		Token:     token,
-		ExpiresAt: expiresAt,
-		DeviceID:  device.ID,
-	})
+	return c.JSON(http.StatusOK, response)
 }
 
 func userRegister(c echo.Context) error {
@@ -121,27 +283,6 @@ func userLogin(c echo.Context) error {
 	})
 }
 
-func getChildren(c echo.Context) error {
-	// TODO: Implement get children profiles
-	return c.JSON(http.StatusOK, map[string]string{
-		"message": "Get children endpoint - to be implemented",
-	})
-}
-
-func createChild(c echo.Context) error {
-	// TODO: Implement create child profile
-	return c.JSON(http.StatusOK, map[string]string{
-		"message": "Create child endpoint - to be implemented",
-	})
-}
-
-func updateChild(c echo.Context) error {
-	// TODO: Implement update child profile
-	return c.JSON(http.StatusOK, map[string]string{
-		"message": "Update child endpoint - to be implemented",
-	})
-}
-
 func getConversations(c echo.Context) error {
 	// TODO: Implement get conversations
 	return c.JSON(http.StatusOK, map[string]string{
@@ -149,14 +290,26 @@ func getConversations(c echo.Context) error {
 	})
 }
 
-// websocketWithAuth handles WebSocket connections with JWT authentication
+// websocketWithAuth handles WebSocket connections with JWT authentication.
+// Until the hub is ready devices are asked to come back later.
 func websocketWithAuth(hub *websocket.Hub, c echo.Context, logger *zap.Logger) error {
-	// Extract JWT token from Authorization header only
-	var token string
-	authHeader := c.Request().Header.Get("Authorization")
-	if authHeader != "" && len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-		token = authHeader[7:]
+	if !hub.Ready() {
+		logger.Warn("WebSocket connection rejected: dependencies not ready")
+		retryAfter := int(math.Ceil(hub.RetryAfter().Seconds()))
+		c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "not_ready",
+			Message: "Server is not ready to accept connections",
+		})
 	}
+	return authenticatedWebSocket(hub, c, logger, websocket.HandleWebSocketWithAuth)
+}
+
+// authenticatedWebSocket validates the device token of a WebSocket upgrade
+// before passing the request to handle with the authenticated device ID
+func authenticatedWebSocket(hub *websocket.Hub, c echo.Context, logger *zap.Logger, handle func(*websocket.Hub, echo.Context, string, *zap.Logger) error) error {
+	// Extract JWT token from Authorization header only
+	token := bearerToken(c)
 
 	if token == "" {
 		logger.Warn("WebSocket connection rejected: missing token")
@@ -166,14 +319,12 @@ func websocketWithAuth(hub *websocket.Hub, c echo.Context, logger *zap.Logger) e
 		})
 	}
 
-	// Validate JWT token
+	// Validate JWT token. Devices refresh an expired token, but
+	// authenticate from scratch when it is malformed or tampered with.
 	claims, err := auth.ValidateToken(token)
 	if err != nil {
 		logger.Warn("WebSocket connection rejected: invalid token", zap.Error(err))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error:   "invalid_token",
-			Message: "Invalid or expired JWT token",
-		})
+		return c.JSON(http.StatusUnauthorized, deviceTokenError(err))
 	}
 
 	// Verify this is a device token
@@ -196,10 +347,21 @@ func websocketWithAuth(hub *websocket.Hub, c echo.Context, logger *zap.Logger) e
 		})
 	}
 
+	// With mTLS the certificate and the token must name the same device
+	if err := auth.VerifyDeviceCertificate(c.Request().TLS, deviceID); err != nil {
+		logger.Warn("WebSocket connection rejected: client certificate mismatch",
+			zap.String("device_id", deviceID),
+			zap.Error(err))
+		return c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "certificate_mismatch",
+			Message: "Client certificate does not match the device in the token",
+		})
+	}
+
 	logger.Info("WebSocket connection authenticated",
 		zap.String("device_id", deviceID),
 		zap.String("role", claims.Role))
 
 	// Handle WebSocket connection with authenticated device ID
-	return websocket.HandleWebSocketWithAuth(hub, c, deviceID, logger)
+	return handle(hub, c, deviceID, logger)
 }
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/adapters/tts"
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/audit"
+	"github.com/satriahrh/arunika/server/internal/websocket"
+)
+
+// newTestRoutes serves every route over memory repositories holding device-1
+func newTestRoutes(t *testing.T) *echo.Echo {
+	t.Helper()
+	// The hub outlives the test's logger, so log nowhere
+	logger := zap.NewNop()
+	server := newElevenLabsVoicesServer()
+	t.Cleanup(server.Close)
+	voiceManager, err := tts.NewElevenLabsTTS(tts.ElevenLabsConfig{APIKey: "key", APIBaseURL: server.URL}, logger)
+	if err != nil {
+		t.Fatalf("Failed to create voice manager: %v", err)
+	}
+
+	devices := adapters.NewMemoryDeviceRepository()
+	if err := devices.Create(context.Background(), &entities.Device{ID: "device-1", SerialNumber: "SN-1", SecretKey: "secret", Model: "doll-v1"}); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	sessions := adapters.NewMemorySessionRepository(adapters.MemorySessionConfig{}, logger)
+
+	e := echo.New()
+	InitRoutes(e, newWebSocketHub(t, websocket.HubConfig{}), devices, devices, adapters.NewMemoryChildRepository(),
+		sessions, adapters.NewMemoryQuietHoursRepository(), voiceManager, audit.NewZapTrail(logger),
+		DeviceAuthConfig{}, ChildVoiceConfig{}, logger)
+	return e
+}
+
+// serveRoute sends a request bearing token, none when empty
+func serveRoute(e *echo.Echo, token, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// The per-child endpoints all work on profiles parents create through the
+// API, not only on profiles seeded into the repository
+func TestInitRoutes_ChildEndpointsUseCreatedProfile(t *testing.T) {
+	e := newTestRoutes(t)
+	token := userToken(t, "owner-1")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		return serveRoute(e, token, method, path, body)
+	}
+
+	rec := do(http.MethodPost, "/api/v1/children", `{"name":"Kirana","device_ids":["device-1"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var child entities.Child
+	if err := json.Unmarshal(rec.Body.Bytes(), &child); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	path := "/api/v1/children/" + child.ID
+	requests := []struct {
+		method, path, body string
+	}{
+		{http.MethodPut, path + "/voice", `{"voice_id":"clone-mama"}`},
+		{http.MethodPut, path + "/language", `{"language":"id-ID"}`},
+		{http.MethodPut, path + "/timezone", `{"timezone":"Asia/Jakarta"}`},
+		{http.MethodPut, path + "/topics", `{"topics":["war"]}`},
+		{http.MethodPut, path + "/recording-consent", `{"consent":true}`},
+		{http.MethodPut, path + "/vocabulary-consent", `{"consent":true}`},
+		{http.MethodGet, path + "/learned-words", ""},
+		{http.MethodGet, path + "/conversations", ""},
+		{http.MethodDelete, path + "/conversations?confirm=true", ""},
+	}
+	for _, r := range requests {
+		if rec := do(r.method, r.path, r.body); rec.Code != http.StatusOK {
+			t.Errorf("%s %s: expected status 200, got %d: %s", r.method, r.path, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+// The runtime metrics are only served to admins
+func TestInitRoutes_DebugVarsRequiresAdmin(t *testing.T) {
+	e := newTestRoutes(t)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"user token", userToken(t, "owner-1"), http.StatusForbidden},
+		{"admin token", adminToken(t), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serveRoute(e, tt.token, http.MethodGet, "/debug/vars", ""); rec.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/errs"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+)
+
+// deviceSelfTester runs a synthetic turn of a device through the pipeline
+type deviceSelfTester interface {
+	SelfTest(ctx context.Context, deviceID string) conversation.SelfTestReport
+}
+
+// selfTestDevice runs a synthetic turn of a device, from speech-to-text to
+// text-to-speech, with the configuration of its conversations, and reports
+// every stage. A failing stage is reported with 200; only an unknown device
+// fails the request.
+func selfTestDevice(c echo.Context, deviceRepo repositories.DeviceRepository, tester deviceSelfTester, logger *zap.Logger) error {
+	deviceID := c.Param("id")
+	if _, err := deviceRepo.GetByID(c.Request().Context(), deviceID); err != nil {
+		if errors.Is(err, errs.ErrDeviceNotFound) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "device_not_found",
+				Message: "Device not found",
+			})
+		}
+		logger.Error("Failed to look up device for self-test", zap.String("device_id", deviceID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "lookup_failed",
+			Message: "Failed to look up device",
+		})
+	}
+
+	report := tester.SelfTest(c.Request().Context(), deviceID)
+
+	resp := SelfTestResponse{
+		DeviceID: report.DeviceID,
+		OK:       report.OK(),
+		ChildID:  report.ChildID,
+		Language: report.Language,
+		VoiceID:  report.VoiceID,
+		Stages:   make([]SelfTestStageResult, 0, len(report.Stages)),
+	}
+	for _, stage := range report.Stages {
+		resp.Stages = append(resp.Stages, SelfTestStageResult{
+			Name:      stage.Name,
+			OK:        stage.OK,
+			LatencyMs: stage.Latency.Milliseconds(),
+			Error:     stage.Error,
+			Output:    stage.Output,
+		})
+	}
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+)
+
+// fakeSelfTester reports a failing text-to-speech stage
+type fakeSelfTester struct {
+	tested []string
+}
+
+func (f *fakeSelfTester) SelfTest(ctx context.Context, deviceID string) conversation.SelfTestReport {
+	f.tested = append(f.tested, deviceID)
+	return conversation.SelfTestReport{
+		DeviceID: deviceID,
+		Language: "id-ID",
+		Stages: []conversation.SelfTestStage{
+			{Name: conversation.SelfTestSTT, OK: true, Latency: 120 * time.Millisecond, Output: "halo"},
+			{Name: conversation.SelfTestLLM, OK: true, Latency: 800 * time.Millisecond, Output: "Halo juga!"},
+			{Name: conversation.SelfTestTTS, Latency: 30 * time.Millisecond, Error: "quota exceeded"},
+		},
+	}
+}
+
+func TestSelfTestDevice_ReportsEveryStage(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	devices := adapters.NewMemoryDeviceRepository()
+	device := &entities.Device{SerialNumber: "ARU-0001", Model: "doll-v1"}
+	if err := devices.Create(context.Background(), device); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	tester := &fakeSelfTester{}
+	e := echo.New()
+	e.POST("/api/v1/admin/devices/:id/selftest", func(c echo.Context) error {
+		return selfTestDevice(c, devices, tester, logger)
+	}, requireRole("admin", logger))
+	selfTest := func(deviceID, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/devices/"+deviceID+"/selftest", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := selfTest(device.ID, adminToken(t))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp SelfTestResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.OK || resp.DeviceID != device.ID || len(resp.Stages) != 3 {
+		t.Fatalf("Expected a failed self-test of three stages, got %+v", resp)
+	}
+	if stt := resp.Stages[0]; stt.Name != "stt" || !stt.OK || stt.LatencyMs != 120 || stt.Output != "halo" {
+		t.Errorf("Unexpected speech-to-text stage %+v", stt)
+	}
+	if tts := resp.Stages[2]; tts.Name != "tts" || tts.OK || tts.Error != "quota exceeded" {
+		t.Errorf("Unexpected text-to-speech stage %+v", tts)
+	}
+
+	if rec := selfTest("device-unknown", adminToken(t)); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown device, got %d", rec.Code)
+	}
+	if rec := selfTest(device.ID, userToken(t, "owner-1")); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a user token, got %d", rec.Code)
+	}
+	if len(tester.tested) != 1 {
+		t.Errorf("Expected a single self-test run, got %v", tester.tested)
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// getChildTimezone returns the timezone the doll tells the time of day in
+func getChildTimezone(c echo.Context, childRepo repositories.ChildRepository, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, TimezoneResponse{Timezone: child.Timezone})
+}
+
+// putChildTimezone sets the IANA timezone the doll tells the time of day in.
+// An empty timezone falls back to the server's default. The change applies
+// from the child's next turn.
+func putChildTimezone(c echo.Context, childRepo repositories.ChildRepository, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	var req TimezoneRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request format",
+		})
+	}
+
+	child.Timezone = req.Timezone
+	if _, err := child.Location(); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_timezone",
+			Message: err.Error(),
+		})
+	}
+
+	if err := childRepo.Update(c.Request().Context(), child); err != nil {
+		logger.Error("Failed to update child timezone",
+			zap.String("child_id", child.ID),
+			zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "update_failed",
+			Message: "Failed to update timezone",
+		})
+	}
+
+	logger.Info("Child timezone updated",
+		zap.String("child_id", child.ID),
+		zap.String("timezone", child.Timezone))
+
+	return c.JSON(http.StatusOK, TimezoneResponse{Timezone: child.Timezone})
+}
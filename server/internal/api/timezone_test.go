@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+func TestChildTimezone_OwnerSetsTimezone(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	children := adapters.NewMemoryChildRepository()
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}}
+	if err := children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/children/:id/timezone", func(c echo.Context) error {
+		return getChildTimezone(c, children, logger)
+	}, requireRole("user", logger))
+	e.PUT("/api/v1/children/:id/timezone", func(c echo.Context) error {
+		return putChildTimezone(c, children, logger)
+	}, requireRole("user", logger))
+
+	do := func(method, token, body string) (*httptest.ResponseRecorder, TimezoneResponse) {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/v1/children/"+child.ID+"/timezone", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		var resp TimezoneResponse
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return rec, resp
+	}
+	token := userToken(t, "owner-1")
+
+	if rec, resp := do(http.MethodGet, token, ""); rec.Code != http.StatusOK || resp.Timezone != "" {
+		t.Fatalf("Expected no timezone before any is set, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec, resp := do(http.MethodPut, token, `{"timezone":"Asia/Makassar"}`); rec.Code != http.StatusOK || resp.Timezone != "Asia/Makassar" {
+		t.Fatalf("Expected the timezone to be set, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stored, _ := children.GetByID(context.Background(), child.ID); stored.Timezone != "Asia/Makassar" {
+		t.Errorf("Expected the timezone stored, got %q", stored.Timezone)
+	}
+
+	if rec, _ := do(http.MethodPut, token, `{"timezone":"Mars/Olympus"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown timezone, got %d", rec.Code)
+	}
+	if rec, _ := do(http.MethodPut, userToken(t, "someone-else"), `{"timezone":"UTC"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-owner, got %d", rec.Code)
+	}
+	if stored, _ := children.GetByID(context.Background(), child.ID); stored.Timezone != "Asia/Makassar" {
+		t.Errorf("Expected rejected changes not to be stored, got %q", stored.Timezone)
+	}
+
+	if rec, resp := do(http.MethodPut, token, `{"timezone":""}`); rec.Code != http.StatusOK || resp.Timezone != "" {
+		t.Errorf("Expected the timezone to be cleared, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// getDiscouragedTopics returns the topics the doll steers a child away from
+func getDiscouragedTopics(c echo.Context, childRepo repositories.ChildRepository, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, DiscouragedTopicsResponse{Topics: append([]string{}, child.DiscouragedTopics...)})
+}
+
+// putDiscouragedTopics replaces the topics the doll steers a child away from.
+// The change applies from the child's next session.
+func putDiscouragedTopics(c echo.Context, childRepo repositories.ChildRepository, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	var req DiscouragedTopicsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request format",
+		})
+	}
+
+	topics, err := entities.NormalizeDiscouragedTopics(req.Topics)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_topics",
+			Message: err.Error(),
+		})
+	}
+
+	child.DiscouragedTopics = topics
+	return updateDiscouragedTopics(c, childRepo, child, logger)
+}
+
+// deleteDiscouragedTopics clears the topics the doll steers a child away from
+func deleteDiscouragedTopics(c echo.Context, childRepo repositories.ChildRepository, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	child.DiscouragedTopics = nil
+	return updateDiscouragedTopics(c, childRepo, child, logger)
+}
+
+func updateDiscouragedTopics(c echo.Context, childRepo repositories.ChildRepository, child *entities.Child, logger *zap.Logger) error {
+	if err := childRepo.Update(c.Request().Context(), child); err != nil {
+		logger.Error("Failed to update discouraged topics",
+			zap.String("child_id", child.ID),
+			zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "update_failed",
+			Message: "Failed to update discouraged topics",
+		})
+	}
+
+	logger.Info("Discouraged topics updated",
+		zap.String("child_id", child.ID),
+		zap.Int("topics", len(child.DiscouragedTopics)))
+
+	return c.JSON(http.StatusOK, DiscouragedTopicsResponse{Topics: append([]string{}, child.DiscouragedTopics...)})
+}
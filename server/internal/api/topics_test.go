@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+type topicsFixture struct {
+	echo     *echo.Echo
+	child    *entities.Child
+	children *adapters.MemoryChildRepository
+}
+
+func newTopicsFixture(t *testing.T) *topicsFixture {
+	t.Helper()
+	logger := zaptest.NewLogger(t)
+	f := &topicsFixture{
+		echo:     echo.New(),
+		children: adapters.NewMemoryChildRepository(),
+	}
+
+	f.child = &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}}
+	if err := f.children.Create(context.Background(), f.child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+
+	f.echo.GET("/api/v1/children/:id/topics", func(c echo.Context) error {
+		return getDiscouragedTopics(c, f.children, logger)
+	}, requireRole("user", logger))
+	f.echo.PUT("/api/v1/children/:id/topics", func(c echo.Context) error {
+		return putDiscouragedTopics(c, f.children, logger)
+	}, requireRole("user", logger))
+	f.echo.DELETE("/api/v1/children/:id/topics", func(c echo.Context) error {
+		return deleteDiscouragedTopics(c, f.children, logger)
+	}, requireRole("user", logger))
+
+	return f
+}
+
+func (f *topicsFixture) do(t *testing.T, method, token, body string) (*httptest.ResponseRecorder, DiscouragedTopicsResponse) {
+	t.Helper()
+	req := httptest.NewRequest(method, "/api/v1/children/"+f.child.ID+"/topics", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	f.echo.ServeHTTP(rec, req)
+
+	var resp DiscouragedTopicsResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+	return rec, resp
+}
+
+func TestDiscouragedTopics_OwnerManagesList(t *testing.T) {
+	f := newTopicsFixture(t)
+	token := userToken(t, "owner-1")
+
+	if rec, resp := f.do(t, http.MethodGet, token, ""); rec.Code != http.StatusOK || resp.Topics == nil || len(resp.Topics) != 0 {
+		t.Fatalf("Expected an empty list before any topic is set, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec, resp := f.do(t, http.MethodPut, token, `{"topics":[" ghost   stories ","war","Ghost Stories",""]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	want := []string{"ghost stories", "war"}
+	if !reflect.DeepEqual(resp.Topics, want) {
+		t.Errorf("Expected normalized topics %v, got %v", want, resp.Topics)
+	}
+	if stored, _ := f.children.GetByID(context.Background(), f.child.ID); !reflect.DeepEqual(stored.DiscouragedTopics, want) {
+		t.Errorf("Expected topics %v stored, got %v", want, stored.DiscouragedTopics)
+	}
+
+	if rec, resp := f.do(t, http.MethodDelete, token, ""); rec.Code != http.StatusOK || len(resp.Topics) != 0 {
+		t.Fatalf("Expected the list to be cleared, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stored, _ := f.children.GetByID(context.Background(), f.child.ID); len(stored.DiscouragedTopics) != 0 {
+		t.Errorf("Expected no topics stored, got %v", stored.DiscouragedTopics)
+	}
+}
+
+func TestDiscouragedTopics_RejectsInvalidList(t *testing.T) {
+	f := newTopicsFixture(t)
+	token := userToken(t, "owner-1")
+
+	var req DiscouragedTopicsRequest
+	for i := 0; i <= entities.MaxDiscouragedTopics; i++ {
+		req.Topics = append(req.Topics, fmt.Sprintf("topic %d", i))
+	}
+	tooMany, _ := json.Marshal(req)
+	for name, body := range map[string]string{
+		"too long":  `{"topics":["` + strings.Repeat("a", entities.MaxDiscouragedTopicBytes+1) + `"]}`,
+		"too many":  string(tooMany),
+		"malformed": `{"topics":"war"}`,
+	} {
+		if rec, _ := f.do(t, http.MethodPut, token, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for a %s list, got %d", name, rec.Code)
+		}
+	}
+}
+
+func TestDiscouragedTopics_OnlyOwner(t *testing.T) {
+	f := newTopicsFixture(t)
+
+	if rec, _ := f.do(t, http.MethodPut, userToken(t, "someone-else"), `{"topics":["war"]}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-owner, got %d", rec.Code)
+	}
+	if stored, _ := f.children.GetByID(context.Background(), f.child.ID); len(stored.DiscouragedTopics) != 0 {
+		t.Errorf("Expected no topics stored for a non-owner, got %v", stored.DiscouragedTopics)
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchOffset    = 500 // Deeper pages should narrow the query instead
+)
+
+// searchTranscripts finds messages across every session of a child's devices
+func searchTranscripts(c echo.Context, childRepo repositories.ChildRepository, sessionRepo repositories.SessionRepository, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	query := c.QueryParam("q")
+	if _, err := entities.SearchTerms(query); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_query",
+			Message: "Search query must contain at least one word",
+		})
+	}
+
+	limit, err := queryInt(c, "limit", defaultSearchLimit)
+	if err != nil || limit < 1 || limit > maxSearchLimit {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_limit",
+			Message: "Limit must be between 1 and 100",
+		})
+	}
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil || offset < 0 || offset > maxSearchOffset {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_offset",
+			Message: "Offset must be between 0 and 500",
+		})
+	}
+
+	// One extra result tells whether another page exists
+	wanted := offset + limit + 1
+	var matches []entities.MessageMatch
+	for _, deviceID := range child.DeviceIDs {
+		deviceMatches, err := sessionRepo.Search(c.Request().Context(), deviceID, query, wanted)
+		if err != nil {
+			logger.Error("Failed to search transcripts",
+				zap.String("child_id", child.ID),
+				zap.String("device_id", deviceID),
+				zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "search_failed",
+				Message: "Failed to search conversations",
+			})
+		}
+		matches = append(matches, deviceMatches...)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Timestamp.After(matches[j].Timestamp)
+	})
+
+	resp := TranscriptSearchResponse{
+		Query:   query,
+		Results: []entities.MessageMatch{},
+		Offset:  offset,
+		Limit:   limit,
+	}
+	if offset < len(matches) {
+		end := min(offset+limit, len(matches))
+		resp.Results = matches[offset:end]
+		resp.HasMore = len(matches) > end
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// queryInt parses an optional integer query parameter
+func queryInt(c echo.Context, name string, fallback int) (int, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+type searchFixture struct {
+	echo  *echo.Echo
+	child *entities.Child
+}
+
+func newSearchFixture(t *testing.T) *searchFixture {
+	t.Helper()
+	logger := zaptest.NewLogger(t)
+	f := &searchFixture{echo: echo.New()}
+
+	childRepo := adapters.NewMemoryChildRepository()
+	f.child = &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1", "device-2"}}
+	if err := childRepo.Create(context.Background(), f.child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+
+	base := time.Date(2025, 3, 1, 19, 0, 0, 0, time.UTC)
+	sessions := &fakeSessionRepository{}
+	seed := []struct {
+		deviceID string
+		content  string
+	}{
+		{"device-1", "Aku takut gelap"},
+		{"device-1", "Aku suka kucing"},
+		{"device-2", "Tadi aku takut sama petir"},
+		{"device-2", "Ketakutan itu wajar"},
+		{"device-other", "Aku juga takut"},
+	}
+	for i, s := range seed {
+		sessions.Create(context.Background(), &entities.Session{
+			ID:       "session-" + s.deviceID,
+			DeviceID: s.deviceID,
+			Messages: []entities.Message{{Timestamp: base.Add(time.Duration(i) * time.Minute), Role: entities.UserRole, Content: s.content}},
+		})
+	}
+
+	f.echo.GET("/api/v1/children/:id/transcripts/search", func(c echo.Context) error {
+		return searchTranscripts(c, childRepo, sessions, logger)
+	}, requireRole("user", logger))
+
+	return f
+}
+
+func (f *searchFixture) search(t *testing.T, token string, query url.Values) (*httptest.ResponseRecorder, TranscriptSearchResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/children/"+f.child.ID+"/transcripts/search?"+query.Encode(), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	f.echo.ServeHTTP(rec, req)
+
+	var resp TranscriptSearchResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+	return rec, resp
+}
+
+func TestSearchTranscripts_ReturnsMatchesAcrossDevices(t *testing.T) {
+	f := newSearchFixture(t)
+
+	rec, resp := f.search(t, userToken(t, "owner-1"), url.Values{"q": {"takut"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if len(resp.Results) != 2 {
+		t.Fatalf("Expected two matches, got %+v", resp.Results)
+	}
+	if resp.Results[0].Snippet != "Tadi aku takut sama petir" || resp.Results[0].DeviceID != "device-2" {
+		t.Errorf("Expected the newest match first, got %+v", resp.Results[0])
+	}
+	if resp.Results[1].Snippet != "Aku takut gelap" || resp.Results[1].SessionID != "session-device-1" {
+		t.Errorf("Unexpected second match %+v", resp.Results[1])
+	}
+	if resp.HasMore {
+		t.Errorf("Expected no further pages")
+	}
+}
+
+func TestSearchTranscripts_Paginates(t *testing.T) {
+	f := newSearchFixture(t)
+	token := userToken(t, "owner-1")
+
+	_, first := f.search(t, token, url.Values{"q": {"takut"}, "limit": {"1"}})
+	if len(first.Results) != 1 || !first.HasMore {
+		t.Fatalf("Expected one result and another page, got %+v", first)
+	}
+
+	_, second := f.search(t, token, url.Values{"q": {"takut"}, "limit": {"1"}, "offset": {"1"}})
+	if len(second.Results) != 1 || second.HasMore || second.Results[0].Timestamp.Equal(first.Results[0].Timestamp) {
+		t.Errorf("Expected the second and last result, got %+v", second)
+	}
+}
+
+func TestSearchTranscripts_RejectsInvalidRequests(t *testing.T) {
+	f := newSearchFixture(t)
+	token := userToken(t, "owner-1")
+
+	for _, query := range []url.Values{
+		{"q": {`"-"`}},
+		{"q": {"takut"}, "limit": {"1000"}},
+		{"q": {"takut"}, "offset": {"-1"}},
+	} {
+		if rec, _ := f.search(t, token, query); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %v, got %d", query, rec.Code)
+		}
+	}
+
+	if rec, _ := f.search(t, userToken(t, "someone-else"), url.Values{"q": {"takut"}}); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-owner, got %d", rec.Code)
+	}
+}
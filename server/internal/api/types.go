@@ -1,6 +1,10 @@
 package api
 
-import "time"
+import (
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
 
 // DeviceAuthRequest represents the request payload for device authentication
 type DeviceAuthRequest struct {
@@ -20,3 +24,236 @@ type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message,omitempty"`
 }
+
+// ChildProfileRequest represents the request payload for creating or updating
+// a child profile. BirthDate is formatted as YYYY-MM-DD and may be empty.
+type ChildProfileRequest struct {
+	Name      string   `json:"name" validate:"required"`
+	DeviceIDs []string `json:"device_ids"`
+	BirthDate string   `json:"birth_date,omitempty"`
+}
+
+// ChildrenResponse represents the child profiles of a parent, the oldest first
+type ChildrenResponse struct {
+	Children []*entities.Child `json:"children"`
+}
+
+// DeleteConversationsResponse represents the response payload for conversation erasure
+type DeleteConversationsResponse struct {
+	ChildID         string `json:"child_id"`
+	SessionsDeleted int64  `json:"sessions_deleted"`
+}
+
+// ConversationListResponse represents the sessions of a child's devices,
+// the most recently active first
+type ConversationListResponse struct {
+	ChildID       string                `json:"child_id"`
+	Conversations []ConversationSummary `json:"conversations"`
+}
+
+// ConversationSummary describes a session without its messages. ParentSummary
+// is set once the session ended and was summarized.
+type ConversationSummary struct {
+	SessionID     string                  `json:"session_id"`
+	DeviceID      string                  `json:"device_id"`
+	StartedAt     time.Time               `json:"started_at"`
+	LastMessageAt time.Time               `json:"last_message_at"`
+	EndedAt       *time.Time              `json:"ended_at,omitempty"`
+	MessageCount  int                     `json:"message_count"`
+	ParentSummary *entities.ParentSummary `json:"parent_summary,omitempty"`
+}
+
+// QuietHoursRequest represents the request payload for setting a child's quiet hours.
+// Start and End are "HH:MM" wall-clock times in the IANA Timezone.
+type QuietHoursRequest struct {
+	Start    string `json:"start" validate:"required"`
+	End      string `json:"end" validate:"required"`
+	Timezone string `json:"timezone" validate:"required"`
+}
+
+// DiscouragedTopicsRequest represents the request payload for replacing the
+// topics the doll steers a child away from
+type DiscouragedTopicsRequest struct {
+	Topics []string `json:"topics"`
+}
+
+// DiscouragedTopicsResponse represents the topics the doll steers a child away from
+type DiscouragedTopicsResponse struct {
+	Topics []string `json:"topics"`
+}
+
+// TimezoneRequest represents the request payload for setting a child's IANA
+// timezone, empty for the server's default
+type TimezoneRequest struct {
+	Timezone string `json:"timezone"`
+}
+
+// TimezoneResponse represents the timezone the doll tells a child the time of day in
+type TimezoneResponse struct {
+	Timezone string `json:"timezone"`
+}
+
+// RecordingConsentRequest represents the request payload for giving or
+// withdrawing consent to a child's audio being recorded
+type RecordingConsentRequest struct {
+	Consent *bool `json:"consent"`
+}
+
+// RecordingConsentResponse represents whether a child's audio may be recorded
+type RecordingConsentResponse struct {
+	Consent bool `json:"consent"`
+}
+
+// LearnedWordsResponse represents the words a child learned, the most
+// recent first, and whether the parent consented to them being tracked
+type LearnedWordsResponse struct {
+	ChildID string                 `json:"child_id"`
+	Consent bool                   `json:"consent"`
+	Date    string                 `json:"date,omitempty"`
+	Words   []entities.LearnedWord `json:"words"`
+}
+
+// LanguageRequest represents the request payload for locking the doll to a
+// BCP-47 language for a child, empty to follow the device's language, and
+// optionally for setting the language the child usually speaks
+type LanguageRequest struct {
+	Language          string  `json:"language"`
+	PreferredLanguage *string `json:"preferred_language,omitempty"`
+}
+
+// LanguageResponse represents the language the doll is locked to for a child
+// and the language the child usually speaks
+type LanguageResponse struct {
+	Language          string `json:"language"`
+	PreferredLanguage string `json:"preferred_language,omitempty"`
+	// VoiceWarning is voice_language_mismatch when the child's voice does not
+	// speak the new language
+	VoiceWarning string `json:"voice_warning,omitempty"`
+}
+
+// VoicesResponse represents the response payload for listing voices
+type VoicesResponse struct {
+	Voices []entities.Voice `json:"voices"`
+}
+
+// VoicesRefreshResponse represents the response payload for refreshing the cached voices
+type VoicesRefreshResponse struct {
+	Voices int `json:"voices"`
+}
+
+// AssignVoiceRequest represents the request payload for assigning a child's voice
+type AssignVoiceRequest struct {
+	VoiceID string `json:"voice_id" validate:"required"`
+}
+
+// ChildVoiceResponse represents the child profile after its voice changed
+type ChildVoiceResponse struct {
+	*entities.Child
+	// VoiceWarning is voice_language_mismatch when the voice does not speak
+	// the child's language
+	VoiceWarning string `json:"voice_warning,omitempty"`
+}
+
+// TranscriptSearchResponse represents a page of transcript search results, newest first
+type TranscriptSearchResponse struct {
+	Query   string                  `json:"query"`
+	Results []entities.MessageMatch `json:"results"`
+	Offset  int                     `json:"offset"`
+	Limit   int                     `json:"limit"`
+	HasMore bool                    `json:"has_more"`
+}
+
+// SelfTestResponse represents the outcome of a synthetic turn of a device,
+// with the configuration it ran with
+type SelfTestResponse struct {
+	DeviceID string                `json:"device_id"`
+	OK       bool                  `json:"ok"`
+	ChildID  string                `json:"child_id,omitempty"`
+	Language string                `json:"language"`
+	VoiceID  string                `json:"voice_id,omitempty"`
+	Stages   []SelfTestStageResult `json:"stages"`
+}
+
+// SelfTestStageResult represents the outcome of a stage of a self-test, one
+// of "stt", "llm" and "tts"
+type SelfTestStageResult struct {
+	Name      string `json:"name"`
+	OK        bool   `json:"ok"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	Output    string `json:"output,omitempty"`
+}
+
+// BroadcastRequest represents the JSON payload for sending a system message
+// to the connected devices a selector picks
+type BroadcastRequest struct {
+	Selector BroadcastSelector `json:"selector"`
+	Message  BroadcastMessage  `json:"message" validate:"required"`
+}
+
+// BroadcastSelector picks devices by their records; fields left empty match
+// any device, so an empty selector picks every connected device
+type BroadcastSelector struct {
+	Model           string `json:"model,omitempty"`
+	OwnerID         string `json:"owner_id,omitempty"`
+	FirmwareVersion string `json:"firmware_version,omitempty"`
+}
+
+// BroadcastMessage is the system message sent to the devices
+type BroadcastMessage struct {
+	Kind string `json:"kind,omitempty"`
+	Text string `json:"text" validate:"required"`
+}
+
+// BroadcastResponse represents the outcome of a broadcast
+type BroadcastResponse struct {
+	Delivered int `json:"delivered"`
+}
+
+// DeviceImportRequest represents the JSON payload for importing devices in bulk
+type DeviceImportRequest struct {
+	Devices []DeviceImportEntry `json:"devices" validate:"required"`
+}
+
+// DeviceImportEntry is a device to provision, one row of an import
+type DeviceImportEntry struct {
+	SerialNumber string `json:"serial_number" validate:"required"`
+	Model        string `json:"model" validate:"required"`
+}
+
+// DeviceImportResponse represents the outcome of a bulk device import.
+// Secrets are only returned for devices created by this import.
+type DeviceImportResponse struct {
+	Created  int              `json:"created"`
+	Existing int              `json:"existing"`
+	Devices  []ImportedDevice `json:"devices"`
+}
+
+// ImportedDevice is the outcome of importing one device, with status "created" or "exists"
+type ImportedDevice struct {
+	SerialNumber string `json:"serial_number"`
+	Model        string `json:"model"`
+	Status       string `json:"status"`
+	DeviceID     string `json:"device_id,omitempty"`
+	SecretKey    string `json:"secret_key,omitempty"`
+}
+
+// DeviceExportResponse represents the manifest of provisioned devices, without secrets
+type DeviceExportResponse struct {
+	Count   int                   `json:"count"`
+	Devices []DeviceManifestEntry `json:"devices"`
+}
+
+// DeviceManifestEntry is one provisioned device in the export manifest
+type DeviceManifestEntry struct {
+	DeviceID     string    `json:"device_id"`
+	SerialNumber string    `json:"serial_number"`
+	Model        string    `json:"model"`
+	Owned        bool      `json:"owned"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	Online          bool       `json:"online"`
+	LastConnectedAt *time.Time `json:"last_connected_at,omitempty"`
+	UptimeSeconds   int64      `json:"uptime_seconds"`
+	DowntimeSeconds int64      `json:"downtime_seconds"`
+}
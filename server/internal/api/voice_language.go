@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// A voice that does not speak a child's language makes the doll sound
+// foreign, such as an English-only voice speaking Indonesian. When the TTS
+// provider tells the languages of its voices, assigning such a voice, or
+// changing the language of a child to one their voice does not speak, is
+// flagged or refused depending on the voice language policy.
+
+// Voice language policies
+const (
+	// VoiceLanguageOff does not check the language of voices
+	VoiceLanguageOff = "off"
+	// VoiceLanguageWarn accepts the change and flags the mismatch in the response
+	VoiceLanguageWarn = "warn"
+	// VoiceLanguageBlock refuses the change
+	VoiceLanguageBlock = "block"
+
+	defaultVoiceLanguagePolicy = VoiceLanguageOff
+)
+
+// voiceLanguageMismatch flags a voice that does not speak the child's language
+const voiceLanguageMismatch = "voice_language_mismatch"
+
+// ChildVoiceConfig holds configuration for the voices of child profiles
+// Optional fields with defaults:
+// - LanguagePolicy: What happens when a child's voice does not speak their language, "off", "warn" to flag it in the response or "block" to refuse it (default: "off")
+type ChildVoiceConfig struct {
+	LanguagePolicy string // Optional: VoiceLanguageOff, VoiceLanguageWarn or VoiceLanguageBlock
+}
+
+// NewChildVoiceConfigFromEnv creates a new ChildVoiceConfig from environment variables
+// This is a helper function to simplify the creation of a properly configured ChildVoiceConfig
+func NewChildVoiceConfigFromEnv() ChildVoiceConfig {
+	return ChildVoiceConfig{
+		LanguagePolicy: os.Getenv("CHILD_VOICE_LANGUAGE_POLICY"),
+	}
+}
+
+// newVoiceLanguagePolicy returns the voice language policy of config
+func newVoiceLanguagePolicy(config ChildVoiceConfig, logger *zap.Logger) string {
+	switch config.LanguagePolicy {
+	case VoiceLanguageOff, VoiceLanguageWarn, VoiceLanguageBlock:
+		return config.LanguagePolicy
+	case "":
+		logger.Info("Using default voice language policy", zap.String("voiceLanguagePolicy", defaultVoiceLanguagePolicy))
+	default:
+		logger.Warn("Unknown voice language policy, using the default",
+			zap.String("voiceLanguagePolicy", config.LanguagePolicy),
+			zap.String("default", defaultVoiceLanguagePolicy))
+	}
+	return defaultVoiceLanguagePolicy
+}
+
+// childLanguage returns the language the doll speaks with child whatever the
+// device, empty when the device decides
+func childLanguage(child *entities.Child) string {
+	if child.ForcedLanguage != "" {
+		return child.ForcedLanguage
+	}
+	return child.Language
+}
+
+// assignedVoice returns the voice assigned to child among those visible to
+// its owner, nil when the child has none or it is not listed
+func assignedVoice(ctx context.Context, voiceManager repositories.VoiceManager, child *entities.Child) (*entities.Voice, error) {
+	if child.VoiceID == "" || voiceManager == nil {
+		return nil, nil
+	}
+	voices, err := voiceManager.ListVoices(ctx, child.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, voice := range voices {
+		if voice.ID == child.VoiceID {
+			return &voice, nil
+		}
+	}
+	return nil, nil
+}
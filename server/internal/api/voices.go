@@ -0,0 +1,214 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+const (
+	maxVoiceSamples     = 25       // Eleven Labs accepts at most 25 samples per voice
+	maxVoiceSampleBytes = 10 << 20 // Per sample
+)
+
+// listVoices returns the voices the caller can assign to their children
+func listVoices(c echo.Context, voiceManager repositories.VoiceManager, logger *zap.Logger) error {
+	claims := claimsFromContext(c)
+
+	voices, err := voiceManager.ListVoices(c.Request().Context(), claims.UserID)
+	if err != nil {
+		logger.Error("Failed to list voices",
+			zap.String("user_id", claims.UserID),
+			zap.Error(err))
+		return c.JSON(http.StatusBadGateway, ErrorResponse{
+			Error:   "voices_unavailable",
+			Message: "Failed to list voices",
+		})
+	}
+
+	return c.JSON(http.StatusOK, VoicesResponse{Voices: voices})
+}
+
+// refreshVoices lists the voices of the provider again, for when voices were
+// added or deleted outside the server and cannot wait for the cache to expire
+func refreshVoices(c echo.Context, voiceManager repositories.VoiceManager, logger *zap.Logger) error {
+	count, err := voiceManager.RefreshVoices(c.Request().Context())
+	if err != nil {
+		logger.Error("Failed to refresh voices", zap.Error(err))
+		return c.JSON(http.StatusBadGateway, ErrorResponse{
+			Error:   "voices_unavailable",
+			Message: "Failed to refresh voices",
+		})
+	}
+
+	logger.Info("Voices refreshed",
+		zap.String("admin_id", claimsFromContext(c).UserID),
+		zap.Int("voices", count))
+	return c.JSON(http.StatusOK, VoicesRefreshResponse{Voices: count})
+}
+
+// createVoice clones a custom voice from uploaded samples
+func createVoice(c echo.Context, voiceManager repositories.VoiceManager, logger *zap.Logger) error {
+	claims := claimsFromContext(c)
+
+	name := c.FormValue("name")
+	form, err := c.MultipartForm()
+	if err != nil || name == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "A name and at least one sample file are required",
+		})
+	}
+
+	files := form.File["files"]
+	if len(files) == 0 || len(files) > maxVoiceSamples {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_samples",
+			Message: "Upload between 1 and 25 sample files",
+		})
+	}
+
+	samples := make([]repositories.VoiceSample, 0, len(files))
+	for _, file := range files {
+		if file.Size > maxVoiceSampleBytes {
+			return c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{
+				Error:   "sample_too_large",
+				Message: "Each sample must be at most 10 MB",
+			})
+		}
+		src, err := file.Open()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_samples",
+				Message: "Failed to read sample file",
+			})
+		}
+		data, err := io.ReadAll(io.LimitReader(src, maxVoiceSampleBytes))
+		src.Close()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_samples",
+				Message: "Failed to read sample file",
+			})
+		}
+		samples = append(samples, repositories.VoiceSample{Filename: file.Filename, Data: data})
+	}
+
+	voice, err := voiceManager.CreateVoice(c.Request().Context(), claims.UserID, name, samples)
+	if errors.Is(err, repositories.ErrVoiceLimitReached) {
+		return c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "voice_limit_reached",
+			Message: "The account already has the maximum number of custom voices",
+		})
+	}
+	if err != nil {
+		logger.Error("Failed to create voice",
+			zap.String("user_id", claims.UserID),
+			zap.Error(err))
+		return c.JSON(http.StatusBadGateway, ErrorResponse{
+			Error:   "voice_creation_failed",
+			Message: "Failed to create voice",
+		})
+	}
+
+	return c.JSON(http.StatusCreated, voice)
+}
+
+// assignChildVoice makes the doll speak with one of the caller's voices. A
+// voice that does not speak the child's language is flagged or refused
+// depending on policy.
+func assignChildVoice(c echo.Context, childRepo repositories.ChildRepository, voiceManager repositories.VoiceManager, policy string, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	var req AssignVoiceRequest
+	if err := c.Bind(&req); err != nil || req.VoiceID == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Voice ID is required",
+		})
+	}
+
+	// Only voices visible to the owner may be assigned
+	voices, err := voiceManager.ListVoices(c.Request().Context(), child.OwnerID)
+	if err != nil {
+		logger.Error("Failed to list voices",
+			zap.String("child_id", child.ID),
+			zap.Error(err))
+		return c.JSON(http.StatusBadGateway, ErrorResponse{
+			Error:   "voices_unavailable",
+			Message: "Failed to validate voice",
+		})
+	}
+	var assigned *entities.Voice
+	for _, voice := range voices {
+		if voice.ID == req.VoiceID {
+			assigned = &voice
+			break
+		}
+	}
+	if assigned == nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "voice_not_found",
+			Message: "Voice does not exist or is not available to this account",
+		})
+	}
+
+	warning := ""
+	if policy != VoiceLanguageOff && !assigned.SupportsLanguage(childLanguage(child)) {
+		logger.Info("Child voice does not speak the child's language",
+			zap.String("child_id", child.ID),
+			zap.String("voice_id", assigned.ID),
+			zap.String("language", childLanguage(child)),
+			zap.String("policy", policy))
+		if policy == VoiceLanguageBlock {
+			return c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+				Error:   voiceLanguageMismatch,
+				Message: "Voice does not speak the child's language",
+			})
+		}
+		warning = voiceLanguageMismatch
+	}
+
+	child.VoiceID = req.VoiceID
+	return updateChildVoice(c, childRepo, child, warning, logger)
+}
+
+// resetChildVoice makes the doll speak with the default voice again
+func resetChildVoice(c echo.Context, childRepo repositories.ChildRepository, logger *zap.Logger) error {
+	child, ok, err := ownedChild(c, childRepo, logger)
+	if !ok {
+		return err
+	}
+
+	child.VoiceID = ""
+	return updateChildVoice(c, childRepo, child, "", logger)
+}
+
+func updateChildVoice(c echo.Context, childRepo repositories.ChildRepository, child *entities.Child, warning string, logger *zap.Logger) error {
+	// A new choice corrects a profile flagged for a deleted voice
+	child.VoiceInvalid = false
+	if err := childRepo.Update(c.Request().Context(), child); err != nil {
+		logger.Error("Failed to update child voice",
+			zap.String("child_id", child.ID),
+			zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "update_failed",
+			Message: "Failed to update child voice",
+		})
+	}
+
+	logger.Info("Child voice updated",
+		zap.String("child_id", child.ID),
+		zap.String("voice_id", child.VoiceID))
+
+	return c.JSON(http.StatusOK, ChildVoiceResponse{Child: child, VoiceWarning: warning})
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/adapters/tts"
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// newElevenLabsVoicesServer mocks the Eleven Labs voices endpoints with a
+// premade voice, a clone of owner-1 and a clone of another account
+func newElevenLabsVoicesServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /voices", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"voices": [
+			{"voice_id": "premade-1", "name": "Rachel", "category": "premade", "labels": {"language": "en"}},
+			{"voice_id": "clone-mama", "name": "Mama", "category": "cloned", "labels": {"arunika_owner": "owner-1"}, "verified_languages": [{"language": "id"}]},
+			{"voice_id": "clone-other", "name": "Papa", "category": "cloned", "labels": {"arunika_owner": "owner-2"}}
+		]}`))
+	})
+	mux.HandleFunc("POST /voices/add", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"voice_id": "clone-new"}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+type voiceFixture struct {
+	echo     *echo.Echo
+	child    *entities.Child
+	children *adapters.MemoryChildRepository
+}
+
+func newVoiceFixture(t *testing.T, maxCustomVoices int, policy string) *voiceFixture {
+	t.Helper()
+	logger := zaptest.NewLogger(t)
+
+	server := newElevenLabsVoicesServer()
+	t.Cleanup(server.Close)
+	voiceManager, err := tts.NewElevenLabsTTS(tts.ElevenLabsConfig{APIKey: "key", APIBaseURL: server.URL, MaxCustomVoices: maxCustomVoices}, logger)
+	if err != nil {
+		t.Fatalf("Failed to create voice manager: %v", err)
+	}
+
+	f := &voiceFixture{echo: echo.New(), children: adapters.NewMemoryChildRepository()}
+	f.child = &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}}
+	if err := f.children.Create(context.Background(), f.child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+
+	f.echo.PUT("/api/v1/children/:id/voice", func(c echo.Context) error {
+		return assignChildVoice(c, f.children, voiceManager, policy, logger)
+	}, requireRole("user", logger))
+	f.echo.PUT("/api/v1/children/:id/language", func(c echo.Context) error {
+		return putChildLanguage(c, f.children, voiceManager, policy, logger)
+	}, requireRole("user", logger))
+	f.echo.POST("/api/v1/admin/voices/refresh", func(c echo.Context) error {
+		return refreshVoices(c, voiceManager, logger)
+	}, requireRole("admin", logger))
+	f.echo.POST("/api/v1/voices", func(c echo.Context) error {
+		return createVoice(c, voiceManager, logger)
+	}, requireRole("user", logger))
+
+	return f
+}
+
+func (f *voiceFixture) assign(t *testing.T, token, voiceID string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/children/"+f.child.ID+"/voice", strings.NewReader(`{"voice_id":"`+voiceID+`"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	f.echo.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAssignChildVoice_ValidatesVoice(t *testing.T) {
+	f := newVoiceFixture(t, 0, VoiceLanguageOff)
+	token := userToken(t, "owner-1")
+
+	rec := f.assign(t, token, "clone-mama")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	child, _ := f.children.GetByID(context.Background(), f.child.ID)
+	if child.VoiceID != "clone-mama" {
+		t.Errorf("Expected the custom voice stored on the child, got %q", child.VoiceID)
+	}
+
+	for _, voiceID := range []string{"does-not-exist", "clone-other"} {
+		if rec := f.assign(t, token, voiceID); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 assigning %q, got %d", voiceID, rec.Code)
+		}
+	}
+	child, _ = f.children.GetByID(context.Background(), f.child.ID)
+	if child.VoiceID != "clone-mama" {
+		t.Errorf("Expected rejected voices to leave the child unchanged, got %q", child.VoiceID)
+	}
+
+	if rec := f.assign(t, userToken(t, "owner-2"), "clone-other"); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-owner, got %d", rec.Code)
+	}
+}
+
+// putLanguage locks the doll of the fixture child to language
+func (f *voiceFixture) putLanguage(t *testing.T, token, language string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/children/"+f.child.ID+"/language", strings.NewReader(`{"language":"`+language+`"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	f.echo.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAssignChildVoice_WarnsAboutVoiceLanguage(t *testing.T) {
+	f := newVoiceFixture(t, 0, VoiceLanguageWarn)
+	f.child.ForcedLanguage = "id-ID"
+	f.children.Update(context.Background(), f.child)
+	token := userToken(t, "owner-1")
+
+	var resp ChildVoiceResponse
+	rec := f.assign(t, token, "clone-mama")
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusOK || resp.VoiceWarning != "" || resp.VoiceID != "clone-mama" {
+		t.Fatalf("Expected the voice speaking Indonesian assigned without warning, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	resp = ChildVoiceResponse{}
+	rec = f.assign(t, token, "premade-1")
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusOK || resp.VoiceWarning != voiceLanguageMismatch {
+		t.Fatalf("Expected the English voice assigned with a warning, got %d: %s", rec.Code, rec.Body.String())
+	}
+	child, _ := f.children.GetByID(context.Background(), f.child.ID)
+	if child.VoiceID != "premade-1" {
+		t.Errorf("Expected the warned voice stored, got %q", child.VoiceID)
+	}
+}
+
+func TestAssignChildVoice_BlocksVoiceNotSpeakingTheLanguage(t *testing.T) {
+	f := newVoiceFixture(t, 0, VoiceLanguageBlock)
+	f.child.Language = "id"
+	f.children.Update(context.Background(), f.child)
+	token := userToken(t, "owner-1")
+
+	rec := f.assign(t, token, "premade-1")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Error != voiceLanguageMismatch {
+		t.Errorf("Expected %s, got %q", voiceLanguageMismatch, resp.Error)
+	}
+	child, _ := f.children.GetByID(context.Background(), f.child.ID)
+	if child.VoiceID != "" {
+		t.Errorf("Expected the refused voice not stored, got %q", child.VoiceID)
+	}
+}
+
+func TestPutChildLanguage_ChecksTheChildVoice(t *testing.T) {
+	f := newVoiceFixture(t, 0, VoiceLanguageBlock)
+	f.child.VoiceID = "premade-1"
+	f.children.Update(context.Background(), f.child)
+	token := userToken(t, "owner-1")
+
+	if rec := f.putLanguage(t, token, "id-ID"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected a language the voice does not speak refused, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := f.putLanguage(t, token, "en-US"); rec.Code != http.StatusOK {
+		t.Errorf("Expected a language the voice speaks accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+	child, _ := f.children.GetByID(context.Background(), f.child.ID)
+	if child.ForcedLanguage != "en-US" {
+		t.Errorf("Expected en-US stored, got %q", child.ForcedLanguage)
+	}
+}
+
+func TestCreateVoice_RespectsAccountLimit(t *testing.T) {
+	f := newVoiceFixture(t, 1, VoiceLanguageOff)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("name", "Nenek")
+	part, _ := writer.CreateFormFile("files", "nenek.wav")
+	part.Write([]byte{0x01, 0x02})
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/voices", &body)
+	req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+userToken(t, "owner-1"))
+	rec := httptest.NewRecorder()
+	f.echo.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409 once the account owns its limit, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Error != "voice_limit_reached" {
+		t.Errorf("Expected voice_limit_reached, got %q", resp.Error)
+	}
+}
+
+func TestRefreshVoices_RequiresAdmin(t *testing.T) {
+	f := newVoiceFixture(t, 0, VoiceLanguageOff)
+
+	refresh := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/voices/refresh", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		f.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := refresh(userToken(t, "owner-1")); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a parent, got %d", rec.Code)
+	}
+	rec := refresh(adminToken(t))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp VoicesRefreshResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Voices != 3 {
+		t.Errorf("Expected 3 voices refreshed, got %d", resp.Voices)
+	}
+}
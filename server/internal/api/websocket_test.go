@@ -0,0 +1,201 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	gorillaws "github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/internal/auth"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+	"github.com/satriahrh/arunika/server/internal/websocket"
+)
+
+// newWebSocketHub runs a hub over fake speech services
+func newWebSocketHub(t *testing.T, config websocket.HubConfig) *websocket.Hub {
+	t.Helper()
+	// The hub outlives the test's logger, so log nowhere
+	logger := zap.NewNop()
+	engine, err := conversation.NewEngine(conversation.Config{}, &conversationtest.LLM{}, &conversationtest.TTS{}, &conversationtest.STT{}, &conversationtest.SessionRepository{}, adapters.NewMemoryChildRepository(), adapters.NewMemoryQuietHoursRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub, err := websocket.NewHub(config, engine, adapters.NewMemoryDeviceRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go hub.Run()
+	return hub
+}
+
+// newWebSocketEcho serves /ws with a hub over fake speech services
+func newWebSocketEcho(t *testing.T) *echo.Echo {
+	t.Helper()
+	return serveWebSocket(newWebSocketHub(t, websocket.HubConfig{}))
+}
+
+// serveWebSocket serves /ws with hub
+func serveWebSocket(hub *websocket.Hub) *echo.Echo {
+	e := echo.New()
+	e.GET("/ws", func(c echo.Context) error {
+		return websocketWithAuth(hub, c, zap.NewNop())
+	})
+	return e
+}
+
+func TestWebSocketWithAuth_RefusesUnauthenticatedUpgrade(t *testing.T) {
+	e := newWebSocketEcho(t)
+	server := httptest.NewServer(e)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	tests := []struct {
+		name   string
+		query  string
+		header http.Header
+	}{
+		{"no token", "", nil},
+		{"device ID in the query only", "?device_id=device-1", nil},
+		{"invalid token", "", http.Header{"Authorization": {"Bearer not-a-token"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, resp, err := gorillaws.DefaultDialer.Dial(url+tt.query, tt.header)
+			if err == nil {
+				conn.Close()
+				t.Fatal("Expected the upgrade to be refused")
+			}
+			if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("Expected status 401, got %v (%v)", resp, err)
+			}
+		})
+	}
+}
+
+func TestWebSocketWithAuth_TellsTokenFailuresApart(t *testing.T) {
+	e := newWebSocketEcho(t)
+	signed := func(expiresAt time.Time) string {
+		t.Helper()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &auth.JWTClaims{
+			DeviceID:         "device-1",
+			Role:             "device",
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(expiresAt)},
+		}).SignedString(auth.JWTSecret)
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return token
+	}
+	// The header and claims of a valid token with another signature
+	parts := strings.Split(signed(time.Now().Add(time.Hour)), ".")
+	tampered := parts[0] + "." + parts[1] + ".c2lnbmVkIGJ5IHNvbWVvbmUgZWxzZQ"
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"expired", signed(time.Now().Add(-time.Hour)), "token_expired"},
+		{"tampered signature", tampered, "token_invalid_signature"},
+		{"garbage", "not-a-token", "token_malformed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			var response ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response %q: %v", rec.Body.String(), err)
+			}
+			if rec.Code != http.StatusUnauthorized || response.Error != tt.want {
+				t.Errorf("Expected 401 %s, got %d %s", tt.want, rec.Code, response.Error)
+			}
+		})
+	}
+}
+
+// healthChecker is a dependency whose health the test sets
+type healthChecker struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (h *healthChecker) CheckHealth(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+func (h *healthChecker) set(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.err = err
+}
+
+// waitReady waits until hub.Ready() reports ready
+func waitReady(t *testing.T, hub *websocket.Hub, ready bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.Ready() != ready {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the hub to become ready=%v", ready)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebSocketWithAuth_RefusesConnectionsUntilReady(t *testing.T) {
+	hub := newWebSocketHub(t, websocket.HubConfig{RetryAfter: 2 * time.Second, ReadinessInterval: 10 * time.Millisecond, ReadinessFailures: 2})
+	gemini := &healthChecker{err: errors.New("connection refused")}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hub.MonitorReadiness(ctx, []websocket.Dependency{{Name: "gemini", Checker: gemini}})
+
+	server := httptest.NewServer(serveWebSocket(hub))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	token, _ := auth.GenerateDeviceToken("device-1")
+	header := http.Header{"Authorization": {"Bearer " + token}}
+
+	assertRefused := func(when string) {
+		t.Helper()
+		conn, resp, err := gorillaws.DefaultDialer.Dial(url, header)
+		if err == nil {
+			conn.Close()
+			t.Fatalf("Expected the upgrade to be refused %s", when)
+		}
+		if resp == nil || resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "2" {
+			t.Fatalf("Expected status 503 with Retry-After %s, got %v (%v)", when, resp, err)
+		}
+	}
+
+	assertRefused("before the dependencies are healthy")
+
+	gemini.set(nil)
+	waitReady(t, hub, true)
+	conn, _, err := gorillaws.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("Expected the upgrade to be accepted once healthy, got %v", err)
+	}
+	conn.Close()
+
+	gemini.set(errors.New("connection refused"))
+	waitReady(t, hub, false)
+	assertRefused("after sustained dependency failures")
+}
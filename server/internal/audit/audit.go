@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Entry describes a single privacy or security relevant action.
+// Entries must never carry the content being acted upon.
+type Entry struct {
+	Action    string                 `json:"action"`
+	ActorID   string                 `json:"actor_id"`
+	TargetID  string                 `json:"target_id"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// Trail records audit entries
+type Trail interface {
+	Record(ctx context.Context, entry Entry)
+}
+
+// ZapTrail writes audit entries to a dedicated named logger
+type ZapTrail struct {
+	logger *zap.Logger
+}
+
+// Ensure ZapTrail implements the Trail interface
+var _ Trail = (*ZapTrail)(nil)
+
+// NewZapTrail creates a new audit trail backed by the given logger
+func NewZapTrail(logger *zap.Logger) *ZapTrail {
+	return &ZapTrail{
+		logger: logger.Named("audit"),
+	}
+}
+
+// Record implements Trail
+func (t *ZapTrail) Record(ctx context.Context, entry Entry) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	t.logger.Info("Audit entry",
+		zap.String("action", entry.Action),
+		zap.String("actor_id", entry.ActorID),
+		zap.String("target_id", entry.TargetID),
+		zap.Any("details", entry.Details),
+		zap.Time("timestamp", entry.Timestamp))
+}
@@ -4,13 +4,14 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 // JWTClaims represents the claims in our JWT token
 type JWTClaims struct {
 	DeviceID string `json:"device_id"`
 	UserID   string `json:"user_id,omitempty"`
-	Role     string `json:"role"` // "device" or "user"
+	Role     string `json:"role"` // "device", "user" or "admin"
 	jwt.RegisteredClaims
 }
 
@@ -23,6 +24,8 @@ func GenerateDeviceToken(deviceID string) (string, error) {
 		DeviceID: deviceID,
 		Role:     "device",
 		RegisteredClaims: jwt.RegisteredClaims{
+			// Tells apart the tokens of a device issued within a second
+			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
@@ -47,6 +50,21 @@ func GenerateUserToken(userID string) (string, error) {
 	return token.SignedString(JWTSecret)
 }
 
+// GenerateAdminToken generates a JWT token for operators of the admin APIs
+func GenerateAdminToken(userID string) (string, error) {
+	claims := &JWTClaims{
+		UserID: userID,
+		Role:   "admin",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(JWTSecret)
+}
+
 // ValidateToken validates a JWT token and returns the claims
 func ValidateToken(tokenString string) (*JWTClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+var (
+	// ErrClientCertificateMissing is returned when a request carries no verified client certificate
+	ErrClientCertificateMissing = errors.New("client certificate missing")
+	// ErrClientCertificateMismatch is returned when the client certificate names another device
+	ErrClientCertificateMismatch = errors.New("client certificate does not match device")
+)
+
+// MTLSConfig holds configuration for serving TLS with client certificates
+// Required fields when Required is set:
+// - ClientCAFile: PEM bundle of the CAs that sign device certificates
+// - CertFile: PEM certificate of the server
+// - KeyFile: PEM private key of the server
+type MTLSConfig struct {
+	Required     bool   // Optional: Serve TLS and require a verified client certificate from every connection
+	ClientCAFile string // Required with Required: CAs that sign device certificates
+	CertFile     string // Required with Required: Certificate of the server
+	KeyFile      string // Required with Required: Private key of the server
+}
+
+// NewMTLSConfigFromEnv creates a new MTLSConfig from environment variables
+// This is a helper function to simplify the creation of a properly configured MTLSConfig
+func NewMTLSConfigFromEnv() MTLSConfig {
+	config := MTLSConfig{
+		ClientCAFile: os.Getenv("MTLS_CLIENT_CA_FILE"),
+		CertFile:     os.Getenv("TLS_CERT_FILE"),
+		KeyFile:      os.Getenv("TLS_KEY_FILE"),
+	}
+
+	if requiredStr := os.Getenv("REQUIRE_MTLS"); requiredStr != "" {
+		if required, err := strconv.ParseBool(requiredStr); err == nil {
+			config.Required = required
+		}
+	}
+
+	return config
+}
+
+// TLSConfig builds the server TLS configuration, which rejects connections
+// without a client certificate signed by one of the client CAs
+func (c MTLSConfig) TLSConfig() (*tls.Config, error) {
+	if c.ClientCAFile == "" || c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("mTLS requires MTLS_CLIENT_CA_FILE, TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+
+	caPEM, err := os.ReadFile(c.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", c.ClientCAFile)
+	}
+
+	certificate, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// DeviceIDFromCertificate returns the device identity of a verified client
+// certificate, the common name of its subject
+func DeviceIDFromCertificate(state *tls.ConnectionState) (string, error) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", ErrClientCertificateMissing
+	}
+	return state.VerifiedChains[0][0].Subject.CommonName, nil
+}
+
+// VerifyDeviceCertificate checks that the connection's client certificate
+// belongs to deviceID. Connections without TLS are not checked, so that
+// deployments terminating TLS elsewhere keep working.
+func VerifyDeviceCertificate(state *tls.ConnectionState, deviceID string) error {
+	if state == nil {
+		return nil
+	}
+	certDeviceID, err := DeviceIDFromCertificate(state)
+	if err != nil {
+		return err
+	}
+	if certDeviceID != deviceID {
+		return fmt.Errorf("%w: certificate is for %q", ErrClientCertificateMismatch, certDeviceID)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package cleanup
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultInterval = time.Minute
+	defaultTimeout  = 30 * time.Second
+)
+
+// SessionCleanupConfig holds configuration for the SessionCleanupService
+// Optional fields with defaults:
+// - Interval: How often idle sessions are expired (default: 1m)
+// - Timeout: Timeout of a single cleanup run (default: 30s)
+type SessionCleanupConfig struct {
+	Interval time.Duration // Optional: How often idle sessions are expired
+	Timeout  time.Duration // Optional: Timeout of a single cleanup run
+}
+
+// NewSessionCleanupConfigFromEnv creates a new SessionCleanupConfig from environment variables
+// This is a helper function to simplify the creation of a properly configured SessionCleanupConfig
+func NewSessionCleanupConfigFromEnv() SessionCleanupConfig {
+	config := SessionCleanupConfig{}
+
+	if intervalStr := os.Getenv("SESSION_CLEANUP_INTERVAL_SECONDS"); intervalStr != "" {
+		if interval, err := strconv.Atoi(intervalStr); err == nil && interval > 0 {
+			config.Interval = time.Duration(interval) * time.Second
+		}
+	}
+
+	return config
+}
@@ -0,0 +1,122 @@
+// Package cleanup runs background maintenance of stored conversations.
+package cleanup
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// SessionCleanupService periodically marks sessions that went idle for longer
+// than entities.SessionIdleTimeout as ended
+type SessionCleanupService struct {
+	interval time.Duration
+	timeout  time.Duration
+	repo     repositories.SessionRepository
+	logger   *zap.Logger
+
+	// Clock used to compute the expiry cutoff, replaced in tests
+	now func() time.Time
+
+	mu   sync.Mutex
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// NewSessionCleanupService creates a new, stopped session cleanup service
+func NewSessionCleanupService(config SessionCleanupConfig, repo repositories.SessionRepository, logger *zap.Logger) *SessionCleanupService {
+	logger = logger.Named("session_cleanup")
+
+	// Apply defaults where needed
+	if config.Interval == 0 {
+		config.Interval = defaultInterval
+		logger.Info("Using default cleanup interval", zap.Duration("interval", config.Interval))
+	}
+
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+		logger.Info("Using default cleanup timeout", zap.Duration("timeout", config.Timeout))
+	}
+
+	return &SessionCleanupService{
+		interval: config.Interval,
+		timeout:  config.Timeout,
+		repo:     repo,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+// Start runs a cleanup immediately and then every interval until Stop is
+// called. Starting a running service does nothing; a stopped service can be
+// started again.
+func (s *SessionCleanupService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stop = cancel
+	s.done = make(chan struct{})
+	go s.run(ctx, s.done)
+
+	s.logger.Info("Session cleanup started", zap.Duration("interval", s.interval))
+}
+
+// Stop cancels a running cleanup and waits for the service to exit
+func (s *SessionCleanupService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == nil {
+		return
+	}
+
+	s.stop()
+	<-s.done
+	s.stop = nil
+	s.done = nil
+
+	s.logger.Info("Session cleanup stopped")
+}
+
+func (s *SessionCleanupService) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.cleanup(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cleanup expires every session idle since before the timeout
+func (s *SessionCleanupService) cleanup(ctx context.Context) {
+	runCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	cutoff := s.now().Add(-entities.SessionIdleTimeout)
+	expired, err := s.repo.ExpireSessions(runCtx, cutoff)
+	if err != nil {
+		// Errors caused by Stop are expected
+		if ctx.Err() == nil {
+			s.logger.Error("Failed to expire idle sessions", zap.Error(err))
+		}
+		return
+	}
+	if expired > 0 {
+		s.logger.Info("Expired idle sessions", zap.Int64("expired", expired))
+	}
+}
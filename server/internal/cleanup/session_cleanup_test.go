@@ -0,0 +1,89 @@
+package cleanup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// signalingRepository reports every ExpireSessions run
+type signalingRepository struct {
+	*conversationtest.SessionRepository
+	cutoffs chan time.Time
+}
+
+func (r *signalingRepository) ExpireSessions(ctx context.Context, cutoff time.Time) (int64, error) {
+	expired, err := r.SessionRepository.ExpireSessions(ctx, cutoff)
+	r.cutoffs <- cutoff
+	return expired, err
+}
+
+func waitForCleanup(t *testing.T, cutoffs <-chan time.Time) time.Time {
+	t.Helper()
+	select {
+	case cutoff := <-cutoffs:
+		return cutoff
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for a cleanup run")
+		return time.Time{}
+	}
+}
+
+func TestSessionCleanupService_ExpiresIdleSessions(t *testing.T) {
+	now := time.Now()
+	repo := &signalingRepository{
+		SessionRepository: &conversationtest.SessionRepository{},
+		cutoffs:           make(chan time.Time, 1),
+	}
+	idle := &entities.Session{DeviceID: "device-1", LastMessageAt: now.Add(-time.Hour)}
+	active := &entities.Session{DeviceID: "device-2", LastMessageAt: now.Add(-time.Minute)}
+	repo.Create(context.Background(), idle)
+	repo.Create(context.Background(), active)
+
+	service := NewSessionCleanupService(SessionCleanupConfig{Interval: time.Hour}, repo, zaptest.NewLogger(t))
+	service.now = func() time.Time { return now }
+
+	service.Start()
+	cutoff := waitForCleanup(t, repo.cutoffs)
+	service.Stop()
+
+	if want := now.Add(-entities.SessionIdleTimeout); !cutoff.Equal(want) {
+		t.Errorf("Expected cutoff %v, got %v", want, cutoff)
+	}
+	sessions := repo.Sessions()
+	if sessions[0].ID != idle.ID || sessions[0].EndedAt.IsZero() {
+		t.Error("Expected the idle session to be ended")
+	}
+	if sessions[1].ID != active.ID || !sessions[1].EndedAt.IsZero() {
+		t.Error("Expected the active session to stay open")
+	}
+}
+
+func TestSessionCleanupService_Restart(t *testing.T) {
+	repo := &signalingRepository{
+		SessionRepository: &conversationtest.SessionRepository{},
+		cutoffs:           make(chan time.Time, 1),
+	}
+	service := NewSessionCleanupService(SessionCleanupConfig{Interval: time.Hour}, repo, zaptest.NewLogger(t))
+
+	service.Start()
+	service.Start() // already running
+	waitForCleanup(t, repo.cutoffs)
+	service.Stop()
+	service.Stop() // already stopped
+
+	service.Start()
+	waitForCleanup(t, repo.cutoffs)
+	service.Stop()
+
+	select {
+	case <-repo.cutoffs:
+		t.Error("Expected no cleanup run after Stop")
+	default:
+	}
+}
@@ -0,0 +1,70 @@
+package conversation
+
+import (
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+// minAdaptiveSampleRate is the lowest output rate preferred on a poor link,
+// below it speech loses too much to be understood by a child
+const minAdaptiveSampleRate = 16000
+
+// lowBandwidthRate returns the output rate of poor links among the declared
+// rates text-to-speech can produce: the lowest of at least
+// minAdaptiveSampleRate, or the lowest one when the device plays none of
+// those. It is negotiated when the device plays no lower rate, and zero when
+// the device declared no rates.
+func lowBandwidthRate(declared []int, negotiated int) int {
+	if len(declared) == 0 {
+		return 0
+	}
+	playable := make(map[int]bool, len(declared))
+	for _, rate := range declared {
+		playable[rate] = true
+	}
+	var lower []int
+	for _, rate := range outputSampleRates {
+		if playable[rate] && rate < negotiated {
+			lower = append(lower, rate)
+		}
+	}
+	if len(lower) == 0 {
+		return negotiated
+	}
+	sort.Ints(lower)
+	for _, rate := range lower {
+		if rate >= minAdaptiveSampleRate {
+			return rate
+		}
+	}
+	return lower[0]
+}
+
+// AdaptOutputSampleRate switches the output rate of the conversation to the
+// link of the device: the lowest suitable rate it declared when poor is set,
+// the negotiated rate otherwise. The rate applies from the next response, a
+// response in progress keeps its own. It returns the output rate and whether
+// it changed; it never changes for devices that declared no playable rates.
+func (c *Conversation) AdaptOutputSampleRate(poor bool) (int, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.audio == nil || c.lowOutputRate == 0 {
+		return c.outputSampleRate(), false
+	}
+
+	rate := c.negotiatedOutputRate
+	if poor {
+		rate = c.lowOutputRate
+	}
+	if rate == c.audio.OutputSampleRate {
+		return rate, false
+	}
+	c.logger.Info("Adapting output sample rate to the link",
+		zap.String("deviceID", c.deviceID),
+		zap.Bool("poorLink", poor),
+		zap.Int("previousOutputSampleRate", c.audio.OutputSampleRate),
+		zap.Int("outputSampleRate", rate))
+	c.audio.OutputSampleRate = rate
+	return rate, true
+}
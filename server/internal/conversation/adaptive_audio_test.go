@@ -0,0 +1,71 @@
+package conversation
+
+import (
+	"testing"
+
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestLowBandwidthRate(t *testing.T) {
+	tests := []struct {
+		name       string
+		declared   []int
+		negotiated int
+		want       int
+	}{
+		{"nothing declared", nil, 24000, 0},
+		{"lowest clear rate", []int{8000, 16000, 22050, 24000}, 24000, 16000},
+		{"only rates below clear", []int{8000, 24000}, 24000, 8000},
+		{"nothing lower", []int{16000, 96000}, 16000, 16000},
+		{"unsupported rates are skipped", []int{11025, 22050, 48000}, 48000, 22050},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lowBandwidthRate(tt.declared, tt.negotiated); got != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEngine_AdaptsOutputSampleRateBetweenResponses(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	if _, err := conv.SetAudioCapabilities(AudioCapabilities{OutputSampleRates: []int{16000, 24000}}); err != nil {
+		t.Fatalf("Failed to negotiate audio: %v", err)
+	}
+
+	if rate, changed := conv.AdaptOutputSampleRate(true); rate != 16000 || !changed {
+		t.Fatalf("Expected 16000 Hz on a poor link, got %d changed %v", rate, changed)
+	}
+	if _, changed := conv.AdaptOutputSampleRate(true); changed {
+		t.Error("Expected no change while the link stays poor")
+	}
+	speak(t, conv, sink)
+	if event := sink.next(t, EventSpeakingStart); event.OutputSampleRate != 16000 {
+		t.Errorf("Expected the response announced at 16000 Hz, got %d", event.OutputSampleRate)
+	}
+	sink.until(t, EventSpeakingEnd)
+
+	if rate, changed := conv.AdaptOutputSampleRate(false); rate != 24000 || !changed {
+		t.Fatalf("Expected 24000 Hz on a good link, got %d changed %v", rate, changed)
+	}
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	conv.Close()
+
+	if rates := f.tts.SampleRates(); len(rates) != 2 || rates[0] != 16000 || rates[1] != 24000 {
+		t.Errorf("Expected the responses synthesized at 16000 then 24000 Hz, got %v", rates)
+	}
+}
+
+func TestEngine_KeepsOutputSampleRateWithoutDeclaredRates(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	conv := f.engine.NewConversation("device-1", newRecorder())
+	defer conv.Close()
+
+	if rate, changed := conv.AdaptOutputSampleRate(true); rate != 24000 || changed {
+		t.Errorf("Expected the default 24000 Hz kept, got %d changed %v", rate, changed)
+	}
+}
@@ -0,0 +1,213 @@
+package conversation
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+var (
+	// bufferedAudioBytes is the audio received from devices and not yet
+	// accepted by speech-to-text, across all connections
+	bufferedAudioBytes = expvar.NewInt("conversation_buffered_audio_bytes")
+	// droppedAudioBytes counts the audio dropped to stay within the budget
+	droppedAudioBytes = expvar.NewInt("conversation_dropped_audio_bytes")
+)
+
+// ErrorAudioBufferExceeded reports that more audio arrived than speech-to-text
+// accepted within the audio budget of the connection. The listening session is
+// abandoned; transports should disconnect the device.
+const ErrorAudioBufferExceeded = "audio_buffer_exceeded"
+
+// errAudioBufferExceeded is returned by Stream when overflowing the budget
+// disconnects the device
+var errAudioBufferExceeded = errors.New("audio buffer exceeded")
+
+// bufferedSTT queues the audio of a listening session in front of the
+// speech-to-text stream, so that a stalled stream never blocks the device
+// connection. The queued audio is bounded by max bytes: beyond it the oldest
+// queued audio is dropped, or Stream fails when overflow disconnects.
+type bufferedSTT struct {
+	stream     repositories.SpeechToTextStreaming
+	max        int
+	disconnect bool
+	logger     *zap.Logger
+
+	mu      sync.Mutex
+	wake    *sync.Cond
+	chunks  [][]byte
+	bytes   int
+	dropped int
+	closed  bool
+	err     error
+	done    chan struct{}
+}
+
+func newBufferedSTT(stream repositories.SpeechToTextStreaming, max int, disconnect bool, logger *zap.Logger) *bufferedSTT {
+	b := &bufferedSTT{
+		stream:     stream,
+		max:        max,
+		disconnect: disconnect,
+		logger:     logger,
+		done:       make(chan struct{}),
+	}
+	b.wake = sync.NewCond(&b.mu)
+	go b.drain()
+	return b
+}
+
+// Stream implements repositories.SpeechToTextStreaming by queueing data
+func (b *bufferedSTT) Stream(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	if b.closed {
+		return errors.New("audio stream already ended")
+	}
+
+	if b.bytes+len(data) > b.max {
+		if b.disconnect {
+			b.discardLocked()
+			b.err = errAudioBufferExceeded
+			return b.err
+		}
+		// Keep the most recent audio, which the transcription still needs
+		for len(b.chunks) > 0 && b.bytes+len(data) > b.max {
+			b.unqueueLocked()
+		}
+		// Not even data fits next to the chunk being streamed
+		if b.bytes+len(data) > b.max {
+			b.dropLocked(len(data))
+			return nil
+		}
+	}
+
+	b.chunks = append(b.chunks, data)
+	b.bytes += len(data)
+	bufferedAudioBytes.Add(int64(len(data)))
+	b.wake.Signal()
+	return nil
+}
+
+// End implements repositories.SpeechToTextStreaming once the queued audio
+// reached speech-to-text
+func (b *bufferedSTT) End() (string, error) {
+	b.mu.Lock()
+	b.closed = true
+	b.wake.Signal()
+	b.mu.Unlock()
+	<-b.done
+
+	b.mu.Lock()
+	dropped := b.dropped
+	b.mu.Unlock()
+	if dropped > 0 {
+		b.logger.Warn("Dropped audio beyond the audio buffer budget",
+			zap.Int("droppedBytes", dropped),
+			zap.Int("audioBufferMax", b.max))
+	}
+	return b.stream.End()
+}
+
+// Confidence implements repositories.TranscriptionConfidence for streams that
+// report the confidence of their transcription
+func (b *bufferedSTT) Confidence() (float64, bool) {
+	if reporter, ok := b.stream.(repositories.TranscriptionConfidence); ok {
+		return reporter.Confidence()
+	}
+	return 0, false
+}
+
+// Close implements repositories.SpeechToTextStreaming by discarding the
+// queued audio and closing the stream, once the drain stopped
+func (b *bufferedSTT) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	for _, chunk := range b.chunks {
+		b.bytes -= len(chunk)
+		bufferedAudioBytes.Add(-int64(len(chunk)))
+	}
+	b.chunks = nil
+	b.wake.Signal()
+	b.mu.Unlock()
+
+	// Closing the stream first unblocks a drain stuck streaming to it
+	err := b.stream.Close()
+	<-b.done
+	return err
+}
+
+// Buffered returns the bytes queued for speech-to-text
+func (b *bufferedSTT) Buffered() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bytes
+}
+
+// drain streams the queued audio in order until End
+func (b *bufferedSTT) drain() {
+	defer close(b.done)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for {
+		for len(b.chunks) == 0 && !b.closed {
+			b.wake.Wait()
+		}
+		if len(b.chunks) == 0 {
+			return
+		}
+		chunk := b.chunks[0]
+		b.chunks = b.chunks[1:]
+
+		// The chunk stays accounted for until speech-to-text accepted it
+		b.mu.Unlock()
+		err := b.stream.Stream(chunk)
+		b.mu.Lock()
+
+		b.bytes -= len(chunk)
+		bufferedAudioBytes.Add(-int64(len(chunk)))
+		if err != nil && b.err == nil {
+			b.err = err
+			b.discardLocked()
+		}
+	}
+}
+
+// unqueueLocked drops the oldest queued chunk. The caller must hold the mutex.
+func (b *bufferedSTT) unqueueLocked() {
+	n := len(b.chunks[0])
+	b.chunks = b.chunks[1:]
+	b.bytes -= n
+	bufferedAudioBytes.Add(-int64(n))
+	b.dropLocked(n)
+}
+
+// discardLocked drops all queued audio. The caller must hold the mutex.
+func (b *bufferedSTT) discardLocked() {
+	for len(b.chunks) > 0 {
+		b.unqueueLocked()
+	}
+	b.chunks = nil
+}
+
+// dropLocked records n bytes of audio as dropped, logging the first drop of
+// the session. The caller must hold the mutex.
+func (b *bufferedSTT) dropLocked(n int) {
+	if b.dropped == 0 {
+		b.logger.Warn("Audio buffer full, dropping audio",
+			zap.Int("bufferedBytes", b.bytes),
+			zap.Int("audioBufferMax", b.max))
+	}
+	b.dropped += n
+	droppedAudioBytes.Add(int64(n))
+}
@@ -0,0 +1,96 @@
+package conversation
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// flood streams count chunks of 100 bytes, the i-th filled with byte i, and
+// fails when streaming blocks
+func flood(t *testing.T, conv *Conversation, count int) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < count; i++ {
+			conv.StreamAudio(bytes.Repeat([]byte{byte(i)}, 100))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Streaming audio blocked on the stalled speech-to-text")
+	}
+}
+
+func TestEngine_AudioBufferDropsOldestWhileSTTStalls(t *testing.T) {
+	stall := make(chan struct{})
+	f := newEngineFixture(t, Config{AudioBufferMax: 1000}, &conversationtest.STT{Transcript: "halo", Stall: stall})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	conv.StartListening(StartOptions{})
+	sink.next(t, EventListeningStart)
+	dropped := droppedAudioBytes.Value()
+	flood(t, conv, 50)
+
+	conv.mutex.Lock()
+	buffered := conv.sttStreaming.(*bufferedSTT).Buffered()
+	conv.mutex.Unlock()
+	if buffered > 1000 {
+		t.Errorf("Expected at most 1000 buffered bytes, got %d", buffered)
+	}
+	if got := droppedAudioBytes.Value() - dropped; got < 4000 {
+		t.Errorf("Expected at least 4000 dropped bytes, got %d", got)
+	}
+
+	close(stall)
+	conv.EndListening()
+	sink.next(t, EventListeningEnd)
+
+	// The newest audio reaches speech-to-text, in order
+	chunks := f.stt.Streams()[0].Chunks()
+	if len(chunks) == 0 || len(chunks) > 10 {
+		t.Fatalf("Expected at most 10 chunks within the budget, got %d", len(chunks))
+	}
+	for i := 1; i < len(chunks); i++ {
+		if chunks[i][0] <= chunks[i-1][0] {
+			t.Fatalf("Expected the chunks in order, got %d after %d", chunks[i][0], chunks[i-1][0])
+		}
+	}
+	if last := chunks[len(chunks)-1][0]; last != 49 {
+		t.Errorf("Expected the last chunk to be kept, got chunk %d", last)
+	}
+}
+
+func TestEngine_AudioBufferOverflowDisconnects(t *testing.T) {
+	stall := make(chan struct{})
+	f := newEngineFixture(t, Config{AudioBufferMax: 1000, AudioBufferOverflowDisconnect: true},
+		&conversationtest.STT{Transcript: "halo", Stall: stall})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	conv.StartListening(StartOptions{})
+	sink.next(t, EventListeningStart)
+	flood(t, conv, 50)
+
+	if event := sink.next(t, EventError); event.Error != ErrorAudioBufferExceeded {
+		t.Errorf("Expected the audio buffer to be exceeded, got %q", event.Error)
+	}
+	if conv.Listening() {
+		t.Error("Expected the listening session to be abandoned")
+	}
+
+	// The abandoned stream is closed even though speech-to-text stalls
+	stream := f.stt.Streams()[0]
+	deadline := time.Now().Add(5 * time.Second)
+	for !stream.Released() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the abandoned stream to be closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
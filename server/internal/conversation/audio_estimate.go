@@ -0,0 +1,47 @@
+package conversation
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// speechCharactersPerSecond is roughly how many characters of text the doll
+// speaks per second, to estimate the audio of a response from its text
+const speechCharactersPerSecond = 14
+
+// AudioEstimate is the expected size of the audio of a response, so that the
+// device can tell how much to buffer before it starts playing
+type AudioEstimate struct {
+	Duration time.Duration
+	// Bytes of the 16-bit mono PCM audio at SampleRate
+	Bytes      int
+	SampleRate int
+	// Known is set when the audio was synthesized already, as for a cached
+	// response; otherwise it is estimated from the length of the text
+	Known bool
+}
+
+// knownAudio returns the estimate of audio synthesized already at sampleRate
+func knownAudio(audio [][]byte, sampleRate int) *AudioEstimate {
+	bytes := 0
+	for _, chunk := range audio {
+		bytes += len(chunk)
+	}
+	return &AudioEstimate{
+		Duration:   time.Duration(bytes/2) * time.Second / time.Duration(sampleRate),
+		Bytes:      bytes,
+		SampleRate: sampleRate,
+		Known:      true,
+	}
+}
+
+// estimateAudio returns the estimate of the audio text synthesizes to at
+// sampleRate, bounded by the audio budget of the response
+func estimateAudio(text string, sampleRate int, budget *audioBudget) *AudioEstimate {
+	duration := time.Duration(utf8.RuneCountInString(text)) * time.Second / speechCharactersPerSecond
+	if budget != nil && duration > budget.limit {
+		duration = budget.limit
+	}
+	samples := int(duration.Seconds() * float64(sampleRate))
+	return &AudioEstimate{Duration: duration, Bytes: 2 * samples, SampleRate: sampleRate}
+}
@@ -0,0 +1,27 @@
+package conversation
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimateAudio(t *testing.T) {
+	estimate := estimateAudio(strings.Repeat("a", 28), 16000, nil)
+	if estimate.Duration != 2*time.Second || estimate.Bytes != 64000 || estimate.Known {
+		t.Errorf("Expected two seconds of estimated audio, got %+v", estimate)
+	}
+
+	// A response is never longer than its audio cap
+	estimate = estimateAudio(strings.Repeat("a", 280), 16000, &audioBudget{limit: 5 * time.Second})
+	if estimate.Duration != 5*time.Second || estimate.Bytes != 160000 {
+		t.Errorf("Expected the estimate bounded by the audio cap, got %+v", estimate)
+	}
+}
+
+func TestKnownAudio(t *testing.T) {
+	estimate := knownAudio([][]byte{make([]byte, 16000), make([]byte, 16000)}, 16000)
+	if estimate.Duration != time.Second || estimate.Bytes != 32000 || !estimate.Known {
+		t.Errorf("Expected a second of known audio, got %+v", estimate)
+	}
+}
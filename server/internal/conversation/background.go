@@ -0,0 +1,75 @@
+package conversation
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// responseTimeout bounds a response, from the transcription to its last audio
+const responseTimeout = 60 * time.Second
+
+// A conversation speaks one response at a time. A response is generated and
+// synthesized in the background, so that the device can keep talking; when
+// the child finishes another utterance before the doll finished answering,
+// the response in progress is cancelled and the next one waits for it to
+// return. Close cancels the responses and waits for every goroutine of the
+// conversation, so that nothing outlives the connection driving it.
+
+// startResponse runs respond in the background, cancelling the response in
+// progress first. It reports false when the conversation is closed.
+// The caller must hold the mutex.
+func (c *Conversation) startResponse(respond func(ctx context.Context)) bool {
+	if c.closed.Load() {
+		return false
+	}
+	if c.cancelResponse != nil {
+		c.logger.Info("Cancelling the response in progress for the next one",
+			zap.String("deviceID", c.deviceID))
+		c.cancelResponse()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.engine.responseTimeout())
+	previous, done := c.responseDone, make(chan struct{})
+	c.cancelResponse, c.responseDone = cancel, done
+	c.tasks.Add(1)
+	go func() {
+		defer c.tasks.Done()
+		defer close(done)
+		defer cancel()
+		if previous != nil {
+			<-previous
+		}
+		respond(ctx)
+	}()
+	return true
+}
+
+// background runs task in a goroutine Close waits for. Nothing runs once the
+// conversation is closed.
+// The caller must hold the mutex.
+func (c *Conversation) background(task func()) {
+	if c.closed.Load() {
+		return
+	}
+	c.tasks.Add(1)
+	go func() {
+		defer c.tasks.Done()
+		task()
+	}()
+}
+
+// Close cancels the response in progress, closes the speech-to-text stream
+// of a listening session that was not suspended, and blocks until every
+// goroutine of the conversation returned. No event is emitted afterwards.
+func (c *Conversation) Close() {
+	c.mutex.Lock()
+	c.closed.Store(true)
+	if c.cancelResponse != nil {
+		c.cancelResponse()
+	}
+	c.closeListening()
+	c.mutex.Unlock()
+	c.tasks.Wait()
+}
@@ -0,0 +1,115 @@
+package conversation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestConversation_SpeaksOneResponseAtATime(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo boneka"})
+	f.tts.Delays = map[string]time.Duration{"Halo juga!": 100 * time.Millisecond}
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	// The child keeps talking before the doll could answer
+	for i := 0; i < 3; i++ {
+		speak(t, conv, sink)
+	}
+	var audio int
+	for i := 0; i < 3; i++ {
+		for _, event := range sink.until(t, EventSpeakingEnd) {
+			if event.Type == EventAudio {
+				audio++
+			}
+		}
+	}
+	conv.Close()
+
+	if n := f.tts.MaxConcurrent(); n != 1 {
+		t.Errorf("Expected one response synthesized at a time, got %d", n)
+	}
+	// Only the last response is heard, the others were cancelled
+	if audio != len(f.tts.Chunks) {
+		t.Errorf("Expected the audio of a single response, got %d chunks", audio)
+	}
+}
+
+func TestConversation_CloseCancelsResponseInProgress(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo boneka"})
+	f.tts.Delays = map[string]time.Duration{"Halo juga!": time.Minute}
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	sink.until(t, EventThinking)
+
+	closed := make(chan struct{})
+	go func() {
+		conv.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Close to cancel the response instead of waiting for it")
+	}
+
+	// Nothing is emitted or started once closed
+	for len(sink.events) > 0 {
+		<-sink.events
+	}
+	conv.StartListening(StartOptions{})
+	select {
+	case event := <-sink.events:
+		t.Errorf("Expected no event after Close, got %v", event.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestConversation_CloseReleasesListeningStream(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo boneka"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	// The device disconnects in the middle of the utterance
+	conv.StartListening(StartOptions{})
+	sink.next(t, EventListeningStart)
+	conv.StreamAudio([]byte{0x01, 0x02})
+	conv.Close()
+
+	stream := f.stt.Streams()[0]
+	if !stream.Released() {
+		t.Error("Expected the stream to be closed with the conversation")
+	}
+	if stream.Ended() {
+		t.Error("Expected the stream to be closed without being transcribed")
+	}
+}
+
+func TestConversation_StartListeningReleasesUnfinishedStream(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo boneka"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	defer conv.Close()
+
+	conv.StartListening(StartOptions{})
+	sink.next(t, EventListeningStart)
+	conv.StreamAudio([]byte{0x01})
+
+	// The device starts listening again without ending the utterance
+	conv.StartListening(StartOptions{})
+	sink.next(t, EventListeningStart)
+
+	streams := f.stt.Streams()
+	if len(streams) != 2 {
+		t.Fatalf("Expected a new stream, got %d streams", len(streams))
+	}
+	if !streams[0].Released() {
+		t.Error("Expected the unfinished stream to be closed")
+	}
+	if streams[1].Released() {
+		t.Error("Expected the new stream to stay open")
+	}
+}
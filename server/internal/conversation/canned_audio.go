@@ -0,0 +1,167 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// The filler, the quiet hours phrase and the pipeline fallback phrase never
+// change with the conversation, and the fallback is spoken right when
+// something already went wrong. With Config.PrewarmPhrases their audio is
+// synthesized once, at startup for the default voice and on first use for
+// the others, and replayed from memory afterwards.
+
+// maxCannedAudio bounds the phrases kept, across voices and audio formats
+const maxCannedAudio = 64
+
+// cannedKey identifies the audio of a phrase as synthesized for a context
+type cannedKey struct {
+	phrase     string
+	language   string
+	voiceID    string
+	sampleRate int
+	chunkSize  int
+}
+
+// cannedKeyFor returns the key of phrase synthesized with the settings of ctx
+func cannedKeyFor(ctx context.Context, phrase string) cannedKey {
+	key := cannedKey{phrase: phrase}
+	key.language, _ = repositories.LanguageFromContext(ctx)
+	key.voiceID, _ = repositories.VoiceIDFromContext(ctx)
+	key.sampleRate, _ = repositories.SampleRateFromContext(ctx)
+	key.chunkSize, _ = repositories.ChunkSizeFromContext(ctx)
+	return key
+}
+
+// cannedAudio holds the synthesized audio of the canned phrases
+type cannedAudio struct {
+	mu    sync.Mutex
+	audio map[cannedKey][][]byte
+}
+
+func (a *cannedAudio) get(key cannedKey) ([][]byte, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	audio, ok := a.audio[key]
+	return audio, ok
+}
+
+func (a *cannedAudio) put(key cannedKey, audio [][]byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.audio == nil {
+		a.audio = make(map[cannedKey][][]byte)
+	}
+	if _, ok := a.audio[key]; !ok && len(a.audio) >= maxCannedAudio {
+		return
+	}
+	a.audio[key] = audio
+}
+
+// reset forgets every phrase
+func (a *cannedAudio) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.audio = nil
+}
+
+// cannedPhrases returns the phrases the configuration may speak
+func (e *Engine) cannedPhrases() []string {
+	phrases := []string{e.config.QuietHoursPhrase}
+	if e.config.FillerEnabled {
+		phrases = append(phrases, e.config.FillerPhrase)
+	}
+	if e.config.SentencePipelining {
+		phrases = append(phrases, e.config.PipelineFallbackPhrase)
+	}
+	if e.config.WindDownPhrase != "" {
+		phrases = append(phrases, e.config.WindDownPhrase)
+	}
+	if e.config.SpeechUnavailablePhrase != "" {
+		phrases = append(phrases, e.config.SpeechUnavailablePhrase)
+	}
+	if e.config.FreshStartPhrase != "" {
+		phrases = append(phrases, e.config.FreshStartPhrase)
+	}
+	return phrases
+}
+
+// PrewarmPhrases synthesizes the canned phrases for the default voice and
+// language, in
+// the background priority, replacing the audio synthesized before; call it
+// again when the voices or phrases change. It does nothing unless
+// Config.PrewarmPhrases is set. Phrases that fail to synthesize are
+// synthesized on first use instead, and their errors are returned.
+func (e *Engine) PrewarmPhrases(ctx context.Context) error {
+	if !e.config.PrewarmPhrases {
+		return nil
+	}
+	e.canned.reset()
+
+	ctx = repositories.WithPriority(repositories.WithLanguage(ctx, e.config.DefaultLanguage), repositories.PriorityBackground)
+	var errs []error
+	for _, phrase := range e.cannedPhrases() {
+		audio, err := e.ttsRepo.ConvertTextToSpeech(ctx, phrase)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to synthesize %q: %w", phrase, err))
+			continue
+		}
+		var chunks [][]byte
+		for chunk := range audio {
+			chunks = append(chunks, chunk)
+		}
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+		e.canned.put(cannedKeyFor(ctx, phrase), chunks)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		e.logger.Warn("Failed to prewarm canned phrases, synthesizing them on demand", zap.Error(err))
+		return err
+	}
+	e.logger.Info("Prewarmed canned phrases", zap.Int("phrases", len(e.cannedPhrases())))
+	return nil
+}
+
+// cannedSpeech returns the audio of a canned phrase for the settings of ctx,
+// replayed from memory when it was synthesized before. Otherwise the audio
+// is synthesized and kept once completely received.
+func (e *Engine) cannedSpeech(ctx context.Context, phrase string) (<-chan []byte, error) {
+	if !e.config.PrewarmPhrases {
+		return e.ttsRepo.ConvertTextToSpeech(ctx, phrase)
+	}
+	key := cannedKeyFor(ctx, phrase)
+	if audio, ok := e.canned.get(key); ok {
+		return cachedResponse{audio: audio}.replay(), nil
+	}
+
+	audio, err := e.ttsRepo.ConvertTextToSpeech(ctx, phrase)
+	if err != nil {
+		return nil, err
+	}
+	tee := make(chan []byte)
+	go func() {
+		defer close(tee)
+		var chunks [][]byte
+		for chunk := range audio {
+			chunks = append(chunks, chunk)
+			select {
+			case tee <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if ctx.Err() == nil {
+			e.canned.put(key, chunks)
+		}
+	}()
+	return tee, nil
+}
@@ -0,0 +1,75 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// fallbackFixture answers with a response whose second sentence fails to
+// synthesize, so that the pipeline falls back to "Maaf, ya."
+func fallbackFixture(t *testing.T) *engineFixture {
+	t.Helper()
+	config := Config{SentencePipelining: true, PipelineConcurrency: 2, PipelineFallbackPhrase: "Maaf, ya.", PrewarmPhrases: true}
+	f := newEngineFixture(t, config, &conversationtest.STT{Transcript: "halo"})
+	f.llm.Reply = "Satu dua. Tiga empat!"
+	f.tts.EchoText = true
+	f.tts.Failures = map[string]error{"Tiga empat!": errors.New("synthesis failed")}
+	return f
+}
+
+// syntheses counts the syntheses of text
+func syntheses(tts *conversationtest.TTS, text string) int {
+	count := 0
+	for _, synthesized := range tts.Texts() {
+		if synthesized == text {
+			count++
+		}
+	}
+	return count
+}
+
+func TestEngine_ServesPrewarmedFallbackAudio(t *testing.T) {
+	f := fallbackFixture(t)
+	if err := f.engine.PrewarmPhrases(context.Background()); err != nil {
+		t.Fatalf("Failed to prewarm phrases: %v", err)
+	}
+	if n := syntheses(f.tts, "Maaf, ya."); n != 1 {
+		t.Fatalf("Expected the fallback synthesized at startup, got %d syntheses", n)
+	}
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	_, audio := pipelinedAudio(t, conv, sink)
+	if want := []string{"Satu dua.", "Maaf, ya."}; !slices.Equal(audio, want) {
+		t.Errorf("Expected the fallback after the failing sentence, got %q", audio)
+	}
+	if n := syntheses(f.tts, "Maaf, ya."); n != 1 {
+		t.Errorf("Expected the fallback served from memory, got %d syntheses", n)
+	}
+}
+
+func TestEngine_SynthesizesFallbackOnDemandWhenPrewarmFails(t *testing.T) {
+	f := fallbackFixture(t)
+	f.tts.Failures["Maaf, ya."] = errors.New("provider unreachable")
+	if err := f.engine.PrewarmPhrases(context.Background()); err == nil {
+		t.Fatal("Expected the failing prewarm to be reported")
+	}
+	delete(f.tts.Failures, "Maaf, ya.")
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	for turn := 0; turn < 2; turn++ {
+		_, audio := pipelinedAudio(t, conv, sink)
+		if want := []string{"Satu dua.", "Maaf, ya."}; !slices.Equal(audio, want) {
+			t.Errorf("Turn %d: expected the fallback after the failing sentence, got %q", turn, audio)
+		}
+	}
+	// Once at startup, then once on demand and kept
+	if n := syntheses(f.tts, "Maaf, ya."); n != 2 {
+		t.Errorf("Expected the fallback synthesized on first use only, got %d syntheses", n)
+	}
+}
@@ -0,0 +1,43 @@
+package conversation
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// A chat session is kept for the turns of a session, but one whose turns
+// keep failing at the provider is not trusted with the next. After
+// Config.ChatMaxFailures failed turns in a row, counting responses a chat
+// session answered with a fallback when it reports its health, the next
+// listening session drops it and creates a new one from the stored
+// messages of the session.
+
+// recordChatTurn counts the turn chatSession answered, with err when it
+// failed. Turns of a chat session that was replaced since are not counted.
+// The caller must hold the mutex.
+func (c *Conversation) recordChatTurn(chatSession repositories.ChatSession, err error) {
+	if chatSession == nil || chatSession != c.chatSession {
+		return
+	}
+	if health, ok := chatSession.(repositories.ChatSessionHealth); err == nil && (!ok || health.Healthy()) {
+		c.chatFailures = 0
+		return
+	}
+	c.chatFailures++
+}
+
+// dropFailedChat drops the chat session after too many failed turns, so
+// that the listening session starting creates a new one.
+// The caller must hold the mutex.
+func (c *Conversation) dropFailedChat() {
+	if c.chatSession == nil || c.chatFailures < c.engine.config.ChatMaxFailures {
+		return
+	}
+	c.logger.Warn("Recreating the chat session after failed turns",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", c.session.ID),
+		zap.Int("failures", c.chatFailures))
+	c.chatSession = nil
+	c.chatFailures = 0
+}
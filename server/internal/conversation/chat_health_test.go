@@ -0,0 +1,90 @@
+package conversation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestEngine_RecreatesChatSessionAfterFailedTurn(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+
+	f.llm.FailChats(errors.New("provider unavailable"), false)
+	speak(t, conv, sink)
+	if event := sink.next(t, EventError); event.Error != "failed to generate response" {
+		t.Fatalf("Expected the turn to fail, got %q", event.Error)
+	}
+
+	f.llm.FailChats(nil, false)
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	conv.Close()
+
+	histories := f.llm.Histories()
+	if len(histories) != 2 {
+		t.Fatalf("Expected the failed chat session to be recreated, got %d chat sessions", len(histories))
+	}
+	// The new chat session continues from the stored messages
+	history := histories[1]
+	if len(history) != 2 || history[0].Role != entities.UserRole || history[1].Content != "Halo juga!" {
+		t.Errorf("Expected the first turn in the history of the new chat session, got %+v", history)
+	}
+	if sessions := f.sessions.Sessions(); len(sessions) != 1 || len(sessions[0].Messages) != 4 {
+		t.Errorf("Expected the turns to continue the same session, got %+v", sessions)
+	}
+}
+
+func TestEngine_RecreatesUnhealthyChatSession(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	// A fallback answer is spoken, but the chat session is not trusted again
+	f.llm.FailChats(nil, true)
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+
+	f.llm.FailChats(nil, false)
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	conv.Close()
+
+	histories := f.llm.Histories()
+	if len(histories) != 2 || len(histories[1]) != 2 {
+		t.Errorf("Expected one recreation with the stored turn, got %+v", histories)
+	}
+}
+
+func TestEngine_KeepsChatSessionBelowMaxFailures(t *testing.T) {
+	f := newEngineFixture(t, Config{ChatMaxFailures: 2}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	f.llm.FailChats(errors.New("provider unavailable"), false)
+	speak(t, conv, sink)
+	sink.next(t, EventError)
+	f.llm.FailChats(nil, false)
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	// The failures were not consecutive
+	f.llm.FailChats(errors.New("provider unavailable"), false)
+	speak(t, conv, sink)
+	sink.next(t, EventError)
+	f.llm.FailChats(nil, false)
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	conv.Close()
+
+	if histories := f.llm.Histories(); len(histories) != 1 {
+		t.Errorf("Expected the chat session to be kept, got %d chat sessions", len(histories))
+	}
+}
@@ -0,0 +1,55 @@
+package conversation
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// childScoped reports whether the conversation continues the sessions of its
+// child across their devices rather than those of its device.
+// The caller must hold the mutex.
+func (c *Conversation) childScoped() bool {
+	return c.engine.sessionsByChild != nil && c.childID != ""
+}
+
+// lastSession returns the session the conversation continues from: the last
+// one of the child when sessions follow the child, else the last one of the
+// device. The caller must hold the mutex.
+func (c *Conversation) lastSession(ctx context.Context) (*entities.Session, error) {
+	if c.childScoped() {
+		return c.engine.sessionsByChild.GetLastByChildID(ctx, c.childID)
+	}
+	return c.engine.sessionRepo.GetLastByDeviceID(ctx, c.deviceID)
+}
+
+// followChild replaces the cached session when the child has since talked
+// through another of their devices, so that the conversation picks up where
+// they left off there. The caller must hold the mutex.
+func (c *Conversation) followChild(ctx context.Context) {
+	if !c.childScoped() || isEphemeral(c.session) {
+		return
+	}
+
+	last, err := c.lastSession(ctx)
+	if err != nil {
+		c.logger.Warn("Failed to get last session of child, continuing the cached session",
+			zap.String("deviceID", c.deviceID),
+			zap.String("childID", c.childID),
+			zap.Error(err))
+		return
+	}
+	if last == nil || (last.ID == c.session.ID && !last.LastMessageAt.After(c.session.LastMessageAt)) {
+		return
+	}
+
+	c.logger.Info("Following child to the session continued on another device",
+		zap.String("deviceID", c.deviceID),
+		zap.String("childID", c.childID),
+		zap.String("sessionID", last.ID))
+	c.session = last
+	// The chat history lacks the turns taken on the other device
+	c.chatSession = nil
+}
@@ -0,0 +1,116 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/adapters/cache"
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+func TestEngine_ChildScopedSessionsFollowChildAcrossDevices(t *testing.T) {
+	f := newEngineFixture(t, Config{ChildScopedSessions: true}, &conversationtest.STT{Transcript: "halo"})
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-bedroom", "device-living-room"}}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	bedroomSink, livingRoomSink := newRecorder(), newRecorder()
+	bedroom := f.engine.NewConversation("device-bedroom", bedroomSink)
+	livingRoom := f.engine.NewConversation("device-living-room", livingRoomSink)
+
+	storedTurn(t, bedroom, bedroomSink)
+	storedTurn(t, livingRoom, livingRoomSink)
+
+	if livingRoom.SessionID() != bedroom.SessionID() {
+		t.Fatalf("Expected the living room doll to continue session %q, got %q", bedroom.SessionID(), livingRoom.SessionID())
+	}
+	histories := f.llm.Histories()
+	if len(histories) != 2 || len(histories[1]) != 2 {
+		t.Fatalf("Expected the living room chat to start from the bedroom turn, got %v", histories)
+	}
+
+	// Back in the bedroom, the turn taken in the living room is not lost
+	storedTurn(t, bedroom, bedroomSink)
+	histories = f.llm.Histories()
+	if len(histories) != 3 || len(histories[2]) != 4 {
+		t.Fatalf("Expected the bedroom chat to be recreated with both turns, got %v", histories)
+	}
+	stored, err := f.sessions.GetLastByChildID(context.Background(), child.ID)
+	if err != nil || stored == nil || len(stored.Messages) != 6 {
+		t.Errorf("Expected the child's session to hold the three turns, got %+v, %v", stored, err)
+	}
+}
+
+func TestEngine_SessionsStayWithDeviceByDefault(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-bedroom", "device-living-room"}}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	bedroomSink, livingRoomSink := newRecorder(), newRecorder()
+	bedroom := f.engine.NewConversation("device-bedroom", bedroomSink)
+	livingRoom := f.engine.NewConversation("device-living-room", livingRoomSink)
+
+	storedTurn(t, bedroom, bedroomSink)
+	storedTurn(t, livingRoom, livingRoomSink)
+
+	if livingRoom.SessionID() == bedroom.SessionID() {
+		t.Errorf("Expected each doll to keep its own session, both continued %q", bedroom.SessionID())
+	}
+	if histories := f.llm.Histories(); len(histories) != 2 || len(histories[1]) != 0 {
+		t.Errorf("Expected the living room chat to start afresh, got %v", histories)
+	}
+}
+
+// The server always puts the session cache in front of MongoDB
+func TestEngine_ChildScopedSessionsThroughSessionCache(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	sessions := cache.NewSessionCache(cache.SessionCacheConfig{}, adapters.NewMemorySessionRepository(adapters.MemorySessionConfig{}, logger), logger)
+	children := adapters.NewMemoryChildRepository()
+	engine, err := NewEngine(Config{ChildScopedSessions: true}, &conversationtest.LLM{Reply: "Halo juga!"}, &conversationtest.TTS{Chunks: [][]byte{{0xAA}}},
+		&conversationtest.STT{Transcript: "halo"}, sessions, children, adapters.NewMemoryQuietHoursRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if engine.sessionsByChild == nil {
+		t.Fatal("Expected the session cache to find sessions by child")
+	}
+
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-bedroom", "device-living-room"}}
+	if err := children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	bedroomSink, livingRoomSink := newRecorder(), newRecorder()
+	bedroom := engine.NewConversation("device-bedroom", bedroomSink)
+	livingRoom := engine.NewConversation("device-living-room", livingRoomSink)
+
+	storedTurn(t, bedroom, bedroomSink)
+	storedTurn(t, livingRoom, livingRoomSink)
+
+	if livingRoom.SessionID() != bedroom.SessionID() {
+		t.Errorf("Expected the living room doll to continue session %q, got %q", bedroom.SessionID(), livingRoom.SessionID())
+	}
+}
+
+func TestEngine_SessionStartedBeforeBindingBecomesChilds(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-bedroom", sink)
+	storedTurn(t, conv, sink)
+
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-bedroom"}}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	storedTurn(t, conv, sink)
+
+	stored, err := f.sessions.GetLastByChildID(context.Background(), child.ID)
+	if err != nil || stored == nil || stored.ID != conv.SessionID() {
+		t.Errorf("Expected session %q found by child once bound, got %+v, %v", conv.SessionID(), stored, err)
+	}
+}
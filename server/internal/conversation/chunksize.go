@@ -0,0 +1,66 @@
+package conversation
+
+import (
+	"context"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+const (
+	// Bounds for the TTS chunk size a device may negotiate
+	minChunkSize = 256
+	maxChunkSize = 16 * 1024
+)
+
+// chunkSizeProfiles maps the connection profiles a device can announce to
+// a TTS chunk size. Larger chunks mean fewer frames, smaller ones mean the
+// first audio arrives sooner.
+var chunkSizeProfiles = map[string]int{
+	"low_latency":    512,
+	"high_bandwidth": 8 * 1024,
+}
+
+// NegotiateChunkSize resolves the TTS chunk size requested by a device,
+// either explicitly in bytes or through a connection profile. An explicit
+// size wins and is clamped to the supported range. It returns 0 when
+// nothing usable was requested, which keeps the TTS default.
+func NegotiateChunkSize(requested int, profile string) int {
+	if requested <= 0 {
+		return chunkSizeProfiles[profile]
+	}
+	if requested < minChunkSize {
+		return minChunkSize
+	}
+	if requested > maxChunkSize {
+		return maxChunkSize
+	}
+	return requested
+}
+
+// SetChunkSize sets the TTS chunk size used for this conversation's audio.
+// Zero restores the TTS default.
+func (c *Conversation) SetChunkSize(size int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.chunkSize = size
+}
+
+// ttsContext applies the negotiated chunk size and sample rate, the session
+// language and the child's custom voice to a synthesis context. The caller must hold the mutex.
+func (c *Conversation) ttsContext(ctx context.Context) context.Context {
+	language := ""
+	if c.session != nil {
+		language = c.language(c.session)
+		ctx = repositories.WithLanguage(ctx, language)
+	}
+	if c.chunkSize > 0 {
+		ctx = repositories.WithChunkSize(ctx, c.chunkSize)
+	}
+	if voiceID := c.speakingVoice(ctx, language); voiceID != "" {
+		ctx = repositories.WithVoiceID(ctx, voiceID)
+	}
+	if c.audio != nil {
+		ctx = repositories.WithSampleRate(ctx, c.audio.OutputSampleRate)
+	}
+	return ctx
+}
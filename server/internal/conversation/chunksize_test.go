@@ -0,0 +1,28 @@
+package conversation
+
+import "testing"
+
+func TestNegotiateChunkSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested int
+		profile   string
+		want      int
+	}{
+		{"nothing requested keeps default", 0, "", 0},
+		{"unknown profile keeps default", 0, "satellite", 0},
+		{"low latency profile", 0, "low_latency", 512},
+		{"high bandwidth profile", 0, "high_bandwidth", 8192},
+		{"explicit size wins over profile", 2048, "low_latency", 2048},
+		{"too small is clamped", 16, "", minChunkSize},
+		{"too large is clamped", 1 << 20, "", maxChunkSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NegotiateChunkSize(tt.requested, tt.profile); got != tt.want {
+				t.Errorf("NegotiateChunkSize(%d, %q) = %d, want %d", tt.requested, tt.profile, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,428 @@
+package conversation
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultFillerDelay  = 400 * time.Millisecond
+	defaultFillerPhrase = "Hmm..."
+
+	defaultQuietHoursPhrase = "Sstt... sudah waktunya tidur. Sampai besok, ya!"
+
+	defaultDemoPhrase = "Halo! Aku boneka Arunika. Senang sekali bisa mengobrol denganmu!"
+
+	defaultSessionCreateRetries = 2
+
+	// defaultLanguage is the language children are listened to in unless the
+	// device or the parent chose another
+	defaultLanguage = "id-ID"
+
+	defaultResponseCacheVariants = 1
+
+	defaultAudioBufferMax = 1 << 20
+	// defaultEarlyAudioMax holds a second of 16 kHz LINEAR16 audio
+	defaultEarlyAudioMax = 32000
+
+	defaultChatMaxFailures = 1
+
+	defaultLearnedWordsDailyMax = 5
+
+	defaultResumeAcknowledgementAfter = 3 * time.Minute
+
+	defaultPipelineConcurrency    = 2
+	defaultPipelineFallbackPhrase = "Eh, aku lupa mau bilang apa. Coba tanya lagi, ya!"
+
+	defaultWindDownPhrase = "Segitu dulu, ya. Nanti kita lanjutkan lagi!"
+
+	defaultSpeechUnavailablePhrase = "Aduh, suaraku lagi istirahat dulu. Nanti kita ngobrol lagi, ya!"
+)
+
+// Config holds configuration for the conversation engine
+// Optional fields with defaults:
+// - FillerEnabled: Play a short acknowledgement while transcription finalizes (default: false)
+// - FillerDelay: How long transcription may take before the filler plays (default: 400ms)
+// - FillerPhrase: The text synthesized as the filler (default: "Hmm...")
+// - ListeningDebounce: Window after speaking ends in which a new listening start is ignored (default: 0, disabled)
+// - EchoSuppression: Drop transcriptions that repeat what the doll just said (default: false)
+// - DemoMode: Answer every transcription with DemoPhrase instead of asking the LLM (default: false)
+// - DemoPhrase: The text synthesized as every response in demo mode (default: "Halo! Aku boneka Arunika. Senang sekali bisa mengobrol denganmu!")
+// - OutputSampleRate: Sample rate of the synthesized audio, kept when the device can play it (default: 24000)
+// - QuietHoursPhrase: The text spoken instead of listening during quiet hours (default: "Sstt... sudah waktunya tidur. Sampai besok, ya!")
+// - SessionCreateRetries: Times a session creation conflicting with another active session is reconciled and retried (default: 2)
+// - PIIRedaction: Replace phone numbers, addresses and emails in transcriptions with placeholders before storing them (default: false)
+// - PIINameDetection: Also ask the LLM for the names of people to redact, when it supports it (default: false)
+// - PIIHashKey: Key of the hash appended to placeholders so repeated values can be matched (default: empty, no hash)
+// - PIIRawToLLM: Send the unredacted transcription to the LLM for the current turn (default: false)
+// - EphemeralSessions: Keep talking with an in-memory session while the session storage fails, storing it once it recovers (default: false)
+// - ResponseCache: Answer an utterance repeated within a session with the response already generated for it (default: false)
+// - ResponseCacheVariants: Responses generated for a repeated utterance before the cached ones are replayed in turn (default: 1)
+// - AudioBufferMax: Bytes of device audio queued for speech-to-text per connection before audio is dropped (default: 1 MiB)
+// - AudioBufferOverflowDisconnect: Abandon the listening session and disconnect the device instead of dropping the oldest audio beyond AudioBufferMax (default: false)
+// - EarlyAudioMax: Bytes of device audio received before listening started kept for the next listening session, oldest dropped first; negative to drop early audio (default: 32000)
+// - ResumeGrace: How long the listening session of a lost connection is held for the device to reconnect and resume it (default: 0, disabled)
+// - SentencePipelining: Synthesize the response sentence by sentence while the LLM is still generating it (default: false)
+// - PipelineConcurrency: Sentences synthesized ahead of the playback at once with sentence pipelining (default: 2)
+// - PipelineFallbackPhrase: The text spoken instead of the rest of a response whose sentence failed to synthesize (default: "Eh, aku lupa mau bilang apa. Coba tanya lagi, ya!")
+// - PrewarmPhrases: Synthesize the filler, quiet hours and fallback phrases once and replay their audio from memory (default: false)
+// - DefaultLanguage: BCP-47 language of the conversations for which neither the device, the session nor the child's profile name one (default: "id-ID")
+// - DefaultTimezone: IANA timezone of the time of day told to the LLM for children without a timezone (default: the server's local timezone)
+// - DailyTopics: Themes the doll suggests, one per day rotating through those suiting the child's age (default: none)
+// - ExplainSimpler: Explain the previous response again more simply when the child says they did not understand it (default: false)
+// - ConfusionCues: Phrases, by language, with which a child says they did not understand (default: common Indonesian and English phrases)
+// - IntentSegmentation: Ask the LLM to list the separate things the child asked in one utterance and answer each in turn, without sentence pipelining (default: false)
+// - ParentSummaries: Ask the LLM for a brief summary of every ended session of a child for the parent, when it supports it (default: false)
+// - MaxResponseAudio: Audio synthesized for a response before it is cut and wound down, for children no ResponseAudioCaps band covers (default: 0, unlimited)
+// - ResponseAudioCaps: Audio caps of responses by age band of the child, overriding MaxResponseAudio (default: none)
+// - WindDownPhrase: The text spoken after a response cut at its audio cap (default: "Segitu dulu, ya. Nanti kita lanjutkan lagi!")
+// - SpeechUnavailablePhrase: The text spoken from its prewarmed audio instead of responses while the text-to-speech quota is exceeded (default: "Aduh, suaraku lagi istirahat dulu. Nanti kita ngobrol lagi, ya!" with PrewarmPhrases)
+// - MaxTurns: Responses in the chat context of a session before it is summarized and started afresh (default: 0, unlimited)
+// - ChatMaxFailures: Consecutive failed turns of a chat session after which the next listening session recreates it from the stored messages (default: 1)
+// - FreshStartPhrase: The text spoken after the last response before a fresh chat context (default: none)
+// - LearnedWords: Track the notable new words of the turns of children whose parent consented, when the LLM supports it (default: false)
+// - LearnedWordsDailyMax: New words tracked per child and day, further turns are not inspected (default: 5)
+// - ResumeAcknowledgement: Welcome the child back to what a continued session was last about, at the start of the first response after a break (default: false)
+// - ResumeAcknowledgementAfter: Time since the last message of a session after which continuing it is a break the child is welcomed back from (default: 3m)
+// - QualityMetrics: Export the audio quality of the utterances by device model and LLM model as conversation_audio_quality (default: false)
+// - VoiceLanguageFallback: Speak with the default voice of the conversation language when the child's voice does not speak it, when the TTS provider tells (default: false)
+// - ChildScopedSessions: Continue the sessions of the child a device is bound to across all their devices, instead of those of the device, when the session storage supports it (default: false)
+// - BargeInFadeOut: Fade the last audio of a response cut short by the next utterance out over this duration, at most 50ms, when text-to-speech streams PCM (default: 0, disabled)
+type Config struct {
+	FillerEnabled     bool          // Optional: Play a short acknowledgement while transcription finalizes
+	FillerDelay       time.Duration // Optional: How long transcription may take before the filler plays
+	FillerPhrase      string        // Optional: The text synthesized as the filler
+	ListeningDebounce time.Duration // Optional: Window after speaking ends in which a new listening start is ignored
+	EchoSuppression   bool          // Optional: Drop transcriptions that repeat what the doll just said
+	DemoMode          bool          // Optional: Answer every transcription with DemoPhrase instead of asking the LLM
+	DemoPhrase        string        // Optional: The text synthesized as every response in demo mode
+	OutputSampleRate  int           // Optional: Sample rate of the synthesized audio, kept when the device can play it
+	QuietHoursPhrase  string        // Optional: The text spoken instead of listening during quiet hours
+
+	SessionCreateRetries int // Optional: Times a conflicting session creation is reconciled and retried
+
+	PIIRedaction     bool   // Optional: Replace personal information in transcriptions before storing them
+	PIINameDetection bool   // Optional: Also ask the LLM for the names of people to redact
+	PIIHashKey       string // Optional: Key of the hash appended to placeholders
+	PIIRawToLLM      bool   // Optional: Send the unredacted transcription to the LLM for the current turn
+
+	EphemeralSessions bool // Optional: Keep talking with an in-memory session while the session storage fails
+
+	ResponseCache         bool // Optional: Answer an utterance repeated within a session with its cached response
+	ResponseCacheVariants int  // Optional: Responses generated for a repeated utterance before the cached ones are replayed
+
+	DefaultLanguage string // Optional: BCP-47 language of the conversations for which nothing else names one
+	DefaultTimezone string // Optional: IANA timezone of the time of day told to the LLM for children without a timezone
+
+	DailyTopics []DailyTopic // Optional: Themes the doll suggests, one per day rotating through those suiting the child's age
+
+	ExplainSimpler bool                // Optional: Explain the previous response again more simply to a confused child
+	ConfusionCues  map[string][]string // Optional: Phrases, by language, with which a child says they did not understand
+
+	AudioBufferMax                int  // Optional: Bytes of device audio queued for speech-to-text per connection
+	AudioBufferOverflowDisconnect bool // Optional: Disconnect the device instead of dropping the oldest audio beyond AudioBufferMax
+	EarlyAudioMax                 int  // Optional: Bytes of audio received before listening started kept for the next listening session
+
+	ResumeGrace time.Duration // Optional: How long the listening session of a lost connection is held for the device to resume it
+
+	SentencePipelining     bool   // Optional: Synthesize the response sentence by sentence while the LLM is still generating it
+	PipelineConcurrency    int    // Optional: Sentences synthesized ahead of the playback at once
+	PipelineFallbackPhrase string // Optional: The text spoken instead of the rest of a response whose sentence failed to synthesize
+
+	PrewarmPhrases bool // Optional: Synthesize the canned phrases once and replay their audio from memory
+
+	IntentSegmentation bool // Optional: Answer each of the separate things the child asked in one utterance in turn
+
+	ParentSummaries bool // Optional: Summarize every ended session of a child for the parent
+
+	MaxResponseAudio  time.Duration      // Optional: Audio synthesized for a response before it is cut and wound down
+	ResponseAudioCaps []ResponseAudioCap // Optional: Audio caps of responses by age band of the child
+	WindDownPhrase    string             // Optional: The text spoken after a response cut at its audio cap
+
+	SpeechUnavailablePhrase string // Optional: The text spoken from its prewarmed audio while the text-to-speech quota is exceeded
+
+	ChatMaxFailures int // Optional: Consecutive failed turns of a chat session before it is recreated from the stored messages
+
+	MaxTurns         int    // Optional: Responses in the chat context of a session before it is summarized and started afresh
+	FreshStartPhrase string // Optional: The text spoken after the last response before a fresh chat context
+
+	LearnedWords         bool // Optional: Track the notable new words of the turns of consenting children
+	LearnedWordsDailyMax int  // Optional: New words tracked per child and day
+
+	ResumeAcknowledgement      bool          // Optional: Welcome the child back to what a continued session was last about
+	ResumeAcknowledgementAfter time.Duration // Optional: Time since the last message of a session after which the child is welcomed back
+
+	QualityMetrics bool // Optional: Export the audio quality of the utterances by device model and LLM model
+
+	VoiceLanguageFallback bool // Optional: Speak with the default voice when the child's voice does not speak the conversation language
+
+	ChildScopedSessions bool // Optional: Continue the sessions of the child a device is bound to across all their devices
+
+	BargeInFadeOut time.Duration // Optional: Fade the last audio of a response cut short by the next utterance out over this duration
+}
+
+// legacyEnv maps the variables that were named after the WebSocket hub before
+// the engine was extracted to their former names, still accepted
+var legacyEnv = map[string]string{
+	"CONVERSATION_FILLER_ENABLED":           "HUB_FILLER_ENABLED",
+	"CONVERSATION_FILLER_DELAY_MS":          "HUB_FILLER_DELAY_MS",
+	"CONVERSATION_FILLER_PHRASE":            "HUB_FILLER_PHRASE",
+	"CONVERSATION_LISTENING_DEBOUNCE_MS":    "HUB_LISTENING_DEBOUNCE_MS",
+	"CONVERSATION_ECHO_SUPPRESSION_ENABLED": "HUB_ECHO_SUPPRESSION_ENABLED",
+}
+
+// getenv returns the value of the variable key, or of its former name when
+// only that one is set
+func getenv(key string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	if legacy, ok := legacyEnv[key]; ok {
+		return os.Getenv(legacy)
+	}
+	return ""
+}
+
+// NewConfigFromEnv creates a new Config from environment variables
+// This is a helper function to simplify the creation of a properly configured Config
+func NewConfigFromEnv() Config {
+	config := Config{
+		FillerPhrase:     getenv("CONVERSATION_FILLER_PHRASE"),
+		DemoPhrase:       os.Getenv("CONVERSATION_DEMO_PHRASE"),
+		QuietHoursPhrase: os.Getenv("CONVERSATION_QUIET_HOURS_PHRASE"),
+		PIIHashKey:       os.Getenv("CONVERSATION_PII_HASH_KEY"),
+		DefaultLanguage:  os.Getenv("CONVERSATION_DEFAULT_LANGUAGE"),
+		DefaultTimezone:  os.Getenv("CONVERSATION_DEFAULT_TIMEZONE"),
+
+		PipelineFallbackPhrase: os.Getenv("CONVERSATION_PIPELINE_FALLBACK_PHRASE"),
+		WindDownPhrase:         os.Getenv("CONVERSATION_WIND_DOWN_PHRASE"),
+
+		SpeechUnavailablePhrase: os.Getenv("CONVERSATION_SPEECH_UNAVAILABLE_PHRASE"),
+		FreshStartPhrase:        os.Getenv("CONVERSATION_FRESH_START_PHRASE"),
+	}
+
+	if enabledStr := getenv("CONVERSATION_FILLER_ENABLED"); enabledStr != "" {
+		if enabled, err := strconv.ParseBool(enabledStr); err == nil {
+			config.FillerEnabled = enabled
+		}
+	}
+
+	if delayStr := getenv("CONVERSATION_FILLER_DELAY_MS"); delayStr != "" {
+		if delay, err := strconv.Atoi(delayStr); err == nil && delay > 0 {
+			config.FillerDelay = time.Duration(delay) * time.Millisecond
+		}
+	}
+
+	if debounceStr := getenv("CONVERSATION_LISTENING_DEBOUNCE_MS"); debounceStr != "" {
+		if debounce, err := strconv.Atoi(debounceStr); err == nil && debounce > 0 {
+			config.ListeningDebounce = time.Duration(debounce) * time.Millisecond
+		}
+	}
+
+	if echoStr := getenv("CONVERSATION_ECHO_SUPPRESSION_ENABLED"); echoStr != "" {
+		if enabled, err := strconv.ParseBool(echoStr); err == nil {
+			config.EchoSuppression = enabled
+		}
+	}
+
+	if demoStr := os.Getenv("CONVERSATION_DEMO_MODE_ENABLED"); demoStr != "" {
+		if enabled, err := strconv.ParseBool(demoStr); err == nil {
+			config.DemoMode = enabled
+		}
+	}
+
+	if rateStr := os.Getenv("CONVERSATION_OUTPUT_SAMPLE_RATE"); rateStr != "" {
+		if rate, err := strconv.Atoi(rateStr); err == nil && rate > 0 {
+			config.OutputSampleRate = rate
+		}
+	}
+
+	if retriesStr := os.Getenv("CONVERSATION_SESSION_CREATE_RETRIES"); retriesStr != "" {
+		if retries, err := strconv.Atoi(retriesStr); err == nil && retries > 0 {
+			config.SessionCreateRetries = retries
+		}
+	}
+
+	if redactStr := os.Getenv("CONVERSATION_PII_REDACTION_ENABLED"); redactStr != "" {
+		if enabled, err := strconv.ParseBool(redactStr); err == nil {
+			config.PIIRedaction = enabled
+		}
+	}
+
+	if namesStr := os.Getenv("CONVERSATION_PII_NAME_DETECTION_ENABLED"); namesStr != "" {
+		if enabled, err := strconv.ParseBool(namesStr); err == nil {
+			config.PIINameDetection = enabled
+		}
+	}
+
+	if rawStr := os.Getenv("CONVERSATION_PII_RAW_TO_LLM"); rawStr != "" {
+		if enabled, err := strconv.ParseBool(rawStr); err == nil {
+			config.PIIRawToLLM = enabled
+		}
+	}
+
+	if ephemeralStr := os.Getenv("CONVERSATION_EPHEMERAL_SESSIONS_ENABLED"); ephemeralStr != "" {
+		if enabled, err := strconv.ParseBool(ephemeralStr); err == nil {
+			config.EphemeralSessions = enabled
+		}
+	}
+
+	if cacheStr := os.Getenv("CONVERSATION_RESPONSE_CACHE_ENABLED"); cacheStr != "" {
+		if enabled, err := strconv.ParseBool(cacheStr); err == nil {
+			config.ResponseCache = enabled
+		}
+	}
+
+	if variantsStr := os.Getenv("CONVERSATION_RESPONSE_CACHE_VARIANTS"); variantsStr != "" {
+		if variants, err := strconv.Atoi(variantsStr); err == nil && variants > 0 {
+			config.ResponseCacheVariants = variants
+		}
+	}
+
+	if maxStr := os.Getenv("CONVERSATION_AUDIO_BUFFER_MAX_BYTES"); maxStr != "" {
+		if max, err := strconv.Atoi(maxStr); err == nil && max > 0 {
+			config.AudioBufferMax = max
+		}
+	}
+
+	if maxStr := os.Getenv("CONVERSATION_EARLY_AUDIO_MAX_BYTES"); maxStr != "" {
+		if max, err := strconv.Atoi(maxStr); err == nil && max != 0 {
+			config.EarlyAudioMax = max
+		}
+	}
+
+	if disconnectStr := os.Getenv("CONVERSATION_AUDIO_BUFFER_OVERFLOW_DISCONNECT"); disconnectStr != "" {
+		if disconnect, err := strconv.ParseBool(disconnectStr); err == nil {
+			config.AudioBufferOverflowDisconnect = disconnect
+		}
+	}
+
+	if graceStr := os.Getenv("CONVERSATION_RESUME_GRACE_MS"); graceStr != "" {
+		if grace, err := strconv.Atoi(graceStr); err == nil && grace > 0 {
+			config.ResumeGrace = time.Duration(grace) * time.Millisecond
+		}
+	}
+
+	if pipeliningStr := os.Getenv("CONVERSATION_SENTENCE_PIPELINING_ENABLED"); pipeliningStr != "" {
+		if enabled, err := strconv.ParseBool(pipeliningStr); err == nil {
+			config.SentencePipelining = enabled
+		}
+	}
+
+	if concurrencyStr := os.Getenv("CONVERSATION_PIPELINE_CONCURRENCY"); concurrencyStr != "" {
+		if concurrency, err := strconv.Atoi(concurrencyStr); err == nil && concurrency > 0 {
+			config.PipelineConcurrency = concurrency
+		}
+	}
+
+	if prewarmStr := os.Getenv("CONVERSATION_PREWARM_PHRASES_ENABLED"); prewarmStr != "" {
+		if enabled, err := strconv.ParseBool(prewarmStr); err == nil {
+			config.PrewarmPhrases = enabled
+		}
+	}
+
+	if topicsStr := os.Getenv("CONVERSATION_DAILY_TOPICS"); topicsStr != "" {
+		if topics, err := ParseDailyTopics(topicsStr); err == nil {
+			config.DailyTopics = topics
+		}
+	}
+
+	if simplerStr := os.Getenv("CONVERSATION_EXPLAIN_SIMPLER_ENABLED"); simplerStr != "" {
+		if enabled, err := strconv.ParseBool(simplerStr); err == nil {
+			config.ExplainSimpler = enabled
+		}
+	}
+
+	if cuesStr := os.Getenv("CONVERSATION_CONFUSION_CUES"); cuesStr != "" {
+		if cues, err := ParseConfusionCues(cuesStr); err == nil {
+			config.ConfusionCues = cues
+		}
+	}
+
+	if segmentationStr := os.Getenv("CONVERSATION_INTENT_SEGMENTATION_ENABLED"); segmentationStr != "" {
+		if enabled, err := strconv.ParseBool(segmentationStr); err == nil {
+			config.IntentSegmentation = enabled
+		}
+	}
+
+	if summaryStr := os.Getenv("CONVERSATION_PARENT_SUMMARY_ENABLED"); summaryStr != "" {
+		if enabled, err := strconv.ParseBool(summaryStr); err == nil {
+			config.ParentSummaries = enabled
+		}
+	}
+
+	if failuresStr := os.Getenv("CONVERSATION_CHAT_MAX_FAILURES"); failuresStr != "" {
+		if failures, err := strconv.Atoi(failuresStr); err == nil && failures > 0 {
+			config.ChatMaxFailures = failures
+		}
+	}
+
+	if wordsStr := os.Getenv("CONVERSATION_LEARNED_WORDS_ENABLED"); wordsStr != "" {
+		if enabled, err := strconv.ParseBool(wordsStr); err == nil {
+			config.LearnedWords = enabled
+		}
+	}
+
+	if maxStr := os.Getenv("CONVERSATION_LEARNED_WORDS_DAILY_MAX"); maxStr != "" {
+		if max, err := strconv.Atoi(maxStr); err == nil && max > 0 {
+			config.LearnedWordsDailyMax = max
+		}
+	}
+
+	if resumeStr := os.Getenv("CONVERSATION_RESUME_ACKNOWLEDGEMENT_ENABLED"); resumeStr != "" {
+		if enabled, err := strconv.ParseBool(resumeStr); err == nil {
+			config.ResumeAcknowledgement = enabled
+		}
+	}
+
+	if afterStr := os.Getenv("CONVERSATION_RESUME_ACKNOWLEDGEMENT_AFTER_SECONDS"); afterStr != "" {
+		if after, err := strconv.Atoi(afterStr); err == nil && after > 0 {
+			config.ResumeAcknowledgementAfter = time.Duration(after) * time.Second
+		}
+	}
+
+	if metricsStr := os.Getenv("CONVERSATION_QUALITY_METRICS_ENABLED"); metricsStr != "" {
+		if enabled, err := strconv.ParseBool(metricsStr); err == nil {
+			config.QualityMetrics = enabled
+		}
+	}
+
+	if fallbackStr := os.Getenv("CONVERSATION_VOICE_LANGUAGE_FALLBACK_ENABLED"); fallbackStr != "" {
+		if enabled, err := strconv.ParseBool(fallbackStr); err == nil {
+			config.VoiceLanguageFallback = enabled
+		}
+	}
+
+	if childScopedStr := os.Getenv("CONVERSATION_CHILD_SCOPED_SESSIONS_ENABLED"); childScopedStr != "" {
+		if enabled, err := strconv.ParseBool(childScopedStr); err == nil {
+			config.ChildScopedSessions = enabled
+		}
+	}
+
+	if fadeStr := os.Getenv("CONVERSATION_BARGE_IN_FADE_OUT_MS"); fadeStr != "" {
+		if fade, err := strconv.Atoi(fadeStr); err == nil && fade > 0 {
+			config.BargeInFadeOut = time.Duration(fade) * time.Millisecond
+		}
+	}
+
+	if turnsStr := os.Getenv("CONVERSATION_MAX_TURNS"); turnsStr != "" {
+		if turns, err := strconv.Atoi(turnsStr); err == nil && turns > 0 {
+			config.MaxTurns = turns
+		}
+	}
+
+	if maxStr := os.Getenv("CONVERSATION_MAX_RESPONSE_AUDIO_SECONDS"); maxStr != "" {
+		if max, err := strconv.Atoi(maxStr); err == nil && max > 0 {
+			config.MaxResponseAudio = time.Duration(max) * time.Second
+		}
+	}
+
+	if capsStr := os.Getenv("CONVERSATION_RESPONSE_AUDIO_CAPS"); capsStr != "" {
+		if caps, err := ParseResponseAudioCaps(capsStr); err == nil {
+			config.ResponseAudioCaps = caps
+		}
+	}
+
+	return config
+}
@@ -0,0 +1,29 @@
+package conversation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewConfigFromEnv_AcceptsFormerHubNames(t *testing.T) {
+	t.Setenv("HUB_FILLER_ENABLED", "true")
+	t.Setenv("HUB_FILLER_DELAY_MS", "250")
+	t.Setenv("HUB_FILLER_PHRASE", "Hmm...")
+	t.Setenv("HUB_LISTENING_DEBOUNCE_MS", "150")
+	t.Setenv("HUB_ECHO_SUPPRESSION_ENABLED", "true")
+
+	config := NewConfigFromEnv()
+
+	if !config.FillerEnabled || config.FillerDelay != 250*time.Millisecond || config.FillerPhrase != "Hmm..." {
+		t.Errorf("Expected the filler configured from the HUB_ names, got %+v", config)
+	}
+	if config.ListeningDebounce != 150*time.Millisecond || !config.EchoSuppression {
+		t.Errorf("Expected debounce and echo suppression configured from the HUB_ names, got %+v", config)
+	}
+
+	// The current name wins over the former one
+	t.Setenv("CONVERSATION_FILLER_PHRASE", "Sebentar...")
+	if config := NewConfigFromEnv(); config.FillerPhrase != "Sebentar..." {
+		t.Errorf("Expected CONVERSATION_FILLER_PHRASE to win, got %q", config.FillerPhrase)
+	}
+}
@@ -0,0 +1,122 @@
+package conversation
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// A long session grows a long chat context: it costs more per response, and
+// the model drifts from its instructions. With Config.MaxTurns, the chat
+// context of a session is summarized once it holds that many responses, and
+// the next chat session starts afresh from the summary and the messages that
+// came after it. The session record, its messages and its ID are kept.
+
+// contextResetTimeout bounds summarizing the chat context of a session and
+// storing the reset
+const contextResetTimeout = 30 * time.Second
+
+// contextTurns returns how many responses the chat context of session holds
+func contextTurns(session *entities.Session) int {
+	turns := 0
+	for _, message := range session.ContextMessages() {
+		if role, _ := entities.ParseRole(string(message.Role)); role == entities.DollRole {
+			turns++
+		}
+	}
+	return turns
+}
+
+// lastContextTurn reports whether the next response of session fills its
+// chat context, after which the context is reset.
+// The caller must hold the mutex.
+func (c *Conversation) lastContextTurn(session *entities.Session) bool {
+	maxTurns := c.engine.config.MaxTurns
+	return maxTurns > 0 && !c.resettingContext && contextTurns(session)+1 >= maxTurns
+}
+
+// speakFreshStart tells the child the conversation starts afresh after the
+// last response of a full chat context, when a phrase is configured
+func (c *Conversation) speakFreshStart(ctx context.Context, sessionID string, turn *turnSummary) {
+	phrase := c.engine.config.FreshStartPhrase
+	if phrase == "" || ctx.Err() != nil {
+		return
+	}
+	audio, err := c.engine.cannedSpeech(ctx, phrase)
+	if err != nil {
+		c.logger.Error("Failed to synthesize the fresh start phrase",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", sessionID),
+			zap.Error(err))
+		return
+	}
+	for audioData := range audio {
+		turn.AudioChunks++
+		turn.AudioBytes += len(audioData)
+		c.emit(Event{Type: EventAudio, SessionID: sessionID, Audio: audioData})
+	}
+}
+
+// resetContext summarizes the chat context of session in the background,
+// records the reset on the session and drops chatSession, so that the next
+// listening session starts a fresh chat from the summary. A context that
+// cannot be summarized is reset all the same, keeping the summary of the
+// reset before, if any.
+// The caller must hold the mutex.
+func (c *Conversation) resetContext(session *entities.Session, chatSession repositories.ChatSession) {
+	c.resettingContext = true
+	previous := ""
+	if session.ContextReset != nil {
+		previous = session.ContextReset.Summary
+	}
+	messages := append([]entities.Message(nil), session.ContextMessages()...)
+	messageCount := len(session.Messages)
+
+	summarizer := c.engine.contextSummarizer
+	c.background(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), contextResetTimeout)
+		defer cancel()
+
+		summary := previous
+		if summarizer != nil {
+			summarized, err := summarizer.SummarizeContext(ctx, previous, messages)
+			if err != nil {
+				c.logger.Warn("Failed to summarize chat context, starting afresh without it",
+					zap.String("deviceID", c.deviceID),
+					zap.String("sessionID", session.ID),
+					zap.Error(err))
+			} else {
+				summary = summarized
+			}
+		}
+
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		c.resettingContext = false
+		session.ContextReset = &entities.ContextReset{
+			Summary:      summary,
+			MessageCount: messageCount,
+			ResetAt:      c.engine.now(),
+		}
+		if c.chatSession == chatSession {
+			c.chatSession = nil
+		}
+		c.logger.Info("Reset the chat context of a long session",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", session.ID),
+			zap.Int("messages", messageCount))
+
+		if isEphemeral(session) {
+			return
+		}
+		if err := c.engine.sessionRepo.Update(ctx, session); err != nil {
+			c.logger.Warn("Failed to store the chat context reset",
+				zap.String("sessionID", session.ID),
+				zap.Error(err))
+		}
+	})
+}
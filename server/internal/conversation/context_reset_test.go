@@ -0,0 +1,152 @@
+package conversation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// awaitContextReset waits for the chat context reset started by the last
+// response of conv to be recorded on its session
+func awaitContextReset(t *testing.T, conv *Conversation) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		conv.mutex.Lock()
+		done := conv.session != nil && conv.session.ContextReset != nil && !conv.resettingContext
+		conv.mutex.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for the chat context reset")
+}
+
+// spokenTexts returns the texts of the audio events, synthesized with EchoText
+func spokenTexts(events []Event) []string {
+	var texts []string
+	for _, event := range events {
+		if event.Type == EventAudio {
+			texts = append(texts, string(event.Audio))
+		}
+	}
+	return texts
+}
+
+func TestEngine_ResetsChatContextAfterMaxTurns(t *testing.T) {
+	for name, pipelining := range map[string]bool{"whole response": false, "pipelined": true} {
+		t.Run(name, func(t *testing.T) {
+			f := newEngineFixture(t, Config{
+				MaxTurns:           2,
+				FreshStartPhrase:   "Kita mulai lagi, ya!",
+				SentencePipelining: pipelining,
+			}, &conversationtest.STT{Transcript: "halo"})
+			f.tts.EchoText = true
+			f.llm.ContextSummary = "Kirana bilang halo dua kali."
+			sink := newRecorder()
+			conv := f.engine.NewConversation("device-1", sink)
+
+			speak(t, conv, sink)
+			if texts := spokenTexts(sink.until(t, EventSpeakingEnd)); len(texts) != 1 || texts[0] != "Halo juga!" {
+				t.Fatalf("Expected only the response before the last turn, got %q", texts)
+			}
+			speak(t, conv, sink)
+			texts := spokenTexts(sink.until(t, EventSpeakingEnd))
+			if len(texts) != 2 || texts[1] != "Kita mulai lagi, ya!" {
+				t.Fatalf("Expected the fresh start phrase after the last response, got %q", texts)
+			}
+			awaitContextReset(t, conv)
+
+			summaries := f.llm.ContextSummaries()
+			if len(summaries) != 1 || summaries[0].Summary != "" || len(summaries[0].Messages) != 4 {
+				t.Fatalf("Expected the two turns summarized once, got %+v", summaries)
+			}
+
+			speak(t, conv, sink)
+			sink.until(t, EventSpeakingEnd)
+			conv.Close()
+
+			histories, seeds := f.llm.Histories(), f.llm.Seeds()
+			if len(histories) != 2 || len(histories[1]) != 0 {
+				t.Fatalf("Expected a fresh chat without the summarized messages, got %+v", histories)
+			}
+			if seeds[0] != "" || seeds[1] != "Kirana bilang halo dua kali." {
+				t.Errorf("Expected the fresh chat seeded with the summary, got %q", seeds)
+			}
+
+			sessions := f.sessions.Sessions()
+			if len(sessions) != 1 || len(sessions[0].Messages) != 6 {
+				t.Fatalf("Expected the session record kept with every message, got %+v", sessions)
+			}
+			reset := sessions[0].ContextReset
+			if reset == nil || reset.MessageCount != 4 || reset.Summary != f.llm.ContextSummary || reset.ResetAt.IsZero() {
+				t.Errorf("Expected the reset stored on the session, got %+v", reset)
+			}
+		})
+	}
+}
+
+func TestEngine_SummarizesEarlierSummaryAtTheNextReset(t *testing.T) {
+	f := newEngineFixture(t, Config{MaxTurns: 1}, &conversationtest.STT{Transcript: "halo"})
+	f.llm.ContextSummary = "Kirana bilang halo."
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	defer conv.Close()
+
+	for i := 0; i < 2; i++ {
+		speak(t, conv, sink)
+		sink.until(t, EventSpeakingEnd)
+		awaitContextReset(t, conv)
+	}
+
+	summaries := f.llm.ContextSummaries()
+	if len(summaries) != 2 {
+		t.Fatalf("Expected a summary at every reset, got %+v", summaries)
+	}
+	// Only the messages after the first reset are summarized again, with
+	// the summary of those before
+	if summaries[1].Summary != "Kirana bilang halo." || len(summaries[1].Messages) != 2 {
+		t.Errorf("Expected the second summary to continue the first, got %+v", summaries[1])
+	}
+}
+
+func TestEngine_ResetsChatContextWhenSummaryFails(t *testing.T) {
+	f := newEngineFixture(t, Config{MaxTurns: 1}, &conversationtest.STT{Transcript: "halo"})
+	f.llm.ContextSummaryErr = errors.New("quota exceeded")
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	awaitContextReset(t, conv)
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	conv.Close()
+
+	histories, seeds := f.llm.Histories(), f.llm.Seeds()
+	if len(histories) != 2 || len(histories[1]) != 0 || seeds[1] != "" {
+		t.Errorf("Expected a fresh chat without a summary, got %+v seeded with %q", histories, seeds)
+	}
+}
+
+func TestEngine_KeepsChatContextWithoutMaxTurns(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	for i := 0; i < 3; i++ {
+		speak(t, conv, sink)
+		sink.until(t, EventSpeakingEnd)
+	}
+	conv.Close()
+
+	if histories := f.llm.Histories(); len(histories) != 1 {
+		t.Errorf("Expected a single chat session, got %d", len(histories))
+	}
+	if summaries := f.llm.ContextSummaries(); len(summaries) != 0 {
+		t.Errorf("Expected no summary, got %+v", summaries)
+	}
+}
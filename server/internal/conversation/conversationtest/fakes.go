@@ -0,0 +1,879 @@
+// Package conversationtest provides in-memory doubles of the pipeline
+// dependencies for tests that drive the conversation engine.
+package conversationtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/errs"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// STT hands out STTStream instances that return a fixed transcript
+type STT struct {
+	Transcript string
+	Delay      time.Duration
+	// Confidence reported for the transcript, none when zero
+	Confidence float64
+	// InitErr fails every InitTranscribeStreaming call when set
+	InitErr error
+	// Stall blocks every Stream call until it is closed, as a stalled
+	// speech-to-text service would
+	Stall chan struct{}
+
+	mu      sync.Mutex
+	streams []*STTStream
+}
+
+// Ensure STT implements the SpeechToText interface
+var _ repositories.SpeechToText = (*STT)(nil)
+
+// TranscribeAudio implements repositories.SpeechToText
+func (f *STT) TranscribeAudio(ctx context.Context, audioData []byte, config repositories.AudioConfig) (string, error) {
+	return f.Transcript, nil
+}
+
+// InitTranscribeStreaming implements repositories.SpeechToText
+func (f *STT) InitTranscribeStreaming(ctx context.Context, config repositories.AudioConfig) (repositories.SpeechToTextStreaming, error) {
+	if f.InitErr != nil {
+		return nil, f.InitErr
+	}
+	stream := &STTStream{Transcript: f.Transcript, Delay: f.Delay, confidence: f.Confidence, Config: config, stall: f.Stall, released: make(chan struct{})}
+	f.mu.Lock()
+	f.streams = append(f.streams, stream)
+	f.mu.Unlock()
+	return stream, nil
+}
+
+// Streams returns every stream initialized so far
+func (f *STT) Streams() []*STTStream {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*STTStream(nil), f.streams...)
+}
+
+// STTStream records streamed audio and returns its transcript after Delay
+type STTStream struct {
+	Transcript string
+	Delay      time.Duration
+	Config     repositories.AudioConfig
+
+	confidence float64
+	stall      chan struct{}
+	released   chan struct{}
+	mu         sync.Mutex
+	chunks     [][]byte
+	ended      bool
+	closed     bool
+}
+
+// Stream implements repositories.SpeechToTextStreaming
+func (f *STTStream) Stream(data []byte) error {
+	if f.stall != nil {
+		select {
+		case <-f.stall:
+		case <-f.released:
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.chunks = append(f.chunks, append([]byte(nil), data...))
+	return nil
+}
+
+// End implements repositories.SpeechToTextStreaming
+func (f *STTStream) End() (string, error) {
+	time.Sleep(f.Delay)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ended = true
+	if f.Transcript == "" {
+		return "", fmt.Errorf("fake transcription: %w", errs.ErrNoSpeech)
+	}
+	return f.Transcript, nil
+}
+
+// Confidence implements repositories.TranscriptionConfidence
+func (f *STTStream) Confidence() (float64, bool) {
+	return f.confidence, f.confidence > 0
+}
+
+// Close implements repositories.SpeechToTextStreaming
+func (f *STTStream) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.closed {
+		f.closed = true
+		close(f.released)
+	}
+	return nil
+}
+
+// Released reports whether the stream was ended or closed
+func (f *STTStream) Released() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ended || f.closed
+}
+
+// Chunks returns the audio streamed so far
+func (f *STTStream) Chunks() [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]byte(nil), f.chunks...)
+}
+
+// Ended reports whether End was called
+func (f *STTStream) Ended() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ended
+}
+
+// TTS returns the configured chunks for every synthesis request
+type TTS struct {
+	Chunks [][]byte
+	// Voices lists the voice IDs VoiceExists accepts, nil to accept any
+	Voices []string
+	// VoiceLanguages lists the base languages of the voices that
+	// VoiceSupportsLanguage knows, any other voice speaks any language
+	VoiceLanguages map[string][]string
+	// EchoText synthesizes every text as a single chunk holding the text, so
+	// that the audio of different texts can be told apart
+	EchoText bool
+	// Delays holds back the audio of the given texts, as a slow synthesis would
+	Delays map[string]time.Duration
+	// Failures fails the synthesis of the given texts
+	Failures map[string]error
+	// Endless synthesizes the given texts as Chunks repeated until the
+	// request is cancelled, as a runaway response would
+	Endless map[string]bool
+	// PCM is reported by OutputsPCM
+	PCM bool
+
+	mu          sync.Mutex
+	active      int
+	maxActive   int
+	texts       []string
+	chunkSizes  []int
+	languages   []string
+	voiceIDs    []string
+	sampleRates []int
+}
+
+// Ensure TTS implements the TextToSpeech interface
+var _ repositories.TextToSpeech = (*TTS)(nil)
+
+// OutputsPCM implements repositories.PCMReporter
+func (f *TTS) OutputsPCM() bool {
+	return f.PCM
+}
+
+// ConvertTextToSpeech implements repositories.TextToSpeech
+func (f *TTS) ConvertTextToSpeech(ctx context.Context, text string) (<-chan []byte, error) {
+	chunkSize, _ := repositories.ChunkSizeFromContext(ctx)
+	language, _ := repositories.LanguageFromContext(ctx)
+	voiceID, _ := repositories.VoiceIDFromContext(ctx)
+	sampleRate, _ := repositories.SampleRateFromContext(ctx)
+	f.mu.Lock()
+	f.texts = append(f.texts, text)
+	f.chunkSizes = append(f.chunkSizes, chunkSize)
+	f.languages = append(f.languages, language)
+	f.voiceIDs = append(f.voiceIDs, voiceID)
+	f.sampleRates = append(f.sampleRates, sampleRate)
+	f.mu.Unlock()
+
+	if err := f.Failures[text]; err != nil {
+		return nil, err
+	}
+	chunks := f.Chunks
+	if f.EchoText {
+		chunks = [][]byte{[]byte(text)}
+	}
+	if f.Endless[text] {
+		audioChan := make(chan []byte)
+		go func() {
+			defer close(audioChan)
+			for {
+				for _, chunk := range chunks {
+					select {
+					case audioChan <- chunk:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+		return audioChan, nil
+	}
+	delay := f.Delays[text]
+	if delay == 0 {
+		audioChan := make(chan []byte, len(chunks))
+		for _, chunk := range chunks {
+			audioChan <- chunk
+		}
+		close(audioChan)
+		return audioChan, nil
+	}
+
+	f.mu.Lock()
+	f.active++
+	f.maxActive = max(f.maxActive, f.active)
+	f.mu.Unlock()
+	audioChan := make(chan []byte, len(chunks))
+	go func() {
+		defer close(audioChan)
+		defer func() {
+			f.mu.Lock()
+			f.active--
+			f.mu.Unlock()
+		}()
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		for _, chunk := range chunks {
+			audioChan <- chunk
+		}
+	}()
+	return audioChan, nil
+}
+
+// MaxConcurrent returns the most delayed syntheses that were in progress at
+// once
+func (f *TTS) MaxConcurrent() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.maxActive
+}
+
+// VoiceExists implements repositories.VoiceValidator
+func (f *TTS) VoiceExists(ctx context.Context, voiceID string) (bool, error) {
+	if f.Voices == nil {
+		return true, nil
+	}
+	for _, id := range f.Voices {
+		if id == voiceID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// VoiceSupportsLanguage implements repositories.VoiceLanguageValidator
+func (f *TTS) VoiceSupportsLanguage(ctx context.Context, voiceID, language string) (bool, error) {
+	languages, ok := f.VoiceLanguages[voiceID]
+	if !ok {
+		return true, nil
+	}
+	return entities.Voice{ID: voiceID, Languages: languages}.SupportsLanguage(language), nil
+}
+
+// Texts returns every text synthesized so far
+func (f *TTS) Texts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.texts...)
+}
+
+// ChunkSizes returns the chunk size requested for every synthesis so far,
+// 0 when the default was used
+func (f *TTS) ChunkSizes() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int(nil), f.chunkSizes...)
+}
+
+// Languages returns the language requested for every synthesis so far
+func (f *TTS) Languages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.languages...)
+}
+
+// VoiceIDs returns the custom voice requested for every synthesis so far,
+// empty when the default voice was used
+func (f *TTS) VoiceIDs() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.voiceIDs...)
+}
+
+// SampleRates returns the sample rate requested for every synthesis so far,
+// 0 when the default was used
+func (f *TTS) SampleRates() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int(nil), f.sampleRates...)
+}
+
+// LLM creates ChatSession instances replying with a fixed text
+type LLM struct {
+	Reply string
+	// Model is reported by ModelName
+	Model string
+	// Names reported by DetectNames wherever they occur in the text
+	Names []string
+	// Summary answered by SummarizeForParent, which fails with SummaryErr
+	// when it is set
+	Summary    entities.ParentSummary
+	SummaryErr error
+	// ContextSummary answered by SummarizeContext, which fails with
+	// ContextSummaryErr when it is set
+	ContextSummary    string
+	ContextSummaryErr error
+	// LearnedWords answered by ExtractLearnedWords for every turn
+	LearnedWords []entities.LearnedWord
+	// ChatErr fails every message sent to the chat sessions when set
+	ChatErr error
+	// Unhealthy makes the chat sessions report themselves unhealthy, as one
+	// answering a failed request with a fallback would
+	Unhealthy bool
+
+	mu         sync.Mutex
+	summarized [][]entities.Message
+	contexts   []ContextSummaryCall
+	extracted  []ExtractionCall
+	practiced  [][]string
+	histories  [][]entities.Message
+	seeds      []string
+	ages       []int
+	topics     [][]string
+	messages   []string
+	localTimes []time.Time
+	languages  []string
+	dayTopics  []string
+	rephrases  []string
+	resumes    []string
+	segmented  []bool
+	degraded   []repositories.DegradationLevel
+}
+
+// Ensure LLM implements the LargeLanguageModel and NameDetector interfaces
+var (
+	_ repositories.LargeLanguageModel = (*LLM)(nil)
+	_ repositories.NameDetector       = (*LLM)(nil)
+	_ repositories.ModelNamer         = (*LLM)(nil)
+	_ repositories.ParentSummarizer   = (*LLM)(nil)
+	_ repositories.ContextSummarizer  = (*LLM)(nil)
+	_ repositories.WordExtractor      = (*LLM)(nil)
+)
+
+// ContextSummaryCall is a call of SummarizeContext
+type ContextSummaryCall struct {
+	Summary  string
+	Messages []entities.Message
+}
+
+// ExtractionCall is a call of ExtractLearnedWords
+type ExtractionCall struct {
+	Child entities.Message
+	Doll  entities.Message
+	Known []string
+}
+
+// GenerateChat implements repositories.LargeLanguageModel
+func (f *LLM) GenerateChat(ctx context.Context, history []entities.Message) (repositories.ChatSession, error) {
+	age, ok := repositories.ChildAgeFromContext(ctx)
+	if !ok {
+		age = -1
+	}
+	f.mu.Lock()
+	f.ages = append(f.ages, age)
+	f.topics = append(f.topics, repositories.DiscouragedTopicsFromContext(ctx))
+	f.histories = append(f.histories, append([]entities.Message(nil), history...))
+	seed, _ := repositories.ContextSummaryFromContext(ctx)
+	f.seeds = append(f.seeds, seed)
+	f.practiced = append(f.practiced, repositories.LearnedWordsFromContext(ctx))
+	f.mu.Unlock()
+	return &ChatSession{Reply: f.Reply, history: append([]entities.Message(nil), history...), llm: f}, nil
+}
+
+// ModelName implements repositories.ModelNamer
+func (f *LLM) ModelName() string {
+	return f.Model
+}
+
+// DetectNames implements repositories.NameDetector
+func (f *LLM) DetectNames(ctx context.Context, text string) ([]string, error) {
+	var names []string
+	for _, name := range f.Names {
+		if strings.Contains(text, name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// SummarizeForParent implements repositories.ParentSummarizer
+func (f *LLM) SummarizeForParent(ctx context.Context, messages []entities.Message) (*entities.ParentSummary, error) {
+	f.mu.Lock()
+	f.summarized = append(f.summarized, append([]entities.Message(nil), messages...))
+	f.mu.Unlock()
+	if f.SummaryErr != nil {
+		return nil, f.SummaryErr
+	}
+	summary := f.Summary
+	return &summary, nil
+}
+
+// Summarized returns the messages passed to every SummarizeForParent call
+func (f *LLM) Summarized() [][]entities.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]entities.Message(nil), f.summarized...)
+}
+
+// SummarizeContext implements repositories.ContextSummarizer
+func (f *LLM) SummarizeContext(ctx context.Context, summary string, messages []entities.Message) (string, error) {
+	f.mu.Lock()
+	f.contexts = append(f.contexts, ContextSummaryCall{Summary: summary, Messages: append([]entities.Message(nil), messages...)})
+	f.mu.Unlock()
+	if f.ContextSummaryErr != nil {
+		return "", f.ContextSummaryErr
+	}
+	return f.ContextSummary, nil
+}
+
+// ExtractLearnedWords implements repositories.WordExtractor
+func (f *LLM) ExtractLearnedWords(ctx context.Context, child, doll entities.Message, known []string) ([]entities.LearnedWord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.extracted = append(f.extracted, ExtractionCall{Child: child, Doll: doll, Known: append([]string(nil), known...)})
+	return append([]entities.LearnedWord(nil), f.LearnedWords...), nil
+}
+
+// Extractions returns every ExtractLearnedWords call
+func (f *LLM) Extractions() []ExtractionCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]ExtractionCall(nil), f.extracted...)
+}
+
+// Practiced returns the learned words passed to every GenerateChat call
+func (f *LLM) Practiced() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]string(nil), f.practiced...)
+}
+
+// FailChats sets ChatErr and Unhealthy while chat sessions may be in use
+func (f *LLM) FailChats(err error, unhealthy bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ChatErr, f.Unhealthy = err, unhealthy
+}
+
+// ContextSummaries returns every SummarizeContext call
+func (f *LLM) ContextSummaries() []ContextSummaryCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]ContextSummaryCall(nil), f.contexts...)
+}
+
+// Histories returns the history passed to every GenerateChat call
+func (f *LLM) Histories() [][]entities.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]entities.Message(nil), f.histories...)
+}
+
+// Seeds returns the context summary passed to every GenerateChat call, empty
+// for none
+func (f *LLM) Seeds() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.seeds...)
+}
+
+// Ages returns the child age passed to every GenerateChat call, -1 when unknown
+func (f *LLM) Ages() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int(nil), f.ages...)
+}
+
+// Topics returns the discouraged topics passed to every GenerateChat call
+func (f *LLM) Topics() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]string(nil), f.topics...)
+}
+
+// Messages returns the content of every message sent to the chat sessions
+func (f *LLM) Messages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.messages...)
+}
+
+// LocalTimes returns the child's local time passed with every message sent
+// to the chat sessions, zero when none was
+func (f *LLM) LocalTimes() []time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]time.Time(nil), f.localTimes...)
+}
+
+// ReplyLanguages returns the reply language passed with every message sent
+// to the chat sessions, empty when none was
+func (f *LLM) ReplyLanguages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.languages...)
+}
+
+// DailyTopics returns the topic of the day passed with every message sent to
+// the chat sessions, empty when none was
+func (f *LLM) DailyTopics() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.dayTopics...)
+}
+
+// Rephrases returns the previous response asked to be explained again with
+// every message sent to the chat sessions, empty when none was
+func (f *LLM) Rephrases() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.rephrases...)
+}
+
+// ResumeTopics returns the topic the child was welcomed back to with every
+// message sent to the chat sessions, empty when none was
+func (f *LLM) ResumeTopics() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.resumes...)
+}
+
+// Segmentations reports whether a response segmented by intent was asked
+// for with every message sent to the chat sessions
+func (f *LLM) Segmentations() []bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]bool(nil), f.segmented...)
+}
+
+// Degradations returns the degradation level of every message sent to the
+// chat sessions
+func (f *LLM) Degradations() []repositories.DegradationLevel {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]repositories.DegradationLevel(nil), f.degraded...)
+}
+
+// ChatSession replies with a fixed text and records the history
+type ChatSession struct {
+	Reply string
+
+	mu      sync.Mutex
+	history []entities.Message
+	llm     *LLM
+}
+
+// Ensure ChatSession implements the StreamingChatSession and
+// ChatSessionHealth interfaces
+var (
+	_ repositories.StreamingChatSession = (*ChatSession)(nil)
+	_ repositories.ChatSessionHealth    = (*ChatSession)(nil)
+)
+
+// SendMessage implements repositories.ChatSession
+func (f *ChatSession) SendMessage(ctx context.Context, message entities.Message) (entities.Message, error) {
+	if err := f.chatErr(); err != nil {
+		return entities.Message{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.recordLocked(ctx, message), nil
+}
+
+// SendMessageStream implements repositories.StreamingChatSession by passing
+// the reply on word by word
+func (f *ChatSession) SendMessageStream(ctx context.Context, message entities.Message, onText func(text string)) (entities.Message, error) {
+	if err := f.chatErr(); err != nil {
+		return entities.Message{}, err
+	}
+	f.mu.Lock()
+	response := f.recordLocked(ctx, message)
+	f.mu.Unlock()
+	for _, word := range strings.SplitAfter(response.Content, " ") {
+		onText(word)
+	}
+	return response, nil
+}
+
+// Healthy implements repositories.ChatSessionHealth
+func (f *ChatSession) Healthy() bool {
+	if f.llm == nil {
+		return true
+	}
+	f.llm.mu.Lock()
+	defer f.llm.mu.Unlock()
+	return !f.llm.Unhealthy
+}
+
+// chatErr returns the error every message sent fails with, if any
+func (f *ChatSession) chatErr() error {
+	if f.llm == nil {
+		return nil
+	}
+	f.llm.mu.Lock()
+	defer f.llm.mu.Unlock()
+	return f.llm.ChatErr
+}
+
+// recordLocked records message and its reply in the history and returns the
+// reply. The caller must hold the mutex.
+func (f *ChatSession) recordLocked(ctx context.Context, message entities.Message) entities.Message {
+	response := entities.Message{Role: entities.DollRole, Content: f.Reply}
+	f.history = append(f.history, message, response)
+	if f.llm != nil {
+		f.llm.mu.Lock()
+		f.llm.messages = append(f.llm.messages, message.Content)
+		localTime, _ := repositories.LocalTimeFromContext(ctx)
+		f.llm.localTimes = append(f.llm.localTimes, localTime)
+		language, _ := repositories.ReplyLanguageFromContext(ctx)
+		f.llm.languages = append(f.llm.languages, language)
+		topic, _ := repositories.DailyTopicFromContext(ctx)
+		f.llm.dayTopics = append(f.llm.dayTopics, topic)
+		previous, _ := repositories.RephraseFromContext(ctx)
+		f.llm.rephrases = append(f.llm.rephrases, previous)
+		resume, _ := repositories.ResumeTopicFromContext(ctx)
+		f.llm.resumes = append(f.llm.resumes, resume)
+		f.llm.segmented = append(f.llm.segmented, repositories.IntentSegmentationFromContext(ctx))
+		f.llm.degraded = append(f.llm.degraded, repositories.DegradationFromContext(ctx))
+		f.llm.mu.Unlock()
+	}
+	return response
+}
+
+// History implements repositories.ChatSession
+func (f *ChatSession) History() ([]entities.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]entities.Message(nil), f.history...), nil
+}
+
+// SessionRepository keeps sessions in memory. Like a database, it stores and
+// returns copies, so callers never share a session instance.
+type SessionRepository struct {
+	// Conflicts is the number of upcoming Create calls failing with
+	// repositories.ErrActiveSessionExists, each as if a stale active session
+	// of the device had been stored just before
+	Conflicts int
+
+	mu          sync.Mutex
+	sessions    []*entities.Session
+	nextID      int
+	unavailable bool
+}
+
+// errUnavailable is returned by every call while the repository is unavailable
+var errUnavailable = errors.New("session storage unavailable")
+
+// SetUnavailable makes every Create, GetLastByDeviceID, GetLastByChildID and Update
+// call fail until it is called again with false
+func (f *SessionRepository) SetUnavailable(unavailable bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unavailable = unavailable
+}
+
+// Ensure SessionRepository implements the SessionRepository interface
+var _ repositories.SessionRepository = (*SessionRepository)(nil)
+
+// Ensure SessionRepository implements the ChildSessionFinder interface
+var _ repositories.ChildSessionFinder = (*SessionRepository)(nil)
+
+// Ensure SessionRepository implements the ChildSessionEraser interface
+var _ repositories.ChildSessionEraser = (*SessionRepository)(nil)
+
+// Create implements repositories.SessionRepository
+func (f *SessionRepository) Create(ctx context.Context, session *entities.Session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.unavailable {
+		return errUnavailable
+	}
+	if f.Conflicts > 0 {
+		f.Conflicts--
+		f.nextID++
+		f.sessions = append(f.sessions, &entities.Session{
+			ID:            "session-" + strconv.Itoa(f.nextID),
+			DeviceID:      session.DeviceID,
+			LastMessageAt: time.Now().Add(-time.Hour),
+		})
+		return fmt.Errorf("failed to create session: %w", repositories.ErrActiveSessionExists)
+	}
+	if session.ID == "" {
+		f.nextID++
+		session.ID = "session-" + strconv.Itoa(f.nextID)
+	}
+	if session.LastMessageAt.IsZero() {
+		session.LastMessageAt = time.Now()
+	}
+	f.sessions = append(f.sessions, copySession(session))
+	return nil
+}
+
+// GetLastByDeviceID implements repositories.SessionRepository
+func (f *SessionRepository) GetLastByDeviceID(ctx context.Context, deviceID string) (*entities.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.unavailable {
+		return nil, errUnavailable
+	}
+	for i := len(f.sessions) - 1; i >= 0; i-- {
+		if f.sessions[i].DeviceID == deviceID {
+			return copySession(f.sessions[i]), nil
+		}
+	}
+	return nil, nil
+}
+
+// GetLastByChildID implements repositories.ChildSessionFinder
+func (f *SessionRepository) GetLastByChildID(ctx context.Context, childID string) (*entities.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.unavailable {
+		return nil, errUnavailable
+	}
+	var last *entities.Session
+	for _, session := range f.sessions {
+		if session.ChildID == childID && (last == nil || !session.LastMessageAt.Before(last.LastMessageAt)) {
+			last = session
+		}
+	}
+	if last == nil {
+		return nil, nil
+	}
+	return copySession(last), nil
+}
+
+// Update implements repositories.SessionRepository
+func (f *SessionRepository) Update(ctx context.Context, session *entities.Session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.unavailable {
+		return errUnavailable
+	}
+	for i, stored := range f.sessions {
+		if stored.ID == session.ID {
+			f.sessions[i] = copySession(session)
+			return nil
+		}
+	}
+	return fmt.Errorf("session %q: %w", session.ID, errs.ErrSessionNotFound)
+}
+
+// DeleteByDeviceID implements repositories.SessionRepository
+func (f *SessionRepository) DeleteByDeviceID(ctx context.Context, deviceID string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var kept []*entities.Session
+	var deleted int64
+	for _, session := range f.sessions {
+		if session.DeviceID == deviceID {
+			deleted++
+			continue
+		}
+		kept = append(kept, session)
+	}
+	f.sessions = kept
+	return deleted, nil
+}
+
+// DeleteByChildID implements repositories.ChildSessionEraser
+func (f *SessionRepository) DeleteByChildID(ctx context.Context, childID string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var kept []*entities.Session
+	var deleted int64
+	for _, session := range f.sessions {
+		if session.ChildID == childID {
+			deleted++
+			continue
+		}
+		kept = append(kept, session)
+	}
+	f.sessions = kept
+	return deleted, nil
+}
+
+// Search implements repositories.SessionRepository
+func (f *SessionRepository) Search(ctx context.Context, deviceID, query string, limit int) ([]entities.MessageMatch, error) {
+	terms, err := entities.SearchTerms(query)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var matches []entities.MessageMatch
+	for i := len(f.sessions) - 1; i >= 0 && len(matches) < limit; i-- {
+		if f.sessions[i].DeviceID != deviceID {
+			continue
+		}
+		matches = append(matches, f.sessions[i].SearchMessages(terms)...)
+	}
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// ListByDeviceID implements repositories.SessionRepository
+func (f *SessionRepository) ListByDeviceID(ctx context.Context, deviceID string, limit int) ([]*entities.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.unavailable {
+		return nil, errUnavailable
+	}
+	var sessions []*entities.Session
+	for i := len(f.sessions) - 1; i >= 0 && len(sessions) < limit; i-- {
+		if f.sessions[i].DeviceID == deviceID {
+			sessions = append(sessions, copySession(f.sessions[i]))
+		}
+	}
+	return sessions, nil
+}
+
+// ExpireSessions implements repositories.SessionRepository
+func (f *SessionRepository) ExpireSessions(ctx context.Context, cutoff time.Time) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var expired int64
+	now := time.Now()
+	for _, session := range f.sessions {
+		if session.Expire(cutoff, now) {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// Sessions returns a copy of every stored session
+func (f *SessionRepository) Sessions() []*entities.Session {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sessions := make([]*entities.Session, len(f.sessions))
+	for i, session := range f.sessions {
+		sessions[i] = copySession(session)
+	}
+	return sessions
+}
+
+func copySession(session *entities.Session) *entities.Session {
+	sessionCopy := *session
+	sessionCopy.Messages = append([]entities.Message(nil), session.Messages...)
+	return &sessionCopy
+}
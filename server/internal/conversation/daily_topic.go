@@ -0,0 +1,96 @@
+package conversation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// DailyTopic is a theme the doll suggests on the days it is chosen. Topics
+// rotate daily through those suiting the child's age.
+type DailyTopic struct {
+	// Topic names the theme in the default language, e.g. "planet-planet"
+	Topic string `json:"topic"`
+	// Localized names the theme by BCP-47 language, e.g. {"en-US": "planets"}
+	Localized map[string]string `json:"localized,omitempty"`
+	// Ages the topic suits, inclusive; 0 leaves a bound open
+	MinAge int `json:"min_age,omitempty"`
+	MaxAge int `json:"max_age,omitempty"`
+}
+
+// ParseDailyTopics parses daily topics encoded as a JSON array, e.g.
+// [{"topic": "planet-planet", "localized": {"en-US": "planets"}, "min_age": 4}]
+func ParseDailyTopics(data string) ([]DailyTopic, error) {
+	var topics []DailyTopic
+	if err := json.Unmarshal([]byte(data), &topics); err != nil {
+		return nil, fmt.Errorf("failed to parse daily topics: %w", err)
+	}
+	for _, topic := range topics {
+		if strings.TrimSpace(topic.Topic) == "" {
+			return nil, fmt.Errorf("daily topic must have a name")
+		}
+		if topic.MinAge < 0 || (topic.MaxAge > 0 && topic.MaxAge < topic.MinAge) {
+			return nil, fmt.Errorf("daily topic %q has an invalid age range", topic.Topic)
+		}
+	}
+	return topics, nil
+}
+
+// suits reports whether the topic suits a child of age. Topics limited to
+// some ages never suit a child of unknown age.
+func (t DailyTopic) suits(age int, ageKnown bool) bool {
+	if !ageKnown {
+		return t.MinAge == 0 && t.MaxAge == 0
+	}
+	return age >= t.MinAge && (t.MaxAge == 0 || age <= t.MaxAge)
+}
+
+// name returns the name of the topic in language, falling back to another
+// region of the language and then to the default name
+func (t DailyTopic) name(language string) string {
+	if name, ok := t.Localized[language]; ok {
+		return name
+	}
+	base, _, _ := strings.Cut(language, "-")
+	if base == "" {
+		return t.Topic
+	}
+	for tag, name := range t.Localized {
+		if tagBase, _, _ := strings.Cut(tag, "-"); strings.EqualFold(tagBase, base) {
+			return name
+		}
+	}
+	return t.Topic
+}
+
+// dailyTopicFor returns the topic of the local date of day among those
+// suiting the child, or nil when none does. Every date picks the next topic,
+// so that consecutive days never repeat one while there are several.
+func dailyTopicFor(topics []DailyTopic, day time.Time, age int, ageKnown bool) *DailyTopic {
+	var suitable []*DailyTopic
+	for i := range topics {
+		if topics[i].suits(age, ageKnown) {
+			suitable = append(suitable, &topics[i])
+		}
+	}
+	if len(suitable) == 0 {
+		return nil
+	}
+	// Days since the epoch of the local date, whatever the timezone
+	days := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC).Unix() / int64(24*time.Hour/time.Second)
+	return suitable[days%int64(len(suitable))]
+}
+
+// dailyTopic returns the name of today's topic in the child's language and
+// timezone, empty when no topic suits the child.
+// The caller must hold the mutex.
+func (c *Conversation) dailyTopic(session *entities.Session) string {
+	topic := dailyTopicFor(c.engine.config.DailyTopics, c.localTime(), c.childAge, c.childAgeKnown)
+	if topic == nil {
+		return ""
+	}
+	return topic.name(c.language(session))
+}
@@ -0,0 +1,90 @@
+package conversation
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestDailyTopicFor(t *testing.T) {
+	topics, err := ParseDailyTopics(`[
+		{"topic": "planet-planet", "localized": {"en-US": "planets"}},
+		{"topic": "dinosaurus", "min_age": 6},
+		{"topic": "warna", "max_age": 5}
+	]`)
+	if err != nil {
+		t.Fatalf("Failed to parse topics: %v", err)
+	}
+	monday := time.Date(2024, time.March, 4, 8, 0, 0, 0, time.UTC)
+
+	// An unknown age leaves only the topics for every age
+	for day := 0; day < 3; day++ {
+		if topic := dailyTopicFor(topics, monday.AddDate(0, 0, day), 0, false); topic == nil || topic.Topic != "planet-planet" {
+			t.Errorf("Day %d: expected the topic for every age, got %v", day, topic)
+		}
+	}
+
+	// Consecutive days rotate through the topics suiting the child
+	var rotation []string
+	for day := 0; day < 4; day++ {
+		rotation = append(rotation, dailyTopicFor(topics, monday.AddDate(0, 0, day), 7, true).Topic)
+	}
+	if rotation[0] == rotation[1] || rotation[0] != rotation[2] || rotation[1] != rotation[3] || slices.Contains(rotation, "warna") {
+		t.Errorf("Expected the two topics for a 7 year old to alternate, got %q", rotation)
+	}
+
+	// The date is the child's, not UTC's
+	jayapura := time.FixedZone("WIT", 9*60*60)
+	lateMonday := time.Date(2024, time.March, 4, 20, 0, 0, 0, time.UTC)
+	if dailyTopicFor(topics, lateMonday, 7, true) == dailyTopicFor(topics, lateMonday.In(jayapura), 7, true) {
+		t.Error("Expected Tuesday in Jayapura to have the next topic")
+	}
+
+	if got := topics[0].name("en-GB"); got != "planets" {
+		t.Errorf("Expected another region of the language, got %q", got)
+	}
+	if got := topics[0].name("id-ID"); got != "planet-planet" {
+		t.Errorf("Expected the default name, got %q", got)
+	}
+
+	if _, err := ParseDailyTopics(`[{"topic": "warna", "min_age": 6, "max_age": 4}]`); err == nil {
+		t.Error("Expected an invalid age range to be refused")
+	}
+}
+
+func TestEngine_PassesDailyTopicToLLM(t *testing.T) {
+	topics := []DailyTopic{
+		{Topic: "planet-planet", Localized: map[string]string{"en-US": "planets"}},
+		{Topic: "dinosaurus", Localized: map[string]string{"en-US": "dinosaurs"}},
+	}
+	f := newEngineFixture(t, Config{DailyTopics: topics, DefaultTimezone: "UTC"}, &conversationtest.STT{Transcript: "halo"})
+	now := time.Date(2024, time.March, 4, 8, 0, 0, 0, time.UTC)
+	f.engine.now = func() time.Time { return now }
+
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	runTurn(t, conv, sink)
+	now = now.AddDate(0, 0, 1)
+	runTurn(t, conv, sink)
+
+	got := f.llm.DailyTopics()
+	if len(got) != 2 || got[0] == "" || got[1] == "" || got[0] == got[1] {
+		t.Fatalf("Expected a different topic on each day, got %q", got)
+	}
+
+	// A child locked to English hears the English name
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-2"}, ForcedLanguage: "en-US"}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	english := f.engine.NewConversation("device-2", sink)
+	runTurn(t, english, sink)
+	want := dailyTopicFor(topics, now, 0, false).Localized["en-US"]
+	if got := f.llm.DailyTopics(); got[2] != want {
+		t.Errorf("Expected today's topic in English, %q, got %q", want, got[2])
+	}
+}
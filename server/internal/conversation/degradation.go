@@ -0,0 +1,52 @@
+package conversation
+
+import (
+	"context"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+	"github.com/satriahrh/arunika/server/internal/degradation"
+)
+
+// severeResponseTimeout bounds a response while conversations are severely
+// degraded, instead of responseTimeout
+const severeResponseTimeout = 20 * time.Second
+
+// Dependencies observed by the degradation controller
+const (
+	dependencyLLM = "llm"
+	dependencyTTS = "tts"
+)
+
+// SetDegradation has the responses of conversations observed by controller,
+// and cut back at the degradation level it derives from them: a degraded
+// response is generated by the fallback model without the filler, and a
+// severely degraded one is given up on sooner.
+func (e *Engine) SetDegradation(controller *degradation.Controller) {
+	e.degradation = controller
+}
+
+// degradationLevel returns the level responses are degraded at
+func (e *Engine) degradationLevel() repositories.DegradationLevel {
+	if e.degradation == nil {
+		return repositories.DegradationNone
+	}
+	return e.degradation.Level()
+}
+
+// observe records a call to dependency for the degradation controller. Calls
+// of a cancelled response say nothing about the dependency.
+func (e *Engine) observe(ctx context.Context, dependency string, latency time.Duration, err error) {
+	if e.degradation == nil || ctx.Err() != nil {
+		return
+	}
+	e.degradation.Observe(dependency, latency, err)
+}
+
+// responseTimeout returns how long a response starting now may take
+func (e *Engine) responseTimeout() time.Duration {
+	if e.degradationLevel() == repositories.DegradationSevere {
+		return severeResponseTimeout
+	}
+	return responseTimeout
+}
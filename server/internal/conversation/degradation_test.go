@@ -0,0 +1,84 @@
+package conversation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/degradation"
+)
+
+func TestDegradation_FailingSynthesisDegradesResponses(t *testing.T) {
+	config := Config{FillerEnabled: true, FillerDelay: 10 * time.Millisecond}
+	f := newEngineFixture(t, config, &conversationtest.STT{Transcript: "halo", Delay: 100 * time.Millisecond})
+	controller, err := degradation.NewController(degradation.DegradationConfig{PartialFailures: 2, SevereFailures: 4}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create controller: %v", err)
+	}
+	f.engine.SetDegradation(controller)
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	if events := sink.until(t, EventSpeakingEnd); indexOf(events, EventFillerStart) == -1 {
+		t.Fatalf("Expected the filler while healthy, got %v", eventTypes(events))
+	}
+
+	f.tts.Failures = map[string]error{f.llm.Reply: errors.New("synthesis unavailable")}
+	for range 2 {
+		speak(t, conv, sink)
+		sink.until(t, EventError)
+	}
+	if level := controller.Level(); level != repositories.DegradationPartial {
+		t.Fatalf("Expected failing syntheses to degrade responses, got %s", level)
+	}
+
+	f.tts.Failures = nil
+	speak(t, conv, sink)
+	if events := sink.until(t, EventSpeakingEnd); indexOf(events, EventFillerStart) != -1 {
+		t.Errorf("Expected no filler while degraded, got %v", eventTypes(events))
+	}
+	want := []repositories.DegradationLevel{
+		repositories.DegradationNone,
+		repositories.DegradationNone,
+		repositories.DegradationNone,
+		repositories.DegradationPartial,
+	}
+	got := f.llm.Degradations()
+	if len(got) != len(want) {
+		t.Fatalf("Expected degradation levels %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected message %d at level %s, got %s", i, want[i], got[i])
+		}
+	}
+	if level := controller.Level(); level != repositories.DegradationNone {
+		t.Errorf("Expected a successful synthesis to recover, got %s", level)
+	}
+}
+
+func TestDegradation_SevereShortensResponseTimeout(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	if timeout := f.engine.responseTimeout(); timeout != responseTimeout {
+		t.Errorf("Expected %s without a controller, got %s", responseTimeout, timeout)
+	}
+
+	controller, err := degradation.NewController(degradation.DegradationConfig{PartialFailures: 1, SevereFailures: 2}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create controller: %v", err)
+	}
+	f.engine.SetDegradation(controller)
+	controller.Observe(dependencyLLM, time.Second, errors.New("unavailable"))
+	if timeout := f.engine.responseTimeout(); timeout != responseTimeout {
+		t.Errorf("Expected %s while partially degraded, got %s", responseTimeout, timeout)
+	}
+	controller.Observe(dependencyLLM, time.Second, errors.New("unavailable"))
+	if timeout := f.engine.responseTimeout(); timeout != severeResponseTimeout {
+		t.Errorf("Expected %s while severely degraded, got %s", severeResponseTimeout, timeout)
+	}
+}
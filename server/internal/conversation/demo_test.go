@@ -0,0 +1,33 @@
+package conversation
+
+import (
+	"testing"
+
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestEngine_DemoModeSpeaksDemoPhrase(t *testing.T) {
+	f := newEngineFixture(t, Config{DemoMode: true}, &conversationtest.STT{Transcript: "halo boneka"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	events := sink.until(t, EventSpeakingEnd)
+
+	speaking := events[indexOf(events, EventSpeakingStart)]
+	if speaking.Message == nil || speaking.Message.Content != defaultDemoPhrase {
+		t.Fatalf("Expected the demo phrase instead of the LLM reply, got %+v", speaking.Message)
+	}
+	audio := 0
+	for _, event := range events {
+		if event.Type == EventAudio && len(event.Audio) > 0 {
+			audio++
+		}
+	}
+	if audio != 2 {
+		t.Errorf("Expected the demo phrase to be synthesized into 2 audio chunks, got %d", audio)
+	}
+	if texts := f.tts.Texts(); len(texts) != 1 || texts[0] != defaultDemoPhrase {
+		t.Errorf("Expected only the demo phrase synthesized, got %v", texts)
+	}
+}
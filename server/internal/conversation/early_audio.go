@@ -0,0 +1,77 @@
+package conversation
+
+import "go.uber.org/zap"
+
+// Firmware may stream the child's first words slightly before its
+// listening_start reaches the server, or while the speech-to-text stream of
+// the start is still being set up. Audio received while no listening session
+// streams is kept, up to Config.EarlyAudioMax bytes with the oldest dropped
+// first, and streamed at the start of the next listening session, after any
+// pre-roll the transport passed along. A listening start that is refused
+// discards it.
+
+// earlyAudioSampleSize is the size of a LINEAR16 mono sample, so that
+// dropping the oldest audio keeps it aligned
+const earlyAudioSampleSize = 2
+
+// bufferEarlyAudio keeps audio received while not listening for the next
+// listening session. It reports false when early audio is dropped instead.
+// The caller must hold the mutex.
+func (c *Conversation) bufferEarlyAudio(audio []byte) bool {
+	limit := c.engine.config.EarlyAudioMax
+	if limit <= 0 {
+		return false
+	}
+
+	if len(audio) >= limit {
+		c.earlyAudioDropped += len(c.earlyAudio) + len(audio) - limit
+		c.earlyAudio = append(c.earlyAudio[:0], audio[len(audio)-limit:]...)
+		return true
+	}
+	if overflow := len(c.earlyAudio) + len(audio) - limit; overflow > 0 {
+		overflow += overflow % earlyAudioSampleSize
+		c.earlyAudioDropped += overflow
+		c.earlyAudio = append(c.earlyAudio[:0], c.earlyAudio[overflow:]...)
+	}
+	c.earlyAudio = append(c.earlyAudio, audio...)
+	c.logger.Debug("Keeping audio received before listening started",
+		zap.String("deviceID", c.deviceID),
+		zap.Int("size", len(audio)),
+		zap.Int("buffered", len(c.earlyAudio)))
+	return true
+}
+
+// takeEarlyAudio returns the audio kept for the next listening session and
+// starts a new buffer.
+// The caller must hold the mutex.
+func (c *Conversation) takeEarlyAudio() []byte {
+	early, dropped := c.earlyAudio, c.earlyAudioDropped
+	c.earlyAudio, c.earlyAudioDropped = nil, 0
+	if dropped > 0 {
+		c.logger.Info("Dropped the oldest audio received before listening started",
+			zap.String("deviceID", c.deviceID),
+			zap.Int("dropped", dropped),
+			zap.Int("earlyAudioMax", c.engine.config.EarlyAudioMax))
+	}
+	return early
+}
+
+// streamEarlyAudio streams the audio received before the listening session
+// started into its speech-to-text stream.
+// The caller must hold the mutex.
+func (c *Conversation) streamEarlyAudio(early []byte) {
+	if len(early) == 0 || c.sttStreaming == nil {
+		return
+	}
+	if err := c.sttStreaming.Stream(early); err != nil {
+		c.logger.Warn("Failed to stream audio received before listening started",
+			zap.String("sessionID", c.session.ID),
+			zap.Error(err))
+		return
+	}
+	c.record(early)
+	c.logger.Info("Streamed audio received before listening started",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", c.session.ID),
+		zap.Int("size", len(early)))
+}
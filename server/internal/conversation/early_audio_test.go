@@ -0,0 +1,92 @@
+package conversation
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// streamedAfterEarlyAudio runs a turn of conv whose audio is early before
+// listening started and live after, and returns the audio speech-to-text got
+func streamedAfterEarlyAudio(t *testing.T, f *engineFixture, conv *Conversation, sink *recorder, early ...[]byte) [][]byte {
+	t.Helper()
+	for _, audio := range early {
+		conv.StreamAudio(audio)
+	}
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	streams := f.stt.Streams()
+	return streams[len(streams)-1].Chunks()
+}
+
+func TestEngine_StreamsAudioReceivedBeforeListeningStarted(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	defer conv.Close()
+
+	chunks := streamedAfterEarlyAudio(t, f, conv, sink, []byte{0x03, 0x03}, []byte{0x04, 0x04})
+	want := [][]byte{{0x03, 0x03, 0x04, 0x04}, {0x01, 0x02}}
+	if len(chunks) != len(want) {
+		t.Fatalf("Expected chunks %v, got %v", want, chunks)
+	}
+	for i := range want {
+		if !bytes.Equal(chunks[i], want[i]) {
+			t.Errorf("Expected chunks %v, got %v", want, chunks)
+		}
+	}
+
+	// The early audio was streamed once
+	if chunks := streamedAfterEarlyAudio(t, f, conv, sink); len(chunks) != 1 {
+		t.Errorf("Expected only the live audio of the next turn, got %v", chunks)
+	}
+}
+
+func TestEngine_EarlyAudioDropsOldestBeyondItsLimit(t *testing.T) {
+	f := newEngineFixture(t, Config{EarlyAudioMax: 4}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	defer conv.Close()
+
+	chunks := streamedAfterEarlyAudio(t, f, conv, sink, []byte{0x03, 0x03}, []byte{0x04, 0x04}, []byte{0x05, 0x05})
+	if len(chunks) != 2 || !bytes.Equal(chunks[0], []byte{0x04, 0x04, 0x05, 0x05}) {
+		t.Errorf("Expected the most recent early audio, got %v", chunks)
+	}
+
+	chunks = streamedAfterEarlyAudio(t, f, conv, sink, []byte{0x06, 0x06, 0x07, 0x07, 0x08, 0x08})
+	if len(chunks) != 2 || !bytes.Equal(chunks[0], []byte{0x07, 0x07, 0x08, 0x08}) {
+		t.Errorf("Expected the end of an oversized chunk, got %v", chunks)
+	}
+}
+
+func TestEngine_DropsEarlyAudioWhenDisabled(t *testing.T) {
+	f := newEngineFixture(t, Config{EarlyAudioMax: -1}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	defer conv.Close()
+
+	if chunks := streamedAfterEarlyAudio(t, f, conv, sink, []byte{0x03, 0x03}); len(chunks) != 1 {
+		t.Errorf("Expected the early audio dropped, got %v", chunks)
+	}
+}
+
+func TestEngine_RefusedListeningStartDiscardsEarlyAudio(t *testing.T) {
+	stt := &conversationtest.STT{Transcript: "halo", InitErr: errors.New("unavailable")}
+	f := newEngineFixture(t, Config{}, stt)
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	defer conv.Close()
+
+	conv.StreamAudio([]byte{0x03, 0x03})
+	conv.StartListening(StartOptions{})
+	if event := sink.next(t, EventListeningStart); event.Error != ErrorTranscriptionUnavailable {
+		t.Fatalf("Expected the listening start refused, got %q", event.Error)
+	}
+
+	stt.InitErr = nil
+	if chunks := streamedAfterEarlyAudio(t, f, conv, sink); len(chunks) != 1 {
+		t.Errorf("Expected the early audio of the refused start discarded, got %v", chunks)
+	}
+}
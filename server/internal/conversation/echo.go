@@ -0,0 +1,56 @@
+package conversation
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// echoCoverageThreshold is the share of the spoken words a transcription must
+// repeat verbatim before it is treated as the doll hearing itself
+const echoCoverageThreshold = 0.5
+
+// withinDebounce reports whether a listening start arrives too soon after the
+// doll finished speaking to be genuine input
+func (c *Conversation) withinDebounce(now time.Time) bool {
+	if c.engine.config.ListeningDebounce <= 0 || c.lastSpeakingEnd.IsZero() {
+		return false
+	}
+	return now.Sub(c.lastSpeakingEnd) < c.engine.config.ListeningDebounce
+}
+
+// isEcho reports whether a transcription is the doll's own speech picked up
+// by its microphone rather than something the child said
+func isEcho(transcription, spoken string) bool {
+	heard := normalizeWords(transcription)
+	said := normalizeWords(spoken)
+	if len(heard) == 0 || len(said) == 0 {
+		return false
+	}
+
+	if float64(len(heard))/float64(len(said)) < echoCoverageThreshold {
+		return false
+	}
+
+	// The heard words must appear as a contiguous run of the spoken words
+	for start := 0; start+len(heard) <= len(said); start++ {
+		match := true
+		for i, word := range heard {
+			if said[start+i] != word {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeWords lowercases text and splits it into words without punctuation
+func normalizeWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
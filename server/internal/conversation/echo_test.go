@@ -0,0 +1,72 @@
+package conversation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func runTurn(t *testing.T, conv *Conversation, sink *recorder) {
+	t.Helper()
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+}
+
+func TestListeningDebounce(t *testing.T) {
+	config := Config{ListeningDebounce: 200 * time.Millisecond}
+	f := newEngineFixture(t, config, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	runTurn(t, conv, sink)
+
+	conv.StartListening(StartOptions{})
+	if event := sink.next(t, EventListeningStart); event.Error != "debounced" {
+		t.Errorf("Expected listening start within debounce window to be suppressed, got %q", event.Error)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+
+	conv.StartListening(StartOptions{})
+	if event := sink.next(t, EventListeningStart); event.Error != "" {
+		t.Errorf("Expected listening start after debounce window to be accepted, got %q", event.Error)
+	}
+}
+
+func TestEchoSuppression_DropsRepeatedResponse(t *testing.T) {
+	// The fake LLM replies "Halo juga!" and the microphone hears it back
+	f := newEngineFixture(t, Config{EchoSuppression: true}, &conversationtest.STT{Transcript: "halo juga"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	runTurn(t, conv, sink)
+
+	speak(t, conv, sink)
+	if event := sink.next(t, EventListeningEnd); event.Error != "echo_detected" {
+		t.Errorf("Expected echoed transcription to be dropped, got %q", event.Error)
+	}
+}
+
+func TestIsEcho(t *testing.T) {
+	tests := []struct {
+		name          string
+		transcription string
+		spoken        string
+		want          bool
+	}{
+		{"exact repeat", "Halo juga!", "Halo juga!", true},
+		{"tail of response", "mau dengar rahasia", "Wah hebat, mau dengar rahasia?", true},
+		{"short answer to question", "apel", "Bisa bilang apel?", false},
+		{"different sentence", "aku suka kucing", "Halo juga!", false},
+		{"nothing spoken yet", "halo", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isEcho(tt.transcription, tt.spoken); got != tt.want {
+				t.Errorf("isEcho(%q, %q) = %v, want %v", tt.transcription, tt.spoken, got, tt.want)
+			}
+		})
+	}
+}
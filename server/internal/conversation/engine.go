@@ -0,0 +1,1293 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/errs"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+	"github.com/satriahrh/arunika/server/internal/audit"
+	"github.com/satriahrh/arunika/server/internal/degradation"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// Engine runs the speech-to-text, LLM and text-to-speech pipeline
+// independently of the transport that carries audio and events.
+type Engine struct {
+	llm         repositories.LargeLanguageModel
+	ttsRepo     repositories.TextToSpeech
+	sttRepo     repositories.SpeechToText
+	sessionRepo repositories.SessionRepository
+	childRepo   repositories.ChildRepository
+
+	// Finds the sessions of children across their devices, nil unless
+	// Config.ChildScopedSessions is enabled and the storage can
+	sessionsByChild repositories.ChildSessionFinder
+
+	quietHoursRepo repositories.QuietHoursRepository
+
+	// Receives session lifecycle events
+	publisher events.Publisher
+
+	config Config
+
+	// Redacts transcriptions before they are stored, nil when disabled
+	redactor *piiRedactor
+
+	// Validates custom voices, nil when text-to-speech cannot
+	voices repositories.VoiceValidator
+	// Tells the languages custom voices speak, nil when text-to-speech cannot
+	voiceLanguages repositories.VoiceLanguageValidator
+	// Tells whether synthesized audio can be faded, nil when text-to-speech
+	// cannot
+	pcm repositories.PCMReporter
+
+	// Audio of the canned phrases, kept with Config.PrewarmPhrases
+	canned cannedAudio
+
+	// Keeps the audio of consenting children, nil when recording is disabled
+	recorder repositories.AudioRecorder
+	trail    audit.Trail
+
+	// Receives the records of the turns of consenting children
+	turnSink repositories.TurnSink
+
+	// Summarizes ended sessions for parents, nil when disabled
+	summarizer repositories.ParentSummarizer
+	// Summarizes the chat context of sessions reaching Config.MaxTurns, nil
+	// when disabled
+	contextSummarizer repositories.ContextSummarizer
+	// Picks the new words of the turns of consenting children, nil when
+	// disabled
+	wordExtractor repositories.WordExtractor
+
+	// Derives the degradation level of responses, nil when they never degrade
+	degradation *degradation.Controller
+
+	// Clock used to evaluate quiet hours and the time of day, replaced in tests
+	now func() time.Time
+	// Timezone of the children without one
+	location *time.Location
+
+	// Listening sessions of lost connections awaiting their device, by device ID
+	heldMu sync.Mutex
+	held   map[string]*heldListening
+
+	logger *zap.Logger
+}
+
+// NewEngine creates a new conversation engine
+func NewEngine(
+	config Config,
+	llm repositories.LargeLanguageModel,
+	ttsRepo repositories.TextToSpeech,
+	sttRepo repositories.SpeechToText,
+	sessionRepo repositories.SessionRepository,
+	childRepo repositories.ChildRepository,
+	quietHoursRepo repositories.QuietHoursRepository,
+	publisher events.Publisher,
+	logger *zap.Logger,
+) (*Engine, error) {
+	// A missing dependency fails now rather than on the first conversation
+	// reaching for it
+	switch {
+	case llm == nil:
+		return nil, errors.New("large language model is required")
+	case ttsRepo == nil:
+		return nil, errors.New("text-to-speech is required")
+	case sttRepo == nil:
+		return nil, errors.New("speech-to-text is required")
+	case sessionRepo == nil:
+		return nil, errors.New("session repository is required")
+	case childRepo == nil:
+		return nil, errors.New("child repository is required")
+	case quietHoursRepo == nil:
+		return nil, errors.New("quiet hours repository is required")
+	case publisher == nil:
+		return nil, errors.New("event publisher is required")
+	}
+
+	// Apply defaults where needed
+	if config.FillerDelay == 0 {
+		config.FillerDelay = defaultFillerDelay
+		logger.Info("Using default filler delay", zap.Duration("fillerDelay", config.FillerDelay))
+	}
+
+	if config.FillerPhrase == "" {
+		config.FillerPhrase = defaultFillerPhrase
+		logger.Info("Using default filler phrase", zap.String("fillerPhrase", config.FillerPhrase))
+	}
+
+	if config.DemoMode && config.DemoPhrase == "" {
+		config.DemoPhrase = defaultDemoPhrase
+		logger.Info("Using default demo phrase", zap.String("demoPhrase", config.DemoPhrase))
+	}
+
+	if config.OutputSampleRate == 0 {
+		config.OutputSampleRate = defaultOutputSampleRate
+		logger.Info("Using default output sample rate", zap.Int("outputSampleRate", config.OutputSampleRate))
+	}
+
+	if config.ResponseCache && config.ResponseCacheVariants == 0 {
+		config.ResponseCacheVariants = defaultResponseCacheVariants
+		logger.Info("Using default response cache variants", zap.Int("responseCacheVariants", config.ResponseCacheVariants))
+	}
+
+	if config.AudioBufferMax == 0 {
+		config.AudioBufferMax = defaultAudioBufferMax
+		logger.Info("Using default audio buffer max", zap.Int("audioBufferMax", config.AudioBufferMax))
+	}
+
+	if config.EarlyAudioMax == 0 {
+		config.EarlyAudioMax = defaultEarlyAudioMax
+		logger.Info("Using default early audio max", zap.Int("earlyAudioMax", config.EarlyAudioMax))
+	}
+
+	if config.ChatMaxFailures == 0 {
+		config.ChatMaxFailures = defaultChatMaxFailures
+		logger.Info("Using default chat max failures", zap.Int("chatMaxFailures", config.ChatMaxFailures))
+	}
+
+	if config.ResumeAcknowledgement && config.ResumeAcknowledgementAfter == 0 {
+		config.ResumeAcknowledgementAfter = defaultResumeAcknowledgementAfter
+		logger.Info("Using default resume acknowledgement after", zap.Duration("resumeAcknowledgementAfter", config.ResumeAcknowledgementAfter))
+	}
+
+	if config.LearnedWords && config.LearnedWordsDailyMax == 0 {
+		config.LearnedWordsDailyMax = defaultLearnedWordsDailyMax
+		logger.Info("Using default learned words daily max", zap.Int("learnedWordsDailyMax", config.LearnedWordsDailyMax))
+	}
+
+	if config.SentencePipelining && config.PipelineConcurrency == 0 {
+		config.PipelineConcurrency = defaultPipelineConcurrency
+		logger.Info("Using default pipeline concurrency", zap.Int("pipelineConcurrency", config.PipelineConcurrency))
+	}
+
+	if config.SentencePipelining && config.PipelineFallbackPhrase == "" {
+		config.PipelineFallbackPhrase = defaultPipelineFallbackPhrase
+		logger.Info("Using default pipeline fallback phrase", zap.String("pipelineFallbackPhrase", config.PipelineFallbackPhrase))
+	}
+
+	if (config.MaxResponseAudio > 0 || len(config.ResponseAudioCaps) > 0) && config.WindDownPhrase == "" {
+		config.WindDownPhrase = defaultWindDownPhrase
+		logger.Info("Using default wind-down phrase", zap.String("windDownPhrase", config.WindDownPhrase))
+	}
+
+	if config.PrewarmPhrases && config.SpeechUnavailablePhrase == "" {
+		config.SpeechUnavailablePhrase = defaultSpeechUnavailablePhrase
+		logger.Info("Using default speech unavailable phrase", zap.String("speechUnavailablePhrase", config.SpeechUnavailablePhrase))
+	}
+
+	if config.ExplainSimpler && config.ConfusionCues == nil {
+		config.ConfusionCues = defaultConfusionCues
+		logger.Info("Using default confusion cues", zap.Int("languages", len(config.ConfusionCues)))
+	}
+
+	if config.QuietHoursPhrase == "" {
+		config.QuietHoursPhrase = defaultQuietHoursPhrase
+		logger.Info("Using default quiet hours phrase", zap.String("quietHoursPhrase", config.QuietHoursPhrase))
+	}
+
+	if config.SessionCreateRetries == 0 {
+		config.SessionCreateRetries = defaultSessionCreateRetries
+		logger.Info("Using default session create retries", zap.Int("sessionCreateRetries", config.SessionCreateRetries))
+	}
+
+	if config.DefaultLanguage == "" {
+		config.DefaultLanguage = defaultLanguage
+		logger.Info("Using default language", zap.String("defaultLanguage", config.DefaultLanguage))
+	} else if normalized, err := entities.NormalizeLanguageTag(config.DefaultLanguage); err != nil {
+		logger.Warn("Invalid default language, using the built-in default",
+			zap.String("defaultLanguage", config.DefaultLanguage),
+			zap.String("builtInDefault", defaultLanguage),
+			zap.Error(err))
+		config.DefaultLanguage = defaultLanguage
+	} else {
+		config.DefaultLanguage = normalized
+	}
+
+	location := time.Local
+	if config.DefaultTimezone == "" {
+		logger.Info("Using server local timezone as default timezone", zap.String("defaultTimezone", location.String()))
+	} else if loaded, err := time.LoadLocation(config.DefaultTimezone); err != nil {
+		logger.Warn("Unknown default timezone, using server local timezone",
+			zap.String("defaultTimezone", config.DefaultTimezone),
+			zap.Error(err))
+	} else {
+		location = loaded
+	}
+
+	var redactor *piiRedactor
+	if config.PIIRedaction {
+		redactor = &piiRedactor{hashKey: []byte(config.PIIHashKey), logger: logger}
+		if config.PIINameDetection {
+			if detector, ok := llm.(repositories.NameDetector); ok {
+				redactor.names = detector
+			} else {
+				logger.Warn("LLM does not support name detection, names will not be redacted")
+			}
+		}
+	}
+
+	var summarizer repositories.ParentSummarizer
+	if config.ParentSummaries {
+		if s, ok := llm.(repositories.ParentSummarizer); ok {
+			summarizer = s
+		} else {
+			logger.Warn("LLM does not support parent summaries, sessions will not be summarized")
+		}
+	}
+
+	var contextSummarizer repositories.ContextSummarizer
+	if config.MaxTurns > 0 {
+		if s, ok := llm.(repositories.ContextSummarizer); ok {
+			contextSummarizer = s
+		} else {
+			logger.Warn("LLM does not support context summaries, fresh chat contexts will start without one")
+		}
+	}
+
+	var wordExtractor repositories.WordExtractor
+	if config.LearnedWords {
+		if e, ok := llm.(repositories.WordExtractor); ok {
+			wordExtractor = e
+		} else {
+			logger.Warn("LLM does not support word extraction, learned words will not be tracked")
+		}
+	}
+
+	voices, _ := ttsRepo.(repositories.VoiceValidator)
+	voiceLanguages, _ := ttsRepo.(repositories.VoiceLanguageValidator)
+	if config.VoiceLanguageFallback && voiceLanguages == nil {
+		logger.Warn("Text-to-speech cannot tell the languages of voices, child voices will not fall back")
+	}
+
+	if config.BargeInFadeOut > maxBargeInFadeOut {
+		logger.Warn("Barge-in fade-out beyond the maximum, using the maximum",
+			zap.Duration("bargeInFadeOut", config.BargeInFadeOut),
+			zap.Duration("max", maxBargeInFadeOut))
+		config.BargeInFadeOut = maxBargeInFadeOut
+	}
+
+	pcm, _ := ttsRepo.(repositories.PCMReporter)
+	if config.BargeInFadeOut > 0 && pcm == nil {
+		logger.Warn("Text-to-speech cannot tell whether it streams PCM, interrupted responses will not fade out")
+	}
+
+	var sessionsByChild repositories.ChildSessionFinder
+	if config.ChildScopedSessions {
+		if finder, ok := sessionRepo.(repositories.ChildSessionFinder); ok {
+			sessionsByChild = finder
+		} else {
+			logger.Warn("Session storage cannot find sessions by child, sessions will stay with their device")
+		}
+	}
+
+	return &Engine{
+		llm:         llm,
+		ttsRepo:     ttsRepo,
+		sttRepo:     sttRepo,
+		sessionRepo: sessionRepo,
+		childRepo:   childRepo,
+
+		quietHoursRepo:  quietHoursRepo,
+		sessionsByChild: sessionsByChild,
+
+		publisher:  publisher,
+		config:     config,
+		redactor:   redactor,
+		summarizer: summarizer,
+		voices:     voices,
+		turnSink:   nopTurnSink{},
+		now:        time.Now,
+		location:   location,
+		held:       make(map[string]*heldListening),
+		logger:     logger,
+
+		contextSummarizer: contextSummarizer,
+		wordExtractor:     wordExtractor,
+		voiceLanguages:    voiceLanguages,
+		pcm:               pcm,
+	}, nil
+}
+
+// StartOptions overrides the audio configuration of a listening session.
+// Zero values keep the defaults.
+type StartOptions struct {
+	SampleRate int
+	Language   string
+	Encoding   string
+	// PreRoll is audio captured just before the session started. It is
+	// streamed to speech-to-text ahead of the live audio so that the onset
+	// of speech is not clipped.
+	PreRoll []byte
+	// ResumeSessionID continues the listening session of this session held
+	// after the device's previous connection was lost, when there is one
+	ResumeSessionID string
+}
+
+// Conversation holds the pipeline state of a single device
+type Conversation struct {
+	engine   *Engine
+	id       string
+	deviceID string
+	childID  string
+	voiceID  string
+	sink     EventSink
+	logger   *zap.Logger
+
+	// Whether voiceID speaks a language, remembered for the language last
+	// checked with Config.VoiceLanguageFallback
+	voiceLanguage voiceLanguageCheck
+
+	// Age of the child, used to tailor response length
+	childAge      int
+	childAgeKnown bool
+	// Topics the parent wants the doll to steer away from
+	topics []string
+	// Timezone of the child, nil for the engine's default
+	location *time.Location
+	// Language the parent locked the doll to, empty to follow the device
+	forcedLanguage string
+	// Language the child usually speaks, and the one the device announced
+	childLanguage  string
+	deviceLanguage string
+	// Whether the parent consented to the child's audio being recorded
+	recordingConsent bool
+	// Whether the parent consented to the child's new words being tracked,
+	// and the words the child learned last, reinforced by new chat sessions
+	vocabularyConsent bool
+	learnedWords      []string
+	// Serializes tracking the learned words of the turns
+	wordsMu sync.Mutex
+	// Hardware model of the device, the fleet its audio quality is
+	// aggregated in
+	deviceModel string
+	// What the session was last about when the child returned to it after a
+	// break, welcomed back to by the next response
+	resumeTopic string
+
+	// Whether the audio of the listening session is recorded, and the
+	// session the decision was last recorded for
+	recording          bool
+	recordingSessionID string
+
+	// Audio streaming session management
+	session      *entities.Session
+	sttStreaming repositories.SpeechToTextStreaming
+	chatSession  repositories.ChatSession
+	// Set while the chat context of the session is summarized for a reset
+	resettingContext bool
+	// Consecutive failed turns of chatSession
+	chatFailures int
+
+	chunkCount     int
+	listeningStart time.Time
+	// Audio received while not listening, kept for the next listening
+	// session, and the bytes dropped from it beyond Config.EarlyAudioMax
+	earlyAudio        []byte
+	earlyAudioDropped int
+
+	// TTS chunk size negotiated by the transport, 0 for the TTS default
+	chunkSize int
+
+	// Sample rates negotiated with the device, nil when it declared none.
+	// audioErr is set when the declaration could not be satisfied.
+	audio    *AudioSettings
+	audioErr error
+	// Output rates adaptive audio switches between: the negotiated one and
+	// the one of poor links, zero when the device declared no playable rates
+	negotiatedOutputRate int
+	lowOutputRate        int
+
+	// What the doll last said and when it finished, used to reject echoes
+	lastSpeakingEnd time.Time
+	lastSpokenText  string
+
+	// Responses to the utterances of the current session
+	responses responseCache
+
+	// Messages this conversation stored, numbering their turn IDs
+	messageSequence int
+
+	// Operator typing the responses instead of the LLM, empty unless the
+	// conversation was taken over
+	operatorID string
+
+	// The response in progress and the goroutines Close waits for
+	cancelResponse context.CancelFunc
+	responseDone   chan struct{}
+	tasks          sync.WaitGroup
+	closed         atomic.Bool
+
+	mutex sync.Mutex
+}
+
+// NewConversation creates the pipeline state for a device.
+// Every event of the conversation is delivered to sink.
+func (e *Engine) NewConversation(deviceID string, sink EventSink) *Conversation {
+	return &Conversation{
+		engine:   e,
+		id:       uuid.NewString(),
+		deviceID: deviceID,
+		sink:     sink,
+		logger:   e.logger,
+	}
+}
+
+// emit delivers an event to the sink, stamping it with the current time
+func (c *Conversation) emit(event Event) {
+	if c.closed.Load() {
+		return
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	c.sink.Emit(event)
+}
+
+// publishLifecycle publishes a session lifecycle event for this device.
+// The caller must hold the mutex.
+func (c *Conversation) publishLifecycle(eventType events.Type, sessionID, reason string) {
+	c.engine.publisher.Publish(events.Event{
+		Type:      eventType,
+		DeviceID:  c.deviceID,
+		ChildID:   c.childID,
+		SessionID: sessionID,
+		Reason:    reason,
+		Timestamp: time.Now(),
+	})
+}
+
+// resolveChild looks up the child the device is assigned to, if any, so
+// that profile changes such as a new voice apply from the next turn.
+// The caller must hold the mutex.
+func (c *Conversation) resolveChild(ctx context.Context) {
+	// Consent is never assumed from a previous lookup
+	c.recordingConsent = false
+	c.vocabularyConsent = false
+	c.learnedWords = nil
+	child, err := c.engine.childRepo.GetByDeviceID(ctx, c.deviceID)
+	if err != nil {
+		c.logger.Debug("Device is not assigned to a child",
+			zap.String("deviceID", c.deviceID),
+			zap.Error(err))
+		return
+	}
+	c.childID = child.ID
+	c.voiceID = c.childVoice(ctx, child)
+	c.childAge, c.childAgeKnown = child.Age(time.Now())
+	c.topics = child.DiscouragedTopics
+	location, err := child.Location()
+	if err != nil {
+		c.logger.Warn("Ignoring invalid child timezone",
+			zap.String("childID", child.ID),
+			zap.Error(err))
+	}
+	c.location = location
+	c.forcedLanguage = child.ForcedLanguage
+	c.childLanguage = child.Language
+	c.recordingConsent = child.RecordingConsent
+	c.vocabularyConsent = child.VocabularyConsent
+	if c.vocabularyConsent {
+		c.learnedWords = child.RecentLearnedWords(learnedWordsReinforced)
+	}
+}
+
+// localTime returns the current time in the child's timezone.
+// The caller must hold the mutex.
+func (c *Conversation) localTime() time.Time {
+	location := c.location
+	if location == nil {
+		location = c.engine.location
+	}
+	return c.engine.now().In(location)
+}
+
+// SessionID returns the ID of the current session, or an empty string
+func (c *Conversation) SessionID() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.session == nil {
+		return ""
+	}
+	return c.session.ID
+}
+
+// Listening reports whether a listening session is streaming to speech-to-text
+func (c *Conversation) Listening() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.sttStreaming != nil
+}
+
+// Forget drops the cached session and chat history so that erased
+// conversations cannot leak into the next turn
+func (c *Conversation) Forget() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.forgetLocked()
+}
+
+// ForgetChild is Forget for a conversation with the child or in one of
+// their sessions
+func (c *Conversation) ForgetChild(childID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.childID == childID || (c.session != nil && c.session.ChildID == childID) {
+		c.forgetLocked()
+	}
+}
+
+// forgetLocked is Forget for callers holding the mutex
+func (c *Conversation) forgetLocked() {
+	if c.session != nil {
+		c.publishLifecycle(events.SessionEnded, c.session.ID, events.ReasonErased)
+	}
+	c.session = nil
+	c.chatSession = nil
+}
+
+// StartListening starts a listening session, continuing the device's last
+// session when possible. The outcome is reported as EventListeningStart.
+func (c *Conversation) StartListening(opts StartOptions) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	event := Event{
+		Type:      EventListeningStart,
+		Timestamp: now,
+	}
+	// Audio the device sent ahead of this start follows the pre-roll
+	early := c.takeEarlyAudio()
+	defer func() {
+		if event.Error == "" {
+			c.streamEarlyAudio(early)
+		}
+		c.emit(event)
+	}()
+
+	if opts.ResumeSessionID != "" && c.resumeListening(ctx, opts, &event) {
+		return
+	}
+
+	// The doll's own speaker can re-trigger the microphone right after it speaks
+	if c.withinDebounce(now) {
+		c.logger.Info("Ignoring listening start within debounce window",
+			zap.String("deviceID", c.deviceID),
+			zap.Duration("sinceSpeakingEnd", now.Sub(c.lastSpeakingEnd)))
+		event.Error = ErrorDebounced
+		return
+	}
+
+	c.resolveChild(ctx)
+
+	// Past bedtime the doll only whispers back instead of starting a turn
+	if c.duringQuietHours(ctx, c.engine.now()) {
+		c.logger.Info("Declining listening start during quiet hours",
+			zap.String("deviceID", c.deviceID),
+			zap.String("childID", c.childID))
+		event.Error = ErrorQuietHours
+		c.background(c.shush)
+		return
+	}
+
+	sampleRate, err := c.inputSampleRate(opts.SampleRate)
+	if err != nil {
+		c.logger.Warn("Rejecting listening start with unsupported audio",
+			zap.String("deviceID", c.deviceID),
+			zap.Int("sampleRate", opts.SampleRate),
+			zap.Error(err))
+		event.Error = ErrorUnsupportedAudio
+		return
+	}
+
+	c.chunkCount = 0
+	c.listeningStart = now
+
+	if c.session == nil {
+		c.session, err = c.lastSession(ctx)
+		if err != nil && !c.engine.config.EphemeralSessions {
+			c.logger.Error("Failed to get last session",
+				zap.String("deviceID", c.deviceID),
+				zap.String("childID", c.childID),
+				zap.Error(err))
+			event.Error = "failed to get last session"
+			return
+		}
+	} else {
+		c.followChild(ctx)
+	}
+	c.persistEphemeralSession(ctx)
+	if c.session == nil || !c.session.CanContinueThisSession() {
+		if c.session != nil {
+			c.endSession(ctx, c.session, now)
+		}
+
+		// The chat history belongs to the previous session
+		c.chatSession = nil
+		session, created, err := c.createSession(ctx, now)
+		switch {
+		case err != nil && c.engine.config.EphemeralSessions:
+			session = c.startEphemeralSession(now, err)
+		case err != nil:
+			c.logger.Error("Failed to create new session",
+				zap.String("deviceID", c.deviceID),
+				zap.Error(err))
+			c.session = nil
+			event.Error = "failed to create new session"
+			return
+		case created:
+			c.publishLifecycle(events.SessionStarted, session.ID, "")
+		}
+		c.session = session
+	}
+	// A session started before the device was bound to the child becomes
+	// theirs, and is found by child once stored
+	if c.session.ChildID == "" {
+		c.session.ChildID = c.childID
+	}
+
+	event.SessionID = c.session.ID
+	c.armResumeAcknowledgement(c.session, now)
+
+	c.dropFailedChat()
+	if c.chatSession == nil {
+		llmCtx := ctx
+		if c.childAgeKnown {
+			llmCtx = repositories.WithChildAge(ctx, c.childAge)
+		}
+		if len(c.topics) > 0 {
+			llmCtx = repositories.WithDiscouragedTopics(llmCtx, c.topics)
+		}
+		if len(c.learnedWords) > 0 && c.engine.wordExtractor != nil {
+			llmCtx = repositories.WithLearnedWords(llmCtx, c.learnedWords)
+		}
+		// A long session continues from the summary of its chat context
+		if reset := c.session.ContextReset; reset != nil && reset.Summary != "" {
+			llmCtx = repositories.WithContextSummary(llmCtx, reset.Summary)
+		}
+		c.chatSession, err = c.engine.llm.GenerateChat(llmCtx, c.session.ContextMessages())
+		if err != nil {
+			c.logger.Error("Failed to create chat session",
+				zap.String("deviceID", c.deviceID),
+				zap.Error(err))
+			event.Error = "failed to create chat session"
+			return
+		}
+		c.chatFailures = 0
+	}
+
+	audioConfig := repositories.AudioConfig{
+		SampleRate: sampleRate,
+		Language:   c.listeningLanguage(opts.Language),
+		Encoding:   "LINEAR16",
+	}
+	if opts.Encoding != "" {
+		audioConfig.Encoding = opts.Encoding
+	}
+	// The doll answers in the language the child speaks
+	c.session.Metadata.Language = audioConfig.Language
+
+	// A listening start before the previous one ended replaces it
+	c.closeListening()
+	stream, err := c.engine.sttRepo.InitTranscribeStreaming(context.Background(), audioConfig)
+	if err != nil {
+		c.logger.Error("Failed to initialize streaming transcription",
+			zap.String("sessionID", c.session.ID),
+			zap.Error(err))
+		event.Error = ErrorTranscriptionUnavailable
+		return
+	}
+	c.sttStreaming = newBufferedSTT(stream, c.engine.config.AudioBufferMax, c.engine.config.AudioBufferOverflowDisconnect,
+		c.logger.With(zap.String("deviceID", c.deviceID), zap.String("sessionID", c.session.ID)))
+
+	c.decideRecording(ctx)
+	c.streamPreRoll(opts.PreRoll)
+
+	c.logger.Info("Audio session started",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", c.session.ID))
+}
+
+// streamPreRoll streams the audio captured before listening started ahead of
+// the live audio. The caller must hold the mutex.
+func (c *Conversation) streamPreRoll(preRoll []byte) {
+	if len(preRoll) == 0 {
+		return
+	}
+	if err := c.sttStreaming.Stream(preRoll); err != nil {
+		c.logger.Warn("Failed to stream pre-roll audio",
+			zap.String("sessionID", c.session.ID),
+			zap.Error(err))
+		return
+	}
+	c.record(preRoll)
+	c.logger.Debug("Streamed pre-roll audio",
+		zap.String("sessionID", c.session.ID),
+		zap.Int("size", len(preRoll)))
+}
+
+// StreamAudio forwards a chunk of the child's audio to speech-to-text
+func (c *Conversation) StreamAudio(data []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.sttStreaming == nil && c.bufferEarlyAudio(data) {
+		return
+	}
+
+	if c.session == nil {
+		c.logger.Warn("Received audio chunk but no active session found",
+			zap.String("deviceID", c.deviceID))
+		return
+	}
+
+	if c.sttStreaming == nil {
+		c.logger.Warn("No active STT streaming for current session",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", c.session.ID))
+		return
+	}
+
+	// Update session counters
+	c.chunkCount++
+
+	// Stream audio data to the speech-to-text service
+	err := c.sttStreaming.Stream(data)
+	if errors.Is(err, errAudioBufferExceeded) {
+		c.abandonListening()
+		return
+	}
+	if err != nil {
+		c.logger.Error("Failed to stream audio data",
+			zap.String("sessionID", c.session.ID),
+			zap.Error(err))
+		return
+	}
+	c.record(data)
+
+	c.logger.Debug("Received audio chunk and processed",
+		zap.String("deviceID", c.deviceID),
+		zap.Int("chunkCount", c.chunkCount),
+		zap.Int("size", len(data)))
+}
+
+// abandonListening drops a listening session whose audio exceeded the audio
+// buffer budget and reports it, so that the transport disconnects the device.
+// The caller must hold the mutex.
+func (c *Conversation) abandonListening() {
+	c.logger.Warn("Audio buffer budget exceeded, abandoning listening session",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", c.session.ID),
+		zap.Int("audioBufferMax", c.engine.config.AudioBufferMax))
+
+	// Closed in the background to release it, the transcription is not used
+	stream := c.sttStreaming
+	c.sttStreaming = nil
+	go stream.Close()
+
+	c.emit(Event{Type: EventError, SessionID: c.session.ID, Error: ErrorAudioBufferExceeded})
+}
+
+// closeListening closes the speech-to-text stream of the listening session,
+// if any, without transcribing it.
+// The caller must hold the mutex.
+func (c *Conversation) closeListening() {
+	if c.sttStreaming == nil {
+		return
+	}
+	stream := c.sttStreaming
+	c.sttStreaming = nil
+	if err := stream.Close(); err != nil {
+		c.logger.Debug("Failed to close speech-to-text stream",
+			zap.String("deviceID", c.deviceID),
+			zap.Error(err))
+	}
+}
+
+// CancelListening drops the listening session without transcribing it,
+// reporting whether there was one. No event is emitted.
+func (c *Conversation) CancelListening() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.sttStreaming == nil {
+		return false
+	}
+
+	c.logger.Info("Cancelling listening session",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", c.session.ID))
+
+	// Closed in the background to release it, the transcription is not used
+	stream := c.sttStreaming
+	c.sttStreaming = nil
+	go stream.Close()
+	return true
+}
+
+// EndListening finalizes the transcription, reports it as EventListeningEnd
+// and generates the spoken response in the background
+func (c *Conversation) EndListening() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	event := Event{
+		Type: EventListeningEnd,
+	}
+	defer func() {
+		c.emit(event)
+	}()
+
+	if c.session == nil || c.sttStreaming == nil {
+		c.logger.Warn("Received listening end without an active listening session",
+			zap.String("deviceID", c.deviceID))
+		event.Error = "not listening"
+		return
+	}
+	event.SessionID = c.session.ID
+
+	// Acknowledge the child if transcription turns out to be slow
+	filler := c.startFiller(c.session.ID)
+
+	transcribing := time.Now()
+	stream := c.sttStreaming
+	finalTranscription, err := stream.End()
+	c.sttStreaming = nil
+	if errors.Is(err, errs.ErrNoSpeech) {
+		filler.stop()
+		c.logger.Info("No speech recognized in utterance",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", c.session.ID))
+		c.recordQuality(qualityTurn{NoSpeech: true})
+		event.Error = ErrorNoSpeech
+		return
+	}
+	if err != nil {
+		filler.stop()
+		c.logger.Error("Failed to end transcription stream",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", c.session.ID),
+			zap.Error(err))
+		event.Error = "failed to end transcription"
+		return
+	}
+	filler.disarm()
+
+	loggedTranscription := finalTranscription
+	if c.engine.redactor != nil {
+		loggedTranscription = c.engine.redactor.redactPatterns(finalTranscription)
+	}
+	c.logger.Info("Transcription completed",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", c.session.ID),
+		zap.String("transcription", loggedTranscription))
+
+	if c.engine.config.EchoSuppression && isEcho(finalTranscription, c.lastSpokenText) {
+		filler.stop()
+		c.logger.Info("Dropping transcription that echoes the doll's last response",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", c.session.ID))
+		event.Error = "echo_detected"
+		return
+	}
+
+	chatMessage := entities.Message{
+		Timestamp:  c.listeningStart,
+		Role:       entities.UserRole,
+		Content:    finalTranscription,
+		DurationMs: time.Since(c.listeningStart).Milliseconds(),
+		Metadata: entities.MessageMetadata{
+			TranscriptionConfidence: transcriptionConfidence(stream),
+		},
+	}
+	event.Message = &chatMessage
+
+	turn := turnSummary{
+		SessionID:    c.session.ID,
+		Language:     c.language(c.session),
+		Confidence:   chatMessage.Metadata.TranscriptionConfidence,
+		Listening:    transcribing.Sub(c.listeningStart),
+		Transcribing: time.Since(transcribing),
+	}
+	session, chatSession := c.session, c.chatSession
+	respond := func(ctx context.Context) {
+		c.respond(ctx, session, chatSession, chatMessage, filler, turn)
+	}
+	if c.operatorID != "" {
+		// The operator answers with SpeakAsOperator
+		filler.stop()
+		respond = func(ctx context.Context) {
+			c.awaitOperator(ctx, session, chatMessage)
+		}
+	}
+	if !c.startResponse(respond) {
+		filler.stop()
+		return
+	}
+
+	c.logger.Info("Starting audio response goroutine",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", c.session.ID))
+}
+
+// response is the state of the doll's reply to one message, handed from
+// stage to stage by respond
+type response struct {
+	session     *entities.Session
+	chatSession repositories.ChatSession
+	filler      *fillerPlayback
+	turn        turnSummary
+
+	ttsCtx context.Context
+	llmCtx context.Context
+	// Cancelled to stop synthesis once the response reaches its audio cap
+	synthesisCtx  context.Context
+	stopSynthesis context.CancelFunc
+
+	// The child's message as stored and as sent to the LLM
+	message entities.Message
+	stored  entities.Message
+	prompt  entities.Message
+
+	cacheKey   string
+	cached     cachedResponse
+	hit        bool
+	budget     *audioBudget
+	lastTurn   bool
+	sampleRate int
+	level      repositories.DegradationLevel
+
+	started      time.Time
+	synthesizing time.Time
+	pipelined    bool
+	streaming    repositories.StreamingChatSession
+
+	reply entities.Message
+	audio <-chan []byte
+	// The audio spoken, kept to cache the response
+	spoken [][]byte
+}
+
+// respond generates and synthesizes the doll's reply to message. It stops
+// speaking once ctx is done.
+func (c *Conversation) respond(ctx context.Context, session *entities.Session, chatSession repositories.ChatSession, message entities.Message, filler *fillerPlayback, turn turnSummary) {
+	r := c.prepareResponse(ctx, session, chatSession, message, filler, turn)
+	// The filler must never overlap with the real response
+	defer filler.stop()
+	defer r.stopSynthesis()
+
+	c.emit(Event{Type: EventThinking, SessionID: session.ID})
+
+	r.stored, r.prompt = c.redactTurn(ctx, message)
+
+	if !c.generate(ctx, r) {
+		return
+	}
+	if !r.pipelined && !r.hit && !c.synthesize(ctx, r) {
+		return
+	}
+	c.recordResponse(r)
+	c.stream(ctx, r)
+	c.persistResponse(ctx, r)
+}
+
+// prepareResponse gathers what the reply to message depends on: the contexts
+// of the LLM and of text-to-speech, the cached response and the audio cap
+func (c *Conversation) prepareResponse(ctx context.Context, session *entities.Session, chatSession repositories.ChatSession, message entities.Message, filler *fillerPlayback, turn turnSummary) *response {
+	r := &response{
+		session:     session,
+		chatSession: chatSession,
+		filler:      filler,
+		turn:        turn,
+		message:     message,
+		started:     time.Now().Add(-turn.Transcribing),
+	}
+
+	c.mutex.Lock()
+	r.ttsCtx = c.ttsContext(ctx)
+	r.llmCtx = repositories.WithLocalTime(ctx, c.localTime())
+	if c.forcedLanguage != "" {
+		r.llmCtx = repositories.WithReplyLanguage(r.llmCtx, c.forcedLanguage)
+	}
+	// A session spanning midnight moves on to the next day's topic
+	if topic := c.dailyTopic(session); topic != "" {
+		r.llmCtx = repositories.WithDailyTopic(r.llmCtx, topic)
+	}
+	r.cacheKey = c.responseCacheKey(message.Content)
+	// A confused child gets the last response again, explained more simply.
+	// It depends on what the doll said before, so it is never cached.
+	if previous := c.confusedAbout(session, message.Content); previous != "" {
+		r.llmCtx = repositories.WithRephrase(r.llmCtx, previous)
+		r.cacheKey = ""
+	}
+	// The welcome back depends on the session too
+	if topic := c.takeResumeTopic(); topic != "" {
+		r.llmCtx = repositories.WithResumeTopic(r.llmCtx, topic)
+		r.cacheKey = ""
+	}
+	if c.engine.config.IntentSegmentation {
+		r.llmCtx = repositories.WithIntentSegmentation(r.llmCtx)
+	}
+	r.cached, r.hit = c.lookupResponse(session.ID, r.cacheKey)
+	r.budget = c.responseAudioBudget()
+	r.lastTurn = c.lastContextTurn(session)
+	r.sampleRate = c.outputSampleRate()
+	c.mutex.Unlock()
+
+	r.level = c.engine.degradationLevel()
+	if r.level != repositories.DegradationNone {
+		r.llmCtx = repositories.WithDegradation(r.llmCtx, r.level)
+	}
+	r.synthesisCtx, r.stopSynthesis = context.WithCancel(r.ttsCtx)
+	return r
+}
+
+// generate produces the text of the reply: from the cache, from the LLM or,
+// with sentence pipelining, spoken sentence by sentence as the LLM streams
+// it. On failure the error is emitted and false is returned.
+func (c *Conversation) generate(ctx context.Context, r *response) bool {
+	r.synthesizing = time.Now()
+	r.streaming, r.pipelined = c.pipelinedSession(r.chatSession, r.hit)
+
+	var err error
+	switch {
+	case r.pipelined:
+		r.reply, r.spoken, err = c.responseAudio(r.llmCtx, r.ttsCtx, r.session.ID, r.streaming, r.prompt, r.cacheKey != "", r.filler, r.budget, &r.turn)
+		c.engine.observe(ctx, dependencyLLM, r.turn.Generating, err)
+	case r.hit:
+		c.logger.Info("Serving cached response to a repeated utterance",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", r.session.ID),
+			zap.String("response", r.cached.text))
+		r.reply = entities.Message{
+			Timestamp: time.Now(),
+			Role:      entities.DollRole,
+			Content:   r.cached.text,
+		}
+		r.audio = r.cached.replay()
+		return true
+	default:
+		generating := time.Now()
+		r.reply, err = c.reply(r.llmCtx, r.chatSession, r.prompt)
+		r.turn.Generating = time.Since(generating)
+		c.engine.observe(ctx, dependencyLLM, r.turn.Generating, err)
+		// The session continues only while its last message is recent
+		if err == nil && r.reply.Timestamp.IsZero() {
+			r.reply.Timestamp = time.Now()
+		}
+	}
+	if err != nil {
+		c.logger.Error("Failed to send message to chat session",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", r.session.ID),
+			zap.Error(err))
+		c.mutex.Lock()
+		c.recordChatTurn(r.chatSession, err)
+		c.mutex.Unlock()
+		c.emit(Event{Type: EventError, SessionID: r.session.ID, Error: "failed to generate response"})
+		return false
+	}
+
+	c.logger.Info("Received chat response",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", r.session.ID),
+		zap.String("response", r.reply.Content))
+	return true
+}
+
+// synthesize starts converting the generated reply to speech. On failure
+// the error is emitted, or the doll apologises when the text-to-speech quota
+// is exhausted, and false is returned.
+func (c *Conversation) synthesize(ctx context.Context, r *response) bool {
+	r.synthesizing = time.Now()
+	audio, err := c.engine.ttsRepo.ConvertTextToSpeech(r.synthesisCtx, r.reply.Content)
+	if err != nil {
+		c.engine.observe(ctx, dependencyTTS, time.Since(r.synthesizing), err)
+		c.logger.Error("Failed to convert text to speech",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", r.session.ID),
+			zap.Error(err))
+		if errors.Is(err, repositories.ErrTTSQuotaExceeded) {
+			r.filler.stop()
+			c.speakUnavailable(r.ttsCtx, r.session.ID, &r.turn, true)
+			return false
+		}
+		c.emit(Event{Type: EventError, SessionID: r.session.ID, Error: "failed to synthesize response"})
+		return false
+	}
+	r.audio = audio
+	return true
+}
+
+// recordResponse records the health of the chat session and the topics of
+// the turn once the reply is ready to be spoken
+func (c *Conversation) recordResponse(r *response) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !r.hit {
+		c.recordChatTurn(r.chatSession, nil)
+		// A chat session answering a failed request with a fallback reports
+		// itself unhealthy
+		if health, ok := r.chatSession.(repositories.ChatSessionHealth); ok && !health.Healthy() {
+			r.turn.Fallback = true
+		}
+	}
+	c.reportTopics(r.session.ID, events.ReasonChildInput, r.message.Content)
+	c.reportTopics(r.session.ID, events.ReasonModelResponse, r.reply.Content)
+}
+
+// stream speaks the reply to the device, up to its audio cap. A pipelined
+// reply was spoken while it was generated, so only its end is announced.
+func (c *Conversation) stream(ctx context.Context, r *response) {
+	if r.pipelined {
+		if r.lastTurn {
+			c.speakFreshStart(r.ttsCtx, r.session.ID, &r.turn)
+		}
+		c.emit(Event{Type: EventSpeakingEnd, SessionID: r.session.ID, Message: &r.reply})
+		return
+	}
+
+	r.filler.stop()
+
+	estimate := estimateAudio(r.reply.Content, r.sampleRate, r.budget)
+	if r.hit {
+		estimate = knownAudio(r.cached.audio, r.sampleRate)
+	}
+	c.emit(Event{Type: EventSpeakingStart, SessionID: r.session.ID, Message: &r.reply, AudioEstimate: estimate, OutputSampleRate: r.sampleRate})
+	tail := c.newFadeTail(r.sampleRate, func(audioData []byte) {
+		c.emit(Event{Type: EventAudio, SessionID: r.session.ID, Audio: audioData})
+	})
+	for audioData := range r.audio {
+		// A cancelled response drains its audio unheard, and so does
+		// one past its audio cap
+		if ctx.Err() != nil || r.budget.cut() {
+			tail.flush(ctx.Err() != nil)
+			continue
+		}
+		audioData, exhausted := r.budget.take(audioData)
+		if exhausted {
+			r.stopSynthesis()
+		}
+		if len(audioData) == 0 {
+			continue
+		}
+		if r.turn.AudioChunks == 0 {
+			r.turn.FirstAudio = time.Since(r.synthesizing)
+		}
+		r.turn.AudioChunks++
+		r.turn.AudioBytes += len(audioData)
+		tail.push(audioData)
+		if r.cacheKey != "" && !r.hit {
+			r.spoken = append(r.spoken, audioData)
+		}
+	}
+	tail.flush(ctx.Err() != nil)
+	if r.budget.cut() && ctx.Err() == nil {
+		c.windDown(r.ttsCtx, r.session.ID, r.budget, &r.turn)
+	}
+	if r.lastTurn {
+		c.speakFreshStart(r.ttsCtx, r.session.ID, &r.turn)
+	}
+	c.emit(Event{Type: EventSpeakingEnd, SessionID: r.session.ID})
+	if !r.hit && r.turn.AudioChunks > 0 {
+		c.engine.observe(ctx, dependencyTTS, r.turn.FirstAudio, nil)
+	}
+}
+
+// persistResponse caches the spoken reply, logs the turn and stores its
+// messages with the session
+func (c *Conversation) persistResponse(ctx context.Context, r *response) {
+	turn := r.turn
+	turn.Synthesizing = time.Since(r.synthesizing)
+	turn.Total = time.Since(r.started)
+
+	turn.Transcription = r.stored.Content
+	turn.Model = c.engine.modelName()
+	turn.Response = r.reply.Content
+	turn.Cached = r.hit
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// A response cut at its audio cap is never replayed
+	if r.cacheKey != "" && !r.hit && r.spoken != nil && ctx.Err() == nil && !r.budget.cut() {
+		c.storeResponse(r.session.ID, r.cacheKey, cachedResponse{text: r.reply.Content, audio: r.spoken})
+	}
+
+	c.lastSpeakingEnd = time.Now()
+	c.lastSpokenText = r.reply.Content
+	c.logTurn(turn)
+	c.recordQuality(qualityTurn{Fallback: turn.Fallback, Confidence: turn.Confidence, FirstAudio: turn.FirstAudio})
+	c.recordTurn(r.ttsCtx, r.session, r.stored, r.reply, r.hit, r.level)
+
+	c.saveMessages(r.session, r.stored, r.reply)
+	c.trackLearnedWords(r.session.ID, r.stored, r.reply)
+	if r.lastTurn {
+		c.resetContext(r.session, r.chatSession)
+	}
+}
+
+// saveMessages adds messages to session and stores it. Every message is
+// given a turn ID, so that storing it again never duplicates it.
+// The caller must hold the mutex.
+func (c *Conversation) saveMessages(session *entities.Session, messages ...entities.Message) {
+	for i := range messages {
+		if messages[i].TurnID == "" {
+			c.messageSequence++
+			messages[i].TurnID = fmt.Sprintf("%s-%d", c.id, c.messageSequence)
+		}
+	}
+	session.AddMessage(func(s *entities.Session) error {
+		if isEphemeral(s) {
+			// Stored with its messages once the storage recovers
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := c.engine.sessionRepo.Update(ctx, s)
+		if err != nil {
+			c.logger.Error("Failed to update session with new messages",
+				zap.String("deviceID", c.deviceID),
+				zap.String("sessionID", s.ID),
+				zap.Error(err))
+			return err
+		}
+		return nil
+	}, messages...)
+}
+
+// pipelinedSession returns the chat session as a streaming one when the
+// response is to be spoken with sentence pipelining. A response segmented by
+// intent is structured, so it is only spoken once complete.
+func (c *Conversation) pipelinedSession(chatSession repositories.ChatSession, hit bool) (repositories.StreamingChatSession, bool) {
+	if !c.engine.config.SentencePipelining || c.engine.config.DemoMode || c.engine.config.IntentSegmentation || hit {
+		return nil, false
+	}
+	streaming, ok := chatSession.(repositories.StreamingChatSession)
+	return streaming, ok
+}
+
+// reply generates the doll's response to message. In demo mode the LLM is
+// bypassed and the configured demo phrase is spoken instead.
+func (c *Conversation) reply(ctx context.Context, chatSession repositories.ChatSession, message entities.Message) (entities.Message, error) {
+	if c.engine.config.DemoMode {
+		return entities.Message{
+			Timestamp: time.Now(),
+			Role:      entities.DollRole,
+			Content:   c.engine.config.DemoPhrase,
+		}, nil
+	}
+	return chatSession.SendMessage(ctx, message)
+}
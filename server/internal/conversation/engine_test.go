@@ -0,0 +1,333 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// recorder is an EventSink that hands events to the test over a channel
+type recorder struct {
+	events chan Event
+}
+
+func newRecorder() *recorder {
+	return &recorder{events: make(chan Event, 256)}
+}
+
+func (r *recorder) Emit(event Event) {
+	r.events <- event
+}
+
+// until collects events up to and including the first one of the given type
+func (r *recorder) until(t *testing.T, eventType EventType) []Event {
+	t.Helper()
+	var events []Event
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-r.events:
+			events = append(events, event)
+			if event.Type == eventType {
+				return events
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %q, got %v", eventType, eventTypes(events))
+		}
+	}
+}
+
+// next returns the first event of the given type, skipping others
+func (r *recorder) next(t *testing.T, eventType EventType) Event {
+	t.Helper()
+	events := r.until(t, eventType)
+	return events[len(events)-1]
+}
+
+func eventTypes(events []Event) []EventType {
+	types := make([]EventType, len(events))
+	for i, event := range events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func indexOf(events []Event, eventType EventType) int {
+	for i, event := range events {
+		if event.Type == eventType {
+			return i
+		}
+	}
+	return -1
+}
+
+type engineFixture struct {
+	engine   *Engine
+	llm      *conversationtest.LLM
+	stt      *conversationtest.STT
+	tts      *conversationtest.TTS
+	sessions *conversationtest.SessionRepository
+	children *adapters.MemoryChildRepository
+	quiet    *adapters.MemoryQuietHoursRepository
+	bus      *events.Bus
+}
+
+func newEngineFixture(t *testing.T, config Config, stt *conversationtest.STT) *engineFixture {
+	t.Helper()
+	f := &engineFixture{
+		llm:      &conversationtest.LLM{Reply: "Halo juga!"},
+		stt:      stt,
+		tts:      &conversationtest.TTS{Chunks: [][]byte{{0xAA}, {0xBB}}},
+		sessions: &conversationtest.SessionRepository{},
+		children: adapters.NewMemoryChildRepository(),
+		quiet:    adapters.NewMemoryQuietHoursRepository(),
+		bus:      events.NewBus(zaptest.NewLogger(t)),
+	}
+	engine, err := NewEngine(config, f.llm, f.tts, f.stt, f.sessions, f.children, f.quiet, f.bus, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.engine = engine
+	return f
+}
+
+// speak runs a listening session that streams a single chunk of audio
+func speak(t *testing.T, conv *Conversation, sink *recorder) {
+	t.Helper()
+	conv.StartListening(StartOptions{})
+	if event := sink.next(t, EventListeningStart); event.Error != "" {
+		t.Fatalf("Failed to start listening: %s", event.Error)
+	}
+	conv.StreamAudio([]byte{0x01, 0x02})
+	conv.EndListening()
+}
+
+func TestEngine_FullTurn(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo boneka"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	events := sink.until(t, EventSpeakingEnd)
+
+	want := []EventType{EventListeningEnd, EventThinking, EventSpeakingStart, EventAudio, EventAudio, EventSpeakingEnd}
+	got := eventTypes(events)
+	if len(got) != len(want) {
+		t.Fatalf("Expected events %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected events %v, got %v", want, got)
+		}
+	}
+
+	if events[0].Message == nil || events[0].Message.Content != "halo boneka" {
+		t.Errorf("Expected transcription on listening end, got %+v", events[0].Message)
+	}
+	if events[2].Message == nil || events[2].Message.Content != "Halo juga!" {
+		t.Errorf("Expected response on speaking start, got %+v", events[2].Message)
+	}
+
+	streams := f.stt.Streams()
+	if len(streams) != 1 || len(streams[0].Chunks()) != 1 || !streams[0].Ended() {
+		t.Errorf("Expected one ended STT stream with the streamed chunk")
+	}
+	if conv.SessionID() == "" || events[0].SessionID != conv.SessionID() {
+		t.Errorf("Expected events to carry the session ID %q, got %q", conv.SessionID(), events[0].SessionID)
+	}
+}
+
+func TestEngine_ListeningEndWithoutStart(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	conv.EndListening()
+
+	if event := sink.next(t, EventListeningEnd); event.Error != "not listening" {
+		t.Errorf("Expected not listening error, got %q", event.Error)
+	}
+}
+
+func TestEngine_ReportsUtteranceWithoutSpeech(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+
+	if event := sink.next(t, EventListeningEnd); event.Error != ErrorNoSpeech {
+		t.Errorf("Expected the no speech error, got %q", event.Error)
+	}
+	if messages := f.llm.Messages(); len(messages) != 0 {
+		t.Errorf("Expected no response to an utterance without speech, got %v", messages)
+	}
+}
+
+func TestEngine_ForgetStartsFreshSession(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	firstSession := conv.SessionID()
+
+	conv.Forget()
+	f.sessions.DeleteByDeviceID(context.Background(), "device-1")
+
+	conv.StartListening(StartOptions{})
+	event := sink.next(t, EventListeningStart)
+	if event.SessionID == "" || event.SessionID == firstSession {
+		t.Errorf("Expected a new session after forgetting, got %q", event.SessionID)
+	}
+}
+
+func TestConversation_ForgetChildClearsOnlyTheirSession(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	sink, otherSink := newRecorder(), newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	other := f.engine.NewConversation("device-2", otherSink)
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	speak(t, other, otherSink)
+	otherSink.until(t, EventSpeakingEnd)
+
+	conv.ForgetChild(child.ID)
+	other.ForgetChild(child.ID)
+
+	if conv.SessionID() != "" {
+		t.Error("Expected the session of the erased child to be cleared")
+	}
+	if other.SessionID() == "" {
+		t.Error("Expected the session of another device to be kept")
+	}
+}
+
+func TestEngine_SynthesizesInSessionLanguage(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "hello doll"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	conv.StartListening(StartOptions{Language: "en-US"})
+	sink.next(t, EventListeningStart)
+	conv.EndListening()
+	sink.until(t, EventSpeakingEnd)
+
+	if languages := f.tts.Languages(); len(languages) != 1 || languages[0] != "en-US" {
+		t.Errorf("Expected the response to be synthesized in en-US, got %v", languages)
+	}
+}
+
+func TestEngine_SynthesizesWithChildVoice(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}, VoiceID: "voice-mama"}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+
+	if voiceIDs := f.tts.VoiceIDs(); len(voiceIDs) != 1 || voiceIDs[0] != "voice-mama" {
+		t.Errorf("Expected the response in the child's custom voice, got %v", voiceIDs)
+	}
+}
+
+func TestEngine_FallsBackFromDeletedChildVoice(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	f.tts.Voices = []string{"voice-papa"}
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}, VoiceID: "voice-mama"}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+
+	if voiceIDs := f.tts.VoiceIDs(); len(voiceIDs) != 1 || voiceIDs[0] != "" {
+		t.Errorf("Expected the response in the default voice, got %v", voiceIDs)
+	}
+	flagged, _ := f.children.GetByID(context.Background(), child.ID)
+	if !flagged.VoiceInvalid || flagged.VoiceID != "voice-mama" {
+		t.Errorf("Expected the profile to be flagged and keep its voice, got %+v", flagged)
+	}
+}
+
+func TestEngine_PassesChildAgeToLLM(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	birthDate := time.Now().AddDate(-4, 0, -1)
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}, BirthDate: birthDate}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	sink := newRecorder()
+
+	f.engine.NewConversation("device-1", sink).StartListening(StartOptions{})
+	sink.next(t, EventListeningStart)
+	f.engine.NewConversation("device-2", sink).StartListening(StartOptions{})
+	sink.next(t, EventListeningStart)
+
+	if ages := f.llm.Ages(); len(ages) != 2 || ages[0] != 4 || ages[1] != -1 {
+		t.Errorf("Expected age 4 for the child's device and none otherwise, got %v", ages)
+	}
+}
+
+func TestNewEngine_RejectsMissingDependencies(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	deps := func() (repositories.LargeLanguageModel, repositories.TextToSpeech, repositories.SpeechToText, repositories.SessionRepository, repositories.ChildRepository, repositories.QuietHoursRepository, events.Publisher) {
+		return &conversationtest.LLM{}, &conversationtest.TTS{}, &conversationtest.STT{}, &conversationtest.SessionRepository{},
+			adapters.NewMemoryChildRepository(), adapters.NewMemoryQuietHoursRepository(), events.NewBus(logger)
+	}
+
+	for i, missing := range []string{"llm", "tts", "stt", "sessions", "children", "quiet hours", "publisher"} {
+		t.Run(missing, func(t *testing.T) {
+			llm, tts, stt, sessions, children, quiet, publisher := deps()
+			switch i {
+			case 0:
+				llm = nil
+			case 1:
+				tts = nil
+			case 2:
+				stt = nil
+			case 3:
+				sessions = nil
+			case 4:
+				children = nil
+			case 5:
+				quiet = nil
+			case 6:
+				publisher = nil
+			}
+			engine, err := NewEngine(Config{}, llm, tts, stt, sessions, children, quiet, publisher, logger)
+			if err == nil || engine != nil {
+				t.Errorf("Expected the missing %s to be rejected, got engine %v and error %v", missing, engine, err)
+			}
+		})
+	}
+}
+
+func TestNewEngine_StoresDependencies(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{})
+
+	if f.engine.llm == nil || f.engine.ttsRepo == nil || f.engine.sttRepo == nil || f.engine.sessionRepo == nil {
+		t.Errorf("Expected every injected dependency to be stored, got %+v", f.engine)
+	}
+}
@@ -0,0 +1,80 @@
+package conversation
+
+import (
+	"context"
+	"expvar"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// ephemeralSessionPrefix marks the IDs of sessions the storage has not accepted yet
+const ephemeralSessionPrefix = "ephemeral-"
+
+var (
+	// ephemeralSessions counts sessions kept in memory because the storage failed
+	ephemeralSessions = expvar.NewInt("conversation_ephemeral_sessions")
+	// ephemeralSessionsPersisted counts ephemeral sessions stored once the storage recovered
+	ephemeralSessionsPersisted = expvar.NewInt("conversation_ephemeral_sessions_persisted")
+)
+
+// isEphemeral reports whether session only exists in memory
+func isEphemeral(session *entities.Session) bool {
+	return strings.HasPrefix(session.ID, ephemeralSessionPrefix)
+}
+
+// startEphemeralSession returns a session kept in memory, so that the child
+// can keep talking while the storage is unavailable
+func (c *Conversation) startEphemeralSession(now time.Time, cause error) *entities.Session {
+	session := &entities.Session{
+		ID:            ephemeralSessionPrefix + uuid.NewString(),
+		DeviceID:      c.deviceID,
+		ChildID:       c.childID,
+		CreatedAt:     now,
+		LastMessageAt: now,
+	}
+	ephemeralSessions.Add(1)
+	c.logger.Warn("Session storage unavailable, continuing with an ephemeral session",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", session.ID),
+		zap.Error(cause))
+	return session
+}
+
+// persistEphemeralSession stores the current session, with its messages, if
+// it only exists in memory. On success the session takes the ID assigned by
+// the storage; otherwise it stays ephemeral until the next attempt.
+// The caller must hold the mutex.
+func (c *Conversation) persistEphemeralSession(ctx context.Context) {
+	if c.session == nil || !isEphemeral(c.session) {
+		return
+	}
+
+	persisted := *c.session
+	persisted.ID = ""
+	persisted.Messages = append([]entities.Message(nil), c.session.Messages...)
+	if err := c.engine.sessionRepo.Create(ctx, &persisted); err != nil {
+		c.logger.Debug("Session storage still unavailable",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", c.session.ID),
+			zap.Error(err))
+		return
+	}
+
+	ephemeralSessionsPersisted.Add(1)
+	c.logger.Info("Persisted ephemeral session",
+		zap.String("deviceID", c.deviceID),
+		zap.String("ephemeralSessionID", c.session.ID),
+		zap.String("sessionID", persisted.ID),
+		zap.Int("messages", len(persisted.Messages)))
+	// Turns still being answered hold this instance, so update it in place
+	c.session.ID = persisted.ID
+	c.session.CreatedAt = persisted.CreatedAt
+	c.session.StoredMessages = persisted.StoredMessages
+	c.publishLifecycle(events.SessionStarted, c.session.ID, "")
+}
@@ -0,0 +1,67 @@
+package conversation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+func TestEngine_ContinuesWithEphemeralSessionWhileStorageFails(t *testing.T) {
+	f := newEngineFixture(t, Config{EphemeralSessions: true}, &conversationtest.STT{Transcript: "halo"})
+	sub := f.bus.Subscribe(0)
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	f.sessions.SetUnavailable(true)
+
+	before := ephemeralSessions.Value()
+	for i := 0; i < 2; i++ {
+		turn := storedTurn(t, conv, sink)
+		if turn[len(turn)-1].Type != EventSpeakingEnd {
+			t.Fatalf("Expected the doll to answer, got %v", turn)
+		}
+	}
+	if got := ephemeralSessions.Value() - before; got != 1 {
+		t.Errorf("Expected one ephemeral session counted, got %d", got)
+	}
+	if messages := f.llm.Messages(); len(messages) != 2 {
+		t.Errorf("Expected both turns to reach the LLM, got %v", messages)
+	}
+	ephemeralID := conv.SessionID()
+	if !strings.HasPrefix(ephemeralID, ephemeralSessionPrefix) {
+		t.Fatalf("Expected an ephemeral session, got %q", ephemeralID)
+	}
+	if sessions := f.sessions.Sessions(); len(sessions) != 0 {
+		t.Fatalf("Expected nothing stored while the storage fails, got %d sessions", len(sessions))
+	}
+
+	// Once the storage recovers the session is stored with its history
+	f.sessions.SetUnavailable(false)
+	conv.StartListening(StartOptions{})
+	event := sink.next(t, EventListeningStart)
+	if event.Error != "" || event.SessionID == ephemeralID || strings.HasPrefix(event.SessionID, ephemeralSessionPrefix) {
+		t.Fatalf("Expected the session to be persisted, got %+v", event)
+	}
+	sessions := f.sessions.Sessions()
+	if len(sessions) != 1 || sessions[0].ID != event.SessionID || len(sessions[0].Messages) != 4 {
+		t.Fatalf("Expected the ephemeral session stored with its 4 messages, got %+v", sessions)
+	}
+	if lifecycle := nextLifecycle(t, sub); lifecycle.Type != events.SessionStarted || lifecycle.SessionID != event.SessionID {
+		t.Errorf("Expected the stored session to be announced, got %+v", lifecycle)
+	}
+	conv.EndListening()
+	sink.until(t, EventSpeakingEnd)
+}
+
+func TestEngine_FailsListeningWhileStorageFailsByDefault(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	f.sessions.SetUnavailable(true)
+
+	conv.StartListening(StartOptions{})
+	if event := sink.next(t, EventListeningStart); event.Error == "" {
+		t.Error("Expected listening to fail without ephemeral sessions")
+	}
+}
@@ -0,0 +1,84 @@
+package conversation
+
+import (
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// EventType identifies what happened in a conversation turn
+type EventType string
+
+const (
+	// EventListeningStart reports that a listening session started, or why it could not
+	EventListeningStart EventType = "listening_start"
+	// EventListeningEnd carries the final transcription, or why there is none
+	EventListeningEnd EventType = "listening_end"
+	// EventThinking reports that the transcription was handed to the LLM
+	EventThinking EventType = "thinking"
+	// EventFillerStart and EventFillerEnd frame the acknowledgement played during slow transcriptions
+	EventFillerStart EventType = "filler_start"
+	EventFillerEnd   EventType = "filler_end"
+	// EventSpeakingStart carries the response message before its audio
+	EventSpeakingStart EventType = "speaking_start"
+	// EventAudio carries one chunk of synthesized audio
+	EventAudio EventType = "audio"
+	// EventSpeakingEnd reports that all response audio was emitted
+	EventSpeakingEnd EventType = "speaking_end"
+	// EventError reports a failure after the transcription was accepted
+	EventError EventType = "error"
+)
+
+// Error reasons reported in Event.Error that transports may act upon
+const (
+	// ErrorDebounced rejects a listening start right after the doll spoke
+	ErrorDebounced = "debounced"
+	// ErrorTranscriptionUnavailable reports that speech-to-text could not be reached
+	ErrorTranscriptionUnavailable = "failed to initialize transcription"
+	// ErrorQuietHours rejects a listening start during the child's quiet hours.
+	// The bedtime phrase follows as a regular spoken response.
+	ErrorQuietHours = "quiet_hours"
+	// ErrorNoSpeech reports that the utterance ended without recognizable speech
+	ErrorNoSpeech = "no_speech"
+	// ErrorTTSQuotaExceeded reports that the response could not be spoken
+	// because the text-to-speech quota is exceeded and the speech unavailable
+	// phrase was not prewarmed
+	ErrorTTSQuotaExceeded = "tts_quota_exceeded"
+)
+
+// Event is a single typed output of the conversation pipeline.
+// Only the fields relevant to the event type are set.
+type Event struct {
+	Type      EventType
+	SessionID string
+	Timestamp time.Time
+
+	// Message is the transcription (EventListeningEnd) or response
+	// (EventSpeakingStart). With sentence pipelining EventSpeakingStart carries
+	// the first sentence only, and EventSpeakingEnd the whole response.
+	Message *entities.Message
+
+	// Audio is set for EventAudio
+	Audio []byte
+
+	// AudioEstimate is how much audio the response of an EventSpeakingStart
+	// is expected to have, nil when it cannot be told
+	AudioEstimate *AudioEstimate
+
+	// OutputSampleRate is the rate of the audio of an EventSpeakingStart
+	// response
+	OutputSampleRate int
+
+	// Error is a short machine-readable reason, empty on success
+	Error string
+
+	// Resumed is set for an EventListeningStart that continued the listening
+	// session held after the device's previous connection was lost
+	Resumed bool
+}
+
+// EventSink receives the events emitted by a conversation.
+// Emit is called sequentially for a single turn and must not block for long.
+type EventSink interface {
+	Emit(event Event)
+}
@@ -0,0 +1,95 @@
+package conversation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// confusionSlack is how many words besides a confusion cue an utterance may
+// have and still only say that the child did not understand, as in "aku masih
+// tidak mengerti". Longer utterances, like "aku tidak mengerti kenapa langit
+// biru", are questions of their own.
+const confusionSlack = 3
+
+// defaultConfusionCues are the phrases, by language, with which a child says
+// they did not understand the doll
+var defaultConfusionCues = map[string][]string{
+	"id": {"tidak mengerti", "nggak ngerti", "gak ngerti", "ga ngerti", "tidak paham", "nggak paham", "gak paham", "bingung", "maksudnya apa", "apa maksudnya"},
+	"en": {"don't understand", "do not understand", "don't get it", "what do you mean", "confused"},
+}
+
+// ParseConfusionCues parses confusion cues encoded as a JSON object of
+// phrases by BCP-47 language, e.g. {"id": ["tidak mengerti"], "en-US": ["huh"]}
+func ParseConfusionCues(data string) (map[string][]string, error) {
+	var cues map[string][]string
+	if err := json.Unmarshal([]byte(data), &cues); err != nil {
+		return nil, fmt.Errorf("failed to parse confusion cues: %w", err)
+	}
+	for language, phrases := range cues {
+		for _, phrase := range phrases {
+			if strings.TrimSpace(phrase) == "" {
+				return nil, fmt.Errorf("confusion cues of %q must not be empty", language)
+			}
+		}
+	}
+	return cues, nil
+}
+
+// confusionCuesFor returns the cues of language, falling back to those of
+// another region of the language
+func confusionCuesFor(cues map[string][]string, language string) []string {
+	if phrases, ok := cues[language]; ok {
+		return phrases
+	}
+	base, _, _ := strings.Cut(language, "-")
+	if base == "" {
+		return nil
+	}
+	for tag, phrases := range cues {
+		if tagBase, _, _ := strings.Cut(tag, "-"); strings.EqualFold(tagBase, base) {
+			return phrases
+		}
+	}
+	return nil
+}
+
+// isConfused reports whether text only says that the child did not
+// understand, using one of cues
+func isConfused(text string, cues []string) bool {
+	words := len(strings.Fields(text))
+	for _, cue := range matchTopics(text, cues) {
+		if words <= len(strings.Fields(cue))+confusionSlack {
+			return true
+		}
+	}
+	return false
+}
+
+// confusedAbout returns the doll's last response in session when message
+// says the child did not understand it, empty otherwise or when
+// ExplainSimpler is disabled.
+// The caller must hold the mutex.
+func (c *Conversation) confusedAbout(session *entities.Session, message string) string {
+	if !c.engine.config.ExplainSimpler {
+		return ""
+	}
+	cues := confusionCuesFor(c.engine.config.ConfusionCues, c.language(session))
+	if !isConfused(message, cues) {
+		return ""
+	}
+	for i := len(session.Messages) - 1; i >= 0; i-- {
+		if session.Messages[i].Role != entities.DollRole {
+			continue
+		}
+		c.logger.Info("Child is confused, explaining the previous response simpler",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", session.ID))
+		return session.Messages[i].Content
+	}
+	return ""
+}
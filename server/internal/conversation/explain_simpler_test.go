@@ -0,0 +1,86 @@
+package conversation
+
+import (
+	"testing"
+
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestIsConfused(t *testing.T) {
+	cues := confusionCuesFor(defaultConfusionCues, "en-US")
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"I don't understand", true},
+		{"Hmm, I still don't get it", true},
+		{"What do you mean?", true},
+		{"I don't understand why the sky is blue at night", false},
+		{"Tell me about dinosaurs", false},
+	}
+	for _, tt := range tests {
+		if got := isConfused(tt.text, cues); got != tt.want {
+			t.Errorf("isConfused(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+
+	if cues := confusionCuesFor(defaultConfusionCues, "fr-FR"); cues != nil {
+		t.Errorf("Expected no cues for a language without any, got %v", cues)
+	}
+	if _, err := ParseConfusionCues(`{"id": ["  "]}`); err == nil {
+		t.Error("Expected an empty cue to be refused")
+	}
+}
+
+func TestEngine_ExplainsPreviousResponseSimplerToConfusedChild(t *testing.T) {
+	stt := &conversationtest.STT{Transcript: "apa itu fotosintesis?"}
+	f := newEngineFixture(t, Config{ExplainSimpler: true, ResponseCache: true}, stt)
+	f.llm.Reply = "Fotosintesis adalah proses tumbuhan membuat makanan dari cahaya."
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+
+	// Saying it twice asks for the explanation again both times
+	stt.Transcript = "aku masih tidak mengerti"
+	for i := 0; i < 2; i++ {
+		speak(t, conv, sink)
+		sink.until(t, EventSpeakingEnd)
+	}
+
+	// Confusion about something new is a question of its own
+	stt.Transcript = "aku tidak mengerti kenapa langit itu biru"
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+
+	rephrases := f.llm.Rephrases()
+	want := []string{"", f.llm.Reply, f.llm.Reply, ""}
+	if len(rephrases) != len(want) {
+		t.Fatalf("Expected %d messages sent to the LLM, got %q", len(want), rephrases)
+	}
+	for i := range want {
+		if rephrases[i] != want[i] {
+			t.Errorf("Message %d: expected rephrase of %q, got %q", i, want[i], rephrases[i])
+		}
+	}
+}
+
+func TestEngine_TreatsConfusionAsNewQuestionWhenDisabled(t *testing.T) {
+	stt := &conversationtest.STT{Transcript: "apa itu fotosintesis?"}
+	f := newEngineFixture(t, Config{}, stt)
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	stt.Transcript = "aku tidak mengerti"
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+
+	for i, previous := range f.llm.Rephrases() {
+		if previous != "" {
+			t.Errorf("Message %d: expected no rephrase, got %q", i, previous)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package conversation
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// maxBargeInFadeOut bounds Config.BargeInFadeOut, since the fade holds back
+// as much audio from the device
+const maxBargeInFadeOut = 50 * time.Millisecond
+
+// fadeTail passes the audio of a response on, holding back its last bytes so
+// that a response cut short by the next utterance fades out instead of
+// ending with a click. Only 16-bit PCM is held back and faded.
+type fadeTail struct {
+	// size is the bytes held back, 0 when nothing is
+	size int
+	held []byte
+	emit func(audio []byte)
+}
+
+// newFadeTail returns a fadeTail emitting audio synthesized at sampleRate.
+// It holds nothing back unless Config.BargeInFadeOut is set and
+// text-to-speech streams PCM.
+func (c *Conversation) newFadeTail(sampleRate int, emit func(audio []byte)) *fadeTail {
+	tail := &fadeTail{emit: emit}
+	if c.engine.config.BargeInFadeOut > 0 && c.engine.pcm != nil && c.engine.pcm.OutputsPCM() {
+		samples := int(c.engine.config.BargeInFadeOut.Seconds() * float64(sampleRate))
+		tail.size = samples * 2
+	}
+	return tail
+}
+
+// push emits audio but for the last bytes of the response so far
+func (t *fadeTail) push(audio []byte) {
+	if t.size == 0 {
+		t.emit(audio)
+		return
+	}
+	t.held = append(t.held, audio...)
+	if excess := len(t.held) - t.size; excess > 0 {
+		t.emit(t.held[:excess])
+		t.held = append([]byte(nil), t.held[excess:]...)
+	}
+}
+
+// flush emits the bytes held back, faded out when the response was
+// interrupted. Flushing again emits nothing.
+func (t *fadeTail) flush(interrupted bool) {
+	if len(t.held) == 0 {
+		return
+	}
+	if interrupted {
+		fadeOutPCM(t.held)
+	}
+	t.emit(t.held)
+	t.held = nil
+}
+
+// fadeOutPCM ramps the 16-bit little-endian samples of audio linearly down
+// to silence, in place
+func fadeOutPCM(audio []byte) {
+	samples := len(audio) / 2
+	for i := 0; i < samples; i++ {
+		gain := float64(samples-1-i) / float64(samples)
+		sample := int16(binary.LittleEndian.Uint16(audio[2*i:]))
+		binary.LittleEndian.PutUint16(audio[2*i:], uint16(int16(float64(sample)*gain)))
+	}
+}
@@ -0,0 +1,122 @@
+package conversation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// toneChunk returns samples of 16-bit PCM at a constant amplitude
+func toneChunk(samples int, amplitude int16) []byte {
+	chunk := make([]byte, 2*samples)
+	for i := 0; i < samples; i++ {
+		binary.LittleEndian.PutUint16(chunk[2*i:], uint16(amplitude))
+	}
+	return chunk
+}
+
+// bargeIn interrupts an endless response with the next utterance and returns
+// the audio of the interrupted response emitted after the interruption
+func bargeIn(t *testing.T, f *engineFixture) [][]byte {
+	t.Helper()
+	f.tts.Endless = map[string]bool{f.llm.Reply: true}
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	t.Cleanup(func() {
+		// The next response is endless too, so its audio is drained until
+		// the conversation is closed
+		done := make(chan struct{})
+		go func() {
+			for {
+				select {
+				case <-sink.events:
+				case <-done:
+					return
+				}
+			}
+		}()
+		conv.Close()
+		close(done)
+	})
+
+	speak(t, conv, sink)
+	sink.next(t, EventAudio)
+	speak(t, conv, sink)
+
+	var audio [][]byte
+	for _, event := range sink.until(t, EventSpeakingEnd) {
+		if event.Type == EventAudio {
+			audio = append(audio, event.Audio)
+		}
+	}
+	return audio
+}
+
+func TestEngine_FadesOutInterruptedPCMResponse(t *testing.T) {
+	f := newEngineFixture(t, Config{OutputSampleRate: 8000, BargeInFadeOut: 10 * time.Millisecond}, &conversationtest.STT{Transcript: "halo"})
+	f.tts.PCM = true
+	f.tts.Chunks = [][]byte{toneChunk(100, 10000)}
+
+	audio := bargeIn(t, f)
+
+	if len(audio) == 0 {
+		t.Fatal("Expected the held back audio to be emitted once interrupted")
+	}
+	// 10ms at 8kHz are 80 samples
+	last := audio[len(audio)-1]
+	if len(last) != 160 {
+		t.Fatalf("Expected the last 160 bytes to be faded, got %d", len(last))
+	}
+	previous := int16(10000)
+	for i := 0; i < len(last)/2; i++ {
+		sample := int16(binary.LittleEndian.Uint16(last[2*i:]))
+		if sample > previous {
+			t.Fatalf("Expected the tail to fade out, sample %d rose to %d", i, sample)
+		}
+		previous = sample
+	}
+	if first := int16(binary.LittleEndian.Uint16(last)); first < 9000 {
+		t.Errorf("Expected the fade to start near the original amplitude, got %d", first)
+	}
+	if previous != 0 {
+		t.Errorf("Expected the fade to end in silence, got %d", previous)
+	}
+	for _, chunk := range audio[:len(audio)-1] {
+		if bytes.Count(chunk, []byte{0x10, 0x27}) != len(chunk)/2 {
+			t.Fatalf("Expected only the tail to be faded, got %v", chunk)
+		}
+	}
+}
+
+func TestEngine_DoesNotFadeOutWithoutPCM(t *testing.T) {
+	f := newEngineFixture(t, Config{OutputSampleRate: 8000, BargeInFadeOut: 10 * time.Millisecond}, &conversationtest.STT{Transcript: "halo"})
+	f.tts.Chunks = [][]byte{toneChunk(100, 10000)}
+
+	for _, chunk := range bargeIn(t, f) {
+		if !bytes.Equal(chunk, f.tts.Chunks[0]) {
+			t.Fatalf("Expected audio other than PCM to pass unchanged, got %v", chunk)
+		}
+	}
+}
+
+func TestFadeTail_PassesUninterruptedAudioUnchanged(t *testing.T) {
+	var emitted []byte
+	tail := &fadeTail{size: 4, emit: func(audio []byte) { emitted = append(emitted, audio...) }}
+
+	tail.push([]byte{1, 2, 3})
+	if len(emitted) != 0 {
+		t.Fatalf("Expected audio within the tail to be held back, got %v", emitted)
+	}
+	tail.push([]byte{4, 5, 6, 7})
+	if !bytes.Equal(emitted, []byte{1, 2, 3}) {
+		t.Fatalf("Expected the audio before the tail to be emitted, got %v", emitted)
+	}
+	tail.flush(false)
+	tail.flush(false)
+	if !bytes.Equal(emitted, []byte{1, 2, 3, 4, 5, 6, 7}) {
+		t.Errorf("Expected the tail to be emitted once unchanged, got %v", emitted)
+	}
+}
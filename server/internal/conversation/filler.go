@@ -0,0 +1,119 @@
+package conversation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// fillerPlayback plays a short acknowledgement while a transcription finalizes.
+// The filler only starts once the configured delay elapses, so fast
+// transcriptions never hear it.
+type fillerPlayback struct {
+	cancel  context.CancelFunc
+	done    chan struct{}
+	mu      sync.Mutex
+	started bool
+}
+
+// disarm cancels the filler if it has not started playing yet.
+// A filler that is already playing is left to finish.
+func (f *fillerPlayback) disarm() {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.started {
+		f.cancel()
+	}
+}
+
+// stop cancels the filler if it has not played yet, cuts it short if it is
+// playing, and blocks until it no longer writes to the client.
+func (f *fillerPlayback) stop() {
+	if f == nil {
+		return
+	}
+	f.cancel()
+	<-f.done
+}
+
+// startFiller schedules the filler for the current listening session.
+// It returns nil when the filler is disabled. The caller must hold the mutex.
+func (c *Conversation) startFiller(sessionID string) *fillerPlayback {
+	if !c.engine.config.FillerEnabled {
+		return nil
+	}
+	// The filler is the first step skipped while responses are degraded
+	if c.engine.degradationLevel() != repositories.DegradationNone {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = c.ttsContext(ctx)
+	filler := &fillerPlayback{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(filler.done)
+
+		timer := time.NewTimer(c.engine.config.FillerDelay)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		filler.mu.Lock()
+		if ctx.Err() != nil {
+			filler.mu.Unlock()
+			return
+		}
+		filler.started = true
+		filler.mu.Unlock()
+
+		c.playFiller(ctx, sessionID)
+	}()
+
+	return filler
+}
+
+// playFiller streams the filler audio framed by filler_start and filler_end
+func (c *Conversation) playFiller(ctx context.Context, sessionID string) {
+	audioDataChan, err := c.engine.cannedSpeech(ctx, c.engine.config.FillerPhrase)
+	if err != nil {
+		c.logger.Warn("Failed to synthesize filler audio",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", sessionID),
+			zap.Error(err))
+		return
+	}
+
+	c.logger.Debug("Playing filler while transcription finalizes",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", sessionID))
+
+	c.emit(Event{Type: EventFillerStart, SessionID: sessionID})
+	defer c.emit(Event{Type: EventFillerEnd, SessionID: sessionID})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case audioData, ok := <-audioDataChan:
+			if !ok {
+				return
+			}
+			c.emit(Event{Type: EventAudio, SessionID: sessionID, Audio: audioData})
+		}
+	}
+}
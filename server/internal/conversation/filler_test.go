@@ -0,0 +1,59 @@
+package conversation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestFiller_SuppressedWhenTranscriptionIsFast(t *testing.T) {
+	config := Config{FillerEnabled: true, FillerDelay: 200 * time.Millisecond}
+	f := newEngineFixture(t, config, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	events := sink.until(t, EventSpeakingEnd)
+
+	if indexOf(events, EventFillerStart) != -1 {
+		t.Errorf("Expected no filler for fast transcription, got %v", eventTypes(events))
+	}
+}
+
+func TestFiller_PlayedWhenTranscriptionIsSlow(t *testing.T) {
+	config := Config{FillerEnabled: true, FillerDelay: 20 * time.Millisecond}
+	f := newEngineFixture(t, config, &conversationtest.STT{Transcript: "halo", Delay: 200 * time.Millisecond})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	events := sink.until(t, EventSpeakingEnd)
+
+	fillerStart := indexOf(events, EventFillerStart)
+	fillerEnd := indexOf(events, EventFillerEnd)
+	speakingStart := indexOf(events, EventSpeakingStart)
+	if fillerStart == -1 || fillerEnd == -1 {
+		t.Fatalf("Expected filler for slow transcription, got %v", eventTypes(events))
+	}
+	if fillerEnd > speakingStart {
+		t.Errorf("Expected filler to end before speaking start, got %v", eventTypes(events))
+	}
+	if texts := f.tts.Texts(); texts[0] != defaultFillerPhrase {
+		t.Errorf("Expected filler phrase %q to be synthesized first, got %q", defaultFillerPhrase, texts[0])
+	}
+}
+
+func TestFiller_DisabledByDefault(t *testing.T) {
+	config := Config{FillerDelay: time.Millisecond}
+	f := newEngineFixture(t, config, &conversationtest.STT{Transcript: "halo", Delay: 100 * time.Millisecond})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	events := sink.until(t, EventSpeakingEnd)
+
+	if indexOf(events, EventFillerStart) != -1 {
+		t.Errorf("Expected no filler when disabled, got %v", eventTypes(events))
+	}
+}
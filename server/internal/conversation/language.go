@@ -0,0 +1,97 @@
+package conversation
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// languageSources are the languages a conversation may be held in, by where
+// they come from; empty when a source names none
+type languageSources struct {
+	// Locked is the language the parent locked the child to
+	Locked string
+	// Request is the language the device heard the utterance in
+	Request string
+	// Session is the language the session was held in so far
+	Session string
+	// Child is the language the child usually speaks, from their profile
+	Child string
+	// Device is the language the device announced on connecting
+	Device string
+	// Default is the language of the server, Config.DefaultLanguage
+	Default string
+}
+
+// resolveLanguage returns the language of a turn. A locked language always
+// applies; otherwise the first language named by the request, the session,
+// the child's profile and the device, in that order, falling back to the
+// default.
+func resolveLanguage(sources languageSources) string {
+	for _, language := range []string{sources.Locked, sources.Request, sources.Session, sources.Child, sources.Device} {
+		if language != "" {
+			return language
+		}
+	}
+	return sources.Default
+}
+
+// languageSources returns the languages the conversation may be held in,
+// besides the request's, with session as the current session.
+// The caller must hold the mutex.
+func (c *Conversation) languageSources(session *entities.Session) languageSources {
+	sources := languageSources{
+		Locked:  c.forcedLanguage,
+		Child:   c.childLanguage,
+		Device:  c.deviceLanguage,
+		Default: c.engine.config.DefaultLanguage,
+	}
+	if session != nil {
+		sources.Session = session.Metadata.Language
+	}
+	return sources
+}
+
+// language returns the language of the conversation in session, for
+// speech-to-text, the LLM, text-to-speech and the canned phrases alike.
+// The caller must hold the mutex.
+func (c *Conversation) language(session *entities.Session) string {
+	return resolveLanguage(c.languageSources(session))
+}
+
+// listeningLanguage returns the language of the utterance starting, which
+// the device heard in requested, empty when it did not say.
+// The caller must hold the mutex.
+func (c *Conversation) listeningLanguage(requested string) string {
+	sources := c.languageSources(c.session)
+	sources.Request = requested
+	// A monolingual household keeps its language whatever the child speaks
+	if sources.Locked != "" && requested != "" && requested != sources.Locked {
+		c.logger.Debug("Ignoring device language, the child's language is locked",
+			zap.String("deviceID", c.deviceID),
+			zap.String("language", requested),
+			zap.String("forcedLanguage", sources.Locked))
+	}
+	return resolveLanguage(sources)
+}
+
+// SetDeviceLanguage sets the language the device announced, used when neither
+// the utterance, the session nor the child's profile name one. An invalid
+// BCP-47 tag is ignored.
+func (c *Conversation) SetDeviceLanguage(language string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if language == "" {
+		c.deviceLanguage = ""
+		return
+	}
+	normalized, err := entities.NormalizeLanguageTag(language)
+	if err != nil {
+		c.logger.Warn("Ignoring invalid device language",
+			zap.String("deviceID", c.deviceID),
+			zap.String("language", language),
+			zap.Error(err))
+		return
+	}
+	c.deviceLanguage = normalized
+}
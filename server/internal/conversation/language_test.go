@@ -0,0 +1,181 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestEngine_HonorsForcedLanguage(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "can we speak English?"})
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}, ForcedLanguage: "id-ID"}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	// The device heard English, but the household only speaks Indonesian
+	conv.StartListening(StartOptions{Language: "en-US"})
+	sink.next(t, EventListeningStart)
+	conv.StreamAudio([]byte{0x01})
+	conv.EndListening()
+	sink.until(t, EventSpeakingEnd)
+
+	if got := f.stt.Streams()[0].Config.Language; got != "id-ID" {
+		t.Errorf("Expected speech-to-text in the forced language, got %q", got)
+	}
+	if got := f.llm.ReplyLanguages(); len(got) != 1 || got[0] != "id-ID" {
+		t.Errorf("Expected the LLM to answer in the forced language, got %v", got)
+	}
+	if got := f.tts.Languages(); len(got) != 1 || got[0] != "id-ID" {
+		t.Errorf("Expected the voice of the forced language, got %v", got)
+	}
+}
+
+func TestEngine_FollowsDeviceLanguageWithoutLock(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "hello"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	conv.StartListening(StartOptions{Language: "en-US"})
+	sink.next(t, EventListeningStart)
+	conv.StreamAudio([]byte{0x01})
+	conv.EndListening()
+	sink.until(t, EventSpeakingEnd)
+
+	if got := f.stt.Streams()[0].Config.Language; got != "en-US" {
+		t.Errorf("Expected speech-to-text in the device's language, got %q", got)
+	}
+	if got := f.llm.ReplyLanguages(); len(got) != 1 || got[0] != "" {
+		t.Errorf("Expected no reply language without a lock, got %v", got)
+	}
+	if got := f.tts.Languages(); len(got) != 1 || got[0] != "en-US" {
+		t.Errorf("Expected the voice of the device's language, got %v", got)
+	}
+}
+
+func TestResolveLanguage(t *testing.T) {
+	all := languageSources{
+		Locked:  "ja-JP",
+		Request: "en-US",
+		Session: "en-GB",
+		Child:   "jv-ID",
+		Device:  "su-ID",
+		Default: "id-ID",
+	}
+
+	tests := []struct {
+		name    string
+		sources func(s languageSources) languageSources
+		want    string
+	}{
+		{"lock beats everything", func(s languageSources) languageSources { return s }, "ja-JP"},
+		{"request beats the session", func(s languageSources) languageSources { s.Locked = ""; return s }, "en-US"},
+		{"session beats the child", func(s languageSources) languageSources {
+			s.Locked, s.Request = "", ""
+			return s
+		}, "en-GB"},
+		{"child beats the device", func(s languageSources) languageSources {
+			s.Locked, s.Request, s.Session = "", "", ""
+			return s
+		}, "jv-ID"},
+		{"device beats the default", func(s languageSources) languageSources {
+			s.Locked, s.Request, s.Session, s.Child = "", "", "", ""
+			return s
+		}, "su-ID"},
+		{"default when nothing names one", func(s languageSources) languageSources {
+			return languageSources{Default: s.Default}
+		}, "id-ID"},
+		{"request without a session", func(s languageSources) languageSources {
+			return languageSources{Request: s.Request, Child: s.Child, Default: s.Default}
+		}, "en-US"},
+		{"device without a child", func(s languageSources) languageSources {
+			return languageSources{Device: s.Device, Default: s.Default}
+		}, "su-ID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveLanguage(tt.sources(all)); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEngine_ResolvesLanguageAcrossSources(t *testing.T) {
+	f := newEngineFixture(t, Config{DefaultLanguage: "ms-my"}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	listen := func(language string) string {
+		t.Helper()
+		conv.StartListening(StartOptions{Language: language})
+		sink.next(t, EventListeningStart)
+		conv.StreamAudio([]byte{0x01})
+		conv.EndListening()
+		sink.until(t, EventSpeakingEnd)
+		streams := f.stt.Streams()
+		return streams[len(streams)-1].Config.Language
+	}
+
+	// The configured default, normalized, without anything else
+	if got := listen(""); got != "ms-MY" {
+		t.Errorf("Expected the configured default language, got %q", got)
+	}
+	if got := f.tts.Languages(); got[len(got)-1] != "ms-MY" {
+		t.Errorf("Expected the voice of the default language, got %v", got)
+	}
+
+	// The session keeps its language over the device's
+	conv.SetDeviceLanguage("en-US")
+	if got := listen(""); got != "ms-MY" {
+		t.Errorf("Expected the session's language over the device's, got %q", got)
+	}
+
+	// The utterance's language beats the session's, and the session follows it
+	if got := listen("jv-ID"); got != "jv-ID" {
+		t.Errorf("Expected the utterance's language, got %q", got)
+	}
+	if got := listen(""); got != "jv-ID" {
+		t.Errorf("Expected the session to keep the utterance's language, got %q", got)
+	}
+}
+
+func TestEngine_ChildLanguageBeatsDeviceLanguage(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "hello"})
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}, Language: "en-GB"}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	conv.SetDeviceLanguage("su-id")
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+
+	if got := f.stt.Streams()[0].Config.Language; got != "en-GB" {
+		t.Errorf("Expected the child's language over the device's, got %q", got)
+	}
+	if got := f.llm.ReplyLanguages(); len(got) != 1 || got[0] != "" {
+		t.Errorf("Expected no reply language without a lock, got %v", got)
+	}
+}
+
+func TestEngine_DeviceLanguageWithoutChild(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "sampurasun"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	conv.SetDeviceLanguage("su-id")
+	conv.SetDeviceLanguage("not a language")
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+
+	if got := f.stt.Streams()[0].Config.Language; got != "su-ID" {
+		t.Errorf("Expected the device's normalized language, got %q", got)
+	}
+}
@@ -0,0 +1,87 @@
+package conversation
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+const (
+	// learnedWordsTimeout bounds picking the new words of a turn and storing them
+	learnedWordsTimeout = 30 * time.Second
+	// learnedWordsReinforced is how many of the words learned last new chat
+	// sessions reinforce
+	learnedWordsReinforced = 5
+)
+
+// trackLearnedWords picks the notable new words of a turn of a consenting
+// child in the background and stores them on the child. Once the child
+// learned Config.LearnedWordsDailyMax words today, turns are not inspected
+// until the next day, which bounds the cost of tracking.
+// The caller must hold the mutex.
+func (c *Conversation) trackLearnedWords(sessionID string, child, doll entities.Message) {
+	extractor := c.engine.wordExtractor
+	if extractor == nil || c.childID == "" || !c.vocabularyConsent {
+		return
+	}
+
+	childID := c.childID
+	childRepo := c.engine.childRepo
+	dailyMax := c.engine.config.LearnedWordsDailyMax
+	now := c.localTime()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	logger := c.logger.With(zap.String("childID", childID), zap.String("sessionID", sessionID))
+
+	c.background(func() {
+		// Turns of the conversation are tracked one after the other, so that
+		// the words of one are known to the next
+		c.wordsMu.Lock()
+		defer c.wordsMu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), learnedWordsTimeout)
+		defer cancel()
+
+		profile, err := childRepo.GetByID(ctx, childID)
+		if err != nil {
+			logger.Warn("Failed to look up child for learned words", zap.Error(err))
+			return
+		}
+		if !profile.VocabularyConsent || profile.LearnedWordsSince(today) >= dailyMax {
+			return
+		}
+
+		words, err := extractor.ExtractLearnedWords(ctx, child, doll, profile.RecentLearnedWords(entities.MaxLearnedWords))
+		if err != nil {
+			logger.Warn("Failed to extract learned words", zap.Error(err))
+			return
+		}
+		if len(words) == 0 {
+			return
+		}
+
+		// The profile may have changed while the words were picked
+		profile, err = childRepo.GetByID(ctx, childID)
+		if err != nil || !profile.VocabularyConsent {
+			return
+		}
+		if remaining := dailyMax - profile.LearnedWordsSince(today); len(words) > remaining {
+			words = words[:max(remaining, 0)]
+		}
+		for i := range words {
+			words[i].SessionID = sessionID
+			words[i].LearnedAt = now
+		}
+		added := profile.AddLearnedWords(words)
+		if len(added) == 0 {
+			return
+		}
+		if err := childRepo.Update(ctx, profile); err != nil {
+			logger.Warn("Failed to store learned words", zap.Error(err))
+			return
+		}
+		logger.Info("Stored learned words", zap.Int("words", len(added)))
+	})
+}
@@ -0,0 +1,101 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// createLearningChild assigns device-1 to a child, whose parent consented to
+// the new words being tracked when consent is set
+func createLearningChild(t *testing.T, f *engineFixture, consent bool) *entities.Child {
+	t.Helper()
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}, VocabularyConsent: consent}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	return child
+}
+
+// turns runs n turns of a conversation of device-1 and waits for its
+// background work
+func turns(t *testing.T, f *engineFixture, n int) {
+	t.Helper()
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	for i := 0; i < n; i++ {
+		speak(t, conv, sink)
+		sink.until(t, EventSpeakingEnd)
+	}
+	conv.Close()
+}
+
+func TestEngine_TracksAndReinforcesLearnedWords(t *testing.T) {
+	f := newEngineFixture(t, Config{LearnedWords: true}, &conversationtest.STT{Transcript: "kenapa daun hijau"})
+	f.llm.LearnedWords = []entities.LearnedWord{{Word: "klorofil", Source: entities.LearnedFromDoll}}
+	child := createLearningChild(t, f, true)
+
+	turns(t, f, 1)
+
+	extractions := f.llm.Extractions()
+	if len(extractions) != 1 || extractions[0].Child.Content != "kenapa daun hijau" || extractions[0].Doll.Content != "Halo juga!" {
+		t.Fatalf("Expected the words of the turn extracted, got %+v", extractions)
+	}
+	stored, _ := f.children.GetByID(context.Background(), child.ID)
+	if len(stored.LearnedWords) != 1 {
+		t.Fatalf("Expected the learned word stored on the child, got %+v", stored.LearnedWords)
+	}
+	word := stored.LearnedWords[0]
+	sessions := f.sessions.Sessions()
+	if word.Word != "klorofil" || word.Source != entities.LearnedFromDoll || word.SessionID != sessions[0].ID || word.LearnedAt.IsZero() {
+		t.Errorf("Expected the word stored with its session, got %+v", word)
+	}
+
+	// A later session reinforces it, and it is known to the next extraction
+	turns(t, f, 1)
+	practiced := f.llm.Practiced()
+	if len(practiced) != 2 || len(practiced[0]) != 0 || len(practiced[1]) != 1 || practiced[1][0] != "klorofil" {
+		t.Errorf("Expected the next chat session to reinforce the word, got %q", practiced)
+	}
+	if extractions := f.llm.Extractions(); len(extractions[1].Known) != 1 || extractions[1].Known[0] != "klorofil" {
+		t.Errorf("Expected the known words passed to the extraction, got %+v", extractions[1])
+	}
+}
+
+func TestEngine_BoundsLearnedWordsPerDay(t *testing.T) {
+	f := newEngineFixture(t, Config{LearnedWords: true, LearnedWordsDailyMax: 2}, &conversationtest.STT{Transcript: "halo"})
+	f.llm.LearnedWords = []entities.LearnedWord{{Word: "gerhana"}, {Word: "orbit"}, {Word: "planet"}}
+	child := createLearningChild(t, f, true)
+
+	turns(t, f, 2)
+
+	stored, _ := f.children.GetByID(context.Background(), child.ID)
+	if len(stored.LearnedWords) != 2 || stored.LearnedWords[1].Word != "orbit" {
+		t.Errorf("Expected the words of the day bounded, got %+v", stored.LearnedWords)
+	}
+	// Once the day's words were learned, turns are not inspected
+	if extractions := f.llm.Extractions(); len(extractions) != 1 {
+		t.Errorf("Expected a single extraction, got %d", len(extractions))
+	}
+}
+
+func TestEngine_TracksNoWordsWithoutConsent(t *testing.T) {
+	f := newEngineFixture(t, Config{LearnedWords: true}, &conversationtest.STT{Transcript: "halo"})
+	f.llm.LearnedWords = []entities.LearnedWord{{Word: "gerhana"}}
+	child := createLearningChild(t, f, false)
+	child.LearnedWords = []entities.LearnedWord{{Word: "orbit", Source: entities.LearnedFromChild}}
+	if err := f.children.Update(context.Background(), child); err != nil {
+		t.Fatalf("Failed to update child: %v", err)
+	}
+
+	turns(t, f, 1)
+
+	if extractions := f.llm.Extractions(); len(extractions) != 0 {
+		t.Errorf("Expected no extraction without consent, got %+v", extractions)
+	}
+	if practiced := f.llm.Practiced(); len(practiced[0]) != 0 {
+		t.Errorf("Expected no word reinforced without consent, got %q", practiced)
+	}
+}
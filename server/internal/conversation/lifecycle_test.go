@@ -0,0 +1,74 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// nextLifecycle returns the next lifecycle event or fails the test
+func nextLifecycle(t *testing.T, sub *events.Subscription) events.Event {
+	t.Helper()
+	select {
+	case event := <-sub.Events():
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a lifecycle event")
+		return events.Event{}
+	}
+}
+
+func TestConversation_PublishesSessionLifecycle(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	child := &entities.Child{OwnerID: "user-1", Name: "Ayu", DeviceIDs: []string{"device-1"}}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	sub := f.bus.Subscribe(0)
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	conv.StartListening(StartOptions{})
+	sink.next(t, EventListeningStart)
+
+	started := nextLifecycle(t, sub)
+	if started.Type != events.SessionStarted || started.SessionID != conv.SessionID() {
+		t.Fatalf("Expected session_started for %q, got %+v", conv.SessionID(), started)
+	}
+	if started.DeviceID != "device-1" || started.ChildID != child.ID || started.Timestamp.IsZero() {
+		t.Errorf("Expected device, child and timestamp on the event, got %+v", started)
+	}
+	firstSession := conv.SessionID()
+
+	// Continuing the same session publishes nothing
+	conv.StartListening(StartOptions{})
+	sink.next(t, EventListeningStart)
+
+	// Let the session go idle
+	conv.mutex.Lock()
+	conv.session.LastMessageAt = time.Now().Add(-time.Hour)
+	conv.mutex.Unlock()
+
+	conv.StartListening(StartOptions{})
+	sink.next(t, EventListeningStart)
+
+	ended := nextLifecycle(t, sub)
+	if ended.Type != events.SessionEnded || ended.SessionID != firstSession || ended.Reason != events.ReasonIdle {
+		t.Fatalf("Expected idle session_ended for %q, got %+v", firstSession, ended)
+	}
+	restarted := nextLifecycle(t, sub)
+	if restarted.Type != events.SessionStarted || restarted.SessionID == firstSession {
+		t.Fatalf("Expected a new session_started, got %+v", restarted)
+	}
+
+	conv.Forget()
+
+	erased := nextLifecycle(t, sub)
+	if erased.Type != events.SessionEnded || erased.SessionID != restarted.SessionID || erased.Reason != events.ReasonErased {
+		t.Errorf("Expected erased session_ended for %q, got %+v", restarted.SessionID, erased)
+	}
+}
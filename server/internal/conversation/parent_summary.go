@@ -0,0 +1,73 @@
+package conversation
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// parentSummaryTimeout bounds summarizing an ended session and storing the
+// summary
+const parentSummaryTimeout = 30 * time.Second
+
+// summarizeForParent asks the LLM for a summary of an ended session of the
+// child in the background and stores it on the session. The messages are
+// redacted of the personal information the patterns find even when PII
+// redaction of stored transcriptions is disabled. A session that cannot be
+// summarized is left without a summary.
+// The caller must hold the mutex.
+func (c *Conversation) summarizeForParent(session *entities.Session) {
+	summarizer := c.engine.summarizer
+	if summarizer == nil || c.childID == "" || !hasChildMessages(session) {
+		return
+	}
+
+	redactor := c.engine.redactor
+	if redactor == nil {
+		redactor = &piiRedactor{}
+	}
+	stored := *session
+	stored.Messages = append([]entities.Message(nil), session.Messages...)
+	summarized := make([]entities.Message, len(stored.Messages))
+	for i, message := range stored.Messages {
+		message.Content = redactor.redactPatterns(message.Content)
+		summarized[i] = message
+	}
+
+	sessionRepo := c.engine.sessionRepo
+	now := c.engine.now
+	c.background(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), parentSummaryTimeout)
+		defer cancel()
+
+		summary, err := summarizer.SummarizeForParent(ctx, summarized)
+		if err != nil {
+			c.logger.Warn("Failed to summarize session for parent",
+				zap.String("sessionID", stored.ID),
+				zap.Error(err))
+			return
+		}
+		summary.GeneratedAt = now()
+
+		// The stored messages are kept as they were, unredacted or not
+		stored.ParentSummary = summary
+		if err := sessionRepo.Update(ctx, &stored); err != nil {
+			c.logger.Warn("Failed to store parent summary",
+				zap.String("sessionID", stored.ID),
+				zap.Error(err))
+		}
+	})
+}
+
+// hasChildMessages reports whether the child said anything in the session
+func hasChildMessages(session *entities.Session) bool {
+	for _, message := range session.Messages {
+		if role, _ := entities.ParseRole(string(message.Role)); role == entities.UserRole {
+			return true
+		}
+	}
+	return false
+}
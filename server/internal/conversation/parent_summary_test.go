@@ -0,0 +1,93 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// endIdleSession starts listening on a device whose last session of the
+// child is idle, ending it, and waits for the background work to finish
+func endIdleSession(t *testing.T, f *engineFixture) *entities.Session {
+	t.Helper()
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	at := time.Now().Add(-time.Hour)
+	old := &entities.Session{
+		DeviceID:      "device-1",
+		LastMessageAt: at,
+		Messages: []entities.Message{
+			{Timestamp: at, Role: entities.UserRole, Content: "nomor ibuku 0812 3456 7890"},
+			{Timestamp: at, Role: entities.DollRole, Content: "Terima kasih sudah cerita!"},
+		},
+	}
+	if err := f.sessions.Create(context.Background(), old); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	conv.StartListening(StartOptions{})
+	if event := sink.next(t, EventListeningStart); event.Error != "" {
+		t.Fatalf("Failed to start listening: %s", event.Error)
+	}
+	conv.Close()
+
+	sessions := f.sessions.Sessions()
+	if len(sessions) != 2 || sessions[0].ID != old.ID || sessions[0].EndedAt.IsZero() {
+		t.Fatalf("Expected the old session to be ended, got %+v", sessions)
+	}
+	return sessions[0]
+}
+
+func TestEngine_StoresParentSummaryOfEndedSession(t *testing.T) {
+	f := newEngineFixture(t, Config{ParentSummaries: true}, &conversationtest.STT{Transcript: "halo"})
+	f.llm.Summary = entities.ParentSummary{Summary: "Kirana bercerita tentang ibunya.", Topics: []string{"keluarga"}, Mood: "ceria"}
+
+	ended := endIdleSession(t, f)
+
+	summarized := f.llm.Summarized()
+	if len(summarized) != 1 || len(summarized[0]) != 2 {
+		t.Fatalf("Expected the messages of the ended session summarized, got %+v", summarized)
+	}
+	if summarized[0][0].Content != "nomor ibuku [PHONE]" {
+		t.Errorf("Expected the messages redacted before summarizing, got %q", summarized[0][0].Content)
+	}
+	summary := ended.ParentSummary
+	if summary == nil || summary.Summary != f.llm.Summary.Summary || summary.Mood != "ceria" || summary.GeneratedAt.IsZero() {
+		t.Fatalf("Expected the summary stored on the session, got %+v", summary)
+	}
+	if ended.Messages[0].Content != "nomor ibuku 0812 3456 7890" {
+		t.Errorf("Expected the stored messages kept as they were, got %q", ended.Messages[0].Content)
+	}
+}
+
+func TestEngine_OmitsParentSummaryWhenLLMFails(t *testing.T) {
+	f := newEngineFixture(t, Config{ParentSummaries: true}, &conversationtest.STT{Transcript: "halo"})
+	f.llm.SummaryErr = errors.New("quota exceeded")
+
+	ended := endIdleSession(t, f)
+
+	if len(f.llm.Summarized()) != 1 {
+		t.Errorf("Expected a summary to be attempted")
+	}
+	if ended.ParentSummary != nil {
+		t.Errorf("Expected no summary stored, got %+v", ended.ParentSummary)
+	}
+}
+
+func TestEngine_SkipsParentSummaryWhenDisabled(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+
+	ended := endIdleSession(t, f)
+
+	if calls := f.llm.Summarized(); len(calls) != 0 || ended.ParentSummary != nil {
+		t.Errorf("Expected no summary without ParentSummaries, got %d calls", len(calls))
+	}
+}
@@ -0,0 +1,405 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// With sentence pipelining the response of a streaming chat session is
+// synthesized sentence by sentence while the rest is still being generated.
+// Up to Config.PipelineConcurrency sentences are synthesized ahead of the
+// playback at once, and their audio is played strictly in sentence order.
+
+// errNoAudio fails the synthesis of a sentence that produced no audio, as a
+// failed text-to-speech request does
+var errNoAudio = errors.New("no audio synthesized")
+
+// sentenceSplitter cuts streamed text into sentences
+type sentenceSplitter struct {
+	pending string
+}
+
+// push adds text and returns the sentences it completed
+func (s *sentenceSplitter) push(text string) []string {
+	s.pending += text
+	var sentences []string
+	start := 0
+	runes := []rune(s.pending)
+	for i, r := range runes {
+		end := r == '\n'
+		if strings.ContainsRune(".!?…", r) && i+1 < len(runes) && unicode.IsSpace(runes[i+1]) {
+			end = true
+		}
+		if !end {
+			continue
+		}
+		if sentence := strings.TrimSpace(string(runes[start : i+1])); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		start = i + 1
+	}
+	s.pending = string(runes[start:])
+	return sentences
+}
+
+// flush returns the text left after the last complete sentence
+func (s *sentenceSplitter) flush() string {
+	rest := strings.TrimSpace(s.pending)
+	s.pending = ""
+	return rest
+}
+
+// pipelinedSentence is a sentence of the response and its audio not yet played
+type pipelinedSentence struct {
+	text   string
+	chunks [][]byte
+	// slot is set once the sentence holds a synthesis slot
+	slot bool
+	done bool
+	err  error
+}
+
+// sentencePipeline synthesizes the sentences added to it with a bounded
+// number of concurrent syntheses and plays their audio in order. Sentences
+// are kept by index until played, so that audio arriving out of order waits
+// for the sentences before it.
+type sentencePipeline struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	tts    repositories.TextToSpeech
+	slots  chan struct{}
+
+	mu        sync.Mutex
+	ready     *sync.Cond
+	sentences []*pipelinedSentence
+	closed    bool
+}
+
+func newSentencePipeline(ctx context.Context, tts repositories.TextToSpeech, concurrency int) *sentencePipeline {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &sentencePipeline{
+		ctx:    ctx,
+		cancel: cancel,
+		tts:    tts,
+		slots:  make(chan struct{}, concurrency),
+	}
+	p.ready = sync.NewCond(&p.mu)
+	// Waiters give up once the pipeline is stopped
+	context.AfterFunc(ctx, func() {
+		p.mu.Lock()
+		p.ready.Broadcast()
+		p.mu.Unlock()
+	})
+	go p.dispatch()
+	return p
+}
+
+// add queues the next sentence for synthesis and returns its index
+func (p *sentencePipeline) add(text string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sentence := &pipelinedSentence{text: text}
+	if err := p.ctx.Err(); err != nil {
+		sentence.done, sentence.err = true, err
+	}
+	p.sentences = append(p.sentences, sentence)
+	p.ready.Broadcast()
+	return len(p.sentences) - 1
+}
+
+// close reports that no more sentences follow
+func (p *sentencePipeline) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.ready.Broadcast()
+}
+
+// stop cancels the syntheses in progress and those not started yet
+func (p *sentencePipeline) stop() {
+	p.cancel()
+}
+
+// dispatch starts the synthesis of the sentences in order, each once a slot
+// is free
+func (p *sentencePipeline) dispatch() {
+	for i := 0; ; i++ {
+		p.mu.Lock()
+		for i >= len(p.sentences) && !p.closed && p.ctx.Err() == nil {
+			p.ready.Wait()
+		}
+		if p.ctx.Err() != nil {
+			p.failLocked(i, p.ctx.Err())
+			p.mu.Unlock()
+			return
+		}
+		if i >= len(p.sentences) {
+			p.mu.Unlock()
+			return
+		}
+		sentence := p.sentences[i]
+		p.mu.Unlock()
+
+		select {
+		case p.slots <- struct{}{}:
+		case <-p.ctx.Done():
+			p.mu.Lock()
+			p.failLocked(i, p.ctx.Err())
+			p.mu.Unlock()
+			return
+		}
+
+		p.mu.Lock()
+		sentence.slot = true
+		p.mu.Unlock()
+		go p.synthesize(sentence)
+	}
+}
+
+// failLocked fails the sentences from index from on that are not done.
+// The caller must hold the mutex.
+func (p *sentencePipeline) failLocked(from int, err error) {
+	for _, sentence := range p.sentences[min(from, len(p.sentences)):] {
+		if !sentence.done {
+			sentence.done, sentence.err = true, err
+		}
+	}
+	p.ready.Broadcast()
+}
+
+// synthesize converts a sentence to speech, keeping its audio for playback
+func (p *sentencePipeline) synthesize(sentence *pipelinedSentence) {
+	audio, err := p.tts.ConvertTextToSpeech(p.ctx, sentence.text)
+	received := 0
+	if err == nil {
+		for chunk := range audio {
+			received++
+			p.mu.Lock()
+			sentence.chunks = append(sentence.chunks, chunk)
+			p.ready.Broadcast()
+			p.mu.Unlock()
+		}
+		if received == 0 {
+			err = errNoAudio
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !sentence.done {
+		sentence.done, sentence.err = true, err
+	}
+	p.ready.Broadcast()
+}
+
+// play emits the audio of the sentences in order as it is synthesized. When a
+// sentence fails the pipeline is stopped after the audio it produced, and the
+// error is returned.
+func (p *sentencePipeline) play(emit func(audio []byte)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := 0; ; i++ {
+		for i >= len(p.sentences) && !p.closed && p.ctx.Err() == nil {
+			p.ready.Wait()
+		}
+		if i >= len(p.sentences) {
+			if err := p.ctx.Err(); err != nil {
+				return err
+			}
+			return nil
+		}
+
+		sentence := p.sentences[i]
+		for {
+			for len(sentence.chunks) == 0 && !sentence.done && p.ctx.Err() == nil {
+				p.ready.Wait()
+			}
+			if len(sentence.chunks) == 0 && !sentence.done {
+				return p.ctx.Err()
+			}
+			if len(sentence.chunks) == 0 {
+				break
+			}
+			// Played audio is not kept
+			chunk := sentence.chunks[0]
+			sentence.chunks[0] = nil
+			sentence.chunks = sentence.chunks[1:]
+			p.mu.Unlock()
+			emit(chunk)
+			p.mu.Lock()
+		}
+
+		if sentence.slot {
+			<-p.slots
+		}
+		if sentence.err != nil {
+			p.cancel()
+			return fmt.Errorf("failed to synthesize sentence %d: %w", i, sentence.err)
+		}
+	}
+}
+
+// streamedResponse is the outcome of a streamed chat response
+type streamedResponse struct {
+	message    entities.Message
+	err        error
+	generating time.Duration
+}
+
+// responseAudio speaks the response of a streaming chat session sentence by
+// sentence as it is generated, starting with EventSpeakingStart, and returns
+// the response. Its audio is returned when keepAudio is set. When a sentence
+// cannot be synthesized the rest of the response is dropped and the pipeline
+// fallback phrase is spoken instead; no audio is returned then. A response
+// reaching the audio cap of budget stops being generated and is wound down.
+func (c *Conversation) responseAudio(llmCtx, ttsCtx context.Context, sessionID string, chatSession repositories.StreamingChatSession, prompt entities.Message, keepAudio bool, filler *fillerPlayback, budget *audioBudget, turn *turnSummary) (entities.Message, [][]byte, error) {
+	llmCtx, cancelLLM := context.WithCancel(llmCtx)
+	defer cancelLLM()
+	pipeline := newSentencePipeline(ttsCtx, c.engine.ttsRepo, c.engine.config.PipelineConcurrency)
+	defer pipeline.stop()
+
+	first := make(chan string, 1)
+	generated := make(chan streamedResponse, 1)
+	go func() {
+		generating := time.Now()
+		var splitter sentenceSplitter
+		add := func(sentence string) {
+			if pipeline.add(sentence) == 0 {
+				first <- sentence
+			}
+		}
+		response, err := chatSession.SendMessageStream(llmCtx, prompt, func(text string) {
+			for _, sentence := range splitter.push(text) {
+				add(sentence)
+			}
+		})
+		if rest := splitter.flush(); rest != "" && err == nil {
+			add(rest)
+		}
+		pipeline.close()
+		generated <- streamedResponse{message: response, err: err, generating: time.Since(generating)}
+	}()
+
+	// Speaking starts with the first sentence, or once the response is
+	// complete when it has none
+	var outcome *streamedResponse
+	var firstSentence string
+	select {
+	case firstSentence = <-first:
+	case result := <-generated:
+		outcome = &result
+		select {
+		case firstSentence = <-first:
+		default:
+		}
+		if result.err != nil && firstSentence == "" {
+			return entities.Message{}, nil, result.err
+		}
+	}
+
+	filler.stop()
+
+	synthesizing := time.Now()
+	var audio [][]byte
+	sampleRate, ok := repositories.SampleRateFromContext(ttsCtx)
+	if !ok {
+		sampleRate = c.engine.config.OutputSampleRate
+	}
+	c.emit(Event{Type: EventSpeakingStart, SessionID: sessionID, Message: &entities.Message{
+		Timestamp: time.Now(),
+		Role:      entities.DollRole,
+		Content:   firstSentence,
+	}, OutputSampleRate: sampleRate})
+	tail := c.newFadeTail(sampleRate, func(audioData []byte) {
+		c.emit(Event{Type: EventAudio, SessionID: sessionID, Audio: audioData})
+	})
+	err := pipeline.play(func(audioData []byte) {
+		audioData, exhausted := budget.take(audioData)
+		if exhausted {
+			// Nothing more is spoken, so the rest need not be generated
+			pipeline.stop()
+			cancelLLM()
+		}
+		if len(audioData) == 0 {
+			return
+		}
+		if turn.AudioChunks == 0 {
+			turn.FirstAudio = time.Since(synthesizing)
+		}
+		turn.AudioChunks++
+		turn.AudioBytes += len(audioData)
+		tail.push(audioData)
+		if keepAudio {
+			audio = append(audio, audioData)
+		}
+	})
+	tail.flush(ttsCtx.Err() != nil)
+	if err != nil {
+		// Nothing more is spoken, so the rest need not be generated
+		cancelLLM()
+	}
+	if outcome == nil {
+		result := <-generated
+		outcome = &result
+	}
+	if err == nil {
+		err = outcome.err
+	}
+	turn.Generating = outcome.generating
+
+	chatResponse := outcome.message
+	// The session continues only while its last message is recent
+	if chatResponse.Timestamp.IsZero() {
+		chatResponse.Timestamp = time.Now()
+	}
+	if err != nil && ttsCtx.Err() != nil {
+		// A cancelled response says nothing more
+		return chatResponse, nil, nil
+	}
+	if budget.cut() {
+		c.windDown(ttsCtx, sessionID, budget, turn)
+		return chatResponse, nil, nil
+	}
+	if err != nil {
+		c.logger.Warn("Response pipeline failed, speaking the fallback phrase",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", sessionID),
+			zap.Error(err))
+		pipeline.stop()
+		turn.Fallback = true
+		if errors.Is(err, repositories.ErrTTSQuotaExceeded) {
+			c.speakUnavailable(ttsCtx, sessionID, turn, false)
+		} else {
+			c.speakPipelineFallback(ttsCtx, sessionID, turn)
+		}
+		audio = nil
+	}
+	return chatResponse, audio, nil
+}
+
+// speakPipelineFallback emits the audio of the pipeline fallback phrase
+func (c *Conversation) speakPipelineFallback(ctx context.Context, sessionID string, turn *turnSummary) {
+	audio, err := c.engine.cannedSpeech(ctx, c.engine.config.PipelineFallbackPhrase)
+	if err != nil {
+		c.logger.Error("Failed to synthesize the pipeline fallback phrase",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", sessionID),
+			zap.Error(err))
+		return
+	}
+	for audioData := range audio {
+		turn.AudioChunks++
+		turn.AudioBytes += len(audioData)
+		c.emit(Event{Type: EventAudio, SessionID: sessionID, Audio: audioData})
+	}
+}
@@ -0,0 +1,112 @@
+package conversation
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestSentenceSplitter(t *testing.T) {
+	var splitter sentenceSplitter
+	var sentences []string
+	for _, piece := range []string{"Hmm... ", "kucing itu ", "lucu! Beratnya 3.5 kilo", "gram. Kamu", " suka?\nAku", " suka"} {
+		sentences = append(sentences, splitter.push(piece)...)
+	}
+	want := []string{"Hmm...", "kucing itu lucu!", "Beratnya 3.5 kilogram.", "Kamu suka?"}
+	if !slices.Equal(sentences, want) {
+		t.Errorf("Expected %q, got %q", want, sentences)
+	}
+	if rest := splitter.flush(); rest != "Aku suka" {
+		t.Errorf("Expected the unfinished sentence, got %q", rest)
+	}
+}
+
+// pipelinedAudio runs a turn and returns its events up to speaking end, with
+// the audio of each as text
+func pipelinedAudio(t *testing.T, conv *Conversation, sink *recorder) ([]Event, []string) {
+	t.Helper()
+	speak(t, conv, sink)
+	events := sink.until(t, EventSpeakingEnd)
+	var audio []string
+	for _, event := range events {
+		switch event.Type {
+		case EventAudio:
+			audio = append(audio, string(event.Audio))
+		case EventError:
+			t.Fatalf("Expected the turn to succeed, got %q", event.Error)
+		}
+	}
+	return events, audio
+}
+
+func TestEngine_PipelinePlaysSentencesInOrder(t *testing.T) {
+	f := newEngineFixture(t, Config{SentencePipelining: true, PipelineConcurrency: 2}, &conversationtest.STT{Transcript: "halo"})
+	f.llm.Reply = "Satu dua. Tiga empat! Lima enam? Tujuh."
+	f.tts.EchoText = true
+	// Later sentences finish synthesizing before the earlier ones
+	f.tts.Delays = map[string]time.Duration{
+		"Satu dua.":   200 * time.Millisecond,
+		"Tiga empat!": 50 * time.Millisecond,
+		"Lima enam?":  100 * time.Millisecond,
+		"Tujuh.":      10 * time.Millisecond,
+	}
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	events, audio := pipelinedAudio(t, conv, sink)
+	want := []string{"Satu dua.", "Tiga empat!", "Lima enam?", "Tujuh."}
+	if !slices.Equal(audio, want) {
+		t.Errorf("Expected the audio in sentence order, got %q", audio)
+	}
+	if got := f.tts.MaxConcurrent(); got != 2 {
+		t.Errorf("Expected 2 concurrent syntheses, got %d", got)
+	}
+
+	for _, event := range events {
+		if event.Type == EventSpeakingStart && event.Message.Content != "Satu dua." {
+			t.Errorf("Expected speaking to start with the first sentence, got %q", event.Message.Content)
+		}
+	}
+	if end := events[len(events)-1]; end.Message == nil || end.Message.Content != f.llm.Reply {
+		t.Errorf("Expected speaking to end with the whole response, got %v", end.Message)
+	}
+}
+
+func TestEngine_PipelineFallsBackOnFailingSentence(t *testing.T) {
+	f := newEngineFixture(t, Config{SentencePipelining: true, PipelineConcurrency: 2, PipelineFallbackPhrase: "Maaf, ya."},
+		&conversationtest.STT{Transcript: "halo"})
+	f.llm.Reply = "Satu dua. Tiga empat! Lima enam? Tujuh."
+	f.tts.EchoText = true
+	f.tts.Delays = map[string]time.Duration{"Satu dua.": 50 * time.Millisecond}
+	f.tts.Failures = map[string]error{"Tiga empat!": errors.New("synthesis failed")}
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	_, audio := pipelinedAudio(t, conv, sink)
+	want := []string{"Satu dua.", "Maaf, ya."}
+	if !slices.Equal(audio, want) {
+		t.Errorf("Expected the sentences before the failure and the fallback, got %q", audio)
+	}
+
+}
+
+func TestEngine_SegmentedResponseIsNotPipelined(t *testing.T) {
+	f := newEngineFixture(t, Config{SentencePipelining: true, IntentSegmentation: true},
+		&conversationtest.STT{Transcript: "ceritakan lelucon dan berapa 2 tambah 2"})
+	f.llm.Reply = "Kenapa ayam menyeberang? Supaya sampai! Terus, 2 tambah 2 itu 4."
+	f.tts.EchoText = true
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	_, audio := pipelinedAudio(t, conv, sink)
+	if !slices.Equal(f.llm.Segmentations(), []bool{true}) {
+		t.Errorf("Expected a response segmented by intent to be asked for, got %v", f.llm.Segmentations())
+	}
+	// Both answers are spoken as one complete response
+	if !slices.Equal(audio, []string{f.llm.Reply}) {
+		t.Errorf("Expected the whole response synthesized at once, got %q", audio)
+	}
+}
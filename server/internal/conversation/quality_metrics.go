@@ -0,0 +1,186 @@
+package conversation
+
+import (
+	"encoding/json"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// unknownLabel labels the turns of devices without a model, and those of an
+// engine that cannot name its LLM model
+const unknownLabel = "unknown"
+
+// With Config.QualityMetrics, the utterances of every fleet, the devices of
+// one hardware model, are aggregated by the LLM model answering them, so
+// that operators notice audio quality degrading across the fleet: children
+// the doll cannot hear, transcriptions it is unsure of, or responses it
+// answers with a fallback.
+
+var (
+	// audioQuality holds the qualityStats of every fleet and LLM model, keyed
+	// by their labels
+	audioQuality = expvar.NewMap("conversation_audio_quality")
+	// qualityMu serializes creating the stats of a fleet and model
+	qualityMu sync.Mutex
+)
+
+// qualityTurn is the outcome of one utterance
+type qualityTurn struct {
+	// NoSpeech is set when no speech was recognized in the utterance
+	NoSpeech bool
+	// Fallback is set when the doll answered with a fallback instead of the
+	// response of the LLM
+	Fallback bool
+	// Confidence of the transcription, zero when the speech-to-text service
+	// reported none
+	Confidence float64
+	// Time from requesting speech to its first chunk, zero when there was
+	// none
+	FirstAudio time.Duration
+}
+
+// qualityStats aggregates the utterances of one fleet and LLM model
+type qualityStats struct {
+	mu              sync.Mutex
+	turns           int64
+	noSpeech        int64
+	fallbacks       int64
+	confidenceSum   float64
+	confidenceTurns int64
+	firstAudioSum   time.Duration
+	firstAudioTurns int64
+}
+
+// qualitySnapshot is how a qualityStats is exported
+type qualitySnapshot struct {
+	Fleet                  string  `json:"fleet"`
+	Model                  string  `json:"model"`
+	Turns                  int64   `json:"turns"`
+	NoSpeechTurns          int64   `json:"no_speech_turns"`
+	FallbackTurns          int64   `json:"fallback_turns"`
+	NoSpeechRate           float64 `json:"no_speech_rate"`
+	FallbackRate           float64 `json:"fallback_rate"`
+	AverageConfidence      float64 `json:"average_confidence"`
+	MeanTimeToFirstAudioMs float64 `json:"mean_time_to_first_audio_ms"`
+}
+
+// add counts turn
+func (s *qualityStats) add(turn qualityTurn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.turns++
+	if turn.NoSpeech {
+		s.noSpeech++
+	}
+	if turn.Fallback {
+		s.fallbacks++
+	}
+	if turn.Confidence > 0 {
+		s.confidenceSum += turn.Confidence
+		s.confidenceTurns++
+	}
+	if turn.FirstAudio > 0 {
+		s.firstAudioSum += turn.FirstAudio
+		s.firstAudioTurns++
+	}
+}
+
+// snapshot returns the rates and averages of the turns counted so far. The
+// rates are of all utterances, the average confidence of those transcribed
+// with one and the time to first audio of those answered with speech.
+func (s *qualityStats) snapshot() qualitySnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := qualitySnapshot{
+		Turns:         s.turns,
+		NoSpeechTurns: s.noSpeech,
+		FallbackTurns: s.fallbacks,
+	}
+	if s.turns > 0 {
+		snapshot.NoSpeechRate = float64(s.noSpeech) / float64(s.turns)
+		snapshot.FallbackRate = float64(s.fallbacks) / float64(s.turns)
+	}
+	if s.confidenceTurns > 0 {
+		snapshot.AverageConfidence = s.confidenceSum / float64(s.confidenceTurns)
+	}
+	if s.firstAudioTurns > 0 {
+		mean := s.firstAudioSum / time.Duration(s.firstAudioTurns)
+		snapshot.MeanTimeToFirstAudioMs = float64(mean) / float64(time.Millisecond)
+	}
+	return snapshot
+}
+
+// labeledQualityStats are the qualityStats of a fleet and model, which label
+// them in the export
+type labeledQualityStats struct {
+	*qualityStats
+	fleet, model string
+}
+
+// String implements expvar.Var
+func (s labeledQualityStats) String() string {
+	snapshot := s.snapshot()
+	snapshot.Fleet, snapshot.Model = s.fleet, s.model
+	encoded, _ := json.Marshal(snapshot)
+	return string(encoded)
+}
+
+// qualityKey keys the stats of fleet and model in audioQuality
+func qualityKey(fleet, model string) string {
+	return "fleet=" + fleet + ",model=" + model
+}
+
+// qualityFor returns the stats of fleet and model, created on first use
+func qualityFor(fleet, model string) *qualityStats {
+	if fleet == "" {
+		fleet = unknownLabel
+	}
+	if model == "" {
+		model = unknownLabel
+	}
+	key := qualityKey(fleet, model)
+
+	qualityMu.Lock()
+	defer qualityMu.Unlock()
+	if stats, ok := audioQuality.Get(key).(labeledQualityStats); ok {
+		return stats.qualityStats
+	}
+	stats := labeledQualityStats{qualityStats: &qualityStats{}, fleet: fleet, model: model}
+	audioQuality.Set(key, stats)
+	return stats.qualityStats
+}
+
+// recordQuality counts the outcome of an utterance in the stats of the
+// fleet of the device and the LLM model, with Config.QualityMetrics.
+// The caller must hold the mutex.
+func (c *Conversation) recordQuality(turn qualityTurn) {
+	if !c.engine.config.QualityMetrics {
+		return
+	}
+	qualityFor(c.deviceModel, c.engine.modelName()).add(turn)
+}
+
+// SetDeviceModel sets the hardware model of the device, the fleet the audio
+// quality of its utterances is aggregated in
+func (c *Conversation) SetDeviceModel(model string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.deviceModel = model
+}
+
+// transcriptionConfidence returns the confidence stream reported for its
+// transcription, zero when it reported none
+func transcriptionConfidence(stream repositories.SpeechToTextStreaming) float64 {
+	reporter, ok := stream.(repositories.TranscriptionConfidence)
+	if !ok {
+		return 0
+	}
+	confidence, ok := reporter.Confidence()
+	if !ok {
+		return 0
+	}
+	return confidence
+}
@@ -0,0 +1,98 @@
+package conversation
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestEngine_ExportsAudioQuality(t *testing.T) {
+	stt := &conversationtest.STT{Transcript: "halo", Confidence: 0.9}
+	f := newEngineFixture(t, Config{QualityMetrics: true}, stt)
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	conv.SetDeviceModel("doll-quality-test")
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+
+	// The child said nothing the doll could hear
+	stt.Transcript = ""
+	speak(t, conv, sink)
+	if event := sink.next(t, EventListeningEnd); event.Error != ErrorNoSpeech {
+		t.Fatalf("Expected no speech, got %q", event.Error)
+	}
+
+	// The chat session answers with a fallback
+	stt.Transcript, stt.Confidence = "halo lagi", 0.5
+	f.llm.FailChats(nil, true)
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	f.llm.FailChats(nil, false)
+
+	stt.Confidence = 0
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	conv.Close()
+
+	var got qualitySnapshot
+	exported := audioQuality.Get(qualityKey("doll-quality-test", unknownLabel))
+	if exported == nil {
+		t.Fatal("Expected the audio quality of the fleet to be exported")
+	}
+	if err := json.Unmarshal([]byte(exported.String()), &got); err != nil {
+		t.Fatalf("Failed to decode the exported audio quality: %v", err)
+	}
+
+	if got.Fleet != "doll-quality-test" || got.Model != unknownLabel {
+		t.Errorf("Expected the stats labeled with the fleet and model, got %q, %q", got.Fleet, got.Model)
+	}
+	if got.Turns != 4 || got.NoSpeechTurns != 1 || got.FallbackTurns != 1 {
+		t.Errorf("Expected 4 turns, 1 without speech and 1 fallback, got %+v", got)
+	}
+	if got.NoSpeechRate != 0.25 || got.FallbackRate != 0.25 {
+		t.Errorf("Expected no-speech and fallback rates of 0.25, got %v, %v", got.NoSpeechRate, got.FallbackRate)
+	}
+	// The turn transcribed without a confidence does not lower the average
+	if math.Abs(got.AverageConfidence-0.7) > 1e-9 {
+		t.Errorf("Expected an average confidence of 0.7, got %v", got.AverageConfidence)
+	}
+	if got.MeanTimeToFirstAudioMs <= 0 {
+		t.Errorf("Expected a mean time to first audio, got %v", got.MeanTimeToFirstAudioMs)
+	}
+}
+
+func TestEngine_StoresTranscriptionConfidence(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo", Confidence: 0.8})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	conv.Close()
+
+	sessions := f.sessions.Sessions()
+	if len(sessions) != 1 || len(sessions[0].Messages) != 2 {
+		t.Fatalf("Expected one stored turn, got %+v", sessions)
+	}
+	if confidence := sessions[0].Messages[0].Metadata.TranscriptionConfidence; confidence != 0.8 {
+		t.Errorf("Expected the transcription confidence stored, got %v", confidence)
+	}
+}
+
+func TestEngine_ExportsNoAudioQualityByDefault(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	conv.SetDeviceModel("doll-quality-disabled")
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	conv.Close()
+
+	if exported := audioQuality.Get(qualityKey("doll-quality-disabled", unknownLabel)); exported != nil {
+		t.Errorf("Expected no audio quality exported, got %s", exported)
+	}
+}
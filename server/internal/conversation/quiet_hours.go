@@ -0,0 +1,76 @@
+package conversation
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// duringQuietHours reports whether the device's child is inside its quiet
+// hours at t. Lookup failures never keep the doll silent.
+// The caller must hold the mutex and have resolved the child.
+func (c *Conversation) duringQuietHours(ctx context.Context, t time.Time) bool {
+	if c.childID == "" {
+		return false
+	}
+
+	quietHours, err := c.engine.quietHoursRepo.GetByChildID(ctx, c.childID)
+	if err != nil {
+		c.logger.Warn("Failed to get quiet hours",
+			zap.String("deviceID", c.deviceID),
+			zap.String("childID", c.childID),
+			zap.Error(err))
+		return false
+	}
+	if quietHours == nil {
+		return false
+	}
+
+	active, err := quietHours.Active(t)
+	if err != nil {
+		c.logger.Warn("Ignoring invalid quiet hours",
+			zap.String("childID", c.childID),
+			zap.Error(err))
+		return false
+	}
+	return active
+}
+
+// shush speaks the bedtime phrase instead of engaging the full pipeline
+func (c *Conversation) shush() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Waits for StartListening to report the rejection first
+	c.mutex.Lock()
+	ttsCtx := c.ttsContext(ctx)
+	c.mutex.Unlock()
+
+	phrase := c.engine.config.QuietHoursPhrase
+	audioDataChan, err := c.engine.cannedSpeech(ttsCtx, phrase)
+	if err != nil {
+		c.logger.Warn("Failed to synthesize quiet hours phrase",
+			zap.String("deviceID", c.deviceID),
+			zap.Error(err))
+		return
+	}
+
+	message := entities.Message{
+		Timestamp: time.Now(),
+		Role:      entities.DollRole,
+		Content:   phrase,
+	}
+	c.emit(Event{Type: EventSpeakingStart, Message: &message})
+	for audioData := range audioDataChan {
+		c.emit(Event{Type: EventAudio, Audio: audioData})
+	}
+	c.emit(Event{Type: EventSpeakingEnd})
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lastSpeakingEnd = time.Now()
+	c.lastSpokenText = phrase
+}
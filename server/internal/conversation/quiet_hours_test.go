@@ -0,0 +1,51 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestEngine_QuietHours(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo boneka"})
+	ctx := context.Background()
+
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}}
+	if err := f.children.Create(ctx, child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	if err := f.quiet.Upsert(ctx, &entities.QuietHours{ChildID: child.ID, Start: "20:00", End: "06:30", Timezone: "Asia/Jakarta"}); err != nil {
+		t.Fatalf("Failed to set quiet hours: %v", err)
+	}
+
+	clock := time.Date(2025, 3, 1, 14, 0, 0, 0, time.UTC) // 21:00 WIB
+	f.engine.now = func() time.Time { return clock }
+
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	conv.StartListening(StartOptions{})
+	if event := sink.next(t, EventListeningStart); event.Error != ErrorQuietHours {
+		t.Fatalf("Expected quiet hours error, got %q", event.Error)
+	}
+	speaking := sink.next(t, EventSpeakingStart)
+	if speaking.Message == nil || speaking.Message.Content != defaultQuietHoursPhrase {
+		t.Errorf("Expected the bedtime phrase, got %+v", speaking.Message)
+	}
+	sink.next(t, EventSpeakingEnd)
+
+	if len(f.stt.Streams()) != 0 || len(f.sessions.Sessions()) != 0 {
+		t.Errorf("Expected no transcription or session during quiet hours")
+	}
+
+	clock = time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC) // 07:00 WIB
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+
+	if texts := f.tts.Texts(); len(texts) != 2 || texts[1] != "Halo juga!" {
+		t.Errorf("Expected a regular response after quiet hours end, got %v", texts)
+	}
+}
@@ -0,0 +1,73 @@
+package conversation
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+	"github.com/satriahrh/arunika/server/internal/audit"
+)
+
+// SetRecorder tees the audio of listening sessions to recorder, for the
+// children whose parent consented to it. Every decision to record a session
+// or not is recorded in trail.
+func (e *Engine) SetRecorder(recorder repositories.AudioRecorder, trail audit.Trail) {
+	e.recorder = recorder
+	e.trail = trail
+}
+
+// decideRecording decides whether the audio of the listening session is
+// recorded, recording the decision in the audit trail the first time it is
+// made for a session or whenever it changes.
+// The caller must hold the mutex.
+func (c *Conversation) decideRecording(ctx context.Context) {
+	if c.engine.recorder == nil {
+		c.recording = false
+		return
+	}
+
+	recording := c.childID != "" && c.recordingConsent
+	if c.recordingSessionID == c.session.ID && c.recording == recording {
+		return
+	}
+	c.recording = recording
+	c.recordingSessionID = c.session.ID
+
+	reason := "consent_given"
+	switch {
+	case c.childID == "":
+		reason = "no_child"
+	case !c.recordingConsent:
+		reason = "consent_absent"
+	}
+	c.engine.trail.Record(ctx, audit.Entry{
+		Action:   "recording_decided",
+		ActorID:  c.deviceID,
+		TargetID: c.childID,
+		Details: map[string]interface{}{
+			"session_id": c.session.ID,
+			"recording":  recording,
+			"reason":     reason,
+		},
+	})
+	c.logger.Info("Decided audio recording",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", c.session.ID),
+		zap.Bool("recording", recording),
+		zap.String("reason", reason))
+}
+
+// record tees a chunk of the child's audio to the recorder when the listening
+// session is recorded.
+// The caller must hold the mutex.
+func (c *Conversation) record(audio []byte) {
+	if !c.recording {
+		return
+	}
+	if err := c.engine.recorder.RecordAudio(context.Background(), c.session.ID, audio); err != nil {
+		c.logger.Warn("Failed to record audio",
+			zap.String("sessionID", c.session.ID),
+			zap.Error(err))
+	}
+}
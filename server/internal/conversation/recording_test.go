@@ -0,0 +1,105 @@
+package conversation
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/audit"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// audioRecorder keeps the recorded audio by session
+type audioRecorder struct {
+	mu    sync.Mutex
+	audio map[string][]byte
+}
+
+func (r *audioRecorder) RecordAudio(ctx context.Context, sessionID string, audio []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.audio == nil {
+		r.audio = make(map[string][]byte)
+	}
+	r.audio[sessionID] = append(r.audio[sessionID], audio...)
+	return nil
+}
+
+// auditTrail keeps audit entries for assertions
+type auditTrail struct {
+	mu      sync.Mutex
+	entries []audit.Entry
+}
+
+func (a *auditTrail) Record(ctx context.Context, entry audit.Entry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries = append(a.entries, entry)
+}
+
+func recordingFixture(t *testing.T, consent bool) (*engineFixture, *audioRecorder, *auditTrail, *entities.Child) {
+	t.Helper()
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo boneka"})
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}, RecordingConsent: consent}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	recorder, trail := &audioRecorder{}, &auditTrail{}
+	f.engine.SetRecorder(recorder, trail)
+	return f, recorder, trail, child
+}
+
+func TestEngine_RecordsAudioWithConsent(t *testing.T) {
+	f, recorder, trail, child := recordingFixture(t, true)
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	conv.StartListening(StartOptions{PreRoll: []byte{0x00}})
+	sink.next(t, EventListeningStart)
+	conv.StreamAudio([]byte{0x01, 0x02})
+	conv.EndListening()
+	sink.until(t, EventSpeakingEnd)
+
+	if audio := recorder.audio[conv.SessionID()]; !bytes.Equal(audio, []byte{0x00, 0x01, 0x02}) {
+		t.Errorf("Expected the pre-roll and the audio recorded, got %v", audio)
+	}
+	if len(trail.entries) != 1 || trail.entries[0].TargetID != child.ID || trail.entries[0].Details["recording"] != true {
+		t.Errorf("Expected the decision to record audited, got %+v", trail.entries)
+	}
+}
+
+func TestEngine_SkipsRecordingWithoutConsent(t *testing.T) {
+	f, recorder, trail, child := recordingFixture(t, false)
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+
+	if len(recorder.audio) != 0 {
+		t.Errorf("Expected nothing recorded without consent, got %v", recorder.audio)
+	}
+	entries := trail.entries
+	if len(entries) != 1 || entries[0].TargetID != child.ID || entries[0].Details["recording"] != false ||
+		entries[0].Details["reason"] != "consent_absent" {
+		t.Fatalf("Expected a single audited decision not to record, got %+v", entries)
+	}
+
+	// Consent applies from the next listening session
+	child.RecordingConsent = true
+	if err := f.children.Update(context.Background(), child); err != nil {
+		t.Fatalf("Failed to update child: %v", err)
+	}
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	if audio := recorder.audio[conv.SessionID()]; !bytes.Equal(audio, []byte{0x01, 0x02}) {
+		t.Errorf("Expected the audio recorded once consent was given, got %v", audio)
+	}
+	if len(trail.entries) != 2 || trail.entries[1].Details["recording"] != true {
+		t.Errorf("Expected the changed decision audited, got %+v", trail.entries)
+	}
+}
@@ -0,0 +1,141 @@
+package conversation
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// piiPattern finds one kind of personal information in a transcription
+type piiPattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// piiPatterns are applied in order, so that the digits of an address are not
+// mistaken for a phone number
+var piiPatterns = []piiPattern{
+	{kind: "EMAIL", re: regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}\b`)},
+	// Indonesian street names need a house number to tell them from "jalan" (to walk)
+	{kind: "ADDRESS", re: regexp.MustCompile(`(?i)\b(?:jalan|jln|jl|gang|gg)\.?\s+(?:[\pL']+\.?\s+){1,4}(?:no|nomor|nomer)\.?\s*\d+[a-z]?\b`)},
+	{kind: "ADDRESS", re: regexp.MustCompile(`(?i)\brt\.?\s*\d{1,3}\s*/?\s*rw\.?\s*\d{1,3}\b`)},
+	{kind: "ADDRESS", re: regexp.MustCompile(`(?i)\b\d{1,5}\s+(?:[\pL']+\s+){1,3}(?:street|st|road|rd|avenue|ave|lane|ln|drive|dr)\b\.?`)},
+	// Nine to fifteen digits, optionally grouped and with a country code
+	{kind: "PHONE", re: regexp.MustCompile(`\+?\(?\d\)?(?:[\s.-]?\(?\d\)?){8,14}\b`)},
+}
+
+// piiRedactor replaces personal information in transcriptions with
+// placeholders such as [PHONE] before they are stored
+type piiRedactor struct {
+	// Keyed hash appended to placeholders, so that repeated values can be
+	// recognized without being readable; placeholders carry no hash when empty
+	hashKey []byte
+
+	// Finds names the patterns cannot, nil when name detection is disabled
+	names repositories.NameDetector
+
+	logger *zap.Logger
+}
+
+// redact returns text with every detected piece of personal information
+// replaced. Name detection failures are logged and leave names in place.
+func (r *piiRedactor) redact(ctx context.Context, text string) string {
+	text = r.redactPatterns(text)
+	if r.names == nil {
+		return text
+	}
+
+	names, err := r.names.DetectNames(ctx, text)
+	if err != nil {
+		r.logger.Warn("Failed to detect names for redaction", zap.Error(err))
+	}
+	for _, name := range names {
+		text = r.replaceName(text, name)
+	}
+	return text
+}
+
+// redactPatterns replaces the personal information matched by piiPatterns
+func (r *piiRedactor) redactPatterns(text string) string {
+	for _, pattern := range piiPatterns {
+		text = pattern.re.ReplaceAllStringFunc(text, func(match string) string {
+			return r.placeholder(pattern.kind, match)
+		})
+	}
+	return text
+}
+
+// replaceName replaces whole-word occurrences of name, ignoring case
+func (r *piiRedactor) replaceName(text, name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return text
+	}
+	re := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(name))
+
+	var redacted strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		before, _ := utf8.DecodeLastRuneInString(text[:loc[0]])
+		after, _ := utf8.DecodeRuneInString(text[loc[1]:])
+		if isWordRune(before) || isWordRune(after) {
+			continue
+		}
+		redacted.WriteString(text[last:loc[0]])
+		redacted.WriteString(r.placeholder("NAME", text[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	redacted.WriteString(text[last:])
+	return redacted.String()
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsNumber(r))
+}
+
+// placeholder returns the replacement of a detected value of the given kind
+func (r *piiRedactor) placeholder(kind, value string) string {
+	if len(r.hashKey) == 0 {
+		return "[" + kind + "]"
+	}
+	mac := hmac.New(sha256.New, r.hashKey)
+	mac.Write([]byte(normalizePII(value)))
+	return "[" + kind + ":" + hex.EncodeToString(mac.Sum(nil))[:8] + "]"
+}
+
+// normalizePII drops case, spaces and punctuation so that "0812-3456 7890"
+// and "081234567890" hash alike
+func normalizePII(value string) string {
+	return strings.Map(func(r rune) rune {
+		if !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '@' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, value)
+}
+
+// redactTurn returns the child's message as it is stored and as it is sent
+// to the LLM. Unless PIIRawToLLM allows it, the LLM sees the redacted text
+// too; otherwise the unredacted text only lives in the chat session's memory.
+func (c *Conversation) redactTurn(ctx context.Context, message entities.Message) (stored, prompt entities.Message) {
+	if c.engine.redactor == nil {
+		return message, message
+	}
+
+	stored = message
+	stored.Content = c.engine.redactor.redact(ctx, message.Content)
+	if c.engine.config.PIIRawToLLM {
+		return stored, message
+	}
+	return stored, stored
+}
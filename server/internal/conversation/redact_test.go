@@ -0,0 +1,120 @@
+package conversation
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestPIIRedactor_Redact(t *testing.T) {
+	redactor := &piiRedactor{logger: zaptest.NewLogger(t)}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"mobile number", "Nomor mama 0812 3456 7890 ya", "Nomor mama [PHONE] ya"},
+		{"international number", "call +62 812-3456-7890 now", "call [PHONE] now"},
+		{"indonesian street", "Rumahku di Jalan Melati Nomor 12 dekat sekolah", "Rumahku di [ADDRESS] dekat sekolah"},
+		{"abbreviated street", "tinggal di Jl. Kenanga Indah No. 5B", "tinggal di [ADDRESS]"},
+		{"neighbourhood", "rumahku RT 03 RW 05", "rumahku [ADDRESS]"},
+		{"english street", "I live at 221 Baker Street", "I live at [ADDRESS]"},
+		{"email", "email papa budi.santoso@example.com", "email papa [EMAIL]"},
+		{"clean sentence", "Aku suka jalan jalan ke taman, umurku 7 tahun!", "Aku suka jalan jalan ke taman, umurku 7 tahun!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactor.redact(context.Background(), tt.text); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPIIRedactor_HashesPlaceholders(t *testing.T) {
+	redactor := &piiRedactor{hashKey: []byte("secret"), logger: zaptest.NewLogger(t)}
+
+	first := redactor.redact(context.Background(), "0812 3456 7890")
+	second := redactor.redact(context.Background(), "0812-3456-7890")
+	other := redactor.redact(context.Background(), "0813 3456 7890")
+
+	if !strings.HasPrefix(first, "[PHONE:") || strings.Contains(first, "3456") {
+		t.Fatalf("Expected a hashed phone placeholder, got %q", first)
+	}
+	if first != second {
+		t.Errorf("Expected the same number to hash alike, got %q and %q", first, second)
+	}
+	if first == other {
+		t.Errorf("Expected different numbers to hash differently, got %q", other)
+	}
+}
+
+func TestPIIRedactor_RedactsDetectedNames(t *testing.T) {
+	redactor := &piiRedactor{
+		names:  &conversationtest.LLM{Names: []string{"Budi"}},
+		logger: zaptest.NewLogger(t),
+	}
+
+	got := redactor.redact(context.Background(), "Budi dan budi teman Budiman")
+	if want := "[NAME] dan [NAME] teman Budiman"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+// storedMessages waits for the turn to be saved and returns the session messages
+func storedMessages(t *testing.T, conv *Conversation) []entities.Message {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		conv.mutex.Lock()
+		messages := append([]entities.Message(nil), conv.session.Messages...)
+		conv.mutex.Unlock()
+		if len(messages) > 0 {
+			return messages
+		}
+		select {
+		case <-timeout:
+			t.Fatal("Timed out waiting for the turn to be stored")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestEngine_StoresRedactedTranscription(t *testing.T) {
+	transcript := "Rumahku di Jalan Melati Nomor 12, telepon 0812 3456 7890"
+	redacted := "Rumahku di [ADDRESS], telepon [PHONE]"
+
+	tests := []struct {
+		name       string
+		rawToLLM   bool
+		wantPrompt string
+	}{
+		{"llm sees redacted text", false, redacted},
+		{"llm sees raw text", true, transcript},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newEngineFixture(t, Config{PIIRedaction: true, PIIRawToLLM: tt.rawToLLM}, &conversationtest.STT{Transcript: transcript})
+			sink := newRecorder()
+			conv := f.engine.NewConversation("device-1", sink)
+
+			speak(t, conv, sink)
+			sink.until(t, EventSpeakingEnd)
+
+			messages := storedMessages(t, conv)
+			if messages[0].Content != redacted {
+				t.Errorf("Expected the stored transcription %q, got %q", redacted, messages[0].Content)
+			}
+			if sent := f.llm.Messages(); len(sent) != 1 || sent[0] != tt.wantPrompt {
+				t.Errorf("Expected the LLM to receive %q, got %v", tt.wantPrompt, sent)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package conversation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxCachedUtterances bounds the distinct utterances cached per session
+const maxCachedUtterances = 32
+
+// timeSensitiveWords mark utterances whose answer may change between repeats,
+// such as asking for the time, so they are always answered afresh
+var timeSensitiveWords = map[string]bool{
+	"jam": true, "pukul": true, "sekarang": true, "hari": true, "tanggal": true,
+	"besok": true, "kemarin": true, "cuaca": true,
+	"time": true, "clock": true, "now": true, "today": true, "date": true,
+	"tomorrow": true, "yesterday": true, "weather": true,
+}
+
+// cachedResponse is a response spoken earlier in the session
+type cachedResponse struct {
+	text  string
+	audio [][]byte
+}
+
+// replay returns the cached audio as a closed TTS stream
+func (r cachedResponse) replay() <-chan []byte {
+	audio := make(chan []byte, len(r.audio))
+	for _, chunk := range r.audio {
+		audio <- chunk
+	}
+	close(audio)
+	return audio
+}
+
+// cachedUtterance holds the responses generated for one utterance
+type cachedUtterance struct {
+	variants []cachedResponse
+	next     int
+}
+
+// responseCache remembers the responses of a single session
+type responseCache struct {
+	sessionID  string
+	utterances map[string]*cachedUtterance
+}
+
+// responseCacheKey returns the cache key of a transcription, or "" when its
+// response must not be cached. The key includes the voice and sample rate so
+// that replayed audio always matches what would be synthesized.
+// The caller must hold the mutex.
+func (c *Conversation) responseCacheKey(transcription string) string {
+	if !c.engine.config.ResponseCache {
+		return ""
+	}
+	words := normalizeWords(transcription)
+	if len(words) == 0 {
+		return ""
+	}
+	for _, word := range words {
+		if timeSensitiveWords[word] {
+			return ""
+		}
+	}
+	return fmt.Sprintf("%s|%d|%s", c.voiceID, c.outputSampleRate(), strings.Join(words, " "))
+}
+
+// lookupResponse returns the response to replay for key. Until the utterance
+// has ResponseCacheVariants responses a miss is reported so that a new one is
+// generated; after that the cached responses are replayed in turn.
+// The caller must hold the mutex.
+func (c *Conversation) lookupResponse(sessionID, key string) (cachedResponse, bool) {
+	if key == "" || c.responses.sessionID != sessionID {
+		return cachedResponse{}, false
+	}
+	utterance := c.responses.utterances[key]
+	if utterance == nil || len(utterance.variants) < c.engine.config.ResponseCacheVariants {
+		return cachedResponse{}, false
+	}
+	response := utterance.variants[utterance.next%len(utterance.variants)]
+	utterance.next++
+	return response, true
+}
+
+// storeResponse caches a response generated for key. Responses of an earlier
+// session are forgotten.
+// The caller must hold the mutex.
+func (c *Conversation) storeResponse(sessionID, key string, response cachedResponse) {
+	if len(response.audio) == 0 {
+		return
+	}
+	if c.responses.sessionID != sessionID {
+		c.responses = responseCache{sessionID: sessionID, utterances: make(map[string]*cachedUtterance)}
+	}
+	utterance := c.responses.utterances[key]
+	if utterance == nil {
+		if len(c.responses.utterances) >= maxCachedUtterances {
+			return
+		}
+		utterance = &cachedUtterance{}
+		c.responses.utterances[key] = utterance
+	}
+	if len(utterance.variants) < c.engine.config.ResponseCacheVariants {
+		utterance.variants = append(utterance.variants, response)
+	}
+}
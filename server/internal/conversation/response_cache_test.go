@@ -0,0 +1,129 @@
+package conversation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// audioOf returns the audio chunks of the events
+func audioOf(turn []Event) [][]byte {
+	var audio [][]byte
+	for _, event := range turn {
+		if event.Type == EventAudio {
+			audio = append(audio, event.Audio)
+		}
+	}
+	return audio
+}
+
+// storedTurn runs a turn and waits until it is saved, so that the next turn
+// continues the same session
+func storedTurn(t *testing.T, conv *Conversation, sink *recorder) []Event {
+	t.Helper()
+	conv.mutex.Lock()
+	before := 0
+	if conv.session != nil {
+		before = len(conv.session.Messages)
+	}
+	conv.mutex.Unlock()
+
+	speak(t, conv, sink)
+	turn := sink.until(t, EventSpeakingEnd)
+
+	timeout := time.After(5 * time.Second)
+	for {
+		conv.mutex.Lock()
+		stored := len(conv.session.Messages)
+		conv.mutex.Unlock()
+		if stored >= before+2 {
+			return turn
+		}
+		select {
+		case <-timeout:
+			t.Fatal("Timed out waiting for the turn to be stored")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestEngine_ReplaysCachedResponseToRepeatedUtterance(t *testing.T) {
+	stt := &conversationtest.STT{Transcript: "Lagi! Lagi!"}
+	f := newEngineFixture(t, Config{ResponseCache: true}, stt)
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	first := storedTurn(t, conv, sink)
+
+	// The same words with different punctuation and case
+	stt.Transcript = "lagi, lagi"
+	second := storedTurn(t, conv, sink)
+
+	if messages := f.llm.Messages(); len(messages) != 1 {
+		t.Errorf("Expected the LLM to answer only the first utterance, got %v", messages)
+	}
+	if texts := f.tts.Texts(); len(texts) != 1 {
+		t.Errorf("Expected only the first response to be synthesized, got %v", texts)
+	}
+	if got, want := len(audioOf(second)), len(audioOf(first)); got != want || want == 0 {
+		t.Errorf("Expected the cached %d audio chunks to be replayed, got %d", want, got)
+	}
+	for _, event := range second {
+		if event.Type == EventSpeakingStart && (event.Message == nil || event.Message.Content != "Halo juga!") {
+			t.Errorf("Expected the cached response text, got %v", event.Message)
+		}
+	}
+
+	// A new utterance is answered afresh
+	stt.Transcript = "aku suka kucing"
+	storedTurn(t, conv, sink)
+	if messages := f.llm.Messages(); len(messages) != 2 || messages[1] != "aku suka kucing" {
+		t.Errorf("Expected the new utterance to reach the LLM, got %v", messages)
+	}
+	if texts := f.tts.Texts(); len(texts) != 2 {
+		t.Errorf("Expected the new response to be synthesized, got %v", texts)
+	}
+
+	// Every turn is still recorded in the session
+	sessions := f.sessions.Sessions()
+	if n := len(sessions[len(sessions)-1].Messages); n != 6 {
+		t.Errorf("Expected 6 messages recorded, got %d", n)
+	}
+}
+
+func TestEngine_ResponseCacheVariantsAndBypass(t *testing.T) {
+	stt := &conversationtest.STT{Transcript: "lagi"}
+	f := newEngineFixture(t, Config{ResponseCache: true, ResponseCacheVariants: 2}, stt)
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	for i := 0; i < 4; i++ {
+		storedTurn(t, conv, sink)
+	}
+	if messages := f.llm.Messages(); len(messages) != 2 {
+		t.Errorf("Expected two responses generated before replaying, got %v", messages)
+	}
+
+	// Questions with time-sensitive answers are never cached
+	stt.Transcript = "jam berapa sekarang?"
+	for i := 0; i < 3; i++ {
+		storedTurn(t, conv, sink)
+	}
+	if messages := f.llm.Messages(); len(messages) != 5 {
+		t.Errorf("Expected every time-sensitive question to reach the LLM, got %v", messages)
+	}
+}
+
+func TestEngine_ResponseCacheDisabledByDefault(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "lagi"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	for i := 0; i < 2; i++ {
+		storedTurn(t, conv, sink)
+	}
+	if messages := f.llm.Messages(); len(messages) != 2 {
+		t.Errorf("Expected every utterance to reach the LLM, got %v", messages)
+	}
+}
@@ -0,0 +1,126 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ResponseAudioCap limits the synthesized audio of every response to
+// children within an age range (inclusive)
+type ResponseAudioCap struct {
+	MinAge     int     `json:"min_age"`
+	MaxAge     int     `json:"max_age"`
+	MaxSeconds float64 `json:"max_seconds"`
+}
+
+// ParseResponseAudioCaps parses response audio caps encoded as a JSON array,
+// e.g. [{"min_age": 2, "max_age": 5, "max_seconds": 20}]
+func ParseResponseAudioCaps(data string) ([]ResponseAudioCap, error) {
+	var caps []ResponseAudioCap
+	if err := json.Unmarshal([]byte(data), &caps); err != nil {
+		return nil, fmt.Errorf("failed to parse response audio caps: %w", err)
+	}
+
+	sorted := append([]ResponseAudioCap(nil), caps...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MinAge < sorted[j].MinAge })
+	for i, band := range sorted {
+		if band.MinAge < 0 || band.MaxAge < band.MinAge {
+			return nil, fmt.Errorf("response audio cap %d-%d has an invalid range", band.MinAge, band.MaxAge)
+		}
+		if band.MaxSeconds <= 0 {
+			return nil, fmt.Errorf("response audio cap %d-%d must allow some audio", band.MinAge, band.MaxAge)
+		}
+		if i > 0 && band.MinAge <= sorted[i-1].MaxAge {
+			return nil, fmt.Errorf("response audio cap %d-%d overlaps %d-%d", band.MinAge, band.MaxAge, sorted[i-1].MinAge, sorted[i-1].MaxAge)
+		}
+	}
+	return caps, nil
+}
+
+// maxResponseAudio returns how much audio a response may have for the
+// child, 0 when it is not limited. The cap of the child's age band applies,
+// and MaxResponseAudio for ages no band covers.
+// The caller must hold the mutex.
+func (c *Conversation) maxResponseAudio() time.Duration {
+	if c.childAgeKnown {
+		for _, band := range c.engine.config.ResponseAudioCaps {
+			if c.childAge >= band.MinAge && c.childAge <= band.MaxAge {
+				return time.Duration(band.MaxSeconds * float64(time.Second))
+			}
+		}
+	}
+	return c.engine.config.MaxResponseAudio
+}
+
+// audioBudget counts down the bytes of audio a response may still have.
+// Synthesized audio is 16-bit mono PCM, so the bytes follow from the
+// duration and the output sample rate.
+type audioBudget struct {
+	limit     time.Duration
+	remaining int
+	exceeded  bool
+}
+
+// responseAudioBudget returns the audio budget of the next response, nil
+// when responses are not limited.
+// The caller must hold the mutex.
+func (c *Conversation) responseAudioBudget() *audioBudget {
+	limit := c.maxResponseAudio()
+	if limit <= 0 {
+		return nil
+	}
+	samples := int(limit.Seconds() * float64(c.outputSampleRate()))
+	return &audioBudget{limit: limit, remaining: 2 * samples}
+}
+
+// take returns the part of audio within the budget, and reports whether the
+// budget is exhausted and synthesis should stop. A nil budget takes all.
+func (b *audioBudget) take(audio []byte) ([]byte, bool) {
+	if b == nil {
+		return audio, false
+	}
+	if b.exceeded {
+		return nil, true
+	}
+	if len(audio) < b.remaining {
+		b.remaining -= len(audio)
+		return audio, false
+	}
+	audio = audio[:b.remaining]
+	b.remaining = 0
+	b.exceeded = true
+	return audio, true
+}
+
+// cut reports whether the response was cut at the cap. A nil budget never
+// cuts.
+func (b *audioBudget) cut() bool {
+	return b != nil && b.exceeded
+}
+
+// windDown closes a response cut at its audio cap with the wind-down
+// phrase, so that it ends naturally instead of mid-sentence
+func (c *Conversation) windDown(ctx context.Context, sessionID string, budget *audioBudget, turn *turnSummary) {
+	c.logger.Warn("Response reached its audio cap, winding down",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", sessionID),
+		zap.Duration("maxResponseAudio", budget.limit))
+	audio, err := c.engine.cannedSpeech(ctx, c.engine.config.WindDownPhrase)
+	if err != nil {
+		c.logger.Error("Failed to synthesize the wind-down phrase",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", sessionID),
+			zap.Error(err))
+		return
+	}
+	for audioData := range audio {
+		turn.AudioChunks++
+		turn.AudioBytes += len(audioData)
+		c.emit(Event{Type: EventAudio, SessionID: sessionID, Audio: audioData})
+	}
+}
@@ -0,0 +1,100 @@
+package conversation
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// cappedTurn runs a turn whose response is synthesized endlessly and returns
+// the bytes of response audio heard, and the text of the audio that followed
+func cappedTurn(t *testing.T, f *engineFixture, deviceID string) (int, string) {
+	t.Helper()
+	f.llm.Reply = "Dahulu kala ada seekor kancil yang tidak berhenti bercerita."
+	// Every synthesis is a chunk holding its text, so the wind-down phrase
+	// is told apart from the response
+	f.tts.EchoText = true
+	f.tts.Endless = map[string]bool{f.llm.Reply: true}
+	sink := newRecorder()
+	conv := f.engine.NewConversation(deviceID, sink)
+
+	speak(t, conv, sink)
+	var response int
+	var after string
+	for _, event := range sink.until(t, EventSpeakingEnd) {
+		switch {
+		case event.Type == EventError:
+			t.Fatalf("Expected the turn to succeed, got %q", event.Error)
+		case event.Type != EventAudio:
+		case strings.HasPrefix(f.llm.Reply, string(event.Audio)):
+			response += len(event.Audio)
+		default:
+			after += string(event.Audio)
+		}
+	}
+	return response, after
+}
+
+func TestEngine_StopsSynthesisAtTheAudioCap(t *testing.T) {
+	for name, pipelining := range map[string]bool{"whole response": false, "pipelined": true} {
+		t.Run(name, func(t *testing.T) {
+			f := newEngineFixture(t, Config{
+				MaxResponseAudio:   time.Second,
+				OutputSampleRate:   8000,
+				WindDownPhrase:     "Segitu dulu, ya.",
+				SentencePipelining: pipelining,
+			}, &conversationtest.STT{Transcript: "ceritakan dongeng"})
+
+			// The endless synthesis only ends once it is stopped
+			response, after := cappedTurn(t, f, "device-1")
+			// A second of 16-bit audio at 8kHz
+			if response != 16000 {
+				t.Errorf("Expected 16000 bytes of response audio, got %d", response)
+			}
+			if after != "Segitu dulu, ya." {
+				t.Errorf("Expected the wind-down phrase after the cut, got %q", after)
+			}
+		})
+	}
+}
+
+func TestEngine_AudioCapFollowsTheAgeBand(t *testing.T) {
+	f := newEngineFixture(t, Config{
+		MaxResponseAudio:  time.Second,
+		ResponseAudioCaps: []ResponseAudioCap{{MinAge: 2, MaxAge: 5, MaxSeconds: 0.5}},
+		OutputSampleRate:  8000,
+	}, &conversationtest.STT{Transcript: "ceritakan dongeng"})
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}, BirthDate: time.Now().AddDate(-4, 0, -1)}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+
+	if response, _ := cappedTurn(t, f, "device-1"); response != 8000 {
+		t.Errorf("Expected the band of a 4-year-old to cap the response at 8000 bytes, got %d", response)
+	}
+	if response, _ := cappedTurn(t, f, "device-2"); response != 16000 {
+		t.Errorf("Expected a device without a child to be capped at 16000 bytes, got %d", response)
+	}
+}
+
+func TestParseResponseAudioCaps(t *testing.T) {
+	caps, err := ParseResponseAudioCaps(`[{"min_age": 2, "max_age": 5, "max_seconds": 20}, {"min_age": 6, "max_age": 12, "max_seconds": 45}]`)
+	if err != nil || len(caps) != 2 || caps[1].MaxSeconds != 45 {
+		t.Fatalf("Expected two caps, got %+v, %v", caps, err)
+	}
+
+	for name, data := range map[string]string{
+		"overlapping": `[{"min_age": 2, "max_age": 6, "max_seconds": 20}, {"min_age": 6, "max_age": 12, "max_seconds": 45}]`,
+		"no audio":    `[{"min_age": 2, "max_age": 5, "max_seconds": 0}]`,
+		"inverted":    `[{"min_age": 5, "max_age": 2, "max_seconds": 20}]`,
+		"malformed":   `{"min_age": 2}`,
+	} {
+		if _, err := ParseResponseAudioCaps(data); err == nil {
+			t.Errorf("Expected %s caps to be rejected", name)
+		}
+	}
+}
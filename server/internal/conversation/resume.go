@@ -0,0 +1,155 @@
+package conversation
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// A listening session interrupted by a network blip is held by the engine for
+// Config.ResumeGrace. When the device reconnects within the window and starts
+// listening with StartOptions.ResumeSessionID, the utterance continues on the
+// same speech-to-text stream instead of starting over.
+
+// heldListening is a listening session waiting for its device to reconnect
+type heldListening struct {
+	session        *entities.Session
+	chatSession    repositories.ChatSession
+	stream         repositories.SpeechToTextStreaming
+	chunkCount     int
+	listeningStart time.Time
+	timer          *time.Timer
+}
+
+// Suspend holds the listening session of a connection that was lost
+// unexpectedly, reporting whether there was one to hold. It is ended when the
+// device does not resume it within the resume grace window.
+func (c *Conversation) Suspend() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	grace := c.engine.config.ResumeGrace
+	if grace <= 0 || c.session == nil || c.sttStreaming == nil {
+		return false
+	}
+
+	held := &heldListening{
+		session:        c.session,
+		chatSession:    c.chatSession,
+		stream:         c.sttStreaming,
+		chunkCount:     c.chunkCount,
+		listeningStart: c.listeningStart,
+	}
+	c.sttStreaming = nil
+	c.engine.hold(c.deviceID, held)
+
+	c.logger.Info("Holding listening session for resume",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", c.session.ID),
+		zap.Duration("resumeGrace", grace))
+	return true
+}
+
+// resumeListening continues the listening session held for the device when
+// it belongs to the session to resume, reporting whether it did.
+// The caller must hold the mutex.
+func (c *Conversation) resumeListening(ctx context.Context, opts StartOptions, event *Event) bool {
+	held := c.engine.takeHeld(c.deviceID, opts.ResumeSessionID)
+	if held == nil {
+		c.logger.Info("No held listening session to resume, starting over",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", opts.ResumeSessionID))
+		return false
+	}
+
+	c.resolveChild(ctx)
+	c.closeListening()
+	c.session = held.session
+	c.chatSession = held.chatSession
+	c.sttStreaming = held.stream
+	c.chunkCount = held.chunkCount
+	c.listeningStart = held.listeningStart
+
+	event.SessionID = c.session.ID
+	event.Resumed = true
+	c.decideRecording(ctx)
+	c.streamPreRoll(opts.PreRoll)
+
+	c.logger.Info("Resumed held listening session",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", c.session.ID),
+		zap.Int("chunkCount", c.chunkCount))
+	return true
+}
+
+// hold keeps held for the device until it is taken or the resume grace
+// window expires, ending the session it replaces
+func (e *Engine) hold(deviceID string, held *heldListening) {
+	e.heldMu.Lock()
+	previous := e.held[deviceID]
+	e.held[deviceID] = held
+	held.timer = time.AfterFunc(e.config.ResumeGrace, func() {
+		e.expireHeld(deviceID, held)
+	})
+	e.heldMu.Unlock()
+
+	if previous != nil {
+		previous.timer.Stop()
+		go previous.stream.Close()
+	}
+}
+
+// takeHeld removes and returns the listening session held for the device,
+// nil unless it belongs to sessionID
+func (e *Engine) takeHeld(deviceID, sessionID string) *heldListening {
+	e.heldMu.Lock()
+	defer e.heldMu.Unlock()
+	held, ok := e.held[deviceID]
+	if !ok || held.session.ID != sessionID {
+		return nil
+	}
+	delete(e.held, deviceID)
+	held.timer.Stop()
+	return held
+}
+
+// ForgetChild ends the listening sessions of a child held for their devices
+// to reconnect, so that erased conversations cannot be resumed
+func (e *Engine) ForgetChild(childID string) {
+	var forgotten []*heldListening
+	e.heldMu.Lock()
+	for deviceID, held := range e.held {
+		if held.session.ChildID == childID {
+			delete(e.held, deviceID)
+			held.timer.Stop()
+			forgotten = append(forgotten, held)
+		}
+	}
+	e.heldMu.Unlock()
+
+	for _, held := range forgotten {
+		// The transcription is not used
+		go held.stream.Close()
+	}
+}
+
+// expireHeld ends a held listening session the device did not resume
+func (e *Engine) expireHeld(deviceID string, held *heldListening) {
+	e.heldMu.Lock()
+	if e.held[deviceID] != held {
+		e.heldMu.Unlock()
+		return
+	}
+	delete(e.held, deviceID)
+	e.heldMu.Unlock()
+
+	e.logger.Info("Resume grace window expired, ending held listening session",
+		zap.String("deviceID", deviceID),
+		zap.String("sessionID", held.session.ID))
+	// The transcription is not used
+	held.stream.Close()
+}
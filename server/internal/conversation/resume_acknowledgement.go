@@ -0,0 +1,52 @@
+package conversation
+
+import (
+	"strings"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// resumeTopicMaxLength bounds the recent topic the LLM welcomes the child
+// back to, in bytes
+const resumeTopicMaxLength = 200
+
+// armResumeAcknowledgement remembers what session was last about when the
+// child returns to it after a break of Config.ResumeAcknowledgementAfter,
+// so that the next response welcomes the child back. A new session has
+// nothing to return to.
+// The caller must hold the mutex.
+func (c *Conversation) armResumeAcknowledgement(session *entities.Session, now time.Time) {
+	c.resumeTopic = ""
+	if !c.engine.config.ResumeAcknowledgement || session.LastMessageAt.IsZero() ||
+		now.Sub(session.LastMessageAt) < c.engine.config.ResumeAcknowledgementAfter {
+		return
+	}
+	c.resumeTopic = recentTopic(session)
+}
+
+// takeResumeTopic returns the topic the next response welcomes the child
+// back to, once.
+// The caller must hold the mutex.
+func (c *Conversation) takeResumeTopic() string {
+	topic := c.resumeTopic
+	c.resumeTopic = ""
+	return topic
+}
+
+// recentTopic returns the last message of the child in the chat context of
+// session, empty when there is none
+func recentTopic(session *entities.Session) string {
+	messages := session.ContextMessages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		content := strings.TrimSpace(messages[i].Content)
+		if messages[i].Role != entities.UserRole || content == "" {
+			continue
+		}
+		if len(content) > resumeTopicMaxLength {
+			content = strings.ToValidUTF8(content[:resumeTopicMaxLength], "")
+		}
+		return content
+	}
+	return ""
+}
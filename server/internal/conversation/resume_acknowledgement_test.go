@@ -0,0 +1,80 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// createBrokenOffSession stores a session of device-1 the child left before
+// the break
+func createBrokenOffSession(t *testing.T, f *engineFixture, lastMessage time.Time) {
+	t.Helper()
+	session := &entities.Session{
+		DeviceID:      "device-1",
+		LastMessageAt: lastMessage,
+		Messages: []entities.Message{
+			{Timestamp: lastMessage, Role: entities.UserRole, Content: "dinosaurus apa yang paling besar?"},
+			{Timestamp: lastMessage, Role: entities.DollRole, Content: "Argentinosaurus!"},
+		},
+	}
+	if err := f.sessions.Create(context.Background(), session); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+}
+
+func TestEngine_WelcomesChildBackToContinuedSession(t *testing.T) {
+	f := newEngineFixture(t, Config{ResumeAcknowledgement: true}, &conversationtest.STT{Transcript: "halo"})
+	createBrokenOffSession(t, f, time.Now().Add(-5*time.Minute))
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	// The conversation goes on without a break
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	conv.Close()
+
+	topics := f.llm.ResumeTopics()
+	if len(topics) != 2 || topics[0] != "dinosaurus apa yang paling besar?" || topics[1] != "" {
+		t.Errorf("Expected only the first response to welcome the child back, got %q", topics)
+	}
+}
+
+func TestEngine_SkipsWelcomeBack(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      Config
+		lastMessage time.Duration
+		stored      bool
+	}{
+		{"new session", Config{ResumeAcknowledgement: true}, 0, false},
+		// The session expired, and the child starts a new one
+		{"expired session", Config{ResumeAcknowledgement: true}, time.Hour, true},
+		{"short pause", Config{ResumeAcknowledgement: true}, time.Minute, true},
+		{"disabled", Config{}, 5 * time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newEngineFixture(t, tt.config, &conversationtest.STT{Transcript: "halo"})
+			if tt.stored {
+				createBrokenOffSession(t, f, time.Now().Add(-tt.lastMessage))
+			}
+			sink := newRecorder()
+			conv := f.engine.NewConversation("device-1", sink)
+
+			speak(t, conv, sink)
+			sink.until(t, EventSpeakingEnd)
+			conv.Close()
+
+			if topics := f.llm.ResumeTopics(); len(topics) != 1 || topics[0] != "" {
+				t.Errorf("Expected no welcome back, got %q", topics)
+			}
+		})
+	}
+}
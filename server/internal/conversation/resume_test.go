@@ -0,0 +1,125 @@
+package conversation
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestEngine_ResumesHeldListeningSession(t *testing.T) {
+	f := newEngineFixture(t, Config{ResumeGrace: time.Minute}, &conversationtest.STT{Transcript: "halo boneka"})
+	lost := newRecorder()
+	conv := f.engine.NewConversation("device-1", lost)
+
+	conv.StartListening(StartOptions{})
+	sessionID := lost.next(t, EventListeningStart).SessionID
+	conv.StreamAudio([]byte{0x01})
+	if !conv.Suspend() {
+		t.Fatal("Expected the listening session to be held")
+	}
+	if conv.Listening() {
+		t.Error("Expected the lost connection to stop listening")
+	}
+
+	// The device reconnects and continues the utterance
+	sink := newRecorder()
+	resumed := f.engine.NewConversation("device-1", sink)
+	resumed.StartListening(StartOptions{ResumeSessionID: sessionID, PreRoll: []byte{0x02}})
+	if event := sink.next(t, EventListeningStart); !event.Resumed || event.SessionID != sessionID || event.Error != "" {
+		t.Fatalf("Expected session %q to be resumed, got %+v", sessionID, event)
+	}
+	resumed.StreamAudio([]byte{0x03})
+	resumed.EndListening()
+
+	if event := sink.next(t, EventListeningEnd); event.Message == nil || event.Message.Content != "halo boneka" {
+		t.Fatalf("Expected the transcription of the resumed utterance, got %+v", event)
+	}
+	streams := f.stt.Streams()
+	if len(streams) != 1 {
+		t.Fatalf("Expected a single STT stream, got %d", len(streams))
+	}
+	if got := bytes.Join(streams[0].Chunks(), nil); !bytes.Equal(got, []byte{0x01, 0x02, 0x03}) {
+		t.Errorf("Expected the audio of both connections on one stream, got %x", got)
+	}
+}
+
+func TestEngine_EndsHeldListeningSessionAfterGrace(t *testing.T) {
+	f := newEngineFixture(t, Config{ResumeGrace: 20 * time.Millisecond}, &conversationtest.STT{Transcript: "halo"})
+	conv := f.engine.NewConversation("device-1", newRecorder())
+
+	conv.StartListening(StartOptions{})
+	conv.StreamAudio([]byte{0x01})
+	sessionID := conv.SessionID()
+	conv.Suspend()
+
+	held := f.stt.Streams()[0]
+	deadline := time.Now().Add(5 * time.Second)
+	for !held.Released() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the held stream to be closed after the grace window")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sink := newRecorder()
+	late := f.engine.NewConversation("device-1", sink)
+	late.StartListening(StartOptions{ResumeSessionID: sessionID})
+	if event := sink.next(t, EventListeningStart); event.Resumed || event.Error != "" {
+		t.Errorf("Expected a fresh listening session after the grace window, got %+v", event)
+	}
+	if streams := f.stt.Streams(); len(streams) != 2 {
+		t.Errorf("Expected a new STT stream, got %d streams", len(streams))
+	}
+}
+
+func TestEngine_ForgetChildEndsHeldListeningSession(t *testing.T) {
+	f := newEngineFixture(t, Config{ResumeGrace: time.Minute}, &conversationtest.STT{Transcript: "halo"})
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	conv := f.engine.NewConversation("device-1", newRecorder())
+
+	conv.StartListening(StartOptions{})
+	conv.StreamAudio([]byte{0x01})
+	sessionID := conv.SessionID()
+	conv.Suspend()
+
+	f.engine.ForgetChild("child-other")
+	f.engine.ForgetChild(child.ID)
+
+	held := f.stt.Streams()[0]
+	deadline := time.Now().Add(5 * time.Second)
+	for !held.Released() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the held stream of the erased child to be closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sink := newRecorder()
+	resumed := f.engine.NewConversation("device-1", sink)
+	resumed.StartListening(StartOptions{ResumeSessionID: sessionID})
+	if event := sink.next(t, EventListeningStart); event.Resumed || event.Error != "" {
+		t.Errorf("Expected a fresh listening session once the child was forgotten, got %+v", event)
+	}
+}
+
+func TestConversation_SuspendWithoutGraceHoldsNothing(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	conv.StartListening(StartOptions{})
+	sink.next(t, EventListeningStart)
+	if conv.Suspend() {
+		t.Error("Expected nothing to be held without a resume grace window")
+	}
+	if !conv.Listening() {
+		t.Error("Expected the listening session to be kept")
+	}
+}
@@ -0,0 +1,180 @@
+package conversation
+
+import (
+	"errors"
+	"expvar"
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Sample rate of the audio sent by devices that do not declare one
+	defaultInputSampleRate = 48000
+	// Sample rates speech-to-text accepts for LINEAR16 audio
+	minInputSampleRate = 8000
+	maxInputSampleRate = 48000
+
+	defaultOutputSampleRate = 24000
+)
+
+// outputSampleRates are the PCM sample rates text-to-speech can produce
+var outputSampleRates = []int{8000, 16000, 22050, 24000, 44100, 48000}
+
+// ErrorUnsupportedAudio rejects a listening start whose audio the pipeline
+// cannot handle, such as after a failed hello negotiation
+const ErrorUnsupportedAudio = "unsupported_audio"
+
+var (
+	// ErrUnsupportedInputSampleRate is returned for device audio speech-to-text cannot transcribe
+	ErrUnsupportedInputSampleRate = errors.New("unsupported input sample rate")
+	// ErrUnsupportedOutputSampleRate is returned when the device plays none of the rates text-to-speech produces
+	ErrUnsupportedOutputSampleRate = errors.New("unsupported output sample rate")
+)
+
+// sampleRateMismatches counts listening starts whose sample rate differs from
+// the one the device declared in its hello
+var sampleRateMismatches = expvar.NewInt("conversation_sample_rate_mismatches")
+
+// SupportedOutputSampleRates returns the PCM sample rates text-to-speech can produce
+func SupportedOutputSampleRates() []int {
+	return append([]int(nil), outputSampleRates...)
+}
+
+// AudioCapabilities are the audio formats a device declares in its handshake.
+// Zero values mean the device did not declare them.
+type AudioCapabilities struct {
+	// InputSampleRate is the rate of the microphone audio the device sends
+	InputSampleRate int
+	// OutputSampleRates are the rates the device can play
+	OutputSampleRates []int
+}
+
+// AudioSettings are the sample rates agreed with a device
+type AudioSettings struct {
+	InputSampleRate  int
+	OutputSampleRate int
+}
+
+// NegotiateAudio picks the sample rates used with a device. The preferred
+// output rate is kept when the device can play it, otherwise the highest
+// rate both sides support is chosen.
+func NegotiateAudio(caps AudioCapabilities, preferredOutput int) (AudioSettings, error) {
+	settings := AudioSettings{
+		InputSampleRate:  defaultInputSampleRate,
+		OutputSampleRate: preferredOutput,
+	}
+
+	if caps.InputSampleRate != 0 {
+		if caps.InputSampleRate < minInputSampleRate || caps.InputSampleRate > maxInputSampleRate {
+			return AudioSettings{}, ErrUnsupportedInputSampleRate
+		}
+		settings.InputSampleRate = caps.InputSampleRate
+	}
+
+	if len(caps.OutputSampleRates) == 0 {
+		return settings, nil
+	}
+	playable := make(map[int]bool, len(caps.OutputSampleRates))
+	for _, rate := range caps.OutputSampleRates {
+		playable[rate] = true
+	}
+	if playable[preferredOutput] {
+		return settings, nil
+	}
+	common := make([]int, 0, len(outputSampleRates))
+	for _, rate := range outputSampleRates {
+		if playable[rate] {
+			common = append(common, rate)
+		}
+	}
+	if len(common) == 0 {
+		return AudioSettings{}, ErrUnsupportedOutputSampleRate
+	}
+	sort.Ints(common)
+	settings.OutputSampleRate = common[len(common)-1]
+	return settings, nil
+}
+
+// SetAudioCapabilities negotiates the sample rates of this conversation from
+// the device's declaration. On failure the conversation refuses to listen
+// until a later declaration succeeds.
+func (c *Conversation) SetAudioCapabilities(caps AudioCapabilities) (AudioSettings, error) {
+	settings, err := NegotiateAudio(caps, c.engine.config.OutputSampleRate)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err != nil {
+		c.logger.Warn("Device audio capabilities are not supported",
+			zap.String("deviceID", c.deviceID),
+			zap.Int("inputSampleRate", caps.InputSampleRate),
+			zap.Ints("outputSampleRates", caps.OutputSampleRates),
+			zap.Error(err))
+		c.audio = nil
+		c.audioErr = err
+		c.negotiatedOutputRate, c.lowOutputRate = 0, 0
+		return AudioSettings{}, err
+	}
+
+	if settings.OutputSampleRate != c.engine.config.OutputSampleRate {
+		c.logger.Info("Device cannot play the default output sample rate",
+			zap.String("deviceID", c.deviceID),
+			zap.Int("defaultOutputSampleRate", c.engine.config.OutputSampleRate),
+			zap.Int("outputSampleRate", settings.OutputSampleRate))
+	}
+	c.audio = &settings
+	c.audioErr = nil
+	c.negotiatedOutputRate = settings.OutputSampleRate
+	c.lowOutputRate = lowBandwidthRate(caps.OutputSampleRates, settings.OutputSampleRate)
+	return settings, nil
+}
+
+// outputSampleRate returns the rate of the synthesized audio the device
+// plays, negotiated or the default.
+// The caller must hold the mutex.
+func (c *Conversation) outputSampleRate() int {
+	if c.audio != nil {
+		return c.audio.OutputSampleRate
+	}
+	return c.engine.config.OutputSampleRate
+}
+
+// InputSampleRate returns the rate of the microphone audio the device
+// declared, or the default when it declared none
+func (c *Conversation) InputSampleRate() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.audio != nil {
+		return c.audio.InputSampleRate
+	}
+	return defaultInputSampleRate
+}
+
+// inputSampleRate resolves the sample rate of a listening session. A rate
+// announced with listening_start describes the audio that follows and wins
+// over the hello declaration, but a difference is logged and counted.
+// The caller must hold the mutex.
+func (c *Conversation) inputSampleRate(requested int) (int, error) {
+	if c.audioErr != nil {
+		return 0, c.audioErr
+	}
+
+	declared := defaultInputSampleRate
+	if c.audio != nil {
+		declared = c.audio.InputSampleRate
+	}
+	if requested == 0 {
+		return declared, nil
+	}
+	if requested < minInputSampleRate || requested > maxInputSampleRate {
+		return 0, ErrUnsupportedInputSampleRate
+	}
+	if c.audio != nil && requested != declared {
+		sampleRateMismatches.Add(1)
+		c.logger.Warn("Listening start sample rate differs from the hello declaration",
+			zap.String("deviceID", c.deviceID),
+			zap.Int("declaredSampleRate", declared),
+			zap.Int("sampleRate", requested))
+	}
+	return requested, nil
+}
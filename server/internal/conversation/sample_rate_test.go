@@ -0,0 +1,104 @@
+package conversation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestNegotiateAudio(t *testing.T) {
+	tests := []struct {
+		name    string
+		caps    AudioCapabilities
+		want    AudioSettings
+		wantErr error
+	}{
+		{"nothing declared", AudioCapabilities{}, AudioSettings{48000, 24000}, nil},
+		{"matching declaration", AudioCapabilities{16000, []int{16000, 24000}}, AudioSettings{16000, 24000}, nil},
+		{"highest common output", AudioCapabilities{16000, []int{8000, 11025, 22050}}, AudioSettings{16000, 22050}, nil},
+		{"no common output", AudioCapabilities{16000, []int{11025, 96000}}, AudioSettings{}, ErrUnsupportedOutputSampleRate},
+		{"input too high", AudioCapabilities{96000, nil}, AudioSettings{}, ErrUnsupportedInputSampleRate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NegotiateAudio(tt.caps, 24000)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEngine_ListensAtTheDeclaredSampleRate(t *testing.T) {
+	tests := []struct {
+		name           string
+		requested      int
+		wantRate       int
+		wantMismatches int64
+	}{
+		{"declared rate", 0, 16000, 0},
+		{"matching listening start", 16000, 16000, 0},
+		{"mismatching listening start", 22050, 22050, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+			sink := newRecorder()
+			conv := f.engine.NewConversation("device-1", sink)
+			if _, err := conv.SetAudioCapabilities(AudioCapabilities{InputSampleRate: 16000}); err != nil {
+				t.Fatalf("Failed to negotiate audio: %v", err)
+			}
+
+			before := sampleRateMismatches.Value()
+			conv.StartListening(StartOptions{SampleRate: tt.requested})
+			if event := sink.next(t, EventListeningStart); event.Error != "" {
+				t.Fatalf("Failed to start listening: %s", event.Error)
+			}
+			conv.EndListening()
+			sink.until(t, EventSpeakingEnd)
+
+			if rate := f.stt.Streams()[0].Config.SampleRate; rate != tt.wantRate {
+				t.Errorf("Expected transcription at %d Hz, got %d", tt.wantRate, rate)
+			}
+			if got := sampleRateMismatches.Value() - before; got != tt.wantMismatches {
+				t.Errorf("Expected %d mismatches counted, got %d", tt.wantMismatches, got)
+			}
+		})
+	}
+}
+
+func TestEngine_RefusesUnsupportedAudio(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	conv.StartListening(StartOptions{SampleRate: 96000})
+	if event := sink.next(t, EventListeningStart); event.Error != ErrorUnsupportedAudio {
+		t.Errorf("Expected an out of range rate to be refused, got %q", event.Error)
+	}
+
+	if _, err := conv.SetAudioCapabilities(AudioCapabilities{OutputSampleRates: []int{11025}}); err == nil {
+		t.Fatal("Expected the negotiation to fail")
+	}
+	conv.StartListening(StartOptions{})
+	if event := sink.next(t, EventListeningStart); event.Error != ErrorUnsupportedAudio {
+		t.Errorf("Expected listening to be refused after a failed negotiation, got %q", event.Error)
+	}
+	if len(f.stt.Streams()) != 0 {
+		t.Error("Expected no transcription stream to be opened")
+	}
+
+	// A later declaration the pipeline supports lets the device listen again
+	if _, err := conv.SetAudioCapabilities(AudioCapabilities{OutputSampleRates: []int{16000}}); err != nil {
+		t.Fatalf("Failed to negotiate audio: %v", err)
+	}
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+	if rates := f.tts.SampleRates(); len(rates) != 1 || rates[0] != 16000 {
+		t.Errorf("Expected the response to be synthesized at 16000 Hz, got %v", rates)
+	}
+}
@@ -0,0 +1,193 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+const (
+	// selfTestSampleRate is the sample rate of the self-test audio
+	selfTestSampleRate = 16000
+	// selfTestStageTimeout bounds every stage of a self-test
+	selfTestStageTimeout = 20 * time.Second
+	// selfTestPrompt is sent to the LLM when the self-test audio was not
+	// transcribed to any text, which speech-to-text may rightly do for a tone
+	selfTestPrompt = "Halo, apa kabar?"
+)
+
+// Stages of a self-test, in the order they run
+const (
+	SelfTestSTT = "stt"
+	SelfTestLLM = "llm"
+	SelfTestTTS = "tts"
+)
+
+// SelfTestStage is the outcome of a stage of a self-test
+type SelfTestStage struct {
+	Name    string
+	OK      bool
+	Latency time.Duration
+	Error   string
+	// Output of the stage: the transcription, the response, or the
+	// synthesized audio size
+	Output string
+}
+
+// SelfTestReport is the outcome of a synthetic turn of a device
+type SelfTestReport struct {
+	DeviceID string
+	// The effective configuration of the device
+	ChildID  string
+	Language string
+	VoiceID  string
+	Stages   []SelfTestStage
+}
+
+// OK reports whether every stage succeeded
+func (r SelfTestReport) OK() bool {
+	for _, stage := range r.Stages {
+		if !stage.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// discardSink drops the events of a conversation nobody listens to
+type discardSink struct{}
+
+func (discardSink) Emit(Event) {}
+
+// SelfTest runs a synthetic turn for deviceID the way its conversations
+// run, with the voice, the language and the age of the child it is assigned
+// to, without a connection and without storing anything: a fixed audio is
+// transcribed, the transcription is answered by the LLM, and the response is
+// synthesized. Every stage runs even when an earlier one failed, using a
+// fixed text in place of its output, so that the report covers the whole
+// pipeline.
+func (e *Engine) SelfTest(ctx context.Context, deviceID string) SelfTestReport {
+	c := e.NewConversation(deviceID, discardSink{})
+	c.logger = e.logger.With(zap.String("deviceID", deviceID), zap.Bool("selfTest", true))
+
+	c.mutex.Lock()
+	c.resolveChild(ctx)
+	c.session = &entities.Session{DeviceID: deviceID}
+	language := c.listeningLanguage("")
+	c.session.Metadata.Language = language
+	llmCtx := repositories.WithLocalTime(ctx, c.localTime())
+	if c.forcedLanguage != "" {
+		llmCtx = repositories.WithReplyLanguage(llmCtx, c.forcedLanguage)
+	}
+	if c.childAgeKnown {
+		llmCtx = repositories.WithChildAge(llmCtx, c.childAge)
+	}
+	if len(c.topics) > 0 {
+		llmCtx = repositories.WithDiscouragedTopics(llmCtx, c.topics)
+	}
+	ttsCtx := c.ttsContext(ctx)
+	report := SelfTestReport{DeviceID: deviceID, ChildID: c.childID, Language: language, VoiceID: c.voiceID}
+	c.mutex.Unlock()
+
+	transcript, stage := runSelfTestStage(ctx, SelfTestSTT, func(ctx context.Context) (string, error) {
+		return e.selfTestTranscribe(ctx, language)
+	})
+	report.Stages = append(report.Stages, stage)
+	if transcript == "" {
+		transcript = selfTestPrompt
+	}
+
+	response, stage := runSelfTestStage(llmCtx, SelfTestLLM, func(ctx context.Context) (string, error) {
+		chatSession, err := e.llm.GenerateChat(ctx, nil)
+		if err != nil {
+			return "", err
+		}
+		message, err := chatSession.SendMessage(ctx, entities.Message{Timestamp: time.Now(), Role: entities.UserRole, Content: transcript})
+		if err == nil && message.Content == "" {
+			err = errors.New("empty response")
+		}
+		return message.Content, err
+	})
+	report.Stages = append(report.Stages, stage)
+	if response == "" {
+		response = selfTestPrompt
+	}
+
+	_, stage = runSelfTestStage(ttsCtx, SelfTestTTS, func(ctx context.Context) (string, error) {
+		audio, err := e.ttsRepo.ConvertTextToSpeech(ctx, response)
+		if err != nil {
+			return "", err
+		}
+		size := 0
+		for chunk := range audio {
+			size += len(chunk)
+		}
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if size == 0 {
+			return "", errors.New("no audio synthesized")
+		}
+		return fmt.Sprintf("%d bytes", size), nil
+	})
+	report.Stages = append(report.Stages, stage)
+
+	e.logger.Info("Ran device self-test",
+		zap.String("deviceID", deviceID),
+		zap.Bool("ok", report.OK()))
+	return report
+}
+
+// runSelfTestStage runs a stage within selfTestStageTimeout, timing it
+func runSelfTestStage(ctx context.Context, name string, run func(ctx context.Context) (string, error)) (string, SelfTestStage) {
+	ctx, cancel := context.WithTimeout(ctx, selfTestStageTimeout)
+	defer cancel()
+
+	started := time.Now()
+	output, err := run(ctx)
+	stage := SelfTestStage{Name: name, OK: err == nil, Latency: time.Since(started), Output: output}
+	if err != nil {
+		stage.Error = err.Error()
+		return "", stage
+	}
+	return output, stage
+}
+
+// selfTestTranscribe streams the self-test audio to speech-to-text
+func (e *Engine) selfTestTranscribe(ctx context.Context, language string) (string, error) {
+	stream, err := e.sttRepo.InitTranscribeStreaming(ctx, repositories.AudioConfig{
+		SampleRate: selfTestSampleRate,
+		Language:   language,
+		Encoding:   "LINEAR16",
+	})
+	if err != nil {
+		return "", err
+	}
+	audio := selfTestAudio()
+	// 100ms chunks, like a device streams
+	for start := 0; start < len(audio); start += selfTestSampleRate / 5 {
+		end := min(start+selfTestSampleRate/5, len(audio))
+		if err := stream.Stream(audio[start:end]); err != nil {
+			stream.End()
+			return "", err
+		}
+	}
+	return stream.End()
+}
+
+// selfTestAudio returns a second of a 440Hz tone as 16-bit PCM
+func selfTestAudio() []byte {
+	pcm := make([]byte, 0, 2*selfTestSampleRate)
+	for i := 0; i < selfTestSampleRate; i++ {
+		sample := int16(8000 * math.Sin(2*math.Pi*440*float64(i)/selfTestSampleRate))
+		pcm = append(pcm, byte(sample), byte(uint16(sample)>>8))
+	}
+	return pcm
+}
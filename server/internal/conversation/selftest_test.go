@@ -0,0 +1,66 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestEngine_SelfTestRunsEveryStageWithDeviceConfiguration(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo boneka"})
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}, VoiceID: "voice-mama", Language: "en-US"}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+
+	report := f.engine.SelfTest(context.Background(), "device-1")
+
+	if !report.OK() || len(report.Stages) != 3 {
+		t.Fatalf("Expected three successful stages, got %+v", report.Stages)
+	}
+	for i, name := range []string{SelfTestSTT, SelfTestLLM, SelfTestTTS} {
+		if stage := report.Stages[i]; stage.Name != name || stage.Output == "" {
+			t.Errorf("Expected stage %q with its output, got %+v", name, stage)
+		}
+	}
+	if report.Stages[0].Output != "halo boneka" || report.Stages[1].Output != "Halo juga!" {
+		t.Errorf("Expected the transcription answered, got %+v", report.Stages)
+	}
+	if report.ChildID != child.ID || report.Language != "en-US" || report.VoiceID != "voice-mama" {
+		t.Errorf("Expected the child's configuration, got %+v", report)
+	}
+	if streams := f.stt.Streams(); len(streams) != 1 || streams[0].Config.Language != "en-US" {
+		t.Errorf("Expected the audio transcribed in the child's language, got %d streams", len(streams))
+	}
+	if voiceIDs := f.tts.VoiceIDs(); len(voiceIDs) != 1 || voiceIDs[0] != "voice-mama" {
+		t.Errorf("Expected the response in the child's voice, got %v", voiceIDs)
+	}
+	if sessions := f.sessions.Sessions(); len(sessions) != 0 {
+		t.Errorf("Expected nothing stored, got %d sessions", len(sessions))
+	}
+}
+
+func TestEngine_SelfTestReportsFailingStage(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{InitErr: errors.New("stt down")})
+
+	report := f.engine.SelfTest(context.Background(), "device-1")
+
+	if report.OK() || len(report.Stages) != 3 {
+		t.Fatalf("Expected a failed self-test of three stages, got %+v", report.Stages)
+	}
+	if stt := report.Stages[0]; stt.OK || stt.Error != "stt down" {
+		t.Errorf("Expected the speech-to-text failure reported, got %+v", stt)
+	}
+	if !report.Stages[1].OK || !report.Stages[2].OK {
+		t.Errorf("Expected the later stages to run with the fixed prompt, got %+v", report.Stages)
+	}
+	if messages := f.llm.Messages(); len(messages) != 1 || messages[0] != selfTestPrompt {
+		t.Errorf("Expected the fixed prompt sent to the LLM, got %v", messages)
+	}
+	if report.Language != defaultLanguage {
+		t.Errorf("Expected the default language for a device without a child, got %q", report.Language)
+	}
+}
@@ -0,0 +1,76 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// endSession marks a session that can no longer be continued as ended, so
+// that storages allowing a single active session per device accept the
+// next one. The caller must hold the mutex.
+func (c *Conversation) endSession(ctx context.Context, session *entities.Session, now time.Time) {
+	c.publishLifecycle(events.SessionEnded, session.ID, events.ReasonIdle)
+	if !session.EndedAt.IsZero() {
+		return
+	}
+
+	session.EndedAt = now
+	if isEphemeral(session) {
+		return
+	}
+	if err := c.engine.sessionRepo.Update(ctx, session); err != nil {
+		// A conflict on the next creation is reconciled again
+		c.logger.Warn("Failed to end previous session",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", session.ID),
+			zap.Error(err))
+		return
+	}
+	c.summarizeForParent(session)
+}
+
+// createSession creates a new session for the device and reports whether it
+// did. When the storage still holds another active session of the device,
+// such as one started by another connection, that session is continued if
+// it can be; otherwise it is ended and the creation retried.
+// The caller must hold the mutex.
+func (c *Conversation) createSession(ctx context.Context, now time.Time) (*entities.Session, bool, error) {
+	for attempt := 0; ; attempt++ {
+		session := &entities.Session{
+			DeviceID: c.deviceID,
+			ChildID:  c.childID,
+		}
+		err := c.engine.sessionRepo.Create(ctx, session)
+		if err == nil {
+			return session, true, nil
+		}
+		if !errors.Is(err, repositories.ErrActiveSessionExists) || attempt >= c.engine.config.SessionCreateRetries {
+			return nil, false, err
+		}
+
+		active, err := c.engine.sessionRepo.GetLastByDeviceID(ctx, c.deviceID)
+		if err != nil {
+			return nil, false, err
+		}
+		if active != nil && active.CanContinueThisSession() {
+			c.logger.Info("Continuing active session found while creating a new one",
+				zap.String("deviceID", c.deviceID),
+				zap.String("sessionID", active.ID))
+			return active, false, nil
+		}
+
+		c.logger.Info("Retrying session creation after a conflicting active session",
+			zap.String("deviceID", c.deviceID),
+			zap.Int("attempt", attempt+1))
+		if active != nil {
+			c.endSession(ctx, active, now)
+		}
+	}
+}
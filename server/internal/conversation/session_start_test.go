@@ -0,0 +1,78 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestEngine_EndsIdleSessionBeforeStartingAnother(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	old := &entities.Session{DeviceID: "device-1", LastMessageAt: time.Now().Add(-time.Hour)}
+	if err := f.sessions.Create(context.Background(), old); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	conv.StartListening(StartOptions{})
+	if event := sink.next(t, EventListeningStart); event.Error != "" {
+		t.Fatalf("Failed to start listening: %s", event.Error)
+	}
+
+	sessions := f.sessions.Sessions()
+	if len(sessions) != 2 {
+		t.Fatalf("Expected the old and a new session, got %d", len(sessions))
+	}
+	if sessions[0].ID != old.ID || sessions[0].EndedAt.IsZero() {
+		t.Errorf("Expected the old session to be ended, got %+v", sessions[0])
+	}
+	if sessions[1].ID != conv.SessionID() || !sessions[1].EndedAt.IsZero() {
+		t.Errorf("Expected the new session to be active and in use, got %+v", sessions[1])
+	}
+}
+
+func TestEngine_RetriesConflictingSessionCreation(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	f.sessions.Conflicts = 1
+	sub := f.bus.Subscribe(0)
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	conv.StartListening(StartOptions{})
+	if event := sink.next(t, EventListeningStart); event.Error != "" {
+		t.Fatalf("Expected the conflict to be reconciled, got %s", event.Error)
+	}
+
+	sessions := f.sessions.Sessions()
+	if len(sessions) != 2 || sessions[0].EndedAt.IsZero() {
+		t.Fatalf("Expected the conflicting session to be ended, got %+v", sessions)
+	}
+	if sessions[1].ID != conv.SessionID() {
+		t.Errorf("Expected the retried session %q to be in use, got %q", sessions[1].ID, conv.SessionID())
+	}
+	if ended := nextLifecycle(t, sub); ended.SessionID != sessions[0].ID {
+		t.Errorf("Expected session_ended for the conflicting session, got %+v", ended)
+	}
+	if started := nextLifecycle(t, sub); started.SessionID != conv.SessionID() {
+		t.Errorf("Expected session_started for the new session, got %+v", started)
+	}
+}
+
+func TestEngine_GivesUpSessionCreationAfterRetries(t *testing.T) {
+	f := newEngineFixture(t, Config{SessionCreateRetries: 1}, &conversationtest.STT{Transcript: "halo"})
+	f.sessions.Conflicts = 3
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	conv.StartListening(StartOptions{})
+	if event := sink.next(t, EventListeningStart); event.Error != "failed to create new session" {
+		t.Errorf("Expected session creation to fail, got %q", event.Error)
+	}
+	if conv.SessionID() != "" {
+		t.Errorf("Expected no session in use, got %q", conv.SessionID())
+	}
+}
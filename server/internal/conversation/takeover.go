@@ -0,0 +1,137 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// An operator may take over a conversation for support, typing the doll's
+// responses instead of the LLM. The child keeps hearing the doll: the
+// operator's text is synthesized with the doll's voice and framed by the
+// usual speaking_start and speaking_end events. The child's utterances are
+// stored as they are transcribed and the operator's responses are stored
+// with the operator's ID. Once the operator hands the conversation back, the
+// LLM picks up with a chat session that includes what the operator said.
+
+var (
+	// ErrNotTakenOver rejects a response of an operator who did not take
+	// over the conversation
+	ErrNotTakenOver = errors.New("conversation not taken over by the operator")
+	// ErrNoSession rejects a response before the device ever listened
+	ErrNoSession = errors.New("no conversation session")
+	// ErrEmptyResponse rejects a response without text
+	ErrEmptyResponse = errors.New("empty response")
+)
+
+// TakeOver has operatorID answer the child instead of the LLM, from the
+// next transcription on
+func (c *Conversation) TakeOver(operatorID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.operatorID = operatorID
+	c.logger.Info("Operator took over the conversation",
+		zap.String("deviceID", c.deviceID),
+		zap.String("operatorID", operatorID))
+}
+
+// HandBack returns the conversation taken over by operatorID to the LLM
+func (c *Conversation) HandBack(operatorID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.operatorID != operatorID {
+		return
+	}
+	c.operatorID = ""
+	// The chat session does not know what the operator said
+	c.chatSession = nil
+	c.logger.Info("Operator handed the conversation back",
+		zap.String("deviceID", c.deviceID),
+		zap.String("operatorID", operatorID))
+}
+
+// SpeakAsOperator speaks text typed by operatorID as the doll's response,
+// cancelling the response in progress
+func (c *Conversation) SpeakAsOperator(operatorID, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ErrEmptyResponse
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if operatorID == "" || c.operatorID != operatorID {
+		return ErrNotTakenOver
+	}
+	if c.session == nil {
+		return ErrNoSession
+	}
+
+	session := c.session
+	response := entities.Message{
+		Role:       entities.DollRole,
+		Content:    text,
+		OperatorID: operatorID,
+	}
+	if !c.startResponse(func(ctx context.Context) {
+		c.speakOperator(ctx, session, response)
+	}) {
+		return ErrNotTakenOver
+	}
+	return nil
+}
+
+// awaitOperator stores the child's message, which the operator answers
+func (c *Conversation) awaitOperator(ctx context.Context, session *entities.Session, message entities.Message) {
+	// The message is stored even when the operator answers right away
+	stored, _ := c.redactTurn(context.WithoutCancel(ctx), message)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.saveMessages(session, stored)
+}
+
+// speakOperator synthesizes and speaks the operator's response, then stores it
+func (c *Conversation) speakOperator(ctx context.Context, session *entities.Session, response entities.Message) {
+	c.mutex.Lock()
+	ttsCtx := c.ttsContext(ctx)
+	c.mutex.Unlock()
+
+	audio, err := c.engine.ttsRepo.ConvertTextToSpeech(ttsCtx, response.Content)
+	if err != nil {
+		c.logger.Error("Failed to convert operator response to speech",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", session.ID),
+			zap.String("operatorID", response.OperatorID),
+			zap.Error(err))
+		c.emit(Event{Type: EventError, SessionID: session.ID, Error: "failed to synthesize response"})
+		return
+	}
+
+	response.Timestamp = time.Now()
+	c.emit(Event{Type: EventSpeakingStart, SessionID: session.ID, Message: &response})
+	for chunk := range audio {
+		// A cancelled response drains its audio unheard
+		if ctx.Err() != nil {
+			continue
+		}
+		c.emit(Event{Type: EventAudio, SessionID: session.ID, Audio: chunk})
+	}
+	c.emit(Event{Type: EventSpeakingEnd, SessionID: session.ID})
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lastSpeakingEnd = time.Now()
+	c.lastSpokenText = response.Content
+	c.logger.Info("Spoke operator response",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", session.ID),
+		zap.String("operatorID", response.OperatorID),
+		zap.String("response", response.Content))
+	c.saveMessages(session, response)
+}
@@ -0,0 +1,98 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestTakeover_OperatorAnswersInsteadOfLLM(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "aku sedih"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	if err := conv.SpeakAsOperator("operator-1", "Halo"); !errors.Is(err, ErrNotTakenOver) {
+		t.Fatalf("Expected a response without a takeover to be rejected, got %v", err)
+	}
+
+	conv.TakeOver("operator-1")
+	speak(t, conv, sink)
+	if event := sink.next(t, EventListeningEnd); event.Message == nil || event.Message.Content != "aku sedih" {
+		t.Fatalf("Expected the transcription, got %+v", event)
+	}
+
+	if err := conv.SpeakAsOperator("operator-2", "Halo"); !errors.Is(err, ErrNotTakenOver) {
+		t.Errorf("Expected another operator to be rejected, got %v", err)
+	}
+	if err := conv.SpeakAsOperator("operator-1", "Kenapa sedih, sayang?"); err != nil {
+		t.Fatalf("Failed to speak as operator: %v", err)
+	}
+	events := sink.until(t, EventSpeakingEnd)
+	want := []EventType{EventSpeakingStart, EventAudio, EventAudio, EventSpeakingEnd}
+	got := eventTypes(events)
+	if len(got) != len(want) {
+		t.Fatalf("Expected events %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected events %v, got %v", want, got)
+		}
+	}
+	if message := events[0].Message; message == nil || message.Content != "Kenapa sedih, sayang?" {
+		t.Errorf("Expected the operator's response, got %+v", message)
+	}
+	if messages := f.llm.Messages(); len(messages) != 0 {
+		t.Errorf("Expected the LLM to be bypassed, got %v", messages)
+	}
+	if texts := f.tts.Texts(); len(texts) != 1 || texts[0] != "Kenapa sedih, sayang?" {
+		t.Errorf("Expected the operator's response to be synthesized, got %v", texts)
+	}
+
+	conv.Close()
+	session, _ := f.sessions.GetLastByDeviceID(context.Background(), "device-1")
+	if session == nil || len(session.Messages) != 2 {
+		t.Fatalf("Expected the child's and the operator's messages stored, got %+v", session)
+	}
+	if child := session.Messages[0]; child.Role != entities.UserRole || child.Content != "aku sedih" || child.OperatorID != "" {
+		t.Errorf("Expected the child's message first, got %+v", child)
+	}
+	if operator := session.Messages[1]; operator.Role != entities.DollRole || operator.OperatorID != "operator-1" {
+		t.Errorf("Expected the response stored as the operator's, got %+v", operator)
+	}
+}
+
+func TestTakeover_HandBackResumesLLM(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	conv.TakeOver("operator-1")
+	speak(t, conv, sink)
+	sink.next(t, EventListeningEnd)
+	if err := conv.SpeakAsOperator("operator-1", "Halo, ini boneka"); err != nil {
+		t.Fatalf("Failed to speak as operator: %v", err)
+	}
+	sink.until(t, EventSpeakingEnd)
+
+	// Another operator cannot hand back the conversation
+	conv.HandBack("operator-2")
+	if err := conv.SpeakAsOperator("operator-1", "Masih di sini"); err != nil {
+		t.Fatalf("Expected the takeover to last, got %v", err)
+	}
+	sink.until(t, EventSpeakingEnd)
+
+	conv.HandBack("operator-1")
+	if err := conv.SpeakAsOperator("operator-1", "Halo"); !errors.Is(err, ErrNotTakenOver) {
+		t.Errorf("Expected responses to be rejected after the hand back, got %v", err)
+	}
+	speak(t, conv, sink)
+	if events := sink.until(t, EventSpeakingEnd); events[len(events)-2].Type != EventAudio {
+		t.Fatalf("Expected the LLM response to be spoken, got %v", eventTypes(events))
+	}
+	if messages := f.llm.Messages(); len(messages) != 1 || messages[0] != "halo" {
+		t.Errorf("Expected the LLM to answer after the hand back, got %v", messages)
+	}
+}
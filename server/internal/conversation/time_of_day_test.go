@@ -0,0 +1,58 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestEngine_PassesChildLocalTimeToLLM(t *testing.T) {
+	f := newEngineFixture(t, Config{DefaultTimezone: "Asia/Jakarta"}, &conversationtest.STT{Transcript: "halo"})
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}, Timezone: "Asia/Jayapura"}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	now := time.Date(2024, time.March, 4, 22, 30, 0, 0, time.UTC)
+	f.engine.now = func() time.Time { return now }
+
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	// 07:30 in Jayapura
+	runTurn(t, conv, sink)
+	// 19:00 in Jayapura
+	now = now.Add(11*time.Hour + 30*time.Minute)
+	runTurn(t, conv, sink)
+
+	times := f.llm.LocalTimes()
+	if len(times) != 2 {
+		t.Fatalf("Expected the local time of 2 turns, got %v", times)
+	}
+	if got := times[0].Location().String(); got != "Asia/Jayapura" {
+		t.Errorf("Expected the child's timezone, got %s", got)
+	}
+	if times[0].Hour() != 7 || times[0].Weekday() != time.Tuesday {
+		t.Errorf("Expected Tuesday morning, got %s", times[0])
+	}
+	if times[1].Hour() != 19 {
+		t.Errorf("Expected the evening, got %s", times[1])
+	}
+}
+
+func TestEngine_DefaultsLocalTimeToConfiguredTimezone(t *testing.T) {
+	f := newEngineFixture(t, Config{DefaultTimezone: "Asia/Makassar"}, &conversationtest.STT{Transcript: "halo"})
+	now := time.Date(2024, time.March, 4, 0, 0, 0, 0, time.UTC)
+	f.engine.now = func() time.Time { return now }
+
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+	runTurn(t, conv, sink)
+
+	times := f.llm.LocalTimes()
+	if len(times) != 1 || times[0].Location().String() != "Asia/Makassar" || times[0].Hour() != 8 {
+		t.Errorf("Expected 08:00 in the default timezone, got %v", times)
+	}
+}
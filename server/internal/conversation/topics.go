@@ -0,0 +1,55 @@
+package conversation
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// matchTopics returns the topics mentioned in text. Matching is a cheap,
+// case-insensitive whole-word search, so "war" matches "the war" but not
+// "warm"; it flags turns for parental review and is not a content filter.
+func matchTopics(text string, topics []string) []string {
+	var matches []string
+	for _, topic := range topics {
+		words := strings.Fields(topic)
+		if len(words) == 0 {
+			continue
+		}
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		pattern := `(?i)(^|[^\pL\pN])` + strings.Join(words, `\s+`) + `($|[^\pL\pN])`
+		if regexp.MustCompile(pattern).MatchString(text) {
+			matches = append(matches, topic)
+		}
+	}
+	return matches
+}
+
+// reportTopics logs and publishes every discouraged topic mentioned in text.
+// source is events.ReasonChildInput or events.ReasonModelResponse.
+// The caller must hold the mutex.
+func (c *Conversation) reportTopics(sessionID, source, text string) {
+	for _, topic := range matchTopics(text, c.topics) {
+		c.logger.Warn("Discouraged topic detected",
+			zap.String("deviceID", c.deviceID),
+			zap.String("childID", c.childID),
+			zap.String("sessionID", sessionID),
+			zap.String("source", source),
+			zap.String("topic", topic))
+		c.engine.publisher.Publish(events.Event{
+			Type:      events.TopicDetected,
+			DeviceID:  c.deviceID,
+			ChildID:   c.childID,
+			SessionID: sessionID,
+			Reason:    source,
+			Topic:     topic,
+			Timestamp: time.Now(),
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package conversation
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+func TestMatchTopics(t *testing.T) {
+	topics := []string{"war", "ghost stories", "  "}
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Tell me about the WAR!", []string{"war"}},
+		{"I feel warm today", nil},
+		{"Do you know any ghost\nstories?", []string{"ghost stories"}},
+		{"war and ghost stories", []string{"war", "ghost stories"}},
+	}
+	for _, tt := range tests {
+		if got := matchTopics(tt.text, topics); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("matchTopics(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEngine_SteersAwayFromDiscouragedTopics(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "ceritakan tentang hantu"})
+	f.llm.Reply = "Hantu itu tidak ada, ayo bicara tentang bintang!"
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}, DiscouragedTopics: []string{"hantu"}}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	sub := f.bus.Subscribe(0)
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+
+	if topics := f.llm.Topics(); len(topics) != 1 || !reflect.DeepEqual(topics[0], []string{"hantu"}) {
+		t.Errorf("Expected the child's topics to reach the LLM, got %v", topics)
+	}
+
+	var detected []events.Event
+	for len(detected) < 2 {
+		if event := nextLifecycle(t, sub); event.Type == events.TopicDetected {
+			detected = append(detected, event)
+		}
+	}
+	for i, source := range []string{events.ReasonChildInput, events.ReasonModelResponse} {
+		event := detected[i]
+		if event.Reason != source || event.Topic != "hantu" || event.ChildID != child.ID || event.SessionID != conv.SessionID() {
+			t.Errorf("Expected a %s topic hit on the child's session, got %+v", source, event)
+		}
+	}
+}
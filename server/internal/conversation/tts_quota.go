@@ -0,0 +1,62 @@
+package conversation
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// While the text-to-speech quota is exceeded every synthesis fails, the
+// phrases not prewarmed included, so the doll can only say the speech
+// unavailable phrase from its prewarmed audio. Without it the device is told
+// with ErrorTTSQuotaExceeded, so that it can play a sound of its own.
+
+// prewarmedSpeech returns the audio of a canned phrase when it is replayed
+// from memory, reporting false when it would have to be synthesized
+func (e *Engine) prewarmedSpeech(ctx context.Context, phrase string) (<-chan []byte, bool) {
+	if !e.config.PrewarmPhrases || phrase == "" {
+		return nil, false
+	}
+	audio, ok := e.canned.get(cannedKeyFor(ctx, phrase))
+	if !ok {
+		return nil, false
+	}
+	return cachedResponse{audio: audio}.replay(), true
+}
+
+// speakUnavailable speaks the speech unavailable phrase instead of a
+// response refused for lack of text-to-speech quota. A response that did not
+// start speaking yet is framed with EventSpeakingStart and EventSpeakingEnd.
+func (c *Conversation) speakUnavailable(ctx context.Context, sessionID string, turn *turnSummary, framed bool) {
+	phrase := c.engine.config.SpeechUnavailablePhrase
+	audio, ok := c.engine.prewarmedSpeech(ctx, phrase)
+	if !ok {
+		c.logger.Warn("Text-to-speech quota exceeded, nothing to speak",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", sessionID))
+		c.emit(Event{Type: EventError, SessionID: sessionID, Error: ErrorTTSQuotaExceeded})
+		return
+	}
+
+	c.logger.Warn("Text-to-speech quota exceeded, speaking the speech unavailable phrase",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", sessionID))
+	if framed {
+		c.emit(Event{Type: EventSpeakingStart, SessionID: sessionID, Message: &entities.Message{
+			Timestamp: time.Now(),
+			Role:      entities.DollRole,
+			Content:   phrase,
+		}})
+	}
+	for audioData := range audio {
+		turn.AudioChunks++
+		turn.AudioBytes += len(audioData)
+		c.emit(Event{Type: EventAudio, SessionID: sessionID, Audio: audioData})
+	}
+	if framed {
+		c.emit(Event{Type: EventSpeakingEnd, SessionID: sessionID})
+	}
+}
@@ -0,0 +1,58 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// quotaExceeded fails the synthesis of the fixture's reply for lack of quota
+func quotaExceeded(f *engineFixture) {
+	f.tts.Failures = map[string]error{
+		f.llm.Reply: fmt.Errorf("%w: out of credits", repositories.ErrTTSQuotaExceeded),
+	}
+}
+
+func TestEngine_SpeaksPrewarmedPhraseWhileQuotaExceeded(t *testing.T) {
+	for _, pipelining := range []bool{false, true} {
+		t.Run(fmt.Sprintf("pipelining %v", pipelining), func(t *testing.T) {
+			f := newEngineFixture(t, Config{PrewarmPhrases: true, SentencePipelining: pipelining}, &conversationtest.STT{Transcript: "halo"})
+			f.tts.EchoText = true
+			if err := f.engine.PrewarmPhrases(context.Background()); err != nil {
+				t.Fatalf("Failed to prewarm: %v", err)
+			}
+			quotaExceeded(f)
+			sink := newRecorder()
+			conv := f.engine.NewConversation("device-1", sink)
+
+			speak(t, conv, sink)
+			var audio []string
+			for _, event := range sink.until(t, EventSpeakingEnd) {
+				switch event.Type {
+				case EventAudio:
+					audio = append(audio, string(event.Audio))
+				case EventError:
+					t.Fatalf("Expected the phrase to be spoken, got error %q", event.Error)
+				}
+			}
+			if len(audio) != 1 || audio[0] != defaultSpeechUnavailablePhrase {
+				t.Errorf("Expected the speech unavailable phrase, got %q", audio)
+			}
+		})
+	}
+}
+
+func TestEngine_ReportsQuotaExceededWithoutPrewarmedPhrase(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo"})
+	quotaExceeded(f)
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	if event := sink.next(t, EventError); event.Error != ErrorTTSQuotaExceeded {
+		t.Errorf("Expected %q, got %q", ErrorTTSQuotaExceeded, event.Error)
+	}
+}
@@ -0,0 +1,86 @@
+package conversation
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// turnSinkTimeout bounds storing the record of a turn
+const turnSinkTimeout = 10 * time.Second
+
+// nopTurnSink drops the records of turns, for when no sink is set
+type nopTurnSink struct{}
+
+func (nopTurnSink) RecordTurn(ctx context.Context, turn entities.TurnRecorded) error {
+	return nil
+}
+
+// SetTurnSink hands the record of every turn of the children whose parent
+// consented to recording to sink, for training
+func (e *Engine) SetTurnSink(sink repositories.TurnSink) {
+	e.turnSink = sink
+}
+
+// recordTurn hands the record of a completed turn to the turn sink in the
+// background, when the parent consented to recording. The transcription and
+// the response are redacted even when PII redaction of stored transcriptions
+// is disabled, and the prompt is recorded as the redacted transcription even
+// when the LLM was sent it raw.
+// The caller must hold the mutex.
+func (c *Conversation) recordTurn(ttsCtx context.Context, session *entities.Session, stored, response entities.Message, cached bool, level repositories.DegradationLevel) {
+	if c.childID == "" || !c.recordingConsent {
+		return
+	}
+
+	redactor := c.engine.redactor
+	if redactor == nil {
+		redactor = &piiRedactor{}
+	}
+	transcription := stored.Content
+	if c.engine.redactor == nil {
+		transcription = redactor.redactPatterns(transcription)
+	}
+
+	turn := entities.TurnRecorded{
+		SessionID:               session.ID,
+		DeviceID:                c.deviceID,
+		ChildID:                 c.childID,
+		Transcription:           transcription,
+		TranscriptionConfidence: stored.Metadata.TranscriptionConfidence,
+		Emotion:                 stored.Metadata.Emotion,
+		Language:                c.language(session),
+		Model:                   c.engine.modelName(),
+		Prompt:                  transcription,
+		Response:                redactor.redactPatterns(response.Content),
+		Cached:                  cached,
+		Degradation:             level.String(),
+		Timestamp:               c.engine.now(),
+	}
+	if c.recording && c.recordingSessionID == session.ID {
+		turn.InputAudio = &entities.TurnAudio{
+			SessionID:  session.ID,
+			StartedAt:  stored.Timestamp,
+			DurationMs: stored.DurationMs,
+		}
+	}
+	turn.Speech.VoiceID, _ = repositories.VoiceIDFromContext(ttsCtx)
+	turn.Speech.Language, _ = repositories.LanguageFromContext(ttsCtx)
+	turn.Speech.SampleRate, _ = repositories.SampleRateFromContext(ttsCtx)
+	turn.Speech.ChunkSize, _ = repositories.ChunkSizeFromContext(ttsCtx)
+
+	sink := c.engine.turnSink
+	c.background(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), turnSinkTimeout)
+		defer cancel()
+		if err := sink.RecordTurn(ctx, turn); err != nil {
+			c.logger.Warn("Failed to record turn",
+				zap.String("sessionID", turn.SessionID),
+				zap.Error(err))
+		}
+	})
+}
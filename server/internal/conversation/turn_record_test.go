@@ -0,0 +1,130 @@
+package conversation
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// turnSink keeps the recorded turns
+type turnSink struct {
+	mu    sync.Mutex
+	turns []entities.TurnRecorded
+}
+
+func (s *turnSink) RecordTurn(ctx context.Context, turn entities.TurnRecorded) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.turns = append(s.turns, turn)
+	return nil
+}
+
+func (s *turnSink) recorded() []entities.TurnRecorded {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]entities.TurnRecorded(nil), s.turns...)
+}
+
+func TestEngine_RecordsTurnWithConsent(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "nomorku 0812 3456 7890"})
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}, RecordingConsent: true}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	f.engine.SetRecorder(&audioRecorder{}, &auditTrail{})
+	sink := &turnSink{}
+	f.engine.SetTurnSink(sink)
+
+	events := newRecorder()
+	conv := f.engine.NewConversation("device-1", events)
+	speak(t, conv, events)
+	events.until(t, EventSpeakingEnd)
+	conv.Close()
+
+	turns := sink.recorded()
+	if len(turns) != 1 {
+		t.Fatalf("Expected one turn recorded, got %d", len(turns))
+	}
+	turn := turns[0]
+	if turn.SessionID != conv.SessionID() || turn.ChildID != child.ID || turn.DeviceID != "device-1" {
+		t.Errorf("Expected the turn of the child's session, got %+v", turn)
+	}
+	if turn.Transcription != "nomorku [PHONE]" || turn.Prompt != turn.Transcription {
+		t.Errorf("Expected the transcription redacted without PII redaction configured, got %q and prompt %q", turn.Transcription, turn.Prompt)
+	}
+	if turn.Response == "" || turn.Degradation != "none" {
+		t.Errorf("Expected the response at no degradation, got %+v", turn)
+	}
+	if turn.InputAudio == nil || turn.InputAudio.SessionID != conv.SessionID() {
+		t.Errorf("Expected the recorded audio referenced, got %+v", turn.InputAudio)
+	}
+	if turn.Speech.Language != "id-ID" {
+		t.Errorf("Expected the speech parameters recorded, got %+v", turn.Speech)
+	}
+}
+
+func TestEngine_SkipsTurnRecordWithoutConsent(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo boneka"})
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	sink := &turnSink{}
+	f.engine.SetTurnSink(sink)
+
+	events := newRecorder()
+	conv := f.engine.NewConversation("device-1", events)
+	speak(t, conv, events)
+	events.until(t, EventSpeakingEnd)
+	conv.Close()
+
+	if turns := sink.recorded(); len(turns) != 0 {
+		t.Errorf("Expected no turn recorded without consent, got %+v", turns)
+	}
+}
+
+// blockingTurnSink holds every record until released
+type blockingTurnSink struct {
+	release chan struct{}
+}
+
+func (s *blockingTurnSink) RecordTurn(ctx context.Context, turn entities.TurnRecorded) error {
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+func TestEngine_TurnRecordDoesNotDelayResponse(t *testing.T) {
+	f := newEngineFixture(t, Config{}, &conversationtest.STT{Transcript: "halo boneka"})
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}, RecordingConsent: true}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	sink := &blockingTurnSink{release: make(chan struct{})}
+	defer close(sink.release)
+	f.engine.SetTurnSink(sink)
+
+	events := newRecorder()
+	conv := f.engine.NewConversation("device-1", events)
+	speak(t, conv, events)
+	events.until(t, EventSpeakingEnd)
+
+	// The next turn starts while the first record is still being stored
+	done := make(chan struct{})
+	go func() {
+		speak(t, conv, events)
+		events.until(t, EventSpeakingEnd)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the next response not to wait for the turn sink")
+	}
+}
@@ -0,0 +1,80 @@
+package conversation
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// turnSummary collects the result and timings of one turn, so that a bad turn
+// can be debugged from a single log entry
+type turnSummary struct {
+	SessionID     string
+	Transcription string
+	Language      string
+	Model         string
+	Response      string
+	Cached        bool
+	// Fallback is set when a fallback was spoken instead of the response of
+	// the LLM
+	Fallback bool
+	// Confidence of the transcription, zero when none was reported
+	Confidence  float64
+	AudioBytes  int
+	AudioChunks int
+
+	// How long the listening session lasted
+	Listening time.Duration
+	// Time from listening end to the final transcription
+	Transcribing time.Duration
+	// Time the LLM took to answer, zero for a cached response
+	Generating time.Duration
+	// Time from requesting speech to its first chunk
+	FirstAudio time.Duration
+	// Time from requesting speech to its last chunk
+	Synthesizing time.Duration
+	// Time from listening end to the last chunk
+	Total time.Duration
+}
+
+// modelName names the model answering the turns
+func (e *Engine) modelName() string {
+	if e.config.DemoMode {
+		return "demo"
+	}
+	if namer, ok := e.llm.(repositories.ModelNamer); ok {
+		return namer.ModelName()
+	}
+	return ""
+}
+
+// logTurn writes the summary of a completed turn. The transcription is the
+// stored one, and the response is redacted the same way when PII redaction
+// is enabled.
+// The caller must hold the mutex.
+func (c *Conversation) logTurn(turn turnSummary) {
+	if c.engine.redactor != nil {
+		turn.Response = c.engine.redactor.redactPatterns(turn.Response)
+	}
+	c.logger.Info("Turn completed",
+		zap.String("deviceID", c.deviceID),
+		zap.String("childID", c.childID),
+		zap.String("sessionID", turn.SessionID),
+		zap.String("transcription", turn.Transcription),
+		zap.String("language", turn.Language),
+		zap.String("model", turn.Model),
+		zap.String("response", turn.Response),
+		zap.Bool("cached", turn.Cached),
+		zap.Bool("fallback", turn.Fallback),
+		zap.Float64("confidence", turn.Confidence),
+		zap.Int("audioBytes", turn.AudioBytes),
+		zap.Int("audioChunks", turn.AudioChunks),
+		zap.Duration("listening", turn.Listening),
+		zap.Duration("transcribing", turn.Transcribing),
+		zap.Duration("generating", turn.Generating),
+		zap.Duration("firstAudio", turn.FirstAudio),
+		zap.Duration("synthesizing", turn.Synthesizing),
+		zap.Duration("total", turn.Total))
+}
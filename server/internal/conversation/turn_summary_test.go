@@ -0,0 +1,57 @@
+package conversation
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+func TestEngine_LogsTurnSummary(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+	tts := &conversationtest.TTS{Chunks: [][]byte{{0xAA, 0xAA}, {0xBB}}}
+	llm := &conversationtest.LLM{Reply: "Nomornya 0812 3456 7890, ya!", Model: "gemini-test"}
+	stt := &conversationtest.STT{Transcript: "telepon 0812 3456 7890"}
+	engine, err := NewEngine(Config{PIIRedaction: true}, llm, tts, stt, &conversationtest.SessionRepository{}, adapters.NewMemoryChildRepository(), adapters.NewMemoryQuietHoursRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := newRecorder()
+	conv := engine.NewConversation("device-1", sink)
+
+	storedTurn(t, conv, sink)
+
+	entries := logs.FilterMessage("Turn completed").All()
+	if len(entries) != 1 {
+		t.Fatalf("Expected one turn summary, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	want := map[string]interface{}{
+		"deviceID":      "device-1",
+		"transcription": "telepon [PHONE]",
+		"language":      "id-ID",
+		"model":         "gemini-test",
+		"response":      "Nomornya [PHONE], ya!",
+		"cached":        false,
+		"audioBytes":    int64(3),
+		"audioChunks":   int64(2),
+	}
+	for key, value := range want {
+		if fields[key] != value {
+			t.Errorf("Expected %s %v, got %v", key, value, fields[key])
+		}
+	}
+	if fields["sessionID"] == "" || fields["sessionID"] == nil {
+		t.Error("Expected the session ID in the summary")
+	}
+	for _, key := range []string{"listening", "transcribing", "generating", "firstAudio", "synthesizing", "total"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("Expected the %s duration in the summary", key)
+		}
+	}
+}
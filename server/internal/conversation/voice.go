@@ -0,0 +1,90 @@
+package conversation
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// childVoice returns the voice the doll speaks with for child. A custom voice
+// the TTS provider no longer has falls back to the default voice, and the
+// profile is flagged so that the parent can pick another one.
+// The caller must hold the mutex.
+func (c *Conversation) childVoice(ctx context.Context, child *entities.Child) string {
+	if child.VoiceID == "" || c.engine.voices == nil {
+		return child.VoiceID
+	}
+
+	exists, err := c.engine.voices.VoiceExists(ctx, child.VoiceID)
+	if err != nil {
+		// Trust the profile rather than silencing a voice that may be fine
+		c.logger.Warn("Failed to validate child voice",
+			zap.String("childID", child.ID),
+			zap.String("voiceID", child.VoiceID),
+			zap.Error(err))
+		return child.VoiceID
+	}
+	if exists {
+		return child.VoiceID
+	}
+
+	c.logger.Warn("Child voice no longer exists, using the default voice",
+		zap.String("childID", child.ID),
+		zap.String("voiceID", child.VoiceID))
+	if !child.VoiceInvalid {
+		child.VoiceInvalid = true
+		if err := c.engine.childRepo.Update(ctx, child); err != nil {
+			c.logger.Error("Failed to flag child voice for correction",
+				zap.String("childID", child.ID),
+				zap.Error(err))
+		}
+	}
+	return ""
+}
+
+// voiceLanguageCheck remembers whether a voice speaks a language
+type voiceLanguageCheck struct {
+	voiceID   string
+	language  string
+	supported bool
+}
+
+// speakingVoice returns the voice the doll speaks language with: the child's
+// voice, unless Config.VoiceLanguageFallback is set and the TTS provider
+// tells the voice does not speak language. The default voice is used then,
+// which text-to-speech picks for the language. Each voice and language is
+// only checked once in a row.
+// The caller must hold the mutex.
+func (c *Conversation) speakingVoice(ctx context.Context, language string) string {
+	if c.voiceID == "" || language == "" || !c.engine.config.VoiceLanguageFallback || c.engine.voiceLanguages == nil {
+		return c.voiceID
+	}
+
+	check := c.voiceLanguage
+	if check.voiceID != c.voiceID || check.language != language {
+		supported, err := c.engine.voiceLanguages.VoiceSupportsLanguage(ctx, c.voiceID, language)
+		if err != nil {
+			// Trust the profile rather than changing a voice that may be fine
+			c.logger.Warn("Failed to check the language of the child voice",
+				zap.String("childID", c.childID),
+				zap.String("voiceID", c.voiceID),
+				zap.String("language", language),
+				zap.Error(err))
+			return c.voiceID
+		}
+		check = voiceLanguageCheck{voiceID: c.voiceID, language: language, supported: supported}
+		c.voiceLanguage = check
+		if !supported {
+			c.logger.Warn("Child voice does not speak the conversation language, using the default voice",
+				zap.String("childID", c.childID),
+				zap.String("voiceID", c.voiceID),
+				zap.String("language", language))
+		}
+	}
+	if !check.supported {
+		return ""
+	}
+	return c.voiceID
+}
@@ -0,0 +1,62 @@
+package conversation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestEngine_KeepsChildVoiceSpeakingTheLanguage(t *testing.T) {
+	f := newEngineFixture(t, Config{VoiceLanguageFallback: true}, &conversationtest.STT{Transcript: "halo"})
+	f.tts.VoiceLanguages = map[string][]string{"voice-mama": {"id", "en"}}
+	child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}, VoiceID: "voice-mama", ForcedLanguage: "id-ID"}
+	if err := f.children.Create(context.Background(), child); err != nil {
+		t.Fatalf("Failed to create child: %v", err)
+	}
+	sink := newRecorder()
+	conv := f.engine.NewConversation("device-1", sink)
+
+	speak(t, conv, sink)
+	sink.until(t, EventSpeakingEnd)
+
+	if voiceIDs := f.tts.VoiceIDs(); len(voiceIDs) != 1 || voiceIDs[0] != "voice-mama" {
+		t.Errorf("Expected the response in the child's voice, got %v", voiceIDs)
+	}
+}
+
+func TestEngine_FallsBackFromChildVoiceNotSpeakingTheLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		fallback bool
+		want     string
+	}{
+		{"fallback enabled", true, ""},
+		{"fallback disabled", false, "voice-rachel"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newEngineFixture(t, Config{VoiceLanguageFallback: tt.fallback}, &conversationtest.STT{Transcript: "halo"})
+			f.tts.VoiceLanguages = map[string][]string{"voice-rachel": {"en"}}
+			child := &entities.Child{OwnerID: "owner-1", Name: "Kirana", DeviceIDs: []string{"device-1"}, VoiceID: "voice-rachel", ForcedLanguage: "id-ID"}
+			if err := f.children.Create(context.Background(), child); err != nil {
+				t.Fatalf("Failed to create child: %v", err)
+			}
+			sink := newRecorder()
+			conv := f.engine.NewConversation("device-1", sink)
+
+			speak(t, conv, sink)
+			sink.until(t, EventSpeakingEnd)
+
+			if voiceIDs := f.tts.VoiceIDs(); len(voiceIDs) != 1 || voiceIDs[0] != tt.want {
+				t.Errorf("Expected the response in voice %q, got %v", tt.want, voiceIDs)
+			}
+			// The profile keeps its voice for the parent to correct
+			stored, _ := f.children.GetByID(context.Background(), child.ID)
+			if stored.VoiceID != "voice-rachel" || stored.VoiceInvalid {
+				t.Errorf("Expected the profile unchanged, got %+v", stored)
+			}
+		})
+	}
+}
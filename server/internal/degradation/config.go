@@ -0,0 +1,67 @@
+package degradation
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPartialLatency  = 4 * time.Second
+	defaultSevereLatency   = 10 * time.Second
+	defaultPartialFailures = 2
+	defaultSevereFailures  = 5
+)
+
+// DegradationConfig holds configuration for the Controller
+// Optional fields with defaults:
+// - Enabled: Whether conversations degrade while their dependencies are unhealthy (default: false)
+// - PartialLatency: Average latency of a dependency degrading conversations partially (default: 4s)
+// - SevereLatency: Average latency of a dependency degrading conversations severely (default: 10s)
+// - PartialFailures: Failures in a row of a dependency degrading conversations partially (default: 2)
+// - SevereFailures: Failures in a row of a dependency degrading conversations severely (default: 5)
+type DegradationConfig struct {
+	Enabled         bool          // Optional: Whether conversations degrade while their dependencies are unhealthy
+	PartialLatency  time.Duration // Optional: Average latency of a dependency degrading conversations partially
+	SevereLatency   time.Duration // Optional: Average latency of a dependency degrading conversations severely
+	PartialFailures int           // Optional: Failures in a row of a dependency degrading conversations partially
+	SevereFailures  int           // Optional: Failures in a row of a dependency degrading conversations severely
+}
+
+// NewDegradationConfigFromEnv creates a new DegradationConfig from environment variables
+// This is a helper function to simplify the creation of a properly configured DegradationConfig
+func NewDegradationConfigFromEnv() DegradationConfig {
+	config := DegradationConfig{}
+
+	if enabledStr := os.Getenv("DEGRADATION_ENABLED"); enabledStr != "" {
+		if enabled, err := strconv.ParseBool(enabledStr); err == nil {
+			config.Enabled = enabled
+		}
+	}
+
+	if latencyStr := os.Getenv("DEGRADATION_PARTIAL_LATENCY_MS"); latencyStr != "" {
+		if latency, err := strconv.Atoi(latencyStr); err == nil && latency > 0 {
+			config.PartialLatency = time.Duration(latency) * time.Millisecond
+		}
+	}
+
+	if latencyStr := os.Getenv("DEGRADATION_SEVERE_LATENCY_MS"); latencyStr != "" {
+		if latency, err := strconv.Atoi(latencyStr); err == nil && latency > 0 {
+			config.SevereLatency = time.Duration(latency) * time.Millisecond
+		}
+	}
+
+	if failuresStr := os.Getenv("DEGRADATION_PARTIAL_FAILURES"); failuresStr != "" {
+		if failures, err := strconv.Atoi(failuresStr); err == nil && failures > 0 {
+			config.PartialFailures = failures
+		}
+	}
+
+	if failuresStr := os.Getenv("DEGRADATION_SEVERE_FAILURES"); failuresStr != "" {
+		if failures, err := strconv.Atoi(failuresStr); err == nil && failures > 0 {
+			config.SevereFailures = failures
+		}
+	}
+
+	return config
+}
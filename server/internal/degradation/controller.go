@@ -0,0 +1,175 @@
+package degradation
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+// The controller watches how the dependencies of conversations behave, from
+// the calls conversations make to them. A dependency that keeps failing or
+// whose average latency climbs past a threshold degrades every conversation:
+// partially, answering with a cheaper model and skipping optional steps, or
+// severely, also giving up on responses sooner. Conversations recover on
+// their own once the dependency answers quickly again.
+
+// latencyWeight is the weight of the last call in the average latency of a
+// dependency
+const latencyWeight = 0.25
+
+// degradationLevel is the current degradation level, for monitoring
+var degradationLevel = expvar.NewInt("degradation_level")
+
+// Controller derives the degradation level of conversations from the health
+// of their dependencies
+type Controller struct {
+	partialLatency  time.Duration
+	severeLatency   time.Duration
+	partialFailures int
+	severeFailures  int
+
+	mu           sync.Mutex
+	dependencies map[string]*dependencyHealth
+	level        repositories.DegradationLevel
+
+	logger *zap.Logger
+}
+
+// dependencyHealth is how a dependency behaved lately
+type dependencyHealth struct {
+	// Moving average of the latency of the successful calls
+	latency  time.Duration
+	observed bool
+	// Failed calls since the last successful one
+	failures int
+}
+
+// ValidateDegradationConfig validates the DegradationConfig
+func ValidateDegradationConfig(config DegradationConfig) error {
+	if config.PartialLatency < 0 || config.SevereLatency < 0 {
+		return fmt.Errorf("degradation latencies must be positive")
+	}
+	if config.PartialFailures < 0 || config.SevereFailures < 0 {
+		return fmt.Errorf("degradation failures must be positive")
+	}
+	if config.PartialLatency > 0 && config.SevereLatency > 0 && config.SevereLatency < config.PartialLatency {
+		return fmt.Errorf("severe degradation latency %s is below the partial one %s", config.SevereLatency, config.PartialLatency)
+	}
+	if config.PartialFailures > 0 && config.SevereFailures > 0 && config.SevereFailures < config.PartialFailures {
+		return fmt.Errorf("severe degradation failures %d are below the partial ones %d", config.SevereFailures, config.PartialFailures)
+	}
+	return nil
+}
+
+// NewController creates a new degradation controller
+func NewController(config DegradationConfig, logger *zap.Logger) (*Controller, error) {
+	if err := ValidateDegradationConfig(config); err != nil {
+		return nil, err
+	}
+
+	logger = logger.Named("degradation")
+
+	// Apply defaults where needed
+	partialLatency := config.PartialLatency
+	if partialLatency == 0 {
+		partialLatency = defaultPartialLatency
+		logger.Info("Using default partial degradation latency", zap.Duration("partialLatency", partialLatency))
+	}
+
+	severeLatency := config.SevereLatency
+	if severeLatency == 0 {
+		severeLatency = max(defaultSevereLatency, partialLatency)
+		logger.Info("Using default severe degradation latency", zap.Duration("severeLatency", severeLatency))
+	}
+
+	partialFailures := config.PartialFailures
+	if partialFailures == 0 {
+		partialFailures = defaultPartialFailures
+		logger.Info("Using default partial degradation failures", zap.Int("partialFailures", partialFailures))
+	}
+
+	severeFailures := config.SevereFailures
+	if severeFailures == 0 {
+		severeFailures = max(defaultSevereFailures, partialFailures)
+		logger.Info("Using default severe degradation failures", zap.Int("severeFailures", severeFailures))
+	}
+
+	return &Controller{
+		partialLatency:  partialLatency,
+		severeLatency:   severeLatency,
+		partialFailures: partialFailures,
+		severeFailures:  severeFailures,
+		dependencies:    make(map[string]*dependencyHealth),
+		logger:          logger,
+	}, nil
+}
+
+// Observe records a call to dependency that took latency and failed with
+// err, if not nil
+func (c *Controller) Observe(dependency string, latency time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	health, ok := c.dependencies[dependency]
+	if !ok {
+		health = &dependencyHealth{}
+		c.dependencies[dependency] = health
+	}
+	if err != nil {
+		health.failures++
+	} else {
+		health.failures = 0
+		if health.observed {
+			health.latency += time.Duration(latencyWeight * float64(latency-health.latency))
+		} else {
+			health.latency, health.observed = latency, true
+		}
+	}
+
+	level := repositories.DegradationNone
+	for _, health := range c.dependencies {
+		level = max(level, c.levelOf(health))
+	}
+	if level == c.level {
+		return
+	}
+
+	fields := []zap.Field{
+		zap.String("level", level.String()),
+		zap.String("previous", c.level.String()),
+		zap.String("dependency", dependency),
+		zap.Duration("latency", health.latency),
+		zap.Int("failures", health.failures),
+	}
+	if level > c.level {
+		c.logger.Warn("Degrading conversations", fields...)
+	} else {
+		c.logger.Info("Conversations recovering from degradation", fields...)
+	}
+	c.level = level
+	degradationLevel.Set(int64(level))
+}
+
+// Level returns the degradation level of conversations
+func (c *Controller) Level() repositories.DegradationLevel {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.level
+}
+
+// levelOf returns the degradation level a dependency behaving as health
+// calls for
+func (c *Controller) levelOf(health *dependencyHealth) repositories.DegradationLevel {
+	switch {
+	case health.failures >= c.severeFailures, health.observed && health.latency >= c.severeLatency:
+		return repositories.DegradationSevere
+	case health.failures >= c.partialFailures, health.observed && health.latency >= c.partialLatency:
+		return repositories.DegradationPartial
+	}
+	return repositories.DegradationNone
+}
@@ -0,0 +1,98 @@
+package degradation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+func newTestController(t *testing.T) *Controller {
+	t.Helper()
+	controller, err := NewController(DegradationConfig{
+		PartialLatency:  time.Second,
+		SevereLatency:   3 * time.Second,
+		PartialFailures: 2,
+		SevereFailures:  4,
+	}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create controller: %v", err)
+	}
+	return controller
+}
+
+func TestController_DegradesOnFailures(t *testing.T) {
+	controller := newTestController(t)
+	failure := errors.New("unavailable")
+
+	want := []repositories.DegradationLevel{
+		repositories.DegradationNone,
+		repositories.DegradationPartial,
+		repositories.DegradationPartial,
+		repositories.DegradationSevere,
+	}
+	for i, level := range want {
+		controller.Observe("llm", 100*time.Millisecond, failure)
+		if got := controller.Level(); got != level {
+			t.Fatalf("Expected level %s after %d failures, got %s", level, i+1, got)
+		}
+	}
+
+	controller.Observe("llm", 100*time.Millisecond, nil)
+	if got := controller.Level(); got != repositories.DegradationNone {
+		t.Errorf("Expected a successful call to recover, got %s", got)
+	}
+}
+
+func TestController_DegradesOnLatency(t *testing.T) {
+	controller := newTestController(t)
+
+	controller.Observe("tts", 200*time.Millisecond, nil)
+	controller.Observe("llm", 1500*time.Millisecond, nil)
+	if got := controller.Level(); got != repositories.DegradationPartial {
+		t.Fatalf("Expected a slow dependency to degrade partially, got %s", got)
+	}
+
+	// The average latency recovers gradually
+	controller.Observe("llm", 100*time.Millisecond, nil)
+	if got := controller.Level(); got != repositories.DegradationPartial {
+		t.Errorf("Expected one fast call not to recover yet, got %s", got)
+	}
+	for range 3 {
+		controller.Observe("llm", 100*time.Millisecond, nil)
+	}
+	if got := controller.Level(); got != repositories.DegradationNone {
+		t.Errorf("Expected fast calls to recover, got %s", got)
+	}
+
+	controller.Observe("tts", 20*time.Second, nil)
+	if got := controller.Level(); got != repositories.DegradationSevere {
+		t.Errorf("Expected a very slow dependency to degrade severely, got %s", got)
+	}
+}
+
+func TestNewController_KeepsDefaultsAbovePartialThresholds(t *testing.T) {
+	controller, err := NewController(DegradationConfig{PartialLatency: 20 * time.Second, PartialFailures: 8}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create controller: %v", err)
+	}
+	if controller.severeLatency != 20*time.Second || controller.severeFailures != 8 {
+		t.Errorf("Expected the severe thresholds to follow the partial ones, got %s and %d", controller.severeLatency, controller.severeFailures)
+	}
+}
+
+func TestNewController_RejectsInvertedThresholds(t *testing.T) {
+	configs := []DegradationConfig{
+		{PartialLatency: 5 * time.Second, SevereLatency: time.Second},
+		{PartialFailures: 5, SevereFailures: 2},
+		{PartialFailures: -1},
+	}
+	for _, config := range configs {
+		if _, err := NewController(config, zaptest.NewLogger(t)); err == nil {
+			t.Errorf("Expected %+v to be rejected", config)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package events provides an in-process bus for session and device lifecycle
+// events, and for conversation events flagged for parental review, consumed
+// by analytics, the parent app and webhooks.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Type identifies a lifecycle event
+type Type string
+
+const (
+	// SessionStarted is published when a new conversation session is created
+	SessionStarted Type = "session_started"
+	// SessionEnded is published when a session can no longer be continued
+	SessionEnded Type = "session_ended"
+	// DeviceOnline is published when a device connects while it was offline
+	DeviceOnline Type = "device_online"
+	// DeviceOffline is published when the last connection of a device closes
+	DeviceOffline Type = "device_offline"
+	// TopicDetected is published when the child or the doll touches a topic
+	// the parent discouraged, for parental review
+	TopicDetected Type = "topic_detected"
+)
+
+// End reasons carried by SessionEnded events
+const (
+	// ReasonIdle means the session exceeded the idle window and was replaced
+	ReasonIdle = "idle"
+	// ReasonErased means the conversation data was erased by the owner
+	ReasonErased = "erased"
+)
+
+// Disconnect reasons carried by DeviceOffline events, besides the close
+// reasons the server sends when it closes the connection itself
+const (
+	// ReasonClean means the device closed the connection normally
+	ReasonClean = "clean"
+	// ReasonAbnormal means the connection dropped without a normal close,
+	// such as after a network loss or missed pongs
+	ReasonAbnormal = "abnormal"
+)
+
+// Sources carried by TopicDetected events
+const (
+	// ReasonChildInput means the topic came up in what the child said
+	ReasonChildInput = "child_input"
+	// ReasonModelResponse means the topic came up in the doll's response
+	ReasonModelResponse = "model_response"
+)
+
+// Event is a single lifecycle event
+type Event struct {
+	Type      Type   `json:"type"`
+	DeviceID  string `json:"device_id"`
+	ChildID   string `json:"child_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	// CloseCode is the WebSocket close code of a DeviceOffline event, 0 when
+	// the connection dropped without a close frame
+	CloseCode int `json:"close_code,omitempty"`
+	// Topic is the discouraged topic of a TopicDetected event
+	Topic     string    `json:"topic,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Publisher publishes lifecycle events
+type Publisher interface {
+	Publish(event Event)
+}
+
+const defaultBufferSize = 64
+
+// Bus fans out published events to its subscribers without ever blocking
+// the publisher. A subscriber that falls behind loses events once its
+// buffer is full.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	logger      *zap.Logger
+}
+
+// Ensure Bus implements the Publisher interface
+var _ Publisher = (*Bus)(nil)
+
+// NewBus creates a new event bus
+func NewBus(logger *zap.Logger) *Bus {
+	return &Bus{
+		subscribers: make(map[*Subscription]struct{}),
+		logger:      logger.Named("events"),
+	}
+}
+
+// Subscription receives the events published after it was created
+type Subscription struct {
+	bus    *Bus
+	events chan Event
+
+	mu      sync.Mutex
+	dropped int
+}
+
+// Events returns the channel delivering events. It is closed by Unsubscribe.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped returns how many events were lost because the buffer was full
+func (s *Subscription) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Unsubscribe stops delivery and closes the events channel
+func (s *Subscription) Unsubscribe() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subscribers[s]; ok {
+		delete(s.bus.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Subscribe registers a subscriber buffering up to bufferSize events.
+// A bufferSize of 0 uses the default of 64.
+func (b *Bus) Subscribe(bufferSize int) *Subscription {
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+
+	sub := &Subscription{
+		bus:    b,
+		events: make(chan Event, bufferSize),
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Publish implements Publisher
+func (b *Bus) Publish(event Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			sub.mu.Lock()
+			sub.dropped++
+			sub.mu.Unlock()
+			b.logger.Warn("Dropping lifecycle event for slow subscriber",
+				zap.String("type", string(event.Type)),
+				zap.String("sessionID", event.SessionID))
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package events
+
+import (
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestBus_DeliversToEverySubscriber(t *testing.T) {
+	bus := NewBus(zaptest.NewLogger(t))
+	first := bus.Subscribe(1)
+	second := bus.Subscribe(1)
+
+	bus.Publish(Event{Type: SessionStarted, DeviceID: "device-1", SessionID: "session-1"})
+
+	for _, sub := range []*Subscription{first, second} {
+		event := <-sub.Events()
+		if event.Type != SessionStarted || event.SessionID != "session-1" {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+		if event.Timestamp.IsZero() {
+			t.Error("Expected the event to be timestamped")
+		}
+	}
+}
+
+func TestBus_DropsWhenSubscriberIsFull(t *testing.T) {
+	bus := NewBus(zaptest.NewLogger(t))
+	sub := bus.Subscribe(1)
+
+	// Must not block even though nobody reads
+	bus.Publish(Event{Type: SessionStarted, SessionID: "session-1"})
+	bus.Publish(Event{Type: SessionEnded, SessionID: "session-1"})
+
+	if sub.Dropped() != 1 {
+		t.Errorf("Expected 1 dropped event, got %d", sub.Dropped())
+	}
+	if event := <-sub.Events(); event.Type != SessionStarted {
+		t.Errorf("Expected the first event to be kept, got %+v", event)
+	}
+}
+
+func TestBus_Unsubscribe(t *testing.T) {
+	bus := NewBus(zaptest.NewLogger(t))
+	sub := bus.Subscribe(1)
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+
+	bus.Publish(Event{Type: SessionStarted, SessionID: "session-1"})
+
+	if _, ok := <-sub.Events(); ok {
+		t.Error("Expected the events channel to be closed")
+	}
+}
@@ -0,0 +1,37 @@
+package grpcserver
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// ConverseClient is the device side of a Converse stream
+type ConverseClient struct {
+	grpc.ClientStream
+}
+
+// Converse opens a Converse stream on the connection. The device JWT must be
+// attached as "authorization: Bearer <token>" outgoing metadata on ctx.
+func Converse(ctx context.Context, cc grpc.ClientConnInterface, opts ...grpc.CallOption) (*ConverseClient, error) {
+	opts = append([]grpc.CallOption{grpc.CallContentSubtype(codecName)}, opts...)
+	stream, err := cc.NewStream(ctx, &ServiceDesc.Streams[0], ConverseMethod, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ConverseClient{ClientStream: stream}, nil
+}
+
+// Send sends a request to the server
+func (c *ConverseClient) Send(req *ConverseRequest) error {
+	return c.ClientStream.SendMsg(req)
+}
+
+// Recv receives the next conversation event
+func (c *ConverseClient) Recv() (*ConverseEvent, error) {
+	event := new(ConverseEvent)
+	if err := c.ClientStream.RecvMsg(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
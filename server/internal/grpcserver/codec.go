@@ -0,0 +1,34 @@
+package grpcserver
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// codecName is the gRPC content subtype of the conversation service.
+// Clients select it with grpc.CallContentSubtype(codecName).
+const codecName = "json"
+
+// jsonCodec marshals the conversation messages as JSON so that the service
+// can be used without generated protobuf code
+type jsonCodec struct{}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// Marshal implements encoding.Codec
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal implements encoding.Codec
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// Name implements encoding.Codec
+func (jsonCodec) Name() string {
+	return codecName
+}
@@ -0,0 +1,36 @@
+package grpcserver
+
+import (
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// Control messages accepted in ConverseRequest.Control
+const (
+	ControlListeningStart = "listening_start"
+	ControlListeningEnd   = "listening_end"
+)
+
+// ConverseRequest is a single message sent by the device.
+// It carries either a control message or a chunk of audio.
+type ConverseRequest struct {
+	Control string `json:"control,omitempty"`
+
+	// Audio configuration, only read with ControlListeningStart
+	SampleRate int    `json:"sample_rate,omitempty"`
+	Language   string `json:"language,omitempty"`
+	Encoding   string `json:"encoding,omitempty"`
+
+	// Audio is a chunk of the child's audio
+	Audio []byte `json:"audio,omitempty"`
+}
+
+// ConverseEvent is a single conversation event sent to the device.
+// Type matches the conversation.EventType values.
+type ConverseEvent struct {
+	Type      string            `json:"type"`
+	SessionID string            `json:"session_id,omitempty"`
+	Timestamp int64             `json:"timestamp"`
+	Message   *entities.Message `json:"message,omitempty"`
+	Audio     []byte            `json:"audio,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
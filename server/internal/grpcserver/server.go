@@ -0,0 +1,214 @@
+// Package grpcserver exposes the conversation engine as a gRPC
+// bidirectional stream, as an alternative to the WebSocket transport.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/satriahrh/arunika/server/internal/auth"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+)
+
+const (
+	// ServiceName is the fully qualified name of the conversation service
+	ServiceName = "arunika.conversation.v1.Conversation"
+
+	// ConverseMethod is the full method name of the Converse stream
+	ConverseMethod = "/" + ServiceName + "/Converse"
+)
+
+// conversationService is the handler type checked by grpc.ServiceDesc
+type conversationService interface {
+	converse(stream grpc.ServerStream) error
+}
+
+// ServiceDesc describes the conversation service. Messages are encoded with
+// the JSON codec registered by this package.
+var ServiceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*conversationService)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Converse",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(conversationService).converse(stream)
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "arunika/conversation.proto",
+}
+
+// Server drives conversations received over gRPC through the engine
+type Server struct {
+	engine *conversation.Engine
+	logger *zap.Logger
+}
+
+// NewServer creates a new gRPC conversation server
+func NewServer(engine *conversation.Engine, logger *zap.Logger) *Server {
+	return &Server{
+		engine: engine,
+		logger: logger,
+	}
+}
+
+// Register registers the conversation service on a gRPC server
+func (s *Server) Register(registrar grpc.ServiceRegistrar) {
+	registrar.RegisterService(&ServiceDesc, s)
+}
+
+// converse handles a single Converse stream. The device keeps its send side
+// open for as long as it wants to receive events; closing it ends the call.
+func (s *Server) converse(stream grpc.ServerStream) error {
+	deviceID, err := authenticate(stream.Context())
+	if err != nil {
+		s.logger.Warn("gRPC conversation rejected", zap.Error(err))
+		return err
+	}
+
+	sink := newStreamSink(stream, s.logger)
+	go sink.sendLoop()
+	defer sink.close()
+
+	conv := s.engine.NewConversation(deviceID, sink)
+	// Releases the speech-to-text stream and the response in progress
+	// however the stream ends. It runs before the sink closes, while the
+	// events emitted meanwhile are still drained.
+	defer conv.Close()
+
+	s.logger.Info("gRPC conversation started", zap.String("deviceID", deviceID))
+
+	for {
+		var req ConverseRequest
+		if err := stream.RecvMsg(&req); err != nil {
+			if errors.Is(err, io.EOF) {
+				s.logger.Info("gRPC conversation ended", zap.String("deviceID", deviceID))
+				return nil
+			}
+			s.logger.Warn("gRPC conversation receive failed",
+				zap.String("deviceID", deviceID),
+				zap.Error(err))
+			return err
+		}
+
+		switch {
+		case len(req.Audio) > 0:
+			conv.StreamAudio(req.Audio)
+		case req.Control == ControlListeningStart:
+			conv.StartListening(conversation.StartOptions{
+				SampleRate: req.SampleRate,
+				Language:   req.Language,
+				Encoding:   req.Encoding,
+			})
+		case req.Control == ControlListeningEnd:
+			conv.EndListening()
+		default:
+			s.logger.Warn("Unknown gRPC control message",
+				zap.String("deviceID", deviceID),
+				zap.String("control", req.Control))
+		}
+	}
+}
+
+// authenticate validates the device JWT sent as "authorization: Bearer <token>"
+// metadata and returns the device ID
+func authenticate(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var token string
+	for _, value := range md.Get("authorization") {
+		if strings.HasPrefix(value, "Bearer ") {
+			token = strings.TrimPrefix(value, "Bearer ")
+			break
+		}
+	}
+	if token == "" {
+		return "", status.Error(codes.Unauthenticated, "missing_token")
+	}
+
+	claims, err := auth.ValidateToken(token)
+	if err != nil {
+		return "", status.Error(codes.Unauthenticated, "invalid_token")
+	}
+
+	if claims.Role != "device" {
+		return "", status.Error(codes.PermissionDenied, "invalid_role")
+	}
+
+	return claims.DeviceID, nil
+}
+
+// streamSink implements conversation.EventSink by queueing events for a
+// single goroutine, since a gRPC stream does not allow concurrent sends
+type streamSink struct {
+	stream grpc.ServerStream
+	events chan ConverseEvent
+	done   chan struct{}
+	exited chan struct{}
+	once   sync.Once
+	logger *zap.Logger
+}
+
+func newStreamSink(stream grpc.ServerStream, logger *zap.Logger) *streamSink {
+	return &streamSink{
+		stream: stream,
+		events: make(chan ConverseEvent, 256),
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+		logger: logger,
+	}
+}
+
+// Emit implements conversation.EventSink. Events emitted after the stream
+// ended or failed are dropped.
+func (s *streamSink) Emit(event conversation.Event) {
+	select {
+	case s.events <- ConverseEvent{
+		Type:      string(event.Type),
+		SessionID: event.SessionID,
+		Timestamp: event.Timestamp.Unix(),
+		Message:   event.Message,
+		Audio:     event.Audio,
+		Error:     event.Error,
+	}:
+	case <-s.done:
+	case <-s.exited:
+	}
+}
+
+// sendLoop writes queued events to the stream until the sink is closed or a
+// send fails
+func (s *streamSink) sendLoop() {
+	defer close(s.exited)
+	for {
+		select {
+		case event := <-s.events:
+			if err := s.stream.SendMsg(&event); err != nil {
+				s.logger.Warn("Failed to send gRPC conversation event", zap.Error(err))
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// close stops the send loop and waits for it to exit so that the stream is
+// never written after its handler returned
+func (s *streamSink) close() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+	<-s.exited
+}
@@ -0,0 +1,225 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/internal/auth"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// newTestConn serves the conversation service over an in-memory listener
+func newTestConn(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	return newTestConnWithSTT(t, &conversationtest.STT{Transcript: "halo boneka"})
+}
+
+// newTestConnWithSTT serves the conversation service transcribing with stt
+func newTestConnWithSTT(t *testing.T, stt *conversationtest.STT) *grpc.ClientConn {
+	t.Helper()
+	logger := zaptest.NewLogger(t)
+	engine, err := conversation.NewEngine(
+		conversation.Config{},
+		&conversationtest.LLM{Reply: "Halo juga!"},
+		&conversationtest.TTS{Chunks: [][]byte{{0xAA}, {0xBB}}},
+		stt,
+		&conversationtest.SessionRepository{},
+		adapters.NewMemoryChildRepository(),
+		adapters.NewMemoryQuietHoursRepository(),
+		events.NewBus(logger),
+		logger,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	NewServer(engine, logger).Register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial test server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// withToken attaches a bearer token to the outgoing metadata
+func withToken(t *testing.T, token string) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func TestConverse_FullTurn(t *testing.T) {
+	conn := newTestConn(t)
+	token, err := auth.GenerateDeviceToken("device-1")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	stream, err := Converse(withToken(t, token), conn)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+
+	requests := []*ConverseRequest{
+		{Control: ControlListeningStart, SampleRate: 16000},
+		{Audio: []byte{0x01, 0x02}},
+		{Control: ControlListeningEnd},
+	}
+	for _, req := range requests {
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+	}
+
+	var types []string
+	var transcription, response string
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Failed to receive event after %v: %v", types, err)
+		}
+		if event.Error != "" {
+			t.Fatalf("Unexpected %s error: %s", event.Type, event.Error)
+		}
+		types = append(types, event.Type)
+		switch event.Type {
+		case string(conversation.EventListeningEnd):
+			transcription = event.Message.Content
+		case string(conversation.EventSpeakingStart):
+			response = event.Message.Content
+		}
+		if event.Type == string(conversation.EventSpeakingEnd) {
+			break
+		}
+	}
+	stream.CloseSend()
+
+	want := []string{"listening_start", "listening_end", "thinking", "speaking_start", "audio", "audio", "speaking_end"}
+	if len(types) != len(want) {
+		t.Fatalf("Expected events %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("Expected events %v, got %v", want, types)
+		}
+	}
+	if transcription != "halo boneka" || response != "Halo juga!" {
+		t.Errorf("Unexpected turn %q -> %q", transcription, response)
+	}
+}
+
+func TestConverse_RequiresDeviceToken(t *testing.T) {
+	conn := newTestConn(t)
+	userToken, err := auth.GenerateUserToken("user-1")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		code  codes.Code
+	}{
+		{"invalid token", "not-a-jwt", codes.Unauthenticated},
+		{"user token", userToken, codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := Converse(withToken(t, tt.token), conn)
+			if err != nil {
+				t.Fatalf("Failed to open stream: %v", err)
+			}
+			_, err = stream.Recv()
+			if status.Code(err) != tt.code {
+				t.Errorf("Expected %v, got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+// A stream cancelled mid-utterance releases its speech-to-text stream
+func TestConverse_CancelReleasesSTTStream(t *testing.T) {
+	stt := &conversationtest.STT{Transcript: "halo boneka"}
+	conn := newTestConnWithSTT(t, stt)
+	token, err := auth.GenerateDeviceToken("device-1")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(withToken(t, token))
+	stream, err := Converse(ctx, conn)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	if err := stream.Send(&ConverseRequest{Control: ControlListeningStart, SampleRate: 16000}); err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	if event, err := stream.Recv(); err != nil || event.Type != string(conversation.EventListeningStart) {
+		t.Fatalf("Expected listening_start, got %v, %v", event, err)
+	}
+
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(stt.Streams()) == 0 || !stt.Streams()[0].Released() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the speech-to-text stream closed when the gRPC stream was cancelled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// failingStream is a server stream whose sends fail
+type failingStream struct {
+	grpc.ServerStream
+}
+
+func (failingStream) SendMsg(interface{}) error {
+	return status.Error(codes.Unavailable, "connection lost")
+}
+
+// Once a send failed the sink drops events instead of blocking the
+// conversation when its buffer fills
+func TestStreamSink_DropsEventsAfterSendFailure(t *testing.T) {
+	sink := newStreamSink(failingStream{}, zaptest.NewLogger(t))
+	go sink.sendLoop()
+	defer sink.close()
+
+	emitted := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			sink.Emit(conversation.Event{Type: conversation.EventAudio})
+		}
+		close(emitted)
+	}()
+	select {
+	case <-emitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Emit blocked after the send loop failed")
+	}
+}
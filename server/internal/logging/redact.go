@@ -0,0 +1,146 @@
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ContentMode sets how conversational content appears in logs
+type ContentMode string
+
+const (
+	// ContentHashed replaces content with its length and a short hash, so
+	// that repeated content can be recognized without being readable
+	ContentHashed ContentMode = "hash"
+	// ContentTruncated keeps the first characters of content
+	ContentTruncated ContentMode = "truncate"
+	// ContentFull keeps content as is, for debugging only
+	ContentFull ContentMode = "full"
+)
+
+const defaultTruncateLength = 12
+
+// contentKeys are the fields that carry what the child said or the doll
+// answered
+var contentKeys = map[string]bool{
+	"transcription":    true,
+	"transcript":       true,
+	"text":             true,
+	"user_message":     true,
+	"response":         true,
+	"response_preview": true,
+}
+
+// RedactionConfig holds the configuration of log redaction
+//
+// Environment Variables:
+// - LOG_CONTENT: How transcriptions and responses are logged, "hash", "truncate" or "full" (default: hash)
+// - LOG_CONTENT_TRUNCATE_LENGTH: Characters of content kept with LOG_CONTENT=truncate (default: 12)
+type RedactionConfig struct {
+	Content        ContentMode // Optional: How transcriptions and responses are logged
+	TruncateLength int         // Optional: Characters of content kept when truncating
+}
+
+// NewRedactionConfigFromEnv creates a RedactionConfig from environment variables
+func NewRedactionConfigFromEnv() RedactionConfig {
+	config := RedactionConfig{
+		Content: ContentMode(os.Getenv("LOG_CONTENT")),
+	}
+
+	if lengthStr := os.Getenv("LOG_CONTENT_TRUNCATE_LENGTH"); lengthStr != "" {
+		if length, err := strconv.Atoi(lengthStr); err == nil {
+			config.TruncateLength = length
+		}
+	}
+
+	return config
+}
+
+// Redact returns logger with the conversational content of its entries
+// masked as config sets. Content is kept in full only with ContentFull.
+func Redact(logger *zap.Logger, config RedactionConfig) *zap.Logger {
+	switch config.Content {
+	case ContentHashed, ContentTruncated, ContentFull:
+	default:
+		if config.Content != "" {
+			logger.Warn("Unknown log content mode, hashing content", zap.String("mode", string(config.Content)))
+		}
+		config.Content = ContentHashed
+		logger.Info("Using default log content mode", zap.String("mode", string(config.Content)))
+	}
+	if config.Content == ContentFull {
+		logger.Warn("Logging transcriptions and responses in full")
+		return logger
+	}
+	if config.TruncateLength <= 0 {
+		config.TruncateLength = defaultTruncateLength
+		if config.Content == ContentTruncated {
+			logger.Info("Using default log content truncate length", zap.Int("length", config.TruncateLength))
+		}
+	}
+
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &redactingCore{Core: core, config: config}
+	}))
+}
+
+// redactingCore masks the content fields of the entries written to Core
+type redactingCore struct {
+	zapcore.Core
+	config RedactionConfig
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(c.redact(fields)), config: c.config}
+}
+
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, c.redact(fields))
+}
+
+// redact returns fields with the content ones masked, leaving fields as is
+func (c *redactingCore) redact(fields []zapcore.Field) []zapcore.Field {
+	var redacted []zapcore.Field
+	for i, field := range fields {
+		if field.Type != zapcore.StringType || !contentKeys[field.Key] {
+			continue
+		}
+		if redacted == nil {
+			redacted = append([]zapcore.Field(nil), fields...)
+		}
+		redacted[i].String = c.mask(field.String)
+	}
+	if redacted == nil {
+		return fields
+	}
+	return redacted
+}
+
+// mask returns how content is logged
+func (c *redactingCore) mask(content string) string {
+	if content == "" {
+		return ""
+	}
+	if c.config.Content == ContentTruncated {
+		runes := []rune(content)
+		if len(runes) <= c.config.TruncateLength {
+			return content
+		}
+		return string(runes[:c.config.TruncateLength]) + "…"
+	}
+	sum := sha256.Sum256([]byte(content))
+	return fmt.Sprintf("[redacted len=%d sha256:%s]", len([]rune(content)), hex.EncodeToString(sum[:])[:8])
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// logTurn logs a transcription and a response through a logger redacting as
+// config sets, and returns the fields of the entry
+func logTurn(t *testing.T, config RedactionConfig) map[string]interface{} {
+	t.Helper()
+	core, logs := observer.New(zap.InfoLevel)
+	logger := Redact(zap.New(core), config)
+
+	logger.With(zap.String("transcription", "Namaku Sari, rumahku dekat sekolah")).
+		Info("Turn completed",
+			zap.String("deviceID", "device-1"),
+			zap.String("response", "Halo Sari! Senang bertemu kamu."))
+
+	entries := logs.FilterMessage("Turn completed").All()
+	if len(entries) != 1 {
+		t.Fatalf("Expected one turn entry, got %d", len(entries))
+	}
+	return entries[0].ContextMap()
+}
+
+func TestRedact_MasksContent(t *testing.T) {
+	for _, config := range []RedactionConfig{{}, {Content: ContentHashed}, {Content: "bogus"}} {
+		fields := logTurn(t, config)
+		for _, key := range []string{"transcription", "response"} {
+			value := fields[key].(string)
+			if strings.Contains(value, "Sari") || !strings.HasPrefix(value, "[redacted len=") {
+				t.Errorf("Mode %q: expected %s to be hashed, got %q", config.Content, key, value)
+			}
+		}
+		if fields["deviceID"] != "device-1" {
+			t.Errorf("Mode %q: expected other fields to be kept, got %v", config.Content, fields["deviceID"])
+		}
+	}
+
+	first, second := logTurn(t, RedactionConfig{}), logTurn(t, RedactionConfig{})
+	if first["response"] != second["response"] {
+		t.Errorf("Expected the same content to hash alike, got %q and %q", first["response"], second["response"])
+	}
+}
+
+func TestRedact_TruncatesContent(t *testing.T) {
+	fields := logTurn(t, RedactionConfig{Content: ContentTruncated, TruncateLength: 5})
+	if fields["response"] != "Halo …" {
+		t.Errorf("Expected the response truncated, got %q", fields["response"])
+	}
+	if fields["transcription"] != "Namak…" {
+		t.Errorf("Expected the transcription truncated, got %q", fields["transcription"])
+	}
+}
+
+func TestRedact_KeepsContentInDebugMode(t *testing.T) {
+	fields := logTurn(t, RedactionConfig{Content: ContentFull})
+	if fields["transcription"] != "Namaku Sari, rumahku dekat sekolah" || fields["response"] != "Halo Sari! Senang bertemu kamu." {
+		t.Errorf("Expected content in full, got %v", fields)
+	}
+}
@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxAttempts    = 5
+	defaultInitialBackoff = 500 * time.Millisecond
+	defaultTimeout        = 10 * time.Second
+	defaultMaxInFlight    = 16
+	defaultQueueSize      = 1000
+)
+
+// WebhookConfig holds configuration for the WebhookDispatcher
+// Required fields when URLs is not empty:
+// - Secret: Shared secret used to sign every delivery
+// Optional fields with defaults:
+// - URLs: Endpoints receiving every event (default: none, webhooks disabled)
+// - MaxAttempts: Delivery attempts before an event is dead-lettered (default: 5)
+// - InitialBackoff: Wait before the first retry, doubled on every retry (default: 500ms)
+// - Timeout: Timeout of a single delivery attempt (default: 10s)
+// - QueueSize: Deliveries waiting for an attempt before more are dead-lettered (default: 1000)
+type WebhookConfig struct {
+	URLs           []string      // Optional: Endpoints receiving every event
+	Secret         string        // Required: Shared secret used to sign every delivery
+	MaxAttempts    int           // Optional: Delivery attempts before an event is dead-lettered
+	InitialBackoff time.Duration // Optional: Wait before the first retry, doubled on every retry
+	Timeout        time.Duration // Optional: Timeout of a single delivery attempt
+	QueueSize      int           // Optional: Deliveries waiting for an attempt before more are dead-lettered
+}
+
+// NewWebhookConfigFromEnv creates a new WebhookConfig from environment variables
+// This is a helper function to simplify the creation of a properly configured WebhookConfig
+func NewWebhookConfigFromEnv() WebhookConfig {
+	config := WebhookConfig{
+		Secret: os.Getenv("WEBHOOK_SECRET"),
+	}
+
+	for _, url := range strings.Split(os.Getenv("WEBHOOK_URLS"), ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			config.URLs = append(config.URLs, url)
+		}
+	}
+
+	if attemptsStr := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); attemptsStr != "" {
+		if attempts, err := strconv.Atoi(attemptsStr); err == nil && attempts > 0 {
+			config.MaxAttempts = attempts
+		}
+	}
+
+	if backoffStr := os.Getenv("WEBHOOK_INITIAL_BACKOFF_MS"); backoffStr != "" {
+		if backoff, err := strconv.Atoi(backoffStr); err == nil && backoff > 0 {
+			config.InitialBackoff = time.Duration(backoff) * time.Millisecond
+		}
+	}
+
+	if timeoutStr := os.Getenv("WEBHOOK_TIMEOUT_MS"); timeoutStr != "" {
+		if timeout, err := strconv.Atoi(timeoutStr); err == nil && timeout > 0 {
+			config.Timeout = time.Duration(timeout) * time.Millisecond
+		}
+	}
+
+	if sizeStr := os.Getenv("WEBHOOK_QUEUE_SIZE"); sizeStr != "" {
+		if size, err := strconv.Atoi(sizeStr); err == nil && size > 0 {
+			config.QueueSize = size
+		}
+	}
+
+	return config
+}
@@ -0,0 +1,340 @@
+// Package webhook delivers event bus events to integrator HTTP endpoints.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// Headers sent with every delivery. Receivers verify the signature by
+// computing hex(HMAC-SHA256(secret, timestamp + "." + body)).
+const (
+	HeaderSignature = "X-Arunika-Signature"
+	HeaderTimestamp = "X-Arunika-Timestamp"
+	HeaderEvent     = "X-Arunika-Event"
+	HeaderDelivery  = "X-Arunika-Delivery"
+)
+
+// Payload is the JSON body of a delivery. ID stays the same across retries
+// so that receivers can deduplicate at-least-once deliveries.
+type Payload struct {
+	ID        string       `json:"id"`
+	Type      events.Type  `json:"type"`
+	CreatedAt time.Time    `json:"created_at"`
+	Data      events.Event `json:"data"`
+}
+
+// WebhookDispatcher POSTs signed events to the configured endpoints
+type WebhookDispatcher struct {
+	urls           []string
+	secret         []byte
+	maxAttempts    int
+	initialBackoff time.Duration
+	client         *http.Client
+
+	// Deliveries waiting for a worker; workers bound concurrent deliveries.
+	// wg counts the deliveries queued or in flight.
+	queue       chan queuedDelivery
+	startOnce   sync.Once
+	wg          sync.WaitGroup
+	deliveryCtx context.Context
+	// Cancels the deliveries still retrying when Shutdown gives up
+	cancelDeliveries context.CancelFunc
+
+	logger     *zap.Logger
+	deadLetter *zap.Logger
+}
+
+// queuedDelivery is a delivery of an event to an endpoint
+type queuedDelivery struct {
+	endpoint string
+	payload  Payload
+	body     []byte
+}
+
+// errQueueFull dead-letters the deliveries beyond the queue size
+var errQueueFull = errors.New("webhook delivery queue is full")
+
+// ValidateWebhookConfig validates the WebhookConfig
+func ValidateWebhookConfig(config WebhookConfig) error {
+	if len(config.URLs) > 0 && config.Secret == "" {
+		return fmt.Errorf("webhook secret is required when webhook URLs are configured")
+	}
+
+	for _, endpoint := range config.URLs {
+		parsed, err := url.Parse(endpoint)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid webhook URL %q", endpoint)
+		}
+	}
+
+	if config.MaxAttempts < 0 {
+		return fmt.Errorf("max attempts must be positive, got %d", config.MaxAttempts)
+	}
+
+	if config.QueueSize < 0 {
+		return fmt.Errorf("queue size must be positive, got %d", config.QueueSize)
+	}
+
+	return nil
+}
+
+// NewWebhookDispatcher creates a new webhook dispatcher
+func NewWebhookDispatcher(config WebhookConfig, logger *zap.Logger) (*WebhookDispatcher, error) {
+	if err := ValidateWebhookConfig(config); err != nil {
+		return nil, err
+	}
+
+	logger = logger.Named("webhook")
+
+	// Apply defaults where needed
+	maxAttempts := config.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultMaxAttempts
+		logger.Info("Using default max attempts", zap.Int("maxAttempts", maxAttempts))
+	}
+
+	initialBackoff := config.InitialBackoff
+	if initialBackoff == 0 {
+		initialBackoff = defaultInitialBackoff
+		logger.Info("Using default initial backoff", zap.Duration("initialBackoff", initialBackoff))
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+		logger.Info("Using default timeout", zap.Duration("timeout", timeout))
+	}
+
+	queueSize := config.QueueSize
+	if queueSize == 0 {
+		queueSize = defaultQueueSize
+		logger.Info("Using default queue size", zap.Int("queueSize", queueSize))
+	}
+
+	deliveryCtx, cancelDeliveries := context.WithCancel(context.Background())
+	return &WebhookDispatcher{
+		urls:             config.URLs,
+		secret:           []byte(config.Secret),
+		maxAttempts:      maxAttempts,
+		initialBackoff:   initialBackoff,
+		client:           &http.Client{Timeout: timeout},
+		queue:            make(chan queuedDelivery, queueSize),
+		deliveryCtx:      deliveryCtx,
+		cancelDeliveries: cancelDeliveries,
+		logger:           logger,
+		deadLetter:       logger.Named("dead_letter"),
+	}, nil
+}
+
+// Run delivers every event received on sub until ctx is cancelled or the
+// subscription is closed. Receiving never waits for deliveries, so that no
+// event is dropped from the subscription while an endpoint is down. The
+// deliveries still pending once Run returned are waited for by Shutdown.
+func (d *WebhookDispatcher) Run(ctx context.Context, sub *events.Subscription) {
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// The events received already are delivered too
+			for {
+				select {
+				case event, ok := <-sub.Events():
+					if !ok {
+						return
+					}
+					d.Dispatch(event)
+				default:
+					return
+				}
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			d.Dispatch(event)
+		}
+	}
+}
+
+// Dispatch queues the delivery of event to every endpoint without waiting.
+// A delivery finding the queue full is dead-lettered. Dispatch must not be
+// called once Shutdown was.
+func (d *WebhookDispatcher) Dispatch(event events.Event) {
+	payload := Payload{
+		ID:        newDeliveryID(),
+		Type:      event.Type,
+		CreatedAt: time.Now(),
+		Data:      event,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		d.logger.Error("Failed to marshal webhook payload", zap.Error(err))
+		return
+	}
+
+	d.startOnce.Do(d.startWorkers)
+	for _, endpoint := range d.urls {
+		d.wg.Add(1)
+		select {
+		case d.queue <- queuedDelivery{endpoint: endpoint, payload: payload, body: body}:
+		default:
+			d.recordDeadLetter(endpoint, payload, body, 0, errQueueFull)
+			d.wg.Done()
+		}
+	}
+}
+
+// startWorkers starts the workers delivering the queued deliveries
+func (d *WebhookDispatcher) startWorkers() {
+	for i := 0; i < defaultMaxInFlight; i++ {
+		go func() {
+			for queued := range d.queue {
+				if err := d.deliveryCtx.Err(); err != nil {
+					// Shutdown gave up before the delivery was attempted
+					d.recordDeadLetter(queued.endpoint, queued.payload, queued.body, 0, err)
+				} else {
+					d.deliver(d.deliveryCtx, queued.endpoint, queued.payload, queued.body)
+				}
+				d.wg.Done()
+			}
+		}()
+	}
+}
+
+// Shutdown waits for the queued and in-flight deliveries, retries included,
+// to finish. When ctx is done first the remaining deliveries are
+// dead-lettered and Shutdown returns the error of ctx.
+func (d *WebhookDispatcher) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		d.cancelDeliveries()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// deliver retries a delivery with exponential backoff until it succeeds,
+// fails permanently or runs out of attempts
+func (d *WebhookDispatcher) deliver(ctx context.Context, endpoint string, payload Payload, body []byte) {
+	backoff := d.initialBackoff
+
+	for attempt := 1; ; attempt++ {
+		retryable, err := d.post(ctx, endpoint, payload, body)
+		if err == nil {
+			d.logger.Debug("Delivered webhook",
+				zap.String("url", endpoint),
+				zap.String("deliveryID", payload.ID),
+				zap.Int("attempt", attempt))
+			return
+		}
+
+		if !retryable || attempt >= d.maxAttempts {
+			d.recordDeadLetter(endpoint, payload, body, attempt, err)
+			return
+		}
+
+		d.logger.Warn("Webhook delivery failed, retrying",
+			zap.String("url", endpoint),
+			zap.String("deliveryID", payload.ID),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			d.recordDeadLetter(endpoint, payload, body, attempt, ctx.Err())
+			return
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// post sends a single signed delivery. It reports whether a failure is worth retrying.
+func (d *WebhookDispatcher) post(ctx context.Context, endpoint string, payload Payload, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(d.secret, timestamp, body))
+	req.Header.Set(HeaderEvent, string(payload.Type))
+	req.Header.Set(HeaderDelivery, payload.ID)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		// Network errors and timeouts are transient
+		return true, fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout:
+		return true, fmt.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("webhook endpoint rejected delivery with status %d", resp.StatusCode)
+	}
+}
+
+// recordDeadLetter logs a delivery that will not be retried, with enough
+// detail to replay it by hand
+func (d *WebhookDispatcher) recordDeadLetter(endpoint string, payload Payload, body []byte, attempts int, err error) {
+	d.deadLetter.Error("Webhook delivery dead-lettered",
+		zap.String("url", endpoint),
+		zap.String("deliveryID", payload.ID),
+		zap.String("type", string(payload.Type)),
+		zap.Int("attempts", attempts),
+		zap.ByteString("payload", body),
+		zap.Error(err))
+}
+
+// Sign returns the hex encoded HMAC-SHA256 signature of a delivery
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newDeliveryID returns a random delivery identifier
+func newDeliveryID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
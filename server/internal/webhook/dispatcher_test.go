@@ -0,0 +1,267 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+const testSecret = "webhook-secret"
+
+// delivery is a request captured by the test receiver
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver records deliveries and answers with the queued status codes,
+// then with 200
+type receiver struct {
+	mu         sync.Mutex
+	statuses   []int
+	deliveries []delivery
+	received   chan struct{}
+}
+
+func newReceiver(statuses ...int) *receiver {
+	return &receiver{statuses: statuses, received: make(chan struct{}, 16)}
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	r.deliveries = append(r.deliveries, delivery{header: req.Header.Clone(), body: body})
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	r.mu.Unlock()
+
+	w.WriteHeader(status)
+	r.received <- struct{}{}
+}
+
+func (r *receiver) wait(t *testing.T, n int) []delivery {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-r.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for delivery %d", i+1)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]delivery(nil), r.deliveries...)
+}
+
+func newTestDispatcher(t *testing.T, logger *zap.Logger, urls ...string) *WebhookDispatcher {
+	t.Helper()
+	dispatcher, err := NewWebhookDispatcher(WebhookConfig{
+		URLs:           urls,
+		Secret:         testSecret,
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	}, logger)
+	if err != nil {
+		t.Fatalf("Failed to create dispatcher: %v", err)
+	}
+	return dispatcher
+}
+
+func TestWebhookDispatcher_DeliversSignedPayload(t *testing.T) {
+	recv := newReceiver()
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	bus := events.NewBus(zaptest.NewLogger(t))
+	dispatcher := newTestDispatcher(t, zaptest.NewLogger(t), server.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Run(ctx, bus.Subscribe(0))
+
+	bus.Publish(events.Event{Type: events.SessionEnded, DeviceID: "device-1", SessionID: "session-1", Reason: events.ReasonIdle})
+
+	got := recv.wait(t, 1)[0]
+
+	var payload Payload
+	if err := json.Unmarshal(got.body, &payload); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+	if payload.Type != events.SessionEnded || payload.Data.SessionID != "session-1" || payload.Data.Reason != events.ReasonIdle {
+		t.Errorf("Unexpected payload: %+v", payload)
+	}
+
+	wantSignature := "sha256=" + Sign([]byte(testSecret), got.header.Get(HeaderTimestamp), got.body)
+	if got.header.Get(HeaderSignature) != wantSignature {
+		t.Errorf("Expected signature %q, got %q", wantSignature, got.header.Get(HeaderSignature))
+	}
+	if got.header.Get(HeaderEvent) != "session_ended" || got.header.Get(HeaderDelivery) != payload.ID {
+		t.Errorf("Unexpected headers: %v", got.header)
+	}
+}
+
+func TestWebhookDispatcher_RetriesOnServerError(t *testing.T) {
+	recv := newReceiver(http.StatusServiceUnavailable, http.StatusInternalServerError)
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	dispatcher := newTestDispatcher(t, zaptest.NewLogger(t), server.URL)
+	dispatcher.Dispatch(events.Event{Type: events.SessionStarted, SessionID: "session-1"})
+
+	// Shutdown waits for the retries
+	if err := dispatcher.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Failed to shut down dispatcher: %v", err)
+	}
+	deliveries := recv.wait(t, 3)
+
+	id := deliveries[0].header.Get(HeaderDelivery)
+	for i, d := range deliveries {
+		if d.header.Get(HeaderDelivery) != id {
+			t.Errorf("Attempt %d used delivery ID %q, want %q", i+1, d.header.Get(HeaderDelivery), id)
+		}
+	}
+}
+
+func TestWebhookDispatcher_DeadLettersPermanentFailure(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int
+	}{
+		{"client error is not retried", []int{http.StatusBadRequest}, 1},
+		{"attempts are exhausted", []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recv := newReceiver(tt.statuses...)
+			server := httptest.NewServer(recv)
+			defer server.Close()
+
+			core, logs := observer.New(zap.ErrorLevel)
+			dispatcher := newTestDispatcher(t, zap.New(core), server.URL)
+			dispatcher.Dispatch(events.Event{Type: events.SessionEnded, SessionID: "session-1"})
+
+			if err := dispatcher.Shutdown(context.Background()); err != nil {
+				t.Fatalf("Failed to shut down dispatcher: %v", err)
+			}
+			recv.wait(t, tt.wantAttempts)
+
+			deadLetters := logs.FilterMessage("Webhook delivery dead-lettered").All()
+			if len(deadLetters) != 1 {
+				t.Fatalf("Expected one dead-letter entry, got %d", len(deadLetters))
+			}
+			if attempts := deadLetters[0].ContextMap()["attempts"]; attempts != int64(tt.wantAttempts) {
+				t.Errorf("Expected %d attempts, got %v", tt.wantAttempts, attempts)
+			}
+		})
+	}
+}
+
+// An endpoint that stops answering neither blocks the events received nor
+// loses them: the deliveries beyond the queue are dead-lettered
+func TestWebhookDispatcher_DispatchDoesNotWaitForDeliveries(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	delivered := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		mu.Lock()
+		delivered++
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	core, logs := observer.New(zap.ErrorLevel)
+	dispatcher, err := NewWebhookDispatcher(WebhookConfig{
+		URLs:      []string{server.URL},
+		Secret:    testSecret,
+		QueueSize: 4,
+	}, zap.New(core))
+	if err != nil {
+		t.Fatalf("Failed to create dispatcher: %v", err)
+	}
+
+	const sent = 40
+	dispatched := make(chan struct{})
+	go func() {
+		for i := 0; i < sent; i++ {
+			dispatcher.Dispatch(events.Event{Type: events.SessionStarted, SessionID: "session-1"})
+		}
+		close(dispatched)
+	}()
+	select {
+	case <-dispatched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Dispatch waited for the endpoint")
+	}
+
+	close(release)
+	if err := dispatcher.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Failed to shut down dispatcher: %v", err)
+	}
+
+	deadLetters := logs.FilterMessage("Webhook delivery dead-lettered").Len()
+	if deadLetters == 0 {
+		t.Error("Expected the deliveries beyond the queue dead-lettered")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if delivered+deadLetters != sent {
+		t.Errorf("Expected every delivery attempted or dead-lettered, got %d delivered and %d dead-lettered", delivered, deadLetters)
+	}
+}
+
+// Shutdown gives up on the retries still pending when its context is done
+// and dead-letters them
+func TestWebhookDispatcher_ShutdownDeadLettersPendingRetries(t *testing.T) {
+	recv := newReceiver(http.StatusServiceUnavailable)
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	core, logs := observer.New(zap.ErrorLevel)
+	dispatcher, err := NewWebhookDispatcher(WebhookConfig{
+		URLs:           []string{server.URL},
+		Secret:         testSecret,
+		InitialBackoff: time.Hour,
+	}, zap.New(core))
+	if err != nil {
+		t.Fatalf("Failed to create dispatcher: %v", err)
+	}
+	dispatcher.Dispatch(events.Event{Type: events.SessionEnded, SessionID: "session-1"})
+	recv.wait(t, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := dispatcher.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline exceeded, got %v", err)
+	}
+	if n := logs.FilterMessage("Webhook delivery dead-lettered").Len(); n != 1 {
+		t.Errorf("Expected the pending retry dead-lettered, got %d entries", n)
+	}
+}
+
+func TestValidateWebhookConfig(t *testing.T) {
+	if err := ValidateWebhookConfig(WebhookConfig{URLs: []string{"https://example.com/hook"}}); err == nil {
+		t.Error("Expected error when the secret is missing")
+	}
+	if err := ValidateWebhookConfig(WebhookConfig{URLs: []string{"ftp://example.com"}, Secret: testSecret}); err == nil {
+		t.Error("Expected error for a non-HTTP URL")
+	}
+	if err := ValidateWebhookConfig(WebhookConfig{}); err != nil {
+		t.Errorf("Expected disabled webhooks to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,281 @@
+package websocket
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Devices may receive the response audio over a second WebSocket connection,
+// keeping the primary connection for control messages. The device asks for it
+// with "audio_channel": true in its hello and connects to the audio endpoint
+// with the channel ID of the hello reply; the protocol is documented in
+// docs/websocket-audio-channel.md.
+
+// Only pings, pongs and the close handshake are read from the audio channel
+const audioChannelMaxMessageSize = 512
+
+// audioChannel is the second connection of a client carrying response audio
+type audioChannel struct {
+	conn   *websocket.Conn
+	send   chan []byte
+	done   chan struct{}
+	logger *zap.Logger
+}
+
+func newAudioChannel(conn *websocket.Conn, logger *zap.Logger) *audioChannel {
+	return &audioChannel{
+		conn:   conn,
+		send:   make(chan []byte, 256),
+		done:   make(chan struct{}),
+		logger: logger,
+	}
+}
+
+// write queues a frame, reporting false when the channel is closed
+func (a *audioChannel) write(frame []byte) bool {
+	select {
+	case <-a.done:
+		return false
+	default:
+	}
+	select {
+	case a.send <- frame:
+		return true
+	case <-a.done:
+		return false
+	}
+}
+
+// closed reports whether the channel stopped accepting frames
+func (a *audioChannel) closed() bool {
+	select {
+	case <-a.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// writePump writes the queued frames until the channel is closed
+func (a *audioChannel) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		a.conn.Close()
+	}()
+
+	for {
+		select {
+		case frame := <-a.send:
+			a.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := a.conn.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+				a.logger.Error("Failed to write to audio channel", zap.Error(err))
+				return
+			}
+
+		case <-ticker.C:
+			a.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := a.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+		case <-a.done:
+			a.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			a.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+		}
+	}
+}
+
+// readPump keeps the connection alive until the device closes it, then
+// detaches the channel from c
+func (a *audioChannel) readPump(c *Client) {
+	defer func() {
+		c.detachAudioChannel(a)
+		a.conn.Close()
+	}()
+
+	a.conn.SetReadLimit(audioChannelMaxMessageSize)
+	a.conn.SetReadDeadline(time.Now().Add(pongWait))
+	a.conn.SetPongHandler(func(string) error {
+		a.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		if _, _, err := a.conn.ReadMessage(); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure, websocket.CloseNormalClosure) {
+				a.logger.Warn("Audio channel error", zap.String("deviceID", c.deviceID), zap.Error(err))
+			}
+			return
+		}
+	}
+}
+
+// offerAudioChannel returns the ID the device connects its audio channel
+// with, creating it on the first request
+func (c *Client) offerAudioChannel() string {
+	c.audioMu.Lock()
+	defer c.audioMu.Unlock()
+	if c.audioChannelID == "" {
+		c.audioChannelID = uuid.NewString()
+	}
+	return c.audioChannelID
+}
+
+// offeredAudioChannel returns the channel ID offered in the hello, empty when
+// the device asked for none
+func (c *Client) offeredAudioChannel() string {
+	c.audioMu.Lock()
+	defer c.audioMu.Unlock()
+	return c.audioChannelID
+}
+
+// attachAudioChannel makes a the audio channel of the connection, closing
+// the previous one. It fails when channelID was not offered to the device or
+// the connection closed meanwhile.
+func (c *Client) attachAudioChannel(a *audioChannel, channelID string) bool {
+	c.audioMu.Lock()
+	if c.audioChannelID == "" || channelID != c.audioChannelID {
+		c.audioMu.Unlock()
+		return false
+	}
+	previous := c.audioChannel
+	c.audioChannel = a
+	c.audioMu.Unlock()
+
+	if previous != nil {
+		close(previous.done)
+	}
+	return true
+}
+
+// detachAudioChannel stops using a, so that the following responses are sent
+// over the primary connection again
+func (c *Client) detachAudioChannel(a *audioChannel) {
+	c.audioMu.Lock()
+	defer c.audioMu.Unlock()
+	if c.audioChannel != a {
+		return
+	}
+	c.audioChannel = nil
+	close(a.done)
+	c.logger.Info("Audio channel disconnected", zap.String("deviceID", c.deviceID))
+}
+
+// closeAudioChannel closes the audio channel with the primary connection and
+// withdraws the channel ID
+func (c *Client) closeAudioChannel() {
+	c.audioMu.Lock()
+	defer c.audioMu.Unlock()
+	c.audioChannelID = ""
+	if c.audioChannel != nil {
+		close(c.audioChannel.done)
+		c.audioChannel = nil
+	}
+}
+
+// beginResponseAudio chooses the connection carrying the audio of the
+// response starting now, reporting whether it is the audio channel. A
+// response never switches channels midway unless the audio channel closes.
+func (c *Client) beginResponseAudio() bool {
+	c.audioMu.Lock()
+	defer c.audioMu.Unlock()
+	c.responseChannel = c.audioChannel
+	c.responseFrames = 0
+	c.responseDropped = 0
+	c.firstDroppedSequence = 0
+	return c.responseChannel != nil
+}
+
+// endResponseAudio returns how the audio of the response that just ended
+// was delivered
+func (c *Client) endResponseAudio() responseDelivery {
+	c.audioMu.Lock()
+	defer c.audioMu.Unlock()
+	delivery := responseDelivery{
+		frames:       c.responseFrames,
+		sequenced:    c.responseChannel != nil || c.responseAudioHeader,
+		dropped:      c.responseDropped,
+		firstDropped: c.firstDroppedSequence,
+	}
+	c.responseChannel = nil
+	c.responseFrames = 0
+	c.responseDropped = 0
+	c.firstDroppedSequence = 0
+	return delivery
+}
+
+// sendResponseAudio sends audio over the audio channel when the current
+// response uses it, reporting false otherwise. Frames carry the audio header
+// with the session and their position in the response. When the audio
+// channel closed midway, the remaining frames keep the header and are sent
+// over the primary connection.
+func (c *Client) sendResponseAudio(sessionID string, audio []byte) bool {
+	c.audioMu.Lock()
+	channel := c.responseChannel
+	if channel == nil {
+		c.audioMu.Unlock()
+		return false
+	}
+	c.responseFrames++
+	sequence := c.responseFrames
+	frame := appendAudioHeader(audioHeader{Sequence: sequence, SessionID: sessionID}, audio)
+	c.audioMu.Unlock()
+
+	if channel.closed() || !channel.write(frame) {
+		c.queueAudio(sequence, frame)
+	}
+	return true
+}
+
+// HandleAudioWebSocketWithAuth accepts the audio channel of a device whose
+// primary connection was offered the channel ID in the "channel" query
+// parameter. Unknown channels are refused before the upgrade.
+func HandleAudioWebSocketWithAuth(hub *Hub, c echo.Context, deviceID string, logger *zap.Logger) error {
+	if deviceID == "" {
+		logger.Warn("Audio channel rejected: missing device ID")
+		return echo.NewHTTPError(http.StatusUnauthorized, "device ID is required")
+	}
+
+	channelID := c.QueryParam("channel")
+	hub.mu.RLock()
+	client, ok := hub.clients[deviceID]
+	hub.mu.RUnlock()
+	if !ok || channelID == "" || client.offeredAudioChannel() != channelID {
+		logger.Warn("Audio channel rejected: unknown channel", zap.String("deviceID", deviceID))
+		return echo.NewHTTPError(http.StatusNotFound, "unknown audio channel")
+	}
+
+	if err := hub.checkOrigin(c.Request(), logger); err != nil {
+		return err
+	}
+
+	conn, err := hub.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		logger.Error("Audio channel upgrade failed", zap.Error(err))
+		return err
+	}
+
+	channel := newAudioChannel(conn, logger)
+	if !client.attachAudioChannel(channel, channelID) {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "unknown audio channel"), time.Now().Add(writeWait))
+		conn.Close()
+		return nil
+	}
+	go channel.writePump()
+	go channel.readPump(client)
+
+	logger.Info("Audio channel connected", zap.String("deviceID", deviceID))
+	client.sendControl(map[string]interface{}{
+		"type":      "audio_channel",
+		"status":    "connected",
+		"timestamp": time.Now().Unix(),
+	})
+	return nil
+}
@@ -0,0 +1,225 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+type audioChannelFixture struct {
+	url string
+}
+
+func newAudioChannelFixture(t *testing.T, config HubConfig) *audioChannelFixture {
+	t.Helper()
+	// The connections outlive the test's logger, so log nowhere
+	logger := zap.NewNop()
+	engine, err := conversation.NewEngine(conversation.Config{}, &conversationtest.LLM{Reply: "Halo juga!"},
+		&conversationtest.TTS{Chunks: [][]byte{{0xAA}, {0xBB}}}, &conversationtest.STT{Transcript: "halo"},
+		&conversationtest.SessionRepository{}, adapters.NewMemoryChildRepository(), adapters.NewMemoryQuietHoursRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub, err := NewHub(config, engine, adapters.NewMemoryDeviceRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go hub.Run()
+
+	e := echo.New()
+	e.GET("/ws/:device", func(c echo.Context) error {
+		return HandleWebSocketWithAuth(hub, c, c.Param("device"), logger)
+	})
+	e.GET("/ws/:device/audio", func(c echo.Context) error {
+		return HandleAudioWebSocketWithAuth(hub, c, c.Param("device"), logger)
+	})
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	return &audioChannelFixture{url: "ws" + strings.TrimPrefix(server.URL, "http")}
+}
+
+func (f *audioChannelFixture) dial(t *testing.T, path string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	conn, resp, err := websocket.DefaultDialer.Dial(f.url+path, nil)
+	if conn != nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+// readControl reads control messages from conn until one of the given type
+func readControl(t *testing.T, conn *websocket.Conn, msgType string) map[string]interface{} {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read %q: %v", msgType, err)
+		}
+		if messageType != websocket.TextMessage {
+			continue
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("Failed to decode %s: %v", data, err)
+		}
+		if msg["type"] == msgType {
+			return msg
+		}
+	}
+}
+
+// hello sends a hello asking for the audio channel and returns the reply
+func hello(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"hello","audio_channel":true}`)); err != nil {
+		t.Fatalf("Failed to send hello: %v", err)
+	}
+	return readControl(t, conn, "hello")
+}
+
+func TestHub_AudioChannelCarriesResponseAudio(t *testing.T) {
+	f := newAudioChannelFixture(t, HubConfig{AudioChannel: true})
+	control, _, err := f.dial(t, "/ws/device-1")
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	reply := hello(t, control)
+	channelID, _ := reply["audio_channel_id"].(string)
+	if reply["audio_channel"] != true || channelID == "" {
+		t.Fatalf("Expected the audio channel to be offered, got %v", reply)
+	}
+
+	// Only the device's own connection can attach the channel
+	if _, resp, err := f.dial(t, "/ws/device-2/audio?channel="+channelID); err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected another device to be refused, got %v", err)
+	}
+	if _, resp, err := f.dial(t, "/ws/device-1/audio?channel=guess"); err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected an unknown channel to be refused, got %v", err)
+	}
+
+	audio, _, err := f.dial(t, "/ws/device-1/audio?channel="+channelID)
+	if err != nil {
+		t.Fatalf("Failed to connect the audio channel: %v", err)
+	}
+	if msg := readControl(t, control, "audio_channel"); msg["status"] != "connected" {
+		t.Fatalf("Expected the audio channel to be confirmed, got %v", msg)
+	}
+
+	for turn := 0; turn < 2; turn++ {
+		// Only control messages arrive on the primary connection
+		received := runSocketTurn(t, control)
+		for _, msg := range received {
+			if msg.Type == "binary" {
+				t.Fatalf("Turn %d: expected no audio on the primary connection, got %v", turn, received)
+			}
+		}
+
+		audio.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for i, want := range []byte{0xAA, 0xBB} {
+			messageType, frame, err := audio.ReadMessage()
+			if err != nil {
+				t.Fatalf("Turn %d: failed to read audio: %v", turn, err)
+			}
+			header, payload, err := parseAudioHeader(frame)
+			if messageType != websocket.BinaryMessage || err != nil {
+				t.Fatalf("Turn %d: expected a headered binary frame, got %d: %v", turn, messageType, err)
+			}
+			if header.Sequence != uint32(i+1) || header.SessionID == "" || len(payload) != 1 || payload[0] != want {
+				t.Errorf("Turn %d: unexpected frame %d: %+v %x", turn, i, header, payload)
+			}
+		}
+	}
+}
+
+func TestHub_AudioChannelMarksResponses(t *testing.T) {
+	f := newAudioChannelFixture(t, HubConfig{AudioChannel: true})
+	control, _, err := f.dial(t, "/ws/device-1")
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	channelID := hello(t, control)["audio_channel_id"].(string)
+
+	speak := func() {
+		t.Helper()
+		for _, msg := range []string{`{"type":"listening_start"}`, "", `{"type":"listening_end"}`} {
+			messageType, data := websocket.TextMessage, []byte(msg)
+			if msg == "" {
+				messageType, data = websocket.BinaryMessage, make([]byte, 320)
+			}
+			if err := control.WriteMessage(messageType, data); err != nil {
+				t.Fatalf("Failed to write: %v", err)
+			}
+		}
+	}
+	// Before the audio channel connects the primary connection carries the audio
+	speak()
+	if start := readControl(t, control, "speaking_start"); start["audio_channel"] != nil {
+		t.Errorf("Expected the audio on the primary connection before the channel connects, got %v", start)
+	}
+	if end := readControl(t, control, "speaking_end"); end["audio_frames"] != nil {
+		t.Errorf("Expected no frame count without the audio channel, got %v", end)
+	}
+
+	audio, _, err := f.dial(t, "/ws/device-1/audio?channel="+channelID)
+	if err != nil {
+		t.Fatalf("Failed to connect the audio channel: %v", err)
+	}
+	readControl(t, control, "audio_channel")
+
+	speak()
+	if start := readControl(t, control, "speaking_start"); start["audio_channel"] != true {
+		t.Errorf("Expected the response to be marked as sent over the audio channel, got %v", start)
+	}
+	if end := readControl(t, control, "speaking_end"); end["audio_frames"] != float64(2) {
+		t.Errorf("Expected the frame count of the response, got %v", end)
+	}
+
+	// Once the audio channel closes the primary connection carries the audio again
+	audio.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		speak()
+		start := readControl(t, control, "speaking_start")
+		readControl(t, control, "speaking_end")
+		if start["audio_channel"] == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the primary connection to carry the audio after the channel closed")
+		}
+	}
+}
+
+func TestHub_AudioChannelNotOfferedWhenDisabled(t *testing.T) {
+	f := newAudioChannelFixture(t, HubConfig{})
+	control, _, err := f.dial(t, "/ws/device-1")
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	if reply := hello(t, control); reply["audio_channel"] != false || reply["audio_channel_id"] != nil {
+		t.Fatalf("Expected the audio channel to be declined, got %v", reply)
+	}
+	if _, resp, err := f.dial(t, "/ws/device-1/audio?channel="); err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the audio channel to be refused, got %v", err)
+	}
+
+	received := runSocketTurn(t, control)
+	if len(received) != 6 || received[3].Type != "binary" || received[4].Type != "binary" {
+		t.Errorf("Expected the audio on the primary connection, got %v", received)
+	}
+}
@@ -0,0 +1,120 @@
+package websocket
+
+import (
+	"expvar"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// The write pump is the only writer of a connection, so response audio
+// reaches the device in the order it was queued. When the device reads too
+// slowly the queue fills up; with HubConfig.AudioDropTimeout set, a frame
+// that cannot be queued within the timeout is dropped instead of stalling the
+// conversation, and the drop is counted and reported.
+
+// audioFramesDropped counts the response audio frames dropped under backpressure
+var audioFramesDropped = expvar.NewInt("websocket_audio_frames_dropped")
+
+// responseDelivery is how the audio of a response was delivered
+type responseDelivery struct {
+	frames uint32
+	// sequenced is set when the frames carried their sequence in the audio
+	// header, so the device can tell which are missing
+	sequenced    bool
+	dropped      uint32
+	firstDropped uint32
+}
+
+// setResponseAudioHeader sets whether response audio sent over this
+// connection carries the audio header
+func (c *Client) setResponseAudioHeader(enabled bool) {
+	c.audioMu.Lock()
+	defer c.audioMu.Unlock()
+	c.responseAudioHeader = enabled
+}
+
+// sendPrimaryAudio sends response audio over this connection, numbering the
+// frames of the response and prefixing them with the audio header when the
+// device asked for it
+func (c *Client) sendPrimaryAudio(sessionID string, audio []byte) {
+	c.audioMu.Lock()
+	c.responseFrames++
+	sequence := c.responseFrames
+	headered := c.responseAudioHeader
+	c.audioMu.Unlock()
+
+	frame := audio
+	if headered {
+		frame = appendAudioHeader(audioHeader{Sequence: sequence, SessionID: sessionID}, audio)
+	}
+	c.queueAudio(sequence, frame)
+}
+
+// queueAudio queues a response audio frame for the write pump. Without an
+// AudioDropTimeout it waits for room as long as it takes; otherwise a frame
+// still not queued after the timeout is dropped and recorded.
+func (c *Client) queueAudio(sequence uint32, frame []byte) {
+	data := WriteData{Type: websocket.BinaryMessage, Payload: frame}
+	timeout := c.hub.config.AudioDropTimeout
+	if timeout <= 0 {
+		c.queue(data)
+		return
+	}
+
+	select {
+	case <-c.done:
+		return
+	case c.send <- data:
+		return
+	default:
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-c.done:
+	case c.send <- data:
+	case <-timer.C:
+		c.recordDroppedAudio(sequence)
+	}
+}
+
+// recordDroppedAudio counts a response audio frame dropped under backpressure
+func (c *Client) recordDroppedAudio(sequence uint32) {
+	audioFramesDropped.Add(1)
+	c.audioMu.Lock()
+	defer c.audioMu.Unlock()
+	if c.responseDropped == 0 {
+		c.firstDroppedSequence = sequence
+	}
+	c.responseDropped++
+}
+
+// reportDroppedAudio logs the frames of a response dropped under
+// backpressure and, when AudioDropNotice is set, tells the device with a
+// speaking_degraded message so it can ask for the response again
+func (c *Client) reportDroppedAudio(sessionID string, delivery responseDelivery) {
+	c.logger.Warn("Response audio dropped under backpressure",
+		zap.String("deviceID", c.deviceID),
+		zap.String("sessionID", sessionID),
+		zap.Uint32("frames", delivery.frames),
+		zap.Uint32("dropped", delivery.dropped),
+		zap.Uint32("firstDropped", delivery.firstDropped))
+	if !c.hub.config.AudioDropNotice {
+		return
+	}
+
+	payload := map[string]interface{}{
+		"type":           "speaking_degraded",
+		"dropped_frames": delivery.dropped,
+		"audio_frames":   delivery.frames,
+	}
+	if sessionID != "" {
+		payload["session_id"] = sessionID
+	}
+	if delivery.sequenced {
+		payload["first_dropped_sequence"] = delivery.firstDropped
+	}
+	c.sendControl(payload)
+}
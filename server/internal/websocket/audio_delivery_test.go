@@ -0,0 +1,96 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestClient_CountsAudioDroppedUnderBackpressure(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{}, &conversationtest.TTS{})
+	hub.config.AudioDropTimeout = 5 * time.Millisecond
+	hub.config.AudioDropNotice = true
+	client := newTestClient(hub, "device-1")
+	client.setResponseAudioHeader(true)
+	dropped := audioFramesDropped.Value()
+
+	// Nothing drains the queue, so it fills up after the speaking start and
+	// 255 frames
+	client.Emit(conversation.Event{Type: conversation.EventSpeakingStart, SessionID: "session-1"})
+	for i := 0; i < 300; i++ {
+		client.Emit(conversation.Event{Type: conversation.EventAudio, SessionID: "session-1", Audio: []byte{byte(i)}})
+	}
+	if got := audioFramesDropped.Value() - dropped; got != 45 {
+		t.Fatalf("Expected 45 frames counted as dropped, got %d", got)
+	}
+
+	go client.Emit(conversation.Event{Type: conversation.EventSpeakingEnd, SessionID: "session-1"})
+	var sequences []uint32
+	var degraded, end map[string]interface{}
+	timeout := time.After(5 * time.Second)
+	for end == nil {
+		select {
+		case data := <-client.send:
+			if data.Type == websocket.BinaryMessage {
+				header, _, err := parseAudioHeader(data.Payload)
+				if err != nil || header.SessionID != "session-1" {
+					t.Fatalf("Expected response audio with the audio header, got %v", err)
+				}
+				sequences = append(sequences, header.Sequence)
+				continue
+			}
+			var msg map[string]interface{}
+			if err := json.Unmarshal(data.Payload, &msg); err != nil {
+				t.Fatalf("Failed to parse queued message: %v", err)
+			}
+			switch msg["type"] {
+			case "speaking_degraded":
+				degraded = msg
+			case "speaking_end":
+				end = msg
+			}
+		case <-timeout:
+			t.Fatal("Timed out waiting for speaking_end")
+		}
+	}
+
+	if len(sequences) != 255 || sequences[0] != 1 || sequences[254] != 255 {
+		t.Errorf("Expected frames 1 to 255 in order, got %d frames", len(sequences))
+	}
+	if degraded == nil || degraded["dropped_frames"] != float64(45) || degraded["first_dropped_sequence"] != float64(256) || degraded["session_id"] != "session-1" {
+		t.Errorf("Expected speaking_degraded to report the dropped frames, got %v", degraded)
+	}
+	if end["dropped_frames"] != float64(45) || end["audio_frames"] != float64(300) {
+		t.Errorf("Expected speaking_end to report the frames and drops, got %v", end)
+	}
+}
+
+func TestClient_WaitsForRoomWithoutDropTimeout(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{}, &conversationtest.TTS{})
+	client := newTestClient(hub, "device-1")
+	dropped := audioFramesDropped.Value()
+
+	done := make(chan struct{})
+	go func() {
+		client.Emit(conversation.Event{Type: conversation.EventSpeakingStart, SessionID: "session-1"})
+		for i := 0; i < 300; i++ {
+			client.Emit(conversation.Event{Type: conversation.EventAudio, SessionID: "session-1", Audio: []byte{byte(i)}})
+		}
+		client.Emit(conversation.Event{Type: conversation.EventSpeakingEnd, SessionID: "session-1"})
+		close(done)
+	}()
+
+	types := readUntil(t, client, "speaking_end")
+	<-done
+	if len(types) != 302 {
+		t.Errorf("Expected every frame to be delivered, got %d messages", len(types))
+	}
+	if got := audioFramesDropped.Value() - dropped; got != 0 {
+		t.Errorf("Expected no drops, got %d", got)
+	}
+}
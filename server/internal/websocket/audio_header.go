@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+// Binary audio frames may start with a header identifying the session and
+// position of the chunk, so that audio reaches the right session even when a
+// control message was lost. Devices opt in with "audio_header": true in their
+// hello; the format is documented in docs/websocket-audio-header.md.
+//
+//	offset  size  field
+//	0       2     magic "AR"
+//	2       1     version (1)
+//	3       1     flags
+//	4       4     sequence, big endian, increasing within a listening session
+//	8       1     session ID length n, 0 when the device has none yet
+//	9       n     session ID
+//	9+n     ...   audio
+const (
+	audioHeaderVersion = 1
+	audioHeaderMinSize = 9
+)
+
+var audioHeaderMagic = [2]byte{'A', 'R'}
+
+// audioFlagFinal marks the last chunk of an utterance, ending the listening
+// session as a listening_end would unless the hub is configured with
+// AudioFinalControl
+const audioFlagFinal byte = 1 << 0
+
+// audioFlagPreRoll marks audio captured before listening started. It is kept
+// as pre-roll of the next listening session instead of starting listening.
+const audioFlagPreRoll byte = 1 << 1
+
+var (
+	errAudioHeaderShort   = errors.New("audio header too short")
+	errAudioHeaderMagic   = errors.New("audio header magic mismatch")
+	errAudioHeaderVersion = errors.New("unsupported audio header version")
+)
+
+// audioHeader is the metadata prefixing an audio chunk
+type audioHeader struct {
+	Version   byte
+	Flags     byte
+	Sequence  uint32
+	SessionID string
+}
+
+// Final reports whether the chunk ends the utterance
+func (h audioHeader) Final() bool {
+	return h.Flags&audioFlagFinal != 0
+}
+
+// PreRoll reports whether the chunk is pre-roll of the next utterance
+func (h audioHeader) PreRoll() bool {
+	return h.Flags&audioFlagPreRoll != 0
+}
+
+// parseAudioHeader splits a binary frame into its header and audio payload
+func parseAudioHeader(frame []byte) (audioHeader, []byte, error) {
+	if len(frame) < audioHeaderMinSize {
+		return audioHeader{}, nil, errAudioHeaderShort
+	}
+	if frame[0] != audioHeaderMagic[0] || frame[1] != audioHeaderMagic[1] {
+		return audioHeader{}, nil, errAudioHeaderMagic
+	}
+	if frame[2] != audioHeaderVersion {
+		return audioHeader{}, nil, errAudioHeaderVersion
+	}
+
+	header := audioHeader{
+		Version:  frame[2],
+		Flags:    frame[3],
+		Sequence: binary.BigEndian.Uint32(frame[4:8]),
+	}
+	idLen := int(frame[8])
+	if len(frame) < audioHeaderMinSize+idLen {
+		return audioHeader{}, nil, errAudioHeaderShort
+	}
+	header.SessionID = string(frame[audioHeaderMinSize : audioHeaderMinSize+idLen])
+	return header, frame[audioHeaderMinSize+idLen:], nil
+}
+
+// appendAudioHeader encodes header in front of audio
+func appendAudioHeader(header audioHeader, audio []byte) []byte {
+	frame := make([]byte, 0, audioHeaderMinSize+len(header.SessionID)+len(audio))
+	frame = append(frame, audioHeaderMagic[0], audioHeaderMagic[1], audioHeaderVersion, header.Flags)
+	frame = binary.BigEndian.AppendUint32(frame, header.Sequence)
+	frame = append(frame, byte(len(header.SessionID)))
+	frame = append(frame, header.SessionID...)
+	return append(frame, audio...)
+}
+
+// processHeaderedAudioChunk routes a chunk carrying an audio header. Chunks of
+// an earlier session and repeated sequence numbers are dropped, and audio
+// arriving while the conversation is not listening starts listening as if the
+// listening_start had been received.
+func (c *Client) processHeaderedAudioChunk(frame []byte) {
+	header, audio, err := parseAudioHeader(frame)
+	if err != nil {
+		c.logger.Warn("Dropping audio chunk with malformed header",
+			zap.String("deviceID", c.deviceID),
+			zap.Int("size", len(frame)),
+			zap.Error(err))
+		return
+	}
+
+	if header.PreRoll() {
+		if !c.bufferPreRoll(audio) {
+			c.logger.Debug("Dropping pre-roll audio chunk",
+				zap.String("deviceID", c.deviceID),
+				zap.Bool("listening", c.conversation.Listening()))
+		}
+		return
+	}
+
+	if !c.conversation.Listening() {
+		c.logger.Info("Headered audio received while not listening, starting listening",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", header.SessionID),
+			zap.Uint32("sequence", header.Sequence))
+		c.handleListeningStart(map[string]interface{}{"type": "listening_start"})
+		if !c.conversation.Listening() {
+			return
+		}
+	}
+
+	if current := c.conversation.SessionID(); header.SessionID != "" && header.SessionID != current {
+		c.logger.Warn("Dropping audio chunk of another session",
+			zap.String("deviceID", c.deviceID),
+			zap.String("sessionID", header.SessionID),
+			zap.String("currentSessionID", current))
+		return
+	}
+
+	if c.audioSequenced && header.Sequence <= c.lastAudioSequence {
+		c.logger.Warn("Dropping repeated or out of order audio chunk",
+			zap.String("deviceID", c.deviceID),
+			zap.Uint32("sequence", header.Sequence),
+			zap.Uint32("lastSequence", c.lastAudioSequence))
+		return
+	}
+	if c.audioSequenced && header.Sequence != c.lastAudioSequence+1 {
+		c.logger.Warn("Audio chunks missing before this one",
+			zap.String("deviceID", c.deviceID),
+			zap.Uint32("sequence", header.Sequence),
+			zap.Uint32("lastSequence", c.lastAudioSequence))
+	}
+	c.lastAudioSequence = header.Sequence
+	c.audioSequenced = true
+
+	c.conversation.StreamAudio(audio)
+
+	if header.Final() && c.hub.config.AudioFinal == AudioFinalFlag {
+		c.handleListeningEnd(map[string]interface{}{"type": "listening_end"})
+		c.finalFlagEnded = true
+	}
+}
@@ -0,0 +1,177 @@
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestParseAudioHeader(t *testing.T) {
+	valid := appendAudioHeader(audioHeader{Flags: audioFlagFinal, Sequence: 258, SessionID: "session-1"}, []byte{0x01, 0x02})
+
+	tests := []struct {
+		name      string
+		frame     []byte
+		want      audioHeader
+		wantAudio []byte
+		wantErr   error
+	}{
+		{"with session", valid, audioHeader{Version: 1, Flags: audioFlagFinal, Sequence: 258, SessionID: "session-1"}, []byte{0x01, 0x02}, nil},
+		{"without session", []byte{'A', 'R', 1, 0, 0, 0, 0, 7, 0, 0xAA}, audioHeader{Version: 1, Sequence: 7}, []byte{0xAA}, nil},
+		{"header only", []byte{'A', 'R', 1, 0, 0, 0, 0, 1, 0}, audioHeader{Version: 1, Sequence: 1}, []byte{}, nil},
+		{"too short", []byte{'A', 'R', 1, 0}, audioHeader{}, nil, errAudioHeaderShort},
+		{"raw audio", []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09}, audioHeader{}, nil, errAudioHeaderMagic},
+		{"future version", []byte{'A', 'R', 2, 0, 0, 0, 0, 1, 0}, audioHeader{}, nil, errAudioHeaderVersion},
+		{"truncated session", valid[:12], audioHeader{}, nil, errAudioHeaderShort},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, audio, err := parseAudioHeader(tt.frame)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if header != tt.want || !bytes.Equal(audio, tt.wantAudio) {
+				t.Errorf("Expected %+v with audio %v, got %+v with audio %v", tt.want, tt.wantAudio, header, audio)
+			}
+		})
+	}
+}
+
+func TestClient_RoutesHeaderedAudio(t *testing.T) {
+	stt := &conversationtest.STT{Transcript: "halo"}
+	hub := newTestHub(t, conversation.Config{}, stt, &conversationtest.TTS{Chunks: [][]byte{{0xAA}}})
+	client := newTestClient(hub, "device-1")
+
+	client.handleHello(map[string]interface{}{"type": "hello", "audio_header": true})
+	if msg := readMessage(t, client, "hello"); msg["audio_header"] != true || msg["audio_header_version"] != float64(audioHeaderVersion) {
+		t.Fatalf("Expected the audio header to be accepted, got %v", msg)
+	}
+
+	// The listening_start was lost; the first chunk starts listening
+	client.processBinaryAudioChunk(appendAudioHeader(audioHeader{Sequence: 1}, []byte{0x01}))
+	started := readMessage(t, client, "listening_start")
+	sessionID, _ := started["session_id"].(string)
+	if started["error"] != nil || sessionID == "" {
+		t.Fatalf("Expected listening to start, got %v", started)
+	}
+
+	client.processBinaryAudioChunk(appendAudioHeader(audioHeader{Sequence: 2, SessionID: sessionID}, []byte{0x02}))
+	// Repeated, stale and malformed chunks are dropped
+	client.processBinaryAudioChunk(appendAudioHeader(audioHeader{Sequence: 2, SessionID: sessionID}, []byte{0x02}))
+	client.processBinaryAudioChunk(appendAudioHeader(audioHeader{Sequence: 3, SessionID: "previous-session"}, []byte{0xEE}))
+	client.processBinaryAudioChunk([]byte{0xEE})
+	// The final flag ends listening even if the listening_end is lost
+	client.processBinaryAudioChunk(appendAudioHeader(audioHeader{Sequence: 3, SessionID: sessionID, Flags: audioFlagFinal}, []byte{0x03}))
+	readUntil(t, client, "speaking_end")
+
+	streams := stt.Streams()
+	if len(streams) != 1 {
+		t.Fatalf("Expected one transcription stream, got %d", len(streams))
+	}
+	chunks := streams[0].Chunks()
+	if len(chunks) != 3 || chunks[0][0] != 0x01 || chunks[1][0] != 0x02 || chunks[2][0] != 0x03 {
+		t.Errorf("Expected the three chunks of the session in order, got %v", chunks)
+	}
+	if !streams[0].Ended() {
+		t.Error("Expected the final chunk to end the transcription")
+	}
+}
+
+// headeredTurn runs an utterance of two headered chunks, ended by the final
+// flag of the last one or by a listening_end, and returns the messages sent
+// to the device and the audio transcribed
+func headeredTurn(t *testing.T, config HubConfig, finalFlag bool) ([]string, [][]byte) {
+	t.Helper()
+	stt := &conversationtest.STT{Transcript: "halo"}
+	hub := newTestHub(t, conversation.Config{}, stt, &conversationtest.TTS{Chunks: [][]byte{{0xAA}}})
+	if config.AudioFinal != "" {
+		hub.config.AudioFinal = config.AudioFinal
+	}
+	client := newTestClient(hub, "device-1")
+	client.handleHello(map[string]interface{}{"type": "hello", "audio_header": true})
+	readMessage(t, client, "hello")
+
+	client.handleListeningStart(map[string]interface{}{"type": "listening_start"})
+	readMessage(t, client, "listening_start")
+	client.processBinaryAudioChunk(appendAudioHeader(audioHeader{Sequence: 1}, []byte{0x01}))
+	if finalFlag {
+		client.processBinaryAudioChunk(appendAudioHeader(audioHeader{Sequence: 2, Flags: audioFlagFinal}, []byte{0x02}))
+	} else {
+		client.processBinaryAudioChunk(appendAudioHeader(audioHeader{Sequence: 2}, []byte{0x02}))
+		client.handleListeningEnd(map[string]interface{}{"type": "listening_end"})
+	}
+	messages := readUntil(t, client, "speaking_end")
+
+	streams := stt.Streams()
+	if len(streams) != 1 || !streams[0].Ended() {
+		t.Fatalf("Expected one ended transcription stream, got %d", len(streams))
+	}
+	return messages, streams[0].Chunks()
+}
+
+func TestClient_FinalFlagEndsListeningAsListeningEnd(t *testing.T) {
+	flagMessages, flagChunks := headeredTurn(t, HubConfig{}, true)
+	controlMessages, controlChunks := headeredTurn(t, HubConfig{}, false)
+
+	if strings.Join(flagMessages, ",") != strings.Join(controlMessages, ",") {
+		t.Errorf("Expected the same messages, got %v with the flag and %v with listening_end", flagMessages, controlMessages)
+	}
+	if len(flagChunks) != len(controlChunks) {
+		t.Fatalf("Expected the same audio transcribed, got %v and %v", flagChunks, controlChunks)
+	}
+	for i := range flagChunks {
+		if !bytes.Equal(flagChunks[i], controlChunks[i]) {
+			t.Errorf("Expected the same audio transcribed, got %v and %v", flagChunks, controlChunks)
+		}
+	}
+}
+
+func TestClient_IgnoresListeningEndAfterFinalFlag(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{Transcript: "halo"}, &conversationtest.TTS{Chunks: [][]byte{{0xAA}}})
+	client := newTestClient(hub, "device-1")
+	client.handleHello(map[string]interface{}{"type": "hello", "audio_header": true})
+	if msg := readMessage(t, client, "hello"); msg["audio_final"] != AudioFinalFlag {
+		t.Fatalf("Expected the final flag announced in the hello, got %v", msg)
+	}
+
+	client.processBinaryAudioChunk(appendAudioHeader(audioHeader{Sequence: 1, Flags: audioFlagFinal}, []byte{0x01}))
+	readUntil(t, client, "speaking_end")
+	// Firmware marking both the final chunk and sending listening_end
+	client.handleListeningEnd(map[string]interface{}{"type": "listening_end"})
+	select {
+	case data := <-client.send:
+		t.Errorf("Expected no message for the listening_end after the final flag, got %s", data.Payload)
+	default:
+	}
+
+	// Only the first listening_end after the flag is ignored
+	runTestTurn(t, client)
+	client.handleListeningEnd(map[string]interface{}{"type": "listening_end"})
+	if msg := readMessage(t, client, "listening_end"); msg["error"] != "not listening" {
+		t.Errorf("Expected a listening_end without listening to be refused, got %v", msg)
+	}
+}
+
+func TestClient_IgnoresFinalFlagWithControlAudioFinal(t *testing.T) {
+	stt := &conversationtest.STT{Transcript: "halo"}
+	hub := newTestHub(t, conversation.Config{}, stt, &conversationtest.TTS{})
+	hub.config.AudioFinal = AudioFinalControl
+	client := newTestClient(hub, "device-1")
+	client.handleHello(map[string]interface{}{"type": "hello", "audio_header": true})
+	readMessage(t, client, "hello")
+
+	client.processBinaryAudioChunk(appendAudioHeader(audioHeader{Sequence: 1, Flags: audioFlagFinal}, []byte{0x01}))
+	readMessage(t, client, "listening_start")
+	if !client.conversation.Listening() || stt.Streams()[0].Ended() {
+		t.Fatal("Expected the final flag to leave listening going")
+	}
+
+	messages, chunks := headeredTurn(t, HubConfig{AudioFinal: AudioFinalControl}, false)
+	if len(chunks) != 2 || messages[len(messages)-1] != "speaking_end" {
+		t.Errorf("Expected listening_end to end the utterance, got %v with %v", messages, chunks)
+	}
+}
@@ -0,0 +1,120 @@
+package websocket
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+)
+
+// A broadcast sends a system message to the connected devices a selector
+// picks. Every connection keeps the record of its device from the moment it
+// registers, so a broadcast selects its devices in a single pass over the
+// connections, without reading the device repository.
+
+// DeviceSelector picks devices by their records. Every field set must match;
+// the empty selector picks every connected device.
+type DeviceSelector struct {
+	Model           string
+	OwnerID         string
+	FirmwareVersion string
+}
+
+// matches reports whether the selector picks device. Devices that are not
+// registered, nil here, are only picked by the empty selector.
+func (s DeviceSelector) matches(device *entities.Device) bool {
+	if s == (DeviceSelector{}) {
+		return true
+	}
+	if device == nil {
+		return false
+	}
+	if s.Model != "" && device.Model != s.Model {
+		return false
+	}
+	if s.OwnerID != "" && (device.OwnerID == nil || *device.OwnerID != s.OwnerID) {
+		return false
+	}
+	if s.FirmwareVersion != "" && device.FirmwareVersion != s.FirmwareVersion {
+		return false
+	}
+	return true
+}
+
+// SystemMessage is a notice from the operators to devices, such as an
+// announcement of maintenance
+type SystemMessage struct {
+	// Kind lets the device tell notices apart, e.g. "maintenance"; empty for
+	// a plain notice
+	Kind string
+	Text string
+}
+
+// Broadcast sends message to the connected devices selector picks and
+// returns how many it was sent to
+func (h *Hub) Broadcast(selector DeviceSelector, message SystemMessage) int {
+	h.mu.RLock()
+	var targets []*Client
+	for _, client := range h.clients {
+		if selector.matches(client.deviceRecord()) {
+			targets = append(targets, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	payload := map[string]interface{}{
+		"type":      "system_message",
+		"text":      message.Text,
+		"timestamp": h.now().Unix(),
+	}
+	if message.Kind != "" {
+		payload["kind"] = message.Kind
+	}
+	for _, client := range targets {
+		client.sendControl(payload)
+	}
+
+	h.logger.Info("Broadcast system message",
+		zap.String("model", selector.Model),
+		zap.String("ownerID", selector.OwnerID),
+		zap.String("firmwareVersion", selector.FirmwareVersion),
+		zap.String("kind", message.Kind),
+		zap.Int("devices", len(targets)))
+	return len(targets)
+}
+
+// setDeviceRecord keeps the record of the device of this connection, whose
+// model is the fleet the audio quality of the conversation is aggregated in
+func (c *Client) setDeviceRecord(device *entities.Device) {
+	c.deviceMu.Lock()
+	c.device = device
+	c.deviceMu.Unlock()
+	if device != nil {
+		c.conversation.SetDeviceModel(device.Model)
+	}
+}
+
+// deviceRecord returns the record of the device of this connection, nil for
+// devices that are not registered
+func (c *Client) deviceRecord() *entities.Device {
+	c.deviceMu.Lock()
+	defer c.deviceMu.Unlock()
+	return c.device
+}
+
+// recordFirmwareVersion stores the firmware version the device announced in
+// its hello, when it changed. Broadcasts pick the device by it right away.
+func (c *Client) recordFirmwareVersion(version string) {
+	if device := c.deviceRecord(); device != nil {
+		if device.FirmwareVersion == version {
+			return
+		}
+		announced := *device
+		announced.FirmwareVersion = version
+		c.setDeviceRecord(&announced)
+	}
+	c.hub.queuePresence(c.deviceID, func(device *entities.Device) {
+		device.FirmwareVersion = version
+		recorded := *device
+		c.setDeviceRecord(&recorded)
+	})
+}
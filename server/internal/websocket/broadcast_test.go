@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// connectDevice registers a device with the hub and connects a client for it
+func connectDevice(t *testing.T, hub *Hub, device *entities.Device) *Client {
+	t.Helper()
+	if err := hub.devices.Create(context.Background(), device); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	client := newTestClient(hub, device.ID)
+	hub.register <- client
+	return client
+}
+
+// waitRegistered waits until the hub registered every client
+func waitRegistered(t *testing.T, hub *Hub, clients ...*Client) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for _, client := range clients {
+		for client.deviceRecord() == nil {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %s to register", client.deviceID)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+// received reports whether the client was sent a system message
+func received(c *Client) (map[string]interface{}, bool) {
+	for {
+		select {
+		case data := <-c.send:
+			var msg map[string]interface{}
+			if json.Unmarshal(data.Payload, &msg) == nil && msg["type"] == "system_message" {
+				return msg, true
+			}
+		default:
+			return nil, false
+		}
+	}
+}
+
+func TestHub_BroadcastsToTheDevicesOfAModel(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{}, &conversationtest.TTS{})
+	go hub.Run()
+
+	owner := "owner-1"
+	v2 := connectDevice(t, hub, &entities.Device{SerialNumber: "ARU-0001", Model: "doll-v2"})
+	v2Owned := connectDevice(t, hub, &entities.Device{SerialNumber: "ARU-0002", Model: "doll-v2", OwnerID: &owner})
+	v1 := connectDevice(t, hub, &entities.Device{SerialNumber: "ARU-0003", Model: "doll-v1", OwnerID: &owner})
+	waitRegistered(t, hub, v2, v2Owned, v1)
+
+	notice := SystemMessage{Kind: "maintenance", Text: "Pembaruan malam ini"}
+	if delivered := hub.Broadcast(DeviceSelector{Model: "doll-v2"}, notice); delivered != 2 {
+		t.Errorf("Expected the notice delivered to 2 devices, got %d", delivered)
+	}
+	for _, client := range []*Client{v2, v2Owned} {
+		msg, ok := received(client)
+		if !ok {
+			t.Fatalf("Expected %s to receive the notice", client.deviceID)
+		}
+		if msg["text"] != notice.Text || msg["kind"] != notice.Kind {
+			t.Errorf("Unexpected system message %v", msg)
+		}
+	}
+	if _, ok := received(v1); ok {
+		t.Error("Expected a device of another model not to receive the notice")
+	}
+
+	if delivered := hub.Broadcast(DeviceSelector{Model: "doll-v2", OwnerID: owner}, notice); delivered != 1 {
+		t.Errorf("Expected a model and owner selector to pick 1 device, got %d", delivered)
+	}
+	if delivered := hub.Broadcast(DeviceSelector{}, notice); delivered != 3 {
+		t.Errorf("Expected the empty selector to pick every device, got %d", delivered)
+	}
+}
+
+func TestHub_BroadcastsByAnnouncedFirmwareVersion(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{}, &conversationtest.TTS{})
+	go hub.Run()
+
+	updated := connectDevice(t, hub, &entities.Device{SerialNumber: "ARU-0001", Model: "doll-v2"})
+	outdated := connectDevice(t, hub, &entities.Device{SerialNumber: "ARU-0002", Model: "doll-v2"})
+	waitRegistered(t, hub, updated, outdated)
+	updated.handleHello(map[string]interface{}{"type": "hello", "firmware_version": "2.1.0"})
+	outdated.handleHello(map[string]interface{}{"type": "hello", "firmware_version": "2.0.3"})
+
+	if delivered := hub.Broadcast(DeviceSelector{FirmwareVersion: "2.0.3"}, SystemMessage{Text: "Perbarui bonekamu"}); delivered != 1 {
+		t.Fatalf("Expected 1 device on the firmware, got %d", delivered)
+	}
+	if _, ok := received(outdated); !ok {
+		t.Error("Expected the device on the firmware to receive the notice")
+	}
+	waitPresenceStored(t, hub)
+	if stored, _ := hub.devices.GetByID(context.Background(), updated.deviceID); stored.FirmwareVersion != "2.1.0" {
+		t.Errorf("Expected the announced firmware version to be stored, got %q", stored.FirmwareVersion)
+	}
+}
@@ -0,0 +1,39 @@
+package websocket
+
+import "github.com/satriahrh/arunika/server/internal/conversation"
+
+// Every speaking_start advises the device how much of the response audio to
+// buffer before it starts playing, so that playback does not run dry while
+// the rest is still being synthesized. A response whose audio was
+// synthesized already, such as a cached one, has a known length; the length
+// of others is estimated from their text. Short responses need no more
+// buffer than their whole audio. When the length cannot be told, as for a
+// pipelined response, twice the usual buffer is advised.
+
+// bufferingHint returns the buffering advice of a response whose audio is
+// expected to be estimate, nil when it is unknown
+func (h *Hub) bufferingHint(estimate *conversation.AudioEstimate) map[string]interface{} {
+	prebuffer := h.config.PlaybackPrebuffer
+	if estimate == nil {
+		return map[string]interface{}{
+			"estimate":     "unknown",
+			"prebuffer_ms": (2 * prebuffer).Milliseconds(),
+		}
+	}
+
+	kind := "estimated"
+	if estimate.Known {
+		kind = "known"
+	}
+	if estimate.Duration < prebuffer {
+		prebuffer = estimate.Duration
+	}
+	samples := int(prebuffer.Seconds() * float64(estimate.SampleRate))
+	return map[string]interface{}{
+		"estimate":        kind,
+		"audio_ms":        estimate.Duration.Milliseconds(),
+		"audio_bytes":     estimate.Bytes,
+		"prebuffer_ms":    prebuffer.Milliseconds(),
+		"prebuffer_bytes": min(2*samples, estimate.Bytes),
+	}
+}
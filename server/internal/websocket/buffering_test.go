@@ -0,0 +1,80 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// speakingBuffering runs a turn of c and returns the buffering hint of its
+// speaking_start
+func speakingBuffering(t *testing.T, c *Client) map[string]interface{} {
+	t.Helper()
+	c.handleListeningStart(map[string]interface{}{"type": "listening_start"})
+	readUntil(t, c, "listening_start")
+	c.processBinaryAudioChunk([]byte{0x01, 0x02})
+	c.handleListeningEnd(map[string]interface{}{"type": "listening_end"})
+	msg := readMessage(t, c, "speaking_start")
+	readUntil(t, c, "speaking_end")
+	buffering, ok := msg["buffering"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected a buffering hint in speaking_start, got %v", msg)
+	}
+	return buffering
+}
+
+func TestClient_HintsBufferingOfEstimatedAndCachedResponses(t *testing.T) {
+	// 100ms of 16-bit audio at the default 24 kHz per chunk
+	chunk := make([]byte, 4800)
+	tts := &conversationtest.TTS{Chunks: [][]byte{chunk, chunk}}
+	hub := newTestHub(t, conversation.Config{ResponseCache: true}, &conversationtest.STT{Transcript: "halo"}, tts)
+	client := newTestClient(hub, "device-1")
+
+	// "Halo juga!" is estimated from its ten characters
+	estimated := speakingBuffering(t, client)
+	if estimated["estimate"] != "estimated" || estimated["prebuffer_ms"] != float64(300) {
+		t.Errorf("Expected an estimate with the default prebuffer, got %v", estimated)
+	}
+	if ms, _ := estimated["audio_ms"].(float64); ms < 500 || ms > 1000 {
+		t.Errorf("Expected an estimate of a short sentence, got %v ms", estimated["audio_ms"])
+	}
+	// 48 bytes of audio per millisecond at 24 kHz
+	if bytes, _ := estimated["audio_bytes"].(float64); bytes < estimated["audio_ms"].(float64)*48 || bytes > (estimated["audio_ms"].(float64)+1)*48 {
+		t.Errorf("Expected the estimated bytes at 24 kHz, got %v", estimated["audio_bytes"])
+	}
+
+	// The repeated utterance replays the cached audio, whose size is known
+	known := speakingBuffering(t, client)
+	want := map[string]interface{}{
+		"estimate":        "known",
+		"audio_ms":        float64(200),
+		"audio_bytes":     float64(9600),
+		"prebuffer_ms":    float64(200),
+		"prebuffer_bytes": float64(9600),
+	}
+	for key, value := range want {
+		if known[key] != value {
+			t.Errorf("Expected %s %v, got %v", key, value, known[key])
+		}
+	}
+}
+
+func TestHub_BufferingHintWithoutEstimateIsConservative(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{}, &conversationtest.TTS{})
+	hub.config.PlaybackPrebuffer = 250 * time.Millisecond
+
+	hint := hub.bufferingHint(nil)
+	if hint["estimate"] != "unknown" || hint["prebuffer_ms"] != int64(500) {
+		t.Errorf("Expected twice the prebuffer for an unknown length, got %v", hint)
+	}
+	if _, ok := hint["audio_ms"]; ok {
+		t.Errorf("Expected no audio length when it is unknown, got %v", hint)
+	}
+
+	hint = hub.bufferingHint(&conversation.AudioEstimate{Duration: 2 * time.Second, Bytes: 64000, SampleRate: 16000})
+	if hint["prebuffer_ms"] != int64(250) || hint["prebuffer_bytes"] != 8000 {
+		t.Errorf("Expected the configured prebuffer of a long response, got %v", hint)
+	}
+}
@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// Run with -race: devices churning through utterances and disconnecting
+// while the doll is answering must not leave goroutines or clients behind
+func TestHub_ChurnLeavesNoResponseGoroutines(t *testing.T) {
+	// The connections outlive the test's logger, so log nowhere
+	logger := zap.NewNop()
+	// Responses are still being synthesized when the devices disconnect
+	tts := &conversationtest.TTS{Chunks: [][]byte{{0xAA}}, Delays: map[string]time.Duration{"Halo juga!": time.Minute}}
+	engine, err := conversation.NewEngine(conversation.Config{}, &conversationtest.LLM{Reply: "Halo juga!"}, tts,
+		&conversationtest.STT{Transcript: "halo boneka"}, &conversationtest.SessionRepository{},
+		adapters.NewMemoryChildRepository(), adapters.NewMemoryQuietHoursRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub, err := NewHub(HubConfig{}, engine, adapters.NewMemoryDeviceRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go hub.Run()
+
+	e := echo.New()
+	e.GET("/ws", func(c echo.Context) error {
+		return HandleWebSocketWithAuth(hub, c, c.QueryParam("device"), logger)
+	})
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?device="
+	baseline := runtime.NumGoroutine()
+
+	write := func(conn *websocket.Conn, messageType int, data string) {
+		t.Helper()
+		if err := conn.WriteMessage(messageType, []byte(data)); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(url+fmt.Sprintf("device-%d", i%4), nil)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		// Each utterance ends before the doll answered the previous one
+		for turn := 0; turn < 3; turn++ {
+			write(conn, websocket.TextMessage, `{"type":"listening_start"}`)
+			readControl(t, conn, "listening_start")
+			write(conn, websocket.BinaryMessage, "\x01")
+			write(conn, websocket.TextMessage, `{"type":"listening_end"}`)
+		}
+		conn.UnderlyingConn().Close()
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		hub.mu.RLock()
+		clients := len(hub.clients)
+		hub.mu.RUnlock()
+		goroutines := runtime.NumGoroutine()
+		// Allow for connections the server is still tearing down
+		if clients == 0 && goroutines <= baseline+2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected no clients and at most %d goroutines, got %d clients and %d goroutines", baseline+2, clients, goroutines)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n := tts.MaxConcurrent(); n > 4 {
+		t.Errorf("Expected at most one response per device at a time, got %d syntheses at once", n)
+	}
+}
@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// CloseReason is the machine-readable reason sent to a device before the
+// server closes its connection. The taxonomy is documented in
+// docs/websocket-close-reasons.md.
+type CloseReason string
+
+const (
+	// Transient reasons: the device should reconnect after retry_after_ms
+	CloseSTTUnavailable CloseReason = "stt_unavailable"
+	CloseServerShutdown CloseReason = "server_shutdown"
+	// CloseAudioBufferExceeded ends a connection that sent more audio than
+	// speech-to-text accepted within the audio buffer budget
+	CloseAudioBufferExceeded CloseReason = "audio_buffer_exceeded"
+
+	// Permanent reasons: the device must not reconnect with the same credentials
+	CloseConnectionReplaced CloseReason = "connection_replaced"
+
+	// CloseIdle ends a connection without conversational activity. The device
+	// may reconnect as soon as the child interacts with it again.
+	CloseIdle CloseReason = "idle"
+
+	// CloseMessageTooBig ends a connection that sent a message beyond
+	// maxMessageSize. The device may reconnect at once once it sends smaller
+	// messages.
+	CloseMessageTooBig CloseReason = "message_too_big"
+)
+
+// Transient reports whether the device may reconnect after a delay
+func (r CloseReason) Transient() bool {
+	switch r {
+	case CloseSTTUnavailable, CloseServerShutdown, CloseAudioBufferExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryAfter returns the reconnect delay suggested for reason, or zero when
+// the device must not retry
+func (h *Hub) retryAfter(reason CloseReason) time.Duration {
+	if reason.Transient() {
+		return h.config.RetryAfter
+	}
+	return 0
+}
+
+// closeWith sends a final "close" control message carrying the reason and
+// retry guidance, followed by a WebSocket close frame
+func (c *Client) closeWith(reason CloseReason) {
+	retryAfter := c.hub.retryAfter(reason)
+
+	payload := map[string]interface{}{
+		"type":   "close",
+		"reason": string(reason),
+		"retry":  reason.Transient() || reason == CloseIdle || reason == CloseMessageTooBig,
+	}
+	closeCode := websocket.ClosePolicyViolation
+	switch {
+	case reason.Transient():
+		payload["retry_after_ms"] = retryAfter.Milliseconds()
+		closeCode = websocket.CloseTryAgainLater
+		if reason == CloseServerShutdown {
+			closeCode = websocket.CloseServiceRestart
+		}
+	case reason == CloseIdle:
+		closeCode = websocket.CloseNormalClosure
+	case reason == CloseMessageTooBig:
+		payload["max_message_bytes"] = maxMessageSize
+		closeCode = websocket.CloseMessageTooBig
+	}
+	c.sendControl(payload)
+
+	c.closeMu.Lock()
+	c.closeReason = reason
+	c.closeCode = closeCode
+	c.closeMu.Unlock()
+
+	c.queue(WriteData{
+		Type:    websocket.CloseMessage,
+		Payload: websocket.FormatCloseMessage(closeCode, string(reason)),
+	})
+
+	c.logger.Info("Closing device connection",
+		zap.String("deviceID", c.deviceID),
+		zap.String("reason", string(reason)),
+		zap.Duration("retryAfter", retryAfter))
+}
@@ -0,0 +1,107 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// readClose returns the final close control message and asserts that a close
+// frame follows it
+func readClose(t *testing.T, c *Client) map[string]interface{} {
+	t.Helper()
+	msg := readMessage(t, c, "close")
+	select {
+	case data := <-c.send:
+		if data.Type != websocket.CloseMessage {
+			t.Fatalf("Expected a close frame after the close message, got type %d", data.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the close frame")
+	}
+	return msg
+}
+
+func TestClient_CloseWithTransientReason(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{}, &conversationtest.TTS{})
+	hub.config.RetryAfter = 1500 * time.Millisecond
+	client := newTestClient(hub, "device-1")
+
+	client.closeWith(CloseSTTUnavailable)
+	msg := readClose(t, client)
+
+	if msg["reason"] != "stt_unavailable" || msg["retry"] != true {
+		t.Errorf("Unexpected close message: %v", msg)
+	}
+	if msg["retry_after_ms"] != float64(1500) {
+		t.Errorf("Expected retry_after_ms 1500, got %v", msg["retry_after_ms"])
+	}
+}
+
+func TestClient_CloseWithPermanentReason(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{}, &conversationtest.TTS{})
+	client := newTestClient(hub, "device-1")
+
+	client.closeWith(CloseConnectionReplaced)
+	msg := readClose(t, client)
+
+	if msg["reason"] != "connection_replaced" || msg["retry"] != false {
+		t.Errorf("Unexpected close message: %v", msg)
+	}
+	if retryAfter, ok := msg["retry_after_ms"]; ok && retryAfter != float64(0) {
+		t.Errorf("Expected no retry hint for a permanent close, got %v", retryAfter)
+	}
+}
+
+func TestClient_ClosesWhenAudioBufferExceeded(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{}, &conversationtest.TTS{})
+	client := newTestClient(hub, "device-1")
+
+	client.Emit(conversation.Event{Type: conversation.EventError, Error: conversation.ErrorAudioBufferExceeded})
+	msg := readClose(t, client)
+
+	if msg["reason"] != "audio_buffer_exceeded" || msg["retry"] != true {
+		t.Errorf("Unexpected close message: %v", msg)
+	}
+}
+
+func TestClient_ClosesOversizedMessageWithTheLimit(t *testing.T) {
+	f := newAudioChannelFixture(t, HubConfig{})
+	conn, _, err := f.dial(t, "/ws/device-1")
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	// The limit is announced before any audio is sent
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"hello"}`)); err != nil {
+		t.Fatalf("Failed to send hello: %v", err)
+	}
+	if reply := readControl(t, conn, "hello"); reply["max_message_bytes"] != float64(maxMessageSize) {
+		t.Errorf("Expected the hello to announce the maximum message size, got %v", reply)
+	}
+
+	if err := conn.WriteMessage(websocket.BinaryMessage, make([]byte, maxMessageSize+1)); err != nil {
+		t.Fatalf("Failed to send the oversized message: %v", err)
+	}
+	msg := readControl(t, conn, "close")
+	if msg["reason"] != string(CloseMessageTooBig) || msg["retry"] != true {
+		t.Errorf("Unexpected close message: %v", msg)
+	}
+	if msg["max_message_bytes"] != float64(maxMessageSize) {
+		t.Errorf("Expected the close message to tell the limit, got %v", msg["max_message_bytes"])
+	}
+	if _, ok := msg["retry_after_ms"]; ok {
+		t.Errorf("Expected no retry delay, got %v", msg["retry_after_ms"])
+	}
+
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseMessageTooBig || closeErr.Text != string(CloseMessageTooBig) {
+		t.Errorf("Expected a close frame with code 1009 and the reason, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package websocket
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetryAfter        = 5 * time.Second
+	defaultIdleTimeout       = 15 * time.Minute
+	defaultReadinessInterval = 30 * time.Second
+	defaultReadinessFailures = 3
+	defaultPlaybackPrebuffer = 300 * time.Millisecond
+	defaultAudioFinal        = AudioFinalFlag
+
+	defaultAdaptiveAudioProbeInterval = 5 * time.Second
+	defaultAdaptiveAudioHighRTT       = 300 * time.Millisecond
+	defaultAdaptiveAudioLowRTT        = 100 * time.Millisecond
+	defaultAdaptiveAudioMaxJitter     = 100 * time.Millisecond
+)
+
+// Ways headered audio ends an utterance
+const (
+	// AudioFinalFlag ends it with the final flag of its last chunk, or a listening_end
+	AudioFinalFlag = "flag"
+	// AudioFinalControl ends it with a listening_end only, ignoring the final flag
+	AudioFinalControl = "control"
+)
+
+// HubConfig holds configuration for the WebSocket hub
+// Optional fields with defaults:
+// - RetryAfter: Reconnect delay suggested to devices after a transient close (default: 5s)
+// - IdleTimeout: Time without conversational activity before a connection is closed (default: 15m)
+// - PreRollMax: Most recent audio kept from before listening starts and sent to speech-to-text first (default: 0, disabled)
+// - AudioChannel: Offer devices a second connection carrying the response audio (default: false)
+// - ReadinessInterval: Time between dependency health checks once connections are accepted (default: 30s)
+// - ReadinessFailures: Failed health checks in a row before connections are refused again (default: 3)
+// - AudioDropTimeout: Time a response audio frame may wait for room in a full send queue before it is dropped (default: 0, never dropped)
+// - AudioDropNotice: Tell devices with a speaking_degraded message when response audio was dropped (default: false)
+// - EventLogSampleRate: Fraction of connections, between 0 and 1, whose protocol events are logged (default: 0, none)
+// - PlaybackPrebuffer: Response audio devices are advised in speaking_start to buffer before playing, doubled when its length is unknown (default: 300ms)
+// - AudioFinal: How headered audio ends an utterance, "flag" for the final flag of its last chunk or a listening_end, "control" for a listening_end only (default: "flag")
+// - AdaptiveAudio: Measure the round trip time of connections and lower the output sample rate on poor links (default: false)
+// - AdaptiveAudioProbeInterval: Time between the pings measuring the round trip time, at most the ping period (default: 5s)
+// - AdaptiveAudioHighRTT: Smoothed round trip time from which a link is poor (default: 300ms)
+// - AdaptiveAudioLowRTT: Smoothed round trip time below which a poor link is good again (default: 100ms)
+// - AdaptiveAudioMaxJitter: Round trip time jitter from which a link is poor (default: 100ms)
+// - AllowedOrigins: Origins of the pages that may connect, exact ("https://dashboard.arunika.app") or subdomains ("*.arunika.app"), requests without an Origin always may (default: none, same host only)
+type HubConfig struct {
+	RetryAfter         time.Duration // Optional: Reconnect delay suggested to devices after a transient close
+	IdleTimeout        time.Duration // Optional: Time without conversational activity before a connection is closed
+	PreRollMax         time.Duration // Optional: Most recent audio kept from before listening starts
+	AudioChannel       bool          // Optional: Offer devices a second connection carrying the response audio
+	ReadinessInterval  time.Duration // Optional: Time between dependency health checks once connections are accepted
+	ReadinessFailures  int           // Optional: Failed health checks in a row before connections are refused again
+	AudioDropTimeout   time.Duration // Optional: Time a response audio frame may wait in a full send queue before it is dropped
+	AudioDropNotice    bool          // Optional: Tell devices with a speaking_degraded message when response audio was dropped
+	EventLogSampleRate float64       // Optional: Fraction of connections whose protocol events are logged
+	PlaybackPrebuffer  time.Duration // Optional: Response audio devices are advised to buffer before playing
+	AudioFinal         string        // Optional: How headered audio ends an utterance, AudioFinalFlag or AudioFinalControl
+
+	AdaptiveAudio              bool          // Optional: Lower the output sample rate on poor links
+	AdaptiveAudioProbeInterval time.Duration // Optional: Time between the pings measuring the round trip time
+	AdaptiveAudioHighRTT       time.Duration // Optional: Smoothed round trip time from which a link is poor
+	AdaptiveAudioLowRTT        time.Duration // Optional: Smoothed round trip time below which a poor link is good again
+	AdaptiveAudioMaxJitter     time.Duration // Optional: Round trip time jitter from which a link is poor
+
+	AllowedOrigins []string // Optional: Origins of the pages that may connect
+}
+
+// NewHubConfigFromEnv creates a new HubConfig from environment variables
+// This is a helper function to simplify the creation of a properly configured HubConfig
+func NewHubConfigFromEnv() HubConfig {
+	config := HubConfig{}
+
+	if retryStr := os.Getenv("WEBSOCKET_RETRY_AFTER_MS"); retryStr != "" {
+		if retry, err := strconv.Atoi(retryStr); err == nil && retry > 0 {
+			config.RetryAfter = time.Duration(retry) * time.Millisecond
+		}
+	}
+
+	if idleStr := os.Getenv("WEBSOCKET_IDLE_TIMEOUT_SECONDS"); idleStr != "" {
+		if idle, err := strconv.Atoi(idleStr); err == nil && idle > 0 {
+			config.IdleTimeout = time.Duration(idle) * time.Second
+		}
+	}
+
+	if preRollStr := os.Getenv("WEBSOCKET_PRE_ROLL_MAX_MS"); preRollStr != "" {
+		if preRoll, err := strconv.Atoi(preRollStr); err == nil && preRoll > 0 {
+			config.PreRollMax = time.Duration(preRoll) * time.Millisecond
+		}
+	}
+
+	if channelStr := os.Getenv("WEBSOCKET_AUDIO_CHANNEL_ENABLED"); channelStr != "" {
+		if enabled, err := strconv.ParseBool(channelStr); err == nil {
+			config.AudioChannel = enabled
+		}
+	}
+
+	if intervalStr := os.Getenv("WEBSOCKET_READINESS_INTERVAL_SECONDS"); intervalStr != "" {
+		if interval, err := strconv.Atoi(intervalStr); err == nil && interval > 0 {
+			config.ReadinessInterval = time.Duration(interval) * time.Second
+		}
+	}
+
+	if failuresStr := os.Getenv("WEBSOCKET_READINESS_FAILURES"); failuresStr != "" {
+		if failures, err := strconv.Atoi(failuresStr); err == nil && failures > 0 {
+			config.ReadinessFailures = failures
+		}
+	}
+
+	if dropStr := os.Getenv("WEBSOCKET_AUDIO_DROP_TIMEOUT_MS"); dropStr != "" {
+		if drop, err := strconv.Atoi(dropStr); err == nil && drop > 0 {
+			config.AudioDropTimeout = time.Duration(drop) * time.Millisecond
+		}
+	}
+
+	if noticeStr := os.Getenv("WEBSOCKET_AUDIO_DROP_NOTICE_ENABLED"); noticeStr != "" {
+		if enabled, err := strconv.ParseBool(noticeStr); err == nil {
+			config.AudioDropNotice = enabled
+		}
+	}
+
+	if sampleStr := os.Getenv("WEBSOCKET_EVENT_LOG_SAMPLE_RATE"); sampleStr != "" {
+		if rate, err := strconv.ParseFloat(sampleStr, 64); err == nil && rate >= 0 && rate <= 1 {
+			config.EventLogSampleRate = rate
+		}
+	}
+
+	if prebufferStr := os.Getenv("WEBSOCKET_PLAYBACK_PREBUFFER_MS"); prebufferStr != "" {
+		if prebuffer, err := strconv.Atoi(prebufferStr); err == nil && prebuffer > 0 {
+			config.PlaybackPrebuffer = time.Duration(prebuffer) * time.Millisecond
+		}
+	}
+
+	config.AudioFinal = os.Getenv("WEBSOCKET_AUDIO_FINAL")
+
+	if adaptiveStr := os.Getenv("WEBSOCKET_ADAPTIVE_AUDIO_ENABLED"); adaptiveStr != "" {
+		if enabled, err := strconv.ParseBool(adaptiveStr); err == nil {
+			config.AdaptiveAudio = enabled
+		}
+	}
+
+	if probeStr := os.Getenv("WEBSOCKET_ADAPTIVE_AUDIO_PROBE_INTERVAL_SECONDS"); probeStr != "" {
+		if probe, err := strconv.Atoi(probeStr); err == nil && probe > 0 {
+			config.AdaptiveAudioProbeInterval = time.Duration(probe) * time.Second
+		}
+	}
+
+	if highStr := os.Getenv("WEBSOCKET_ADAPTIVE_AUDIO_HIGH_RTT_MS"); highStr != "" {
+		if high, err := strconv.Atoi(highStr); err == nil && high > 0 {
+			config.AdaptiveAudioHighRTT = time.Duration(high) * time.Millisecond
+		}
+	}
+
+	if lowStr := os.Getenv("WEBSOCKET_ADAPTIVE_AUDIO_LOW_RTT_MS"); lowStr != "" {
+		if low, err := strconv.Atoi(lowStr); err == nil && low > 0 {
+			config.AdaptiveAudioLowRTT = time.Duration(low) * time.Millisecond
+		}
+	}
+
+	if jitterStr := os.Getenv("WEBSOCKET_ADAPTIVE_AUDIO_MAX_JITTER_MS"); jitterStr != "" {
+		if jitter, err := strconv.Atoi(jitterStr); err == nil && jitter > 0 {
+			config.AdaptiveAudioMaxJitter = time.Duration(jitter) * time.Millisecond
+		}
+	}
+
+	if originsStr := os.Getenv("WEBSOCKET_ALLOWED_ORIGINS"); originsStr != "" {
+		config.AllowedOrigins = strings.Split(originsStr, ",")
+	}
+
+	return config
+}
@@ -0,0 +1,35 @@
+package websocket
+
+import (
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/internal/conversation"
+)
+
+// HeaderDeterministic asks for the deterministic speech services on a
+// connection. It is ignored unless deterministic mode is enabled.
+const HeaderDeterministic = "X-Arunika-Deterministic"
+
+// EnableDeterministicMode lets connections opt in to engine, which should
+// use deterministic speech services, by sending HeaderDeterministic. Other
+// connections of the same server keep the real services. Only meant for
+// integration testing; call it before the hub serves connections.
+func (h *Hub) EnableDeterministicMode(engine *conversation.Engine) {
+	h.deterministicEngine = engine
+}
+
+// engineFor returns the engine serving the connection requested with r
+func (h *Hub) engineFor(r *http.Request, deviceID string) *conversation.Engine {
+	if h.deterministicEngine == nil {
+		return h.engine
+	}
+	if deterministic, _ := strconv.ParseBool(r.Header.Get(HeaderDeterministic)); !deterministic {
+		return h.engine
+	}
+	h.logger.Info("Serving connection with deterministic speech services",
+		zap.String("deviceID", deviceID))
+	return h.deterministicEngine
+}
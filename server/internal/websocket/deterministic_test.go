@@ -0,0 +1,155 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/adapters/deterministic"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// turnMessage is a message received during a turn; binary frames have the type "binary"
+type turnMessage struct {
+	Type string
+	Chat string
+	Size int
+}
+
+// runSocketTurn plays one turn over conn and returns the messages received
+// up to and including speaking_end
+func runSocketTurn(t *testing.T, conn *websocket.Conn) []turnMessage {
+	t.Helper()
+	for _, msg := range []string{`{"type":"listening_start"}`, "", `{"type":"listening_end"}`} {
+		messageType, data := websocket.TextMessage, []byte(msg)
+		if msg == "" {
+			messageType, data = websocket.BinaryMessage, make([]byte, 320)
+		}
+		if err := conn.WriteMessage(messageType, data); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+
+	var received []turnMessage
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read after %v: %v", received, err)
+		}
+		if messageType == websocket.BinaryMessage {
+			received = append(received, turnMessage{Type: "binary", Size: len(data)})
+			continue
+		}
+		var msg struct {
+			Type string `json:"type"`
+			Chat struct {
+				Content string `json:"content"`
+			} `json:"chat"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("Failed to decode %s: %v", data, err)
+		}
+		received = append(received, turnMessage{Type: msg.Type, Chat: msg.Chat.Content})
+		if msg.Type == "speaking_end" {
+			return received
+		}
+	}
+}
+
+func TestHub_DeterministicModeFullLoop(t *testing.T) {
+	// The connections outlive the test's logger, so log nowhere
+	logger := zap.NewNop()
+	bus := events.NewBus(logger)
+	children := adapters.NewMemoryChildRepository()
+	quietHours := adapters.NewMemoryQuietHoursRepository()
+	sessions := &conversationtest.SessionRepository{}
+	llm := &conversationtest.LLM{Reply: "Halo juga!"}
+
+	engine, err := conversation.NewEngine(conversation.Config{}, llm, &conversationtest.TTS{Chunks: [][]byte{{0xAA}}}, &conversationtest.STT{Transcript: "real"}, sessions, children, quietHours, bus, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deterministicConfig := deterministic.Config{Enabled: true, Transcript: "tes", AudioDuration: 100 * time.Millisecond}
+	deterministicEngine, err := conversation.NewEngine(conversation.Config{DemoMode: true, DemoPhrase: "fixed"}, llm,
+		deterministic.NewTextToSpeech(deterministicConfig, logger), deterministic.NewSpeechToText(deterministicConfig, logger),
+		sessions, children, quietHours, bus, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hub, err := NewHub(HubConfig{}, engine, adapters.NewMemoryDeviceRepository(), bus, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub.EnableDeterministicMode(deterministicEngine)
+	go hub.Run()
+
+	e := echo.New()
+	e.GET("/ws/:device", func(c echo.Context) error {
+		return HandleWebSocketWithAuth(hub, c, c.Param("device"), logger)
+	})
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	dial := func(deviceID string, header http.Header) *websocket.Conn {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws/"+deviceID, header)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		return conn
+	}
+	fake := dial("device-ci", http.Header{HeaderDeterministic: []string{"true"}})
+	defer fake.Close()
+	regular := dial("device-real", nil)
+	defer regular.Close()
+
+	for turn := 0; turn < 2; turn++ {
+		received := runSocketTurn(t, fake)
+
+		// 100ms of 16-bit silence at 24kHz, streamed in 1024 byte chunks
+		want := []turnMessage{{Type: "listening_start"}, {Type: "listening_end", Chat: "tes"}, {Type: "speaking_start", Chat: "fixed"}}
+		for _, size := range []int{1024, 1024, 1024, 1024, 704} {
+			want = append(want, turnMessage{Type: "binary", Size: size})
+		}
+		want = append(want, turnMessage{Type: "speaking_end"})
+		if len(received) != len(want) {
+			t.Fatalf("Turn %d: expected messages %v, got %v", turn, want, received)
+		}
+		for i := range want {
+			if received[i] != want[i] {
+				t.Fatalf("Turn %d: expected messages %v, got %v", turn, want, received)
+			}
+		}
+	}
+
+	// A connection without the header keeps the regular services
+	received := runSocketTurn(t, regular)
+	if len(received) != 5 || received[1].Chat != "real" || received[2].Chat != "Halo juga!" {
+		t.Errorf("Expected the regular services for a connection without the header, got %v", received)
+	}
+	if messages := llm.Messages(); len(messages) != 1 || messages[0] != "real" {
+		t.Errorf("Expected only the regular connection to reach the LLM, got %v", messages)
+	}
+}
+
+func TestHub_IgnoresDeterministicHeaderWhenDisabled(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{}, &conversationtest.TTS{})
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Header.Set(HeaderDeterministic, "true")
+
+	if engine := hub.engineFor(req, "device-1"); engine != hub.engine {
+		t.Error("Expected the regular engine while deterministic mode is disabled")
+	}
+}
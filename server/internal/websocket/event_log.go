@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/internal/conversation"
+)
+
+// With HubConfig.EventLogSampleRate set, a fraction of the connections is
+// chosen when they connect and their protocol events are logged: the
+// connection and disconnection, the control messages of the device, the
+// messages of the server and a summary of every utterance and response.
+// Audio payloads are never logged, only counted. The other connections log
+// as usual.
+
+// eventLog logs the protocol events of a sampled connection; its zero value
+// logs nothing
+type eventLog struct {
+	logger *zap.Logger
+
+	// Audio of the current utterance, only used by the read pump
+	utteranceFrames int
+	utteranceBytes  int
+	utteranceStart  time.Time
+}
+
+// sampleConnection reports whether the events of a new connection are logged
+func (h *Hub) sampleConnection() bool {
+	rate := h.config.EventLogSampleRate
+	return rate > 0 && (rate >= 1 || h.random() < rate)
+}
+
+// newEventLog returns the event log of a new connection of deviceID, which
+// logs nothing unless the connection is sampled
+func (h *Hub) newEventLog(deviceID string, logger *zap.Logger) eventLog {
+	if !h.sampleConnection() {
+		return eventLog{}
+	}
+	return eventLog{logger: logger.With(
+		zap.String("deviceID", deviceID),
+		zap.Bool("sampled", true))}
+}
+
+// log logs a protocol event of a sampled connection
+func (l *eventLog) log(message string, fields ...zap.Field) {
+	if l.logger != nil {
+		l.logger.Info(message, fields...)
+	}
+}
+
+// logControl logs a control message of the device, with its audio settings
+// but no other content
+func (l *eventLog) logControl(msgType string, msg map[string]interface{}, now time.Time) {
+	if l.logger == nil {
+		return
+	}
+	fields := []zap.Field{zap.String("type", msgType)}
+	for _, key := range []string{"sample_rate", "language", "encoding", "chunk_size", "profile"} {
+		if value, ok := msg[key]; ok {
+			fields = append(fields, zap.Any(key, value))
+		}
+	}
+	switch msgType {
+	case "listening_start":
+		l.utteranceFrames, l.utteranceBytes, l.utteranceStart = 0, 0, now
+	case "listening_end":
+		fields = append(fields,
+			zap.Int("audioFrames", l.utteranceFrames),
+			zap.Int("audioBytes", l.utteranceBytes),
+			zap.Duration("utteranceDuration", now.Sub(l.utteranceStart)))
+	}
+	l.log("WebSocket control message received", fields...)
+}
+
+// countAudio counts an audio frame of the device
+func (l *eventLog) countAudio(data []byte) {
+	if l.logger != nil {
+		l.utteranceFrames++
+		l.utteranceBytes += len(data)
+	}
+}
+
+// logEvent logs a message of the server, with the delivery of the response
+// audio once it was spoken
+func (l *eventLog) logEvent(event conversation.Event, delivery responseDelivery) {
+	if l.logger == nil {
+		return
+	}
+	fields := []zap.Field{zap.String("type", string(event.Type))}
+	if event.SessionID != "" {
+		fields = append(fields, zap.String("sessionID", event.SessionID))
+	}
+	if event.Error != "" {
+		fields = append(fields, zap.String("error", event.Error))
+	}
+	switch event.Type {
+	case conversation.EventListeningEnd:
+		if event.Message != nil {
+			fields = append(fields, zap.Int("transcriptionLength", len(event.Message.Content)))
+		}
+	case conversation.EventSpeakingEnd, conversation.EventFillerEnd:
+		fields = append(fields,
+			zap.Uint32("audioFrames", delivery.frames),
+			zap.Uint32("droppedFrames", delivery.dropped))
+	}
+	l.log("WebSocket message sent", fields...)
+}
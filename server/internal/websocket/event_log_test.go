@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestHub_SamplesConfiguredFractionOfConnections(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{}, &conversationtest.TTS{})
+	hub.random = rand.New(rand.NewSource(1)).Float64
+
+	for _, rate := range []float64{0, 0.01, 0.25, 1} {
+		hub.config.EventLogSampleRate = rate
+		const connections = 20000
+		sampled := 0
+		for i := 0; i < connections; i++ {
+			if hub.sampleConnection() {
+				sampled++
+			}
+		}
+		// Four standard deviations of the binomial distribution
+		tolerance := 4 * math.Sqrt(connections*rate*(1-rate))
+		if got := float64(sampled); math.Abs(got-connections*rate) > tolerance {
+			t.Errorf("Expected about %.0f of %d connections sampled at %v, got %d", connections*rate, connections, rate, sampled)
+		}
+	}
+}
+
+func TestClient_LogsEventsOfSampledConnectionWithoutAudio(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{Transcript: "halo"}, &conversationtest.TTS{Chunks: [][]byte{{0xAA, 0xBB}}})
+	core, logs := observer.New(zap.InfoLevel)
+	hub.logger = zap.New(core)
+	hub.config.EventLogSampleRate = 1
+	client := newTestClient(hub, "device-1")
+
+	start, _ := json.Marshal(map[string]interface{}{"type": "listening_start", "sample_rate": 16000})
+	client.processMessage(start)
+	readMessage(t, client, "listening_start")
+	client.processBinaryAudioChunk([]byte{0x01, 0x02, 0x03, 0x04})
+	client.processMessage([]byte(`{"type": "listening_end"}`))
+	readMessage(t, client, "speaking_end")
+
+	received := logs.FilterMessage("WebSocket control message received").AllUntimed()
+	if len(received) != 2 {
+		t.Fatalf("Expected the two control messages logged, got %d", len(received))
+	}
+	end := received[1].ContextMap()
+	if end["type"] != "listening_end" || end["audioFrames"] != int64(1) || end["audioBytes"] != int64(4) {
+		t.Errorf("Expected the utterance summarized, got %v", end)
+	}
+	if received[0].ContextMap()["sample_rate"] == nil {
+		t.Errorf("Expected the audio settings of listening_start logged, got %v", received[0].ContextMap())
+	}
+
+	var sent []string
+	for _, entry := range logs.FilterMessage("WebSocket message sent").AllUntimed() {
+		sent = append(sent, entry.ContextMap()["type"].(string))
+	}
+	if len(sent) == 0 || sent[len(sent)-1] != "speaking_end" {
+		t.Errorf("Expected the messages sent logged through speaking_end, got %v", sent)
+	}
+	for _, entry := range logs.AllUntimed() {
+		for _, field := range entry.Context {
+			if field.Type == zapcore.ByteStringType || field.Type == zapcore.BinaryType {
+				t.Errorf("Expected no audio in the logs, got %q in %q", field.Key, entry.Message)
+			}
+		}
+	}
+}
+
+func TestClient_LogsNothingForUnsampledConnection(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{Transcript: "halo"}, &conversationtest.TTS{})
+	core, logs := observer.New(zap.InfoLevel)
+	hub.logger = zap.New(core)
+	client := newTestClient(hub, "device-1")
+
+	client.processMessage([]byte(`{"type": "listening_start"}`))
+	readMessage(t, client, "listening_start")
+
+	if entries := logs.FilterField(zap.Bool("sampled", true)).Len(); entries != 0 {
+		t.Errorf("Expected no event logged without sampling, got %d", entries)
+	}
+}
@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// newTestHub builds a hub whose engine is wired with the given fakes
+func newTestHub(t *testing.T, config conversation.Config, stt *conversationtest.STT, tts *conversationtest.TTS) *Hub {
+	t.Helper()
+	logger := zaptest.NewLogger(t)
+	engine, err := conversation.NewEngine(config, &conversationtest.LLM{Reply: "Halo juga!"}, tts, stt, &conversationtest.SessionRepository{}, adapters.NewMemoryChildRepository(), adapters.NewMemoryQuietHoursRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub, err := NewHub(HubConfig{}, engine, adapters.NewMemoryDeviceRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hub
+}
+
+// newTestClient builds a client without a network connection so that the
+// messages it queues can be inspected directly
+func newTestClient(hub *Hub, deviceID string) *Client {
+	return newClient(hub, nil, deviceID, hub.engine, hub.logger)
+}
+
+// readUntil collects queued messages until a control message of the given
+// type is received. Binary frames are reported with the type "binary".
+func readUntil(t *testing.T, c *Client, msgType string) []string {
+	t.Helper()
+	var types []string
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case data := <-c.send:
+			if data.Type == websocket.BinaryMessage {
+				types = append(types, "binary")
+				continue
+			}
+			var msg map[string]interface{}
+			if err := json.Unmarshal(data.Payload, &msg); err != nil {
+				t.Fatalf("Failed to parse queued message: %v", err)
+			}
+			got, _ := msg["type"].(string)
+			types = append(types, got)
+			if got == msgType {
+				return types
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %q, got %v", msgType, types)
+		}
+	}
+}
+
+// readMessage skips queued messages until a control message of the given type
+// is received and returns its decoded payload
+func readMessage(t *testing.T, c *Client, msgType string) map[string]interface{} {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case data := <-c.send:
+			if data.Type == websocket.BinaryMessage {
+				continue
+			}
+			var msg map[string]interface{}
+			if err := json.Unmarshal(data.Payload, &msg); err != nil {
+				t.Fatalf("Failed to parse queued message: %v", err)
+			}
+			if msg["type"] == msgType {
+				return msg
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %q", msgType)
+		}
+	}
+}
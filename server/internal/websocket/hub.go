@@ -3,11 +3,12 @@ package websocket
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io"
+	"math/rand"
 	"net/http"
-	"os"
-	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -16,6 +17,8 @@ import (
 
 	"github.com/satriahrh/arunika/server/domain/entities"
 	"github.com/satriahrh/arunika/server/domain/repositories"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/events"
 )
 
 const (
@@ -28,19 +31,10 @@ const (
 	// Send pings to peer with this period. Must be less than pongWait.
 	pingPeriod = (pongWait * 9) / 10
 
-	// Maximum message size allowed from peer.
+	// Maximum message size allowed from peer, announced in the hello.
 	maxMessageSize = 512 * 1024 // 512KB for audio chunks
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		// TODO: Implement proper origin checking
-		return true
-	},
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-}
-
 // Hub maintains the set of active clients and broadcasts messages to the clients.
 type Hub struct {
 	// Registered clients.
@@ -55,56 +49,256 @@ type Hub struct {
 	// Mutex for thread-safe access to clients map
 	mu sync.RWMutex
 
-	llm         repositories.LargeLanguageModel
-	ttsRepo     repositories.TextToSpeech
-	sttRepo     repositories.SpeechToText
-	sessionRepo repositories.SessionRepository
+	// Client holding the listening session of each device, so that two
+	// connections of one device never drive the shared session at once
+	listening   map[string]*Client
+	listeningMu sync.Mutex
+
+	// Console of the operator who took over each device, see
+	// HandleOperatorWebSocket
+	operators   map[string]*operatorConsole
+	operatorsMu sync.Mutex
+
+	// Upgrades the connections of the origins allowed by the config
+	upgrader websocket.Upgrader
+
+	// Conversation engine shared by all clients
+	engine *conversation.Engine
+	// Engine with deterministic speech services for connections that ask for
+	// it, nil unless deterministic mode is enabled
+	deterministicEngine *conversation.Engine
+
+	config HubConfig
+
+	// Record and announce when devices connect and disconnect
+	devices   repositories.DeviceRepository
+	publisher events.Publisher
+	// Changes to device records waiting to be stored, by device ID. A device
+	// has an entry while a worker stores its changes, see queuePresence.
+	pendingPresence   map[string][]func(device *entities.Device)
+	pendingPresenceMu sync.Mutex
+
+	now func() time.Time
+	// Draws the sampling of connections, replaced in tests
+	random func() float64
+
+	// Cleared while the dependencies of conversations are unhealthy, see
+	// MonitorReadiness
+	ready atomic.Bool
+	// Set once Shutdown was called, see Shutdown
+	draining atomic.Bool
 
 	logger *zap.Logger
 }
 
 // NewHub creates a new WebSocket hub
-func NewHub(
-	llm repositories.LargeLanguageModel,
-	ttsRepo repositories.TextToSpeech,
-	sttRepo repositories.SpeechToText,
-	sessionRepo repositories.SessionRepository,
-	logger *zap.Logger,
-) *Hub {
-	return &Hub{
-		clients:     make(map[string]*Client),
-		register:    make(chan *Client),
-		unregister:  make(chan *Client),
-		llm:         llm,
-		ttsRepo:     ttsRepo,
-		sttRepo:     sttRepo,
-		sessionRepo: sessionRepo,
-		logger:      logger,
+func NewHub(config HubConfig, engine *conversation.Engine, devices repositories.DeviceRepository, publisher events.Publisher, logger *zap.Logger) (*Hub, error) {
+	// A missing dependency fails now rather than on the first connection
+	// reaching for it
+	switch {
+	case engine == nil:
+		return nil, errors.New("conversation engine is required")
+	case devices == nil:
+		return nil, errors.New("device repository is required")
+	case publisher == nil:
+		return nil, errors.New("event publisher is required")
+	}
+
+	// Apply defaults where needed
+	if config.RetryAfter == 0 {
+		config.RetryAfter = defaultRetryAfter
+		logger.Info("Using default retry after", zap.Duration("retryAfter", config.RetryAfter))
+	}
+
+	if config.IdleTimeout == 0 {
+		config.IdleTimeout = defaultIdleTimeout
+		logger.Info("Using default idle timeout", zap.Duration("idleTimeout", config.IdleTimeout))
+	}
+
+	if config.ReadinessInterval == 0 {
+		config.ReadinessInterval = defaultReadinessInterval
+		logger.Info("Using default readiness interval", zap.Duration("readinessInterval", config.ReadinessInterval))
+	}
+
+	if config.ReadinessFailures == 0 {
+		config.ReadinessFailures = defaultReadinessFailures
+		logger.Info("Using default readiness failures", zap.Int("readinessFailures", config.ReadinessFailures))
+	}
+
+	if config.PlaybackPrebuffer == 0 {
+		config.PlaybackPrebuffer = defaultPlaybackPrebuffer
+		logger.Info("Using default playback prebuffer", zap.Duration("playbackPrebuffer", config.PlaybackPrebuffer))
+	}
+
+	if config.AdaptiveAudioProbeInterval == 0 {
+		config.AdaptiveAudioProbeInterval = defaultAdaptiveAudioProbeInterval
+		logger.Info("Using default adaptive audio probe interval", zap.Duration("adaptiveAudioProbeInterval", config.AdaptiveAudioProbeInterval))
+	} else if config.AdaptiveAudioProbeInterval > pingPeriod {
+		logger.Warn("Adaptive audio probe interval beyond the ping period, using the ping period",
+			zap.Duration("adaptiveAudioProbeInterval", config.AdaptiveAudioProbeInterval),
+			zap.Duration("pingPeriod", pingPeriod))
+		config.AdaptiveAudioProbeInterval = pingPeriod
 	}
+
+	if config.AdaptiveAudioHighRTT == 0 {
+		config.AdaptiveAudioHighRTT = defaultAdaptiveAudioHighRTT
+		logger.Info("Using default adaptive audio high RTT", zap.Duration("adaptiveAudioHighRTT", config.AdaptiveAudioHighRTT))
+	}
+
+	if config.AdaptiveAudioLowRTT == 0 {
+		config.AdaptiveAudioLowRTT = defaultAdaptiveAudioLowRTT
+		logger.Info("Using default adaptive audio low RTT", zap.Duration("adaptiveAudioLowRTT", config.AdaptiveAudioLowRTT))
+	}
+
+	if config.AdaptiveAudioLowRTT >= config.AdaptiveAudioHighRTT {
+		logger.Warn("Adaptive audio low RTT is not below the high RTT, using the defaults",
+			zap.Duration("adaptiveAudioLowRTT", config.AdaptiveAudioLowRTT),
+			zap.Duration("adaptiveAudioHighRTT", config.AdaptiveAudioHighRTT))
+		config.AdaptiveAudioHighRTT = defaultAdaptiveAudioHighRTT
+		config.AdaptiveAudioLowRTT = defaultAdaptiveAudioLowRTT
+	}
+
+	if config.AdaptiveAudioMaxJitter == 0 {
+		config.AdaptiveAudioMaxJitter = defaultAdaptiveAudioMaxJitter
+		logger.Info("Using default adaptive audio max jitter", zap.Duration("adaptiveAudioMaxJitter", config.AdaptiveAudioMaxJitter))
+	}
+
+	config.AllowedOrigins = normalizeOrigins(config.AllowedOrigins)
+	if len(config.AllowedOrigins) == 0 {
+		logger.Info("No allowed origins, accepting browser connections from the same host only")
+	}
+
+	switch config.AudioFinal {
+	case AudioFinalFlag, AudioFinalControl:
+	case "":
+		config.AudioFinal = defaultAudioFinal
+		logger.Info("Using default audio final", zap.String("audioFinal", config.AudioFinal))
+	default:
+		logger.Warn("Unknown audio final, using the default",
+			zap.String("audioFinal", config.AudioFinal),
+			zap.String("default", defaultAudioFinal))
+		config.AudioFinal = defaultAudioFinal
+	}
+
+	hub := &Hub{
+		clients:         make(map[string]*Client),
+		pendingPresence: make(map[string][]func(device *entities.Device)),
+		register:        make(chan *Client),
+		unregister:      make(chan *Client),
+		listening:       make(map[string]*Client),
+		operators:       make(map[string]*operatorConsole),
+		engine:          engine,
+		config:          config,
+		devices:         devices,
+		publisher:       publisher,
+		now:             time.Now,
+		random:          rand.Float64,
+		logger:          logger,
+	}
+	hub.upgrader = websocket.Upgrader{
+		CheckOrigin:     hub.originAllowed,
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+	}
+	hub.ready.Store(true)
+	return hub, nil
 }
 
 // Run starts the hub's main loop
 func (h *Hub) Run() {
+	idleTicker := time.NewTicker(h.idleCheckPeriod())
+	defer idleTicker.Stop()
+
 	for {
 		select {
+		case <-idleTicker.C:
+			h.reapIdle()
+
 		case client := <-h.register:
 			h.mu.Lock()
+			previous, replaced := h.clients[client.deviceID]
 			h.clients[client.deviceID] = client
 			h.mu.Unlock()
+			if replaced {
+				// A device reconnecting before its lost connection timed out
+				// resumes the utterance it was speaking
+				previous.conversation.Suspend()
+				client.setDeviceRecord(previous.deviceRecord())
+				// Only one connection per device; the old one must not reconnect
+				go previous.closeWith(CloseConnectionReplaced)
+			} else {
+				h.deviceOnline(client)
+			}
+			// A connection upgraded just before the hub began draining is
+			// closed like the others
+			if h.draining.Load() {
+				go client.closeWith(CloseServerShutdown)
+			}
+			h.resumeTakeover(client)
 			h.logger.Info("Client registered", zap.String("deviceID", client.deviceID))
 
 		case client := <-h.unregister:
 			h.mu.Lock()
-			if _, ok := h.clients[client.deviceID]; ok {
+			current, ok := h.clients[client.deviceID]
+			last := ok && current == client
+			if last {
 				delete(h.clients, client.deviceID)
 				close(client.send)
 			}
 			h.mu.Unlock()
+			// A replaced connection leaves the device online
+			if last {
+				h.deviceOffline(client)
+				if console := h.operatorOf(client.deviceID); console != nil {
+					console.sendControl(map[string]interface{}{"type": "device_disconnected"})
+				}
+			}
+			h.releaseListening(client)
 			h.logger.Info("Client unregistered", zap.String("deviceID", client.deviceID))
 		}
 	}
 }
 
+// SelfTest runs a synthetic turn for deviceID with the conversation engine,
+// see conversation.Engine.SelfTest
+func (h *Hub) SelfTest(ctx context.Context, deviceID string) conversation.SelfTestReport {
+	return h.engine.SelfTest(ctx, deviceID)
+}
+
+// ForgetDevice drops the conversation state cached for a connected device so
+// that erased history cannot leak into the next turn
+func (h *Hub) ForgetDevice(deviceID string) {
+	h.mu.RLock()
+	client, ok := h.clients[deviceID]
+	h.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	client.conversation.Forget()
+
+	h.logger.Info("Cleared cached conversation state", zap.String("deviceID", deviceID))
+}
+
+// ForgetChild drops the conversation state cached for a child, whichever
+// devices they talked through, so that erased history cannot leak into the
+// next turn
+func (h *Hub) ForgetChild(childID string) {
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for _, client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		client.conversation.ForgetChild(childID)
+	}
+	h.engine.ForgetChild(childID)
+
+	h.logger.Info("Cleared cached conversation state", zap.String("childID", childID))
+}
+
 type WriteData struct {
 	// MessageType is the type of the websocket message.
 	// Expect websocket.TextMessage or websocket.BinaryMessage
@@ -112,6 +306,31 @@ type WriteData struct {
 	Payload []byte
 }
 
+// queue queues a message for the write pump, reporting false when the
+// read pump stopped and the write pump may no longer take it
+func (c *Client) queue(data WriteData) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- data:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// sendControl queues a JSON control message for the device
+func (c *Client) sendControl(payload map[string]interface{}) {
+	responseBytes, _ := json.Marshal(payload)
+	c.queue(WriteData{
+		Type:    websocket.TextMessage,
+		Payload: responseBytes,
+	})
+}
+
 // Client is a middleman between the websocket connection and the hub.
 type Client struct {
 	hub *Hub
@@ -121,73 +340,111 @@ type Client struct {
 
 	// Buffered channel of outbound messages.
 	send chan WriteData
+	// Closed when the read pump stops, so that nothing waits on send anymore
+	done chan struct{}
 
 	// Device ID for this client
 	deviceID string
+	// Record of the device, kept from registration to select broadcasts
+	deviceMu sync.Mutex
+	device   *entities.Device
 
 	// Logger
 	logger *zap.Logger
-
-	// Audio streaming session management
-	session      *entities.Session
-	sttStreaming repositories.SpeechToTextStreaming
-	chatSession  repositories.ChatSession
-
-	chunkCount     int
-	listeningStart time.Time
-
-	mutex sync.Mutex
+	// Protocol events of a sampled connection
+	events eventLog
+
+	// Conversation pipeline driven by this connection
+	conversation *conversation.Conversation
+
+	// Conversational activity watched by the idle reaper. A sleeping doll
+	// keeps its connection but does not converse.
+	activityMu   sync.Mutex
+	lastActivity time.Time
+	speaking     bool
+	reaped       bool
+	sleeping     bool
+
+	// Why the server closed the connection, empty when the device did
+	closeMu     sync.Mutex
+	closeReason CloseReason
+	closeCode   int
+
+	// Why the read pump stopped, set before unregistering
+	offlineReason string
+	offlineCode   int
+
+	// Audio header negotiated in the hello, only used by the read pump
+	audioHeaders      bool
+	audioSequenced    bool
+	lastAudioSequence uint32
+	// Listening was ended by the final flag, the listening_end the device may
+	// send after it is not an error
+	finalFlagEnded bool
+
+	// Audio received before listening started, only used by the read pump
+	preRoll []byte
+
+	// Second connection carrying response audio, negotiated in the hello.
+	// responseChannel is the audio channel of the current response, nil when
+	// its audio is sent over this connection.
+	audioMu         sync.Mutex
+	audioChannelID  string
+	audioChannel    *audioChannel
+	responseChannel *audioChannel
+	responseFrames  uint32
+
+	// Response audio sent over this connection carries the audio header too,
+	// negotiated in the hello
+	responseAudioHeader bool
+	// Frames of the current response dropped under backpressure, and the
+	// sequence of the first of them
+	responseDropped      uint32
+	firstDroppedSequence uint32
+
+	// Round trip time of the connection, measured with adaptive audio
+	link linkQuality
 }
 
-// HandleWebSocket handles websocket requests from the peer.
-func HandleWebSocket(hub *Hub, c echo.Context, logger *zap.Logger) error {
-	conn, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
-	if err != nil {
-		logger.Error("WebSocket upgrade failed", zap.Error(err))
-		return err
-	}
-
-	// TODO: Extract device ID from JWT token in query parameter
-	deviceID := c.QueryParam("device_id")
-	if deviceID == "" {
-		deviceID = "unknown" // Temporary fallback
-	}
-
+// newClient creates a client whose conversation events are written to the connection
+func newClient(hub *Hub, conn *websocket.Conn, deviceID string, engine *conversation.Engine, logger *zap.Logger) *Client {
 	client := &Client{
 		hub:      hub,
 		conn:     conn,
 		send:     make(chan WriteData, 256),
+		done:     make(chan struct{}),
 		deviceID: deviceID,
 		logger:   logger,
-	}
-
-	client.hub.register <- client
-
-	// Allow collection of memory referenced by the caller by doing all work in
-	// new goroutines.
-	go client.writePump()
-	go client.readPump()
+		events:   hub.newEventLog(deviceID, logger),
 
-	return nil
+		lastActivity: hub.now(),
+	}
+	client.conversation = engine.NewConversation(deviceID, client)
+	return client
 }
 
 // HandleWebSocketWithAuth handles websocket requests with pre-authenticated device ID
+// Connections without a device ID are refused before the upgrade, so that
+// the hub never registers clients that cannot be told apart.
 func HandleWebSocketWithAuth(hub *Hub, c echo.Context, deviceID string, logger *zap.Logger) error {
-	conn, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if deviceID == "" {
+		logger.Warn("WebSocket connection rejected: missing device ID")
+		return echo.NewHTTPError(http.StatusUnauthorized, "device ID is required")
+	}
+	if err := hub.checkOrigin(c.Request(), logger); err != nil {
+		return err
+	}
+
+	conn, err := hub.upgrader.Upgrade(c.Response(), c.Request(), nil)
 	if err != nil {
 		logger.Error("WebSocket upgrade failed", zap.Error(err))
 		return err
 	}
 
-	client := &Client{
-		hub:      hub,
-		conn:     conn,
-		send:     make(chan WriteData, 256),
-		deviceID: deviceID,
-		logger:   logger,
-	}
+	client := newClient(hub, conn, deviceID, hub.engineFor(c.Request(), deviceID), logger)
 
 	client.hub.register <- client
+	client.events.log("WebSocket connected")
 
 	// Allow collection of memory referenced by the caller by doing all work in
 	// new goroutines.
@@ -200,23 +457,51 @@ func HandleWebSocketWithAuth(hub *Hub, c echo.Context, deviceID string, logger *
 // readPump pumps messages from the websocket connection to the hub.
 func (c *Client) readPump() {
 	defer func() {
+		c.closeAudioChannel()
+		// A blip mid-utterance must not make the child repeat themselves
+		if c.offlineReason == events.ReasonAbnormal {
+			c.conversation.Suspend()
+		}
+		// Responses in progress must not write to a connection that is gone
+		close(c.done)
+		c.conversation.Close()
 		c.hub.unregister <- c
 		c.conn.Close()
+		c.events.log("WebSocket disconnected",
+			zap.String("reason", c.offlineReason),
+			zap.Int("code", c.offlineCode))
 	}()
 
-	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
-	c.conn.SetPongHandler(func(string) error {
+	c.conn.SetPongHandler(func(appData string) error {
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.observePong(appData)
 		return nil
 	})
 
+	tooBig := false
 	for {
-		messageType, message, err := c.conn.ReadMessage()
+		messageType, message, err := c.readMessage()
+		if errors.Is(err, errMessageTooBig) {
+			// The connection closes once the close frame was written, the
+			// messages until then are dropped
+			if !tooBig {
+				tooBig = true
+				c.logger.Warn("Closing connection that sent a message beyond the maximum size",
+					zap.String("deviceID", c.deviceID),
+					zap.Int("maxMessageSize", maxMessageSize))
+				c.closeWith(CloseMessageTooBig)
+			}
+			continue
+		}
+		if tooBig && err == nil {
+			continue
+		}
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				c.logger.Error("WebSocket error", zap.Error(err))
 			}
+			c.offlineReason, c.offlineCode = c.disconnectReason(err)
 			break
 		}
 
@@ -234,9 +519,32 @@ func (c *Client) readPump() {
 	}
 }
 
+// errMessageTooBig is returned by readMessage for a message beyond
+// maxMessageSize
+var errMessageTooBig = errors.New("message too big")
+
+// readMessage reads the next message from the device. A message beyond
+// maxMessageSize fails with errMessageTooBig without being read further.
+// The limit is not left to the read limit of the connection, which closes it
+// with a bare close frame before the device could be told the limit.
+func (c *Client) readMessage() (int, []byte, error) {
+	messageType, r, err := c.conn.NextReader()
+	if err != nil {
+		return messageType, nil, err
+	}
+	message, err := io.ReadAll(io.LimitReader(r, maxMessageSize+1))
+	if err != nil {
+		return messageType, nil, err
+	}
+	if len(message) > maxMessageSize {
+		return messageType, nil, errMessageTooBig
+	}
+	return messageType, message, nil
+}
+
 // writePump pumps messages from the hub to the websocket connection.
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(c.pingPeriod())
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
@@ -256,9 +564,14 @@ func (c *Client) writePump() {
 				return
 			}
 
+			// A close frame queued by closeWith is the last message
+			if message.Type == websocket.CloseMessage {
+				return
+			}
+
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := c.conn.WriteMessage(websocket.PingMessage, c.pingPayload()); err != nil {
 				return
 			}
 		}
@@ -279,8 +592,16 @@ func (c *Client) processMessage(message []byte) {
 		c.logger.Error("Message missing type field")
 		return
 	}
+	c.touch()
+	c.events.logControl(msgType, msg, c.hub.now())
 
 	switch msgType {
+	case "hello":
+		c.handleHello(msg)
+	case "sleep":
+		c.handleSleep()
+	case "wake":
+		c.handleWake()
 	case "listening_start":
 		c.handleListeningStart(msg)
 	case "listening_end":
@@ -290,372 +611,250 @@ func (c *Client) processMessage(message []byte) {
 	}
 }
 
-// processBinaryAudioChunk handles binary audio data
-func (c *Client) processBinaryAudioChunk(data []byte) {
-	// For now, we'll assume there's an active session to update counters
-	// In a full implementation, you'd extract session ID from binary headers
-	// or track the current active session per device
-
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	if c.session == nil {
-		c.logger.Warn("Received binary audio chunk but no active session found",
-			zap.String("deviceID", c.deviceID))
-		return
+// handleHello negotiates per-connection settings announced by the device.
+// The device may request a TTS chunk size in bytes ("chunk_size") or a
+// connection profile ("profile": "low_latency" or "high_bandwidth"),
+// prefix audio frames with a header ("audio_header": true), and receive the
+// response audio over a second connection ("audio_channel": true). It may
+// also announce the language it is set to ("language"), used when neither
+// the utterance, the session nor the child's profile name one.
+func (c *Client) handleHello(msg map[string]interface{}) {
+	if language, ok := msg["language"].(string); ok {
+		c.conversation.SetDeviceLanguage(language)
 	}
-
-	if c.sttStreaming == nil {
-		c.logger.Warn("No active STT streaming for current session",
-			zap.String("deviceID", c.deviceID),
-			zap.String("sessionID", c.session.ID))
-		return
+	if version, ok := msg["firmware_version"].(string); ok && version != "" {
+		c.recordFirmwareVersion(version)
 	}
 
-	// Update session counters
-	c.chunkCount++
-
-	// Stream audio data to the speech-to-text service
-	if err := c.sttStreaming.Stream(data); err != nil {
-		c.logger.Error("Failed to stream audio data",
-			zap.String("sessionID", c.session.ID),
-			zap.Error(err))
-		// Optionally, you might want to end the session or take other actions here
-		return
+	var requested int
+	if v, ok := msg["chunk_size"].(float64); ok {
+		requested = int(v)
 	}
+	profile, _ := msg["profile"].(string)
 
-	c.logger.Debug("Received binary audio chunk and processed",
-		zap.String("deviceID", c.deviceID),
-		zap.Int("chunkCount", c.chunkCount),
-		zap.Int("size", len(data)))
-}
-
-// handleListeningStart handles the start of an audio streaming session
-func (c *Client) handleListeningStart(msg map[string]interface{}) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	c.chunkCount = 0
-	c.listeningStart = time.Now()
+	chunkSize := conversation.NegotiateChunkSize(requested, profile)
+	c.conversation.SetChunkSize(chunkSize)
 
-	var response map[string]interface{} = map[string]interface{}{
-		"type":      "listening_start",
-		"timestamp": c.listeningStart.Unix(),
-	}
-
-	defer func() {
-		responseBytes, _ := json.Marshal(response)
-		select {
-		case c.send <- WriteData{
-			Type:    websocket.TextMessage,
-			Payload: responseBytes,
-		}:
-		default:
-			close(c.send)
-		}
-	}()
+	c.logger.Info("Negotiated connection settings",
+		zap.String("deviceID", c.deviceID),
+		zap.String("profile", profile),
+		zap.Int("chunkSize", chunkSize))
 
-	var err error
-	if c.session == nil {
-		c.session, err = c.hub.sessionRepo.GetLastByDeviceID(ctx, c.deviceID)
-		if err != nil {
-			c.logger.Error("Failed to get last session by device ID",
-				zap.String("deviceID", c.deviceID),
-				zap.Error(err))
-			response["error"] = "failed to get last session"
-			return
+	response := map[string]interface{}{
+		"type":              "hello",
+		"timestamp":         time.Now().Unix(),
+		"max_message_bytes": maxMessageSize,
+	}
+	// Omitted when the server default applies
+	if chunkSize > 0 {
+		response["chunk_size"] = chunkSize
+	}
+	if enabled, ok := msg["audio_header"].(bool); ok {
+		c.audioHeaders = enabled
+		response["audio_header"] = enabled
+		if enabled {
+			response["audio_header_version"] = audioHeaderVersion
+			response["audio_final"] = c.hub.config.AudioFinal
 		}
 	}
-	if c.session == nil || !c.session.CanContinueThisSession() {
-		c.session = &entities.Session{
-			DeviceID: c.deviceID,
+	if enabled, ok := msg["response_audio_header"].(bool); ok {
+		c.setResponseAudioHeader(enabled)
+		response["response_audio_header"] = enabled
+	}
+	if requested, ok := msg["audio_channel"].(bool); ok && requested {
+		// Devices keep the single connection when the server does not offer it
+		response["audio_channel"] = c.hub.config.AudioChannel
+		if c.hub.config.AudioChannel {
+			response["audio_channel_id"] = c.offerAudioChannel()
 		}
-		err := c.hub.sessionRepo.Create(ctx, c.session)
+	}
+	if caps, declared := parseAudioCapabilities(msg); declared {
+		settings, err := c.conversation.SetAudioCapabilities(caps)
 		if err != nil {
-			c.logger.Error("Failed to create new session",
-				zap.String("deviceID", c.deviceID),
-				zap.Error(err))
-			response["error"] = "failed to create new session"
-			return
+			response["error"] = conversation.ErrorUnsupportedAudio
+			response["message"] = err.Error()
+			response["supported_output_sample_rates"] = conversation.SupportedOutputSampleRates()
+		} else {
+			response["input_sample_rate"] = settings.InputSampleRate
+			response["output_sample_rate"] = settings.OutputSampleRate
 		}
 	}
+	c.sendControl(response)
+}
 
-	response["session_id"] = c.session.ID
-
-	if c.chatSession == nil {
-		c.chatSession, err = c.hub.llm.GenerateChat(ctx, c.session.Messages)
-		if err != nil {
-			c.logger.Error("Failed to create chat session",
-				zap.String("deviceID", c.deviceID),
-				zap.Error(err))
-			response["error"] = "failed to create chat session"
-			return
+// parseAudioCapabilities reads the sample rates declared in a hello message,
+// reporting whether the device declared any
+func parseAudioCapabilities(msg map[string]interface{}) (conversation.AudioCapabilities, bool) {
+	var caps conversation.AudioCapabilities
+	declared := false
+	if v, ok := msg["input_sample_rate"].(float64); ok {
+		caps.InputSampleRate = int(v)
+		declared = true
+	}
+	if rates, ok := msg["output_sample_rates"].([]interface{}); ok {
+		for _, rate := range rates {
+			if v, ok := rate.(float64); ok {
+				caps.OutputSampleRates = append(caps.OutputSampleRates, int(v))
+			}
 		}
+		declared = true
 	}
+	return caps, declared
+}
 
-	audioConfig := repositories.AudioConfig{
-		SampleRate: 48000,
-		Language:   "id-ID",
-		Encoding:   "LINEAR16",
-	}
-	if v, ok := msg["sample_rate"].(float64); ok && v > 0 {
-		audioConfig.SampleRate = int(v)
-	}
-	if v, ok := msg["language"].(string); ok && v != "" {
-		audioConfig.Language = v
+// processBinaryAudioChunk handles binary audio data
+func (c *Client) processBinaryAudioChunk(data []byte) {
+	c.touch()
+	c.events.countAudio(data)
+	if c.isSleeping() {
+		return
 	}
-	if v, ok := msg["encoding"].(string); ok && v != "" {
-		audioConfig.Encoding = v
+	if c.audioHeaders {
+		c.processHeaderedAudioChunk(data)
+		return
 	}
-
-	c.sttStreaming, err = c.hub.sttRepo.InitTranscribeStreaming(context.Background(), audioConfig)
-	if err != nil {
-		c.logger.Error("Failed to initialize streaming transcription",
-			zap.String("sessionID", c.session.ID),
-			zap.Error(err))
-		response["error"] = "failed to initialize transcription"
+	if c.bufferPreRoll(data) {
 		return
 	}
-
-	c.logger.Info("Audio session started",
-		zap.String("deviceID", c.deviceID),
-		zap.String("sessionID", c.session.ID))
-
-	response["message"] = "listening started"
+	c.conversation.StreamAudio(data)
 }
 
-// handleListeningEnd handles the end of an audio streaming session
-func (c *Client) handleListeningEnd(msg map[string]interface{}) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	var response map[string]interface{} = map[string]interface{}{
-		"type":       "listening_end",
-		"session_id": c.session.ID,
-	}
-
-	defer func() {
-		responseBytes, _ := json.Marshal(response)
-		select {
-		case c.send <- WriteData{
-			Type:    websocket.TextMessage,
-			Payload: responseBytes,
-		}:
-		default:
-			close(c.send)
-		}
-	}()
-
-	var finalTranscription string
-	var err error
-	finalTranscription, err = c.sttStreaming.End()
-	if err != nil {
-		c.logger.Error("Failed to end transcription stream",
-			zap.String("deviceID", c.deviceID),
-			zap.String("sessionID", c.session.ID),
-			zap.Error(err))
-		response["error"] = "failed to end transcription"
+// handleListeningStart handles the start of an audio streaming session
+func (c *Client) handleListeningStart(msg map[string]interface{}) {
+	if c.isSleeping() {
+		c.logger.Debug("Ignoring listening start while sleeping", zap.String("deviceID", c.deviceID))
 		return
 	}
 
-	c.logger.Info("Transcription completed",
-		zap.String("deviceID", c.deviceID),
-		zap.String("sessionID", c.session.ID),
-		zap.String("transcription", finalTranscription))
-
-	chatMessage := entities.Message{
-		Timestamp:  c.listeningStart,
-		Role:       entities.UserRole,
-		Content:    finalTranscription,
-		DurationMs: time.Since(c.listeningStart).Milliseconds(),
+	var opts conversation.StartOptions
+	if v, ok := msg["sample_rate"].(float64); ok && v > 0 {
+		opts.SampleRate = int(v)
+	}
+	if v, ok := msg["language"].(string); ok && v != "" {
+		opts.Language = v
+	}
+	if v, ok := msg["encoding"].(string); ok && v != "" {
+		opts.Encoding = v
+	}
+	if v, ok := msg["resume_session_id"].(string); ok {
+		opts.ResumeSessionID = v
 	}
-	response["chat"] = chatMessage
 
-	go c.responseAudio(chatMessage)
+	// Sequence numbers start over with every listening session
+	c.audioSequenced = false
+	c.finalFlagEnded = false
 
-	c.logger.Info("Starting audio response goroutine",
-		zap.String("deviceID", c.deviceID),
-		zap.String("sessionID", c.session.ID))
+	opts.PreRoll = c.takePreRoll()
 
-	fmt.Println("Adding user message to session:", msg)
+	if !c.hub.acquireListening(c) {
+		c.rejectBusy()
+		return
+	}
+	c.conversation.StartListening(opts)
 }
 
-func (c *Client) responseAudio(message entities.Message) {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
-
-	chatResponse, err := c.chatSession.SendMessage(ctx, message)
-	if err != nil {
-		c.logger.Error("Failed to send message to chat session",
-			zap.String("deviceID", c.deviceID),
-			zap.String("sessionID", c.session.ID),
-			zap.Error(err))
+// handleListeningEnd handles the end of an audio streaming session
+func (c *Client) handleListeningEnd(msg map[string]interface{}) {
+	if c.isSleeping() {
 		return
 	}
-
-	c.logger.Info("Received chat response",
-		zap.String("deviceID", c.deviceID),
-		zap.String("sessionID", c.session.ID),
-		zap.String("response", chatResponse.Content))
-
-	audioDataChan, err := c.hub.ttsRepo.ConvertTextToSpeech(ctx, chatResponse.Content)
-	if err != nil {
-		c.logger.Error("Failed to convert text to speech",
-			zap.String("deviceID", c.deviceID),
-			zap.String("sessionID", c.session.ID),
-			zap.Error(err))
+	if c.finalFlagEnded {
+		c.finalFlagEnded = false
+		c.logger.Debug("Ignoring listening end of an utterance the final flag ended",
+			zap.String("deviceID", c.deviceID))
 		return
 	}
+	c.conversation.EndListening()
+	c.hub.releaseListening(c)
+}
 
-	c.send <- WriteData{
-		Type: websocket.TextMessage,
-		Payload: func() []byte {
-			responseBytes, _ := json.Marshal(map[string]interface{}{
-				"type":       "speaking_start",
-				"session_id": c.session.ID,
-				"chat":       chatResponse,
-			})
-			return responseBytes
-		}(),
-	}
-	for audioData := range audioDataChan {
-		c.send <- WriteData{
-			Type:    websocket.BinaryMessage,
-			Payload: audioData,
+// Emit implements conversation.EventSink by translating pipeline events
+// into the WebSocket protocol
+func (c *Client) Emit(event conversation.Event) {
+	switch event.Type {
+	case conversation.EventAudio:
+		if c.sendResponseAudio(event.SessionID, event.Audio) {
+			return
 		}
+		c.sendPrimaryAudio(event.SessionID, event.Audio)
+		return
+	case conversation.EventThinking:
+		// Not part of the WebSocket protocol
+		return
 	}
 
-	c.send <- WriteData{
-		Type: websocket.TextMessage,
-		Payload: func() []byte {
-			responseBytes, _ := json.Marshal(map[string]interface{}{
-				"type":       "speaking_end",
-				"session_id": c.session.ID,
-				"timestamp":  time.Now().Unix(),
-			})
-			return responseBytes
-		}(),
+	payload := map[string]interface{}{
+		"type": string(event.Type),
 	}
-
-	c.session.AddMessage(func(s *entities.Session) error {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		err := c.hub.sessionRepo.Update(ctx, s)
-		if err != nil {
-			c.logger.Error("Failed to update session with new messages",
-				zap.String("deviceID", c.deviceID),
-				zap.String("sessionID", c.session.ID),
-				zap.Error(err))
-			return err
-		}
-		return nil
-	}, message, chatResponse)
-}
-
-// responseWithSampleAlso deprecated
-func (c *Client) responseWithSampleAlso() {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	time.Sleep(100 * time.Millisecond)
-
-	c.logger.Info("Starting delayed audio response",
-		zap.String("deviceID", c.deviceID),
-		zap.String("sessionID", c.session.ID),
-		zap.String("delay", "5 seconds"))
-
-	time.Sleep(5 * time.Second)
-
-	c.logger.Info("Sending audio response start message",
-		zap.String("deviceID", c.deviceID),
-		zap.String("sessionID", c.session.ID))
-
-	startMessage := map[string]interface{}{
-		"type":       "speaking_start",
-		"session_id": c.session.ID,
-		"timestamp":  time.Now().Unix(),
+	if event.SessionID != "" {
+		payload["session_id"] = event.SessionID
 	}
-	responseBytes, _ := json.Marshal(startMessage)
-	c.send <- WriteData{
-		Type:    websocket.TextMessage,
-		Payload: responseBytes,
+	if event.Error != "" {
+		payload["error"] = event.Error
 	}
 
-	audioFilePath := filepath.Join(".", "sample_audio.wav")
-	audioFileData, err := os.ReadFile(audioFilePath)
-	if err != nil {
-		c.logger.Error("Failed to read sample audio file for response",
-			zap.String("deviceID", c.deviceID),
-			zap.String("sessionID", c.session.ID),
-			zap.String("filePath", audioFilePath),
-			zap.Error(err))
-		return
+	var delivery responseDelivery
+	switch event.Type {
+	case conversation.EventSpeakingStart, conversation.EventFillerStart:
+		if c.beginResponseAudio() {
+			payload["audio_channel"] = true
+		}
+	case conversation.EventSpeakingEnd, conversation.EventFillerEnd:
+		delivery = c.endResponseAudio()
+		if delivery.sequenced {
+			payload["audio_frames"] = delivery.frames
+		}
+		if delivery.dropped > 0 {
+			payload["dropped_frames"] = delivery.dropped
+			c.reportDroppedAudio(event.SessionID, delivery)
+		}
 	}
 
-	c.logger.Info("Read sample audio file for response",
-		zap.String("deviceID", c.deviceID),
-		zap.String("sessionID", c.session.ID),
-		zap.String("filePath", audioFilePath),
-		zap.Int("totalBytes", len(audioFileData)))
-
-	// Send audio data in chunks
-	chunkSize := 1024 // 1KB chunks
-	totalChunks := (len(audioFileData) + chunkSize - 1) / chunkSize
-	sendingChunks := totalChunks / 2
-
-	c.logger.Info("Starting to send audio response chunks",
-		zap.String("deviceID", c.deviceID),
-		zap.String("sessionID", c.session.ID),
-		zap.Int("totalChunks", totalChunks),
-		zap.Int("sendingChunks", sendingChunks),
-		zap.Int("chunkSize", chunkSize))
-
-	for i := 0; i < sendingChunks; i++ {
-		start := i * chunkSize
-		end := start + chunkSize
-		if end > len(audioFileData) {
-			end = len(audioFileData)
+	switch event.Type {
+	case conversation.EventListeningStart:
+		payload["timestamp"] = event.Timestamp.Unix()
+		if event.Error == "" {
+			payload["message"] = "listening started"
 		}
-
-		audioChunk := audioFileData[start:end]
-
-		c.logger.Debug("Sending audio response chunk",
-			zap.String("deviceID", c.deviceID),
-			zap.String("sessionID", c.session.ID),
-			zap.Int("chunkNumber", i+1),
-			zap.Int("chunkSize", len(audioChunk)))
-
-		c.send <- WriteData{
-			Type:    websocket.BinaryMessage,
-			Payload: audioChunk,
+		if event.Resumed {
+			payload["resumed"] = true
+		}
+	case conversation.EventListeningEnd:
+		if event.Message != nil {
+			payload["chat"] = event.Message
+		}
+	case conversation.EventSpeakingStart:
+		if event.Message != nil {
+			payload["chat"] = event.Message
+		}
+		payload["buffering"] = c.hub.bufferingHint(event.AudioEstimate)
+		if c.hub.config.AdaptiveAudio && event.OutputSampleRate > 0 {
+			payload["output_sample_rate"] = event.OutputSampleRate
+		}
+	case conversation.EventSpeakingEnd:
+		payload["timestamp"] = event.Timestamp.Unix()
+		// A pipelined response is complete only once spoken
+		if event.Message != nil {
+			payload["chat"] = event.Message
 		}
-
-		time.Sleep(100 * time.Millisecond) // Small delay between chunks
 	}
 
-	c.logger.Info("Finished sending audio response chunks",
-		zap.String("deviceID", c.deviceID),
-		zap.String("sessionID", c.session.ID),
-		zap.Int("totalChunksSent", sendingChunks))
-
-	c.logger.Info("Sending audio response end message",
-		zap.String("deviceID", c.deviceID),
-		zap.String("sessionID", c.session.ID))
+	c.sendControl(payload)
+	c.events.logEvent(event, delivery)
+	c.trackSpeaking(event)
+	c.forwardToOperator(event)
 
-	endMessage := map[string]interface{}{
-		"type":       "audio_response_ended",
-		"session_id": c.session.ID,
-		"timestamp":  time.Now().Unix(),
+	// A listening start that failed leaves the device free to listen elsewhere
+	if event.Type == conversation.EventListeningStart && event.Error != "" {
+		c.hub.releaseListening(c)
 	}
-	responseBytes, _ = json.Marshal(endMessage)
-	c.send <- WriteData{
-		Type:    websocket.TextMessage,
-		Payload: responseBytes,
+
+	if event.Type == conversation.EventListeningStart && event.Error == conversation.ErrorTranscriptionUnavailable {
+		c.closeWith(CloseSTTUnavailable)
 	}
 
-	c.logger.Info("Audio response completed",
-		zap.String("deviceID", c.deviceID),
-		zap.String("sessionID", c.session.ID))
+	if event.Type == conversation.EventError && event.Error == conversation.ErrorAudioBufferExceeded {
+		c.hub.releaseListening(c)
+		c.closeWith(CloseAudioBufferExceeded)
+	}
 }
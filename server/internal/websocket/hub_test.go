@@ -0,0 +1,198 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// runTestTurn drives a full listening turn through the client's protocol handlers
+func runTestTurn(t *testing.T, c *Client) []string {
+	t.Helper()
+	c.handleListeningStart(map[string]interface{}{"type": "listening_start"})
+	readUntil(t, c, "listening_start")
+	c.processBinaryAudioChunk([]byte{0x01, 0x02})
+	c.handleListeningEnd(map[string]interface{}{"type": "listening_end"})
+	return readUntil(t, c, "speaking_end")
+}
+
+func TestClient_TranslatesConversationEvents(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{Transcript: "halo"}, &conversationtest.TTS{Chunks: [][]byte{{0xAA}}})
+	client := newTestClient(hub, "device-1")
+
+	types := runTestTurn(t, client)
+
+	want := []string{"listening_end", "speaking_start", "binary", "speaking_end"}
+	if len(types) != len(want) {
+		t.Fatalf("Expected messages %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("Expected messages %v, got %v", want, types)
+		}
+	}
+}
+
+func TestClient_ListeningStartResponse(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{Transcript: "halo"}, &conversationtest.TTS{})
+	client := newTestClient(hub, "device-1")
+
+	client.handleListeningStart(map[string]interface{}{"type": "listening_start", "sample_rate": float64(16000)})
+	msg := readMessage(t, client, "listening_start")
+
+	if msg["message"] != "listening started" || msg["session_id"] == nil || msg["timestamp"] == nil {
+		t.Errorf("Unexpected listening_start response: %v", msg)
+	}
+}
+
+func TestHub_ForgetDevice(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{Transcript: "halo"}, &conversationtest.TTS{})
+	client := newTestClient(hub, "device-1")
+	hub.clients["device-1"] = client
+
+	runTestTurn(t, client)
+	if client.conversation.SessionID() == "" {
+		t.Fatal("Expected an active session after a turn")
+	}
+
+	hub.ForgetDevice("device-1")
+
+	if client.conversation.SessionID() != "" {
+		t.Error("Expected cached session to be cleared")
+	}
+
+	// Unknown devices are ignored
+	hub.ForgetDevice("device-unknown")
+}
+
+func TestClient_HelloNegotiatesChunkSize(t *testing.T) {
+	tts := &conversationtest.TTS{Chunks: [][]byte{{0xAA}}}
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{Transcript: "halo"}, tts)
+	client := newTestClient(hub, "device-1")
+
+	client.handleHello(map[string]interface{}{"type": "hello", "profile": "high_bandwidth"})
+	msg := readMessage(t, client, "hello")
+	if msg["chunk_size"] != float64(8192) {
+		t.Fatalf("Expected negotiated chunk_size 8192, got %v", msg["chunk_size"])
+	}
+
+	runTestTurn(t, client)
+
+	sizes := tts.ChunkSizes()
+	if len(sizes) != 1 || sizes[0] != 8192 {
+		t.Errorf("Expected the response to be synthesized with chunk size 8192, got %v", sizes)
+	}
+}
+
+func TestClient_HelloNegotiatesSampleRates(t *testing.T) {
+	tts := &conversationtest.TTS{Chunks: [][]byte{{0xAA}}}
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{Transcript: "halo"}, tts)
+	client := newTestClient(hub, "device-1")
+
+	client.handleHello(map[string]interface{}{
+		"type":                "hello",
+		"input_sample_rate":   float64(16000),
+		"output_sample_rates": []interface{}{float64(16000), float64(22050)},
+	})
+	msg := readMessage(t, client, "hello")
+	if msg["input_sample_rate"] != float64(16000) || msg["output_sample_rate"] != float64(22050) {
+		t.Fatalf("Expected 16000 in and 22050 out, got %v", msg)
+	}
+
+	runTestTurn(t, client)
+
+	if rates := tts.SampleRates(); len(rates) != 1 || rates[0] != 22050 {
+		t.Errorf("Expected the response to be synthesized at 22050 Hz, got %v", rates)
+	}
+}
+
+func TestClient_HelloRejectsUnsupportedSampleRates(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{Transcript: "halo"}, &conversationtest.TTS{})
+	client := newTestClient(hub, "device-1")
+
+	client.handleHello(map[string]interface{}{
+		"type":                "hello",
+		"output_sample_rates": []interface{}{float64(11025)},
+	})
+	msg := readMessage(t, client, "hello")
+	if msg["error"] != conversation.ErrorUnsupportedAudio || msg["supported_output_sample_rates"] == nil {
+		t.Fatalf("Expected an unsupported_audio error listing the supported rates, got %v", msg)
+	}
+
+	client.handleListeningStart(map[string]interface{}{"type": "listening_start"})
+	if msg := readMessage(t, client, "listening_start"); msg["error"] != conversation.ErrorUnsupportedAudio {
+		t.Errorf("Expected listening to be refused with unsupported_audio, got %v", msg)
+	}
+}
+
+func TestHandleWebSocketWithAuth_RefusesMissingDeviceID(t *testing.T) {
+	logger := zap.NewNop()
+	engine, err := conversation.NewEngine(conversation.Config{}, &conversationtest.LLM{}, &conversationtest.TTS{}, &conversationtest.STT{}, &conversationtest.SessionRepository{}, adapters.NewMemoryChildRepository(), adapters.NewMemoryQuietHoursRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub, err := NewHub(HubConfig{}, engine, adapters.NewMemoryDeviceRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go hub.Run()
+
+	e := echo.New()
+	e.GET("/ws", func(c echo.Context) error {
+		return HandleWebSocketWithAuth(hub, c, "", logger)
+	})
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err == nil {
+		conn.Close()
+		t.Fatal("Expected the upgrade to be refused")
+	}
+	if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401, got %v (%v)", resp, err)
+	}
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	if len(hub.clients) != 0 {
+		t.Errorf("Expected no client to be registered, got %d", len(hub.clients))
+	}
+}
+
+func TestNewHub_RejectsMissingDependencies(t *testing.T) {
+	logger := zap.NewNop()
+	engine, err := conversation.NewEngine(conversation.Config{}, &conversationtest.LLM{}, &conversationtest.TTS{}, &conversationtest.STT{}, &conversationtest.SessionRepository{}, adapters.NewMemoryChildRepository(), adapters.NewMemoryQuietHoursRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices := adapters.NewMemoryDeviceRepository()
+	bus := events.NewBus(logger)
+
+	if hub, err := NewHub(HubConfig{}, nil, devices, bus, logger); err == nil || hub != nil {
+		t.Errorf("Expected the missing engine to be rejected, got hub %v and error %v", hub, err)
+	}
+	if hub, err := NewHub(HubConfig{}, engine, nil, bus, logger); err == nil || hub != nil {
+		t.Errorf("Expected the missing device repository to be rejected, got hub %v and error %v", hub, err)
+	}
+	if hub, err := NewHub(HubConfig{}, engine, devices, nil, logger); err == nil || hub != nil {
+		t.Errorf("Expected the missing publisher to be rejected, got hub %v and error %v", hub, err)
+	}
+
+	hub, err := NewHub(HubConfig{}, engine, devices, bus, logger)
+	if err != nil {
+		t.Fatalf("Expected the hub to be created, got %v", err)
+	}
+	if hub.engine != engine || hub.devices == nil || hub.publisher == nil {
+		t.Errorf("Expected every injected dependency to be stored, got %+v", hub)
+	}
+}
@@ -0,0 +1,74 @@
+package websocket
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/internal/conversation"
+)
+
+// idleCheckPeriod returns how often the hub looks for idle connections
+func (h *Hub) idleCheckPeriod() time.Duration {
+	return h.config.IdleTimeout / 4
+}
+
+// touch records conversational activity on the connection. WebSocket pings
+// and pongs only prove liveness and never count as activity.
+func (c *Client) touch() {
+	c.activityMu.Lock()
+	defer c.activityMu.Unlock()
+	c.lastActivity = c.hub.now()
+}
+
+// trackSpeaking marks the connection busy while the doll answers, so that a
+// long response never counts against the idle timeout
+func (c *Client) trackSpeaking(event conversation.Event) {
+	switch event.Type {
+	case conversation.EventSpeakingStart:
+		c.activityMu.Lock()
+		c.speaking = true
+		c.activityMu.Unlock()
+	case conversation.EventSpeakingEnd:
+		c.activityMu.Lock()
+		c.speaking = false
+		c.activityMu.Unlock()
+		c.touch()
+	}
+}
+
+// claimIdle reports whether the connection has had no conversational
+// activity for the timeout, marking it so that it is reaped only once
+func (c *Client) claimIdle(now time.Time, timeout time.Duration) bool {
+	c.activityMu.Lock()
+	defer c.activityMu.Unlock()
+	if c.reaped || c.speaking || c.sleeping || now.Sub(c.lastActivity) < timeout {
+		return false
+	}
+	c.reaped = true
+	return true
+}
+
+// reapIdle says goodbye to and disconnects every connection that has been
+// idle for longer than the idle timeout. Connections that are listening,
+// speaking or sleeping are exempt.
+func (h *Hub) reapIdle() {
+	now := h.now()
+
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for _, client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		if h.isListening(client) || !client.claimIdle(now, h.config.IdleTimeout) {
+			continue
+		}
+		h.logger.Info("Reaping idle connection",
+			zap.String("deviceID", client.deviceID),
+			zap.Duration("idleTimeout", h.config.IdleTimeout))
+		go client.closeWith(CloseIdle)
+	}
+}
@@ -0,0 +1,181 @@
+package websocket
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// fakeClock is a settable time source for the idle reaper
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// registerTestClient adds a client to the hub as if it had connected
+func registerTestClient(hub *Hub, deviceID string) *Client {
+	client := newTestClient(hub, deviceID)
+	hub.mu.Lock()
+	hub.clients[deviceID] = client
+	hub.mu.Unlock()
+	return client
+}
+
+// assertNothingQueued fails when the client was sent any message
+func assertNothingQueued(t *testing.T, c *Client) {
+	t.Helper()
+	select {
+	case data := <-c.send:
+		t.Fatalf("Expected no message, got %s", data.Payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHub_ReapsIdleButPingingConnection(t *testing.T) {
+	// The connection outlives the test's logger, so log nowhere
+	logger := zap.NewNop()
+	engine, err := conversation.NewEngine(conversation.Config{}, &conversationtest.LLM{}, &conversationtest.TTS{}, &conversationtest.STT{}, &conversationtest.SessionRepository{}, adapters.NewMemoryChildRepository(), adapters.NewMemoryQuietHoursRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub, err := NewHub(HubConfig{IdleTimeout: time.Hour}, engine, adapters.NewMemoryDeviceRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{now: time.Now()}
+	hub.now = clock.Now
+	go hub.Run()
+
+	e := echo.New()
+	e.GET("/ws", func(c echo.Context) error {
+		return HandleWebSocketWithAuth(hub, c, "device-1", logger)
+	})
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	pongs := make(chan struct{}, 16)
+	conn.SetPongHandler(func(string) error {
+		pongs <- struct{}{}
+		return nil
+	})
+	messages := make(chan string, 16)
+	go func() {
+		defer close(messages)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			messages <- string(data)
+		}
+	}()
+
+	ping := func() {
+		t.Helper()
+		if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)); err != nil {
+			t.Fatalf("Failed to ping: %v", err)
+		}
+		select {
+		case <-pongs:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for a pong")
+		}
+	}
+
+	// Healthy at the WebSocket layer but never starting a conversation
+	for i := 0; i < 3; i++ {
+		ping()
+		clock.Advance(20 * time.Minute)
+		if i < 2 {
+			hub.reapIdle()
+		}
+	}
+	select {
+	case msg := <-messages:
+		t.Fatalf("Expected the connection to survive before the idle timeout, got %s", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	hub.reapIdle()
+
+	select {
+	case msg := <-messages:
+		if !strings.Contains(msg, `"reason":"idle"`) || !strings.Contains(msg, `"type":"close"`) {
+			t.Fatalf("Expected an idle goodbye, got %s", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the idle goodbye")
+	}
+	select {
+	case _, open := <-messages:
+		if open {
+			t.Fatal("Expected the connection to be closed after the goodbye")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the connection to close")
+	}
+}
+
+func TestHub_IdleReaperExemptsActiveClients(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{Transcript: "halo"}, &conversationtest.TTS{})
+	hub.config.IdleTimeout = time.Minute
+	clock := &fakeClock{now: time.Now()}
+	hub.now = clock.Now
+
+	listening := registerTestClient(hub, "device-listening")
+	hub.acquireListening(listening)
+	speaking := registerTestClient(hub, "device-speaking")
+	speaking.trackSpeaking(conversation.Event{Type: conversation.EventSpeakingStart})
+	talking := registerTestClient(hub, "device-talking")
+
+	clock.Advance(50 * time.Second)
+	talking.processMessage([]byte(`{"type":"hello"}`))
+	readMessage(t, talking, "hello")
+	clock.Advance(50 * time.Second)
+	hub.reapIdle()
+
+	for _, client := range []*Client{listening, speaking, talking} {
+		assertNothingQueued(t, client)
+	}
+
+	// Once the response ends the idle timeout starts over
+	speaking.trackSpeaking(conversation.Event{Type: conversation.EventSpeakingEnd})
+	clock.Advance(time.Minute)
+	hub.reapIdle()
+	if msg := readClose(t, speaking); msg["reason"] != "idle" || msg["retry"] != true || msg["retry_after_ms"] != nil {
+		t.Errorf("Unexpected idle close message: %v", msg)
+	}
+
+	// A reaped connection is closed only once
+	hub.reapIdle()
+	assertNothingQueued(t, speaking)
+}
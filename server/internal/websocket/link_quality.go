@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// With HubConfig.AdaptiveAudio, pings carry the time they were sent and the
+// pong echoing it measures the round trip time of the connection. The
+// smoothed round trip time and its jitter tell a poor link from a good one,
+// and the output sample rate of the conversation follows: the lowest suitable
+// rate the device declared on a poor link, the negotiated one on a good link.
+
+// linkQuality tracks the round trip time of a connection the way TCP does,
+// RFC 6298, and whether its link is poor
+type linkQuality struct {
+	mu      sync.Mutex
+	samples int
+	srtt    time.Duration
+	jitter  time.Duration
+	poor    bool
+}
+
+// observe adds a round trip time and reports whether the link is poor and
+// whether it just changed. A link turns poor once its smoothed round trip
+// time reaches high or its jitter maxJitter, and good again only once they
+// are below low and maxJitter, so that a link around one threshold does not
+// flap between rates.
+func (l *linkQuality) observe(rtt, high, low, maxJitter time.Duration) (bool, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.samples == 0 {
+		l.srtt, l.jitter = rtt, rtt/2
+	} else {
+		deviation := l.srtt - rtt
+		if deviation < 0 {
+			deviation = -deviation
+		}
+		l.jitter = (3*l.jitter + deviation) / 4
+		l.srtt = (7*l.srtt + rtt) / 8
+	}
+	l.samples++
+
+	poor := l.poor
+	if l.srtt >= high || l.jitter >= maxJitter {
+		poor = true
+	} else if l.srtt <= low {
+		poor = false
+	}
+	changed := poor != l.poor
+	l.poor = poor
+	return poor, changed
+}
+
+// pingPeriod returns the time between pings, shorter with adaptive audio so
+// that the round trip time follows the link
+func (c *Client) pingPeriod() time.Duration {
+	if c.hub.config.AdaptiveAudio {
+		return c.hub.config.AdaptiveAudioProbeInterval
+	}
+	return pingPeriod
+}
+
+// pingPayload returns the application data of the next ping, the time it is
+// sent with adaptive audio
+func (c *Client) pingPayload() []byte {
+	if !c.hub.config.AdaptiveAudio {
+		return nil
+	}
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, uint64(c.hub.now().UnixNano()))
+	return payload
+}
+
+// observePong measures the round trip time of the ping a pong echoes, and
+// adapts the output sample rate when the link changed. Pongs without the
+// time of their ping are ignored.
+func (c *Client) observePong(appData string) {
+	if !c.hub.config.AdaptiveAudio || len(appData) != 8 {
+		return
+	}
+	sent := time.Unix(0, int64(binary.BigEndian.Uint64([]byte(appData))))
+	rtt := c.hub.now().Sub(sent)
+	if rtt < 0 {
+		return
+	}
+
+	config := c.hub.config
+	poor, changed := c.link.observe(rtt, config.AdaptiveAudioHighRTT, config.AdaptiveAudioLowRTT, config.AdaptiveAudioMaxJitter)
+	if !changed {
+		return
+	}
+	rate, switched := c.conversation.AdaptOutputSampleRate(poor)
+	c.logger.Info("Device link quality changed",
+		zap.String("deviceID", c.deviceID),
+		zap.Bool("poorLink", poor),
+		zap.Duration("rtt", rtt),
+		zap.Int("outputSampleRate", rate))
+	if !switched {
+		return
+	}
+	// The device plays the next response at the new rate, which its
+	// speaking_start repeats
+	c.sendControl(map[string]interface{}{
+		"type":               "audio_format",
+		"output_sample_rate": rate,
+		"poor_link":          poor,
+	})
+}
@@ -0,0 +1,115 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+// pong simulates the pong of a ping answered after rtt
+func pong(c *Client, clock *fakeClock, rtt time.Duration) {
+	payload := c.pingPayload()
+	clock.Advance(rtt)
+	c.observePong(string(payload))
+}
+
+func TestClient_AdaptsOutputSampleRateToRTT(t *testing.T) {
+	tts := &conversationtest.TTS{Chunks: [][]byte{{0xAA}}}
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{Transcript: "halo"}, tts)
+	hub.config.AdaptiveAudio = true
+	clock := &fakeClock{now: time.Now()}
+	hub.now = clock.Now
+	client := newTestClient(hub, "device-1")
+
+	client.handleHello(map[string]interface{}{
+		"type":                "hello",
+		"output_sample_rates": []interface{}{float64(8000), float64(16000), float64(24000)},
+	})
+	if msg := readMessage(t, client, "hello"); msg["output_sample_rate"] != float64(24000) {
+		t.Fatalf("Expected 24000 Hz negotiated, got %v", msg)
+	}
+
+	// A high round trip time lowers the rate to the lowest one still clear
+	pong(client, clock, 500*time.Millisecond)
+	msg := readMessage(t, client, "audio_format")
+	if msg["output_sample_rate"] != float64(16000) || msg["poor_link"] != true {
+		t.Fatalf("Expected 16000 Hz on the poor link, got %v", msg)
+	}
+	runTestTurn(t, client)
+
+	// The link recovers once its smoothed round trip time is low again
+	for i := 0; i < 30; i++ {
+		pong(client, clock, 20*time.Millisecond)
+	}
+	msg = readMessage(t, client, "audio_format")
+	if msg["output_sample_rate"] != float64(24000) || msg["poor_link"] != false {
+		t.Fatalf("Expected 24000 Hz back on the good link, got %v", msg)
+	}
+	runTestTurn(t, client)
+
+	rates := tts.SampleRates()
+	if len(rates) != 2 || rates[0] != 16000 || rates[1] != 24000 {
+		t.Errorf("Expected the responses synthesized at 16000 then 24000 Hz, got %v", rates)
+	}
+}
+
+func TestClient_AnnouncesOutputSampleRateInSpeakingStart(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{Transcript: "halo"}, &conversationtest.TTS{Chunks: [][]byte{{0xAA}}})
+	hub.config.AdaptiveAudio = true
+	client := newTestClient(hub, "device-1")
+
+	client.handleHello(map[string]interface{}{
+		"type":                "hello",
+		"output_sample_rates": []interface{}{float64(16000), float64(22050)},
+	})
+	readMessage(t, client, "hello")
+	client.conversation.AdaptOutputSampleRate(true)
+
+	client.handleListeningStart(map[string]interface{}{"type": "listening_start"})
+	readUntil(t, client, "listening_start")
+	client.processBinaryAudioChunk([]byte{0x01, 0x02})
+	client.handleListeningEnd(map[string]interface{}{"type": "listening_end"})
+	if msg := readMessage(t, client, "speaking_start"); msg["output_sample_rate"] != float64(16000) {
+		t.Errorf("Expected speaking_start at 16000 Hz, got %v", msg)
+	}
+}
+
+func TestClient_KeepsOutputSampleRateWithoutAdaptiveAudio(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{Transcript: "halo"}, &conversationtest.TTS{})
+	clock := &fakeClock{now: time.Now()}
+	hub.now = clock.Now
+	client := newTestClient(hub, "device-1")
+
+	client.handleHello(map[string]interface{}{
+		"type":                "hello",
+		"output_sample_rates": []interface{}{float64(16000), float64(24000)},
+	})
+	readMessage(t, client, "hello")
+
+	if payload := client.pingPayload(); payload != nil {
+		t.Errorf("Expected plain pings, got %v", payload)
+	}
+	client.observePong(string(make([]byte, 8)))
+	assertNothingQueued(t, client)
+}
+
+func TestLinkQuality_Hysteresis(t *testing.T) {
+	var link linkQuality
+	high, low, jitter := 300*time.Millisecond, 100*time.Millisecond, 100*time.Millisecond
+
+	if poor, changed := link.observe(50*time.Millisecond, high, low, jitter); poor || changed {
+		t.Fatalf("Expected a good link, got poor %v changed %v", poor, changed)
+	}
+	if poor, changed := link.observe(2*time.Second, high, low, jitter); !poor || !changed {
+		t.Fatalf("Expected the link to turn poor, got poor %v changed %v", poor, changed)
+	}
+	// Between the thresholds the link stays poor
+	for i := 0; i < 50; i++ {
+		link.observe(200*time.Millisecond, high, low, jitter)
+	}
+	if poor, _ := link.observe(200*time.Millisecond, high, low, jitter); !poor {
+		t.Error("Expected the link to stay poor between the thresholds")
+	}
+}
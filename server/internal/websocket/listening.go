@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// errorBusy rejects a listening start while the device is already listening
+// on another connection
+const errorBusy = "busy"
+
+// acquireListening claims the device's single listening session for c.
+// It fails while another connection of the same device is listening.
+func (h *Hub) acquireListening(c *Client) bool {
+	h.listeningMu.Lock()
+	defer h.listeningMu.Unlock()
+	if holder, ok := h.listening[c.deviceID]; ok && holder != c {
+		return false
+	}
+	h.listening[c.deviceID] = c
+	return true
+}
+
+// releaseListening gives up the device's listening session if c holds it
+func (h *Hub) releaseListening(c *Client) {
+	h.listeningMu.Lock()
+	defer h.listeningMu.Unlock()
+	if h.listening[c.deviceID] == c {
+		delete(h.listening, c.deviceID)
+	}
+}
+
+// isListening reports whether c holds its device's listening session
+func (h *Hub) isListening(c *Client) bool {
+	h.listeningMu.Lock()
+	defer h.listeningMu.Unlock()
+	return h.listening[c.deviceID] == c
+}
+
+// rejectBusy answers a listening start that lost the device to another connection
+func (c *Client) rejectBusy() {
+	c.logger.Info("Rejecting listening start, device is listening on another connection",
+		zap.String("deviceID", c.deviceID))
+	c.sendControl(map[string]interface{}{
+		"type":      "listening_start",
+		"error":     errorBusy,
+		"timestamp": time.Now().Unix(),
+	})
+}
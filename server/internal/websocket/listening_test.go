@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestHub_OneListeningSessionPerDevice(t *testing.T) {
+	stt := &conversationtest.STT{Transcript: "halo"}
+	hub := newTestHub(t, conversation.Config{}, stt, &conversationtest.TTS{})
+	first := newTestClient(hub, "device-1")
+	second := newTestClient(hub, "device-1")
+	other := newTestClient(hub, "device-2")
+
+	first.handleListeningStart(map[string]interface{}{"type": "listening_start"})
+	if msg := readMessage(t, first, "listening_start"); msg["error"] != nil {
+		t.Fatalf("Expected the first connection to start listening, got %v", msg)
+	}
+
+	second.handleListeningStart(map[string]interface{}{"type": "listening_start"})
+	if msg := readMessage(t, second, "listening_start"); msg["error"] != errorBusy {
+		t.Fatalf("Expected the second connection of the device to be busy, got %v", msg)
+	}
+	if streams := len(stt.Streams()); streams != 1 {
+		t.Fatalf("Expected a single STT stream, got %d", streams)
+	}
+
+	other.handleListeningStart(map[string]interface{}{"type": "listening_start"})
+	if msg := readMessage(t, other, "listening_start"); msg["error"] != nil {
+		t.Errorf("Expected another device to listen unaffected, got %v", msg)
+	}
+
+	first.handleListeningEnd(map[string]interface{}{"type": "listening_end"})
+	readMessage(t, first, "listening_end")
+
+	second.handleListeningStart(map[string]interface{}{"type": "listening_start"})
+	if msg := readMessage(t, second, "listening_start"); msg["error"] != nil {
+		t.Errorf("Expected the second connection to listen once the first finished, got %v", msg)
+	}
+	readMessage(t, first, "speaking_end")
+}
+
+func TestHub_FailedListeningStartReleasesDevice(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{InitErr: errors.New("stt down")}, &conversationtest.TTS{})
+	first := newTestClient(hub, "device-1")
+	second := newTestClient(hub, "device-1")
+
+	first.handleListeningStart(map[string]interface{}{"type": "listening_start"})
+	if msg := readMessage(t, first, "listening_start"); msg["error"] == nil {
+		t.Fatalf("Expected the listening start to fail, got %v", msg)
+	}
+
+	second.handleListeningStart(map[string]interface{}{"type": "listening_start"})
+	if msg := readMessage(t, second, "listening_start"); msg["error"] == errorBusy {
+		t.Error("Expected a failed listening start not to keep the device busy")
+	}
+}
@@ -0,0 +1,79 @@
+package websocket
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Browsers name the page opening a WebSocket in its Origin header, which is
+// checked so that other sites cannot talk to the hub with the credentials of
+// a visitor. Devices send no Origin and are never refused for it.
+
+// normalizeOrigins trims and lowercases the allowed origins, dropping empty
+// ones and trailing slashes
+func normalizeOrigins(origins []string) []string {
+	var normalized []string
+	for _, origin := range origins {
+		origin = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+		if origin != "" {
+			normalized = append(normalized, origin)
+		}
+	}
+	return normalized
+}
+
+// originAllowed reports whether a connection requested by r may be upgraded.
+// Without allowed origins configured, only pages of the hub's own host may
+// connect.
+func (h *Hub) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if len(h.config.AllowedOrigins) == 0 {
+		return strings.EqualFold(u.Host, r.Host)
+	}
+	for _, allowed := range h.config.AllowedOrigins {
+		if matchOrigin(allowed, u) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrigin reports whether origin matches pattern, a host optionally
+// preceded by a scheme. A host starting with "*." matches its subdomains,
+// whatever their port.
+func matchOrigin(pattern string, origin *url.URL) bool {
+	scheme, host, found := strings.Cut(pattern, "://")
+	if !found {
+		scheme, host = "", pattern
+	}
+	if scheme != "" && !strings.EqualFold(scheme, origin.Scheme) {
+		return false
+	}
+	if domain, ok := strings.CutPrefix(host, "*."); ok {
+		return strings.HasSuffix(strings.ToLower(origin.Hostname()), "."+domain)
+	}
+	return strings.EqualFold(host, origin.Host)
+}
+
+// checkOrigin refuses a connection whose origin is not allowed with 403
+// before it is upgraded
+func (h *Hub) checkOrigin(r *http.Request, logger *zap.Logger) error {
+	if h.originAllowed(r) {
+		return nil
+	}
+	logger.Warn("WebSocket connection rejected: origin not allowed",
+		zap.String("origin", r.Header.Get("Origin")),
+		zap.String("path", r.URL.Path))
+	return echo.NewHTTPError(http.StatusForbidden, "origin not allowed")
+}
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+func TestHub_OriginAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{"device without origin", []string{"https://dashboard.arunika.app"}, "", true},
+		{"exact match", []string{"https://dashboard.arunika.app"}, "https://dashboard.arunika.app", true},
+		{"exact match ignoring case", []string{" HTTPS://Dashboard.Arunika.app/ "}, "https://dashboard.arunika.app", true},
+		{"other scheme", []string{"https://dashboard.arunika.app"}, "http://dashboard.arunika.app", false},
+		{"other host", []string{"https://dashboard.arunika.app"}, "https://evil.example", false},
+		{"host without scheme", []string{"dashboard.arunika.app"}, "http://dashboard.arunika.app", true},
+		{"wildcard subdomain", []string{"*.arunika.app"}, "https://doll.arunika.app:8443", true},
+		{"wildcard nested subdomain", []string{"*.arunika.app"}, "https://a.b.arunika.app", true},
+		{"wildcard apex", []string{"*.arunika.app"}, "https://arunika.app", false},
+		{"wildcard lookalike", []string{"*.arunika.app"}, "https://evilarunika.app", false},
+		{"wildcard with scheme", []string{"https://*.arunika.app"}, "http://doll.arunika.app", false},
+		{"malformed origin", []string{"*.arunika.app"}, "://", false},
+		{"same host without allowed origins", nil, "http://server.local:8080", true},
+		{"other host without allowed origins", nil, "http://evil.example", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := &Hub{config: HubConfig{AllowedOrigins: normalizeOrigins(tt.allowed)}}
+			r := httptest.NewRequest(http.MethodGet, "http://server.local:8080/ws", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+
+			if got := hub.originAllowed(r); got != tt.want {
+				t.Errorf("Expected origin %q allowed to be %v, got %v", tt.origin, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHandleWebSocketWithAuth_RefusesDisallowedOrigin(t *testing.T) {
+	// The connection outlives the test's logger, so log nowhere
+	logger := zap.NewNop()
+	engine, err := conversation.NewEngine(conversation.Config{}, &conversationtest.LLM{}, &conversationtest.TTS{}, &conversationtest.STT{}, &conversationtest.SessionRepository{}, adapters.NewMemoryChildRepository(), adapters.NewMemoryQuietHoursRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub, err := NewHub(HubConfig{AllowedOrigins: []string{"*.arunika.app"}}, engine, adapters.NewMemoryDeviceRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go hub.Run()
+
+	e := echo.New()
+	e.GET("/ws", func(c echo.Context) error {
+		return HandleWebSocketWithAuth(hub, c, "device-1", logger)
+	})
+	server := httptest.NewServer(e)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://evil.example"}})
+	if err == nil {
+		conn.Close()
+		t.Fatal("Expected the upgrade to be refused")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected status 403, got %v (%v)", resp, err)
+	}
+
+	conn, _, err = websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://dashboard.arunika.app"}})
+	if err != nil {
+		t.Fatalf("Expected an allowed origin to connect, got %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,62 @@
+package websocket
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Devices that detect speech on their own usually send listening_start only
+// once the child is already talking, clipping the first syllable. With
+// PreRollMax set, binary audio that arrives while the device is not listening
+// is pre-roll: the hub keeps the most recent PreRollMax of it and streams it
+// to speech-to-text ahead of the live audio of the next listening session.
+// Firmware can either flush its own capture buffer right before
+// listening_start or stream the microphone continuously. The protocol is
+// documented in docs/websocket-pre-roll.md.
+
+// bytesPerSample is the size of a LINEAR16 mono sample
+const bytesPerSample = 2
+
+// preRollLimit returns how many bytes of pre-roll the client keeps
+func (c *Client) preRollLimit() int {
+	if c.hub.config.PreRollMax <= 0 {
+		return 0
+	}
+	samples := int64(c.conversation.InputSampleRate()) * int64(c.hub.config.PreRollMax) / int64(time.Second)
+	return int(samples) * bytesPerSample
+}
+
+// bufferPreRoll keeps audio received while the device is not listening,
+// dropping the oldest audio beyond the limit. It reports whether the audio
+// was taken as pre-roll.
+func (c *Client) bufferPreRoll(audio []byte) bool {
+	limit := c.preRollLimit()
+	if limit == 0 || c.conversation.Listening() {
+		return false
+	}
+
+	if len(audio) >= limit {
+		c.preRoll = append(c.preRoll[:0], audio[len(audio)-limit:]...)
+		return true
+	}
+	if overflow := len(c.preRoll) + len(audio) - limit; overflow > 0 {
+		// Drop whole samples so that the audio stays aligned
+		overflow += overflow % bytesPerSample
+		c.preRoll = append(c.preRoll[:0], c.preRoll[overflow:]...)
+	}
+	c.preRoll = append(c.preRoll, audio...)
+	return true
+}
+
+// takePreRoll returns the buffered pre-roll and starts a new buffer
+func (c *Client) takePreRoll() []byte {
+	preRoll := c.preRoll
+	c.preRoll = nil
+	if len(preRoll) > 0 {
+		c.logger.Debug("Prepending pre-roll audio to the listening session",
+			zap.String("deviceID", c.deviceID),
+			zap.Int("size", len(preRoll)))
+	}
+	return preRoll
+}
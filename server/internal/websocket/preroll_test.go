@@ -0,0 +1,106 @@
+package websocket
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestClient_StreamsPreRollBeforeLiveAudio(t *testing.T) {
+	stt := &conversationtest.STT{Transcript: "halo"}
+	hub := newTestHub(t, conversation.Config{}, stt, &conversationtest.TTS{})
+	// 100ms of 16-bit audio at the default 48000 Hz
+	hub.config.PreRollMax = 100 * time.Millisecond
+	client := newTestClient(hub, "device-1")
+
+	// Too much pre-roll: only the most recent 9600 bytes are kept
+	client.processBinaryAudioChunk(bytes.Repeat([]byte{0x01}, 6000))
+	client.processBinaryAudioChunk(bytes.Repeat([]byte{0x02}, 6000))
+	client.processBinaryAudioChunk(bytes.Repeat([]byte{0x03}, 6000))
+
+	client.handleListeningStart(map[string]interface{}{"type": "listening_start"})
+	readUntil(t, client, "listening_start")
+	client.processBinaryAudioChunk([]byte{0x04, 0x04})
+	client.handleListeningEnd(map[string]interface{}{"type": "listening_end"})
+	readUntil(t, client, "speaking_end")
+
+	chunks := stt.Streams()[0].Chunks()
+	if len(chunks) != 2 {
+		t.Fatalf("Expected the pre-roll and one live chunk, got %d chunks", len(chunks))
+	}
+	want := append(bytes.Repeat([]byte{0x02}, 3600), bytes.Repeat([]byte{0x03}, 6000)...)
+	if !bytes.Equal(chunks[0], want) {
+		t.Errorf("Expected the most recent 9600 bytes as pre-roll, got %d bytes starting with %#x", len(chunks[0]), chunks[0][0])
+	}
+	if !bytes.Equal(chunks[1], []byte{0x04, 0x04}) {
+		t.Errorf("Expected the live audio after the pre-roll, got %v", chunks[1])
+	}
+
+	// The pre-roll is used only once
+	runTestTurn(t, client)
+	if chunks := stt.Streams()[1].Chunks(); len(chunks) != 1 {
+		t.Errorf("Expected only the live chunk in the next session, got %d chunks", len(chunks))
+	}
+}
+
+func TestClient_HeaderedPreRollDoesNotStartListening(t *testing.T) {
+	stt := &conversationtest.STT{Transcript: "halo"}
+	hub := newTestHub(t, conversation.Config{}, stt, &conversationtest.TTS{})
+	hub.config.PreRollMax = 100 * time.Millisecond
+	client := newTestClient(hub, "device-1")
+	client.audioHeaders = true
+
+	client.processBinaryAudioChunk(appendAudioHeader(audioHeader{Flags: audioFlagPreRoll}, []byte{0x01, 0x01}))
+	assertNothingQueued(t, client)
+	if client.conversation.Listening() {
+		t.Fatal("Expected pre-roll not to start listening")
+	}
+
+	client.processBinaryAudioChunk(appendAudioHeader(audioHeader{Sequence: 1}, []byte{0x02, 0x02}))
+	readUntil(t, client, "listening_start")
+	client.processBinaryAudioChunk(appendAudioHeader(audioHeader{Flags: audioFlagFinal, Sequence: 2}, []byte{0x03, 0x03}))
+	readUntil(t, client, "speaking_end")
+
+	chunks := stt.Streams()[0].Chunks()
+	want := [][]byte{{0x01, 0x01}, {0x02, 0x02}, {0x03, 0x03}}
+	if len(chunks) != len(want) {
+		t.Fatalf("Expected chunks %v, got %v", want, chunks)
+	}
+	for i := range want {
+		if !bytes.Equal(chunks[i], want[i]) {
+			t.Errorf("Expected chunks %v, got %v", want, chunks)
+		}
+	}
+}
+
+func TestClient_KeepsNoPreRollByDefault(t *testing.T) {
+	stt := &conversationtest.STT{Transcript: "halo"}
+	// The conversation would keep the early audio itself
+	hub := newTestHub(t, conversation.Config{EarlyAudioMax: -1}, stt, &conversationtest.TTS{})
+	client := newTestClient(hub, "device-1")
+
+	client.processBinaryAudioChunk([]byte{0x01, 0x01})
+	runTestTurn(t, client)
+
+	if chunks := stt.Streams()[0].Chunks(); len(chunks) != 1 {
+		t.Errorf("Expected audio before listening_start to be dropped, got %d chunks", len(chunks))
+	}
+}
+
+func TestClient_StreamsEarlyAudioWithoutPreRoll(t *testing.T) {
+	stt := &conversationtest.STT{Transcript: "halo"}
+	hub := newTestHub(t, conversation.Config{}, stt, &conversationtest.TTS{})
+	client := newTestClient(hub, "device-1")
+
+	// Firmware streaming slightly before its listening_start
+	client.processBinaryAudioChunk([]byte{0x01, 0x01})
+	runTestTurn(t, client)
+
+	chunks := stt.Streams()[0].Chunks()
+	if len(chunks) != 2 || !bytes.Equal(chunks[0], []byte{0x01, 0x01}) {
+		t.Errorf("Expected the early audio streamed first, got %v", chunks)
+	}
+}
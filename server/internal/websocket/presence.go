@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/domain/errs"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// disconnectReason classifies the error that stopped the read pump. A close
+// the server initiated keeps its own reason.
+func (c *Client) disconnectReason(err error) (string, int) {
+	c.closeMu.Lock()
+	reason, code := c.closeReason, c.closeCode
+	c.closeMu.Unlock()
+	if reason != "" {
+		return string(reason), code
+	}
+
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		return events.ReasonAbnormal, 0
+	}
+	switch closeErr.Code {
+	case websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived:
+		return events.ReasonClean, closeErr.Code
+	default:
+		return events.ReasonAbnormal, closeErr.Code
+	}
+}
+
+// deviceOnline records that the device of c connected
+func (h *Hub) deviceOnline(c *Client) {
+	now := h.now()
+	h.queuePresence(c.deviceID, func(device *entities.Device) {
+		device.Connect(now)
+		recorded := *device
+		c.setDeviceRecord(&recorded)
+	})
+	h.publisher.Publish(events.Event{
+		Type:      events.DeviceOnline,
+		DeviceID:  c.deviceID,
+		Timestamp: now,
+	})
+}
+
+// deviceOffline records that the last connection of the device of c closed
+func (h *Hub) deviceOffline(c *Client) {
+	now := h.now()
+	h.queuePresence(c.deviceID, func(device *entities.Device) {
+		device.Disconnect(now)
+	})
+
+	reason := c.offlineReason
+	if reason == "" {
+		reason = events.ReasonAbnormal
+	}
+	h.logger.Info("Device offline",
+		zap.String("deviceID", c.deviceID),
+		zap.String("reason", reason),
+		zap.Int("closeCode", c.offlineCode))
+	h.publisher.Publish(events.Event{
+		Type:      events.DeviceOffline,
+		DeviceID:  c.deviceID,
+		Reason:    reason,
+		CloseCode: c.offlineCode,
+		Timestamp: now,
+	})
+}
+
+// queuePresence has change applied to the stored device in the background,
+// after the changes queued before for the device. Slow storage never holds
+// up the hub or the connection.
+func (h *Hub) queuePresence(deviceID string, change func(device *entities.Device)) {
+	h.pendingPresenceMu.Lock()
+	changes, storing := h.pendingPresence[deviceID]
+	h.pendingPresence[deviceID] = append(changes, change)
+	h.pendingPresenceMu.Unlock()
+
+	if !storing {
+		go h.storePresence(deviceID)
+	}
+}
+
+// storePresence stores the changes queued for a device until none is left
+func (h *Hub) storePresence(deviceID string) {
+	for {
+		h.pendingPresenceMu.Lock()
+		changes := h.pendingPresence[deviceID]
+		if len(changes) == 0 {
+			delete(h.pendingPresence, deviceID)
+			h.pendingPresenceMu.Unlock()
+			return
+		}
+		h.pendingPresence[deviceID] = changes[len(changes):]
+		h.pendingPresenceMu.Unlock()
+
+		h.updatePresence(deviceID, changes...)
+	}
+}
+
+// presencePending reports whether changes to device records are yet to be
+// stored
+func (h *Hub) presencePending() bool {
+	h.pendingPresenceMu.Lock()
+	defer h.pendingPresenceMu.Unlock()
+	return len(h.pendingPresence) > 0
+}
+
+// presenceUpdateAttempts bounds the attempts to store a change to a device
+// record that was updated by another caller meanwhile
+const presenceUpdateAttempts = 3
+
+// updatePresence applies changes to the stored device in order. Connections
+// of devices that are not registered, such as during development, are not
+// recorded. Changes to a device are stored by one worker at a time, see
+// queuePresence; the device repository refuses to store a device updated
+// elsewhere since it was read, which is read again.
+func (h *Hub) updatePresence(deviceID string, changes ...func(device *entities.Device)) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var err error
+	for attempt := 0; attempt < presenceUpdateAttempts; attempt++ {
+		var device *entities.Device
+		device, err = h.devices.GetByID(ctx, deviceID)
+		if err != nil {
+			h.logger.Debug("Not recording presence of unknown device",
+				zap.String("deviceID", deviceID),
+				zap.Error(err))
+			return
+		}
+		for _, change := range changes {
+			change(device)
+		}
+		err = h.devices.Update(ctx, device)
+		if !errors.Is(err, errs.ErrDeviceModified) {
+			break
+		}
+	}
+	if err != nil {
+		h.logger.Warn("Failed to record device presence",
+			zap.String("deviceID", deviceID),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,266 @@
+package websocket
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/domain/entities"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// presenceFixture serves a running hub over a real WebSocket endpoint
+type presenceFixture struct {
+	hub     *Hub
+	devices *adapters.MemoryDeviceRepository
+	device  *entities.Device
+	clock   *fakeClock
+	events  *events.Subscription
+	url     string
+}
+
+func newPresenceFixture(t *testing.T) *presenceFixture {
+	t.Helper()
+	// Connections outlive the test's logger, so log nowhere
+	logger := zap.NewNop()
+	bus := events.NewBus(logger)
+	f := &presenceFixture{
+		devices: adapters.NewMemoryDeviceRepository(),
+		device:  &entities.Device{SerialNumber: "ARU-0001", Model: "doll-v1"},
+		clock:   &fakeClock{now: time.Now()},
+		events:  bus.Subscribe(0),
+	}
+	if err := f.devices.Create(context.Background(), f.device); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+
+	engine, err := conversation.NewEngine(conversation.Config{}, &conversationtest.LLM{}, &conversationtest.TTS{}, &conversationtest.STT{}, &conversationtest.SessionRepository{}, adapters.NewMemoryChildRepository(), adapters.NewMemoryQuietHoursRepository(), bus, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub, err := NewHub(HubConfig{}, engine, f.devices, bus, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.hub = hub
+	f.hub.now = f.clock.Now
+	go f.hub.Run()
+
+	e := echo.New()
+	e.GET("/ws", func(c echo.Context) error {
+		return HandleWebSocketWithAuth(f.hub, c, f.device.ID, logger)
+	})
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	f.url = "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	return f
+}
+
+func (f *presenceFixture) dial(t *testing.T) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(f.url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// next returns the next published lifecycle event
+func (f *presenceFixture) next(t *testing.T) events.Event {
+	t.Helper()
+	select {
+	case event := <-f.events.Events():
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a lifecycle event")
+		return events.Event{}
+	}
+}
+
+// waitPresenceStored waits until the hub stored every change to device records
+func waitPresenceStored(t *testing.T, hub *Hub) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.presencePending() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for device records to be stored")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHub_PublishesDeviceOffline(t *testing.T) {
+	tests := []struct {
+		name       string
+		disconnect func(conn *websocket.Conn)
+		wantReason string
+		wantCode   int
+	}{
+		{
+			name: "clean close",
+			disconnect: func(conn *websocket.Conn) {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			},
+			wantReason: events.ReasonClean,
+			wantCode:   websocket.CloseNormalClosure,
+		},
+		{
+			name: "connection dropped",
+			disconnect: func(conn *websocket.Conn) {
+				conn.UnderlyingConn().Close()
+			},
+			wantReason: events.ReasonAbnormal,
+			wantCode:   websocket.CloseAbnormalClosure,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPresenceFixture(t)
+			conn := f.dial(t)
+
+			if online := f.next(t); online.Type != events.DeviceOnline || online.DeviceID != f.device.ID {
+				t.Fatalf("Expected device_online, got %+v", online)
+			}
+
+			f.clock.Advance(90 * time.Second)
+			tt.disconnect(conn)
+
+			offline := f.next(t)
+			if offline.Type != events.DeviceOffline || offline.DeviceID != f.device.ID {
+				t.Fatalf("Expected device_offline, got %+v", offline)
+			}
+			if offline.Reason != tt.wantReason || offline.CloseCode != tt.wantCode {
+				t.Errorf("Expected reason %q with code %d, got %q with code %d", tt.wantReason, tt.wantCode, offline.Reason, offline.CloseCode)
+			}
+
+			waitPresenceStored(t, f.hub)
+			device, _ := f.devices.GetByID(context.Background(), f.device.ID)
+			if device.Online || device.UptimeSeconds != 90 || !device.LastDisconnectedAt.Equal(f.clock.Now()) {
+				t.Errorf("Expected the device offline after 90s of uptime, got %+v", device)
+			}
+		})
+	}
+}
+
+func TestHub_ReplacedConnectionKeepsDeviceOnline(t *testing.T) {
+	f := newPresenceFixture(t)
+	first := f.dial(t)
+	f.next(t)
+
+	f.dial(t)
+	// The first connection is told it was replaced and closed by the server
+	for {
+		if _, _, err := first.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	select {
+	case event := <-f.events.Events():
+		t.Fatalf("Expected no lifecycle event while the device stays connected, got %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+	waitPresenceStored(t, f.hub)
+	if device, _ := f.devices.GetByID(context.Background(), f.device.ID); !device.Online {
+		t.Error("Expected the device to stay online")
+	}
+}
+
+// Run with -race: changes to the device record made at once, such as the
+// firmware version of a hello while the connection is recorded, are all kept
+func TestHub_ConcurrentPresenceUpdatesAreNotLost(t *testing.T) {
+	f := newPresenceFixture(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.hub.queuePresence(f.device.ID, func(device *entities.Device) {
+				device.UptimeSeconds++
+			})
+		}()
+	}
+	wg.Wait()
+	waitPresenceStored(t, f.hub)
+
+	stored, err := f.devices.GetByID(context.Background(), f.device.ID)
+	if err != nil {
+		t.Fatalf("Failed to get device: %v", err)
+	}
+	if stored.UptimeSeconds != 20 {
+		t.Errorf("Expected every change kept, got %d of 20", stored.UptimeSeconds)
+	}
+}
+
+// blockingDevices is a device repository whose reads wait until released
+type blockingDevices struct {
+	*adapters.MemoryDeviceRepository
+	release chan struct{}
+}
+
+func (d *blockingDevices) GetByID(ctx context.Context, id string) (*entities.Device, error) {
+	<-d.release
+	return d.MemoryDeviceRepository.GetByID(ctx, id)
+}
+
+func TestHub_SlowDeviceStorageDoesNotStallConnections(t *testing.T) {
+	f := newPresenceFixture(t)
+	devices := &blockingDevices{MemoryDeviceRepository: f.devices, release: make(chan struct{})}
+	f.hub.devices = devices
+
+	// Each connection is announced and closed while the first record is
+	// still being read
+	for i := 0; i < 3; i++ {
+		conn := f.dial(t)
+		if online := f.next(t); online.Type != events.DeviceOnline {
+			t.Fatalf("Expected device_online, got %+v", online)
+		}
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		if offline := f.next(t); offline.Type != events.DeviceOffline {
+			t.Fatalf("Expected device_offline, got %+v", offline)
+		}
+	}
+
+	close(devices.release)
+	waitPresenceStored(t, f.hub)
+	device, _ := f.devices.GetByID(context.Background(), f.device.ID)
+	if device.Online || device.LastConnectedAt.IsZero() {
+		t.Errorf("Expected the device recorded offline after its connections, got %+v", device)
+	}
+}
+
+// The device is updated elsewhere, as when it authenticates, while its
+// presence is being stored
+func TestHub_PresenceKeepsConcurrentDeviceUpdates(t *testing.T) {
+	f := newPresenceFixture(t)
+	interleaved := false
+	f.hub.updatePresence(f.device.ID, func(device *entities.Device) {
+		device.Online = true
+		if interleaved {
+			return
+		}
+		interleaved = true
+		stored, _ := f.devices.GetByID(context.Background(), f.device.ID)
+		stored.FirmwareVersion = "2.0.3"
+		if err := f.devices.Update(context.Background(), stored); err != nil {
+			t.Errorf("Failed to update device: %v", err)
+		}
+	})
+
+	stored, _ := f.devices.GetByID(context.Background(), f.device.ID)
+	if !stored.Online || stored.FirmwareVersion != "2.0.3" {
+		t.Errorf("Expected both updates stored, got %+v", stored)
+	}
+}
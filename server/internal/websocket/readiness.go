@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/domain/repositories"
+)
+
+const (
+	// readinessCheckTimeout bounds the health check of each dependency
+	readinessCheckTimeout = 5 * time.Second
+	// readinessRetry is the time between health checks until the
+	// dependencies first become healthy, or are healthy again
+	readinessRetry = time.Second
+)
+
+// Dependency is a service conversations cannot go without
+type Dependency struct {
+	Name    string
+	Checker repositories.HealthChecker
+}
+
+// Ready reports whether the hub accepts new connections, which it stops
+// doing for good once Shutdown was called
+func (h *Hub) Ready() bool {
+	return h.ready.Load() && !h.draining.Load()
+}
+
+// RetryAfter returns the reconnect delay suggested to devices whose
+// connection was refused or closed for a transient reason
+func (h *Hub) RetryAfter() time.Duration {
+	return h.config.RetryAfter
+}
+
+// MonitorReadiness makes the hub refuse new connections until every
+// dependency passes its health check, then checks them again every
+// ReadinessInterval until ctx is done. After ReadinessFailures failed checks
+// in a row the hub refuses connections again until the dependencies recover.
+// Connections already established are left alone.
+func (h *Hub) MonitorReadiness(ctx context.Context, dependencies []Dependency) {
+	h.ready.Store(false)
+	go func() {
+		failures := 0
+		for {
+			if err := h.checkDependencies(ctx, dependencies); err != nil {
+				failures++
+				if h.ready.Load() && failures >= h.config.ReadinessFailures {
+					h.ready.Store(false)
+					h.logger.Error("Dependencies unhealthy, refusing new connections",
+						zap.Int("failures", failures),
+						zap.Error(err))
+				} else {
+					h.logger.Warn("Dependency health check failed",
+						zap.Int("failures", failures),
+						zap.Error(err))
+				}
+			} else {
+				failures = 0
+				if !h.ready.Swap(true) {
+					h.logger.Info("Dependencies healthy, accepting connections")
+				}
+			}
+
+			wait := h.config.ReadinessInterval
+			if !h.ready.Load() {
+				wait = min(wait, readinessRetry)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+	}()
+}
+
+// checkDependencies returns the error of the first unhealthy dependency
+func (h *Hub) checkDependencies(ctx context.Context, dependencies []Dependency) error {
+	for _, dependency := range dependencies {
+		checkCtx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+		err := dependency.Checker.CheckHealth(checkCtx)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("%s: %w", dependency.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package websocket
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+func TestHub_ResumesListeningAfterBlip(t *testing.T) {
+	// The connections outlive the test's logger, so log nowhere
+	logger := zap.NewNop()
+	stt := &conversationtest.STT{Transcript: "halo boneka"}
+	engine, err := conversation.NewEngine(conversation.Config{ResumeGrace: time.Minute}, &conversationtest.LLM{Reply: "Halo juga!"},
+		&conversationtest.TTS{Chunks: [][]byte{{0xAA}}}, stt,
+		&conversationtest.SessionRepository{}, adapters.NewMemoryChildRepository(), adapters.NewMemoryQuietHoursRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub, err := NewHub(HubConfig{}, engine, adapters.NewMemoryDeviceRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go hub.Run()
+
+	e := echo.New()
+	e.GET("/ws", func(c echo.Context) error {
+		return HandleWebSocketWithAuth(hub, c, "device-1", logger)
+	})
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	dial := func() *websocket.Conn {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	write := func(conn *websocket.Conn, messageType int, data string) {
+		t.Helper()
+		if err := conn.WriteMessage(messageType, []byte(data)); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+
+	lost := dial()
+	write(lost, websocket.TextMessage, `{"type":"listening_start"}`)
+	sessionID, _ := readControl(t, lost, "listening_start")["session_id"].(string)
+	write(lost, websocket.BinaryMessage, "\x01")
+	deadline := time.Now().Add(5 * time.Second)
+	for len(stt.Streams()) == 0 || len(stt.Streams()[0].Chunks()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the audio to reach speech-to-text")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The network drops mid-utterance and the device reconnects
+	lost.UnderlyingConn().Close()
+	conn := dial()
+	write(conn, websocket.TextMessage, `{"type":"listening_start","resume_session_id":"`+sessionID+`"}`)
+	if msg := readControl(t, conn, "listening_start"); msg["resumed"] != true || msg["session_id"] != sessionID {
+		t.Fatalf("Expected session %q to be resumed, got %v", sessionID, msg)
+	}
+	write(conn, websocket.BinaryMessage, "\x02")
+	write(conn, websocket.TextMessage, `{"type":"listening_end"}`)
+
+	msg := readControl(t, conn, "listening_end")
+	if chat, _ := msg["chat"].(map[string]interface{}); chat["content"] != "halo boneka" {
+		t.Fatalf("Expected the transcription of the whole utterance, got %v", msg)
+	}
+	streams := stt.Streams()
+	if len(streams) != 1 || !bytes.Equal(bytes.Join(streams[0].Chunks(), nil), []byte{0x01, 0x02}) {
+		t.Errorf("Expected the audio of both connections on one STT stream")
+	}
+}
+
+func TestHub_DisconnectReleasesListeningStream(t *testing.T) {
+	logger := zap.NewNop()
+	stt := &conversationtest.STT{Transcript: "halo boneka"}
+	engine, err := conversation.NewEngine(conversation.Config{}, &conversationtest.LLM{Reply: "Halo juga!"},
+		&conversationtest.TTS{Chunks: [][]byte{{0xAA}}}, stt,
+		&conversationtest.SessionRepository{}, adapters.NewMemoryChildRepository(), adapters.NewMemoryQuietHoursRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub, err := NewHub(HubConfig{}, engine, adapters.NewMemoryDeviceRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go hub.Run()
+
+	e := echo.New()
+	e.GET("/ws", func(c echo.Context) error {
+		return HandleWebSocketWithAuth(hub, c, "device-1", logger)
+	})
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"listening_start"}`)); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	readControl(t, conn, "listening_start")
+	if err := conn.WriteMessage(websocket.BinaryMessage, []byte{0x01}); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	// The device goes away mid-utterance, with nothing to resume
+	conn.UnderlyingConn().Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(stt.Streams()) == 0 || !stt.Streams()[0].Released() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the stream of the listening session to be closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stt.Streams()[0].Ended() {
+		t.Error("Expected the stream to be closed without being transcribed")
+	}
+}
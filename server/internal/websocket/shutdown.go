@@ -0,0 +1,61 @@
+package websocket
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// shutdownPollInterval is the time between checks for the connections left
+// while the hub drains
+const shutdownPollInterval = 50 * time.Millisecond
+
+// Shutdown drains the hub before the server stops: it refuses new
+// connections, asks every connected device to reconnect after a restart with
+// a server_shutdown close and waits for the connections to close. The audio
+// queued for a device is written before its close frame. Shutdown returns
+// once no device is connected and their records are stored, or with the
+// error of ctx when it is done first.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.draining.Store(true)
+
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for _, client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	h.logger.Info("Draining WebSocket connections", zap.Int("connections", len(clients)))
+	for _, client := range clients {
+		go client.closeWith(CloseServerShutdown)
+	}
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		remaining := h.connectedCount()
+		// The devices that disconnected are recorded offline before the
+		// server stops
+		if remaining == 0 && !h.presencePending() {
+			h.logger.Info("WebSocket connections drained")
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			h.logger.Warn("Gave up draining WebSocket connections",
+				zap.Int("remaining", remaining),
+				zap.Error(ctx.Err()))
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// connectedCount returns the number of registered connections
+func (h *Hub) connectedCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
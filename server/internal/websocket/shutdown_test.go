@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+func TestHub_ShutdownDrainsConnections(t *testing.T) {
+	// The connection outlives the test's logger, so log nowhere
+	logger := zap.NewNop()
+	engine, err := conversation.NewEngine(conversation.Config{}, &conversationtest.LLM{}, &conversationtest.TTS{}, &conversationtest.STT{}, &conversationtest.SessionRepository{}, adapters.NewMemoryChildRepository(), adapters.NewMemoryQuietHoursRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub, err := NewHub(HubConfig{}, engine, adapters.NewMemoryDeviceRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub.ready.Store(true)
+	go hub.Run()
+
+	e := echo.New()
+	e.GET("/ws", func(c echo.Context) error {
+		return HandleWebSocketWithAuth(hub, c, "device-1", logger)
+	})
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	var client *Client
+	deadline := time.Now().Add(5 * time.Second)
+	for client == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the client to register")
+		}
+		hub.mu.RLock()
+		client = hub.clients["device-1"]
+		hub.mu.RUnlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Audio still queued when the server stops reaches the device first
+	client.queue(WriteData{Type: websocket.BinaryMessage, Payload: []byte{1, 2, 3, 4}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- hub.Shutdown(ctx) }()
+
+	var received []string
+	var closeErr *websocket.CloseError
+	for closeErr == nil {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			if !errors.As(err, &closeErr) {
+				t.Fatalf("Expected a close frame, got %v", err)
+			}
+			break
+		}
+		if messageType == websocket.BinaryMessage {
+			received = append(received, "binary")
+			continue
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("Failed to parse message: %v", err)
+		}
+		if msg["type"] == "close" {
+			if msg["reason"] != "server_shutdown" || msg["retry"] != true {
+				t.Errorf("Unexpected close message: %v", msg)
+			}
+			received = append(received, "close")
+		}
+	}
+
+	if strings.Join(received, ",") != "binary,close" {
+		t.Errorf("Expected the queued audio before the close message, got %v", received)
+	}
+	if closeErr.Code != websocket.CloseServiceRestart || closeErr.Text != "server_shutdown" {
+		t.Errorf("Expected close code %d with server_shutdown, got %d %q", websocket.CloseServiceRestart, closeErr.Code, closeErr.Text)
+	}
+
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Errorf("Expected the hub to drain, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for Shutdown to return")
+	}
+	if hub.Ready() {
+		t.Error("Expected the hub to refuse new connections after shutdown")
+	}
+}
+
+func TestHub_ShutdownGivesUpWhenContextDone(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{}, &conversationtest.TTS{})
+	// The connections are closed in the background, possibly after the test
+	hub.logger = zap.NewNop()
+	// Never closes, since no pumps are running
+	client := registerTestClient(hub, "device-1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := hub.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to be exceeded, got %v", err)
+	}
+
+	msg := readClose(t, client)
+	if msg["reason"] != "server_shutdown" {
+		t.Errorf("Unexpected close message: %v", msg)
+	}
+}
+
+func TestHub_ShutdownClosesConnectionsRegisteredLate(t *testing.T) {
+	hub := newTestHub(t, conversation.Config{}, &conversationtest.STT{}, &conversationtest.TTS{})
+	// The connections are closed in the background, possibly after the test
+	hub.logger = zap.NewNop()
+	go hub.Run()
+
+	if err := hub.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Expected an empty hub to drain at once, got %v", err)
+	}
+
+	// Upgraded before the hub began draining, registered after
+	client := newTestClient(hub, "device-1")
+	hub.register <- client
+
+	msg := readClose(t, client)
+	if msg["reason"] != "server_shutdown" {
+		t.Errorf("Unexpected close message: %v", msg)
+	}
+}
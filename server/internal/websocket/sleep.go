@@ -0,0 +1,72 @@
+package websocket
+
+import (
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ActiveDevice is a device connected to the hub
+type ActiveDevice struct {
+	DeviceID string `json:"device_id"`
+	// Sleeping is set while the doll sleeps: it stays connected but does not
+	// converse
+	Sleeping bool `json:"sleeping"`
+}
+
+// GetActiveDevices returns the devices connected to the hub, ordered by ID
+func (h *Hub) GetActiveDevices() []ActiveDevice {
+	h.mu.RLock()
+	devices := make([]ActiveDevice, 0, len(h.clients))
+	for deviceID, client := range h.clients {
+		devices = append(devices, ActiveDevice{DeviceID: deviceID, Sleeping: client.isSleeping()})
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i].DeviceID < devices[j].DeviceID })
+	return devices
+}
+
+// isSleeping reports whether the doll of the connection is sleeping
+func (c *Client) isSleeping() bool {
+	c.activityMu.Lock()
+	defer c.activityMu.Unlock()
+	return c.sleeping
+}
+
+// handleSleep puts the doll to sleep. The connection stays open, but
+// listening starts and audio are ignored until the doll wakes, and an
+// utterance in progress is dropped.
+func (c *Client) handleSleep() {
+	c.activityMu.Lock()
+	c.sleeping = true
+	c.activityMu.Unlock()
+
+	c.preRoll = nil
+	if c.conversation.CancelListening() {
+		c.hub.releaseListening(c)
+	}
+
+	c.logger.Info("Device sleeping", zap.String("deviceID", c.deviceID))
+	c.sendControl(map[string]interface{}{
+		"type":      "sleep",
+		"sleeping":  true,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// handleWake lets the sleeping doll converse again
+func (c *Client) handleWake() {
+	c.activityMu.Lock()
+	c.sleeping = false
+	c.activityMu.Unlock()
+	c.touch()
+
+	c.logger.Info("Device awake", zap.String("deviceID", c.deviceID))
+	c.sendControl(map[string]interface{}{
+		"type":      "wake",
+		"sleeping":  false,
+		"timestamp": time.Now().Unix(),
+	})
+}
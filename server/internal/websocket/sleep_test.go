@@ -0,0 +1,68 @@
+package websocket
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+)
+
+func TestClient_SuppressesListeningWhileSleeping(t *testing.T) {
+	stt := &conversationtest.STT{Transcript: "halo"}
+	hub := newTestHub(t, conversation.Config{}, stt, &conversationtest.TTS{Chunks: [][]byte{{0xAA}}})
+	client := registerTestClient(hub, "device-1")
+
+	client.processMessage([]byte(`{"type":"sleep"}`))
+	if msg := readMessage(t, client, "sleep"); msg["sleeping"] != true {
+		t.Fatalf("Expected the sleep to be confirmed, got %v", msg)
+	}
+	if devices := hub.GetActiveDevices(); !slices.Equal(devices, []ActiveDevice{{DeviceID: "device-1", Sleeping: true}}) {
+		t.Errorf("Expected the device to be reported sleeping, got %v", devices)
+	}
+
+	// A sleeping doll quietly ignores the turn
+	client.processMessage([]byte(`{"type":"listening_start"}`))
+	client.processBinaryAudioChunk([]byte{0x01, 0x02})
+	client.processMessage([]byte(`{"type":"listening_end"}`))
+	assertNothingQueued(t, client)
+	if streams := len(stt.Streams()); streams != 0 {
+		t.Fatalf("Expected no transcription while sleeping, got %d streams", streams)
+	}
+
+	client.processMessage([]byte(`{"type":"wake"}`))
+	if msg := readMessage(t, client, "wake"); msg["sleeping"] != false {
+		t.Fatalf("Expected the wake to be confirmed, got %v", msg)
+	}
+	if devices := hub.GetActiveDevices(); devices[0].Sleeping {
+		t.Errorf("Expected the device to be reported awake, got %v", devices)
+	}
+	if types := runTestTurn(t, client); !slices.Contains(types, "speaking_start") {
+		t.Errorf("Expected the doll to answer once awake, got %v", types)
+	}
+}
+
+func TestClient_SleepDropsUtteranceInProgress(t *testing.T) {
+	stt := &conversationtest.STT{Transcript: "halo"}
+	hub := newTestHub(t, conversation.Config{}, stt, &conversationtest.TTS{})
+	client := registerTestClient(hub, "device-1")
+
+	client.handleListeningStart(map[string]interface{}{"type": "listening_start"})
+	readMessage(t, client, "listening_start")
+	client.processMessage([]byte(`{"type":"sleep"}`))
+	readMessage(t, client, "sleep")
+
+	if client.conversation.Listening() || hub.isListening(client) {
+		t.Error("Expected the listening session to be dropped")
+	}
+	stream := stt.Streams()[0]
+	deadline := time.Now().Add(5 * time.Second)
+	for !stream.Released() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the dropped stream to be closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assertNothingQueued(t, client)
+}
@@ -0,0 +1,297 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/internal/conversation"
+)
+
+// An operator console attaches to the live conversation of a device for
+// support: it receives the child's transcriptions and the doll's responses,
+// and types the responses the doll speaks instead of the LLM's. Only one
+// operator takes over a device at a time; the conversation is handed back
+// to the LLM when the console detaches or disconnects. The protocol is
+// documented in docs/websocket-operator-takeover.md.
+
+// Operators only send short control messages
+const operatorMaxMessageSize = 8 * 1024
+
+// operatorConsole is the connection of the operator who took over the
+// conversation of a device
+type operatorConsole struct {
+	operatorID string
+	deviceID   string
+	conn       *websocket.Conn
+	send       chan []byte
+	done       chan struct{}
+	logger     *zap.Logger
+}
+
+// sendControl queues a JSON control message for the operator, dropping it
+// when the console is gone or too slow
+func (o *operatorConsole) sendControl(payload map[string]interface{}) {
+	data, _ := json.Marshal(payload)
+	select {
+	case <-o.done:
+	case o.send <- data:
+	default:
+		o.logger.Warn("Dropping message to a slow operator console",
+			zap.String("deviceID", o.deviceID),
+			zap.String("operatorID", o.operatorID))
+	}
+}
+
+// HandleOperatorWebSocket attaches the console of operatorID to the live
+// conversation of the device. Devices not connected and conversations
+// another operator took over are refused before the upgrade.
+func HandleOperatorWebSocket(hub *Hub, c echo.Context, deviceID, operatorID string, logger *zap.Logger) error {
+	if deviceID == "" || operatorID == "" {
+		logger.Warn("Operator console rejected: missing device or operator ID")
+		return echo.NewHTTPError(http.StatusBadRequest, "device and operator IDs are required")
+	}
+	if err := hub.checkOrigin(c.Request(), logger); err != nil {
+		return err
+	}
+
+	client, ok := hub.client(deviceID)
+	if !ok {
+		logger.Warn("Operator console rejected: device not connected",
+			zap.String("deviceID", deviceID),
+			zap.String("operatorID", operatorID))
+		return echo.NewHTTPError(http.StatusNotFound, "device not connected")
+	}
+
+	console := &operatorConsole{
+		operatorID: operatorID,
+		deviceID:   deviceID,
+		send:       make(chan []byte, 64),
+		done:       make(chan struct{}),
+		logger:     logger,
+	}
+	if !hub.claimTakeover(console) {
+		logger.Warn("Operator console rejected: conversation already taken over",
+			zap.String("deviceID", deviceID),
+			zap.String("operatorID", operatorID))
+		return echo.NewHTTPError(http.StatusConflict, "conversation already taken over")
+	}
+
+	conn, err := hub.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		hub.releaseTakeover(console)
+		logger.Error("Operator console upgrade failed", zap.Error(err))
+		return err
+	}
+	console.conn = conn
+
+	client.conversation.TakeOver(operatorID)
+	logger.Info("Operator attached to the conversation",
+		zap.String("deviceID", deviceID),
+		zap.String("operatorID", operatorID))
+	console.sendControl(map[string]interface{}{
+		"type":      "attached",
+		"device_id": deviceID,
+	})
+
+	go console.writePump()
+	go console.readPump(hub)
+	return nil
+}
+
+// readPump reads the operator's messages until the operator detaches or
+// disconnects, then hands the conversation back. The write pump closes the
+// connection.
+func (o *operatorConsole) readPump(hub *Hub) {
+	defer func() {
+		hub.releaseTakeover(o)
+		close(o.done)
+	}()
+
+	o.conn.SetReadLimit(operatorMaxMessageSize)
+	o.conn.SetReadDeadline(time.Now().Add(pongWait))
+	o.conn.SetPongHandler(func(string) error {
+		o.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		_, data, err := o.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure, websocket.CloseNormalClosure) {
+				o.logger.Warn("Operator console error", zap.String("deviceID", o.deviceID), zap.Error(err))
+			}
+			return
+		}
+
+		var msg struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			o.sendControl(map[string]interface{}{"type": "error", "error": "invalid_message"})
+			continue
+		}
+		switch msg.Type {
+		case "respond":
+			hub.operatorRespond(o, msg.Text)
+		case "detach":
+			return
+		default:
+			o.sendControl(map[string]interface{}{"type": "error", "error": "unknown_message_type"})
+		}
+	}
+}
+
+// writePump writes the queued messages until the console is gone, then
+// confirms the detach and closes the connection
+func (o *operatorConsole) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		o.conn.Close()
+	}()
+
+	for {
+		select {
+		case data := <-o.send:
+			o.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := o.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				o.logger.Error("Failed to write to operator console", zap.Error(err))
+				return
+			}
+
+		case <-ticker.C:
+			o.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := o.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+		case <-o.done:
+			o.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			o.conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"detached"}`))
+			o.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "detached"))
+			return
+		}
+	}
+}
+
+// client returns the connection of the device
+func (h *Hub) client(deviceID string) (*Client, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	client, ok := h.clients[deviceID]
+	return client, ok
+}
+
+// claimTakeover makes console the operator of its device, reporting false
+// when another operator holds it
+func (h *Hub) claimTakeover(console *operatorConsole) bool {
+	h.operatorsMu.Lock()
+	defer h.operatorsMu.Unlock()
+	if _, taken := h.operators[console.deviceID]; taken {
+		return false
+	}
+	h.operators[console.deviceID] = console
+	return true
+}
+
+// releaseTakeover hands the conversation console took over back to the LLM
+func (h *Hub) releaseTakeover(console *operatorConsole) {
+	h.operatorsMu.Lock()
+	if h.operators[console.deviceID] != console {
+		h.operatorsMu.Unlock()
+		return
+	}
+	delete(h.operators, console.deviceID)
+	h.operatorsMu.Unlock()
+
+	if client, ok := h.client(console.deviceID); ok {
+		client.conversation.HandBack(console.operatorID)
+	}
+	h.logger.Info("Operator detached from the conversation",
+		zap.String("deviceID", console.deviceID),
+		zap.String("operatorID", console.operatorID))
+}
+
+// operatorOf returns the console of the operator who took over the device,
+// nil when none did
+func (h *Hub) operatorOf(deviceID string) *operatorConsole {
+	h.operatorsMu.Lock()
+	defer h.operatorsMu.Unlock()
+	return h.operators[deviceID]
+}
+
+// resumeTakeover keeps the conversation of a reconnecting device with the
+// operator who took it over
+func (h *Hub) resumeTakeover(client *Client) {
+	console := h.operatorOf(client.deviceID)
+	if console == nil {
+		return
+	}
+	client.conversation.TakeOver(console.operatorID)
+	console.sendControl(map[string]interface{}{"type": "device_connected"})
+}
+
+// operatorRespond has the device speak the response typed by the operator
+func (h *Hub) operatorRespond(console *operatorConsole, text string) {
+	client, ok := h.client(console.deviceID)
+	if !ok {
+		console.sendControl(map[string]interface{}{"type": "error", "error": "device_offline"})
+		return
+	}
+
+	err := client.conversation.SpeakAsOperator(console.operatorID, text)
+	switch {
+	case err == nil:
+		return
+	case errors.Is(err, conversation.ErrEmptyResponse):
+		console.sendControl(map[string]interface{}{"type": "error", "error": "empty_response"})
+	case errors.Is(err, conversation.ErrNoSession):
+		console.sendControl(map[string]interface{}{"type": "error", "error": "no_session"})
+	default:
+		console.sendControl(map[string]interface{}{"type": "error", "error": "not_taken_over"})
+	}
+}
+
+// forwardToOperator passes what the child said and the doll answered on to
+// the operator who took over the device
+func (c *Client) forwardToOperator(event conversation.Event) {
+	console := c.hub.operatorOf(c.deviceID)
+	if console == nil {
+		return
+	}
+
+	payload := map[string]interface{}{
+		"session_id": event.SessionID,
+	}
+	switch event.Type {
+	case conversation.EventListeningEnd:
+		if event.Message == nil {
+			return
+		}
+		payload["type"] = "transcription"
+		payload["text"] = event.Message.Content
+	case conversation.EventSpeakingStart:
+		payload["type"] = "speaking_start"
+		if event.Message != nil {
+			payload["text"] = event.Message.Content
+			if event.Message.OperatorID != "" {
+				payload["operator_id"] = event.Message.OperatorID
+			}
+		}
+	case conversation.EventSpeakingEnd:
+		payload["type"] = "speaking_end"
+	case conversation.EventError:
+		payload["type"] = "error"
+		payload["error"] = event.Error
+	default:
+		return
+	}
+	console.sendControl(payload)
+}
@@ -0,0 +1,144 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/satriahrh/arunika/server/adapters"
+	"github.com/satriahrh/arunika/server/internal/conversation"
+	"github.com/satriahrh/arunika/server/internal/conversation/conversationtest"
+	"github.com/satriahrh/arunika/server/internal/events"
+)
+
+// newTakeoverFixture serves devices on /ws/:device and operator consoles on
+// /takeover/:device/:operator
+func newTakeoverFixture(t *testing.T) *audioChannelFixture {
+	t.Helper()
+	// The connections outlive the test's logger, so log nowhere
+	logger := zap.NewNop()
+	engine, err := conversation.NewEngine(conversation.Config{}, &conversationtest.LLM{Reply: "Halo juga!"},
+		&conversationtest.TTS{Chunks: [][]byte{{0xAA}, {0xBB}}}, &conversationtest.STT{Transcript: "aku takut"},
+		&conversationtest.SessionRepository{}, adapters.NewMemoryChildRepository(), adapters.NewMemoryQuietHoursRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub, err := NewHub(HubConfig{}, engine, adapters.NewMemoryDeviceRepository(), events.NewBus(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go hub.Run()
+
+	e := echo.New()
+	e.GET("/ws/:device", func(c echo.Context) error {
+		return HandleWebSocketWithAuth(hub, c, c.Param("device"), logger)
+	})
+	e.GET("/takeover/:device/:operator", func(c echo.Context) error {
+		return HandleOperatorWebSocket(hub, c, c.Param("device"), c.Param("operator"), logger)
+	})
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	return &audioChannelFixture{url: "ws" + strings.TrimPrefix(server.URL, "http")}
+}
+
+// speakToDevice streams an utterance over the device connection
+func speakToDevice(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+	for _, msg := range []string{`{"type":"listening_start"}`, "", `{"type":"listening_end"}`} {
+		messageType, data := websocket.TextMessage, []byte(msg)
+		if msg == "" {
+			messageType, data = websocket.BinaryMessage, make([]byte, 320)
+		}
+		if err := conn.WriteMessage(messageType, data); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+}
+
+func TestHub_OperatorTakesOverConversation(t *testing.T) {
+	f := newTakeoverFixture(t)
+	if _, resp, err := f.dial(t, "/takeover/device-1/operator-1"); err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected a console for an offline device to be refused, got %v", err)
+	}
+
+	device, _, err := f.dial(t, "/ws/device-1")
+	if err != nil {
+		t.Fatalf("Failed to connect the device: %v", err)
+	}
+	// The hub registered the device once it answers
+	hello(t, device)
+
+	operator, _, err := f.dial(t, "/takeover/device-1/operator-1")
+	if err != nil {
+		t.Fatalf("Failed to attach the operator: %v", err)
+	}
+	if msg := readControl(t, operator, "attached"); msg["device_id"] != "device-1" {
+		t.Fatalf("Expected the attach to name the device, got %v", msg)
+	}
+	if _, resp, err := f.dial(t, "/takeover/device-1/operator-2"); err == nil || resp.StatusCode != http.StatusConflict {
+		t.Fatalf("Expected a second operator to be refused, got %v", err)
+	}
+
+	speakToDevice(t, device)
+	if msg := readControl(t, operator, "transcription"); msg["text"] != "aku takut" || msg["session_id"] == "" {
+		t.Fatalf("Expected the operator to receive the transcription, got %v", msg)
+	}
+	readControl(t, device, "listening_end")
+
+	if err := operator.WriteMessage(websocket.TextMessage, []byte(`{"type":"respond","text":"Tidak apa-apa, aku di sini"}`)); err != nil {
+		t.Fatalf("Failed to send the response: %v", err)
+	}
+	start := readControl(t, device, "speaking_start")
+	if chat, _ := start["chat"].(map[string]interface{}); chat["content"] != "Tidak apa-apa, aku di sini" {
+		t.Errorf("Expected the device to speak the operator's response, got %v", start)
+	}
+	device.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i < 2; i++ {
+		if messageType, _, err := device.ReadMessage(); err != nil || messageType != websocket.BinaryMessage {
+			t.Fatalf("Expected response audio, got %d: %v", messageType, err)
+		}
+	}
+	readControl(t, device, "speaking_end")
+	if msg := readControl(t, operator, "speaking_start"); msg["operator_id"] != "operator-1" {
+		t.Errorf("Expected the operator to see their response spoken, got %v", msg)
+	}
+	readControl(t, operator, "speaking_end")
+}
+
+func TestHub_OperatorDetachHandsConversationBack(t *testing.T) {
+	f := newTakeoverFixture(t)
+	device, _, err := f.dial(t, "/ws/device-1")
+	if err != nil {
+		t.Fatalf("Failed to connect the device: %v", err)
+	}
+	hello(t, device)
+
+	operator, _, err := f.dial(t, "/takeover/device-1/operator-1")
+	if err != nil {
+		t.Fatalf("Failed to attach the operator: %v", err)
+	}
+	readControl(t, operator, "attached")
+
+	if err := operator.WriteMessage(websocket.TextMessage, []byte(`{"type":"detach"}`)); err != nil {
+		t.Fatalf("Failed to detach: %v", err)
+	}
+	readControl(t, operator, "detached")
+
+	// The doll answers again, and another operator may take over
+	speakToDevice(t, device)
+	start := readControl(t, device, "speaking_start")
+	if chat, _ := start["chat"].(map[string]interface{}); chat["content"] != "Halo juga!" {
+		t.Errorf("Expected the LLM to answer after the detach, got %v", start)
+	}
+	next, _, err := f.dial(t, "/takeover/device-1/operator-2")
+	if err != nil {
+		t.Fatalf("Expected another operator to attach after the detach: %v", err)
+	}
+	readControl(t, next, "attached")
+}
@@ -45,6 +45,10 @@ func main() {
 	// Create headers with JWT token
 	headers := http.Header{}
 	headers.Add("Authorization", "Bearer "+token)
+	// Ask a server running with DETERMINISTIC_MODE_ENABLED for fake speech services
+	if os.Getenv("ARUNIKA_DETERMINISTIC") == "true" {
+		headers.Add("X-Arunika-Deterministic", "true")
+	}
 
 	c, _, err := websocket.DefaultDialer.Dial(u.String(), headers)
 	if err != nil {